@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/cache"
+	"github.com/bagtoad/imgsort/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// newCacheCmd returns the "cache" command group, which shows and clears the
+// models and caches kept in the data directory.
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Show or clear the downloaded models and the score and thumbnail caches",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "info",
+		Short: "Print where the models and caches are kept and how much space they take",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			locs, err := cache.Locations()
+			if err != nil {
+				return err
+			}
+			report.PrintCacheInfo(os.Stdout, locs)
+			return nil
+		},
+	})
+	cmd.AddCommand(newCacheClearCmd())
+	return cmd
+}
+
+// newCacheClearCmd returns the "cache clear" command.
+func newCacheClearCmd() *cobra.Command {
+	var models, scores, thumbs, yes bool
+
+	cmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Delete cached data, after confirmation",
+		Long: `clear deletes the selected data: --scores for the classification score
+cache, --thumbnails for the thumbnail cache, and --models for the downloaded
+model files, which are fetched again on the next run. Without a selection,
+both caches are cleared and the models are kept. It asks before deleting
+anything unless --yes is given.
+
+  imgsort cache clear
+  imgsort cache clear --models --yes`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !models && !scores && !thumbs {
+				scores, thumbs = true, true
+			}
+			all, err := cache.Locations()
+			if err != nil {
+				return err
+			}
+			selected := map[string]bool{cache.KindModels: models, cache.KindScores: scores, cache.KindThumbs: thumbs}
+			var locs []cache.Location
+			for _, l := range all {
+				if selected[l.Kind] && l.Files > 0 {
+					locs = append(locs, l)
+				}
+			}
+			if len(locs) == 0 {
+				fmt.Println("Nothing to clear")
+				return nil
+			}
+
+			fmt.Println("This deletes:")
+			report.PrintCacheClear(os.Stdout, locs)
+			if !yes && !confirm(cmd, "Continue?") {
+				fmt.Println("Nothing was deleted")
+				return nil
+			}
+			for _, l := range locs {
+				if err := cache.Clear(l); err != nil {
+					return fmt.Errorf("cannot clear %s: %w", l.Kind, err)
+				}
+			}
+			fmt.Printf("Cleared %d locations\n", len(locs))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&models, "models", false, "Delete the downloaded model files")
+	cmd.Flags().BoolVar(&scores, "scores", false, "Delete the classification score cache")
+	cmd.Flags().BoolVar(&scores, "embeddings", false, "Same as --scores")
+	cmd.Flags().BoolVar(&thumbs, "thumbnails", false, "Delete the thumbnail cache")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation")
+	cmd.Flags().MarkHidden("embeddings")
+	return cmd
+}
+
+// confirm asks question on stdout and reports whether the answer read from
+// the command's input was yes. End of input counts as no.
+func confirm(cmd *cobra.Command, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
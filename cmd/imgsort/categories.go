@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/spf13/cobra"
+)
+
+// newCategoriesCmd returns the "categories" command group.
+func newCategoriesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "categories",
+		Short: "Manage the category list used for classification",
+	}
+	cmd.AddCommand(newCategoriesExportCmd())
+	cmd.AddCommand(newCategoriesConvertCmd())
+	return cmd
+}
+
+// newCategoriesExportCmd returns the "categories export" command.
+func newCategoriesExportCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "export [path]",
+		Short: "Write the built-in categories to a file for editing",
+		Long: `Write the built-in category list, grouped by theme, to
+~/.imgsort/categories.txt (or the given path). Edit the file to customize
+which categories imgsort classifies into.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ""
+			if len(args) == 1 {
+				path = args[0]
+			}
+			written, err := categories.ExportDefaults(path, force)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %d categories to %s\n", len(categories.DefaultCategories), written)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite the file if it already exists")
+	return cmd
+}
+
+// newCategoriesConvertCmd returns the "categories convert" command.
+func newCategoriesConvertCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "convert <input> [output]",
+		Short: "Translate a categories file to the categories.json or categories.yaml format",
+		Long: `Translate a categories file, such as categories.txt, to the richer
+categories.json or categories.yaml format, where each category can also set
+its folder, prompt or prompts, threshold, weight, examples, and whether it is
+a negative category. The output format follows the output's extension; it
+defaults to the input path with a .json extension.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := args[0]
+			out := strings.TrimSuffix(in, filepath.Ext(in)) + ".json"
+			if len(args) == 2 {
+				out = args[1]
+			}
+			if out == in {
+				return fmt.Errorf("%s is already in the categories.json format", in)
+			}
+			write := categories.WriteSpec
+			if ext := strings.ToLower(filepath.Ext(out)); ext == ".yaml" || ext == ".yml" {
+				write = categories.WriteSpecYAML
+			}
+
+			cats, err := categories.LoadFile(in)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(out); err == nil && !force {
+				return fmt.Errorf("%s already exists (use --force to overwrite)", out)
+			}
+			f, err := os.Create(out)
+			if err != nil {
+				return fmt.Errorf("cannot create categories file: %w", err)
+			}
+			defer f.Close()
+			if err := write(f, cats); err != nil {
+				return fmt.Errorf("cannot write categories file: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %d categories to %s\n", len(cats), out)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite the output file if it already exists")
+	return cmd
+}
+
+// applyCategorySpec copies the settings in the custom categories file for
+// each of cats into the run: prompts into classifyOpts, and thresholds,
+// weights, and negative flags into opts.rules. Settings this version cannot
+// act on yet are reported and ignored.
+func applyCategorySpec(spec []categories.Category, cats []string, classifyOpts *model.ClassifyOptions, opts *options) {
+	inRun := make(map[string]bool, len(cats))
+	for _, c := range cats {
+		inRun[c] = true
+	}
+
+	for _, c := range spec {
+		if !inRun[c.Name] {
+			continue
+		}
+		if prompts := c.AllPrompts(); len(prompts) > 0 {
+			if classifyOpts.Prompts == nil {
+				classifyOpts.Prompts = make(map[string]string)
+			}
+			classifyOpts.Prompts[c.Name] = prompts[0]
+			if len(prompts) > 1 {
+				if classifyOpts.ExtraPrompts == nil {
+					classifyOpts.ExtraPrompts = make(map[string][]string)
+				}
+				classifyOpts.ExtraPrompts[c.Name] = prompts[1:]
+			}
+		}
+		if c.Threshold > 0 || c.Weight > 0 || c.Negative {
+			if opts.rules == nil {
+				opts.rules = make(map[string]categorizer.Rule)
+			}
+			opts.rules[c.Name] = categorizer.Rule{Threshold: c.Threshold, Weight: c.Weight, Negative: c.Negative}
+		}
+		if c.Folder != "" && c.Folder != c.Name {
+			fmt.Fprintf(os.Stderr, "Warning: category %q sets a folder, which is not supported yet; sorting into %q\n", c.Name, c.Name)
+		}
+		if len(c.Examples) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: category %q lists examples, which are not supported yet and are ignored\n", c.Name)
+		}
+	}
+}
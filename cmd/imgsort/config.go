@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+	"github.com/bagtoad/imgsort/internal/dirconfig"
+	"github.com/spf13/cobra"
+)
+
+// setting is one effective option and where its value came from.
+type setting struct {
+	name, value, source string
+}
+
+// newConfigCmd returns the "config" command group. root supplies the sorting
+// flags so "config show" reports the same defaults a run would use.
+func newConfigCmd(root *cobra.Command, opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect per-directory settings",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <directory>",
+		Short: "Print the settings a run in a directory would use, and where each comes from",
+		Long: `Print the effective settings for sorting a directory. Each value comes
+from a command-line flag, the directory's ` + dirconfig.FileName + ` file, the
+global ~/.imgsort/categories.txt, or the built-in default, in that order of
+precedence.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, settings, err := applyDirConfig(root.Flags().Changed, args[0], opts)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Settings for %s:\n", args[0])
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, s := range settings {
+				fmt.Fprintf(tw, "  %s\t%s\t(%s)\n", s.name, s.value, s.source)
+			}
+			return tw.Flush()
+		},
+	})
+	return cmd
+}
+
+// applyDirConfig loads dir's settings file and copies each setting into opts
+// unless its flag was given on the command line. It returns the file's
+// settings (nil if the directory has none) and every effective setting with
+// its source. Warnings about unknown keys are printed.
+func applyDirConfig(changed func(string) bool, dir string, opts *options) (*dirconfig.Config, []setting, error) {
+	cfg, warnings, err := dirconfig.Load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	if cfg == nil {
+		cfg = &dirconfig.Config{}
+	}
+
+	var settings []setting
+	source := func(flag string, inFile bool) string {
+		switch {
+		case changed(flag):
+			return "flag"
+		case inFile:
+			return cfg.Path
+		}
+		return "default"
+	}
+
+	// Categories and profile are chosen together: a flag for either one
+	// overrides both from the file.
+	catSource := "default"
+	customFile := ""
+	switch {
+	case changed("categories") || changed("triage"):
+		catSource = "flag"
+	case cfg.Profile != "":
+		if cfg.Profile != categories.TriageProfile.Name {
+			return nil, nil, fmt.Errorf("%s: unknown profile %q (expected %s)", cfg.Path, cfg.Profile, categories.TriageProfile.Name)
+		}
+		opts.triage = true
+		catSource = cfg.Path
+	case len(cfg.Categories) > 0:
+		opts.categories = strings.Join(cfg.Categories, ",")
+		catSource = cfg.Path
+	default:
+		if custom, err := categories.LoadCustomCategories(); err == nil && len(custom) > 0 {
+			catSource = "global"
+			customFile, _ = categories.CustomFile()
+		}
+	}
+	switch {
+	case opts.triage:
+		settings = append(settings, setting{"profile", categories.TriageProfile.Name, catSource})
+	case opts.categories != "":
+		settings = append(settings, setting{"categories", opts.categories, catSource})
+	case catSource == "global":
+		settings = append(settings, setting{"categories", customFile, catSource})
+	default:
+		settings = append(settings, setting{"categories", "built-in list", catSource})
+	}
+
+	src := source("confidence", cfg.Confidence != nil)
+	if src == cfg.Path {
+		opts.confidence = *cfg.Confidence
+		opts.confidenceSet = true
+	}
+	if src == "default" && opts.triage {
+		opts.confidence = categories.TriageProfile.Threshold
+		src = "profile"
+	}
+	settings = append(settings, setting{"confidence", strconv.FormatFloat(opts.confidence, 'g', -1, 64), src})
+
+	for _, s := range []struct {
+		flag  string
+		value string
+		dst   *string
+	}{
+		{"mode", cfg.Mode, &opts.mode},
+		{"on-conflict", cfg.OnConflict, &opts.onConflict},
+		{"secondary", cfg.Secondary, &opts.secondary},
+		{"unsorted", cfg.Unsorted, &opts.unsorted},
+	} {
+		src := source(s.flag, s.value != "")
+		if src == cfg.Path {
+			*s.dst = s.value
+		}
+		settings = append(settings, setting{s.flag, *s.dst, src})
+	}
+
+	src = source("max-per-category", cfg.MaxPerCategory != nil)
+	if src == cfg.Path {
+		opts.maxPerCat = *cfg.MaxPerCategory
+	}
+	settings = append(settings, setting{"max-per-category", strconv.Itoa(opts.maxPerCat), src})
+
+	src = source("preserve-structure", cfg.Preserve != nil)
+	if src == cfg.Path {
+		opts.preserve = *cfg.Preserve
+	}
+	settings = append(settings, setting{"preserve-structure", strconv.FormatBool(opts.preserve), src})
+
+	if cfg.Path == "" {
+		return nil, settings, nil
+	}
+	return cfg, settings, nil
+}
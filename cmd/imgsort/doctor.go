@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/spf13/cobra"
+)
+
+// newDoctorCmd returns the "doctor" command, which checks that the ONNX
+// Runtime library and model files are usable.
+func newDoctorCmd(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the ONNX Runtime library and model files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doctor(opts.onnxRuntime, opts.offline)
+		},
+	}
+}
+
+func doctor(explicitPath string, offline bool) error {
+	ok := true
+
+	info, err := model.LoadRuntime(explicitPath)
+	if err != nil {
+		fmt.Printf("ONNX Runtime library:  %s\n", model.ResolveLibraryPath(explicitPath))
+		fmt.Printf("Required version:      %s or newer\n", model.MinONNXRuntimeVersion)
+		fmt.Printf("Runtime status:        FAIL: %v\n", err)
+		ok = false
+	} else {
+		fmt.Printf("ONNX Runtime library:  %s\n", info.LibraryPath)
+		fmt.Printf("Required version:      %s or newer\n", model.MinONNXRuntimeVersion)
+		fmt.Printf("Runtime version:       %s (OK)\n", info.Version)
+		model.DestroyRuntime()
+	}
+
+	dir, err := model.ModelsDir()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Models directory:      %s\n", dir)
+	lock, err := model.ReadLock()
+	if err != nil {
+		fmt.Printf("  %-20s FAIL: %v\n", model.LockFile, err)
+		ok = false
+	}
+	for _, m := range model.RequiredFiles {
+		status := "OK"
+		path := filepath.Join(dir, m.Name)
+		if _, err := os.Stat(path); err != nil {
+			status = "missing (downloaded on next run)"
+			if offline {
+				status = "FAIL: missing (run imgsort models fetch)"
+				ok = false
+			}
+		} else if want := model.ExpectedHash(m, lock); want != "" {
+			if got, err := model.FileHash(path); err != nil || got != want {
+				status = "FAIL: does not match its recorded SHA-256"
+				ok = false
+			}
+		}
+		fmt.Printf("  %-20s %s\n", m.Name, status)
+	}
+
+	if !ok {
+		return fmt.Errorf("problems found")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/report"
+)
+
+// calibrationSize is how many images are classified up front to estimate
+// the run's duration. Runs smaller than twice this skip the estimate.
+const calibrationSize = 10
+
+// classifyWithEstimate classifies paths like ClassifyAllParallel. Unless
+// estimate is false or the run is small, it first classifies an evenly
+// spaced sample, prints the expected duration for the whole run, and then
+// classifies the rest, reusing the sample's scores. If needsModel is set,
+// the sample and the estimate cover only the paths it accepts, since the
+// others are answered from the cache. Progress is reported to prog.
+func classifyWithEstimate(clip categorizer.Classifier, paths, cats []string, classifyOpts model.ClassifyOptions,
+	workers int, estimate bool, prog *progress, needsModel func(string) bool) ([]categorizer.ImageScores, error) {
+	var timed []int // indexes of the paths the estimate covers
+	for i, p := range paths {
+		if needsModel == nil || needsModel(p) {
+			timed = append(timed, i)
+		}
+	}
+	if !estimate || len(timed) < 2*calibrationSize {
+		return categorizer.ClassifyAllEvents(clip, paths, cats, classifyOpts, workers, prog.pass(0))
+	}
+
+	// Spread the sample across the list so clusters of cached or
+	// unusually large files do not skew it.
+	inSample := make(map[int]bool, calibrationSize)
+	var sample, rest []string
+	for i := range calibrationSize {
+		inSample[timed[i*len(timed)/calibrationSize]] = true
+	}
+	for i, p := range paths {
+		if inSample[i] {
+			sample = append(sample, p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+
+	fmt.Printf("Timing a sample of %d images...\n", len(sample))
+	start := time.Now()
+	sampleScores, err := categorizer.ClassifyAllEvents(clip, sample, cats, classifyOpts, workers,
+		prog.sample())
+	if err != nil {
+		return nil, err
+	}
+	perImage := time.Since(start) / time.Duration(len(sample))
+	report.PrintEstimate(os.Stdout, len(timed), perImage*time.Duration(len(timed)))
+
+	restScores, err := categorizer.ClassifyAllEvents(clip, rest, cats, classifyOpts, workers,
+		prog.pass(len(sample)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Put the results back in the original order.
+	all := make([]categorizer.ImageScores, 0, len(paths))
+	si, ri := 0, 0
+	for i := range paths {
+		if inSample[i] {
+			all = append(all, sampleScores[si])
+			si++
+		} else {
+			all = append(all, restScores[ri])
+			ri++
+		}
+	}
+	return all, nil
+}
@@ -1,23 +1,131 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/bagtoad/imgsort/internal/appdir"
+	"github.com/bagtoad/imgsort/internal/buildinfo"
+	"github.com/bagtoad/imgsort/internal/burst"
+	"github.com/bagtoad/imgsort/internal/cache"
 	"github.com/bagtoad/imgsort/internal/categories"
 	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/exif"
+	"github.com/bagtoad/imgsort/internal/filedate"
+	"github.com/bagtoad/imgsort/internal/firstrun"
 	"github.com/bagtoad/imgsort/internal/model"
 	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/overrides"
+	"github.com/bagtoad/imgsort/internal/palette"
 	"github.com/bagtoad/imgsort/internal/report"
 	"github.com/bagtoad/imgsort/internal/scanner"
+	"github.com/bagtoad/imgsort/internal/serve"
+	"github.com/bagtoad/imgsort/internal/state"
 	"github.com/spf13/cobra"
 )
 
+// options holds the parsed command-line flags for a sorting run.
+type options struct {
+	dryRun        bool
+	categories    string
+	restrict      bool
+	confidence    float64
+	tentative     float64
+	pairwise      bool
+	baseMargin    float64
+	triage        bool
+	verbose       bool
+	suggest       bool
+	onConflict    string
+	onFolder      string
+	secondary     string
+	unsorted      string
+	openSet       string
+	maxPerCat     int
+	minCatSize    int
+	preserve      bool
+	dateFolders   bool
+	strictDates   bool
+	dateFloor     string
+	outputDir     string
+	copyFiles     bool
+	recursive     bool
+	splitLive     bool
+	noJunkFilter  bool
+	noLangWarning bool
+	transactional bool
+	ignoreVanish  bool
+	summaryLine   bool
+	reportLimit   int
+	emitScript    string
+	stream        bool
+	overridesFile string
+
+	// overrides is the --overrides file, loaded once categories are known.
+	overrides    overrides.Overrides
+	scoresIn     string
+	scoresOut    string
+	reportJSON   string
+	export       string
+	exportFile   string
+	diffPlan     string
+	diffPlanJSON string
+	scanOnly     bool
+	incremental  bool
+	samplePct    float64
+	seed         uint64
+
+	// modifiedAfter is the --incremental cutoff loaded from the run state.
+	modifiedAfter time.Time
+
+	// confidenceSet records whether --confidence was given explicitly,
+	// so profiles can supply their own default.
+	confidenceSet bool
+
+	// rules holds per-category decision settings from categories.json.
+	rules map[string]categorizer.Rule
+
+	groupBursts bool
+	burstGap    time.Duration
+
+	onnxRuntime string
+	modelsDir   string
+	offline     bool
+	remote      string
+	mode        string
+
+	// backend settings for classifying through an "imgsort serve" instance
+	backend      string
+	sharedFS     bool
+	backendConns int
+	backendWait  time.Duration
+
+	noCache     bool
+	strictCache bool
+	exifRoute   bool
+	sidecar     bool
+	xattr       bool
+	writeIndex  bool
+	smallImages string
+	expand      bool
+	promptPool  string
+	minSize     int
+	noEstimate  bool
+	fileTimeout time.Duration
+	noWarmup    bool
+	trace       string
+	progJSON    string
+}
+
 func main() {
-	var dryRun bool
-	var categoriesFlag string
-	var confidence float64
+	var opts options
 
 	rootCmd := &cobra.Command{
 		Use:   "imgsort <directory>",
@@ -28,22 +136,155 @@ in a directory and sort them into category-named subfolders.
 Images are classified using zero-shot classification against either
 a built-in set of common categories, a custom categories file
 (~/.imgsort/categories.txt), or categories provided via --categories.`,
-		Args: cobra.ExactArgs(1),
+		Args:    cobra.ExactArgs(1),
+		Version: buildinfo.Get().Version,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(args[0], dryRun, categoriesFlag, confidence)
+			if opts.scanOnly {
+				return runScan(args[0], false, scanner.Options{Recursive: opts.recursive, NoJunkFilter: opts.noJunkFilter})
+			}
+			opts.confidenceSet = cmd.Flags().Changed("confidence")
+			dirCfg, _, err := applyDirConfig(cmd.Flags().Changed, args[0], &opts)
+			if err != nil {
+				return err
+			}
+			if dirCfg != nil {
+				fmt.Printf("Applying directory settings from %s\n", dirCfg.Path)
+			}
+			firstRun := false
+			if !opts.dryRun && dirCfg == nil && !cmd.Flags().Changed("dry-run") && !cmd.Flags().Changed("no-move") {
+				if firstRun, err = firstrun.Pending(); err != nil {
+					return err
+				}
+			}
+			if firstRun {
+				fmt.Println("First run: showing what would be done without moving any files")
+				opts.dryRun = true
+			}
+			if err := run(args[0], opts); err != nil {
+				return err
+			}
+			if firstRun {
+				fmt.Println("\nNothing was moved because this was imgsort's first run.")
+				if err := firstrun.Mark(); err != nil {
+					log.Printf("Warning: %v (set %s to a writable directory to remember the first run)", err, appdir.StateEnv)
+					fmt.Println("Run the same command with --dry-run=false to move the files.")
+				} else {
+					fmt.Println("Run the same command again to move the files, or add --dry-run to preview again.")
+				}
+			}
+			return nil
 		},
 	}
 
-	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without moving files")
-	rootCmd.Flags().StringVar(&categoriesFlag, "categories", "", "Comma-separated list of categories to classify into")
-	rootCmd.Flags().Float64Var(&confidence, "confidence", 0.15, "Minimum confidence threshold for classification (0.0-1.0)")
+	rootCmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Show what would be done without moving files (the default on the first run)")
+	rootCmd.Flags().BoolVar(&opts.dryRun, "no-move", false, "Same as --dry-run")
+	rootCmd.Flags().StringVar(&opts.outputDir, "output-dir", "", "Create the category folders in this directory instead of the one being sorted")
+	rootCmd.Flags().BoolVar(&opts.copyFiles, "copy", false, "Copy images into their category folders and leave the originals in place")
+	rootCmd.Flags().StringVar(&opts.categories, "categories", "", "Comma-separated list of categories to classify into")
+	rootCmd.Flags().BoolVar(&opts.restrict, "restrict", false, "Use --categories to narrow the custom or default list instead of replacing it")
+	rootCmd.Flags().Float64Var(&opts.confidence, "confidence", 0.15, "Minimum confidence threshold for classification (0.0-1.0)")
+	rootCmd.Flags().Float64Var(&opts.tentative, "tentative-threshold", 0, "Sort images scoring between this and --confidence into category/_tentative/ for review instead of skipping them")
+	rootCmd.Flags().BoolVar(&opts.triage, "triage", false, "Quickly split images into screenshots, memes, and photos")
+	rootCmd.Flags().Float64Var(&opts.baseMargin, "baseline-margin", 0, "Skip an image only if the generic baseline prompt beats its best category by at least this much (-1 to 1)")
+	rootCmd.Flags().BoolVar(&opts.pairwise, "pairwise", false, "Score each category against the baseline only, so --confidence does not depend on the number of categories")
+	rootCmd.Flags().BoolVar(&opts.scanOnly, "scan-only", false, "Only list the images that would be classified (same as 'imgsort scan')")
+	rootCmd.Flags().BoolVarP(&opts.verbose, "verbose", "v", false, "Name the file being classified and show additional analysis, such as which categories competed")
+	rootCmd.Flags().BoolVar(&opts.suggest, "suggest", false, "After the run, suggest renaming or dropping categories that never won an image or won only with low confidence")
+	rootCmd.Flags().StringVar(&opts.onConflict, "on-conflict", "suffix", "What to do when a destination file exists: suffix, hash, timestamp, skip, or overwrite")
+	rootCmd.Flags().StringVar(&opts.onFolder, "on-folder-conflict", "fail", "What to do when a category's folder name is taken by a file: fail, suffix (category_sorted), or skip-category")
+	rootCmd.Flags().StringVar(&opts.secondary, "secondary", "none", "Also record each image under its runner-up category: none, symlink, or sidecar")
+	rootCmd.Flags().StringVar(&opts.openSet, "open-set-category", "", "Sort images the model rejects (the baseline won, or below --confidence) into a category with this name instead of leaving them")
+	rootCmd.Flags().StringVar(&opts.unsorted, "unsorted", "leave", "What to do with images that match no category: leave them in place, or move them into unsorted/")
+	rootCmd.Flags().IntVar(&opts.maxPerCat, "max-per-category", 0, "Split categories with more files than this into part-1, part-2, ... subfolders (0 = no limit)")
+	rootCmd.Flags().IntVar(&opts.minCatSize, "min-category-size", 0, "Put categories that captured fewer than this many images into misc/ instead of their own folders (0 = no minimum)")
+	rootCmd.Flags().BoolVar(&opts.recursive, "recursive", false, "Also sort images in subfolders, skipping category folders imgsort created")
+	rootCmd.Flags().Float64Var(&opts.samplePct, "sample-percent", 0, "Classify and report on a random percentage (0-100) of the images, without moving anything")
+	rootCmd.Flags().Uint64Var(&opts.seed, "seed", 1, "Random seed for --sample-percent; the same seed picks the same images")
+	rootCmd.Flags().BoolVar(&opts.incremental, "incremental", false, "Only sort images modified since the last successful run over this directory")
+	rootCmd.Flags().BoolVar(&opts.noJunkFilter, "no-junk-filter", false, "Count .DS_Store, Thumbs.db, desktop.ini, and ._ files as skipped files instead of ignoring them")
+	rootCmd.Flags().BoolVar(&opts.noLangWarning, "no-language-warning", false, "Do not warn about categories that do not look like English")
+	rootCmd.Flags().BoolVar(&opts.splitLive, "split-live-photos", false, "Sort Live Photo stills and their .MOV videos independently instead of moving each pair together")
+	rootCmd.Flags().BoolVar(&opts.preserve, "preserve-structure", false, "Mirror each image's subdirectory under its category folder instead of placing it directly inside")
+	rootCmd.Flags().BoolVar(&opts.dateFolders, "date-folders", false, "Place each image in a YYYY/MM folder inside its category folder, by its EXIF date or file time")
+	rootCmd.Flags().BoolVar(&opts.strictDates, "strict-dates", false, "With --date-folders, move images with an implausible date to unsorted/ instead of dating them by the next timestamp")
+	rootCmd.Flags().StringVar(&opts.dateFloor, "date-floor", "", "With --date-folders, the earliest plausible date, as YYYY-MM-DD (default 1990-01-01)")
+	rootCmd.Flags().StringVar(&opts.overridesFile, "overrides", "", "Assign files matching the names or globs in this file (\"IMG_4410.jpg = documents\") to a category without classifying them")
+	rootCmd.Flags().BoolVar(&opts.stream, "stream", false, "Sort each image as soon as it is classified, keeping memory flat for very large directories (prints counts instead of every file)")
+	rootCmd.Flags().StringVar(&opts.emitScript, "emit-script", "", "With --dry-run, write the planned moves as a shell script to this file, to review and run yourself")
+	rootCmd.Flags().IntVar(&opts.reportLimit, "report-limit", report.DefaultListLimit, "List at most this many files per category in the report (0 = no limit; --verbose lists them all)")
+	rootCmd.Flags().BoolVar(&opts.summaryLine, "summary-line", false, "End the output with one line summing up the run, for notifications and scripts")
+	rootCmd.Flags().BoolVar(&opts.ignoreVanish, "ignore-vanished", false, "Silently leave out images deleted or moved by something else after they were classified, instead of reporting them as skipped")
+	rootCmd.Flags().BoolVar(&opts.transactional, "transactional", false, "If moving fails partway, move every file back so the directory is left as it started")
+	rootCmd.Flags().BoolVar(&opts.expand, "expand-prompts", false, "Also score each category against related terms (built-in and ~/.imgsort/expansions.txt)")
+	rootCmd.Flags().StringVar(&opts.promptPool, "prompt-pool", "max", "How --expand-prompts and categories.json prompt lists combine a category's prompts: max or mean")
+	rootCmd.Flags().StringVar(&opts.scoresIn, "scores-in", "", "Sort using scores from a previous --scores-out file instead of running the model")
+	rootCmd.Flags().BoolVar(&opts.sidecar, "sidecar", false, "Write <image>.json next to each image with its category, top scores, and model")
+	rootCmd.Flags().BoolVar(&opts.writeIndex, "write-index", false, "Write "+mover.IndexFile+" into each category folder listing its files by confidence")
+	rootCmd.Flags().BoolVar(&opts.xattr, "xattr", false, "Record each image's category and confidence in its extended attributes (user.imgsort.*)")
+	rootCmd.Flags().StringVar(&opts.scoresOut, "scores-out", "", "Write the full per-image scores to a JSON file")
+	rootCmd.Flags().StringVar(&opts.reportJSON, "report-json", "", "Write a JSON report listing every file seen, including skipped and excluded ones, with the reason")
+	rootCmd.Flags().StringVar(&opts.export, "export", "", "Write per-file results for other tools in this format (tags) to --export-file")
+	rootCmd.Flags().StringVar(&opts.exportFile, "export-file", "", "File to write --export output to")
+	rootCmd.Flags().StringVar(&opts.diffPlan, "diff-plan", "", "Compare this run's proposals against a previous --report-json file and print only what changed")
+	rootCmd.Flags().StringVar(&opts.diffPlanJSON, "diff-plan-json", "", "With --diff-plan, also write the differences as JSON to this file")
+
+	rootCmd.Flags().BoolVar(&opts.groupBursts, "group-bursts", false, "Keep burst sequences (consecutive numbering, close timestamps) together in one category")
+	rootCmd.Flags().DurationVar(&opts.burstGap, "burst-gap", burst.DefaultMaxGap, "Maximum time between consecutive shots in a burst")
+	rootCmd.Flags().StringVar(&opts.mode, "mode", "clip", "How to classify images: clip (AI model) or color (dominant color, no model download)")
+	rootCmd.Flags().StringVar(&opts.remote, "remote", "", "Run inference on a remote server at this URL instead of locally (see README)")
+	rootCmd.Flags().StringVar(&opts.backend, "backend", "", "Classify using an 'imgsort serve' instance at this URL instead of locally")
+	rootCmd.Flags().BoolVar(&opts.sharedFS, "shared-fs", false, "With --backend, send file paths instead of image data (the server must see the same paths)")
+	rootCmd.Flags().IntVar(&opts.backendConns, "backend-concurrency", 4, "With --backend, number of images sent to the server at once")
+	rootCmd.Flags().DurationVar(&opts.backendWait, "backend-timeout", time.Minute, "With --backend, timeout for each request")
+	rootCmd.Flags().StringVar(&opts.smallImages, "small-images", "stretch", "How to enlarge images smaller than the model input: stretch, sharp (nearest-neighbour), or pad (center without stretching)")
+	rootCmd.Flags().IntVar(&opts.minSize, "min-size", 0, "Skip images whose shorter side is below this many pixels (0 = no minimum)")
+	rootCmd.Flags().BoolVar(&opts.exifRoute, "exif-route", false, "Use EXIF camera metadata to rule out screenshot-like categories for camera photos")
+	rootCmd.Flags().BoolVar(&opts.noEstimate, "no-estimate", false, "Skip timing a sample of images to estimate how long the run will take")
+	rootCmd.Flags().BoolVar(&opts.noWarmup, "no-warmup", false, "Skip the blank inference run after loading the model, which keeps setup from slowing the first image")
+	rootCmd.Flags().Bool("warmup", true, "Run one blank inference after loading the model")
+	rootCmd.Flags().MarkDeprecated("warmup", "the warm-up now runs by default; use --no-warmup to skip it")
+	rootCmd.Flags().StringVar(&opts.progJSON, "progress-json", "", "Write an event to this file as each image starts and finishes classifying, as newline-delimited JSON (- for stderr)")
+	rootCmd.Flags().StringVar(&opts.trace, "trace", "", "Write the prompts, token IDs, tensor shapes, and raw logits sent to the model for each image to this file (- for stderr)")
+	rootCmd.Flags().DurationVar(&opts.fileTimeout, "file-timeout", time.Minute, "Skip an image whose classification takes longer than this (0 = no limit)")
+	rootCmd.Flags().BoolVar(&opts.noCache, "no-cache", false, "Classify every image even if its scores are cached from an earlier run")
+	rootCmd.Flags().BoolVar(&opts.strictCache, "strict-cache", false, "Only reuse cached scores if the whole file's hash matches (slower)")
+	rootCmd.PersistentFlags().StringVar(&opts.onnxRuntime, "onnxruntime", "", "Path to the ONNX Runtime shared library (default: embedded or system library)")
+	rootCmd.PersistentFlags().StringVar(&opts.modelsDir, "models-dir", "", "Directory holding the model files (default: $IMGSORT_MODELS_DIR, $IMGSORT_HOME/models, or ~/.imgsort/models)")
+	rootCmd.PersistentFlags().BoolVar(&opts.offline, "offline", false, "Never download model files; fail unless they are already staged and match their recorded hashes")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		model.SetModelsDir(opts.modelsDir)
+	}
+
+	rootCmd.AddCommand(newCategoriesCmd())
+	rootCmd.AddCommand(newScanCmd())
+	rootCmd.AddCommand(newDoctorCmd(&opts))
+	rootCmd.AddCommand(newServeCmd(&opts))
+	rootCmd.AddCommand(newRenameCategoryCmd())
+	rootCmd.AddCommand(newPruneEmptyCmd())
+	rootCmd.AddCommand(newConfigCmd(rootCmd, &opts))
+	rootCmd.AddCommand(newModelsCmd())
+	rootCmd.AddCommand(newRankCmd(&opts))
+	rootCmd.AddCommand(newPinCmd())
+	rootCmd.AddCommand(newCacheCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newStatsCmd())
+	rootCmd.SetVersionTemplate(versionText())
 
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
-func run(dir string, dryRun bool, categoriesFlag string, confidence float64) error {
+// exitCode maps an error to the process exit code: 2 when the directory had
+// no images to process, 1 for any other failure.
+func exitCode(err error) int {
+	if errors.Is(err, scanner.ErrNoImages) {
+		return 2
+	}
+	return 1
+}
+
+func run(dir string, opts options) error {
 	// Validate directory
 	info, err := os.Stat(dir)
 	if err != nil {
@@ -53,75 +294,882 @@ func run(dir string, dryRun bool, categoriesFlag string, confidence float64) err
 		return fmt.Errorf("%s is not a directory", dir)
 	}
 
-	// Resolve categories
-	var cliCats []string
-	if categoriesFlag != "" {
-		for _, c := range strings.Split(categoriesFlag, ",") {
-			c = strings.TrimSpace(c)
-			if c != "" {
-				cliCats = append(cliCats, c)
+	onConflict, err := mover.ParseConflictStrategy(opts.onConflict)
+	if err != nil {
+		return err
+	}
+	secondary, err := mover.ParseSecondaryMode(opts.secondary)
+	if err != nil {
+		return err
+	}
+	unsorted, err := mover.ParseUnsortedPolicy(opts.unsorted)
+	if err != nil {
+		return err
+	}
+	onFolder, err := mover.ParseFolderPolicy(opts.onFolder)
+	if err != nil {
+		return err
+	}
+	small, err := model.ParseSmallImageMode(opts.smallImages)
+	if err != nil {
+		return err
+	}
+	if opts.minSize < 0 {
+		return fmt.Errorf("--min-size must not be negative")
+	}
+	if opts.reportLimit < 0 {
+		return fmt.Errorf("--report-limit must not be negative")
+	}
+	if opts.maxPerCat < 0 {
+		return fmt.Errorf("--max-per-category must not be negative")
+	}
+	if opts.minCatSize < 0 {
+		return fmt.Errorf("--min-category-size must not be negative")
+	}
+	if opts.export != "" {
+		if err := report.CheckExportFormat(opts.export); err != nil {
+			return fmt.Errorf("--export: %w", err)
+		}
+		if opts.exportFile == "" {
+			return fmt.Errorf("--export requires --export-file")
+		}
+	} else if opts.exportFile != "" {
+		return fmt.Errorf("--export-file requires --export")
+	}
+	if opts.emitScript != "" {
+		if !opts.dryRun {
+			return fmt.Errorf("--emit-script requires --dry-run")
+		}
+		if secondary != mover.SecondaryNone {
+			return fmt.Errorf("--emit-script cannot be combined with --secondary")
+		}
+		if opts.copyFiles {
+			return fmt.Errorf("--emit-script writes moves and cannot be combined with --copy")
+		}
+	}
+	if opts.outputDir != "" {
+		if opts.outputDir, err = checkOutputDir(dir, opts.outputDir, opts.recursive); err != nil {
+			return err
+		}
+	}
+	if opts.fileTimeout < 0 {
+		return fmt.Errorf("--file-timeout must not be negative")
+	}
+	if opts.baseMargin < -1 || opts.baseMargin > 1 {
+		return fmt.Errorf("--baseline-margin must be between -1 and 1")
+	}
+	if opts.samplePct < 0 || opts.samplePct > 100 {
+		return fmt.Errorf("--sample-percent must be between 0 and 100")
+	}
+	if opts.samplePct > 0 {
+		if opts.scoresIn != "" {
+			return fmt.Errorf("--sample-percent cannot be combined with --scores-in")
+		}
+		// A sample is for judging the categories, not for sorting.
+		opts.dryRun = true
+	}
+	modelID := model.ModelID
+	switch opts.mode {
+	case "clip":
+	case "color":
+		modelID = palette.ModelID
+		if opts.remote != "" || opts.backend != "" {
+			return fmt.Errorf("--mode color cannot be combined with --remote or --backend")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q (expected clip or color)", opts.mode)
+	}
+	if opts.remote != "" && opts.backend != "" {
+		return fmt.Errorf("--remote and --backend cannot be combined")
+	}
+	if opts.trace != "" {
+		if opts.mode == "color" {
+			return fmt.Errorf("--trace needs the CLIP model and cannot be combined with --mode color")
+		}
+		if opts.scoresIn != "" {
+			return fmt.Errorf("--trace cannot be combined with --scores-in")
+		}
+	}
+	if opts.diffPlanJSON != "" && opts.diffPlan == "" {
+		return fmt.Errorf("--diff-plan-json requires --diff-plan")
+	}
+	dates := filedate.Options{Strict: opts.strictDates}
+	if opts.dateFloor != "" {
+		if dates.Floor, err = time.ParseInLocation(time.DateOnly, opts.dateFloor, time.Local); err != nil {
+			return fmt.Errorf("--date-floor must be a date such as 1990-01-01")
+		}
+	}
+	if !opts.dateFolders && (opts.strictDates || opts.dateFloor != "") {
+		return fmt.Errorf("--strict-dates and --date-floor require --date-folders")
+	}
+	if opts.stream {
+		if err := checkStreamOptions(opts); err != nil {
+			return err
+		}
+	}
+	runStart := time.Now()
+	if opts.incremental {
+		if opts.scoresIn != "" {
+			return fmt.Errorf("--incremental cannot be combined with --scores-in")
+		}
+		st, err := state.Load(dir)
+		if err != nil {
+			return err
+		}
+		opts.modifiedAfter = st.LastRun
+		if st.LastRun.IsZero() {
+			fmt.Println("No previous run recorded; sorting every image")
+		} else {
+			fmt.Printf("Only sorting images modified since %s\n", st.LastRun.Local().Format(time.DateTime))
+		}
+	}
+	var prevPlan report.RunReport
+	if opts.diffPlan != "" {
+		if !opts.dryRun {
+			return fmt.Errorf("--diff-plan compares proposals and requires --dry-run")
+		}
+		if prevPlan, err = readRunReport(opts.diffPlan); err != nil {
+			return err
+		}
+	}
+
+	// Fail before inference rather than after classifying everything. A
+	// copy only reads the images, but a move takes them out of dir.
+	if !opts.dryRun {
+		if !opts.copyFiles || opts.outputDir == "" {
+			if err := mover.CheckWritable(dir); err != nil {
+				return err
 			}
 		}
+		if opts.outputDir != "" {
+			if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+				return fmt.Errorf("cannot create --output-dir: %w", err)
+			}
+			if err := mover.CheckWritable(opts.outputDir); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Resolve categories
+	cats, classifyOpts, err := resolveCategories(&opts)
+	if err != nil {
+		return err
+	}
+	if opts.tentative != 0 && (opts.tentative < 0 || opts.tentative >= opts.confidence) {
+		return fmt.Errorf("--tentative-threshold must be above 0 and below --confidence (%g)", opts.confidence)
+	}
+	if opts.openSet != "" {
+		if strings.TrimSpace(opts.openSet) == "" || strings.ContainsAny(opts.openSet, `/\`) || opts.openSet == "." || opts.openSet == ".." {
+			return fmt.Errorf("--open-set-category %q is not a valid folder name", opts.openSet)
+		}
+		if slices.Contains(cats, opts.openSet) {
+			return fmt.Errorf("--open-set-category %q is also a category; choose a name that is not", opts.openSet)
+		}
+	}
+	if opts.overridesFile != "" {
+		if opts.overrides, err = overrides.Load(opts.overridesFile); err != nil {
+			return err
+		}
+		if err := opts.overrides.Check(cats); err != nil {
+			return fmt.Errorf("%s: %w", opts.overridesFile, err)
+		}
+	}
+	classifyOpts.Preprocess = model.PreprocessOptions{Small: small, MinSize: opts.minSize}
+	if thumbs := openThumbs(); thumbs != nil && !opts.noCache {
+		// Thumbnails saved here spare later runs, such as --mode color, a decode.
+		classifyOpts.Preprocess.Decoded = thumbs.Keep
+	}
+	if opts.trace != "" {
+		w, closeTrace, err := openOutput(opts.trace, "trace")
+		if err != nil {
+			return err
+		}
+		defer closeTrace()
+		classifyOpts.Trace = w
+	}
+	if err := checkFolderConflicts(opts.destDir(dir), cats, opts, unsorted, onFolder); err != nil {
+		return err
+	}
+
+	moveOpts := mover.Options{
+		DryRun:             opts.dryRun,
+		OutputDir:          opts.outputDir,
+		Copy:               opts.copyFiles,
+		OnConflict:         onConflict,
+		OnFolderConflict:   onFolder,
+		Secondary:          secondary,
+		SecondaryThreshold: opts.confidence,
+		MaxPerCategory:     opts.maxPerCat,
+		MinCategorySize:    opts.minCatSize,
+		PreserveStructure:  opts.preserve,
+		DateFolders:        opts.dateFolders,
+		Dates:              dates,
+		Transactional:      opts.transactional,
+		IgnoreVanished:     opts.ignoreVanish,
+		Unsorted:           unsorted,
+	}
+	if opts.stream {
+		return runStream(dir, modelID, cats, classifyOpts, moveOpts, opts, runStart)
+	}
+
+	var scores []categorizer.ImageScores
+	var timing classifyTiming
+	scanResult := &scanner.Result{}
+	var probe *cache.Probe
+	if opts.scoresIn != "" {
+		scores, err = loadScores(dir, opts.scoresIn, modelID, cats, opts.overrides)
+	} else {
+		scores, scanResult, probe, timing, err = classify(dir, modelID, cats, classifyOpts, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.scoresOut != "" {
+		if err := categorizer.WriteScores(opts.scoresOut, dir, modelID, cats, scores); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote scores for %d images to %s\n", len(scores), opts.scoresOut)
+	}
+
+	decideOpts := opts.decideOptions()
+	var results []categorizer.Result
+	if opts.groupBursts {
+		paths := make([]string, len(scores))
+		for i, is := range scores {
+			paths[i] = is.Path
+		}
+		groups := burst.DetectPaths(paths, opts.burstGap)
+		fmt.Printf("Detected %d burst sequences\n", len(groups))
+		results = categorizer.DecideGroups(scores, groups, decideOpts)
+	} else {
+		results = categorizer.Decide(scores, decideOpts)
+	}
+
+	// Move files
+	if opts.dryRun {
+		fmt.Println("Dry run mode — no files will be moved")
+	}
+	moveOpts.Live = scanResult.Live
+	moves, err := mover.MoveFiles(dir, results, moveOpts)
+	if err != nil {
+		return err
+	}
+
+	if opts.emitScript != "" {
+		if err := writeScript(opts.emitScript, dir, moves); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the planned moves as a shell script to %s\n", opts.emitScript)
+	}
+	if opts.sidecar && !opts.dryRun {
+		n, err := writeSidecars(results, scores, moves, modelID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d sidecar files\n", n)
+	}
+	if opts.writeIndex && !opts.dryRun {
+		written, err := mover.WriteIndexes(opts.destDir(dir), results, moves)
+		if err != nil {
+			return err
+		}
+		if len(written) > 0 {
+			fmt.Printf("Wrote %d category index files\n", len(written))
+		}
+	}
+	if opts.xattr && !opts.dryRun {
+		n, err := writeXattrs(results, moves)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			fmt.Printf("Recorded the category in the extended attributes of %d files\n", n)
+		}
+	}
+
+	var plan report.RunReport
+	if opts.reportJSON != "" || opts.diffPlan != "" {
+		plan = report.NewRunReport(dir, results, moves, scanResult, opts.confidence, opts.dryRun)
+		report.AddContentKeys(&plan, contentKey)
+		if probe != nil {
+			report.AddCacheStatus(&plan, probe.Status)
+		}
+	}
+	if opts.reportJSON != "" {
+		if err := writeRunReport(opts.reportJSON, plan); err != nil {
+			return err
+		}
+	}
+	if opts.export != "" {
+		if err := writeExport(opts.exportFile, report.Tags(results, opts.confidence)); err != nil {
+			return err
+		}
+	}
+	if opts.diffPlan != "" {
+		return writePlanDiff(opts, dir, report.DiffPlans(prevPlan, plan))
+	}
+
+	// Print report
+	listLimit := opts.reportLimit
+	if opts.verbose {
+		listLimit = 0
+	}
+	report.Print(os.Stdout, results, moves, scanResult.SkippedCount, opts.dryRun, listLimit)
+	report.PrintUnreadable(os.Stdout, scanResult.Unreadable)
+	if opts.dryRun && timing.classify > 0 {
+		report.PrintTiming(os.Stdout, len(scores), timing.classify, timing.warmup)
+	}
+	if opts.verbose {
+		report.PrintConfusion(os.Stdout, report.Confusion(results))
+	}
+	if opts.suggest {
+		report.PrintSuggestions(os.Stdout, report.Suggest(cats, scores, results, decideOpts))
+	}
+
+	if opts.incremental && !opts.dryRun {
+		if err := state.Save(dir, runStart); err != nil {
+			log.Printf("Warning: %v; the next --incremental run will look at every image (set %s to a writable directory)", err, appdir.StateEnv)
+		}
+	}
+	if opts.summaryLine {
+		stats := report.NewStats(results, moves, scanResult.SkippedCount, opts.dryRun)
+		fmt.Println(report.SummaryLine(stats, time.Since(runStart)))
+	}
+	return nil
+}
+
+// decideOptions returns how images are assigned categories in this run.
+func (o options) decideOptions() categorizer.Options {
+	return categorizer.Options{
+		Threshold:          o.confidence,
+		TentativeThreshold: o.tentative,
+		Pairwise:           o.pairwise,
+		BaselineMargin:     o.baseMargin,
+		Rules:              o.rules,
+		OpenSet:            o.openSet,
+	}
+}
+
+// resolveCategories picks the categories and prompts for the run, either from
+// a built-in profile or from --categories, the custom file, or the defaults.
+// A profile's threshold replaces the default --confidence.
+func resolveCategories(opts *options) ([]string, model.ClassifyOptions, error) {
+	cliCats := splitCategories(opts.categories)
+
+	if opts.mode == "color" {
+		if opts.triage {
+			return nil, model.ClassifyOptions{}, fmt.Errorf("--triage cannot be combined with --mode color")
+		}
+		cats, err := palette.Resolve(cliCats)
+		if err != nil {
+			return nil, model.ClassifyOptions{}, err
+		}
+		fmt.Printf("Sorting by dominant color (%d colors)\n", len(cats))
+		return cats, model.ClassifyOptions{NoBaseline: true}, nil
+	}
+
+	if opts.triage {
+		if opts.categories != "" {
+			return nil, model.ClassifyOptions{}, fmt.Errorf("--triage cannot be combined with --categories")
+		}
+		profile := categories.TriageProfile
+		if !opts.confidenceSet {
+			opts.confidence = profile.Threshold
+		}
+		fmt.Printf("Using %s profile (%d categories)\n", profile.Name, len(profile.Categories))
+		return profile.Categories, model.ClassifyOptions{
+			Prompts:    profile.Prompts,
+			NoBaseline: profile.NoBaseline,
+		}, nil
 	}
-	cats, err := categories.Resolve(cliCats)
+
+	resolve := categories.Resolve
+	if opts.restrict {
+		resolve = categories.ResolveRestricted
+	}
+	cats, err := resolve(cliCats)
 	if err != nil {
-		return fmt.Errorf("cannot resolve categories: %w", err)
+		return nil, model.ClassifyOptions{}, fmt.Errorf("cannot resolve categories: %w", err)
 	}
 	fmt.Printf("Using %d categories\n", len(cats))
 
+	var classifyOpts model.ClassifyOptions
+	if opts.expand {
+		pool, err := model.ParsePoolMode(opts.promptPool)
+		if err != nil {
+			return nil, model.ClassifyOptions{}, err
+		}
+		expansions, err := categories.LoadExpansions(cats)
+		if err != nil {
+			return nil, model.ClassifyOptions{}, err
+		}
+		fmt.Printf("Expanding prompts for %d categories (%s pooling)\n", len(expansions), pool)
+		classifyOpts.Expansions = expansions
+		classifyOpts.Pool = pool
+	}
+
+	spec, err := categories.LoadCustom()
+	if err != nil {
+		return nil, model.ClassifyOptions{}, fmt.Errorf("cannot resolve categories: %w", err)
+	}
+	applyCategorySpec(spec, cats, &classifyOpts, opts)
+	if !opts.noLangWarning {
+		for _, w := range categories.Validate(cats, classifyOpts.Prompts) {
+			fmt.Fprintf(os.Stderr, "Warning: %s (--no-language-warning hides this)\n", w)
+		}
+	}
+	if len(classifyOpts.ExtraPrompts) > 0 && classifyOpts.Pool == "" {
+		if classifyOpts.Pool, err = model.ParsePoolMode(opts.promptPool); err != nil {
+			return nil, model.ClassifyOptions{}, err
+		}
+	}
+	return cats, classifyOpts, nil
+}
+
+// splitCategories parses a comma-separated --categories value.
+func splitCategories(s string) []string {
+	var cats []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			cats = append(cats, c)
+		}
+	}
+	return cats
+}
+
+// classifyTiming is how long a run spent warming up the model and
+// classifying images, without scanning and loading the model.
+type classifyTiming struct {
+	warmup, classify time.Duration
+}
+
+// classify scans dir and runs the classifier over every image found. It
+// returns the raw scores, the scan result, and how long classifying took.
+func classify(dir, modelID string, cats []string, classifyOpts model.ClassifyOptions, opts options) ([]categorizer.ImageScores, *scanner.Result, *cache.Probe, classifyTiming, error) {
+	var timing classifyTiming
 	// Scan directory
 	fmt.Printf("Scanning %s...\n", dir)
-	scanResult, err := scanner.Scan(dir)
+	scanResult, err := scanWithCount(dir, scanner.Options{
+		Recursive:       opts.recursive,
+		Categories:      cats,
+		SplitLivePhotos: opts.splitLive,
+		RecordExcluded:  opts.reportJSON != "",
+		NoJunkFilter:    opts.noJunkFilter,
+		ModifiedAfter:   opts.modifiedAfter,
+	})
+	if errors.Is(err, scanner.ErrNoImages) && scanResult.UnchangedCount > 0 {
+		// Nothing new is a normal outcome for an incremental run.
+		fmt.Printf("No new images (%d unchanged since the last run)\n", scanResult.UnchangedCount)
+		return nil, scanResult, nil, timing, nil
+	}
 	if err != nil {
-		return err
+		return nil, nil, nil, timing, err
 	}
+	var dupes int
+	scanResult.ImagePaths, dupes = scanner.Dedupe(scanResult.ImagePaths)
 	fmt.Printf("Found %d images (%d non-image files skipped)\n", len(scanResult.ImagePaths), scanResult.SkippedCount)
+	if dupes > 0 {
+		log.Printf("Warning: ignored %d duplicate paths to images already listed", dupes)
+	}
+	if n := scanResult.UnchangedCount; n > 0 {
+		fmt.Printf("%d unchanged images from earlier runs were left out\n", n)
+	}
+	if opts.samplePct > 0 {
+		total := len(scanResult.ImagePaths)
+		scanResult.ImagePaths = scanner.Sample(scanResult.ImagePaths, opts.samplePct, opts.seed)
+		fmt.Printf("Sampling %d of %d images (%g%%, seed %d); nothing will be moved\n",
+			len(scanResult.ImagePaths), total, opts.samplePct, opts.seed)
+	}
+	if n := len(scanResult.Live); n > 0 {
+		fmt.Printf("%d are Live or Motion Photos and will be moved with their videos\n", n)
+	}
+	if n := len(scanResult.Unreadable); n > 0 {
+		fmt.Printf("Could not read %d paths; they will be left alone (see the summary)\n", n)
+	}
+	toClassify, overridden := splitOverrides(scanResult.ImagePaths, opts.overrides)
+	if len(overridden) > 0 {
+		fmt.Printf("%d match --overrides and will not be classified\n", len(overridden))
+	}
+	toClassify, pinned, err := splitPinned(dir, toClassify)
+	if err != nil {
+		return nil, nil, nil, timing, err
+	}
+	if len(pinned) > 0 {
+		fmt.Printf("%d are pinned to a category and will not be classified\n", len(pinned))
+	}
+	manual := append(overridden, pinned...)
+	if len(toClassify) == 0 && len(manual) > 0 {
+		return manual, scanResult, nil, timing, nil
+	}
+
+	clip, cached, store, cleanup, warmup, err := setupClassifier(opts, modelID)
+	if err != nil {
+		return nil, nil, nil, timing, err
+	}
+	defer cleanup()
+	prog := newProgress(len(toClassify), opts.decideOptions(), opts.verbose)
+	if opts.progJSON != "" {
+		w, closeEvents, err := openOutput(opts.progJSON, "progress")
+		if err != nil {
+			return nil, nil, nil, timing, err
+		}
+		defer closeEvents()
+		prog.json = categorizer.NewProgressWriter(w)
+	}
+
+	// Categorize images
+	fmt.Println("Categorizing images...")
+	workers := 1
+	if opts.backend != "" {
+		workers = opts.backendConns
+	}
+	// A dry run shows how much the cache will answer, and times only the
+	// images that need the model.
+	var probe *cache.Probe
+	var needsModel func(string) bool
+	if cached != nil && opts.dryRun {
+		p := store.Probe(toClassify, cache.Context(modelID, cats, classifyOpts))
+		report.PrintCacheProbe(os.Stdout, p)
+		probe = &p
+		needsModel = func(path string) bool { return p.Status[path] != cache.StatusHit }
+	}
+	start := time.Now()
+	scores, err := classifyWithEstimate(clip, toClassify, cats, classifyOpts, workers, !opts.noEstimate, prog, needsModel)
+	timing = classifyTiming{warmup: warmup, classify: time.Since(start)}
+	if err != nil {
+		return nil, nil, nil, timing, err
+	}
+	prog.finish()
+	if prog.json != nil && prog.json.Err() != nil {
+		log.Printf("Warning: %v", prog.json.Err())
+	}
+
+	if cached != nil {
+		fmt.Printf("%d of %d images answered from the score cache\n", cached.Hits(), len(scores))
+		if err := store.Save(); err != nil {
+			log.Printf("Warning: %v (set %s to a writable directory to keep scores between runs)", err, appdir.CacheEnv)
+		}
+	}
 
-	// Ensure models are downloaded
-	fmt.Println("Checking AI model...")
-	err = model.EnsureModels(func(filename string, downloaded, total int64) {
-		if total > 0 {
-			pct := float64(downloaded) / float64(total) * 100
-			fmt.Printf("\rDownloading %s... %.0f%%", filename, pct)
+	return append(scores, manual...), scanResult, probe, timing, nil
+}
+
+// setupClassifier makes sure the model files are in place and returns the
+// classifier for the run, with the per-file timeout, score cache, and EXIF
+// routing applied as opts asks. cached and store are nil without a cache.
+// cleanup releases the model, and warmup is how long warming it up took.
+func setupClassifier(opts options, modelID string) (clip categorizer.Classifier, cached *cache.Classifier, store *cache.Store, cleanup func(), warmup time.Duration, err error) {
+	// Ensure models are downloaded; a remote server only needs the tokenizer
+	// here
+	if opts.mode != "color" {
+		files := model.RequiredFiles
+		if opts.remote != "" || opts.backend != "" {
+			files = model.TokenizerFiles
+		}
+		fmt.Println("Checking AI model...")
+		if err := ensureModelFiles(files, opts.offline); err != nil {
+			return nil, nil, nil, nil, 0, fmt.Errorf("model setup failed: %w", err)
+		}
+	}
+
+	clip, release, warmup, err := newClassifier(opts)
+	if err != nil {
+		return nil, nil, nil, nil, 0, err
+	}
+	timed := categorizer.WithTimeout(clip, categorizer.TimeoutOptions{Timeout: opts.fileTimeout})
+	clip = timed
+	cleanup = func() {
+		// A timed-out image may still be running in the session; leave it
+		// for the process exit to release rather than destroy it under it.
+		if !timed.Busy() {
+			release()
+		}
+	}
+
+	// Cached images never reach the model, so there would be nothing to trace.
+	if !opts.noCache && opts.trace == "" {
+		if store, err = openCache(opts.strictCache); err != nil {
+			log.Printf("Warning: not using the score cache: %v", err)
+			store = nil
 		} else {
-			fmt.Printf("\rDownloading %s... %d bytes", filename, downloaded)
+			cached = cache.NewClassifier(clip, store, modelID)
+			clip = cached
 		}
-	})
+	}
+
+	if opts.exifRoute {
+		clip = categorizer.WithCategorySelector(clip, routeByCamera)
+	}
+	return clip, cached, store, cleanup, warmup, nil
+}
+
+// checkOutputDir validates --output-dir for sorting dir and returns it as
+// an absolute path, or "" if it is dir itself.
+func checkOutputDir(dir, outputDir string, recursive bool) (string, error) {
+	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		return fmt.Errorf("model setup failed: %w", err)
+		return "", err
+	}
+	out, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", err
+	}
+	if out == absDir {
+		return "", nil
+	}
+	if info, err := os.Stat(out); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("--output-dir %s is not a directory", outputDir)
+	}
+	if rel, err := filepath.Rel(absDir, out); recursive && err == nil && filepath.IsLocal(rel) {
+		return "", fmt.Errorf("--output-dir is inside %s, so --recursive would sort the sorted images again; choose a directory outside it", dir)
+	}
+	return out, nil
+}
+
+// destDir returns the directory the category folders go in when sorting
+// dir: --output-dir if given, otherwise dir itself.
+func (o options) destDir(dir string) string {
+	if o.outputDir != "" {
+		return o.outputDir
+	}
+	return dir
+}
+
+// checkFolderConflicts reports categories whose folder name is taken by a
+// file in dir before anything is classified, failing the run under
+// mover.FolderFail and warning about what will happen otherwise.
+func checkFolderConflicts(dir string, cats []string, opts options, unsorted mover.UnsortedPolicy, policy mover.FolderPolicy) error {
+	names := slices.Clone(cats)
+	if opts.openSet != "" {
+		names = append(names, opts.openSet)
+	}
+	if unsorted == mover.UnsortedMove || opts.strictDates {
+		names = append(names, mover.UnsortedDir)
+	}
+	if opts.minCatSize > 1 {
+		names = append(names, mover.MiscDir)
+	}
+	taken := mover.FolderConflicts(dir, names)
+	if len(taken) == 0 {
+		return nil
+	}
+	list := strings.Join(taken, ", ")
+	switch policy {
+	case mover.FolderSuffix:
+		log.Printf("Warning: files in %s are named like categories (%s); those categories go into folders ending in %s instead",
+			dir, list, mover.FolderSuffixText)
+	case mover.FolderSkipCategory:
+		log.Printf("Warning: files in %s are named like categories (%s); images in those categories will be left in place",
+			dir, list)
+	default:
+		return fmt.Errorf("files in %s are named like categories, so their folders cannot be created: %s "+
+			"(rename them, or use --on-folder-conflict suffix or skip-category)", dir, list)
+	}
+	return nil
+}
+
+// routeByCamera drops screen-only categories for images whose EXIF names a
+// camera. Images without camera metadata keep every category, since many
+// real photos have their EXIF stripped.
+func routeByCamera(path string, cats []string) []string {
+	cam, err := exif.ReadCamera(path)
+	if err != nil || !cam.Known() {
+		return cats
+	}
+	return categories.ForCameraPhoto(cats)
+}
+
+// openCache opens the score cache in its default location.
+func openCache(strict bool) (*cache.Store, error) {
+	path, err := cache.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return cache.Open(path, strict)
+}
+
+// openThumbs opens the thumbnail cache in its default location, or returns
+// nil if there is none.
+func openThumbs() *cache.Thumbs {
+	dir, err := cache.ThumbDir()
+	if err != nil {
+		return nil
+	}
+	return cache.OpenThumbs(dir, cache.DefaultThumbLimit, nil)
+}
+
+// newClassifier returns the color classifier for --mode color, a remote
+// session for --backend or --remote, and otherwise a local CLIP session,
+// warmed up unless --no-warmup is given. The returned cleanup function
+// releases the session; warmup is how long warming it up took.
+func newClassifier(opts options) (c categorizer.Classifier, cleanup func(), warmup time.Duration, err error) {
+	if opts.mode == "color" {
+		var c palette.Classifier
+		if thumbs := openThumbs(); thumbs != nil && !opts.noCache {
+			c.Load = thumbs.Load
+		}
+		return c, func() {}, 0, nil
+	}
+	if opts.backend != "" {
+		fmt.Printf("Using imgsort server at %s...\n", opts.backend)
+		remote, err := model.NewRemoteSession(opts.backend, model.RemoteOptions{
+			Token:       os.Getenv(serve.TokenEnv),
+			Upload:      !opts.sharedFS,
+			SharedFS:    opts.sharedFS,
+			Timeout:     opts.backendWait,
+			Concurrency: opts.backendConns,
+			Retries:     2,
+		})
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("cannot set up remote inference: %w", err)
+		}
+		if err := remote.CheckModel(); err != nil {
+			return nil, nil, 0, err
+		}
+		return remote, func() {}, 0, nil
+	}
+	if opts.remote != "" {
+		fmt.Printf("Using remote inference at %s...\n", opts.remote)
+		remote, err := model.NewRemoteSession(opts.remote, model.RemoteOptions{Token: os.Getenv(serve.TokenEnv)})
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("cannot set up remote inference: %w", err)
+		}
+		return remote, func() {}, 0, nil
 	}
 
-	// Create CLIP session
 	fmt.Println("Loading CLIP model...")
-	clip, err := model.NewCLIPSession("")
+	clip, err := model.NewCLIPSession(opts.onnxRuntime)
 	if err != nil {
-		return fmt.Errorf("cannot load CLIP model: %w", err)
+		return nil, nil, 0, fmt.Errorf("cannot load CLIP model: %w", err)
+	}
+	if !opts.noWarmup {
+		start := time.Now()
+		if err := clip.Warmup(); err != nil {
+			clip.Destroy()
+			return nil, nil, 0, err
+		}
+		warmup = time.Since(start)
 	}
-	defer clip.Destroy()
+	return clip, clip.Destroy, warmup, nil
+}
 
-	// Categorize images
-	fmt.Println("Categorizing images...")
-	results, err := categorizer.Categorize(clip, scanResult.ImagePaths, cats, confidence,
-		func(current, total int) {
-			fmt.Printf("\rProcessing image %d/%d...", current, total)
-		},
-	)
+// openOutput opens the destination of --trace or --progress-json, where "-"
+// means stderr. what names the output in errors.
+func openOutput(path, what string) (io.Writer, func(), error) {
+	if path == "-" {
+		return os.Stderr, func() {}, nil
+	}
+	f, err := os.Create(path)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("cannot write %s: %w", what, err)
 	}
-	fmt.Println() // newline after progress
+	return f, func() { f.Close() }, nil
+}
 
-	// Move files
-	if dryRun {
-		fmt.Println("Dry run mode — no files will be moved")
+// loadScores reads previously exported scores instead of running the model.
+// Images that no longer exist in dir are dropped with a warning.
+// Images matching ov get their category from it, as in a classifying run.
+func loadScores(dir, path, modelID string, cats []string, ov overrides.Overrides) ([]categorizer.ImageScores, error) {
+	fmt.Printf("Loading scores from %s...\n", path)
+	sf, err := categorizer.ReadScores(path, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := sf.CheckCompatible(modelID, cats); err != nil {
+		return nil, err
 	}
-	moves, err := mover.MoveFiles(dir, results, dryRun)
+
+	scores := make([]categorizer.ImageScores, 0, len(sf.Images))
+	for _, is := range sf.Images {
+		if _, err := os.Stat(is.Path); err != nil {
+			log.Printf("Warning: skipping %s: no longer present", is.Path)
+			continue
+		}
+		scores = append(scores, is)
+	}
+	fmt.Printf("Loaded scores for %d images\n", len(scores))
+	applyOverrides(scores, ov)
+	return scores, applyPins(dir, scores)
+}
+
+// contentKey identifies a file by its size and sampled contents, leaving out
+// the modification time so copies and renamed files still match.
+func contentKey(path string) (string, error) {
+	k, err := cache.Identify(path, false)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return fmt.Sprintf("%d-%s", k.Size, k.Sample), nil
+}
 
-	// Print report
-	report.Print(os.Stdout, results, moves, scanResult.SkippedCount, dryRun)
+// writeExport writes the --export tags file to path.
+func writeExport(path string, tags map[string][]report.Tag) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot write export: %w", err)
+	}
+	if err := report.WriteTagsJSON(f, tags); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write export: %w", err)
+	}
+	return f.Close()
+}
 
-	return nil
+// writeScript writes the planned moves as a shell script for --emit-script.
+func writeScript(path, dir string, moves []mover.MoveResult) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("cannot write script: %w", err)
+	}
+	if err := report.WriteScript(f, dir, moves); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write script: %w", err)
+	}
+	return f.Close()
+}
+
+// readRunReport loads a run report written by --report-json.
+func readRunReport(path string) (report.RunReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return report.RunReport{}, fmt.Errorf("cannot read previous plan: %w", err)
+	}
+	defer f.Close()
+	rep, err := report.ReadRunJSON(f)
+	if err != nil {
+		return report.RunReport{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return rep, nil
+}
+
+// writePlanDiff prints d, and writes it to opts.diffPlanJSON if set.
+func writePlanDiff(opts options, dir string, d report.PlanDiff) error {
+	report.PrintPlanDiff(os.Stdout, dir, d)
+	if opts.diffPlanJSON == "" {
+		return nil
+	}
+	out, err := os.Create(opts.diffPlanJSON)
+	if err != nil {
+		return fmt.Errorf("cannot write plan diff: %w", err)
+	}
+	if err := report.WritePlanDiffJSON(out, d); err != nil {
+		out.Close()
+		return fmt.Errorf("cannot write plan diff: %w", err)
+	}
+	return out.Close()
+}
+
+// writeRunReport saves rep as JSON to path.
+func writeRunReport(path string, rep report.RunReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	if err := report.WriteRunJSON(f, rep); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write report: %w", err)
+	}
+	return f.Close()
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/bagtoad/imgsort/internal/dlprogress"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/spf13/cobra"
+)
+
+// newModelsCmd returns the "models" command group.
+func newModelsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "models",
+		Short: "Stage and verify the model files, for example in a container image",
+		Long: `Manage the model files imgsort runs. "fetch" downloads them into the
+models directory (--models-dir, or $IMGSORT_HOME/models) and records their
+hashes in ` + model.LockFile + `; "verify" checks a staged directory without
+downloading; "files" prints the file set for pre-fetching by other means.
+Runs with --offline never download and fail unless the staged files match.`,
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "files",
+		Short: "Print the model files with their URLs and expected SHA-256 hashes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lock, err := model.ReadLock()
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "FILE\tSHA-256\tURL")
+			for _, m := range model.RequiredFiles {
+				hash := model.ExpectedHash(m, lock)
+				if hash == "" {
+					hash = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Name, hash, m.URL)
+			}
+			return tw.Flush()
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "fetch",
+		Short: "Download any missing model files and record their hashes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := ensureModelFiles(model.RequiredFiles, false); err != nil {
+				return err
+			}
+			if err := model.WriteLock(model.RequiredFiles); err != nil {
+				return err
+			}
+			dir, err := model.ModelsDir()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Staged %d model files in %s\n", len(model.RequiredFiles), dir)
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "Check the staged model files without downloading",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := model.VerifyFiles(model.RequiredFiles); err != nil {
+				return err
+			}
+			dir, err := model.ModelsDir()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("All %d model files in %s are present and match\n", len(model.RequiredFiles), dir)
+			return nil
+		},
+	})
+	return cmd
+}
+
+// ensureModelFiles makes files available in the models directory. Offline,
+// it only verifies the staged files; otherwise missing files are downloaded
+// with a progress line for each.
+func ensureModelFiles(files []model.ModelFile, offline bool) error {
+	if offline {
+		return model.VerifyFiles(files)
+	}
+	progress := dlprogress.New(os.Stdout, isTerminal(os.Stdout))
+	defer progress.Finish()
+	return model.EnsureFiles(files, progress.Update)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/overrides"
+)
+
+// splitOverrides splits paths into the images that still need classifying
+// and the scores of those the --overrides file assigns a category.
+func splitOverrides(paths []string, o overrides.Overrides) ([]string, []categorizer.ImageScores) {
+	if len(o) == 0 {
+		return paths, nil
+	}
+	var rest []string
+	var overridden []categorizer.ImageScores
+	for _, path := range paths {
+		if category, ok := o.Lookup(path); ok {
+			overridden = append(overridden, categorizer.ImageScores{Path: path, Override: category})
+		} else {
+			rest = append(rest, path)
+		}
+	}
+	return rest, overridden
+}
+
+// applyOverrides marks loaded scores of images the --overrides file
+// assigns a category, so the file takes precedence over the scores.
+func applyOverrides(scores []categorizer.ImageScores, o overrides.Overrides) {
+	for i := range scores {
+		if category, ok := o.Lookup(scores[i].Path); ok {
+			scores[i].Override = category
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/pins"
+	"github.com/bagtoad/imgsort/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+// newPinCmd returns the "pin" command, which records the category of images
+// the model got wrong so later runs sort them there without classifying.
+func newPinCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pin <directory> <category> <image>...",
+		Short: "Pin images to a category so later runs skip classifying them",
+		Long: `pin records corrections in the directory's ` + pins.FileName + ` file. A pinned
+image is sorted into its category with full confidence on every later run,
+whatever the model would say. Pinning an image again replaces its category.
+The file lists one "path = category" per line and can be edited by hand.
+
+  imgsort pin ~/Photos receipts ~/Photos/IMG_0042.jpg`,
+		Args: cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+			category := args[1]
+			for _, img := range args[2:] {
+				abs, err := filepath.Abs(img)
+				if err != nil {
+					return err
+				}
+				if _, err := os.Stat(abs); err != nil {
+					return err
+				}
+				if !scanner.Supported(abs) {
+					return fmt.Errorf("%s is not a supported image", img)
+				}
+				if err := pins.Append(dir, abs, category); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Pinned %d images to %s in %s\n", len(args)-2, category, filepath.Join(dir, pins.FileName))
+			return nil
+		},
+	}
+}
+
+// splitPinned loads dir's pins and splits paths into the images that still
+// need classifying and the scores of those that are pinned.
+func splitPinned(dir string, paths []string) ([]string, []categorizer.ImageScores, error) {
+	p, err := pins.Load(dir)
+	if err != nil || len(p) == 0 {
+		return paths, nil, err
+	}
+	var rest []string
+	var pinned []categorizer.ImageScores
+	for _, path := range paths {
+		if category, ok := p.Lookup(dir, path); ok {
+			pinned = append(pinned, categorizer.ImageScores{Path: path, Pinned: category})
+		} else {
+			rest = append(rest, path)
+		}
+	}
+	return rest, pinned, nil
+}
+
+// applyPins marks the loaded scores of pinned images in dir, so the pins
+// take precedence over scores classified before they were made.
+func applyPins(dir string, scores []categorizer.ImageScores) error {
+	p, err := pins.Load(dir)
+	if err != nil {
+		return err
+	}
+	for i := range scores {
+		if category, ok := p.Lookup(dir, scores[i].Path); ok {
+			scores[i].Pinned = category
+		}
+	}
+	return nil
+}
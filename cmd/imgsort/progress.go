@@ -0,0 +1,290 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/report"
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// tallyTop is how many categories the live tally shows.
+const tallyTop = 3
+
+// slowFileNotice is how long one image may take before the progress output
+// names it.
+const slowFileNotice = 5 * time.Second
+
+// progress prints the classification progress line with an ETA. On a
+// terminal it also keeps a running tally of outcomes on the line below, so
+// a misbehaving run is visible before it ends. In verbose mode the line
+// also names the file being classified.
+type progress struct {
+	total   int
+	decide  categorizer.Options
+	tty     bool
+	verbose bool
+
+	// json, if set, receives every event for --progress-json.
+	json *categorizer.ProgressWriter
+
+	// mu guards the fields below and the output; events arrive from the
+	// classifying goroutines and slow notices from the watcher.
+	mu      sync.Mutex
+	line    string
+	counts  map[string]int
+	skipped int
+
+	// active holds the images being classified and when each started;
+	// current is the one started last, and noted those already named as
+	// slow. stop ends the watcher that names them, once it is running.
+	active  map[string]time.Time
+	current string
+	noted   map[string]bool
+	stop    chan struct{}
+}
+
+// newProgress returns a progress display for total images, deciding each
+// result with opts for the tally.
+func newProgress(total int, opts categorizer.Options, verbose bool) *progress {
+	opts.Quiet = true // the final Decide reports skips
+	return &progress{
+		total:   total,
+		decide:  opts,
+		tty:     isTerminal(os.Stdout),
+		verbose: verbose,
+		counts:  make(map[string]int),
+		active:  make(map[string]time.Time),
+		noted:   make(map[string]bool),
+	}
+}
+
+// record adds a classified image to the tally.
+func (p *progress) record(is categorizer.ImageScores) {
+	r := categorizer.DecideOne(is, p.decide)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if r.Skipped {
+		p.skipped++
+	} else {
+		p.counts[r.Category]++
+	}
+}
+
+// sample returns a progress callback for the timing sample, which is
+// tallied and watched for slow files but does not move the progress line.
+func (p *progress) sample() categorizer.ProgressEventFunc {
+	return func(e categorizer.ProgressEvent) {
+		p.writeJSON(e, 0)
+		if e.Kind == categorizer.ProgressStarted {
+			p.started(e.Path)
+			return
+		}
+		p.finished(e.Path)
+		p.record(e.Scores)
+	}
+}
+
+// pass returns a progress callback for a pass that starts after offset
+// images are already done, with the remaining time estimated from this
+// pass's rate.
+func (p *progress) pass(offset int) categorizer.ProgressEventFunc {
+	start := time.Now()
+	return func(e categorizer.ProgressEvent) {
+		p.writeJSON(e, offset)
+		n := offset + e.Done
+		if e.Kind == categorizer.ProgressStarted {
+			p.started(e.Path)
+			if !p.verbose {
+				return
+			}
+			n++ // the line names the image being started
+		} else {
+			p.finished(e.Path)
+			p.record(e.Scores)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		eta := ""
+		if e.Done >= 3 {
+			left := time.Since(start) / time.Duration(e.Done) * time.Duration(e.Total-e.Done)
+			eta = fmt.Sprintf(" (%s left)", report.ApproxDuration(left))
+		}
+		p.line = fmt.Sprintf("Processing image %d/%d%s", n, p.total, eta)
+		if p.verbose && p.current != "" {
+			p.line += ": " + filepath.Base(p.current)
+		}
+		p.line += "..."
+		if !p.tty {
+			fmt.Printf("\r%s   ", p.line)
+			return
+		}
+		p.redraw(p.line)
+	}
+}
+
+// writeJSON writes e to p.json, if set, counting the images of every pass
+// of the run.
+func (p *progress) writeJSON(e categorizer.ProgressEvent, offset int) {
+	if p.json == nil {
+		return
+	}
+	e.Done += offset
+	e.Total = p.total
+	p.json.Write(e)
+}
+
+// started records that path is being classified, starting the watcher for
+// slow files with the first image.
+func (p *progress) started(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active[path] = time.Now()
+	p.current = path
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+		go p.watch(p.stop)
+	}
+}
+
+// finished records that path is no longer being classified.
+func (p *progress) finished(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.active, path)
+	delete(p.noted, path)
+	if p.current == path {
+		p.current = ""
+	}
+}
+
+// slowCheck is how often the watcher looks for slow files.
+const slowCheck = time.Second
+
+// watch names each image that has been classifying for slowFileNotice,
+// once, so the file holding up a run can be spotted before it times out.
+func (p *progress) watch(stop <-chan struct{}) {
+	tick := time.NewTicker(slowCheck)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+		p.mu.Lock()
+		for path, start := range p.active {
+			if elapsed := time.Since(start); elapsed >= slowFileNotice && !p.noted[path] {
+				p.noted[path] = true
+				p.slow(path, elapsed)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// slow names an image that has been classifying for elapsed. p.mu must be
+// held.
+func (p *progress) slow(path string, elapsed time.Duration) {
+	note := fmt.Sprintf("still working on %s (%s)", path, elapsed.Round(time.Second))
+	if !p.tty {
+		fmt.Printf("\n%s\n", note)
+		return
+	}
+	line := p.line
+	if line == "" {
+		line = "Processing..."
+	}
+	p.redraw(line + " " + note)
+}
+
+// redraw rewrites the progress and tally lines, then returns to the
+// progress line.
+func (p *progress) redraw(line string) {
+	fmt.Printf("\r\033[K%s\n\033[K%s\033[1A\r", line, p.tally())
+}
+
+// finish stops watching for slow files and moves the cursor below the
+// progress output.
+func (p *progress) finish() {
+	p.mu.Lock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	p.mu.Unlock()
+	if p.tty {
+		fmt.Print("\n\n")
+		return
+	}
+	fmt.Println()
+}
+
+// tally formats the most common categories and the skip count, e.g.
+// "landscape 214 · people 180 · skipped 97".
+func (p *progress) tally() string {
+	cats := make([]string, 0, len(p.counts))
+	for c := range p.counts {
+		cats = append(cats, c)
+	}
+	slices.SortFunc(cats, func(a, b string) int {
+		return cmp.Or(cmp.Compare(p.counts[b], p.counts[a]), cmp.Compare(a, b))
+	})
+
+	var parts []string
+	for _, c := range cats[:min(tallyTop, len(cats))] {
+		parts = append(parts, fmt.Sprintf("%s %d", c, p.counts[c]))
+	}
+	if p.skipped > 0 {
+		parts = append(parts, fmt.Sprintf("skipped %d", p.skipped))
+	}
+	return "  " + strings.Join(parts, " · ")
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// scanTick is how many entries pass between updates of the scan count.
+const scanTick = 1000
+
+// scanWithCount scans dir like scanner.ScanWithOptions, but streams it so
+// that directories large enough to pause on show a running count of the
+// entries read. Images are put in name order, since the stream gives them
+// in file system order.
+func scanWithCount(dir string, opts scanner.Options) (*scanner.Result, error) {
+	n := 0
+	result, err := scanner.Stream(dir, opts, func(scanner.Entry) error {
+		if n++; n%scanTick == 0 {
+			fmt.Printf("\rScanned %s entries...", groupDigits(n))
+		}
+		return nil
+	})
+	if n >= scanTick {
+		fmt.Printf("\rScanned %s entries    \n", groupDigits(n))
+	}
+	if result != nil {
+		slices.Sort(result.ImagePaths)
+	}
+	return result, err
+}
+
+// groupDigits formats n with commas between groups of three digits, such
+// as 120,300.
+func groupDigits(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0 && s[i-1] != '-'; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// newPruneEmptyCmd returns the "prune-empty" command, which removes empty
+// category folders left behind by undo, retries, or manual reshuffling.
+func newPruneEmptyCmd() *cobra.Command {
+	var opts mover.PruneOptions
+	var cliCats string
+
+	cmd := &cobra.Command{
+		Use:   "prune-empty <directory>",
+		Short: "Remove empty category folders imgsort created",
+		Long: `prune-empty removes empty folders imgsort created in a directory, including
+empty subfolders inside them (category/2023/03 is removed bottom-up). The
+folders come from the directory's .imgsort-meta.json; without one, empty
+top-level folders named after a current category are removed. Folders with
+any file or symlink are kept, and symlinks are never followed. Junk files
+such as .DS_Store do not count as content and are deleted with the folder.
+
+  imgsort prune-empty ~/Photos --dry-run
+  imgsort prune-empty ~/Photos --all-empty`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cats, err := categories.Resolve(splitCategories(cliCats))
+			if err != nil {
+				return err
+			}
+			opts.Categories = cats
+
+			removed, err := mover.PruneEmpty(args[0], opts)
+			report.PrintPrune(os.Stdout, args[0], removed, opts.DryRun)
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "List the folders that would be removed without removing them")
+	cmd.Flags().StringVar(&cliCats, "categories", "", "Comma-separated categories to match when the directory has no manifest")
+	cmd.Flags().BoolVar(&opts.AllEmpty, "all-empty", false, "Remove every empty folder, not just ones imgsort created")
+	cmd.Flags().BoolVar(&opts.NoJunkFilter, "no-junk-filter", false, "Keep folders holding only .DS_Store, Thumbs.db, desktop.ini, or ._ files")
+	return cmd
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+// newRankCmd returns the "rank" command, which lists the images in a
+// directory that best match each category, without moving anything.
+func newRankCmd(opts *options) *cobra.Command {
+	var top int
+	var scanOpts scanner.Options
+
+	cmd := &cobra.Command{
+		Use:   "rank <directory> <category>...",
+		Short: "List the images that best match each category, without moving them",
+		Long: `rank scores every image in the directory against the given categories
+and lists, for each category, the images that match it best, for queries
+like "show me the best sunset photos".`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if top < 0 {
+				return fmt.Errorf("--top must not be negative")
+			}
+			return runRank(args[0], args[1:], top, scanOpts, *opts)
+		},
+	}
+
+	cmd.Flags().IntVar(&top, "top", 10, "Number of images to list per category (0 = all)")
+	cmd.Flags().BoolVar(&scanOpts.Recursive, "recursive", false, "Also rank images in subfolders")
+	return cmd
+}
+
+func runRank(dir string, cats []string, top int, scanOpts scanner.Options, opts options) error {
+	result, err := scanner.ScanWithOptions(dir, scanOpts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Checking AI model...")
+	if err := ensureModelFiles(model.RequiredFiles, opts.offline); err != nil {
+		return fmt.Errorf("model setup failed: %w", err)
+	}
+	clip, err := model.NewCLIPSession(opts.onnxRuntime)
+	if err != nil {
+		return fmt.Errorf("cannot load CLIP model: %w", err)
+	}
+	defer clip.Destroy()
+
+	fmt.Printf("Ranking %d images...\n", len(result.ImagePaths))
+	ranked, err := clip.RankImages(result.ImagePaths, cats, model.ClassifyOptions{})
+	if err != nil {
+		return err
+	}
+	for _, cat := range cats {
+		images := ranked[cat]
+		if top > 0 && len(images) > top {
+			images = images[:top]
+		}
+		fmt.Printf("\n%s:\n", cat)
+		for i, im := range images {
+			rel, err := filepath.Rel(dir, im.Path)
+			if err != nil {
+				rel = im.Path
+			}
+			fmt.Printf("  %2d. %s (%.1f%%)\n", i+1, rel, im.Score*100)
+		}
+	}
+	return nil
+}
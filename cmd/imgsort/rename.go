@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// newRenameCategoryCmd returns the "rename-category" command, which renames
+// or merges category folders after a sort.
+func newRenameCategoryCmd() *cobra.Command {
+	var merge, onConflict string
+	var dryRun, noJunkFilter bool
+
+	cmd := &cobra.Command{
+		Use:   "rename-category <directory> [<old> <new>]",
+		Short: "Rename a category folder, or merge several into one",
+		Long: `rename-category moves the contents of one category folder into another,
+resolving name collisions with --on-conflict, and removes the emptied folder.
+
+  imgsort rename-category ~/Photos docs documents
+  imgsort rename-category ~/Photos --merge sea,waves=ocean`,
+		Args: cobra.RangeArgs(1, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var sources []string
+			var target string
+			switch {
+			case merge != "" && len(args) == 1:
+				var err error
+				if sources, target, err = parseMergeSpec(merge); err != nil {
+					return err
+				}
+			case merge == "" && len(args) == 3:
+				sources, target = []string{args[1]}, args[2]
+			default:
+				return fmt.Errorf("give either <old> <new> or --merge a,b=target")
+			}
+
+			strategy, err := mover.ParseConflictStrategy(onConflict)
+			if err != nil {
+				return err
+			}
+			moves, err := mover.MergeCategories(args[0], sources, target, mover.MergeOptions{
+				DryRun:       dryRun,
+				OnConflict:   strategy,
+				NoJunkFilter: noJunkFilter,
+			})
+			report.PrintMerge(os.Stdout, sources, target, moves, dryRun)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&merge, "merge", "", "Merge several categories at once: a,b,c=target")
+	cmd.Flags().StringVar(&onConflict, "on-conflict", "suffix", "What to do when a file exists in the target: suffix, hash, timestamp, skip, or overwrite")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be moved without moving files")
+	cmd.Flags().BoolVar(&noJunkFilter, "no-junk-filter", false, "Move .DS_Store, Thumbs.db, desktop.ini, and ._ files too, instead of deleting them with the emptied folder")
+	return cmd
+}
+
+// parseMergeSpec parses "a,b,c=target".
+func parseMergeSpec(spec string) ([]string, string, error) {
+	left, target, ok := strings.Cut(spec, "=")
+	target = strings.TrimSpace(target)
+	if !ok || target == "" {
+		return nil, "", fmt.Errorf("invalid --merge %q (expected a,b,c=target)", spec)
+	}
+	var sources []string
+	for _, s := range strings.Split(left, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			sources = append(sources, s)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, "", fmt.Errorf("invalid --merge %q: no source categories", spec)
+	}
+	return sources, target, nil
+}
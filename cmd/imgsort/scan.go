@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+	"github.com/bagtoad/imgsort/internal/report"
+	"github.com/bagtoad/imgsort/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+// newScanCmd returns the "scan" command, which lists what a sorting run
+// would process without loading the model.
+func newScanCmd() *cobra.Command {
+	var jsonOut bool
+	var scanOpts scanner.Options
+
+	cmd := &cobra.Command{
+		Use:   "scan <directory>",
+		Short: "List the images a sorting run would classify, without loading the model",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScan(args[0], jsonOut, scanOpts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Write the scan summary as JSON")
+	cmd.Flags().BoolVar(&scanOpts.Recursive, "recursive", false, "Also scan subfolders, skipping category folders imgsort created")
+	cmd.Flags().BoolVar(&scanOpts.NoJunkFilter, "no-junk-filter", false, "Count .DS_Store, Thumbs.db, desktop.ini, and ._ files instead of ignoring them")
+	return cmd
+}
+
+// runScan scans dir with scanOpts and prints the summary. It returns an error wrapping
+// scanner.ErrNoImages when the directory has no images, after still
+// printing the summary. Recursive scans recognize output folders by the
+// custom or default categories when the directory has no manifest.
+func runScan(dir string, jsonOut bool, scanOpts scanner.Options) error {
+	if scanOpts.Recursive {
+		cats, err := categories.Resolve(nil)
+		if err != nil {
+			return err
+		}
+		scanOpts.Categories = cats
+	}
+	result, err := scanner.ScanWithOptions(dir, scanOpts)
+	if err != nil && !errors.Is(err, scanner.ErrNoImages) {
+		return err
+	}
+
+	summary := report.NewScanSummary(dir, result)
+	if jsonOut {
+		if jerr := report.WriteScanJSON(os.Stdout, summary); jerr != nil {
+			return jerr
+		}
+	} else {
+		report.PrintScan(os.Stdout, summary)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/serve"
+	"github.com/spf13/cobra"
+)
+
+// newServeCmd returns the "serve" command, which runs the model for other
+// imgsort instances that pass --backend or --remote.
+func newServeCmd(opts *options) *cobra.Command {
+	var addr string
+	var concurrency int
+	var stdio, allowPaths bool
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Classify images for other imgsort instances over HTTP",
+		Long: `serve loads the CLIP model and answers classification requests from
+imgsort runs on other machines that pass --backend or --remote. If
+` + serve.TokenEnv + ` is set, clients must send the same token. It listens on
+` + serve.DefaultAddr + ` by default; listening on an address other machines can
+reach requires a token.
+
+With --stdio it instead reads one JSON request per line from standard
+input, such as {"id": 1, "path": "a.jpg", "categories": ["beach", "city"]},
+and writes one JSON response per line to standard output, so scripts and
+editors can keep the model loaded between calls. Requests without
+categories use the custom or default list.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if stdio {
+				return runServeStdio(opts.onnxRuntime, opts.offline)
+			}
+			return runServe(addr, serve.Options{
+				Token:       os.Getenv(serve.TokenEnv),
+				Concurrency: concurrency,
+				AllowPaths:  allowPaths,
+			}, opts.onnxRuntime, opts.offline)
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "listen", serve.DefaultAddr, "Address to listen on")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 1, "Maximum number of images to classify at once")
+	cmd.Flags().BoolVar(&allowPaths, "allow-paths", false, "Let clients name images by their path on this machine (--shared-fs) instead of uploading them")
+	cmd.Flags().BoolVar(&stdio, "stdio", false, "Answer newline-delimited JSON requests on stdin instead of listening for HTTP")
+	return cmd
+}
+
+func runServe(addr string, serveOpts serve.Options, onnxRuntime string, offline bool) error {
+	if err := serve.CheckAddr(addr, serveOpts.Token); err != nil {
+		return err
+	}
+
+	fmt.Println("Checking AI model...")
+	if err := ensureModelFiles(model.RequiredFiles, offline); err != nil {
+		return fmt.Errorf("model setup failed: %w", err)
+	}
+	clip, err := model.NewCLIPSession(onnxRuntime)
+	if err != nil {
+		return fmt.Errorf("cannot load CLIP model: %w", err)
+	}
+	defer clip.Destroy()
+
+	srv := serve.NewServer(clip, model.ModelID, serveOpts)
+	fmt.Printf("Serving %s on %s\n", model.ModelID, addr)
+	return http.ListenAndServe(addr, srv.Handler())
+}
+
+func runServeStdio(onnxRuntime string, offline bool) error {
+	// Keep stdout for responses; progress and warnings go to stderr.
+	out := bufio.NewWriter(os.Stdout)
+	os.Stdout = os.Stderr
+
+	cats, err := categories.Resolve(nil)
+	if err != nil {
+		return err
+	}
+	if err := ensureModelFiles(model.RequiredFiles, offline); err != nil {
+		return fmt.Errorf("model setup failed: %w", err)
+	}
+	clip, err := model.NewCLIPSession(onnxRuntime)
+	if err != nil {
+		return fmt.Errorf("cannot load CLIP model: %w", err)
+	}
+	defer clip.Destroy()
+
+	fmt.Fprintf(os.Stderr, "Ready: reading requests from stdin\n")
+	return serve.ServeStdio(os.Stdin, out, clip, cats)
+}
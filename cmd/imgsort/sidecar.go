@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/sidecar"
+)
+
+// writeSidecars writes a classification sidecar next to every image: in its
+// category folder if it was moved, and beside the original otherwise. It
+// returns the number written.
+func writeSidecars(results []categorizer.Result, scores []categorizer.ImageScores, moves []mover.MoveResult, modelID string) (int, error) {
+	byPath := make(map[string]categorizer.ImageScores, len(scores))
+	for _, is := range scores {
+		byPath[is.Path] = is
+	}
+	dest := make(map[string]string, len(moves))
+	for _, m := range moves {
+		if !m.Skipped {
+			dest[m.SourcePath] = m.DestPath
+		}
+	}
+
+	now := time.Now()
+	written := 0
+	for _, r := range results {
+		path := r.Path
+		if d, ok := dest[r.Path]; ok {
+			path = d
+		}
+		sc := sidecar.New(r, byPath[r.Path], modelID, sidecar.DefaultTop, now)
+		if _, err := sidecar.Write(path, sc); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/filedate"
+	"github.com/bagtoad/imgsort/internal/manifest"
+	"github.com/bagtoad/imgsort/internal/report"
+	"github.com/bagtoad/imgsort/internal/scanner"
+	"github.com/bagtoad/imgsort/internal/sidecar"
+	"github.com/bagtoad/imgsort/internal/xattr"
+	"github.com/spf13/cobra"
+)
+
+// newStatsCmd returns the "stats" command, which summarizes how a sorted
+// directory breaks down by category.
+func newStatsCmd() *cobra.Command {
+	var export string
+	var anonymize bool
+
+	cmd := &cobra.Command{
+		Use:   "stats <directory>",
+		Short: "Summarize a sorted directory by category, optionally exporting anonymized numbers to share",
+		Long: `stats counts the images in each category folder of a sorted directory: how
+many, their total size, a histogram of their confidence, and the range of
+dates they were taken. Confidence comes from the sidecar files or extended
+attributes written with --sidecar or --xattr; images without either are
+counted as unscored. Images left at the top level are uncategorized.
+
+--export writes the numbers as JSON, with every image's path, category,
+size, date, and confidence. Add --anonymize to leave out anything that
+identifies a file (paths, file names, and hashes) and keep only the
+numbers per category, for sharing.
+
+  imgsort stats ~/Photos
+  imgsort stats ~/Photos --export stats.json --anonymize`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if anonymize && export == "" {
+				return fmt.Errorf("--anonymize applies to --export")
+			}
+			return runStats(args[0], export, anonymize)
+		},
+	}
+
+	cmd.Flags().StringVar(&export, "export", "", "Write the statistics as JSON to this file (- for stdout)")
+	cmd.Flags().BoolVar(&anonymize, "anonymize", false, "With --export, write only per-category numbers, without paths, file names, or hashes")
+	return cmd
+}
+
+// runStats counts the sorted tree at dir and prints or exports the result.
+func runStats(dir, export string, anonymize bool) error {
+	files, err := libraryFiles(dir)
+	if err != nil {
+		return err
+	}
+	s := report.NewLibraryStats(dir, files)
+	if export == "" {
+		report.PrintLibraryStats(os.Stdout, s)
+		return nil
+	}
+
+	out := os.Stdout
+	if export != "-" {
+		f, err := os.Create(export)
+		if err != nil {
+			return fmt.Errorf("cannot write stats: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if anonymize {
+		err = report.WriteAnonymousStatsJSON(out, report.Anonymize(s))
+	} else {
+		err = report.WriteLibraryStatsJSON(out, s)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write stats: %w", err)
+	}
+	if out != os.Stdout {
+		fmt.Printf("Wrote statistics for %d images to %s\n", s.Images, export)
+		return out.Close()
+	}
+	return nil
+}
+
+// libraryFiles lists the images of the sorted tree at dir with their
+// category, size, date, and recorded confidence. An image's category is the
+// top-level folder holding it; when dir has a manifest, folders imgsort did
+// not create count as uncategorized.
+func libraryFiles(dir string) ([]report.LibraryFile, error) {
+	result, err := scanner.ScanWithOptions(dir, scanner.Options{
+		Recursive:       true,
+		OutputFolders:   true,
+		SplitLivePhotos: true,
+	})
+	if err != nil && !errors.Is(err, scanner.ErrNoImages) {
+		return nil, err
+	}
+	for _, p := range result.Unreadable {
+		log.Printf("Warning: cannot read %s; it is left out of the statistics", p)
+	}
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	warnedXattr := false
+	files := make([]report.LibraryFile, 0, len(result.ImagePaths))
+	for _, p := range result.ImagePaths {
+		f := report.LibraryFile{Path: p}
+		if rel, err := filepath.Rel(dir, p); err == nil {
+			if top, _, nested := strings.Cut(filepath.ToSlash(rel), "/"); nested && (m == nil || m.IsManaged(top)) {
+				f.Category = top
+			}
+		}
+		if info, err := os.Stat(p); err == nil {
+			f.Bytes = info.Size()
+		}
+		if date, _, err := filedate.Date(p); err == nil {
+			f.Date = date
+		}
+
+		sc, ok, err := sidecar.Read(p)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if ok && sc.Category != "" {
+			f.Confidence, f.HasConfidence = sc.Confidence, true
+		} else if cat, conf, ok, err := xattr.Read(p); ok && cat != "" {
+			f.Confidence, f.HasConfidence = conf, true
+		} else if err != nil && !errors.Is(err, xattr.ErrUnsupported) && !warnedXattr {
+			log.Printf("Warning: cannot read extended attributes: %v", err)
+			warnedXattr = true
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/pins"
+	"github.com/bagtoad/imgsort/internal/pipeline"
+	"github.com/bagtoad/imgsort/internal/report"
+	"github.com/bagtoad/imgsort/internal/scanner"
+	"github.com/bagtoad/imgsort/internal/state"
+)
+
+// streamProgressEvery is how many images a streamed run sorts between
+// progress lines.
+const streamProgressEvery = 1000
+
+// checkStreamOptions rejects flags that need every image's result at once,
+// which a streamed run never holds.
+func checkStreamOptions(opts options) error {
+	conflicts := []struct {
+		set  bool
+		flag string
+	}{
+		{opts.groupBursts, "--group-bursts"},
+		{opts.maxPerCat > 0, "--max-per-category"},
+		{opts.minCatSize > 1, "--min-category-size"},
+		{opts.transactional, "--transactional"},
+		{opts.samplePct > 0, "--sample-percent"},
+		{opts.scoresIn != "", "--scores-in"},
+		{opts.scoresOut != "", "--scores-out"},
+		{opts.reportJSON != "", "--report-json"},
+		{opts.diffPlan != "", "--diff-plan"},
+		{opts.export != "", "--export"},
+		{opts.writeIndex, "--write-index"},
+		{opts.suggest, "--suggest"},
+		{opts.emitScript != "", "--emit-script"},
+	}
+	for _, c := range conflicts {
+		if c.set {
+			return fmt.Errorf("--stream cannot be combined with %s, which needs every image's result at once", c.flag)
+		}
+	}
+	if opts.progJSON != "" {
+		return fmt.Errorf("--stream cannot be combined with --progress-json; streamed images report no classification progress")
+	}
+	return nil
+}
+
+// runStream sorts dir one image at a time: the scan hands each image to the
+// classifier as it is found, and each is decided and moved as soon as its
+// scores arrive. Overrides and pins are sorted in their turn. Only counts
+// are kept, so memory does not grow with the number of images, except in a
+// dry run, which remembers each planned name to predict suffixes. Live
+// Photos are not paired.
+func runStream(dir, modelID string, cats []string, classifyOpts model.ClassifyOptions, moveOpts mover.Options, opts options, runStart time.Time) error {
+	clip, cached, store, cleanup, _, err := setupClassifier(opts, modelID)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	pinned, err := pins.Load(dir)
+	if err != nil {
+		return err
+	}
+	workers := 1
+	if opts.backend != "" {
+		workers = opts.backendConns
+	}
+
+	// Files are moved into category folders while the scan is still
+	// reading the tree, so a recursive scan leaves out every folder the
+	// run may sort into, not only those the manifest lists.
+	outputs := append(slices.Clone(cats), mover.UnsortedDir)
+	if opts.openSet != "" {
+		outputs = append(outputs, opts.openSet)
+	}
+
+	fmt.Printf("Sorting %s as images are found...\n", dir)
+	tally := report.NewTally(opts.dryRun)
+	decideOpts := opts.decideOptions()
+	scanRes, scanErr := pipeline.Run(dir, pipeline.Options{
+		Scan: scanner.Options{
+			Recursive:       opts.recursive,
+			Categories:      outputs,
+			CategoryFolders: opts.outputDir == "",
+			SplitLivePhotos: true,
+			NoJunkFilter:    opts.noJunkFilter,
+			ModifiedAfter:   opts.modifiedAfter,
+		},
+		Categories: cats,
+		Classify:   classifyOpts,
+		Workers:    workers,
+		Classifier: func() (categorizer.Classifier, error) { return clip, nil },
+		Route: func(path string) (categorizer.ImageScores, bool, error) {
+			if category, ok := opts.overrides.Lookup(path); ok {
+				return categorizer.ImageScores{Path: path, Override: category}, true, nil
+			}
+			if category, ok := pinned.Lookup(dir, path); ok {
+				return categorizer.ImageScores{Path: path, Pinned: category}, true, nil
+			}
+			return categorizer.ImageScores{}, false, nil
+		},
+		Decide: func(is categorizer.ImageScores) (categorizer.Result, error) {
+			return categorizer.DecideOne(is, decideOpts), nil
+		},
+		Move: moveOpts,
+		Sorted: func(is categorizer.ImageScores, r categorizer.Result, moves []mover.MoveResult, err error) error {
+			if err != nil {
+				return err
+			}
+			if opts.sidecar && !opts.dryRun {
+				if _, err := writeSidecars([]categorizer.Result{r}, []categorizer.ImageScores{is}, moves, modelID); err != nil {
+					return err
+				}
+			}
+			if opts.xattr && !opts.dryRun {
+				if _, err := writeXattrs([]categorizer.Result{r}, moves); err != nil {
+					return err
+				}
+			}
+			tally.Add(r, moves)
+			if opts.verbose {
+				for _, m := range moves {
+					if m.Skipped {
+						fmt.Printf("  Skipped %s (%s)\n", filepath.Base(m.SourcePath), m.Reason)
+					} else {
+						fmt.Printf("  %s → %s\n", filepath.Base(m.SourcePath), m.DestPath)
+					}
+				}
+			}
+			if s := tally.Stats(); s.Images%streamProgressEvery == 0 {
+				fmt.Printf("Sorted %d images so far (%d moved)\n", s.Images, s.Moved)
+			}
+			return nil
+		},
+	})
+	switch {
+	case errors.Is(scanErr, scanner.ErrNoImages) && scanRes != nil && scanRes.UnchangedCount > 0:
+		fmt.Printf("No new images (%d unchanged since the last run)\n", scanRes.UnchangedCount)
+	case scanErr != nil:
+		return scanErr
+	}
+
+	if cached != nil {
+		fmt.Printf("%d images answered from the score cache\n", cached.Hits())
+		if err := store.Save(); err != nil {
+			log.Printf("Warning: %v (set %s to a writable directory to keep scores between runs)", err, appdir.CacheEnv)
+		}
+	}
+	if scanRes != nil {
+		tally.AddNonImage(scanRes.SkippedCount)
+	}
+	stats := tally.Stats()
+	report.PrintStats(os.Stdout, stats)
+	if scanRes != nil {
+		report.PrintUnreadable(os.Stdout, scanRes.Unreadable)
+	}
+
+	if opts.incremental && !opts.dryRun {
+		if err := state.Save(dir, runStart); err != nil {
+			log.Printf("Warning: %v; the next --incremental run will look at every image (set %s to a writable directory)", err, appdir.StateEnv)
+		}
+	}
+	if opts.summaryLine {
+		fmt.Println(report.SummaryLine(stats, time.Since(runStart)))
+	}
+	return nil
+}
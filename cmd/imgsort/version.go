@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/buildinfo"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/onnxlib"
+	"github.com/bagtoad/imgsort/internal/report"
+)
+
+// versionText is printed by --version: the build, the default model, and
+// the ONNX Runtime the binary will use, without loading either.
+func versionText() string {
+	info := buildinfo.Get()
+	commit := info.Commit
+	if commit == "" {
+		commit = "unknown"
+	}
+	runtime := "system library, " + model.MinONNXRuntimeVersion + " or newer"
+	if onnxlib.Embedded() {
+		runtime = "embedded"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "imgsort %s\n", info.Version)
+	fmt.Fprintf(&b, "  commit:         %s\n", commit)
+	fmt.Fprintf(&b, "  model:          %s\n", model.ModelID)
+	fmt.Fprintf(&b, "  onnxruntime:    %s\n", runtime)
+	fmt.Fprintf(&b, "  report schema:  %d\n", report.SchemaVersion)
+	fmt.Fprintf(&b, "  go:             %s %s\n", info.GoVersion, info.Platform)
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/xattr"
+)
+
+// writeXattrs records the category of every categorized image in its
+// extended attributes, on the moved file if it was moved. An image the run
+// left uncategorized has the attributes an earlier run wrote removed, so
+// they never name a category the image is no longer sorted into. Files on
+// a file system without extended attributes are passed over with one
+// warning for the run. It returns the number written.
+func writeXattrs(results []categorizer.Result, moves []mover.MoveResult) (int, error) {
+	dest := make(map[string]string, len(moves))
+	for _, m := range moves {
+		if !m.Skipped {
+			dest[m.SourcePath] = m.DestPath
+		}
+	}
+
+	written, unsupported := 0, 0
+	for _, r := range results {
+		path := r.Path
+		if d, ok := dest[r.Path]; ok {
+			path = d
+		}
+		if r.Skipped || r.Category == "" {
+			err := xattr.Remove(path)
+			if err != nil && !errors.Is(err, xattr.ErrUnsupported) && !errors.Is(err, fs.ErrNotExist) {
+				return written, err
+			}
+			continue
+		}
+		err := xattr.Write(path, r.Category, r.Confidence)
+		if errors.Is(err, xattr.ErrUnsupported) {
+			unsupported++
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		written++
+	}
+	if unsupported > 0 {
+		log.Printf("Warning: %d files are on a file system without extended attributes; their category was not recorded there", unsupported)
+	}
+	return written, nil
+}
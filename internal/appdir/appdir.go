@@ -0,0 +1,47 @@
+// Package appdir locates the directory imgsort keeps its models, caches,
+// and settings in.
+package appdir
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Env overrides the data directory, for containers and other setups where
+// the home directory is not the right place.
+const Env = "IMGSORT_HOME"
+
+// Variables that move one kind of data out of the data directory, for
+// setups such as containers where it is read-only.
+const (
+	ModelsEnv = "IMGSORT_MODELS_DIR"
+	CacheEnv  = "IMGSORT_CACHE_DIR"
+	StateEnv  = "IMGSORT_STATE_DIR"
+)
+
+// Dir returns the data directory: $IMGSORT_HOME if set, otherwise
+// ~/.imgsort.
+func Dir() (string, error) {
+	if dir := os.Getenv(Env); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".imgsort"), nil
+}
+
+// Sub returns the directory for one kind of data: $env if set, otherwise
+// name inside Dir.
+func Sub(env, name string) (string, error) {
+	if dir := os.Getenv(env); dir != "" {
+		return dir, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
@@ -0,0 +1,32 @@
+package appdir
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(Env, "")
+	if dir, err := Dir(); err != nil || dir != filepath.Join(home, ".imgsort") {
+		t.Errorf("Dir() = %q, %v; want ~/.imgsort", dir, err)
+	}
+
+	t.Setenv(Env, "/opt/imgsort")
+	if dir, err := Dir(); err != nil || dir != "/opt/imgsort" {
+		t.Errorf("Dir() = %q, %v; want $%s", dir, err, Env)
+	}
+}
+
+func TestSub(t *testing.T) {
+	t.Setenv(Env, "/srv/imgsort")
+	t.Setenv(CacheEnv, "")
+	if dir, err := Sub(CacheEnv, "cache"); err != nil || dir != filepath.Join("/srv/imgsort", "cache") {
+		t.Errorf("Sub() = %q, %v; want it under the data directory", dir, err)
+	}
+	t.Setenv(CacheEnv, "/var/cache/imgsort")
+	if dir, err := Sub(CacheEnv, "cache"); err != nil || dir != "/var/cache/imgsort" {
+		t.Errorf("Sub() = %q, %v; want $%s", dir, err, CacheEnv)
+	}
+}
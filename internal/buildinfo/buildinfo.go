@@ -0,0 +1,48 @@
+// Package buildinfo identifies the running imgsort binary.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit are set when building a release:
+//
+//	go build -ldflags "-X github.com/bagtoad/imgsort/internal/buildinfo.Version=v1.2.0 \
+//	  -X github.com/bagtoad/imgsort/internal/buildinfo.Commit=$(git rev-parse --short HEAD)"
+//
+// Without them, Get falls back to what the Go toolchain recorded.
+var (
+	Version string
+	Commit  string
+)
+
+// Info describes the binary.
+type Info struct {
+	Version   string
+	Commit    string
+	GoVersion string
+	Platform  string
+}
+
+// Get returns the binary's version information. A build without -ldflags
+// reports the module version for go install builds and "dev" otherwise,
+// and the VCS revision if the toolchain stamped one.
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, GoVersion: runtime.Version(), Platform: runtime.GOOS + "/" + runtime.GOARCH}
+	bi, ok := debug.ReadBuildInfo()
+	if info.Version == "" {
+		info.Version = "dev"
+		if ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+	}
+	if info.Commit == "" && ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" {
+				info.Commit = s.Value[:min(len(s.Value), 12)]
+			}
+		}
+	}
+	return info
+}
@@ -0,0 +1,116 @@
+// Package burst detects sequences of burst-mode shots so they can be sorted
+// into the same category.
+package burst
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxGap is the largest modification-time gap between consecutive
+// shots that still counts as the same burst.
+const DefaultMaxGap = 2 * time.Second
+
+// File is a candidate burst member.
+type File struct {
+	Path    string
+	ModTime time.Time
+}
+
+// Group is a detected burst sequence with at least two members, in
+// numbering order. ID is the base name of the first member.
+type Group struct {
+	ID    string
+	Paths []string
+}
+
+// numbered matches a file stem ending in a run of digits, optionally followed
+// by a non-digit suffix (IMG_2041, DSC02041-edit).
+var numbered = regexp.MustCompile(`^(.*?)(\d+)(\D*)$`)
+
+// member is a File with its parsed sequence number.
+type member struct {
+	File
+	num int
+}
+
+// Detect groups files into burst sequences. Files belong to the same burst
+// when they share a directory, name prefix and suffix, and extension, their
+// numbers are consecutive, and their modification times are at most maxGap
+// apart. Detection is conservative: any file that does not clearly belong to
+// a sequence is left out of every group.
+func Detect(files []File, maxGap time.Duration) []Group {
+	byKey := make(map[string][]member)
+	var keys []string
+	for _, f := range files {
+		ext := filepath.Ext(f.Path)
+		stem := strings.TrimSuffix(filepath.Base(f.Path), ext)
+		m := numbered.FindStringSubmatch(stem)
+		if m == nil {
+			continue
+		}
+		num, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		key := filepath.Dir(f.Path) + "\x00" + m[1] + "\x00" + m[3] + "\x00" + strings.ToLower(ext)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], member{File: f, num: num})
+	}
+
+	var groups []Group
+	for _, key := range keys {
+		members := byKey[key]
+		sort.Slice(members, func(i, j int) bool { return members[i].num < members[j].num })
+
+		run := []member{members[0]}
+		flush := func() {
+			if len(run) >= 2 {
+				g := Group{ID: filepath.Base(run[0].Path)}
+				for _, m := range run {
+					g.Paths = append(g.Paths, m.Path)
+				}
+				groups = append(groups, g)
+			}
+		}
+		for _, m := range members[1:] {
+			prev := run[len(run)-1]
+			if m.num == prev.num+1 && absDuration(m.ModTime.Sub(prev.ModTime)) <= maxGap {
+				run = append(run, m)
+				continue
+			}
+			flush()
+			run = []member{m}
+		}
+		flush()
+	}
+	return groups
+}
+
+// DetectPaths stats each path and runs Detect. Files that cannot be
+// stat'ed are treated individually.
+func DetectPaths(paths []string, maxGap time.Duration) []Group {
+	files := make([]File, 0, len(paths))
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		files = append(files, File{Path: p, ModTime: info.ModTime()})
+	}
+	return Detect(files, maxGap)
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,97 @@
+package burst
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func file(path string, offset time.Duration) File {
+	return File{Path: path, ModTime: base.Add(offset)}
+}
+
+func TestDetectSequence(t *testing.T) {
+	files := []File{
+		file("/p/IMG_2043.jpg", 2*time.Second),
+		file("/p/IMG_2041.jpg", 0),
+		file("/p/IMG_2042.jpg", time.Second),
+		file("/p/beach.jpg", 0),
+	}
+
+	groups := Detect(files, DefaultMaxGap)
+	expected := []Group{{
+		ID:    "IMG_2041.jpg",
+		Paths: []string{"/p/IMG_2041.jpg", "/p/IMG_2042.jpg", "/p/IMG_2043.jpg"},
+	}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("expected %+v, got %+v", expected, groups)
+	}
+}
+
+func TestDetectSplitsOnTimeGap(t *testing.T) {
+	files := []File{
+		file("/p/IMG_1.jpg", 0),
+		file("/p/IMG_2.jpg", time.Second),
+		file("/p/IMG_3.jpg", time.Minute),
+		file("/p/IMG_4.jpg", time.Minute+time.Second),
+	}
+
+	groups := Detect(files, DefaultMaxGap)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", groups)
+	}
+	if groups[0].ID != "IMG_1.jpg" || groups[1].ID != "IMG_3.jpg" {
+		t.Errorf("unexpected groups: %+v", groups)
+	}
+}
+
+func TestDetectConservative(t *testing.T) {
+	tests := map[string][]File{
+		"numbering gap": {
+			file("/p/IMG_10.jpg", 0),
+			file("/p/IMG_12.jpg", time.Second),
+		},
+		"different prefix": {
+			file("/p/IMG_10.jpg", 0),
+			file("/p/DSC_11.jpg", time.Second),
+		},
+		"different extension": {
+			file("/p/IMG_10.jpg", 0),
+			file("/p/IMG_11.png", time.Second),
+		},
+		"different directory": {
+			file("/a/IMG_10.jpg", 0),
+			file("/b/IMG_11.jpg", time.Second),
+		},
+		"no number": {
+			file("/p/beach.jpg", 0),
+			file("/p/beach-copy.jpg", 0),
+		},
+		"single file": {
+			file("/p/IMG_10.jpg", 0),
+		},
+	}
+
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			if groups := Detect(files, DefaultMaxGap); len(groups) != 0 {
+				t.Errorf("expected no groups, got %+v", groups)
+			}
+		})
+	}
+}
+
+func TestDetectSuffix(t *testing.T) {
+	files := []File{
+		file("/p/DSC02041-edit.jpg", 0),
+		file("/p/DSC02042-edit.jpg", time.Second),
+		file("/p/DSC02042.jpg", time.Second),
+	}
+
+	groups := Detect(files, DefaultMaxGap)
+	if len(groups) != 1 || len(groups[0].Paths) != 2 || groups[0].ID != "DSC02041-edit.jpg" {
+		t.Errorf("expected one group of edited files, got %+v", groups)
+	}
+}
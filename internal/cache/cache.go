@@ -0,0 +1,177 @@
+// Package cache remembers classification scores across runs, keyed by file
+// contents rather than path, so re-running over an unchanged (or
+// reorganized) library skips the model.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// Dir returns the cache directory: $IMGSORT_CACHE_DIR, or ~/.imgsort/cache/.
+func Dir() (string, error) {
+	return appdir.Sub(appdir.CacheEnv, "cache")
+}
+
+// DefaultPath returns the scores cache file inside Dir.
+func DefaultPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "scores.json"), nil
+}
+
+// entry is one file's cached scores. Scores are stored per context (model,
+// categories, and prompts), since scores are only valid for the exact
+// category list that produced them.
+type entry struct {
+	Key    Key                           `json:"key"`
+	Scores map[string]map[string]float32 `json:"scores"`
+}
+
+// Store is a scores cache backed by a JSON file. It is safe for concurrent use.
+type Store struct {
+	path    string
+	strict  bool
+	mu      sync.Mutex
+	entries map[string]*entry // by Key.quick()
+	dirty   bool
+}
+
+// Open loads the cache at path; a missing file yields an empty cache. In
+// strict mode a hit also requires the full content hash to match.
+func Open(path string, strict bool) (*Store, error) {
+	s := &Store{path: path, strict: strict, entries: make(map[string]*entry)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cache: %w", err)
+	}
+
+	var list []*entry
+	if err := json.Unmarshal(data, &list); err != nil {
+		// A corrupt cache is only a performance problem; start over.
+		return s, nil
+	}
+	for _, e := range list {
+		s.entries[e.Key.quick()] = e
+	}
+	return s, nil
+}
+
+// Context returns the cache context for a model and classification setup.
+// Preprocessing and expansion options only enter the context when they
+// differ from the defaults, so existing caches stay valid.
+func Context(modelID string, categories []string, opts model.ClassifyOptions) string {
+	small := opts.Preprocess.Small
+	if small == model.SmallStretch {
+		small = ""
+	}
+	pool := opts.Pool
+	if len(opts.Expansions)+len(opts.ExtraPrompts) == 0 || pool == model.PoolMax {
+		pool = ""
+	}
+	data, _ := json.Marshal(struct {
+		Model        string               `json:"model"`
+		Categories   []string             `json:"categories"`
+		Prompts      map[string]string    `json:"prompts,omitempty"`
+		NoBaseline   bool                 `json:"no_baseline,omitempty"`
+		Small        model.SmallImageMode `json:"small,omitempty"`
+		MinSize      int                  `json:"min_size,omitempty"`
+		Expansions   map[string][]string  `json:"expansions,omitempty"`
+		ExtraPrompts map[string][]string  `json:"extra_prompts,omitempty"`
+		Pool         model.PoolMode       `json:"pool,omitempty"`
+		Calibrated   bool                 `json:"calibrated,omitempty"`
+	}{modelID, categories, opts.Prompts, opts.NoBaseline, small, opts.Preprocess.MinSize, opts.Expansions, opts.ExtraPrompts, pool,
+		model.Calibrates(categories, opts)})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Lookup returns cached scores for path in context ctx, and the file's key
+// for a later Put.
+//
+// In strict mode, an entry recorded without a full hash (by a non-strict
+// run) is trusted on its quick key once and upgraded with the full hash, so
+// switching to strict mode does not discard the cache.
+func (s *Store) Lookup(path, ctx string) (map[string]float32, Key, error) {
+	key, err := Identify(path, s.strict)
+	if err != nil {
+		return nil, Key{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key.quick()]
+	if !ok {
+		return nil, key, nil
+	}
+	if s.strict {
+		switch e.Key.Full {
+		case "":
+			e.Key.Full = key.Full
+			s.dirty = true
+		case key.Full:
+		default:
+			return nil, key, nil
+		}
+	}
+	return e.Scores[ctx], key, nil
+}
+
+// Put records scores for the file identified by key in context ctx.
+func (s *Store) Put(key Key, ctx string, scores map[string]float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key.quick()]
+	if !ok || (key.Full != "" && e.Key.Full != "" && e.Key.Full != key.Full) {
+		e = &entry{Key: key, Scores: make(map[string]map[string]float32)}
+		s.entries[key.quick()] = e
+	}
+	if key.Full != "" {
+		e.Key.Full = key.Full
+	}
+	e.Scores[ctx] = scores
+	s.dirty = true
+}
+
+// Save writes the cache back to disk if anything changed.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+
+	list := make([]*entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e)
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("cannot create cache directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("cannot write cache: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("cannot write cache: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
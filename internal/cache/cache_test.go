@@ -0,0 +1,276 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// countingClassifier returns fixed scores and counts calls.
+type countingClassifier struct {
+	calls int
+}
+
+func (c *countingClassifier) ClassifyWithOptions(string, []string, model.ClassifyOptions) (map[string]float32, error) {
+	c.calls++
+	return map[string]float32{model.BaselineCategory: 0.2, "beach": 0.8}, nil
+}
+
+// writeFile creates a file of size bytes with a repeating pattern.
+func writeFile(t testing.TB, path string, size int) {
+	t.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClassifierHitsAfterMove(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.jpg")
+	writeFile(t, img, 1000)
+
+	store, err := Open(filepath.Join(dir, "cache.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingClassifier{}
+	c := NewClassifier(inner, store, "m")
+	cats := []string{"beach"}
+
+	if _, err := c.ClassifyWithOptions(img, cats, model.ClassifyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Moving the file keeps its contents and mtime, so it still hits.
+	moved := filepath.Join(dir, "beach", "a.jpg")
+	os.MkdirAll(filepath.Dir(moved), 0755)
+	if err := os.Rename(img, moved); err != nil {
+		t.Fatal(err)
+	}
+	scores, err := c.ClassifyWithOptions(moved, cats, model.ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 1 || c.Hits() != 1 || scores["beach"] != 0.8 {
+		t.Errorf("expected a cache hit after moving, got %d calls, %d hits", inner.calls, c.Hits())
+	}
+
+	// Different categories are a different context.
+	if _, err := c.ClassifyWithOptions(moved, []string{"beach", "city"}, model.ClassifyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("a different category list should miss, got %d calls", inner.calls)
+	}
+}
+
+func TestClassifierMissesWhenChanged(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.jpg")
+	writeFile(t, img, 1000)
+
+	store, _ := Open(filepath.Join(dir, "cache.json"), false)
+	inner := &countingClassifier{}
+	c := NewClassifier(inner, store, "m")
+	c.ClassifyWithOptions(img, []string{"beach"}, model.ClassifyOptions{})
+
+	writeFile(t, img, 1001)
+	c.ClassifyWithOptions(img, []string{"beach"}, model.ClassifyOptions{})
+	if inner.calls != 2 {
+		t.Errorf("a modified file should miss, got %d calls", inner.calls)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.jpg")
+	writeFile(t, img, 1000)
+	cachePath := filepath.Join(dir, "cache", "scores.json")
+
+	store, _ := Open(cachePath, false)
+	NewClassifier(&countingClassifier{}, store, "m").ClassifyWithOptions(img, []string{"beach"}, model.ClassifyOptions{})
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(cachePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingClassifier{}
+	NewClassifier(inner, reopened, "m").ClassifyWithOptions(img, []string{"beach"}, model.ClassifyOptions{})
+	if inner.calls != 0 {
+		t.Error("expected a hit from the saved cache")
+	}
+}
+
+func TestStrictModeChecksFullHash(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.jpg")
+	writeFile(t, img, 4*sampleSize)
+	cachePath := filepath.Join(dir, "scores.json")
+	cats := []string{"beach"}
+
+	// A non-strict run records no full hash.
+	loose, _ := Open(cachePath, false)
+	NewClassifier(&countingClassifier{}, loose, "m").ClassifyWithOptions(img, cats, model.ClassifyOptions{})
+	loose.Save()
+
+	// Upgrading to strict trusts the entry once and records its full hash.
+	strict, _ := Open(cachePath, true)
+	inner := &countingClassifier{}
+	c := NewClassifier(inner, strict, "m")
+	c.ClassifyWithOptions(img, cats, model.ClassifyOptions{})
+	if inner.calls != 0 {
+		t.Fatal("switching to strict mode should not invalidate the cache")
+	}
+
+	// Change a byte the sample does not cover, keeping size and mtime.
+	info, _ := os.Stat(img)
+	f, err := os.OpenFile(img, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff}, 2*sampleSize)
+	f.Close()
+	os.Chtimes(img, info.ModTime(), info.ModTime())
+
+	c.ClassifyWithOptions(img, cats, model.ClassifyOptions{})
+	if inner.calls != 1 {
+		t.Error("strict mode should miss when only the middle of the file changed")
+	}
+
+	loose2, _ := Open(cachePath, false)
+	if scores, _, _ := loose2.Lookup(img, Context("m", cats, model.ClassifyOptions{})); scores == nil {
+		t.Error("non-strict mode only compares the sample, so it should still hit")
+	}
+}
+
+// BenchmarkIdentify compares the cheap key, which reads at most 128 KiB,
+// with the full hash on a 16 MiB file. Cache-hit runs use the former, so
+// their cost is dominated by opening and statting files.
+func BenchmarkIdentify(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "big.jpg")
+	writeFile(b, path, 16<<20)
+
+	for _, strict := range []bool{false, true} {
+		name := "quick"
+		if strict {
+			name = "full"
+		}
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := Identify(path, strict); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestIdentifySmallFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "small.jpg")
+	writeFile(t, path, 10)
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(path, mtime, mtime)
+
+	k, err := Identify(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Size != 10 || !k.ModTime.Equal(mtime) || k.Sample != k.Full {
+		t.Errorf("small files should hash whole contents for both keys, got %+v", k)
+	}
+}
+
+func TestContextExpansions(t *testing.T) {
+	cats := []string{"dog", "cat"}
+	base := Context("m", cats, model.ClassifyOptions{})
+	if Context("m", cats, model.ClassifyOptions{Pool: model.PoolMean}) != base {
+		t.Error("pooling without expansions should not change the context")
+	}
+
+	expanded := model.ClassifyOptions{Expansions: map[string][]string{"dog": {"puppy"}}}
+	if Context("m", cats, expanded) == base {
+		t.Error("expansions should change the context")
+	}
+	explicitMax := expanded
+	explicitMax.Pool = model.PoolMax
+	if Context("m", cats, explicitMax) != Context("m", cats, expanded) {
+		t.Error("max pooling is the default and should not change the context")
+	}
+	mean := expanded
+	mean.Pool = model.PoolMean
+	if Context("m", cats, mean) == Context("m", cats, expanded) {
+		t.Error("mean pooling should change the context")
+	}
+
+	extra := model.ClassifyOptions{ExtraPrompts: map[string][]string{"dog": {"a dog catching a frisbee"}}}
+	if Context("m", cats, extra) == base {
+		t.Error("extra prompts should change the context")
+	}
+	extraMean := extra
+	extraMean.Pool = model.PoolMean
+	if Context("m", cats, extraMean) == Context("m", cats, extra) {
+		t.Error("mean pooling should change the context with extra prompts")
+	}
+}
+
+func TestContextInvalidation(t *testing.T) {
+	cats := []string{"dog", "cat", "bird", "fish"}
+	base := Context("m", cats, model.ClassifyOptions{})
+
+	tests := []struct {
+		name string
+		ctx  string
+	}{
+		{"model", Context("other", cats, model.ClassifyOptions{})},
+		{"added category", Context("m", append(cats[:4:4], "horse"), model.ClassifyOptions{})},
+		{"removed category", Context("m", cats[:3], model.ClassifyOptions{})},
+		{"prompt", Context("m", cats, model.ClassifyOptions{Prompts: map[string]string{"dog": "a photo of a puppy"}})},
+		{"baseline", Context("m", cats, model.ClassifyOptions{NoBaseline: true})},
+		{"small images", Context("m", cats, model.ClassifyOptions{Preprocess: model.PreprocessOptions{Small: model.SmallPad}})},
+		{"minimum size", Context("m", cats, model.ClassifyOptions{Preprocess: model.PreprocessOptions{MinSize: 64}})},
+	}
+	seen := map[string]string{base: "base"}
+	for _, tt := range tests {
+		if prev, ok := seen[tt.ctx]; ok {
+			t.Errorf("changing the %s gives the same context as %s", tt.name, prev)
+		}
+		seen[tt.ctx] = tt.name
+	}
+
+	// Settings that only affect decisions or throughput reuse the scores.
+	if Context("m", cats, model.ClassifyOptions{ChunkSize: 2}) != base {
+		t.Error("the chunk size should not change the context")
+	}
+	if Context("m", cats, model.ClassifyOptions{Preprocess: model.PreprocessOptions{Small: model.SmallStretch}}) != base {
+		t.Error("the default small-image mode should not change the context")
+	}
+}
+
+func TestClassifierReusesScoresPerContext(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.jpg")
+	writeFile(t, img, 1000)
+
+	store, _ := Open(filepath.Join(dir, "cache.json"), false)
+	inner := &countingClassifier{}
+	c := NewClassifier(inner, store, "m")
+	prompts := model.ClassifyOptions{Prompts: map[string]string{"beach": "a photo of the seaside"}}
+	for _, opts := range []model.ClassifyOptions{{}, prompts, {}, prompts} {
+		if _, err := c.ClassifyWithOptions(img, []string{"beach"}, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected one model call per setup, each then cached, got %d", inner.calls)
+	}
+}
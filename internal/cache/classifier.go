@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"sync/atomic"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// Classifier wraps another classifier, answering from the store when it can
+// and recording fresh scores otherwise.
+type Classifier struct {
+	inner   categorizer.Classifier
+	store   *Store
+	modelID string
+
+	hits atomic.Int64
+}
+
+// NewClassifier returns a caching wrapper around inner, which runs modelID.
+func NewClassifier(inner categorizer.Classifier, store *Store, modelID string) *Classifier {
+	return &Classifier{inner: inner, store: store, modelID: modelID}
+}
+
+// ClassifyWithOptions implements categorizer.Classifier.
+func (c *Classifier) ClassifyWithOptions(imagePath string, categories []string, opts model.ClassifyOptions) (map[string]float32, error) {
+	ctx := Context(c.modelID, categories, opts)
+	cached, key, err := c.store.Lookup(imagePath, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		c.hits.Add(1)
+		return cached, nil
+	}
+
+	scores, err := c.inner.ClassifyWithOptions(imagePath, categories, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.store.Put(key, ctx, scores)
+	return scores, nil
+}
+
+// Hits returns how many images were answered from the cache.
+func (c *Classifier) Hits() int {
+	return int(c.hits.Load())
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// sampleSize is how much of the start and end of a file the quick key hashes.
+const sampleSize = 64 << 10
+
+// Key identifies a file's contents independently of its path, so files that
+// were moved but not changed still hit the cache.
+type Key struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+
+	// Sample is a SHA-256 of the first and last 64 KiB. Together with size
+	// and mtime it is the primary key; computing it reads at most 128 KiB.
+	Sample string `json:"sample"`
+
+	// Full is a SHA-256 of the whole file. It is only computed in strict
+	// mode and is empty otherwise.
+	Full string `json:"full,omitempty"`
+}
+
+// quick returns the primary lookup key.
+func (k Key) quick() string {
+	return fmt.Sprintf("%d-%d-%s", k.Size, k.ModTime.UnixNano(), k.Sample)
+}
+
+// Identify computes the cheap key for path, and the full content hash too
+// when strict is set.
+func Identify(path string, strict bool) (Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Key{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return Key{}, err
+	}
+	k := Key{Size: info.Size(), ModTime: info.ModTime().UTC()}
+
+	h := sha256.New()
+	if k.Size <= 2*sampleSize {
+		if _, err := io.Copy(h, f); err != nil {
+			return Key{}, err
+		}
+	} else {
+		if _, err := io.CopyN(h, f, sampleSize); err != nil {
+			return Key{}, err
+		}
+		if _, err := f.Seek(-sampleSize, io.SeekEnd); err != nil {
+			return Key{}, err
+		}
+		if _, err := io.CopyN(h, f, sampleSize); err != nil {
+			return Key{}, err
+		}
+	}
+	k.Sample = hex.EncodeToString(h.Sum(nil))
+
+	if strict {
+		if k.Full, err = fullHash(f); err != nil {
+			return Key{}, err
+		}
+	}
+	return k, nil
+}
+
+// fullHash returns the SHA-256 of the whole file.
+func fullHash(f *os.File) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,72 @@
+package cache
+
+// Cache statuses of a file, recorded in Probe.Status.
+const (
+	// StatusHit means the file's scores are cached for this run's context.
+	StatusHit = "hit"
+	// StatusMiss means nothing is cached for the file's contents.
+	StatusMiss = "miss"
+	// StatusOtherSettings means the file's scores are cached, but for a
+	// different model, category list, or prompts.
+	StatusOtherSettings = "other_settings"
+	// StatusUnreadable means the file could not be read to compute its key.
+	StatusUnreadable = "unreadable"
+)
+
+// Probe is what the cache holds for a set of files in one context.
+type Probe struct {
+	// Status maps each path probed to one of the Status constants.
+	Status map[string]string
+
+	Hits          int
+	Misses        int
+	OtherSettings int
+	Unreadable    int
+}
+
+// Uncached returns how many files would need the model.
+func (p Probe) Uncached() int {
+	return p.Misses + p.OtherSettings + p.Unreadable
+}
+
+// Probe reports which of paths a lookup in context ctx would answer. It
+// reads only the files' keys and the index of cached contexts, never their
+// scores, and changes nothing: a strict store's entry without a full hash
+// counts as a hit, as Lookup would trust it.
+func (s *Store) Probe(paths []string, ctx string) Probe {
+	p := Probe{Status: make(map[string]string, len(paths))}
+	for _, path := range paths {
+		status := s.probe(path, ctx)
+		p.Status[path] = status
+		switch status {
+		case StatusHit:
+			p.Hits++
+		case StatusMiss:
+			p.Misses++
+		case StatusOtherSettings:
+			p.OtherSettings++
+		case StatusUnreadable:
+			p.Unreadable++
+		}
+	}
+	return p
+}
+
+// probe returns the cache status of one file.
+func (s *Store) probe(path, ctx string) string {
+	key, err := Identify(path, s.strict)
+	if err != nil {
+		return StatusUnreadable
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key.quick()]
+	if !ok || (s.strict && e.Key.Full != "" && e.Key.Full != key.Full) {
+		return StatusMiss
+	}
+	if _, ok := e.Scores[ctx]; !ok {
+		return StatusOtherSettings
+	}
+	return StatusHit
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+func TestProbe(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"hit.jpg", "other.jpg", "new.jpg"} {
+		p := filepath.Join(dir, name)
+		writeFile(t, p, 1000+len(paths))
+		paths = append(paths, p)
+	}
+	paths = append(paths, filepath.Join(dir, "gone.jpg"))
+
+	cachePath := filepath.Join(dir, "cache.json")
+	store, err := Open(cachePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingClassifier{}
+	NewClassifier(inner, store, "m").ClassifyWithOptions(paths[0], []string{"beach"}, model.ClassifyOptions{})
+	NewClassifier(inner, store, "other-model").ClassifyWithOptions(paths[1], []string{"beach"}, model.ClassifyOptions{})
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := os.ReadFile(cachePath)
+
+	p := store.Probe(paths, Context("m", []string{"beach"}, model.ClassifyOptions{}))
+	want := map[string]string{
+		paths[0]: StatusHit,
+		paths[1]: StatusOtherSettings,
+		paths[2]: StatusMiss,
+		paths[3]: StatusUnreadable,
+	}
+	for path, status := range want {
+		if p.Status[path] != status {
+			t.Errorf("%s: expected %s, got %s", filepath.Base(path), status, p.Status[path])
+		}
+	}
+	if p.Hits != 1 || p.OtherSettings != 1 || p.Misses != 1 || p.Unreadable != 1 || p.Uncached() != 3 {
+		t.Errorf("unexpected counts: %+v", p)
+	}
+
+	// Probing is read-only: nothing is classified or written.
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(cachePath); string(after) != string(saved) || inner.calls != 2 {
+		t.Errorf("probing changed the cache or ran the classifier (%d calls)", inner.calls)
+	}
+
+	// A strict store misses a file whose full hash changed.
+	strict, err := Open(cachePath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClassifier(inner, strict, "m")
+	c.ClassifyWithOptions(paths[0], []string{"beach"}, model.ClassifyOptions{})
+	info, _ := os.Stat(paths[0])
+	data, _ := os.ReadFile(paths[0])
+	data[500]++
+	os.WriteFile(paths[0], data, 0644)
+	os.Chtimes(paths[0], info.ModTime(), info.ModTime())
+	if got := strict.Probe(paths[:1], Context("m", []string{"beach"}, model.ClassifyOptions{})).Status[paths[0]]; got != StatusMiss {
+		t.Errorf("expected a changed file to miss in strict mode, got %s", got)
+	}
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/video"
+)
+
+// DefaultThumbLimit is the default size cap for the thumbnail cache.
+const DefaultThumbLimit = 256 << 20
+
+// ThumbSize is the size Keep and Load cache thumbnails at.
+const ThumbSize = 160
+
+// thumbQuality is the JPEG quality thumbnails are stored at.
+const thumbQuality = 85
+
+// ThumbDir returns the thumbnail cache directory inside Dir.
+func ThumbDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "thumbs"), nil
+}
+
+// Decoder decodes an image file for Thumbs on a cache miss.
+type Decoder func(path string) (image.Image, error)
+
+// Thumbs caches downscaled previews on disk, keyed by file contents and
+// target size, so reports and review screens don't decode every image on
+// every run. The least recently used thumbnails are evicted once the cache
+// grows past its limit. It is safe for concurrent use.
+type Thumbs struct {
+	dir    string
+	limit  int64
+	decode Decoder
+
+	mu sync.Mutex
+	// total is the cache's size in bytes as of the last eviction plus what
+	// was stored since, or -1 before the first store.
+	total int64
+}
+
+// OpenThumbs returns a thumbnail cache in dir capped at limit bytes. A nil
+// decode decodes files with the registered image formats.
+func OpenThumbs(dir string, limit int64, decode Decoder) *Thumbs {
+	if decode == nil {
+		decode = decodeFile
+	}
+	return &Thumbs{dir: dir, limit: limit, decode: decode, total: -1}
+}
+
+// Get returns a thumbnail of path whose longer side is at most size pixels,
+// decoding the image only if no cached thumbnail matches its contents. If the
+// new thumbnail cannot be cached, it is returned along with the error.
+func (t *Thumbs) Get(path string, size int) (image.Image, error) {
+	key, err := Identify(path, false)
+	if err != nil {
+		return nil, err
+	}
+	if img, ok := t.load(key, size); ok {
+		return img, nil
+	}
+
+	img, err := t.decode(path)
+	if err != nil {
+		return nil, err
+	}
+	thumb := model.Thumbnail(img, size)
+	return thumb, t.store(key, size, thumb)
+}
+
+// Put caches a thumbnail for the file identified by key from an image that
+// is already decoded, such as during preprocessing.
+func (t *Thumbs) Put(key Key, size int, img image.Image) error {
+	return t.store(key, size, model.Thumbnail(img, size))
+}
+
+// Keep caches a ThumbSize thumbnail of img, decoded from the file at path,
+// for model.PreprocessOptions.Decoded. Failures are ignored; a missing
+// thumbnail only costs a decode later.
+func (t *Thumbs) Keep(path string, img image.Image) {
+	if key, err := Identify(path, false); err == nil {
+		t.Put(key, ThumbSize, img)
+	}
+}
+
+// Load returns a ThumbSize thumbnail of path, for palette.Classifier.Load.
+// A thumbnail that was decoded but could not be cached is still returned.
+func (t *Thumbs) Load(path string) (image.Image, error) {
+	img, err := t.Get(path, ThumbSize)
+	if img != nil {
+		return img, nil
+	}
+	return nil, err
+}
+
+// Clear removes every cached thumbnail.
+func (t *Thumbs) Clear() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total = -1
+	return os.RemoveAll(t.dir)
+}
+
+// file returns the cache path for a file's thumbnail at size.
+func (t *Thumbs) file(key Key, size int) string {
+	return filepath.Join(t.dir, fmt.Sprintf("%s-%d-%d.jpg", key.Sample, key.Size, size))
+}
+
+// load reads a cached thumbnail and marks it as recently used.
+func (t *Thumbs) load(key Key, size int) (image.Image, bool) {
+	path := t.file(key, size)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		// A damaged thumbnail is only a performance problem; rebuild it.
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return img, true
+}
+
+// store writes a thumbnail and evicts old ones to stay within the limit.
+func (t *Thumbs) store(key Key, size int, img image.Image) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return fmt.Errorf("cannot create thumbnail cache: %w", err)
+	}
+	path := t.file(key, size)
+	tmp, err := os.CreateTemp(t.dir, ".thumb-*")
+	if err != nil {
+		return fmt.Errorf("cannot write thumbnail: %w", err)
+	}
+	counter := &countingWriter{w: tmp}
+	err = jpeg.Encode(counter, img, &jpeg.Options{Quality: thumbQuality})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot write thumbnail: %w", err)
+	}
+
+	// Listing the cache on every store would make filling it quadratic, so
+	// only a cache that may be over its limit is listed.
+	if t.total >= 0 {
+		t.total += counter.n
+		if t.total <= t.limit {
+			return nil
+		}
+	}
+	return t.evict(path)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// evict removes the least recently used thumbnails, other than keep, until
+// the cache fits within its limit.
+func (t *Thumbs) evict(keep string) error {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return err
+	}
+
+	type thumb struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var thumbs []thumb
+	var total int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		total += info.Size()
+		thumbs = append(thumbs, thumb{filepath.Join(t.dir, e.Name()), info.Size(), info.ModTime()})
+	}
+	t.total = total
+	if total <= t.limit {
+		return nil
+	}
+
+	slices.SortFunc(thumbs, func(a, b thumb) int { return a.used.Compare(b.used) })
+	for _, th := range thumbs {
+		if total <= t.limit {
+			break
+		}
+		if th.path == keep {
+			continue
+		}
+		if err := os.Remove(th.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= th.size
+		t.total = total
+	}
+	return nil
+}
+
+// decodeFile decodes an image file with the registered formats, or the
+// representative frame of a video.
+func decodeFile(path string) (image.Image, error) {
+	if video.Is(path) {
+		return video.Frame(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s: %w", path, err)
+	}
+	return img, nil
+}
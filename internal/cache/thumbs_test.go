@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingDecoder decodes files normally and counts calls.
+type countingDecoder struct {
+	calls int
+}
+
+func (d *countingDecoder) decode(path string) (image.Image, error) {
+	d.calls++
+	return decodeFile(path)
+}
+
+// writePNG creates a w x h PNG whose pixels depend on seed.
+func writePNG(t *testing.T, path string, w, h int, seed uint8) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x) + seed, G: uint8(y), B: seed, A: 255})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThumbsReuseAcrossRuns(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.png")
+	writePNG(t, img, 400, 200, 1)
+	thumbDir := filepath.Join(dir, "thumbs")
+
+	var dec countingDecoder
+	thumb, err := OpenThumbs(thumbDir, DefaultThumbLimit, dec.decode).Get(img, 160)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 160 || b.Dy() != 80 {
+		t.Errorf("expected a 160x80 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+
+	// A second run, after the image was reorganized, decodes nothing.
+	moved := filepath.Join(dir, "beach", "a.png")
+	if err := os.MkdirAll(filepath.Dir(moved), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(img, moved); err != nil {
+		t.Fatal(err)
+	}
+	thumb, err = OpenThumbs(thumbDir, DefaultThumbLimit, dec.decode).Get(moved, 160)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.calls != 1 {
+		t.Errorf("expected 1 decode across both runs, got %d", dec.calls)
+	}
+	if b := thumb.Bounds(); b.Dx() != 160 || b.Dy() != 80 {
+		t.Errorf("cached thumbnail is %dx%d", b.Dx(), b.Dy())
+	}
+
+	// Another size is a separate entry.
+	if _, err := OpenThumbs(thumbDir, DefaultThumbLimit, dec.decode).Get(moved, 64); err != nil {
+		t.Fatal(err)
+	}
+	if dec.calls != 2 {
+		t.Errorf("a new size should decode again, got %d decodes", dec.calls)
+	}
+}
+
+func TestThumbsKeepThenLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	writePNG(t, path, 400, 200, 1)
+	img, err := decodeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbDir := filepath.Join(dir, "thumbs")
+
+	// Classification keeps a thumbnail of the image it already decoded, so
+	// the next run loads it without decoding the file.
+	var dec countingDecoder
+	OpenThumbs(thumbDir, DefaultThumbLimit, dec.decode).Keep(path, img)
+	thumb, err := OpenThumbs(thumbDir, DefaultThumbLimit, dec.decode).Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.calls != 0 {
+		t.Errorf("expected no decodes, got %d", dec.calls)
+	}
+	if b := thumb.Bounds(); b.Dx() != ThumbSize || b.Dy() != ThumbSize/2 {
+		t.Errorf("expected a %dx%d thumbnail, got %dx%d", ThumbSize, ThumbSize/2, b.Dx(), b.Dy())
+	}
+}
+
+func TestThumbsEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.png"), filepath.Join(dir, "b.png")
+	writePNG(t, a, 300, 300, 1)
+	writePNG(t, b, 300, 300, 2)
+	thumbDir := filepath.Join(dir, "thumbs")
+
+	var dec countingDecoder
+	thumbs := OpenThumbs(thumbDir, 1, dec.decode)
+	if _, err := thumbs.Get(a, 100); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(thumbDir)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 cached thumbnail, got %d", len(entries))
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(thumbDir, entries[0].Name()), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := thumbs.Get(b, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := thumbs.Get(b, 100); err != nil {
+		t.Fatal(err)
+	}
+	if dec.calls != 2 {
+		t.Errorf("the newest thumbnail should stay cached, got %d decodes", dec.calls)
+	}
+	if _, err := os.Stat(filepath.Join(thumbDir, entries[0].Name())); !os.IsNotExist(err) {
+		t.Error("the least recently used thumbnail should be evicted")
+	}
+}
+
+func TestThumbsClear(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "a.png")
+	writePNG(t, img, 50, 50, 1)
+	thumbDir := filepath.Join(dir, "thumbs")
+
+	var dec countingDecoder
+	thumbs := OpenThumbs(thumbDir, DefaultThumbLimit, dec.decode)
+	if _, err := thumbs.Get(img, 160); err != nil {
+		t.Fatal(err)
+	}
+	if err := thumbs.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(thumbDir); !os.IsNotExist(err) {
+		t.Error("Clear should remove the thumbnail directory")
+	}
+	if _, err := thumbs.Get(img, 160); err != nil {
+		t.Fatal(err)
+	}
+	if dec.calls != 2 {
+		t.Errorf("expected a decode after Clear, got %d decodes", dec.calls)
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// Kinds of data imgsort keeps on disk, as reported by Locations.
+const (
+	KindModels = "models"
+	KindScores = "scores"
+	KindThumbs = "thumbnails"
+)
+
+// Location is one kind of data imgsort keeps on disk, with how much space
+// it takes. Size and Files are zero when nothing is there yet.
+type Location struct {
+	Kind  string
+	Path  string
+	Size  int64
+	Files int
+}
+
+// Locations returns where the models, the scores cache, and the thumbnail
+// cache live and how large each is.
+func Locations() ([]Location, error) {
+	models, err := model.ModelsDir()
+	if err != nil {
+		return nil, err
+	}
+	scores, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	thumbs, err := ThumbDir()
+	if err != nil {
+		return nil, err
+	}
+
+	locs := []Location{
+		{Kind: KindModels, Path: models},
+		{Kind: KindScores, Path: scores},
+		{Kind: KindThumbs, Path: thumbs},
+	}
+	for i := range locs {
+		if locs[i].Size, locs[i].Files, err = measure(locs[i].Path); err != nil {
+			return nil, err
+		}
+	}
+	return locs, nil
+}
+
+// measure returns the total size of the regular files at or under path and
+// how many there are. Symlinks are not followed, and a missing path
+// measures zero.
+func measure(path string) (int64, int, error) {
+	var size int64
+	var files int
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		files++
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, nil
+	}
+	return size, files, err
+}
+
+// Clear deletes the data at loc. For models, only the files imgsort
+// downloads and their hash list are removed, since the models directory
+// may have been chosen by the user; the directory itself goes only if that
+// leaves it empty.
+func Clear(loc Location) error {
+	switch loc.Kind {
+	case KindModels:
+		names := []string{model.LockFile}
+		for _, f := range model.RequiredFiles {
+			names = append(names, f.Name, f.Name+".tmp")
+		}
+		for _, name := range names {
+			if err := os.Remove(filepath.Join(loc.Path, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		// Fails harmlessly if anything else is in there.
+		os.Remove(loc.Path)
+		return nil
+	case KindScores:
+		for _, p := range []string{loc.Path, loc.Path + ".tmp"} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	case KindThumbs:
+		return os.RemoveAll(loc.Path)
+	}
+	return fmt.Errorf("unknown cache %q", loc.Kind)
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// populate writes size bytes to path under home, creating its directory.
+func populate(t *testing.T, home, path string, size int) {
+	t.Helper()
+	full := filepath.Join(home, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocationsAndClear(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(appdir.Env, home)
+	populate(t, home, "models/model.onnx", 1000)
+	populate(t, home, "models/vocab.json", 200)
+	populate(t, home, "models/"+model.LockFile, 50)
+	populate(t, home, "models/README", 10)
+	populate(t, home, "cache/scores.json", 300)
+	populate(t, home, "cache/thumbs/ab/1.jpg", 40)
+	populate(t, home, "cache/thumbs/cd/2.jpg", 60)
+	if err := os.Symlink(filepath.Join(home, "models", "model.onnx"), filepath.Join(home, "cache", "thumbs", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	locs, err := Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Location{
+		{Kind: KindModels, Path: filepath.Join(home, "models"), Size: 1260, Files: 4},
+		{Kind: KindScores, Path: filepath.Join(home, "cache", "scores.json"), Size: 300, Files: 1},
+		{Kind: KindThumbs, Path: filepath.Join(home, "cache", "thumbs"), Size: 100, Files: 2},
+	}
+	if len(locs) != len(want) {
+		t.Fatalf("got %+v, want %+v", locs, want)
+	}
+	for i := range want {
+		if locs[i] != want[i] {
+			t.Errorf("got %+v, want %+v", locs[i], want[i])
+		}
+	}
+
+	for _, l := range locs {
+		if err := Clear(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The symlinked model survives clearing the thumbnails, and files
+	// imgsort did not download are left in the models directory.
+	if locs, err = Locations(); err != nil {
+		t.Fatal(err)
+	}
+	if locs[0].Files != 1 || locs[0].Size != 10 || locs[1].Files != 0 || locs[2].Files != 0 {
+		t.Errorf("unexpected locations after clearing: %+v", locs)
+	}
+	if _, err := os.Stat(filepath.Join(home, "cache", "thumbs")); !os.IsNotExist(err) {
+		t.Errorf("expected the thumbnail cache to be removed, got %v", err)
+	}
+}
+
+func TestLocationsEmptyHome(t *testing.T) {
+	t.Setenv(appdir.Env, t.TempDir())
+	locs, err := Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range locs {
+		if l.Size != 0 || l.Files != 0 {
+			t.Errorf("expected %s to be empty, got %+v", l.Kind, l)
+		}
+		if err := Clear(l); err != nil {
+			t.Errorf("clearing an empty %s: %v", l.Kind, err)
+		}
+	}
+}
+
+func TestLocationsFollowEnv(t *testing.T) {
+	t.Setenv(appdir.Env, t.TempDir())
+	models, caches := t.TempDir(), t.TempDir()
+	t.Setenv(appdir.ModelsEnv, models)
+	t.Setenv(appdir.CacheEnv, caches)
+
+	locs, err := Locations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		KindModels: models,
+		KindScores: filepath.Join(caches, "scores.json"),
+		KindThumbs: filepath.Join(caches, "thumbs"),
+	}
+	for _, l := range locs {
+		if l.Path != want[l.Kind] {
+			t.Errorf("%s is at %s, want %s", l.Kind, l.Path, want[l.Kind])
+		}
+	}
+}
@@ -4,82 +4,179 @@ package categories
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
 )
 
+// Group is a named set of related categories.
+type Group struct {
+	Name       string
+	Categories []string
+}
+
+// DefaultGroups is the built-in list of common photo categories, grouped by theme.
+var DefaultGroups = []Group{
+	{"People & Social", []string{
+		"people", "portrait", "selfie", "group photo", "baby", "wedding", "family",
+	}},
+	{"Animals", []string{
+		"dog", "cat", "bird", "wildlife", "pet", "fish", "insect",
+	}},
+	{"Nature & Landscapes", []string{
+		"landscape", "mountain", "forest", "ocean", "lake", "river", "waterfall",
+		"desert", "field", "garden", "park", "sunrise", "sunset", "sky", "clouds",
+	}},
+	{"Urban & Architecture", []string{
+		"city", "building", "skyscraper", "bridge", "street", "house", "church",
+		"castle", "monument", "ruins",
+	}},
+	{"Food & Drink", []string{
+		"food", "dessert", "coffee", "cocktail", "fruit", "meal",
+	}},
+	{"Travel & Transport", []string{
+		"car", "airplane", "boat", "train", "bicycle", "motorcycle", "road",
+		"airport", "harbor",
+	}},
+	{"Activities & Sports", []string{
+		"sports", "hiking", "swimming", "skiing", "concert", "festival", "party",
+	}},
+	{"Art & Creative", []string{
+		"art", "painting", "sculpture", "graffiti", "illustration", "calligraphy",
+	}},
+	{"Indoor & Objects", []string{
+		"indoor", "furniture", "electronics", "book", "toy", "instrument",
+		"clothing", "jewelry",
+	}},
+	{"Documents & Screenshots", []string{
+		"document", "screenshot", "whiteboard", "diagram", "chart", "map", "sign",
+		"receipt", "menu",
+	}},
+	{"Miscellaneous", []string{
+		"flower", "tree", "night", "fireworks", "snow", "rain", "fog", "abstract",
+		"pattern", "texture", "macro", "aerial",
+	}},
+}
+
 // DefaultCategories is the built-in list of common photo categories.
-var DefaultCategories = []string{
-	// People & Social
-	"people", "portrait", "selfie", "group photo", "baby", "wedding", "family",
-	// Animals
-	"dog", "cat", "bird", "wildlife", "pet", "fish", "insect",
-	// Nature & Landscapes
-	"landscape", "mountain", "forest", "ocean", "lake", "river", "waterfall",
-	"desert", "field", "garden", "park", "sunrise", "sunset", "sky", "clouds",
-	// Urban & Architecture
-	"city", "building", "skyscraper", "bridge", "street", "house", "church",
-	"castle", "monument", "ruins",
-	// Food & Drink
-	"food", "dessert", "coffee", "cocktail", "fruit", "meal",
-	// Travel & Transport
-	"car", "airplane", "boat", "train", "bicycle", "motorcycle", "road",
-	"airport", "harbor",
-	// Activities & Sports
-	"sports", "hiking", "swimming", "skiing", "concert", "festival", "party",
-	// Art & Creative
-	"art", "painting", "sculpture", "graffiti", "illustration", "calligraphy",
-	// Indoor & Objects
-	"indoor", "furniture", "electronics", "book", "toy", "instrument",
-	"clothing", "jewelry",
-	// Documents & Screenshots
-	"document", "screenshot", "whiteboard", "diagram", "chart", "map", "sign",
-	"receipt", "menu",
-	// Miscellaneous
-	"flower", "tree", "night", "fireworks", "snow", "rain", "fog",
-	"abstract", "pattern", "texture", "macro", "aerial",
+var DefaultCategories = flatten(DefaultGroups)
+
+// flatten returns the categories of all groups in order.
+func flatten(groups []Group) []string {
+	var cats []string
+	for _, g := range groups {
+		cats = append(cats, g.Categories...)
+	}
+	return cats
 }
 
 // configPath returns the path to the user's custom categories file.
 func configPath() (string, error) {
-	home, err := os.UserHomeDir()
+	dir, err := appdir.Dir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".imgsort", "categories.txt"), nil
+	return filepath.Join(dir, "categories.txt"), nil
 }
 
-// LoadCustomCategories reads categories from ~/.imgsort/categories.txt.
-// Returns nil if the file does not exist.
-func LoadCustomCategories() ([]string, error) {
-	path, err := configPath()
+// customFiles are the names the custom categories file may have in
+// ~/.imgsort, in order of preference.
+var customFiles = []string{"categories.json", "categories.yaml", "categories.yml", "categories.txt"}
+
+// CustomFile returns the path of the user's custom categories file, or ""
+// if there is none.
+func CustomFile() (string, error) {
+	dir, err := appdir.Dir()
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	for _, name := range customFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return path, nil
+		}
 	}
+	return "", nil
+}
 
-	f, err := os.Open(path)
-	if os.IsNotExist(err) {
-		return nil, nil
+// LoadCustom reads the user's custom categories from ~/.imgsort,
+// preferring categories.json over categories.txt. Returns nil if there is
+// no custom file.
+func LoadCustom() ([]Category, error) {
+	path, err := CustomFile()
+	if err != nil || path == "" {
+		return nil, err
 	}
-	if err != nil {
-		return nil, fmt.Errorf("cannot open categories file: %w", err)
+	return LoadFile(path)
+}
+
+// LoadCustomCategories returns the names of the user's custom categories.
+// Returns nil if there is no custom file.
+func LoadCustomCategories() ([]string, error) {
+	cats, err := LoadCustom()
+	if err != nil || len(cats) == 0 {
+		return nil, err
 	}
-	defer f.Close()
+	return Names(cats), nil
+}
 
-	var categories []string
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
+// textFields names the fields of a categories.txt line, in order.
+var textFields = []string{"label", "prompt", "weight", "threshold"}
+
+// ParseText reads the categories.txt format, one category per line:
+//
+//	label | prompt | weight | threshold
+//
+// Fields are separated by "|" and trailing ones may be left off, so a line
+// holding only a label still works. An empty prompt keeps the default
+// "a photo of {label}"; an empty weight or threshold keeps the run's
+// setting. Blank lines and lines starting with # are ignored.
+func ParseText(r io.Reader) ([]Category, error) {
+	var cats []Category
+	var lines []int
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
 		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			categories = append(categories, line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) > len(textFields) {
+			return nil, fmt.Errorf("line %d: expected at most %d fields (%s), got %d",
+				n, len(textFields), strings.Join(textFields, " | "), len(fields))
 		}
+		c := Category{Name: strings.TrimSpace(fields[0])}
+		for i, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			switch textFields[i+1] {
+			case "prompt":
+				c.Prompt = f
+			case "weight", "threshold":
+				v, err := strconv.ParseFloat(f, 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s: %q is not a number", n, textFields[i+1], f)
+				}
+				if textFields[i+1] == "weight" {
+					c.Weight = v
+				} else {
+					c.Threshold = v
+				}
+			}
+		}
+		cats = append(cats, c)
+		lines = append(lines, n)
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading categories file: %w", err)
+		return nil, err
 	}
-
-	return categories, nil
+	return cats, checkSpec(cats, lines)
 }
 
 // Resolve returns the final list of categories to use for classification.
@@ -88,7 +185,56 @@ func Resolve(cliCategories []string) ([]string, error) {
 	if len(cliCategories) > 0 {
 		return cliCategories, nil
 	}
+	return baseCategories()
+}
+
+// ResolveRestricted is like Resolve, but cliCategories narrow the custom or
+// default list instead of replacing it. The result keeps the base list's
+// order. Naming a category that is not in the base list is an error, so a
+// typo cannot silently drop it.
+func ResolveRestricted(cliCategories []string) ([]string, error) {
+	base, err := baseCategories()
+	if err != nil {
+		return nil, err
+	}
+	if len(cliCategories) == 0 {
+		return base, nil
+	}
+	return Intersect(base, cliCategories)
+}
+
+// Intersect returns the categories in base that are also in allow, in base
+// order. It returns an error listing any allowed categories missing from base.
+func Intersect(base, allow []string) ([]string, error) {
+	inBase := make(map[string]bool, len(base))
+	for _, c := range base {
+		inBase[c] = true
+	}
+	allowed := make(map[string]bool, len(allow))
+	var unknown []string
+	for _, c := range allow {
+		allowed[c] = true
+		if !inBase[c] {
+			unknown = append(unknown, c)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("cannot restrict to categories that are not in the current list: %s",
+			strings.Join(unknown, ", "))
+	}
 
+	var out []string
+	for _, c := range base {
+		if allowed[c] {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// baseCategories returns the custom categories if the file has any, and
+// the defaults otherwise.
+func baseCategories() ([]string, error) {
 	custom, err := LoadCustomCategories()
 	if err != nil {
 		return nil, err
@@ -96,6 +242,52 @@ func Resolve(cliCategories []string) ([]string, error) {
 	if len(custom) > 0 {
 		return custom, nil
 	}
-
 	return DefaultCategories, nil
 }
+
+// WriteGroups writes groups in the categories.txt format: one category per
+// line, with each group introduced by a comment header.
+func WriteGroups(w io.Writer, groups []Group) error {
+	bw := bufio.NewWriter(w)
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "# %s\n", g.Name)
+		for _, c := range g.Categories {
+			fmt.Fprintln(bw, c)
+		}
+	}
+	return bw.Flush()
+}
+
+// ExportDefaults writes the built-in categories to path, or to
+// ~/.imgsort/categories.txt when path is empty, and returns the path written.
+// An existing file is only replaced when force is true.
+func ExportDefaults(path string, force bool) (string, error) {
+	if path == "" {
+		p, err := configPath()
+		if err != nil {
+			return "", err
+		}
+		path = p
+	}
+
+	if _, err := os.Stat(path); err == nil && !force {
+		return "", fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("cannot create directory: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot create categories file: %w", err)
+	}
+	defer f.Close()
+
+	if err := WriteGroups(f, DefaultGroups); err != nil {
+		return "", fmt.Errorf("cannot write categories file: %w", err)
+	}
+	return path, f.Close()
+}
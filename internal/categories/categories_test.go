@@ -3,6 +3,8 @@ package categories
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -30,6 +32,57 @@ func TestResolveDefaults(t *testing.T) {
 	}
 }
 
+func TestResolveRestricted(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	dir := filepath.Join(tmpHome, ".imgsort")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "categories.txt"), []byte("nature\nanimals\nfood\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Override semantics: the CLI list replaces the custom list entirely.
+	override, err := Resolve([]string{"food", "cars"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(override, []string{"food", "cars"}) {
+		t.Errorf("Resolve should return the CLI list as is, got %v", override)
+	}
+
+	// Restrict semantics: the CLI list narrows the custom list, in its order.
+	restricted, err := ResolveRestricted([]string{"food", "nature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(restricted, []string{"nature", "food"}) {
+		t.Errorf("expected [nature food] in custom-file order, got %v", restricted)
+	}
+
+	if _, err := ResolveRestricted([]string{"food", "cars"}); err == nil || !strings.Contains(err.Error(), "cars") {
+		t.Errorf("expected error naming the unknown category, got %v", err)
+	}
+
+	all, err := ResolveRestricted(nil)
+	if err != nil || len(all) != 3 {
+		t.Errorf("no restriction should return the whole custom list, got %v, %v", all, err)
+	}
+}
+
+func TestResolveRestrictedDefaults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	restricted, err := ResolveRestricted([]string{DefaultCategories[2], DefaultCategories[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(restricted, []string{DefaultCategories[0], DefaultCategories[2]}) {
+		t.Errorf("expected defaults narrowed in default order, got %v", restricted)
+	}
+}
+
 func TestLoadCustomCategories(t *testing.T) {
 	tmpHome := t.TempDir()
 	t.Setenv("HOME", tmpHome)
@@ -77,3 +130,50 @@ func TestDefaultCategoriesNotEmpty(t *testing.T) {
 		t.Errorf("expected at least 50 default categories, got %d", len(DefaultCategories))
 	}
 }
+
+func TestExportDefaultsRoundTrip(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	path, err := ExportDefaults("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(tmpHome, ".imgsort", "categories.txt") {
+		t.Errorf("unexpected export path: %s", path)
+	}
+
+	cats, err := LoadCustomCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cats) != len(DefaultCategories) {
+		t.Fatalf("expected %d categories, got %d", len(DefaultCategories), len(cats))
+	}
+	for i, c := range DefaultCategories {
+		if cats[i] != c {
+			t.Errorf("category %d: expected %q, got %q", i, c, cats[i])
+		}
+	}
+}
+
+func TestExportDefaultsForce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "categories.txt")
+	if err := os.WriteFile(path, []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExportDefaults(path, false); err == nil {
+		t.Error("expected error when file exists without force")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "mine\n" {
+		t.Error("existing file should be untouched without force")
+	}
+
+	if _, err := ExportDefaults(path, true); err != nil {
+		t.Fatalf("expected overwrite with force: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) == "mine\n" {
+		t.Error("existing file should be replaced with force")
+	}
+}
@@ -0,0 +1,98 @@
+package categories
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
+)
+
+// ExpansionsFile is the name of the user's prompt expansion file in
+// ~/.imgsort.
+const ExpansionsFile = "expansions.txt"
+
+// BuiltinExpansions lists related terms for default categories whose images
+// are often described in other words. Each term is scored as
+// "a photo of {term}" alongside the category's own prompt.
+var BuiltinExpansions = map[string][]string{
+	"dog":        {"puppy", "canine"},
+	"cat":        {"kitten", "feline"},
+	"car":        {"automobile", "vehicle on a road"},
+	"food":       {"a plate of food", "a dish"},
+	"ocean":      {"the sea", "waves on a beach"},
+	"baby":       {"an infant", "a newborn"},
+	"selfie":     {"a self-portrait taken with a phone"},
+	"screenshot": {"a phone screen", "a computer screen"},
+}
+
+// ParseExpansions reads the expansion file format: one category per line,
+// followed by a colon and a comma-separated list of terms. Blank lines and
+// lines starting with # are ignored.
+//
+//	dog: puppy, canine, a dog playing fetch
+func ParseExpansions(r io.Reader) (map[string][]string, error) {
+	out := make(map[string][]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cat, list, ok := strings.Cut(line, ":")
+		cat = strings.TrimSpace(cat)
+		if !ok || cat == "" {
+			return nil, fmt.Errorf("line %d: expected \"category: term, term\"", n)
+		}
+		for _, term := range strings.Split(list, ",") {
+			if term = strings.TrimSpace(term); term != "" {
+				out[cat] = append(out[cat], term)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LoadExpansions returns the expansions for cats: the built-in ones, with
+// any category listed in ~/.imgsort/expansions.txt taking its terms from
+// the file instead. Categories without expansions are omitted.
+func LoadExpansions(cats []string) (map[string][]string, error) {
+	all := make(map[string][]string, len(BuiltinExpansions))
+	for cat, terms := range BuiltinExpansions {
+		all[cat] = terms
+	}
+
+	dir, err := appdir.Dir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, ExpansionsFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("cannot open expansions file: %w", err)
+	default:
+		defer f.Close()
+		user, err := ParseExpansions(f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read expansions file: %w", err)
+		}
+		for cat, terms := range user {
+			all[cat] = terms
+		}
+	}
+
+	out := make(map[string][]string)
+	for _, cat := range cats {
+		if terms := all[cat]; len(terms) > 0 {
+			out[cat] = terms
+		}
+	}
+	return out, nil
+}
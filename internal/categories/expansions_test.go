@@ -0,0 +1,59 @@
+package categories
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseExpansions(t *testing.T) {
+	got, err := ParseExpansions(strings.NewReader("# pets\ndog: puppy, canine ,\n\ncat:kitten\nempty:\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"dog": {"puppy", "canine"}, "cat": {"kitten"}}
+	if !maps.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := ParseExpansions(strings.NewReader("dog: puppy\njust a category\n")); err == nil ||
+		!strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a line 2 error, got %v", err)
+	}
+}
+
+func TestLoadExpansions(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	got, err := LoadExpansions([]string{"dog", "sunset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got["dog"], BuiltinExpansions["dog"]) || len(got) != 1 {
+		t.Errorf("expected only the built-in dog terms, got %v", got)
+	}
+
+	dir := filepath.Join(tmpHome, ".imgsort")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ExpansionsFile), []byte("dog: hound\nsunset: dusk, golden hour\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadExpansions([]string{"dog", "sunset", "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"dog":    {"hound"},
+		"sunset": {"dusk", "golden hour"},
+		"cat":    BuiltinExpansions["cat"],
+	}
+	if !maps.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package categories
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// foreignWords are common photo subjects in Spanish, German, French,
+// Italian, Portuguese, and Dutch that are not also English words, so a
+// category named with one is very likely not in English.
+var foreignWords = map[string]bool{
+	// Spanish
+	"perro": true, "perros": true, "gato": true, "gatos": true, "playa": true,
+	"comida": true, "boda": true, "coche": true, "flores": true, "familia": true,
+	"nieve": true, "montanas": true, "paisaje": true, "cumpleanos": true,
+	// German
+	"hund": true, "hunde": true, "katze": true, "katzen": true, "berge": true,
+	"familie": true, "urlaub": true, "hochzeit": true, "schnee": true,
+	"blumen": true, "kinder": true, "landschaft": true, "geburtstag": true,
+	// French
+	"chien": true, "chiens": true, "plage": true, "montagne": true,
+	"voiture": true, "fleurs": true, "nourriture": true, "paysage": true,
+	"anniversaire": true, "vacances": true,
+	// Italian
+	"gatto": true, "spiaggia": true, "montagna": true,
+	"cibo": true, "matrimonio": true, "fiori": true, "compleanno": true,
+	// Portuguese
+	"cachorro": true, "praia": true, "casamento": true, "ferias": true,
+	// Dutch
+	"hond": true, "honden": true, "bloemen": true, "bruiloft": true,
+	"verjaardag": true, "vakantie": true,
+}
+
+// englishLoanwords are English words usually written with accents, which
+// should not be mistaken for another language.
+var englishLoanwords = map[string]bool{
+	"café": true, "cafés": true, "résumé": true, "jalapeño": true,
+	"jalapeños": true, "piñata": true, "naïve": true, "façade": true,
+	"décor": true, "fiancé": true, "fiancée": true, "entrée": true,
+	"soufflé": true, "crêpe": true, "crêpes": true, "rosé": true,
+	"crème": true, "brûlée": true, "mâché": true, "pokémon": true,
+}
+
+// LikelyNonEnglish reports whether a category name is probably not English:
+// it has a word with letters outside ASCII, such as an accented letter or
+// Cyrillic or CJK script, other than an English loanword like "café", or a
+// word from a small list of common non-English photo subjects.
+func LikelyNonEnglish(name string) bool {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '_'
+	})
+	for _, w := range fields {
+		if foreignWords[w] {
+			return true
+		}
+		if englishLoanwords[w] {
+			continue
+		}
+		for _, r := range w {
+			if r > unicode.MaxASCII && unicode.IsLetter(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks cats for problems that lower accuracy without making the
+// run fail, and returns a warning for each. prompts maps categories to the
+// custom prompts that replace their names, which are checked instead.
+// CLIP's text encoder is trained almost entirely on English, so categories
+// named in other languages match noticeably worse.
+func Validate(cats []string, prompts map[string]string) []string {
+	var foreign []string
+	for _, c := range cats {
+		text := c
+		if p, ok := prompts[c]; ok {
+			text = p
+		}
+		if LikelyNonEnglish(text) {
+			foreign = append(foreign, fmt.Sprintf("%q", c))
+		}
+	}
+	if len(foreign) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("categories %s do not look like English, which the model understands best. "+
+		"Use English names, or keep the name and give an English prompt for it in categories.json or categories.yaml",
+		strings.Join(foreign, ", "))}
+}
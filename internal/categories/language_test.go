@@ -0,0 +1,51 @@
+package categories
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLikelyNonEnglish(t *testing.T) {
+	for name, want := range map[string]bool{
+		"dog":            false,
+		"group photo":    false,
+		"fireworks":      false,
+		"café":           false,
+		"jalapeño_pizza": false,
+		"Crème Brûlée":   false,
+		"niños":          true,
+		"Straße":         true,
+		"plage":          true,
+		"mi-perro":       true,
+		"Hochzeit":       true,
+		"собаки":         true,
+		"猫":              true,
+		"お祭り":            true,
+		"日本 cat":         true,
+		"42":             false,
+		"2024 trip":      false,
+	} {
+		if got := LikelyNonEnglish(name); got != want {
+			t.Errorf("LikelyNonEnglish(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if w := Validate([]string{"dog", "beach", "café"}, nil); len(w) != 0 {
+		t.Errorf("expected no warnings for English categories, got %v", w)
+	}
+
+	w := Validate([]string{"dog", "perro", "собака", "gâteau"}, map[string]string{"gâteau": "a photo of a cake"})
+	if len(w) != 1 {
+		t.Fatalf("expected one warning, got %v", w)
+	}
+	if !strings.Contains(w[0], `"perro", "собака"`) || strings.Contains(w[0], "gâteau") || strings.Contains(w[0], `"dog"`) {
+		t.Errorf("expected the warning to name perro and собака only, got %q", w[0])
+	}
+
+	// A foreign prompt is flagged even when the name is English.
+	if w := Validate([]string{"dog"}, map[string]string{"dog": "una foto de un perro"}); len(w) != 1 {
+		t.Errorf("expected a non-English prompt to be flagged, got %v", w)
+	}
+}
@@ -0,0 +1,32 @@
+package categories
+
+// Profile is a self-contained classification setup: the categories to sort
+// into, the prompt used for each, and the defaults that suit them.
+type Profile struct {
+	Name       string
+	Categories []string
+
+	// Prompts maps each category to the text sent to the model.
+	Prompts map[string]string
+
+	// Threshold is the default minimum confidence for this profile.
+	Threshold float64
+
+	// NoBaseline disables the generic baseline prompt. Profiles whose
+	// categories cover every possible image do not need it.
+	NoBaseline bool
+}
+
+// TriageProfile is a fast three-way split into screenshots, memes, and photos.
+// The three classes are exhaustive, so the baseline is not used.
+var TriageProfile = Profile{
+	Name:       "triage",
+	Categories: []string{"screenshots", "memes", "photos"},
+	Prompts: map[string]string{
+		"screenshots": "a screenshot of a phone or computer screen showing an app or website",
+		"memes":       "a meme image with bold text overlaid on a picture",
+		"photos":      "a real photograph taken with a camera",
+	},
+	Threshold:  0.5,
+	NoBaseline: true,
+}
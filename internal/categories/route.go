@@ -0,0 +1,26 @@
+package categories
+
+// BornDigital lists categories that only fit images created on a screen.
+// A file with camera metadata cannot belong to them.
+var BornDigital = []string{"screenshot", "screenshots", "meme", "memes", "diagram", "chart"}
+
+// ForCameraPhoto returns cats without the BornDigital categories, for images
+// whose EXIF names a camera. If that would leave nothing, cats is returned
+// unchanged.
+func ForCameraPhoto(cats []string) []string {
+	digital := make(map[string]bool, len(BornDigital))
+	for _, c := range BornDigital {
+		digital[c] = true
+	}
+
+	var out []string
+	for _, c := range cats {
+		if !digital[c] {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return cats
+	}
+	return out
+}
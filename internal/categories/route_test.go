@@ -0,0 +1,23 @@
+package categories
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestForCameraPhoto(t *testing.T) {
+	got := ForCameraPhoto(TriageProfile.Categories)
+	if !slices.Equal(got, []string{"photos"}) {
+		t.Errorf("expected camera photos to route to photos only, got %v", got)
+	}
+
+	got = ForCameraPhoto([]string{"document", "screenshot", "receipt"})
+	if !slices.Equal(got, []string{"document", "receipt"}) {
+		t.Errorf("camera shots of documents should keep document categories, got %v", got)
+	}
+
+	only := []string{"screenshot"}
+	if got := ForCameraPhoto(only); !slices.Equal(got, only) {
+		t.Errorf("should not empty the list, got %v", got)
+	}
+}
@@ -0,0 +1,240 @@
+package categories
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Category is one entry of a categories.json or categories.yaml file. Only Name is required;
+// the text format fills in Prompt, Weight, and Threshold at most.
+type Category struct {
+	Name string `json:"name"`
+
+	// Folder is the folder images are sorted into, if not Name.
+	Folder string `json:"folder,omitempty"`
+
+	// Prompt replaces "a photo of {name}". Prompts gives several instead,
+	// whose scores are pooled; the first is the category's main prompt.
+	Prompt  string   `json:"prompt,omitempty"`
+	Prompts []string `json:"prompts,omitempty"`
+
+	// Threshold replaces the run's --confidence for this category.
+	Threshold float64 `json:"threshold,omitempty"`
+
+	// Weight multiplies the category's score before categories compete.
+	Weight float64 `json:"weight,omitempty"`
+
+	// Examples are paths of images that belong in the category.
+	Examples []string `json:"examples,omitempty"`
+
+	// Negative categories are scored but never sorted into, to draw away
+	// images that would otherwise land somewhere wrong.
+	Negative bool `json:"negative,omitempty"`
+}
+
+// AllPrompts returns the category's custom prompts, main prompt first, or
+// nil if it uses the default prompt.
+func (c Category) AllPrompts() []string {
+	if c.Prompt != "" {
+		return []string{c.Prompt}
+	}
+	return c.Prompts
+}
+
+// validate checks one entry on its own; duplicates are checked by the caller.
+func (c Category) validate() error {
+	if err := checkFolderName(c.Name); err != nil {
+		return fmt.Errorf("name: %w", err)
+	}
+	if c.Folder != "" {
+		if err := checkFolderName(c.Folder); err != nil {
+			return fmt.Errorf("folder: %w", err)
+		}
+	}
+	if c.Prompt != "" && len(c.Prompts) > 0 {
+		return errors.New("set prompt or prompts, not both")
+	}
+	for i, p := range c.Prompts {
+		if strings.TrimSpace(p) == "" {
+			return fmt.Errorf("prompts[%d]: prompt is empty", i)
+		}
+	}
+	if c.Threshold < 0 || c.Threshold > 1 {
+		return fmt.Errorf("threshold: %g is outside 0-1", c.Threshold)
+	}
+	if c.Weight < 0 {
+		return fmt.Errorf("weight: %g is negative", c.Weight)
+	}
+	for i, e := range c.Examples {
+		if strings.TrimSpace(e) == "" {
+			return fmt.Errorf("examples[%d]: path is empty", i)
+		}
+	}
+	return nil
+}
+
+// checkFolderName rejects names that cannot be a single folder.
+func checkFolderName(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return errors.New("is empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("%q is not a valid folder name", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("%q contains a path separator", name)
+	}
+	return nil
+}
+
+// ParseSpec reads a categories.json file: a list of category objects.
+// Errors name the line and field at fault.
+//
+//	[
+//	  {"name": "dog", "prompts": ["a photo of a dog", "a puppy"]},
+//	  {"name": "receipts", "threshold": 0.4},
+//	  {"name": "blurry", "negative": true}
+//	]
+func ParseSpec(r io.Reader) ([]Category, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if tok, err := dec.Token(); err != nil {
+		return nil, jsonError(data, 0, err)
+	} else if tok != json.Delim('[') {
+		return nil, fmt.Errorf("line 1: expected a list of categories")
+	}
+
+	var cats []Category
+	var lines []int
+	for dec.More() {
+		start := dec.InputOffset()
+		var c Category
+		if err := dec.Decode(&c); err != nil {
+			return nil, jsonError(data, start, err)
+		}
+		cats = append(cats, c)
+		lines = append(lines, lineAt(data, start))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, jsonError(data, dec.InputOffset(), err)
+	}
+	return cats, checkSpec(cats, lines)
+}
+
+// checkSpec validates parsed categories, where lines[i] is the line
+// category i starts on.
+func checkSpec(cats []Category, lines []int) error {
+	seen := make(map[string]int)
+	for i, c := range cats {
+		if err := c.validate(); err != nil {
+			if c.Name == "" {
+				return fmt.Errorf("line %d: category %d: %w", lines[i], i+1, err)
+			}
+			return fmt.Errorf("line %d: category %q: %w", lines[i], c.Name, err)
+		}
+		if prev, ok := seen[c.Name]; ok {
+			return fmt.Errorf("line %d: category %q is already defined on line %d", lines[i], c.Name, prev)
+		}
+		seen[c.Name] = lines[i]
+	}
+	return nil
+}
+
+// jsonError adds line and field context to a decoding error. start is the
+// offset of the value being decoded, for errors that carry no offset.
+func jsonError(data []byte, start int64, err error) error {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		// The offset is just past the offending byte.
+		return fmt.Errorf("line %d: %v", 1+bytes.Count(data[:min(syntax.Offset, int64(len(data)))], []byte("\n")), syntax)
+	case errors.As(err, &typ):
+		return fmt.Errorf("line %d: field %q: expected %s, got %s", lineAt(data, typ.Offset), typ.Field, typ.Type, typ.Value)
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("line %d: unexpected end of file", lineAt(data, int64(len(data))))
+	}
+	return fmt.Errorf("line %d: %s", lineAt(data, start), strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// lineAt returns the 1-based line of the first non-space byte at or after
+// offset.
+func lineAt(data []byte, offset int64) int {
+	offset = min(offset, int64(len(data)))
+	for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n,", rune(data[offset])) {
+		offset++
+	}
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
+
+// WriteSpec writes cats in the categories.json format.
+func WriteSpec(w io.Writer, cats []Category) error {
+	if cats == nil {
+		cats = []Category{}
+	}
+	data, err := json.MarshalIndent(cats, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// FromNames returns plain categories for names.
+func FromNames(names []string) []Category {
+	cats := make([]Category, len(names))
+	for i, n := range names {
+		cats[i] = Category{Name: n}
+	}
+	return cats
+}
+
+// Names returns the category names in order.
+func Names(cats []Category) []string {
+	names := make([]string, len(cats))
+	for i, c := range cats {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// LoadFile reads a category file, choosing the format by extension: .json
+// or .yaml for the full format, anything else for the text format read by
+// ParseText.
+func LoadFile(path string) ([]Category, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open categories file: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		cats, err := ParseSpec(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return cats, nil
+	case ".yaml", ".yml":
+		cats, err := ParseSpecYAML(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return cats, nil
+	}
+	cats, err := ParseText(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cats, nil
+}
@@ -0,0 +1,276 @@
+package categories
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// specFixture sets every field at least once.
+var specFixture = []Category{
+	{Name: "dog"},
+	{Name: "cat", Folder: "cats", Prompt: "a photo of a cat"},
+	{Name: "receipts", Prompts: []string{"a photo of a receipt", "a printed till slip"}},
+	{Name: "sunset", Threshold: 0.4, Weight: 1.5},
+	{Name: "family", Examples: []string{"ref/family1.jpg", "ref/family2.jpg"}},
+	{Name: "blurry", Negative: true},
+	{Name: "notes", Prompt: "a note: handwritten"},
+	{Name: "shots", Prompt: "shot #1"},
+}
+
+func TestSpecRoundTrip(t *testing.T) {
+	formats := []struct {
+		name  string
+		write func(io.Writer, []Category) error
+		parse func(io.Reader) ([]Category, error)
+	}{
+		{"json", WriteSpec, ParseSpec},
+		{"yaml", WriteSpecYAML, ParseSpecYAML},
+	}
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := f.write(&buf, specFixture); err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.parse(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, specFixture) {
+				t.Errorf("round trip changed the categories:\nwant %+v\ngot  %+v", specFixture, got)
+			}
+		})
+	}
+}
+
+func TestParseSpecYAMLFields(t *testing.T) {
+	in := `# pets
+- name: dog
+  folder: dogs   # plural folder
+  prompts:
+    - a dog
+    - "a puppy"
+  threshold: 0.3
+  weight: 2
+  examples: [a.jpg, 'b.jpg']
+  negative: true
+- name: cat
+  prompt: "shot #1"  # quoted, so the first # is not a comment
+`
+	got, err := ParseSpecYAML(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Category{{
+		Name: "dog", Folder: "dogs", Prompts: []string{"a dog", "a puppy"}, Threshold: 0.3,
+		Weight: 2, Examples: []string{"a.jpg", "b.jpg"}, Negative: true,
+	}, {Name: "cat", Prompt: "shot #1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseSpecYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"top-level key", "name: dog\n", "line 1: expected a \"- name: ...\" entry"},
+		{"no colon", "- name: dog\n  negative\n", `line 2: expected "key: value"`},
+		{"unknown field", "- name: dog\n  colour: brown\n", `line 2: unknown field "colour"`},
+		{"repeated field", "- name: dog\n  prompt: a\n  prompt: b\n", `line 3: field "prompt" is set more than once`},
+		{"bad number", "- name: dog\n  threshold: high\n", `line 2: field "threshold": expected a number, got "high"`},
+		{"bad bool", "- name: dog\n  negative: maybe\n", `line 2: field "negative": expected true or false`},
+		{"no value", "- name: dog\n  folder:\n", `line 2: field "folder" has no value`},
+		{"stray item", "- name: dog\n  folder: dogs\n    - x\n", "line 3: list item without a key"},
+		{"validation", "- name: dog\n- name: cat\n  threshold: 2\n", `line 2: category "cat": threshold: 2 is outside 0-1`},
+		{"duplicate", "- name: dog\n- name: dog\n", `line 2: category "dog" is already defined on line 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpecYAML(strings.NewReader(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseSpecFields(t *testing.T) {
+	in := `[
+  {"name": "dog", "folder": "dogs", "prompts": ["a dog", "a puppy"], "threshold": 0.3,
+   "weight": 2, "examples": ["a.jpg"], "negative": true}
+]`
+	got, err := ParseSpec(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Category{{
+		Name: "dog", Folder: "dogs", Prompts: []string{"a dog", "a puppy"}, Threshold: 0.3,
+		Weight: 2, Examples: []string{"a.jpg"}, Negative: true,
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if p := got[0].AllPrompts(); !slices.Equal(p, []string{"a dog", "a puppy"}) {
+		t.Errorf("unexpected prompts %v", p)
+	}
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"not a list", `{"name": "dog"}`, "line 1: expected a list of categories"},
+		{"syntax", "[\n  {\"name\": \"dog\",}\n]", "line 2: invalid character"},
+		{"truncated", "[\n  {\"name\": \"dog\"}", "line 2: unexpected end"},
+		{"missing name", "[\n  {\"name\": \"dog\"},\n  {\"prompt\": \"a cat\"}\n]", `line 3: category 2: name: is empty`},
+		{"duplicate", "[\n  {\"name\": \"dog\"},\n  {\"name\": \"dog\"}\n]", `line 3: category "dog" is already defined on line 2`},
+		{"unknown field", "[\n  {\"name\": \"dog\",\n   \"colour\": \"brown\"}\n]", `line 2: unknown field "colour"`},
+		{"wrong type", "[\n  {\"name\": \"dog\",\n   \"threshold\": \"high\"}\n]", `line 3: field "threshold": expected float64, got string`},
+		{"name separator", `[{"name": "a/b"}]`, `category "a/b": name: "a/b" contains a path separator`},
+		{"folder dots", `[{"name": "dog", "folder": ".."}]`, `category "dog": folder: ".." is not a valid folder name`},
+		{"prompt and prompts", `[{"name": "dog", "prompt": "a", "prompts": ["b"]}]`, "set prompt or prompts, not both"},
+		{"empty prompt", `[{"name": "dog", "prompts": ["a dog", " "]}]`, "prompts[1]: prompt is empty"},
+		{"threshold range", `[{"name": "dog", "threshold": 1.5}]`, "threshold: 1.5 is outside 0-1"},
+		{"negative weight", `[{"name": "dog", "weight": -1}]`, "weight: -1 is negative"},
+		{"empty example", `[{"name": "dog", "examples": [""]}]`, "examples[0]: path is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec(strings.NewReader(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadCustomPrefersJSON(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+	dir := filepath.Join(tmpHome, ".imgsort")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "categories.txt"), []byte("nature\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec := `[{"name": "dog", "threshold": 0.3}, {"name": "cat"}]`
+	if err := os.WriteFile(filepath.Join(dir, "categories.json"), []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cats, err := LoadCustom()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cats) != 2 || cats[0].Threshold != 0.3 {
+		t.Errorf("expected categories.json to be loaded, got %+v", cats)
+	}
+	resolved, err := Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(resolved, []string{"dog", "cat"}) {
+		t.Errorf("Resolve should use categories.json, got %v", resolved)
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	dir := t.TempDir()
+
+	txt := filepath.Join(dir, "categories.txt")
+	if err := os.WriteFile(txt, []byte("# Animals\ndog\ncat\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cats, err := LoadFile(txt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cats, []Category{{Name: "dog"}, {Name: "cat"}}) {
+		t.Errorf("unexpected categories from text file: %+v", cats)
+	}
+
+	yaml := filepath.Join(dir, "categories.yaml")
+	if err := os.WriteFile(yaml, []byte("- name: dog\n  negative: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cats, err = LoadFile(yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cats, []Category{{Name: "dog", Negative: true}}) {
+		t.Errorf("unexpected categories from YAML file: %+v", cats)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`[{"name": ""}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(bad); err == nil || !strings.HasPrefix(err.Error(), bad+": line 1") {
+		t.Errorf("expected error naming the file and line, got %v", err)
+	}
+
+	badTxt := filepath.Join(dir, "bad.txt")
+	if err := os.WriteFile(badTxt, []byte("dog\ncat | | x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(badTxt); err == nil || !strings.HasPrefix(err.Error(), badTxt+": line 2") {
+		t.Errorf("expected error naming the text file and line, got %v", err)
+	}
+}
+
+func TestParseTextFields(t *testing.T) {
+	input := `# label | prompt | weight | threshold
+dog
+cat | a photo of a house cat
+sunset | | 1.2
+receipts | a printed till slip | | 0.4
+blurry|a blurry photo|0.5|0.9
+`
+	cats, err := ParseText(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Category{
+		{Name: "dog"},
+		{Name: "cat", Prompt: "a photo of a house cat"},
+		{Name: "sunset", Weight: 1.2},
+		{Name: "receipts", Prompt: "a printed till slip", Threshold: 0.4},
+		{Name: "blurry", Prompt: "a blurry photo", Weight: 0.5, Threshold: 0.9},
+	}
+	if !reflect.DeepEqual(cats, want) {
+		t.Errorf("ParseText() = %+v, want %+v", cats, want)
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"dog\ncat | a cat | 1 | 0.5 | extra\n", "line 2: expected at most 4 fields"},
+		{"\ndog | | heavy\n", `line 2: weight: "heavy" is not a number`},
+		{"dog | | | 50%\n", `line 1: threshold: "50%" is not a number`},
+		{"dog | | | 1.5\n", `line 1: category "dog": threshold: 1.5 is outside 0-1`},
+		{"dog | | -1\n", `line 1: category "dog": weight: -1 is negative`},
+		{"| a photo of nothing\n", "line 1: category 1: name:"},
+		{"dog\n# comment\ndog | a dog\n", `line 3: category "dog" is already defined on line 1`},
+	}
+	for _, tt := range tests {
+		_, err := ParseText(strings.NewReader(tt.input))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("ParseText(%q) error = %v, want prefix %q", tt.input, err, tt.want)
+		}
+	}
+}
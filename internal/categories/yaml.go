@@ -0,0 +1,202 @@
+package categories
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/yamlline"
+)
+
+// ParseSpecYAML reads a categories.yaml file in a small YAML subset: a list
+// of "- name: ..." entries whose other fields follow on indented
+// "key: value" lines. Lists are written inline ("[a, b]") or as "- item"
+// lines indented below their key, and "#" starts a comment.
+//
+//	# categories.yaml
+//	- name: dog
+//	  prompts:
+//	    - a photo of a dog
+//	    - a puppy
+//	- name: blurry
+//	  negative: true
+func ParseSpecYAML(r io.Reader) ([]Category, error) {
+	var cats []Category
+	var lines []int
+	var seen map[string]bool
+	entryIndent := -1
+	listKey := ""
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		raw := yamlline.StripComment(scanner.Text())
+		body := strings.TrimSpace(raw)
+		if body == "" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+		item, isItem := strings.CutPrefix(body, "- ")
+
+		var err error
+		switch {
+		case isItem && (entryIndent < 0 || indent == entryIndent):
+			entryIndent = indent
+			cats = append(cats, Category{})
+			lines = append(lines, lineNo)
+			seen = make(map[string]bool)
+			listKey, err = setYAMLField(&cats[len(cats)-1], strings.TrimSpace(item), seen)
+		case entryIndent < 0 || indent <= entryIndent:
+			err = fmt.Errorf("expected a \"- name: ...\" entry")
+		case isItem:
+			if listKey == "" {
+				err = fmt.Errorf("list item without a key")
+				break
+			}
+			appendYAMLList(&cats[len(cats)-1], listKey, yamlline.Unquote(strings.TrimSpace(item)))
+		default:
+			listKey, err = setYAMLField(&cats[len(cats)-1], body, seen)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cats, checkSpec(cats, lines)
+}
+
+// setYAMLField applies one "key: value" line to c. A list key with no value
+// starts a block list, and is returned so its items can follow.
+func setYAMLField(c *Category, line string, seen map[string]bool) (string, error) {
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", fmt.Errorf("expected \"key: value\"")
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if seen[key] {
+		return "", fmt.Errorf("field %q is set more than once", key)
+	}
+	seen[key] = true
+
+	isList := key == "prompts" || key == "examples"
+	if value == "" {
+		if isList {
+			return key, nil
+		}
+		return "", fmt.Errorf("field %q has no value", key)
+	}
+	if isList {
+		for _, item := range splitInlineList(value) {
+			appendYAMLList(c, key, item)
+		}
+		return "", nil
+	}
+
+	value = yamlline.Unquote(value)
+	switch key {
+	case "name":
+		c.Name = value
+	case "folder":
+		c.Folder = value
+	case "prompt":
+		c.Prompt = value
+	case "threshold", "weight":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("field %q: expected a number, got %q", key, value)
+		}
+		if key == "threshold" {
+			c.Threshold = f
+		} else {
+			c.Weight = f
+		}
+	case "negative":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("field %q: expected true or false, got %q", key, value)
+		}
+		c.Negative = b
+	default:
+		return "", fmt.Errorf("unknown field %q", key)
+	}
+	return "", nil
+}
+
+// appendYAMLList adds item to the list field key of c.
+func appendYAMLList(c *Category, key, item string) {
+	switch key {
+	case "prompts":
+		c.Prompts = append(c.Prompts, item)
+	case "examples":
+		c.Examples = append(c.Examples, item)
+	}
+}
+
+// splitInlineList parses "[a, b]", or a single scalar as a one-item list.
+func splitInlineList(value string) []string {
+	inner, ok := strings.CutPrefix(value, "[")
+	if !ok {
+		return []string{yamlline.Unquote(value)}
+	}
+	inner = strings.TrimSuffix(inner, "]")
+	var items []string
+	for _, item := range strings.Split(inner, ",") {
+		if item = yamlline.Unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// WriteSpecYAML writes cats in the categories.yaml format.
+func WriteSpecYAML(w io.Writer, cats []Category) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range cats {
+		fmt.Fprintf(bw, "- name: %s\n", quoteYAML(c.Name))
+		field := func(key, value string) {
+			if value != "" {
+				fmt.Fprintf(bw, "  %s: %s\n", key, quoteYAML(value))
+			}
+		}
+		list := func(key string, items []string) {
+			if len(items) == 0 {
+				return
+			}
+			fmt.Fprintf(bw, "  %s:\n", key)
+			for _, item := range items {
+				fmt.Fprintf(bw, "    - %s\n", quoteYAML(item))
+			}
+		}
+		field("folder", c.Folder)
+		field("prompt", c.Prompt)
+		list("prompts", c.Prompts)
+		if c.Threshold != 0 {
+			field("threshold", strconv.FormatFloat(c.Threshold, 'g', -1, 64))
+		}
+		if c.Weight != 0 {
+			field("weight", strconv.FormatFloat(c.Weight, 'g', -1, 64))
+		}
+		list("examples", c.Examples)
+		if c.Negative {
+			field("negative", "true")
+		}
+	}
+	return bw.Flush()
+}
+
+// quoteYAML quotes s if it would otherwise be read back differently.
+func quoteYAML(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s[:1], `"'[-#`) ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		if strings.Contains(s, `"`) {
+			return "'" + s + "'"
+		}
+		return `"` + s + `"`
+	}
+	return s
+}
@@ -2,9 +2,11 @@
 package categorizer
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
+	"github.com/bagtoad/imgsort/internal/burst"
 	"github.com/bagtoad/imgsort/internal/model"
 )
 
@@ -14,82 +16,462 @@ type Result struct {
 	Category   string
 	Confidence float32
 	Skipped    bool
+
+	// RunnerUp is the second-best real category and its score. It is empty
+	// when fewer than two categories were scored.
+	RunnerUp           string
+	RunnerUpConfidence float32
+
+	// Group is the burst ID when the image was categorized together with
+	// the other members of a burst sequence.
+	Group string
+
+	// Pinned is true when Category came from a pin rather than the model;
+	// Confidence is then 1.
+	Pinned bool
+
+	// Overridden is true when Category came from the --overrides file;
+	// Confidence is then 1, though it was set by hand.
+	Overridden bool
+
+	// Tier is TierConfident or TierTentative when Options.TentativeThreshold
+	// is set, and empty otherwise.
+	Tier string
+
+	// Scores is the full score map from Classify, including the baseline.
+	// It is only set when Options.StoreScores is true.
+	Scores map[string]float32
+
+	// Attempts is how many times classification was tried; more than one
+	// means a transient failure was retried.
+	Attempts int
+
+	// SkipReason says why a skipped image was not categorized. BestCandidate
+	// and BestScore are the category that came closest, if any, and Error
+	// is the classification error for SkipError and SkipTimeout.
+	SkipReason    string
+	BestCandidate string
+	BestScore     float32
+	Error         string
+
+	// Rejected is SkipBaseline or SkipThreshold for an image assigned to
+	// Options.OpenSet instead of being skipped; BestCandidate and BestScore
+	// are then set as for a skipped image.
+	Rejected string
+}
+
+// Reasons an image is skipped, recorded in Result.SkipReason.
+const (
+	SkipError     = "error"
+	SkipTimeout   = "timeout"
+	SkipBaseline  = "baseline"
+	SkipThreshold = "below_threshold"
+	SkipNegative  = "negative"
+)
+
+// Confidence tiers, recorded in Result.Tier.
+const (
+	TierConfident = "confident"
+	TierTentative = "tentative"
+)
+
+// Classifier scores an image against a list of categories, returning a score
+// per category plus the baseline. model.CLIPSession and model.RemoteSession
+// implement it; tests use fakes.
+type Classifier interface {
+	ClassifyWithOptions(imagePath string, categories []string, opts model.ClassifyOptions) (map[string]float32, error)
+}
+
+// WithCategorySelector returns a Classifier that lets sel narrow the
+// categories for each image before calling inner, for example based on file
+// metadata. Scores only cover the selected categories.
+func WithCategorySelector(inner Classifier, sel func(path string, categories []string) []string) Classifier {
+	return selectingClassifier{inner: inner, sel: sel}
 }
 
+type selectingClassifier struct {
+	inner Classifier
+	sel   func(string, []string) []string
+}
+
+func (s selectingClassifier) ClassifyWithOptions(imagePath string, categories []string, opts model.ClassifyOptions) (map[string]float32, error) {
+	return s.inner.ClassifyWithOptions(imagePath, s.sel(imagePath, categories), opts)
+}
+
+// ImageScores holds the raw classification output for a single image.
+// Error is set (and Scores is nil) when the image could not be classified,
+// and TimedOut when that was because it hit a TimeoutClassifier's limit.
+// Pinned is set instead of Scores for an image whose category was decided
+// by hand; it takes precedence over any scores. Override is the same for
+// an image matched by the --overrides file, and takes precedence over
+// Pinned.
+type ImageScores struct {
+	Path     string             `json:"path"`
+	Scores   map[string]float32 `json:"scores,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	TimedOut bool               `json:"timed_out,omitempty"`
+	Attempts int                `json:"attempts,omitempty"`
+	Pinned   string             `json:"pinned,omitempty"`
+	Override string             `json:"override,omitempty"`
+}
+
+// ProgressFunc is called after each image is classified with the number of
+// images done so far, the total, and the image's scores. For the file being
+// started and how long each took, use a ProgressEventFunc.
+type ProgressFunc func(current, total int, done ImageScores)
+
 // Categorize classifies a list of images against the given categories using
-// the provided CLIP session. Images below the confidence threshold or where the
+// the provided classifier. Images below the confidence threshold or where the
 // baseline "uncategorized" prompt wins are skipped.
 func Categorize(
-	clip *model.CLIPSession,
+	clip Classifier,
 	imagePaths []string,
 	categories []string,
 	threshold float64,
-	progressFn func(current, total int),
+	progressFn ProgressFunc,
 ) ([]Result, error) {
+	scores, err := ClassifyAll(clip, imagePaths, categories, model.ClassifyOptions{}, progressFn)
+	if err != nil {
+		return nil, err
+	}
+	return Decide(scores, Options{Threshold: threshold}), nil
+}
+
+// ClassifyAll runs the classifier over every image and returns the raw score maps
+// without making any categorization decisions. Transient failures are retried
+// according to DefaultRetry; images that still fail are recorded with an
+// error rather than aborting the run.
+func ClassifyAll(
+	clip Classifier,
+	imagePaths []string,
+	categories []string,
+	classifyOpts model.ClassifyOptions,
+	progressFn ProgressFunc,
+) ([]ImageScores, error) {
+	return ClassifyAllEvents(clip, imagePaths, categories, classifyOpts, 1, progressFn.Events())
+}
+
+// ClassifyOne is ClassifyAll for a single image, for callers that handle
+// images as they are found instead of collecting a list first.
+func ClassifyOne(clip Classifier, imgPath string, categories []string, classifyOpts model.ClassifyOptions) (ImageScores, error) {
 	if len(categories) == 0 {
-		return nil, fmt.Errorf("no categories provided")
+		return ImageScores{}, fmt.Errorf("no categories provided")
 	}
+	return classifyOne(clip, imgPath, categories, classifyOpts), nil
+}
 
-	results := make([]Result, 0, len(imagePaths))
+// ClassifyAllParallel is like ClassifyAll but classifies up to workers
+// images at once, for classifiers that are safe for concurrent use such as
+// a remote server. Results keep the order of imagePaths; progressFn is
+// called as images complete.
+func ClassifyAllParallel(
+	clip Classifier,
+	imagePaths []string,
+	categories []string,
+	classifyOpts model.ClassifyOptions,
+	workers int,
+	progressFn ProgressFunc,
+) ([]ImageScores, error) {
+	return ClassifyAllEvents(clip, imagePaths, categories, classifyOpts, workers, progressFn.Events())
+}
 
-	for i, imgPath := range imagePaths {
-		if progressFn != nil {
-			progressFn(i+1, len(imagePaths))
-		}
+// classifyOne classifies a single image with retries, recording any final
+// error on the result.
+func classifyOne(clip Classifier, imgPath string, categories []string, classifyOpts model.ClassifyOptions) ImageScores {
+	scores, attempts, err := classifyWithRetry(clip, imgPath, categories, classifyOpts, DefaultRetry)
+	if err != nil {
+		return ImageScores{Path: imgPath, Error: err.Error(), TimedOut: errors.Is(err, ErrTimeout), Attempts: attempts}
+	}
+	return ImageScores{Path: imgPath, Scores: scores, Attempts: attempts}
+}
+
+// Options controls how raw scores are turned into results.
+type Options struct {
+	// Threshold is the minimum confidence for an image to be categorized.
+	Threshold float64
+
+	// TentativeThreshold, when set, categorizes images that fall below the
+	// threshold (or a category's own Rule.Threshold) but reach this score
+	// anyway, marking them TierTentative; images that reach the threshold
+	// are marked TierConfident. Zero skips everything below the threshold.
+	TentativeThreshold float64
+
+	// Pairwise scores each category only against the baseline prompt,
+	// p(cat) / (p(cat) + p(baseline)), instead of using its share of the
+	// softmax over all categories. Softmax shares shrink as categories are
+	// added, so the same image might score 40% with 6 categories but 8% with
+	// 90; pairwise scores do not depend on how many categories there are.
+	// The tradeoff is that pairwise scores ignore how close the other
+	// categories came, and any category that beats the baseline scores
+	// above 0.5, so thresholds must be set higher than in softmax mode.
+	Pairwise bool
+
+	// BaselineMargin is how far the baseline must beat the best category
+	// for an image to be skipped as uncategorized: it is skipped when
+	// baseline - best is at least the margin. Zero skips on a tie or worse,
+	// as before. A positive margin keeps close calls from flipping between
+	// runs, and a negative one also skips images the baseline nearly matched.
+	BaselineMargin float64
+
+	// StoreScores attaches each image's full score map to its Result. This
+	// keeps every score in memory for the whole run.
+	StoreScores bool
+
+	// Quiet suppresses the warning logged for each skipped image, for
+	// callers that decide the same scores again later.
+	Quiet bool
+
+	// Rules adjusts how individual categories are decided, keyed by
+	// category name. Categories without a rule use the defaults.
+	Rules map[string]Rule
+
+	// OpenSet, when set, names a catch-all category that receives the
+	// images the model rejects: those the baseline wins and those below
+	// the threshold. They are categorized there with Result.Rejected set
+	// instead of being skipped. Images that failed to classify, and those
+	// won by a negative category, are still skipped.
+	OpenSet string
+}
+
+// Rule overrides the decision settings for one category.
+type Rule struct {
+	// Threshold replaces Options.Threshold for images the category wins.
+	// Zero keeps the global threshold.
+	Threshold float64
+
+	// Weight multiplies the category's score before categories are
+	// compared, capped at 1. Zero leaves the score unchanged.
+	Weight float64
+
+	// Negative marks a category that is scored but never sorted into:
+	// images it wins are skipped, as if the baseline had won.
+	Negative bool
+}
 
-		scores, err := clip.Classify(imgPath, categories)
-		if err != nil {
-			log.Printf("Warning: skipping %s: %v", imgPath, err)
-			results = append(results, Result{Path: imgPath, Skipped: true})
+// Decide turns raw score maps into categorization results. Images below the
+// confidence threshold or where the baseline "uncategorized" prompt wins are
+// skipped.
+func Decide(all []ImageScores, opts Options) []Result {
+	results := make([]Result, 0, len(all))
+	for _, is := range all {
+		results = append(results, decide(is, opts))
+	}
+	return results
+}
+
+// DecideOne is Decide for a single image.
+func DecideOne(is ImageScores, opts Options) Result {
+	return decide(is, opts)
+}
+
+// DecideGroups is like Decide, but each burst group is decided once using
+// the average of its members' scores, and every member gets the same
+// category. Members that failed to classify, are pinned, or are overridden
+// are decided individually.
+func DecideGroups(all []ImageScores, groups []burst.Group, opts Options) []Result {
+	byPath := make(map[string]int, len(all))
+	for i, is := range all {
+		byPath[is.Path] = i
+	}
+
+	grouped := make(map[string]Result)
+	for _, g := range groups {
+		var members []ImageScores
+		for _, p := range g.Paths {
+			if i, ok := byPath[p]; ok && all[i].Error == "" && all[i].Pinned == "" && all[i].Override == "" {
+				members = append(members, all[i])
+			}
+		}
+		if len(members) < 2 {
 			continue
 		}
 
-		// Find the best real category (excluding the baseline)
-		bestCat := ""
-		bestScore := float32(0)
-		for cat, score := range scores {
-			if cat == model.BaselineCategory {
-				continue
-			}
-			if score > bestScore {
-				bestScore = score
-				bestCat = cat
+		r := decide(ImageScores{Path: g.ID, Scores: averageScores(members)}, opts)
+		r.Group = g.ID
+		for _, m := range members {
+			r.Path = m.Path
+			r.Attempts = m.Attempts
+			if opts.StoreScores {
+				r.Scores = m.Scores
 			}
+			grouped[m.Path] = r
 		}
+	}
 
-		// Skip if the baseline "uncategorized" prompt scored higher than the best real category
-		baselineScore := scores[model.BaselineCategory]
-		if baselineScore >= bestScore {
-			log.Printf("Warning: skipping %s (no category matched better than baseline; best was %q at %.1f%%)",
-				imgPath, bestCat, bestScore*100)
-			results = append(results, Result{Path: imgPath, Skipped: true})
+	results := make([]Result, 0, len(all))
+	for _, is := range all {
+		if r, ok := grouped[is.Path]; ok {
+			results = append(results, r)
 			continue
 		}
+		results = append(results, decide(is, opts))
+	}
+	return results
+}
 
-		if float64(bestScore) < threshold {
-			log.Printf("Warning: skipping %s (best match %q at %.1f%% confidence, below %.1f%% threshold)",
-				imgPath, bestCat, bestScore*100, threshold*100)
-			results = append(results, Result{Path: imgPath, Skipped: true})
-			continue
+// pairwiseScores rescales each category's softmax score against the baseline
+// alone: p(cat) / (p(cat) + p(baseline)). This equals the sigmoid of the
+// difference between the category and baseline logits. The baseline itself
+// scores 0.5, which is where a category and the baseline tie. Scores without
+// a baseline are returned unchanged.
+func pairwiseScores(scores map[string]float32) map[string]float32 {
+	base, ok := scores[model.BaselineCategory]
+	if !ok {
+		return scores
+	}
+	out := make(map[string]float32, len(scores))
+	for cat, score := range scores {
+		if score+base > 0 {
+			out[cat] = score / (score + base)
+		}
+	}
+	out[model.BaselineCategory] = 0.5
+	return out
+}
+
+// weightedScores multiplies each category's score by its rule's weight,
+// capping the result at 1.
+func weightedScores(scores map[string]float32, rules map[string]Rule) map[string]float32 {
+	out := make(map[string]float32, len(scores))
+	for cat, score := range scores {
+		if w := rules[cat].Weight; w > 0 {
+			score = min(score*float32(w), 1)
 		}
+		out[cat] = score
+	}
+	return out
+}
+
+// averageScores returns the per-category mean of the members' scores.
+func averageScores(members []ImageScores) map[string]float32 {
+	avg := make(map[string]float32)
+	for _, m := range members {
+		for cat, score := range m.Scores {
+			avg[cat] += score
+		}
+	}
+	for cat := range avg {
+		avg[cat] /= float32(len(members))
+	}
+	return avg
+}
 
-		results = append(results, Result{
-			Path:       imgPath,
-			Category:   bestCat,
-			Confidence: bestScore,
-		})
+// decide applies the categorization rules to a single image's scores.
+func decide(is ImageScores, opts Options) Result {
+	r := decideScores(is, opts)
+	r.Attempts = is.Attempts
+	if opts.StoreScores {
+		r.Scores = is.Scores
 	}
+	return r
+}
 
-	return results, nil
+// openSetResult assigns an image the model rejected for reason to the
+// open-set category.
+func openSetResult(imgPath, reason, bestCat string, bestScore float32, opts Options) Result {
+	return Result{Path: imgPath, Category: opts.OpenSet, Rejected: reason, BestCandidate: bestCat, BestScore: bestScore}
 }
 
-// GroupByCategory groups categorization results by category name.
-func GroupByCategory(results []Result) map[string][]Result {
-	groups := make(map[string][]Result)
-	for _, r := range results {
-		if !r.Skipped {
-			groups[r.Category] = append(groups[r.Category], r)
+// decideScores picks the category for one image, or marks it skipped.
+func decideScores(is ImageScores, opts Options) Result {
+	imgPath, scores := is.Path, is.Scores
+	tier := ""
+	if opts.TentativeThreshold > 0 {
+		tier = TierConfident
+	}
+	warnf := log.Printf
+	if opts.Quiet {
+		warnf = func(string, ...any) {}
+	}
+	if is.Override != "" {
+		// Overriding to a negative category is how to leave files alone.
+		if opts.Rules[is.Override].Negative {
+			warnf("Warning: skipping %s (overridden to negative category %q)", imgPath, is.Override)
+			return Result{Path: imgPath, Skipped: true, SkipReason: SkipNegative, Overridden: true, BestCandidate: is.Override}
+		}
+		return Result{Path: imgPath, Category: is.Override, Confidence: 1, Overridden: true, Tier: tier}
+	}
+	if is.Pinned != "" {
+		return Result{Path: imgPath, Category: is.Pinned, Confidence: 1, Pinned: true, Tier: tier}
+	}
+	if is.Error != "" {
+		warnf("Warning: skipping %s: %s", imgPath, is.Error)
+		reason := SkipError
+		if is.TimedOut {
+			reason = SkipTimeout
 		}
+		return Result{Path: imgPath, Skipped: true, SkipReason: reason, Error: is.Error}
+	}
+	if opts.Pairwise {
+		scores = pairwiseScores(scores)
+	}
+	if len(opts.Rules) > 0 {
+		scores = weightedScores(scores, opts.Rules)
+	}
+	threshold := opts.Threshold
+
+	// Find the best and second-best real categories (excluding the baseline
+	// and any that scored nothing). A negative category can win, but is
+	// never the runner-up.
+	bestCat, secondCat := "", ""
+	bestScore, secondScore := float32(0), float32(0)
+	for _, cs := range model.RankScores(scores) {
+		switch {
+		case cs.Baseline || cs.Score <= 0:
+		case bestCat == "":
+			bestCat, bestScore = cs.Category, cs.Score
+		case secondCat == "" && !opts.Rules[cs.Category].Negative:
+			secondCat, secondScore = cs.Category, cs.Score
+		}
+	}
+
+	// Skip if the baseline "uncategorized" prompt beat the best real
+	// category by at least the margin. Scores classified without a baseline
+	// have none and only skip images that scored nothing, whatever the margin.
+	baselineScore, hasBaseline := scores[model.BaselineCategory]
+	margin := opts.BaselineMargin
+	if !hasBaseline {
+		margin = 0
+	}
+	lead := float64(baselineScore) - float64(bestScore)
+	if bestCat == "" || lead >= margin {
+		if opts.OpenSet != "" {
+			return openSetResult(imgPath, SkipBaseline, bestCat, bestScore, opts)
+		}
+		warnf("Warning: skipping %s (no category matched better than baseline; best was %q at %.1f%%)",
+			imgPath, bestCat, bestScore*100)
+		return Result{Path: imgPath, Skipped: true, SkipReason: SkipBaseline, BestCandidate: bestCat, BestScore: bestScore}
+	}
+
+	if opts.Rules[bestCat].Negative {
+		warnf("Warning: skipping %s (best match %q is a negative category)", imgPath, bestCat)
+		return Result{Path: imgPath, Skipped: true, SkipReason: SkipNegative, BestCandidate: bestCat, BestScore: bestScore}
+	}
+	if t := opts.Rules[bestCat].Threshold; t > 0 {
+		threshold = t
+	}
+	if float64(bestScore) < threshold {
+		floor := threshold
+		if t := opts.TentativeThreshold; t > 0 {
+			floor = min(t, threshold)
+		}
+		if float64(bestScore) < floor {
+			if opts.OpenSet != "" {
+				return openSetResult(imgPath, SkipThreshold, bestCat, bestScore, opts)
+			}
+			warnf("Warning: skipping %s (best match %q at %.1f%% confidence, below %.1f%% threshold)",
+				imgPath, bestCat, bestScore*100, floor*100)
+			return Result{Path: imgPath, Skipped: true, SkipReason: SkipThreshold, BestCandidate: bestCat, BestScore: bestScore}
+		}
+		tier = TierTentative
+	}
+
+	return Result{
+		Path:               imgPath,
+		Category:           bestCat,
+		Confidence:         bestScore,
+		RunnerUp:           secondCat,
+		RunnerUpConfidence: secondScore,
+		Tier:               tier,
 	}
-	return groups
 }
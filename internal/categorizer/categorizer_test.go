@@ -0,0 +1,617 @@
+package categorizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/burst"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+func TestDecide(t *testing.T) {
+	all := []ImageScores{
+		{Path: "/imgs/beach.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.1, "beach": 0.6, "ocean": 0.25, "city": 0.05,
+		}},
+		{Path: "/imgs/blur.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.5, "beach": 0.3, "ocean": 0.1, "city": 0.1,
+		}},
+		{Path: "/imgs/weak.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.09, "beach": 0.1, "ocean": 0.4, "city": 0.41,
+		}},
+		{Path: "/imgs/broken.jpg", Error: "cannot decode image"},
+	}
+
+	results := Decide(all, Options{Threshold: 0.5})
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+
+	if r := results[0]; r.Skipped || r.Category != "beach" || r.RunnerUp != "ocean" {
+		t.Errorf("expected beach with runner-up ocean, got %+v", r)
+	}
+	if r := results[1]; !r.Skipped || r.SkipReason != SkipBaseline || r.BestCandidate != "beach" || r.BestScore != 0.3 {
+		t.Errorf("expected image where baseline wins to be skipped with beach as best candidate, got %+v", r)
+	}
+	if r := results[2]; !r.Skipped || r.SkipReason != SkipThreshold || r.BestCandidate != "city" {
+		t.Errorf("expected image below threshold to be skipped with city as best candidate, got %+v", r)
+	}
+	if r := results[3]; !r.Skipped || r.SkipReason != SkipError || r.Error != "cannot decode image" {
+		t.Errorf("expected image with classification error to be skipped with its error, got %+v", r)
+	}
+}
+
+func TestDecideGroups(t *testing.T) {
+	all := []ImageScores{
+		{Path: "/p/IMG_1.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.1, "beach": 0.5, "ocean": 0.4,
+		}},
+		{Path: "/p/IMG_2.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.1, "beach": 0.3, "ocean": 0.6,
+		}},
+		{Path: "/p/IMG_3.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.1, "beach": 0.3, "ocean": 0.6,
+		}},
+		{Path: "/p/other.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.1, "beach": 0.8, "ocean": 0.1,
+		}},
+	}
+	groups := []burst.Group{{ID: "IMG_1.jpg", Paths: []string{"/p/IMG_1.jpg", "/p/IMG_2.jpg", "/p/IMG_3.jpg"}}}
+
+	results := DecideGroups(all, groups, Options{Threshold: 0.15})
+	for _, r := range results[:3] {
+		if r.Category != "ocean" || r.Group != "IMG_1.jpg" {
+			t.Errorf("expected burst member to be ocean in group IMG_1.jpg, got %+v", r)
+		}
+	}
+	if results[0].Path != "/p/IMG_1.jpg" {
+		t.Errorf("expected member path to be preserved, got %s", results[0].Path)
+	}
+	if r := results[3]; r.Category != "beach" || r.Group != "" {
+		t.Errorf("expected ungrouped image to be decided alone, got %+v", r)
+	}
+}
+
+func TestDecidePinned(t *testing.T) {
+	all := []ImageScores{
+		// The model is confident this is a beach, but it was pinned to receipts.
+		{Path: "/p/IMG_1.jpg", Pinned: "receipts", Scores: map[string]float32{
+			model.BaselineCategory: 0.05, "beach": 0.9, "city": 0.05,
+		}},
+		{Path: "/p/IMG_2.jpg", Scores: map[string]float32{
+			model.BaselineCategory: 0.1, "beach": 0.3, "city": 0.6,
+		}},
+		{Path: "/p/IMG_3.jpg", Pinned: "beach"},
+	}
+	groups := []burst.Group{{ID: "IMG_1.jpg", Paths: []string{"/p/IMG_1.jpg", "/p/IMG_2.jpg", "/p/IMG_3.jpg"}}}
+
+	want := []struct {
+		category string
+		pinned   bool
+	}{{"receipts", true}, {"city", false}, {"beach", true}}
+	for _, results := range [][]Result{
+		Decide(all, Options{Threshold: 0.5}),
+		DecideGroups(all, groups, Options{Threshold: 0.15}),
+	} {
+		for i, r := range results {
+			if r.Skipped || r.Category != want[i].category || r.Pinned != want[i].pinned || r.Group != "" {
+				t.Errorf("%s: expected %s (pinned %v) decided alone, got %+v", r.Path, want[i].category, want[i].pinned, r)
+			}
+		}
+		if results[0].Confidence != 1 {
+			t.Errorf("expected a pinned image to have full confidence, got %v", results[0].Confidence)
+		}
+	}
+}
+
+func TestDecideOverride(t *testing.T) {
+	confident := map[string]float32{model.BaselineCategory: 0.05, "beach": 0.9, "city": 0.05}
+	opts := Options{Threshold: 0.5, Quiet: true, Rules: map[string]Rule{"blurry": {Negative: true}}}
+
+	// An override wins over both the scores and a pin.
+	r := DecideOne(ImageScores{Path: "/p/a.jpg", Override: "city", Pinned: "beach", Scores: confident}, opts)
+	if r.Skipped || r.Category != "city" || !r.Overridden || r.Pinned || r.Confidence != 1 {
+		t.Errorf("expected the override to decide, got %+v", r)
+	}
+
+	// Overriding to a negative category leaves the image in place.
+	r = DecideOne(ImageScores{Path: "/p/b.jpg", Override: "blurry", Scores: confident}, opts)
+	if !r.Skipped || r.SkipReason != SkipNegative || !r.Overridden || r.BestCandidate != "blurry" {
+		t.Errorf("expected an override to a negative category to skip, got %+v", r)
+	}
+
+	// Overridden burst members are decided alone.
+	all := []ImageScores{
+		{Path: "/p/IMG_1.jpg", Scores: confident},
+		{Path: "/p/IMG_2.jpg", Override: "city"},
+	}
+	groups := []burst.Group{{ID: "IMG_1.jpg", Paths: []string{"/p/IMG_1.jpg", "/p/IMG_2.jpg"}}}
+	results := DecideGroups(all, groups, opts)
+	if results[1].Category != "city" || !results[1].Overridden || results[1].Group != "" {
+		t.Errorf("expected the overridden member decided alone, got %+v", results[1])
+	}
+}
+
+func TestDecideTentative(t *testing.T) {
+	score := func(path, cat string, v float32) ImageScores {
+		return ImageScores{Path: path, Scores: map[string]float32{model.BaselineCategory: 0.01, cat: v}}
+	}
+	opts := Options{
+		Threshold:          0.4,
+		TentativeThreshold: 0.15,
+		Rules: map[string]Rule{
+			"beach": {Threshold: 0.6}, // raises the confident bar
+			"city":  {Threshold: 0.1}, // below the tentative bar
+		},
+	}
+	tests := []struct {
+		is      ImageScores
+		tier    string
+		skipped bool
+	}{
+		{score("at-threshold.jpg", "food", 0.4), TierConfident, false},
+		{score("just-below.jpg", "food", 0.3999), TierTentative, false},
+		{score("at-tentative.jpg", "food", 0.15), TierTentative, false},
+		{score("below-tentative.jpg", "food", 0.1499), "", true},
+		{score("beach-high.jpg", "beach", 0.6), TierConfident, false},
+		{score("beach-mid.jpg", "beach", 0.5), TierTentative, false},
+		{score("city-low.jpg", "city", 0.12), TierConfident, false},
+		{score("city-lower.jpg", "city", 0.09), "", true},
+		{ImageScores{Path: "pinned.jpg", Pinned: "food"}, TierConfident, false},
+	}
+	var all []ImageScores
+	for _, tt := range tests {
+		all = append(all, tt.is)
+	}
+	for i, r := range Decide(all, opts) {
+		if r.Tier != tests[i].tier || r.Skipped != tests[i].skipped {
+			t.Errorf("%s: expected tier %q skipped=%v, got %+v", r.Path, tests[i].tier, tests[i].skipped, r)
+		}
+	}
+
+	opts.TentativeThreshold = 0
+	for _, r := range Decide(all, opts) {
+		if r.Tier != "" {
+			t.Errorf("%s: expected no tier without a tentative threshold, got %q", r.Path, r.Tier)
+		}
+	}
+}
+
+func TestDecideOpenSet(t *testing.T) {
+	opts := Options{
+		Threshold: 0.3,
+		OpenSet:   "misc",
+		Quiet:     true,
+		Rules:     map[string]Rule{"blurry": {Negative: true}},
+	}
+	all := []ImageScores{
+		{Path: "beach.jpg", Scores: map[string]float32{model.BaselineCategory: 0.1, "beach": 0.8, "city": 0.1}},
+		{Path: "baseline.jpg", Scores: map[string]float32{model.BaselineCategory: 0.6, "beach": 0.3, "city": 0.1}},
+		{Path: "low.jpg", Scores: map[string]float32{model.BaselineCategory: 0.05, "beach": 0.25, "city": 0.2}},
+		{Path: "blurry.jpg", Scores: map[string]float32{model.BaselineCategory: 0.1, "blurry": 0.7, "beach": 0.2}},
+		{Path: "broken.jpg", Error: "cannot decode image: unexpected EOF"},
+	}
+	want := []struct {
+		category, rejected, skip string
+	}{
+		{"beach", "", ""},
+		{"misc", SkipBaseline, ""},
+		{"misc", SkipThreshold, ""},
+		{"", "", SkipNegative},
+		{"", "", SkipError},
+	}
+	for i, r := range Decide(all, opts) {
+		w := want[i]
+		if r.Category != w.category || r.Rejected != w.rejected || r.SkipReason != w.skip || r.Skipped != (w.skip != "") {
+			t.Errorf("%s: expected category %q rejected %q skip %q, got %+v", r.Path, w.category, w.rejected, w.skip, r)
+		}
+	}
+	if r := DecideOne(all[1], opts); r.BestCandidate != "beach" || r.BestScore != 0.3 {
+		t.Errorf("an open-set image should keep its closest category, got %+v", r)
+	}
+}
+
+// softmaxScores converts logits to a softmax score map, as Classify does.
+func softmaxScores(logits map[string]float64) map[string]float32 {
+	sum := 0.0
+	for _, l := range logits {
+		sum += math.Exp(l)
+	}
+	scores := make(map[string]float32, len(logits))
+	for cat, l := range logits {
+		scores[cat] = float32(math.Exp(l) / sum)
+	}
+	return scores
+}
+
+func TestDecidePairwiseStableAcrossCategoryCounts(t *testing.T) {
+	// The same image scored against 6 and against 90 categories: the
+	// landscape and baseline logits are identical, only the distractors differ.
+	small := map[string]float64{model.BaselineCategory: 20, "landscape": 23}
+	large := map[string]float64{model.BaselineCategory: 20, "landscape": 23}
+	for i := 0; i < 5; i++ {
+		small[fmt.Sprintf("other%d", i)] = 21
+	}
+	for i := 0; i < 89; i++ {
+		large[fmt.Sprintf("other%d", i)] = 21
+	}
+
+	decideOne := func(logits map[string]float64, opts Options) Result {
+		return Decide([]ImageScores{{Path: "/imgs/a.jpg", Scores: softmaxScores(logits)}}, opts)[0]
+	}
+
+	softSmall := decideOne(small, Options{})
+	softLarge := decideOne(large, Options{})
+	if softSmall.Category != "landscape" || softLarge.Category != "landscape" {
+		t.Fatalf("expected landscape to win, got %q and %q", softSmall.Category, softLarge.Category)
+	}
+	if softSmall.Confidence < 3*softLarge.Confidence {
+		t.Errorf("expected softmax confidence to drop sharply with more categories: %.3f vs %.3f",
+			softSmall.Confidence, softLarge.Confidence)
+	}
+
+	pairSmall := decideOne(small, Options{Pairwise: true})
+	pairLarge := decideOne(large, Options{Pairwise: true})
+	if diff := math.Abs(float64(pairSmall.Confidence - pairLarge.Confidence)); diff > 1e-4 {
+		t.Errorf("expected pairwise confidence to be stable: %.4f vs %.4f", pairSmall.Confidence, pairLarge.Confidence)
+	}
+	// sigmoid(23 - 20)
+	if want := 1 / (1 + math.Exp(-3)); math.Abs(float64(pairSmall.Confidence)-want) > 1e-4 {
+		t.Errorf("expected pairwise confidence %.4f, got %.4f", want, pairSmall.Confidence)
+	}
+}
+
+func TestDecidePairwiseBaselineWins(t *testing.T) {
+	scores := softmaxScores(map[string]float64{model.BaselineCategory: 22, "cat": 21})
+	r := Decide([]ImageScores{{Path: "/imgs/dark.png", Scores: scores}}, Options{Pairwise: true})[0]
+	if !r.Skipped {
+		t.Errorf("expected image to be skipped when the baseline beats every category, got %+v", r)
+	}
+}
+
+func TestDecideStoreScores(t *testing.T) {
+	classified := map[string]float32{model.BaselineCategory: 0.1, "beach": 0.6, "ocean": 0.3}
+	all := []ImageScores{
+		{Path: "/imgs/beach.jpg", Scores: classified},
+		{Path: "/imgs/broken.jpg", Error: "cannot decode image"},
+	}
+
+	results := Decide(all, Options{Threshold: 0.15, StoreScores: true, Pairwise: true})
+	if !reflect.DeepEqual(results[0].Scores, classified) {
+		t.Errorf("stored scores should match Classify's output:\nexpected %v\ngot      %v", classified, results[0].Scores)
+	}
+	if results[1].Scores != nil {
+		t.Errorf("expected no scores for an image that failed to classify, got %v", results[1].Scores)
+	}
+
+	if r := Decide(all, Options{Threshold: 0.15}); r[0].Scores != nil {
+		t.Error("scores should not be stored unless requested")
+	}
+}
+
+func TestDecideQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	all := []ImageScores{
+		{Path: "/imgs/blur.jpg", Scores: map[string]float32{model.BaselineCategory: 0.05, "beach": 0.1, "city": 0.05}},
+		{Path: "/imgs/broken.jpg", Error: "cannot decode image"},
+	}
+	r := Decide(all, Options{Threshold: 0.15, Quiet: true})
+	if !r[0].Skipped || !r[1].Skipped {
+		t.Fatalf("expected both images skipped, got %+v", r)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no warnings, got %q", buf.String())
+	}
+
+	Decide(all, Options{Threshold: 0.15})
+	if n := strings.Count(buf.String(), "Warning: skipping"); n != 2 {
+		t.Errorf("expected 2 warnings without Quiet, got %d: %q", n, buf.String())
+	}
+}
+
+func TestDecideRules(t *testing.T) {
+	scores := map[string]float32{model.BaselineCategory: 0.1, "beach": 0.4, "ocean": 0.3, "blurry": 0.2}
+	decideWith := func(rules map[string]Rule) Result {
+		return DecideOne(ImageScores{Path: "/imgs/a.jpg", Scores: scores}, Options{Threshold: 0.15, Quiet: true, Rules: rules})
+	}
+
+	if r := decideWith(map[string]Rule{"beach": {Threshold: 0.5}}); r.SkipReason != SkipThreshold || r.BestCandidate != "beach" {
+		t.Errorf("a category threshold should replace the global one, got %+v", r)
+	}
+	if r := decideWith(map[string]Rule{"ocean": {Threshold: 0.5}}); r.Category != "beach" {
+		t.Errorf("another category's threshold should not apply, got %+v", r)
+	}
+	if r := decideWith(map[string]Rule{"ocean": {Weight: 1.5}}); r.Category != "ocean" || r.Confidence < 0.449 || r.RunnerUp != "beach" {
+		t.Errorf("a weight should scale the category's score, got %+v", r)
+	}
+	if r := decideWith(map[string]Rule{"ocean": {Weight: 10}}); r.Confidence != 1 {
+		t.Errorf("weighted scores should be capped at 1, got %+v", r)
+	}
+	if r := decideWith(map[string]Rule{"blurry": {Negative: true, Weight: 3}}); r.SkipReason != SkipNegative || r.BestCandidate != "blurry" {
+		t.Errorf("an image won by a negative category should be skipped, got %+v", r)
+	}
+	if r := decideWith(map[string]Rule{"ocean": {Negative: true}}); r.Category != "beach" || r.RunnerUp != "blurry" {
+		t.Errorf("a negative category should not be the runner-up, got %+v", r)
+	}
+}
+
+func TestDecideBaselineMargin(t *testing.T) {
+	// Dyadic scores keep the differences exact in floating point.
+	decide := func(baseline, best float32, margin float64) Result {
+		scores := map[string]float32{model.BaselineCategory: baseline, "beach": best}
+		return DecideOne(ImageScores{Path: "a.jpg", Scores: scores}, Options{Threshold: 0.1, BaselineMargin: margin, Quiet: true})
+	}
+	tests := []struct {
+		name           string
+		baseline, best float32
+		margin         float64
+		skipped        bool
+	}{
+		{"default: baseline ahead", 0.5, 0.25, 0, true},
+		{"default: tie skips", 0.375, 0.375, 0, true},
+		{"default: best ahead", 0.25, 0.5, 0, false},
+		{"margin: ahead by less", 0.5, 0.375, 0.25, false},
+		{"margin: ahead by exactly the margin", 0.625, 0.375, 0.25, true},
+		{"margin: ahead by more", 0.75, 0.25, 0.25, true},
+		{"negative margin: best barely ahead", 0.25, 0.3125, -0.125, true},
+		{"negative margin: best ahead by exactly the margin", 0.25, 0.375, -0.125, true},
+		{"negative margin: best ahead by more", 0.25, 0.4375, -0.125, false},
+		{"negative margin: best well ahead", 0.25, 0.5, -0.125, false},
+	}
+	for _, tt := range tests {
+		r := decide(tt.baseline, tt.best, tt.margin)
+		if tt.skipped && r.SkipReason != SkipBaseline {
+			t.Errorf("%s: expected a baseline skip, got %+v", tt.name, r)
+		}
+		if !tt.skipped && r.Category != "beach" {
+			t.Errorf("%s: expected beach, got %+v", tt.name, r)
+		}
+	}
+
+	// Without a baseline the margin does not apply.
+	r := DecideOne(ImageScores{Path: "a.jpg", Scores: map[string]float32{"beach": 0.25}}, Options{Threshold: 0.1, BaselineMargin: -0.5, Quiet: true})
+	if r.Category != "beach" {
+		t.Errorf("scores without a baseline should ignore the margin, got %+v", r)
+	}
+}
+
+// fakeClassifier returns canned scores or errors per image path.
+type fakeClassifier struct {
+	scores map[string]map[string]float32
+	errs   map[string]error
+}
+
+func (f fakeClassifier) ClassifyWithOptions(path string, _ []string, _ model.ClassifyOptions) (map[string]float32, error) {
+	if err, ok := f.errs[path]; ok {
+		return nil, err
+	}
+	return f.scores[path], nil
+}
+
+func TestCategorize(t *testing.T) {
+	clip := fakeClassifier{
+		scores: map[string]map[string]float32{
+			"assign.jpg":   {model.BaselineCategory: 0.1, "beach": 0.7, "city": 0.2},
+			"low.jpg":      {model.BaselineCategory: 0.05, "beach": 0.12, "city": 0.11},
+			"baseline.jpg": {model.BaselineCategory: 0.6, "beach": 0.3, "city": 0.1},
+			"tie.jpg":      {model.BaselineCategory: 0.4, "beach": 0.4, "city": 0.2},
+			"exact.jpg":    {model.BaselineCategory: 0.05, "beach": 0.15, "city": 0.1},
+		},
+		errs: map[string]error{"broken.jpg": fmt.Errorf("cannot preprocess image: bad data")},
+	}
+
+	tests := []struct {
+		path     string
+		category string
+		skipped  bool
+	}{
+		{"assign.jpg", "beach", false},
+		{"low.jpg", "", true},         // best is below the threshold
+		{"baseline.jpg", "", true},    // baseline beats every category
+		{"tie.jpg", "", true},         // ties go to the baseline
+		{"exact.jpg", "beach", false}, // exactly at the threshold is kept
+		{"broken.jpg", "", true},      // classification error
+	}
+
+	paths := make([]string, len(tests))
+	for i, tt := range tests {
+		paths[i] = tt.path
+	}
+	results, err := Categorize(clip, paths, []string{"beach", "city"}, 0.15, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tt := range tests {
+		r := results[i]
+		if r.Path != tt.path || r.Skipped != tt.skipped || r.Category != tt.category {
+			t.Errorf("%s: expected category %q skipped=%v, got %+v", tt.path, tt.category, tt.skipped, r)
+		}
+	}
+	if results[0].RunnerUp != "city" {
+		t.Errorf("expected runner-up city, got %q", results[0].RunnerUp)
+	}
+}
+
+func TestCategorizeNoCategories(t *testing.T) {
+	if _, err := Categorize(fakeClassifier{}, []string{"a.jpg"}, nil, 0.15, nil); err == nil {
+		t.Error("expected error for empty category list")
+	}
+}
+
+func TestClassifyAllParallelKeepsOrder(t *testing.T) {
+	clip := fakeClassifier{scores: map[string]map[string]float32{}}
+	var paths []string
+	for i := range 20 {
+		p := fmt.Sprintf("img%02d.jpg", i)
+		paths = append(paths, p)
+		clip.scores[p] = map[string]float32{model.BaselineCategory: 0.1, "beach": float32(i) / 20}
+	}
+
+	calls := 0
+	seen := make(map[string]bool)
+	all, err := ClassifyAllParallel(clip, paths, []string{"beach"}, model.ClassifyOptions{}, 4,
+		func(current, total int, done ImageScores) {
+			calls++
+			seen[done.Path] = true
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, is := range all {
+		if is.Path != paths[i] || is.Scores["beach"] != float32(i)/20 {
+			t.Errorf("result %d out of order: %+v", i, is)
+		}
+	}
+	if calls != len(paths) || len(seen) != len(paths) {
+		t.Errorf("expected %d progress calls, one per image, got %d for %d images", len(paths), calls, len(seen))
+	}
+}
+
+// echoClassifier scores every category it is given equally.
+type echoClassifier struct{}
+
+func (echoClassifier) ClassifyWithOptions(_ string, cats []string, _ model.ClassifyOptions) (map[string]float32, error) {
+	scores := make(map[string]float32, len(cats))
+	for _, c := range cats {
+		scores[c] = 1 / float32(len(cats))
+	}
+	return scores, nil
+}
+
+func TestWithCategorySelector(t *testing.T) {
+	clip := WithCategorySelector(echoClassifier{}, func(path string, cats []string) []string {
+		if path == "camera.jpg" {
+			return cats[:1]
+		}
+		return cats
+	})
+
+	all, err := ClassifyAll(clip, []string{"camera.jpg", "other.png"}, []string{"photos", "screenshots"}, model.ClassifyOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all[0].Scores) != 1 || all[0].Scores["photos"] != 1 {
+		t.Errorf("expected only the selected category, got %v", all[0].Scores)
+	}
+	if len(all[1].Scores) != 2 {
+		t.Errorf("expected the full list for unselected images, got %v", all[1].Scores)
+	}
+}
+
+func TestClassifyAllEvents(t *testing.T) {
+	paths, err := filepath.Glob("../../testdata/*.jpg")
+	if err != nil || len(paths) < 2 {
+		t.Fatalf("expected testdata images, got %v: %v", paths, err)
+	}
+	clip := fakeClassifier{
+		scores: make(map[string]map[string]float32),
+		errs:   map[string]error{paths[1]: fmt.Errorf("cannot decode image: %w", ErrTimeout)},
+	}
+	for _, p := range paths {
+		clip.scores[p] = map[string]float32{model.BaselineCategory: 0.1, "beach": 0.9}
+	}
+
+	var events []ProgressEvent
+	if _, err := ClassifyAllEvents(clip, paths, []string{"beach"}, model.ClassifyOptions{}, 1, func(e ProgressEvent) {
+		events = append(events, e)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2*len(paths) {
+		t.Fatalf("expected a start and a finish per image, got %+v", events)
+	}
+	for i, p := range paths {
+		start, end := events[2*i], events[2*i+1]
+		if start.Kind != ProgressStarted || start.Path != p || start.Done != i || start.Total != len(paths) {
+			t.Errorf("event %d: expected %s to start after %d done, got %+v", 2*i, p, i, start)
+		}
+		want := OutcomeClassified
+		if i == 1 {
+			want = OutcomeTimeout
+		}
+		if end.Kind != ProgressFinished || end.Path != p || end.Done != i+1 || end.Outcome != want ||
+			end.Scores.Path != p || end.Elapsed < 0 {
+			t.Errorf("event %d: expected %s to finish as %s, got %+v", 2*i+1, p, want, end)
+		}
+	}
+
+	// The old callback still sees each finished image once, in order.
+	var calls []string
+	ClassifyAll(clip, paths, []string{"beach"}, model.ClassifyOptions{}, func(current, total int, done ImageScores) {
+		calls = append(calls, fmt.Sprintf("%d/%d %s", current, total, done.Path))
+	})
+	for i, p := range paths {
+		if want := fmt.Sprintf("%d/%d %s", i+1, len(paths), p); i >= len(calls) || calls[i] != want {
+			t.Errorf("call %d: expected %q, got %v", i, want, calls)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	paths, err := filepath.Glob("../../testdata/*.jpg")
+	if err != nil || len(paths) < 2 {
+		t.Fatalf("expected testdata images, got %v: %v", paths, err)
+	}
+	paths = paths[:2]
+	clip := fakeClassifier{
+		scores: map[string]map[string]float32{paths[0]: {model.BaselineCategory: 0.1, "beach": 0.9}},
+		errs:   map[string]error{paths[1]: fmt.Errorf("cannot decode image")},
+	}
+
+	var buf bytes.Buffer
+	pw := NewProgressWriter(&buf)
+	if _, err := ClassifyAllEvents(clip, paths, []string{"beach"}, model.ClassifyOptions{}, 1, pw.Write); err != nil {
+		t.Fatal(err)
+	}
+	if pw.Err() != nil {
+		t.Fatal(pw.Err())
+	}
+
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]any
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	want := []struct {
+		event, path, outcome string
+		done                 float64
+	}{
+		{"started", paths[0], "", 0},
+		{"finished", paths[0], OutcomeClassified, 1},
+		{"started", paths[1], "", 1},
+		{"finished", paths[1], OutcomeError, 2},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %v", len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e["event"] != w.event || e["path"] != w.path || e["done"] != w.done || e["total"] != 2.0 {
+			t.Errorf("event %d: expected %s %s after %v done, got %v", i, w.event, w.path, w.done, e)
+		}
+		if outcome, _ := e["outcome"].(string); outcome != w.outcome {
+			t.Errorf("event %d: expected outcome %q, got %v", i, w.outcome, e)
+		}
+		if _, ok := e["elapsed_ms"]; ok != (w.event == "finished") {
+			t.Errorf("event %d: elapsed_ms should only be on finished events, got %v", i, e)
+		}
+	}
+	if events[3]["error"] == nil {
+		t.Errorf("expected the failed image's error, got %v", events[3])
+	}
+}
@@ -0,0 +1,89 @@
+package categorizer
+
+import (
+	"slices"
+	"sort"
+)
+
+// GroupOrder is the order GroupByCategory returns its groups in.
+type GroupOrder int
+
+const (
+	// ByName sorts groups by category name (the default).
+	ByName GroupOrder = iota
+	// ByCount puts the largest groups first, breaking ties by name.
+	ByCount
+)
+
+// GroupOptions controls GroupByCategory.
+type GroupOptions struct {
+	Order GroupOrder
+}
+
+// Group is the results sharing one category, in their original order.
+type Group struct {
+	Category string
+	Results  []Result
+}
+
+// SkipGroup is the skipped results sharing one skip reason, in their
+// original order.
+type SkipGroup struct {
+	Reason  string
+	Results []Result
+}
+
+// GroupByCategory groups the categorized results by category, in the order
+// opts asks for. Skipped results are left out.
+func GroupByCategory(results []Result, opts GroupOptions) []Group {
+	categorized, _ := Partition(results)
+	keys, members := groupBy(categorized, func(r Result) string { return r.Category })
+	groups := make([]Group, len(keys))
+	for i, k := range keys {
+		groups[i] = Group{Category: k, Results: members[k]}
+	}
+	if opts.Order == ByCount {
+		sort.SliceStable(groups, func(a, b int) bool { return len(groups[a].Results) > len(groups[b].Results) })
+	}
+	return groups
+}
+
+// GroupSkippedByReason groups the skipped results by SkipReason, sorted by
+// reason.
+func GroupSkippedByReason(results []Result) []SkipGroup {
+	_, skipped := Partition(results)
+	keys, members := groupBy(skipped, func(r Result) string { return r.SkipReason })
+	groups := make([]SkipGroup, len(keys))
+	for i, k := range keys {
+		groups[i] = SkipGroup{Reason: k, Results: members[k]}
+	}
+	return groups
+}
+
+// Partition splits results into categorized and skipped ones, keeping
+// their order.
+func Partition(results []Result) (categorized, skipped []Result) {
+	for _, r := range results {
+		if r.Skipped {
+			skipped = append(skipped, r)
+		} else {
+			categorized = append(categorized, r)
+		}
+	}
+	return categorized, skipped
+}
+
+// groupBy collects results under key(r) and returns the keys sorted.
+func groupBy(results []Result, key func(Result) string) ([]string, map[string][]Result) {
+	members := make(map[string][]Result)
+	var keys []string
+	for _, r := range results {
+		k := key(r)
+		if _, ok := members[k]; !ok {
+			keys = append(keys, k)
+		}
+		members[k] = append(members[k], r)
+	}
+	slices.Sort(keys)
+	return keys, members
+}
@@ -0,0 +1,86 @@
+package categorizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+var groupFixture = []Result{
+	{Path: "a.jpg", Category: "city"},
+	{Path: "b.jpg", Skipped: true, SkipReason: SkipThreshold},
+	{Path: "c.jpg", Category: "beach"},
+	{Path: "d.jpg", Category: "city"},
+	{Path: "e.jpg", Skipped: true, SkipReason: SkipBaseline},
+	{Path: "f.jpg", Category: "aurora"},
+	{Path: "g.jpg", Skipped: true, SkipReason: SkipThreshold},
+}
+
+// groupPaths summarizes groups as category -> paths, in order.
+func groupPaths(groups []Group) [][]string {
+	var out [][]string
+	for _, g := range groups {
+		row := []string{g.Category}
+		for _, r := range g.Results {
+			row = append(row, r.Path)
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+func TestGroupByCategory(t *testing.T) {
+	want := [][]string{{"aurora", "f.jpg"}, {"beach", "c.jpg"}, {"city", "a.jpg", "d.jpg"}}
+	if got := groupPaths(GroupByCategory(groupFixture, GroupOptions{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("by name: got %v, want %v", got, want)
+	}
+
+	want = [][]string{{"city", "a.jpg", "d.jpg"}, {"aurora", "f.jpg"}, {"beach", "c.jpg"}}
+	if got := groupPaths(GroupByCategory(groupFixture, GroupOptions{Order: ByCount})); !reflect.DeepEqual(got, want) {
+		t.Errorf("by count: got %v, want %v", got, want)
+	}
+}
+
+func TestGroupByCategoryIsStable(t *testing.T) {
+	first := GroupByCategory(groupFixture, GroupOptions{Order: ByCount})
+	for i := 0; i < 20; i++ {
+		if got := GroupByCategory(groupFixture, GroupOptions{Order: ByCount}); !reflect.DeepEqual(got, first) {
+			t.Fatalf("run %d returned a different order: %v", i, groupPaths(got))
+		}
+	}
+	if got := GroupByCategory(nil, GroupOptions{}); len(got) != 0 {
+		t.Errorf("expected no groups for no results, got %v", got)
+	}
+}
+
+func TestGroupSkippedByReason(t *testing.T) {
+	groups := GroupSkippedByReason(groupFixture)
+	var got [][]string
+	for _, g := range groups {
+		row := []string{g.Reason}
+		for _, r := range g.Results {
+			row = append(row, r.Path)
+		}
+		got = append(got, row)
+	}
+	want := [][]string{{SkipBaseline, "e.jpg"}, {SkipThreshold, "b.jpg", "g.jpg"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	categorized, skipped := Partition(groupFixture)
+	paths := func(rs []Result) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.Path)
+		}
+		return out
+	}
+	if got := paths(categorized); !reflect.DeepEqual(got, []string{"a.jpg", "c.jpg", "d.jpg", "f.jpg"}) {
+		t.Errorf("categorized = %v", got)
+	}
+	if got := paths(skipped); !reflect.DeepEqual(got, []string{"b.jpg", "e.jpg", "g.jpg"}) {
+		t.Errorf("skipped = %v", got)
+	}
+}
@@ -0,0 +1,196 @@
+package categorizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// ProgressKind says what a ProgressEvent reports.
+type ProgressKind int
+
+const (
+	// ProgressStarted is reported as an image is handed to the classifier.
+	ProgressStarted ProgressKind = iota
+	// ProgressFinished is reported once an image is classified or has
+	// failed for good.
+	ProgressFinished
+)
+
+// Outcomes of a finished image, recorded in ProgressEvent.Outcome.
+const (
+	OutcomeClassified = "classified"
+	OutcomeError      = "error"
+	OutcomeTimeout    = "timeout"
+)
+
+// ProgressEvent is one step of a classification pass.
+type ProgressEvent struct {
+	Kind ProgressKind
+
+	// Path is the image started or finished.
+	Path string
+
+	// Done is how many images have finished, including this one, and
+	// Total how many the pass classifies.
+	Done  int
+	Total int
+
+	// For ProgressFinished: how long the image took, including retries,
+	// how it ended, and its scores.
+	Elapsed time.Duration
+	Outcome string
+	Scores  ImageScores
+}
+
+// ProgressEventFunc receives the events of a classification pass. Calls
+// are never concurrent, even when images are classified in parallel.
+type ProgressEventFunc func(ProgressEvent)
+
+// Events adapts f to a ProgressEventFunc that calls it for each finished
+// image. A nil f gives a nil ProgressEventFunc.
+func (f ProgressFunc) Events() ProgressEventFunc {
+	if f == nil {
+		return nil
+	}
+	return func(e ProgressEvent) {
+		if e.Kind == ProgressFinished {
+			f(e.Done, e.Total, e.Scores)
+		}
+	}
+}
+
+// ProgressWriter writes progress events as newline-delimited JSON, one
+// object per event, for scripts and GUIs following a run:
+//
+//	{"event":"started","path":"/photos/a.jpg","done":0,"total":120}
+//	{"event":"finished","path":"/photos/a.jpg","done":1,"total":120,"elapsed_ms":412,"outcome":"classified"}
+//
+// A finished image that failed also has its "error". Write is a
+// ProgressEventFunc.
+type ProgressWriter struct {
+	enc *json.Encoder
+	err error
+}
+
+// progressRecord is the JSON form of a ProgressEvent.
+type progressRecord struct {
+	Event     string `json:"event"`
+	Path      string `json:"path"`
+	Done      int    `json:"done"`
+	Total     int    `json:"total"`
+	ElapsedMS *int64 `json:"elapsed_ms,omitempty"`
+	Outcome   string `json:"outcome,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NewProgressWriter returns a ProgressWriter writing to w.
+func NewProgressWriter(w io.Writer) *ProgressWriter {
+	return &ProgressWriter{enc: json.NewEncoder(w)}
+}
+
+// Write writes e as one line. After a failed write, later events are
+// dropped; Err returns the failure.
+func (p *ProgressWriter) Write(e ProgressEvent) {
+	if p.err != nil {
+		return
+	}
+	rec := progressRecord{Event: "started", Path: e.Path, Done: e.Done, Total: e.Total}
+	if e.Kind == ProgressFinished {
+		ms := e.Elapsed.Milliseconds()
+		rec.Event, rec.ElapsedMS, rec.Outcome, rec.Error = "finished", &ms, e.Outcome, e.Scores.Error
+	}
+	if err := p.enc.Encode(rec); err != nil {
+		p.err = fmt.Errorf("cannot write progress: %w", err)
+	}
+}
+
+// Err returns the error that stopped Write, if any.
+func (p *ProgressWriter) Err() error {
+	return p.err
+}
+
+// outcome says how classifying an image ended.
+func outcome(is ImageScores) string {
+	switch {
+	case is.TimedOut:
+		return OutcomeTimeout
+	case is.Error != "":
+		return OutcomeError
+	}
+	return OutcomeClassified
+}
+
+// ClassifyAllEvents is ClassifyAllParallel reporting each image as it
+// starts and finishes rather than only once it is done. With workers of
+// one or less, images are classified in order, one at a time.
+func ClassifyAllEvents(
+	clip Classifier,
+	imagePaths []string,
+	categories []string,
+	classifyOpts model.ClassifyOptions,
+	workers int,
+	eventFn ProgressEventFunc,
+) ([]ImageScores, error) {
+	if len(categories) == 0 {
+		return nil, fmt.Errorf("no categories provided")
+	}
+
+	all := make([]ImageScores, len(imagePaths))
+	var mu sync.Mutex
+	done := 0
+	emit := func(e ProgressEvent) {
+		if eventFn == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		e.Total = len(imagePaths)
+		if e.Kind == ProgressFinished {
+			done++
+		}
+		e.Done = done
+		eventFn(e)
+	}
+	run := func(i int) {
+		emit(ProgressEvent{Kind: ProgressStarted, Path: imagePaths[i]})
+		start := time.Now()
+		all[i] = classifyOne(clip, imagePaths[i], categories, classifyOpts)
+		emit(ProgressEvent{
+			Kind:    ProgressFinished,
+			Path:    imagePaths[i],
+			Elapsed: time.Since(start),
+			Outcome: outcome(all[i]),
+			Scores:  all[i],
+		})
+	}
+
+	if workers <= 1 {
+		for i := range imagePaths {
+			run(i)
+		}
+		return all, nil
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(imagePaths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				run(i)
+			}
+		}()
+	}
+	for i := range imagePaths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return all, nil
+}
@@ -0,0 +1,75 @@
+package categorizer
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// RetryPolicy controls how often a failed classification is retried.
+type RetryPolicy struct {
+	// Retries is the number of extra attempts after the first failure.
+	Retries int
+
+	// Backoff is the wait before the first retry; it doubles each time.
+	Backoff time.Duration
+}
+
+// DefaultRetry is the policy used by ClassifyAll.
+var DefaultRetry = RetryPolicy{Retries: 2, Backoff: 250 * time.Millisecond}
+
+// sleep is replaced in tests to avoid real delays.
+var sleep = time.Sleep
+
+// classifyWithRetry classifies one image, retrying transient failures. It
+// returns the number of attempts made along with the last result.
+func classifyWithRetry(clip Classifier, imgPath string, categories []string, opts model.ClassifyOptions, policy RetryPolicy) (map[string]float32, int, error) {
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		scores, err := clip.ClassifyWithOptions(imgPath, categories, opts)
+		if err == nil || attempt > policy.Retries || !isTransient(err) {
+			return scores, attempt, err
+		}
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// transientErrnos are errors a file can report while another process (such
+// as a sync client) briefly holds it open or locked.
+var transientErrnos = []syscall.Errno{syscall.EAGAIN, syscall.EBUSY, syscall.EINTR}
+
+// transientMessages match failures that carry no typed error: Windows
+// sharing violations surfaced through image decoders and allocation
+// failures reported by ONNX Runtime.
+var transientMessages = []string{
+	"being used by another process",
+	"lock violation",
+	"out of memory",
+	"failed to allocate",
+	"bad_alloc",
+}
+
+// isTransient reports whether err is likely to succeed on retry. Decode
+// errors, missing files, and model errors are permanent.
+func isTransient(err error) bool {
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var temp interface{ Temporary() bool }
+	if errors.As(err, &temp) && temp.Temporary() {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
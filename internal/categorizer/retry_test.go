@@ -0,0 +1,84 @@
+package categorizer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// flakyClassifier fails with err for the first failures calls.
+type flakyClassifier struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyClassifier) ClassifyWithOptions(string, []string, model.ClassifyOptions) (map[string]float32, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return map[string]float32{model.BaselineCategory: 0.1, "beach": 0.9}, nil
+}
+
+func noSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+func TestClassifyAllRetriesTransient(t *testing.T) {
+	waits := noSleep(t)
+	lockErr := &fs.PathError{Op: "open", Path: "a.jpg", Err: syscall.EBUSY}
+	clip := &flakyClassifier{failures: 2, err: lockErr}
+
+	all, err := ClassifyAll(clip, []string{"a.jpg"}, []string{"beach"}, model.ClassifyOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].Error != "" || all[0].Attempts != 3 {
+		t.Fatalf("expected success on attempt 3, got %+v", all[0])
+	}
+	if len(*waits) != 2 || (*waits)[1] != 2*(*waits)[0] {
+		t.Errorf("expected doubling backoff, got %v", *waits)
+	}
+
+	results := Decide(all, Options{})
+	if results[0].Category != "beach" || results[0].Attempts != 3 {
+		t.Errorf("expected attempts on result, got %+v", results[0])
+	}
+}
+
+func TestClassifyAllGivesUpAfterRetries(t *testing.T) {
+	noSleep(t)
+	clip := &flakyClassifier{failures: 10, err: fmt.Errorf("run failed: Failed to allocate memory")}
+
+	all, err := ClassifyAll(clip, []string{"a.jpg"}, []string{"beach"}, model.ClassifyOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].Attempts != DefaultRetry.Retries+1 || all[0].Error != "run failed: Failed to allocate memory" {
+		t.Errorf("expected original error after %d attempts, got %+v", DefaultRetry.Retries+1, all[0])
+	}
+}
+
+func TestClassifyAllDoesNotRetryPermanent(t *testing.T) {
+	waits := noSleep(t)
+	clip := &flakyClassifier{failures: 1, err: errors.New("cannot preprocess image: unknown format")}
+
+	all, err := ClassifyAll(clip, []string{"a.jpg"}, []string{"beach"}, model.ClassifyOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].Error == "" || all[0].Attempts != 1 || len(*waits) != 0 {
+		t.Errorf("permanent errors should not be retried, got %+v", all[0])
+	}
+}
@@ -0,0 +1,76 @@
+package categorizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ScoreFile is the on-disk format written by --scores-out and read by
+// --scores-in. Image paths are stored relative to the sorted directory so the
+// file stays valid if the directory is moved or mounted elsewhere.
+type ScoreFile struct {
+	Model      string        `json:"model"`
+	Categories []string      `json:"categories"`
+	Images     []ImageScores `json:"images"`
+}
+
+// WriteScores saves raw score maps for the images in baseDir to path.
+func WriteScores(path, baseDir, modelID string, categories []string, all []ImageScores) error {
+	sf := ScoreFile{
+		Model:      modelID,
+		Categories: categories,
+		Images:     make([]ImageScores, 0, len(all)),
+	}
+	for _, is := range all {
+		if rel, err := filepath.Rel(baseDir, is.Path); err == nil {
+			is.Path = filepath.ToSlash(rel)
+		}
+		sf.Images = append(sf.Images, is)
+	}
+
+	data, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode scores: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write scores file: %w", err)
+	}
+	return nil
+}
+
+// ReadScores loads a score file and resolves its image paths against baseDir.
+func ReadScores(path, baseDir string) (*ScoreFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read scores file: %w", err)
+	}
+
+	var sf ScoreFile
+	if err := json.Unmarshal(data, &sf); err != nil {
+		return nil, fmt.Errorf("cannot parse scores file: %w", err)
+	}
+	for i, is := range sf.Images {
+		if !filepath.IsAbs(is.Path) {
+			sf.Images[i].Path = filepath.Join(baseDir, filepath.FromSlash(is.Path))
+		}
+	}
+	return &sf, nil
+}
+
+// CheckCompatible returns an error if the score file was produced with a
+// different model or category list than the current run would use. Scores are
+// softmaxed across the whole category list, so decisions are only meaningful
+// against the exact list that produced them.
+func (sf *ScoreFile) CheckCompatible(modelID string, categories []string) error {
+	if sf.Model != modelID {
+		return fmt.Errorf("scores were recorded with model %q, but the active model is %q", sf.Model, modelID)
+	}
+	if !slices.Equal(sf.Categories, categories) {
+		return fmt.Errorf("scores were recorded with %d categories that differ from the %d active categories; "+
+			"pass the same --categories used when the scores were written", len(sf.Categories), len(categories))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package categorizer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+func TestScoresRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cats := []string{"beach", "city", "food"}
+	all := []ImageScores{
+		{Path: filepath.Join(dir, "a.jpg"), Scores: map[string]float32{
+			model.BaselineCategory: 0.05, "beach": 0.7, "city": 0.2, "food": 0.05,
+		}},
+		{Path: filepath.Join(dir, "b.jpg"), Scores: map[string]float32{
+			model.BaselineCategory: 0.4, "beach": 0.2, "city": 0.2, "food": 0.2,
+		}},
+		{Path: filepath.Join(dir, "c.jpg"), Scores: map[string]float32{
+			model.BaselineCategory: 0.1, "beach": 0.1, "city": 0.15, "food": 0.65,
+		}},
+		{Path: filepath.Join(dir, "d.jpg"), Error: "cannot decode image"},
+	}
+
+	path := filepath.Join(t.TempDir(), "scores.json")
+	if err := WriteScores(path, dir, model.ModelID, cats, all); err != nil {
+		t.Fatal(err)
+	}
+
+	sf, err := ReadScores(path, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.CheckCompatible(model.ModelID, cats); err != nil {
+		t.Fatalf("expected compatible scores: %v", err)
+	}
+
+	for _, threshold := range []float64{0.1, 0.5, 0.68} {
+		live := Decide(all, Options{Threshold: threshold})
+		replayed := Decide(sf.Images, Options{Threshold: threshold})
+		if !reflect.DeepEqual(live, replayed) {
+			t.Errorf("threshold %.2f: decisions differ\nlive:     %+v\nreplayed: %+v", threshold, live, replayed)
+		}
+	}
+}
+
+func TestScoresCheckCompatible(t *testing.T) {
+	sf := &ScoreFile{Model: model.ModelID, Categories: []string{"beach", "city"}}
+
+	if err := sf.CheckCompatible(model.ModelID, []string{"beach", "food"}); err == nil {
+		t.Error("expected error for different category list")
+	}
+	if err := sf.CheckCompatible("other/model", []string{"beach", "city"}); err == nil {
+		t.Error("expected error for different model")
+	}
+}
@@ -0,0 +1,106 @@
+package categorizer
+
+import (
+	"iter"
+	"sync"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// ClassifyStream classifies each image paths yields and yields its scores
+// as soon as it is done, so memory use does not grow with the number of
+// images. With workers of one or less, images are classified in order, one
+// at a time, inside the caller's loop. Otherwise up to workers images are
+// classified at once, paths is ranged over on its own goroutine, and scores
+// are yielded in the order images finish. Breaking out of the loop stops
+// the stream; paths may then see one more image before it stops too.
+// Categories must not be empty.
+func ClassifyStream(
+	clip Classifier,
+	paths iter.Seq[string],
+	categories []string,
+	classifyOpts model.ClassifyOptions,
+	workers int,
+) iter.Seq[ImageScores] {
+	items := func(yield func(ImageScores) bool) {
+		for path := range paths {
+			if !yield(ImageScores{Path: path}) {
+				return
+			}
+		}
+	}
+	return ClassifyStreamScores(clip, items, categories, classifyOpts, workers)
+}
+
+// ClassifyStreamScores is like ClassifyStream for a stream that mixes
+// images to classify with images sorted by hand: an item with Override or
+// Pinned set is yielded as it is, in its turn, and the others are
+// classified by Path.
+func ClassifyStreamScores(
+	clip Classifier,
+	items iter.Seq[ImageScores],
+	categories []string,
+	classifyOpts model.ClassifyOptions,
+	workers int,
+) iter.Seq[ImageScores] {
+	classify := func(is ImageScores) ImageScores {
+		if is.Override != "" || is.Pinned != "" {
+			return is
+		}
+		return classifyOne(clip, is.Path, categories, classifyOpts)
+	}
+	return func(yield func(ImageScores) bool) {
+		if workers <= 1 {
+			for is := range items {
+				if !yield(classify(is)) {
+					return
+				}
+			}
+			return
+		}
+
+		jobs := make(chan ImageScores)
+		out := make(chan ImageScores)
+		stop := make(chan struct{})
+		go func() {
+			defer close(jobs)
+			for is := range items {
+				select {
+				case jobs <- is:
+				case <-stop:
+					return
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for is := range jobs {
+					select {
+					case out <- classify(is):
+					case <-stop:
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+
+		defer func() {
+			close(stop)
+			for range out {
+				// Wait for the workers, so none outlives the stream.
+			}
+		}()
+		for is := range out {
+			if !yield(is) {
+				return
+			}
+		}
+	}
+}
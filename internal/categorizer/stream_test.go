@@ -0,0 +1,130 @@
+package categorizer
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// inFlightClassifier records the most images it was asked to classify at
+// once.
+type inFlightClassifier struct {
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (c *inFlightClassifier) ClassifyWithOptions(_ string, cats []string, _ model.ClassifyOptions) (map[string]float32, error) {
+	c.mu.Lock()
+	c.current++
+	c.max = max(c.max, c.current)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.current--
+		c.mu.Unlock()
+	}()
+	return map[string]float32{cats[0]: 1}, nil
+}
+
+// countedPaths yields n generated paths without holding them in memory,
+// and counts how many were taken.
+func countedPaths(n int, taken *atomic.Int64) func(func(string) bool) {
+	return func(yield func(string) bool) {
+		for i := range n {
+			taken.Add(1)
+			if !yield(fmt.Sprintf("img-%05d.jpg", i)) {
+				return
+			}
+		}
+	}
+}
+
+func TestClassifyStream(t *testing.T) {
+	const n = 5000
+	for _, workers := range []int{1, 4} {
+		clip := &inFlightClassifier{}
+		var taken atomic.Int64
+		seen := make(map[string]bool, n)
+		last := ""
+		inOrder := true
+		for is := range ClassifyStream(clip, countedPaths(n, &taken), []string{"beach"}, model.ClassifyOptions{}, workers) {
+			if seen[is.Path] {
+				t.Fatalf("workers=%d: %s yielded twice", workers, is.Path)
+			}
+			seen[is.Path] = true
+			if is.Path < last {
+				inOrder = false
+			}
+			last = is.Path
+			if is.Scores["beach"] != 1 {
+				t.Fatalf("workers=%d: unexpected scores %v", workers, is.Scores)
+			}
+		}
+		if len(seen) != n {
+			t.Errorf("workers=%d: got %d images, want %d", workers, len(seen), n)
+		}
+		if clip.max > workers {
+			t.Errorf("workers=%d: %d images classified at once", workers, clip.max)
+		}
+		if workers == 1 && !inOrder {
+			t.Error("a single worker should keep the order of paths")
+		}
+	}
+}
+
+func TestClassifyStreamStops(t *testing.T) {
+	const workers = 4
+	var taken atomic.Int64
+	got := 0
+	for range ClassifyStream(&inFlightClassifier{}, countedPaths(5000, &taken), []string{"beach"}, model.ClassifyOptions{}, workers) {
+		got++
+		if got == 10 {
+			break
+		}
+	}
+	// Each worker may hold one image, and the feeder one more.
+	if n := taken.Load(); n > int64(got+workers+2) {
+		t.Errorf("stream read %d paths after stopping at %d", n, got)
+	}
+}
+
+func TestClassifyStreamScoresPassesManual(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		items := func(yield func(ImageScores) bool) {
+			for i := range 100 {
+				is := ImageScores{Path: fmt.Sprintf("img-%03d.jpg", i)}
+				switch i % 3 {
+				case 1:
+					is.Override = "city"
+				case 2:
+					is.Pinned = "dog"
+				}
+				if !yield(is) {
+					return
+				}
+			}
+		}
+		clip := &inFlightClassifier{}
+		counts := make(map[string]int)
+		for is := range ClassifyStreamScores(clip, items, []string{"beach"}, model.ClassifyOptions{}, workers) {
+			switch {
+			case is.Override != "":
+				counts["override"]++
+			case is.Pinned != "":
+				counts["pinned"]++
+			case is.Scores["beach"] == 1:
+				counts["classified"]++
+			}
+			if (is.Override != "" || is.Pinned != "") && is.Scores != nil {
+				t.Errorf("workers %d: %s was sorted by hand but classified", workers, is.Path)
+			}
+		}
+		if counts["classified"] != 34 || counts["override"] != 33 || counts["pinned"] != 33 {
+			t.Errorf("workers %d: unexpected counts %v", workers, counts)
+		}
+	}
+}
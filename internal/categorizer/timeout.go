@@ -0,0 +1,93 @@
+package categorizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// ErrTimeout is returned by a TimeoutClassifier for an image that took
+// longer than its timeout. It is not retried.
+var ErrTimeout = errors.New("classification timed out")
+
+// TimeoutOptions configures WithTimeout.
+type TimeoutOptions struct {
+	// Timeout bounds the preprocessing and inference of each image. Zero
+	// means no limit.
+	Timeout time.Duration
+
+	// SlowAfter is how long an image may take before OnSlow is called with
+	// its path, so a stuck file can be named before it times out. Zero or
+	// a nil OnSlow disables the notice.
+	SlowAfter time.Duration
+	OnSlow    func(path string, elapsed time.Duration)
+}
+
+// TimeoutClassifier is a Classifier that gives up on images taking longer
+// than a timeout. See WithTimeout.
+type TimeoutClassifier struct {
+	inner   Classifier
+	opts    TimeoutOptions
+	running atomic.Int64
+}
+
+// WithTimeout returns a Classifier that runs inner with the limits in opts.
+// An image that times out fails with ErrTimeout, which ClassifyAll records
+// as skipped with SkipTimeout so the run can continue. Decoders and ONNX
+// Runtime cannot be interrupted, so the abandoned call keeps running in the
+// background until it returns; Busy reports whether any still are.
+func WithTimeout(inner Classifier, opts TimeoutOptions) *TimeoutClassifier {
+	return &TimeoutClassifier{inner: inner, opts: opts}
+}
+
+type classifyResult struct {
+	scores map[string]float32
+	err    error
+}
+
+// ClassifyWithOptions implements Classifier.
+func (t *TimeoutClassifier) ClassifyWithOptions(imagePath string, categories []string, opts model.ClassifyOptions) (map[string]float32, error) {
+	ctx := context.Background()
+	if t.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.opts.Timeout)
+		defer cancel()
+	}
+	var slow <-chan time.Time
+	if t.opts.SlowAfter > 0 && t.opts.OnSlow != nil {
+		timer := time.NewTimer(t.opts.SlowAfter)
+		defer timer.Stop()
+		slow = timer.C
+	}
+
+	start := time.Now()
+	done := make(chan classifyResult, 1)
+	t.running.Add(1)
+	go func() {
+		defer t.running.Add(-1)
+		scores, err := t.inner.ClassifyWithOptions(imagePath, categories, opts)
+		done <- classifyResult{scores, err}
+	}()
+
+	for {
+		select {
+		case r := <-done:
+			return r.scores, r.err
+		case <-slow:
+			slow = nil
+			t.opts.OnSlow(imagePath, time.Since(start))
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w after %s", ErrTimeout, t.opts.Timeout)
+		}
+	}
+}
+
+// Busy reports whether a classification, including one that timed out, is
+// still running, in which case the inner classifier should not be released.
+func (t *TimeoutClassifier) Busy() bool {
+	return t.running.Load() > 0
+}
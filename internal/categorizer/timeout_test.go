@@ -0,0 +1,102 @@
+package categorizer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// slowClassifier blocks on images in delay for the given duration, or until
+// release is closed for a zero duration, and scores the rest at once.
+type slowClassifier struct {
+	delay   map[string]time.Duration
+	release chan struct{}
+}
+
+func (s slowClassifier) ClassifyWithOptions(path string, _ []string, _ model.ClassifyOptions) (map[string]float32, error) {
+	if d, ok := s.delay[path]; ok {
+		if d == 0 {
+			<-s.release
+		} else {
+			time.Sleep(d)
+		}
+	}
+	return map[string]float32{model.BaselineCategory: 0.1, "beach": 0.9}, nil
+}
+
+func TestWithTimeoutSkipsSlowImages(t *testing.T) {
+	release := make(chan struct{})
+	clip := WithTimeout(slowClassifier{delay: map[string]time.Duration{"stuck.jpg": 0}, release: release},
+		TimeoutOptions{Timeout: 50 * time.Millisecond})
+
+	results, err := Categorize(clip, []string{"stuck.jpg", "fast.jpg"}, []string{"beach"}, 0.15, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stuck, fast := results[0], results[1]
+	if !stuck.Skipped || stuck.SkipReason != SkipTimeout || stuck.Attempts != 1 {
+		t.Errorf("expected the stuck image to time out without retries, got %+v", stuck)
+	}
+	if fast.Skipped || fast.Category != "beach" {
+		t.Errorf("expected the run to continue past the timeout, got %+v", fast)
+	}
+
+	if !clip.Busy() {
+		t.Error("expected the abandoned classification to still be running")
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for clip.Busy() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if clip.Busy() {
+		t.Error("expected Busy to clear once the classification returned")
+	}
+}
+
+func TestWithTimeoutReportsSlowImages(t *testing.T) {
+	var mu sync.Mutex
+	var slow []string
+	clip := WithTimeout(slowClassifier{delay: map[string]time.Duration{"big.tif": 50 * time.Millisecond}}, TimeoutOptions{
+		Timeout:   time.Second,
+		SlowAfter: 10 * time.Millisecond,
+		OnSlow: func(path string, elapsed time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			slow = append(slow, path)
+		},
+	})
+
+	all, err := ClassifyAll(clip, []string{"big.tif", "small.jpg"}, []string{"beach"}, model.ClassifyOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, is := range all {
+		if is.Error != "" {
+			t.Errorf("%s: unexpected error %s", is.Path, is.Error)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slow) != 1 || slow[0] != "big.tif" {
+		t.Errorf("expected one slow notice for big.tif, got %v", slow)
+	}
+}
+
+func TestWithTimeoutError(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	clip := WithTimeout(slowClassifier{delay: map[string]time.Duration{"a.jpg": 0}, release: release},
+		TimeoutOptions{Timeout: time.Millisecond})
+
+	_, err := clip.ClassifyWithOptions("a.jpg", []string{"beach"}, model.ClassifyOptions{})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if isTransient(err) {
+		t.Error("timeouts should not be retried")
+	}
+}
@@ -0,0 +1,215 @@
+// Package dirconfig reads the per-directory settings file that pins how a
+// particular folder is sorted.
+package dirconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/yamlline"
+)
+
+// FileName is the settings file looked up inside the directory being sorted.
+const FileName = ".imgsort.yaml"
+
+// Config holds the settings a directory pins. Fields that the file does not
+// set are left at their zero value (nil for pointers), so callers can tell an
+// explicit false or 0 from an absent key.
+type Config struct {
+	// Path is the file the settings were read from.
+	Path string
+
+	Profile        string
+	Categories     []string
+	Confidence     *float64
+	Mode           string
+	OnConflict     string
+	Secondary      string
+	Unsorted       string
+	MaxPerCategory *int
+	Preserve       *bool
+}
+
+// Keys lists the settings a directory file may contain, in display order.
+var Keys = []string{
+	"profile", "categories", "confidence", "mode",
+	"on-conflict", "secondary", "unsorted", "max-per-category", "preserve-structure",
+}
+
+// Load reads FileName from dir. It returns nil without error when the
+// directory has no settings file. Unknown keys are not an error; they are
+// returned as warnings so a typo is visible without blocking the run.
+func Load(dir string) (*Config, []string, error) {
+	path := filepath.Join(dir, FileName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open directory settings: %w", err)
+	}
+	defer f.Close()
+
+	cfg, warnings, err := Parse(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Path = path
+	for i, w := range warnings {
+		warnings[i] = path + ": " + w
+	}
+	return cfg, warnings, nil
+}
+
+// Parse reads settings in a small YAML subset: one "key: value" per line,
+// "#" comments, and lists written either inline ("[a, b]") or as indented
+// "- item" lines below the key.
+func Parse(r io.Reader) (*Config, []string, error) {
+	cfg := &Config{}
+	var warnings []string
+	seen := make(map[string]bool)
+
+	// listKey is the key whose block list is being read, if any.
+	listKey := ""
+	var list []string
+	listLine := 0
+	finishList := func() error {
+		if listKey == "" {
+			return nil
+		}
+		err := cfg.setList(listKey, list)
+		if err != nil {
+			err = fmt.Errorf("line %d: %w", listLine, err)
+		}
+		listKey, list = "", nil
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := yamlline.StripComment(scanner.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			item, ok := strings.CutPrefix(strings.TrimSpace(line), "- ")
+			if listKey == "" {
+				return nil, nil, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			if !ok {
+				return nil, nil, fmt.Errorf("line %d: expected a \"- item\" list entry", lineNo)
+			}
+			list = append(list, yamlline.Unquote(strings.TrimSpace(item)))
+			continue
+		}
+		if err := finishList(); err != nil {
+			return nil, nil, err
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if seen[key] {
+			return nil, nil, fmt.Errorf("line %d: %s is set more than once", lineNo, key)
+		}
+		seen[key] = true
+
+		if !slices.Contains(Keys, key) {
+			warnings = append(warnings, fmt.Sprintf("line %d: unknown setting %q ignored", lineNo, key))
+			continue
+		}
+		if value == "" {
+			listKey, listLine = key, lineNo
+			continue
+		}
+		if err := cfg.set(key, value); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("cannot read directory settings: %w", err)
+	}
+	if err := finishList(); err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Profile != "" && len(cfg.Categories) > 0 {
+		return nil, nil, fmt.Errorf("profile and categories cannot both be set")
+	}
+	return cfg, warnings, nil
+}
+
+// set applies a single scalar or inline-list value.
+func (c *Config) set(key, value string) error {
+	if strings.HasPrefix(value, "[") {
+		if !strings.HasSuffix(value, "]") {
+			return fmt.Errorf("%s: unterminated list", key)
+		}
+		var items []string
+		for _, item := range strings.Split(value[1:len(value)-1], ",") {
+			if item = yamlline.Unquote(strings.TrimSpace(item)); item != "" {
+				items = append(items, item)
+			}
+		}
+		return c.setList(key, items)
+	}
+
+	value = yamlline.Unquote(value)
+	switch key {
+	case "profile":
+		c.Profile = value
+	case "categories":
+		return c.setList(key, []string{value})
+	case "confidence":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 || f > 1 {
+			return fmt.Errorf("confidence must be a number between 0 and 1, got %q", value)
+		}
+		c.Confidence = &f
+	case "mode":
+		c.Mode = value
+	case "on-conflict":
+		c.OnConflict = value
+	case "secondary":
+		c.Secondary = value
+	case "unsorted":
+		c.Unsorted = value
+	case "max-per-category":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("max-per-category must be a non-negative integer, got %q", value)
+		}
+		c.MaxPerCategory = &n
+	case "preserve-structure":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("preserve-structure must be true or false, got %q", value)
+		}
+		c.Preserve = &b
+	}
+	return nil
+}
+
+// setList applies a list value. Only categories accepts one.
+func (c *Config) setList(key string, items []string) error {
+	if key != "categories" {
+		return fmt.Errorf("%s does not take a list", key)
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("categories must not be empty")
+	}
+	c.Categories = items
+	return nil
+}
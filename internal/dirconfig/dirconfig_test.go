@@ -0,0 +1,121 @@
+package dirconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `# Camera roll
+categories:
+  - beach
+  - "city street"
+confidence: 0.3   # stricter than the default
+on-conflict: skip
+unsorted: move
+max-per-category: 500
+preserve-structure: true
+`
+	cfg, warnings, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if !slices.Equal(cfg.Categories, []string{"beach", "city street"}) {
+		t.Errorf("categories = %v", cfg.Categories)
+	}
+	if cfg.Confidence == nil || *cfg.Confidence != 0.3 {
+		t.Errorf("confidence = %v", cfg.Confidence)
+	}
+	if cfg.OnConflict != "skip" {
+		t.Errorf("on-conflict = %q", cfg.OnConflict)
+	}
+	if cfg.Unsorted != "move" {
+		t.Errorf("unsorted = %q", cfg.Unsorted)
+	}
+	if cfg.MaxPerCategory == nil || *cfg.MaxPerCategory != 500 {
+		t.Errorf("max-per-category = %v", cfg.MaxPerCategory)
+	}
+	if cfg.Preserve == nil || !*cfg.Preserve {
+		t.Errorf("preserve-structure = %v", cfg.Preserve)
+	}
+	if cfg.Mode != "" || cfg.Secondary != "" || cfg.Profile != "" {
+		t.Errorf("unset keys should stay empty: %+v", cfg)
+	}
+}
+
+func TestParseInlineList(t *testing.T) {
+	cfg, _, err := Parse(strings.NewReader("categories: [dog, 'cat', c#, \"cat #2\"] # pets\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.Categories, []string{"dog", "cat", "c#", "cat #2"}) {
+		t.Errorf("categories = %v", cfg.Categories)
+	}
+}
+
+func TestParseUnknownKeyWarns(t *testing.T) {
+	cfg, warnings, err := Parse(strings.NewReader("profile: triage\nconfidance: 0.4\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Profile != "triage" {
+		t.Errorf("profile = %q", cfg.Profile)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `"confidance"`) || !strings.Contains(warnings[0], "line 2") {
+		t.Errorf("expected one warning for the misspelled key, got %v", warnings)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"bad confidence", "confidence: high\n", "line 1"},
+		{"confidence out of range", "confidence: 1.5\n", "between 0 and 1"},
+		{"negative max", "max-per-category: -1\n", "non-negative"},
+		{"bad bool", "preserve-structure: maybe\n", "true or false"},
+		{"list for scalar", "mode: [clip]\n", "does not take a list"},
+		{"empty list", "categories:\nmode: clip\n", "must not be empty"},
+		{"orphan item", "  - dog\n", "without a key"},
+		{"no colon", "categories\n", "key: value"},
+		{"duplicate", "mode: clip\nmode: color\n", "more than once"},
+		{"profile and categories", "profile: triage\ncategories: [dog]\n", "cannot both be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cfg, warnings, err := Load(dir)
+	if cfg != nil || warnings != nil || err != nil {
+		t.Fatalf("missing file should load as nil, got %v %v %v", cfg, warnings, err)
+	}
+
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte("mode: color\nlayout: flat\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, warnings, err = Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Path != path || cfg.Mode != "color" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], path) {
+		t.Errorf("warnings should name the file: %v", warnings)
+	}
+}
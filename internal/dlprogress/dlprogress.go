@@ -0,0 +1,138 @@
+// Package dlprogress shows the progress of model downloads, one line per
+// file, without downloads running side by side overwriting each other's
+// output.
+package dlprogress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Intervals between redraws. A terminal is redrawn often enough to look
+// live; a log gets a line per file now and then, so it stays readable.
+const (
+	ttyInterval   = 100 * time.Millisecond
+	plainInterval = 10 * time.Second
+)
+
+// Renderer receives download progress. Update has the signature of the
+// progress callback model.EnsureFiles takes, and may be called from several
+// goroutines at once. Finish writes the final state once every download
+// has ended.
+type Renderer interface {
+	Update(name string, downloaded, total int64)
+	Finish()
+}
+
+// New returns a Renderer writing to w. On a terminal (tty) it keeps one
+// line per download and rewrites them in place with ANSI cursor movement;
+// otherwise it prints a plain line per download when it starts, every few
+// seconds, and when it completes.
+func New(w io.Writer, tty bool) Renderer {
+	return &renderer{w: w, tty: tty, now: time.Now, files: make(map[string]*file)}
+}
+
+// Nop returns a Renderer that writes nothing, for quiet output.
+func Nop() Renderer {
+	return nop{}
+}
+
+type nop struct{}
+
+func (nop) Update(string, int64, int64) {}
+func (nop) Finish()                     {}
+
+// file is the last reported progress of one download.
+type file struct {
+	name              string
+	downloaded, total int64
+	printed           time.Time // last plain line, when not on a terminal
+	done              bool
+}
+
+// line describes f's progress.
+func (f *file) line() string {
+	switch {
+	case f.done:
+		return fmt.Sprintf("Downloaded %s", f.name)
+	case f.total > 0:
+		return fmt.Sprintf("Downloading %s... %.0f%%", f.name, float64(f.downloaded)/float64(f.total)*100)
+	}
+	return fmt.Sprintf("Downloading %s... %d bytes", f.name, f.downloaded)
+}
+
+type renderer struct {
+	w   io.Writer
+	tty bool
+	now func() time.Time
+
+	mu    sync.Mutex
+	order []*file // in the order downloads started
+	files map[string]*file
+	drawn int       // lines of the terminal block drawn so far
+	drew  time.Time // when the terminal block was last drawn
+}
+
+func (r *renderer) Update(name string, downloaded, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.files[name]
+	started := f == nil
+	if started {
+		f = &file{name: name}
+		r.files[name] = f
+		r.order = append(r.order, f)
+	}
+	f.downloaded, f.total = downloaded, total
+	completed := total > 0 && downloaded >= total
+	f.done = completed
+
+	now := r.now()
+	if r.tty {
+		if started || completed || now.Sub(r.drew) >= ttyInterval {
+			r.draw(now)
+		}
+		return
+	}
+	if started || completed || now.Sub(f.printed) >= plainInterval {
+		fmt.Fprintln(r.w, f.line())
+		f.printed = now
+	}
+}
+
+// draw rewrites the terminal block: the cursor goes back up to its first
+// line, and every line is cleared and written again, so the cursor ends up
+// just below the block.
+func (r *renderer) draw(now time.Time) {
+	var b strings.Builder
+	if r.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", r.drawn)
+	}
+	for _, f := range r.order {
+		fmt.Fprintf(&b, "\r\033[K%s\n", f.line())
+	}
+	io.WriteString(r.w, b.String())
+	r.drawn = len(r.order)
+	r.drew = now
+}
+
+func (r *renderer) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tty {
+		if len(r.order) > 0 {
+			r.draw(r.now())
+		}
+		return
+	}
+	// Complete downloads were printed as they ended; show where the others
+	// stopped, since the last periodic line may be well behind.
+	for _, f := range r.order {
+		if !f.done {
+			fmt.Fprintln(r.w, f.line())
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package dlprogress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTest returns a renderer into buf whose clock advances only when the
+// returned function is called.
+func newTest(buf *bytes.Buffer, tty bool) (*renderer, func(time.Duration)) {
+	r := New(buf, tty).(*renderer)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTerminalRendersLinePerDownload(t *testing.T) {
+	var buf bytes.Buffer
+	r, advance := newTest(&buf, true)
+
+	r.Update("model.onnx", 0, 200)
+	r.Update("vocab.json", 0, 100)
+	advance(time.Second)
+	r.Update("model.onnx", 100, 200)
+	r.Update("vocab.json", 10, 100) // too soon after the last redraw
+	r.Update("vocab.json", 100, 100)
+	r.Finish()
+
+	want := "\r\033[KDownloading model.onnx... 0%\n" +
+		"\033[1A\r\033[KDownloading model.onnx... 0%\n\r\033[KDownloading vocab.json... 0%\n" +
+		"\033[2A\r\033[KDownloading model.onnx... 50%\n\r\033[KDownloading vocab.json... 0%\n" +
+		"\033[2A\r\033[KDownloading model.onnx... 50%\n\r\033[KDownloaded vocab.json\n" +
+		"\033[2A\r\033[KDownloading model.onnx... 50%\n\r\033[KDownloaded vocab.json\n"
+	if got := buf.String(); got != want {
+		t.Errorf("terminal output:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestPlainPrintsPeriodically(t *testing.T) {
+	var buf bytes.Buffer
+	r, advance := newTest(&buf, false)
+
+	r.Update("model.onnx", 0, 200)
+	r.Update("merges.txt", 0, -1)
+	advance(time.Second)
+	r.Update("model.onnx", 50, 200)
+	advance(plainInterval)
+	r.Update("model.onnx", 100, 200)
+	r.Update("merges.txt", 4096, -1)
+	r.Update("model.onnx", 200, 200)
+	r.Update("merges.txt", 8192, -1)
+	r.Finish()
+
+	want := []string{
+		"Downloading model.onnx... 0%",
+		"Downloading merges.txt... 0 bytes",
+		"Downloading model.onnx... 50%",
+		"Downloading merges.txt... 4096 bytes",
+		"Downloaded model.onnx",
+		"Downloading merges.txt... 8192 bytes",
+	}
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("plain output:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if strings.Contains(buf.String(), "\033") || strings.Contains(buf.String(), "\r") {
+		t.Errorf("plain output should have no terminal control codes: %q", buf.String())
+	}
+}
+
+func TestNop(t *testing.T) {
+	r := Nop()
+	r.Update("model.onnx", 1, 2)
+	r.Finish()
+}
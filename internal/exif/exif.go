@@ -0,0 +1,254 @@
+// Package exif reads the few EXIF fields imgsort uses, without decoding the
+// image. Only JPEG (APP1) and TIFF files carry EXIF in a form it reads;
+// other formats report no metadata.
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TIFF tags for the camera maker and model in IFD0, the pointer to the Exif
+// sub-IFD, and the capture time inside it.
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+// TIFF field types used here.
+const (
+	typeASCII = 2
+	typeLong  = 4
+)
+
+// dateLayout is the format of EXIF date and time values.
+const dateLayout = "2006:01:02 15:04:05"
+
+// maxTIFFHeader bounds how much of a TIFF file is read to find IFD0.
+const maxTIFFHeader = 1 << 20
+
+// errNoExif means the file has no EXIF block that could be read.
+var errNoExif = errors.New("no EXIF data")
+
+// Camera holds the camera identification tags.
+type Camera struct {
+	Make  string
+	Model string
+}
+
+// Known reports whether any camera tag was present.
+func (c Camera) Known() bool {
+	return c.Make != "" || c.Model != ""
+}
+
+// ReadCamera returns the camera Make and Model recorded in path. Files
+// without EXIF, or in formats it does not read, yield an empty Camera and no
+// error; only I/O failures are returned.
+func ReadCamera(path string) (Camera, error) {
+	tiff, err := readTIFF(path)
+	if err != nil || tiff == nil {
+		return Camera{}, err
+	}
+	return parseCamera(tiff), nil
+}
+
+// ReadDateTaken returns the DateTimeOriginal recorded in path. EXIF stores no
+// time zone, so the time is interpreted as local. ok is false when the file
+// has no such tag; only I/O failures are returned as errors.
+func ReadDateTaken(path string) (t time.Time, ok bool, err error) {
+	tiff, err := readTIFF(path)
+	if err != nil || tiff == nil {
+		return time.Time{}, false, err
+	}
+	t, ok = parseDateTaken(tiff)
+	return t, ok, nil
+}
+
+// readTIFF returns the TIFF block holding path's EXIF, or nil if the file has
+// none or is in a format it does not read.
+func readTIFF(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tiff []byte
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		tiff, err = jpegExif(bufio.NewReader(f))
+	case ".tif", ".tiff":
+		tiff, err = io.ReadAll(io.LimitReader(f, maxTIFFHeader))
+	default:
+		return nil, nil
+	}
+	if errors.Is(err, errNoExif) {
+		return nil, nil
+	}
+	return tiff, err
+}
+
+// jpegExif returns the TIFF payload of the first Exif APP1 segment.
+func jpegExif(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, errNoExif
+	}
+
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:2]); err != nil {
+			return nil, errNoExif
+		}
+		if hdr[0] != 0xFF {
+			return nil, errNoExif
+		}
+		marker := hdr[1]
+		if marker == 0xD8 || (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			continue // markers without a length
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, errNoExif // image data starts; metadata comes before it
+		}
+		if _, err := io.ReadFull(r, hdr[2:]); err != nil {
+			return nil, errNoExif
+		}
+		length := int(binary.BigEndian.Uint16(hdr[2:])) - 2
+		if length < 0 {
+			return nil, errNoExif
+		}
+
+		seg := make([]byte, length)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return nil, errNoExif
+		}
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:], nil
+		}
+	}
+}
+
+// parseCamera reads Make and Model from IFD0 of a TIFF block. Malformed
+// data yields whatever was read before the problem.
+func parseCamera(tiff []byte) Camera {
+	var cam Camera
+	order, ifd, ok := tiffIFD0(tiff)
+	if !ok {
+		return cam
+	}
+	walkIFD(tiff, order, ifd, func(tag, typ uint16, count, val int) {
+		if tag != tagMake && tag != tagModel {
+			return
+		}
+		s, ok := asciiValue(tiff, order, typ, count, val)
+		if !ok {
+			return
+		}
+		if tag == tagMake {
+			cam.Make = s
+		} else {
+			cam.Model = s
+		}
+	})
+	return cam
+}
+
+// parseDateTaken reads DateTimeOriginal from the Exif sub-IFD of a TIFF
+// block.
+func parseDateTaken(tiff []byte) (time.Time, bool) {
+	order, ifd, ok := tiffIFD0(tiff)
+	if !ok {
+		return time.Time{}, false
+	}
+	exifIFD := -1
+	walkIFD(tiff, order, ifd, func(tag, typ uint16, count, val int) {
+		if tag == tagExifIFD && typ == typeLong && count == 1 {
+			exifIFD = int(order.Uint32(tiff[val:]))
+		}
+	})
+	if exifIFD < 0 {
+		return time.Time{}, false
+	}
+
+	var taken time.Time
+	found := false
+	walkIFD(tiff, order, exifIFD, func(tag, typ uint16, count, val int) {
+		if tag != tagDateTimeOriginal {
+			return
+		}
+		s, ok := asciiValue(tiff, order, typ, count, val)
+		if !ok {
+			return
+		}
+		if t, err := time.ParseInLocation(dateLayout, s, time.Local); err == nil {
+			taken, found = t, true
+		}
+	})
+	return taken, found
+}
+
+// tiffIFD0 returns the byte order of a TIFF block and the offset of its first
+// IFD.
+func tiffIFD0(tiff []byte) (binary.ByteOrder, int, bool) {
+	if len(tiff) < 8 {
+		return nil, 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, 0, false
+	}
+	return order, int(order.Uint32(tiff[4:])), true
+}
+
+// walkIFD calls fn for each entry of the IFD at off, passing the offset of
+// the entry's 4-byte value field. Entries past the end of tiff are ignored.
+func walkIFD(tiff []byte, order binary.ByteOrder, off int, fn func(tag, typ uint16, count, val int)) {
+	if off < 0 || off+2 > len(tiff) {
+		return
+	}
+	n := int(order.Uint16(tiff[off:]))
+	for i := range n {
+		e := off + 2 + i*12
+		if e+12 > len(tiff) {
+			break
+		}
+		fn(order.Uint16(tiff[e:]), order.Uint16(tiff[e+2:]), int(order.Uint32(tiff[e+4:])), e+8)
+	}
+}
+
+// asciiValue returns the string held by an ASCII entry, whose value is
+// stored inline when it fits in 4 bytes and at an offset otherwise.
+func asciiValue(tiff []byte, order binary.ByteOrder, typ uint16, count, val int) (string, bool) {
+	if typ != typeASCII {
+		return "", false
+	}
+	var b []byte
+	if count <= 4 {
+		b = tiff[val : val+count]
+	} else {
+		off := int(order.Uint32(tiff[val:]))
+		if off < 0 || off+count > len(tiff) {
+			return "", false
+		}
+		b = tiff[off : off+count]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00")), true
+}
@@ -0,0 +1,198 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildTIFF returns a TIFF block whose IFD0 holds the given Make and Model.
+func buildTIFF(order binary.ByteOrder, make, model string) []byte {
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, order, uint16(42))
+	binary.Write(&buf, order, uint32(8))
+
+	values := []struct {
+		tag uint16
+		s   string
+	}{{tagMake, make}, {tagModel, model}}
+	dataOff := 8 + 2 + len(values)*12 + 4
+	var data bytes.Buffer
+
+	binary.Write(&buf, order, uint16(len(values)))
+	for _, v := range values {
+		s := v.s + "\x00"
+		binary.Write(&buf, order, v.tag)
+		binary.Write(&buf, order, uint16(2))
+		binary.Write(&buf, order, uint32(len(s)))
+		if len(s) <= 4 {
+			var inline [4]byte
+			copy(inline[:], s)
+			buf.Write(inline[:])
+		} else {
+			binary.Write(&buf, order, uint32(dataOff+data.Len()))
+			data.WriteString(s)
+		}
+	}
+	binary.Write(&buf, order, uint32(0)) // no next IFD
+	buf.Write(data.Bytes())
+	return buf.Bytes()
+}
+
+// buildDateTIFF returns a TIFF block whose Exif sub-IFD holds a
+// DateTimeOriginal of date.
+func buildDateTIFF(order binary.ByteOrder, date string) []byte {
+	var buf bytes.Buffer
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(&buf, order, uint16(42))
+	binary.Write(&buf, order, uint32(8))
+
+	// IFD0: a single pointer to the Exif sub-IFD that follows it.
+	exifOff := 8 + 2 + 12 + 4
+	binary.Write(&buf, order, uint16(1))
+	binary.Write(&buf, order, uint16(tagExifIFD))
+	binary.Write(&buf, order, uint16(typeLong))
+	binary.Write(&buf, order, uint32(1))
+	binary.Write(&buf, order, uint32(exifOff))
+	binary.Write(&buf, order, uint32(0))
+
+	s := date + "\x00"
+	binary.Write(&buf, order, uint16(1))
+	binary.Write(&buf, order, uint16(tagDateTimeOriginal))
+	binary.Write(&buf, order, uint16(typeASCII))
+	binary.Write(&buf, order, uint32(len(s)))
+	binary.Write(&buf, order, uint32(exifOff+2+12+4))
+	binary.Write(&buf, order, uint32(0))
+	buf.WriteString(s)
+	return buf.Bytes()
+}
+
+// writeJPEG writes a small JPEG, with an Exif APP1 segment if tiff is set.
+func writeJPEG(t *testing.T, tiff []byte) string {
+	t.Helper()
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := img.Bytes()
+
+	var out bytes.Buffer
+	out.Write(data[:2]) // SOI
+	if tiff != nil {
+		payload := append([]byte("Exif\x00\x00"), tiff...)
+		out.Write([]byte{0xFF, 0xE1})
+		binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+		out.Write(payload)
+	}
+	out.Write(data[2:])
+
+	path := filepath.Join(t.TempDir(), "img.jpg")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadCameraJPEG(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		path := writeJPEG(t, buildTIFF(order, "Canon", "EOS R5"))
+		cam, err := ReadCamera(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cam.Make != "Canon" || cam.Model != "EOS R5" || !cam.Known() {
+			t.Errorf("%v: unexpected camera %+v", order, cam)
+		}
+	}
+}
+
+func TestReadCameraShortInlineValue(t *testing.T) {
+	cam, err := ReadCamera(writeJPEG(t, buildTIFF(binary.LittleEndian, "LG", "G6")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cam.Make != "LG" || cam.Model != "G6" {
+		t.Errorf("unexpected camera %+v", cam)
+	}
+}
+
+func TestReadCameraTIFF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.tiff")
+	if err := os.WriteFile(path, buildTIFF(binary.BigEndian, "NIKON", "D850"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cam, err := ReadCamera(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cam.Make != "NIKON" || cam.Model != "D850" {
+		t.Errorf("unexpected camera %+v", cam)
+	}
+}
+
+func TestReadCameraNoExif(t *testing.T) {
+	for _, path := range []string{
+		writeJPEG(t, nil),
+		filepath.Join("..", "..", "testdata", "sunset.png"),
+		filepath.Join("..", "..", "testdata", "readme.txt"),
+	} {
+		cam, err := ReadCamera(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if cam.Known() {
+			t.Errorf("%s: expected no camera, got %+v", path, cam)
+		}
+	}
+}
+
+func TestParseCameraTruncated(t *testing.T) {
+	tiff := buildTIFF(binary.LittleEndian, "Canon", "EOS R5")
+	for n := range len(tiff) {
+		parseCamera(tiff[:n]) // must not panic
+	}
+}
+
+func TestReadDateTaken(t *testing.T) {
+	want := time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local)
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		taken, ok, err := ReadDateTaken(writeJPEG(t, buildDateTIFF(order, "2023:07:14 18:30:05")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || !taken.Equal(want) {
+			t.Errorf("%v: got %v (ok=%v), want %v", order, taken, ok, want)
+		}
+	}
+
+	for _, tiff := range [][]byte{
+		nil,
+		buildTIFF(binary.LittleEndian, "Canon", "EOS R5"),
+		buildDateTIFF(binary.LittleEndian, "0000:00:00 00:00:00"),
+	} {
+		if taken, ok, err := ReadDateTaken(writeJPEG(t, tiff)); err != nil || ok {
+			t.Errorf("expected no date, got %v (ok=%v, err=%v)", taken, ok, err)
+		}
+	}
+}
+
+func TestParseDateTakenTruncated(t *testing.T) {
+	tiff := buildDateTIFF(binary.BigEndian, "2023:07:14 18:30:05")
+	for n := range len(tiff) {
+		parseDateTaken(tiff[:n]) // must not panic
+	}
+}
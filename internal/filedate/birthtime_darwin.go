@@ -0,0 +1,16 @@
+package filedate
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// birthTime returns the file creation time macOS records in Birthtimespec.
+func birthTime(info os.FileInfo) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || (st.Birthtimespec.Sec == 0 && st.Birthtimespec.Nsec == 0) {
+		return time.Time{}, false
+	}
+	return time.Unix(st.Birthtimespec.Unix()), true
+}
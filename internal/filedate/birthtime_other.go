@@ -0,0 +1,14 @@
+//go:build !darwin
+
+package filedate
+
+import (
+	"os"
+	"time"
+)
+
+// birthTime reports no creation time; outside macOS, os.Stat does not
+// expose one portably, so callers fall back to the modification time.
+func birthTime(os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
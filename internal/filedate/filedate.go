@@ -0,0 +1,114 @@
+// Package filedate finds the date an image was taken, for bucketing images
+// chronologically. It prefers the EXIF capture time, then the file's
+// creation time where the platform records one, then its modification time.
+// Implausible dates, such as 1970 from a camera with a dead clock battery,
+// are passed over for the next source.
+package filedate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/exif"
+)
+
+// Source records which timestamp a date came from.
+type Source string
+
+const (
+	SourceExif     Source = "exif"
+	SourceCreated  Source = "created"
+	SourceModified Source = "modified"
+
+	// SourceToday means no source had a plausible date, so the current
+	// time was used.
+	SourceToday Source = "today"
+)
+
+// DefaultFloor is the earliest date accepted when Options.Floor is zero.
+var DefaultFloor = time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// futureSlack is how far past now a date may lie, to allow for time zones
+// and slightly fast camera clocks.
+const futureSlack = 24 * time.Hour
+
+// ErrImplausible is returned (wrapped) by Resolve in strict mode for a file
+// with an implausible date.
+var ErrImplausible = errors.New("implausible date")
+
+// now is replaced in tests.
+var now = time.Now
+
+// Options controls Resolve.
+type Options struct {
+	// Floor is the earliest plausible date. Zero means DefaultFloor.
+	Floor time.Time
+
+	// Strict fails with ErrImplausible as soon as a source gives an
+	// implausible date, so callers can set such files aside, instead of
+	// falling back to the next source.
+	Strict bool
+}
+
+// Result is a date found by Resolve. Implausible is set when a source was
+// passed over because its date was before the floor or in the future.
+type Result struct {
+	Time        time.Time
+	Source      Source
+	Implausible bool
+}
+
+// Date returns the best available date for the image at path and where it
+// came from, using the default Options. Unreadable EXIF is not an error;
+// the file's own timestamps are used instead.
+func Date(path string) (time.Time, Source, error) {
+	r, err := Resolve(path, Options{})
+	return r.Time, r.Source, err
+}
+
+// Resolve finds the date for the image at path, trying EXIF, then the
+// creation time, then the modification time, and finally today. Each date
+// must fall between opts.Floor and a day from now to be used.
+func Resolve(path string, opts Options) (Result, error) {
+	floor := opts.Floor
+	if floor.IsZero() {
+		floor = DefaultFloor
+	}
+	latest := now().Add(futureSlack)
+
+	var r Result
+	accept := func(t time.Time, src Source) (bool, error) {
+		if !t.Before(floor) && !t.After(latest) {
+			r.Time, r.Source = t, src
+			return true, nil
+		}
+		if opts.Strict {
+			return false, fmt.Errorf("%w for %s: %s from %s", ErrImplausible, path, t.Format(time.DateOnly), src)
+		}
+		r.Implausible = true
+		return false, nil
+	}
+
+	if t, ok, err := exif.ReadDateTaken(path); err == nil && ok {
+		if ok, err := accept(t, SourceExif); ok || err != nil {
+			return r, err
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Result{}, err
+	}
+	if t, ok := birthTime(info); ok {
+		if ok, err := accept(t, SourceCreated); ok || err != nil {
+			return r, err
+		}
+	}
+	if ok, err := accept(info.ModTime(), SourceModified); ok || err != nil {
+		return r, err
+	}
+	r.Time, r.Source = now(), SourceToday
+	return r, nil
+}
@@ -0,0 +1,29 @@
+package filedate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDateBirthtime(t *testing.T) {
+	before := time.Now().Add(-time.Minute)
+	path := filepath.Join(t.TempDir(), "screenshot.png")
+	if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Backdating the mtime leaves the creation time alone.
+	mtime := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	got, src, err := Date(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != SourceCreated || got.Before(before) {
+		t.Errorf("got %v from %s, want the creation time (after %v)", got, src, before)
+	}
+}
@@ -0,0 +1,156 @@
+package filedate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// writeExifJPEG writes the start of a JPEG whose Exif block records date as
+// DateTimeOriginal. It holds no image data; only the metadata is read.
+func writeExifJPEG(t *testing.T, date string) string {
+	t.Helper()
+	var tiff bytes.Buffer
+	put := func(vs ...any) {
+		for _, v := range vs {
+			binary.Write(&tiff, binary.LittleEndian, v)
+		}
+	}
+	tiff.WriteString("II")
+	put(uint16(42), uint32(8))
+	// IFD0 points at the Exif sub-IFD at offset 26.
+	put(uint16(1), uint16(0x8769), uint16(4), uint32(1), uint32(26), uint32(0))
+	s := date + "\x00"
+	put(uint16(1), uint16(0x9003), uint16(2), uint32(len(s)), uint32(44), uint32(0))
+	tiff.WriteString(s)
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write([]byte{0xFF, 0xD9})
+
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDateExif(t *testing.T) {
+	path := writeExifJPEG(t, "2019:12:25 08:00:00")
+	old := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	got, src, err := Date(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2019, 12, 25, 8, 0, 0, 0, time.Local); src != SourceExif || !got.Equal(want) {
+		t.Errorf("got %v from %s, want %v from exif", got, src, want)
+	}
+}
+
+func TestDateModifiedFallback(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("macOS records a creation time, which takes precedence")
+	}
+	path := filepath.Join(t.TempDir(), "download.png")
+	if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	got, src, err := Date(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != SourceModified || !got.Equal(mtime) {
+		t.Errorf("got %v from %s, want %v from modified", got, src, mtime)
+	}
+}
+
+func TestDateMissingFile(t *testing.T) {
+	if _, _, err := Date(filepath.Join(t.TempDir(), "gone.jpg")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestResolveImplausibleDates(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("macOS records a creation time, which sits between EXIF and the modification time")
+	}
+	today := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return today }
+
+	// withMtime writes an image with the given EXIF date (none if empty)
+	// and modification time.
+	withMtime := func(exifDate string, mtime time.Time) string {
+		path := filepath.Join(t.TempDir(), "photo.jpg")
+		if exifDate != "" {
+			path = writeExifJPEG(t, exifDate)
+		} else if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	epoch := time.Unix(0, 0)
+
+	tests := []struct {
+		name            string
+		path            string
+		opts            Options
+		wantSource      Source
+		wantTime        time.Time
+		wantImplausible bool
+	}{
+		{"exif plausible", withMtime("2019:12:25 08:00:00", good), Options{}, SourceExif,
+			time.Date(2019, 12, 25, 8, 0, 0, 0, time.Local), false},
+		{"exif 1970 falls back to mtime", withMtime("1970:01:01 00:00:00", good), Options{}, SourceModified, good, true},
+		{"future exif falls back to mtime", withMtime("2031:05:05 10:00:00", good), Options{}, SourceModified, good, true},
+		{"exif within a day of now is kept", withMtime("2024:06:02 08:00:00", good), Options{}, SourceExif,
+			time.Date(2024, 6, 2, 8, 0, 0, 0, time.Local), false},
+		{"exif below a custom floor", withMtime("1995:07:01 09:00:00", good), Options{Floor: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+			SourceModified, good, true},
+		{"mtime 1970 falls back to today", withMtime("", epoch), Options{}, SourceToday, today, true},
+		{"both implausible falls back to today", withMtime("1970:01:01 00:00:00", epoch), Options{}, SourceToday, today, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Resolve(tt.path, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Source != tt.wantSource || !r.Time.Equal(tt.wantTime) || r.Implausible != tt.wantImplausible {
+				t.Errorf("got %v from %s (implausible=%v), want %v from %s (implausible=%v)",
+					r.Time, r.Source, r.Implausible, tt.wantTime, tt.wantSource, tt.wantImplausible)
+			}
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		if _, err := Resolve(withMtime("1970:01:01 00:00:00", good), Options{Strict: true}); !errors.Is(err, ErrImplausible) {
+			t.Errorf("expected ErrImplausible, got %v", err)
+		}
+		r, err := Resolve(withMtime("2019:12:25 08:00:00", epoch), Options{Strict: true})
+		if err != nil || r.Source != SourceExif {
+			t.Errorf("a plausible EXIF date should be used before the bad mtime is consulted, got %+v, %v", r, err)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+// Package firstrun tells whether imgsort is being used for the first time
+// on this machine, so the first sort can preview its moves instead of
+// making them.
+package firstrun
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+	"github.com/bagtoad/imgsort/internal/state"
+)
+
+// markerName is the file in the state directory recording that the first
+// run has happened.
+const markerName = "first-run-done"
+
+// Path returns the marker file's path.
+func Path() (string, error) {
+	dir, err := state.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, markerName), nil
+}
+
+// Pending reports whether this is the first run: no earlier run left the
+// marker, and the user has not set up a custom categories file, which
+// would show they have used imgsort before. A state directory the marker
+// cannot be written to never has a first run, since every run would
+// otherwise be one.
+func Pending() (bool, error) {
+	path, err := Path()
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, fmt.Errorf("cannot check first-run marker: %w", err)
+	}
+	custom, err := categories.CustomFile()
+	if err != nil {
+		return false, err
+	}
+	return custom == "" && writable(filepath.Dir(path)), nil
+}
+
+// writable reports whether files can be created in dir, creating it if
+// needed.
+func writable(dir string) bool {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false
+	}
+	f, err := os.CreateTemp(dir, markerName+"-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// Mark records that the first run has happened, so Pending is false from
+// now on.
+func Mark() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("cannot write first-run marker: %w", err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return fmt.Errorf("cannot write first-run marker: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package firstrun
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
+)
+
+func TestPendingUntilMarked(t *testing.T) {
+	t.Setenv(appdir.Env, filepath.Join(t.TempDir(), "imgsort"))
+
+	for i := 0; i < 2; i++ {
+		if pending, err := Pending(); err != nil || !pending {
+			t.Fatalf("Pending() = %v, %v before the first run; want true", pending, err)
+		}
+	}
+	if err := Mark(); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := Pending(); err != nil || pending {
+		t.Errorf("Pending() = %v, %v after Mark; want false", pending, err)
+	}
+	// Marking again is harmless.
+	if err := Mark(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPendingWithCustomCategories(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(appdir.Env, dir)
+	if err := os.WriteFile(filepath.Join(dir, "categories.txt"), []byte("dog\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err := Pending(); err != nil || pending {
+		t.Errorf("Pending() = %v, %v with a categories file; want false", pending, err)
+	}
+}
+
+func TestPendingUnwritableState(t *testing.T) {
+	home := t.TempDir()
+	if err := os.Chmod(home, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(home, 0755) })
+	if f, err := os.CreateTemp(home, "probe"); err == nil {
+		f.Close()
+		os.Remove(f.Name())
+		t.Skip("permissions are not enforced for this user")
+	}
+	t.Setenv(appdir.Env, home)
+
+	if pending, err := Pending(); err != nil || pending {
+		t.Errorf("Pending() = %v, %v with an unwritable state directory; want false", pending, err)
+	}
+}
@@ -0,0 +1,25 @@
+// Package junk recognizes the metadata files operating systems leave in
+// folders, such as .DS_Store and Thumbs.db. imgsort treats them as noise:
+// they are not counted as skipped files and do not keep a folder from being
+// empty.
+package junk
+
+import (
+	"slices"
+	"strings"
+)
+
+// Names are the junk file names, matched case-insensitively.
+var Names = []string{".DS_Store", "Thumbs.db", "desktop.ini"}
+
+// appleDoublePrefix starts the "._name" files macOS writes on filesystems
+// without extended attributes.
+const appleDoublePrefix = "._"
+
+// Is reports whether a file name is junk.
+func Is(name string) bool {
+	if strings.HasPrefix(name, appleDoublePrefix) {
+		return true
+	}
+	return slices.ContainsFunc(Names, func(n string) bool { return strings.EqualFold(n, name) })
+}
@@ -0,0 +1,21 @@
+package junk
+
+import "testing"
+
+func TestIs(t *testing.T) {
+	for name, want := range map[string]bool{
+		".DS_Store":    true,
+		"Thumbs.db":    true,
+		"thumbs.db":    true,
+		"desktop.ini":  true,
+		"._IMG_01.jpg": true,
+		"IMG_01.jpg":   false,
+		".hidden":      false,
+		"notes.txt":    false,
+		"_DS_Store":    false,
+	} {
+		if got := Is(name); got != want {
+			t.Errorf("Is(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
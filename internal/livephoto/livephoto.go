@@ -0,0 +1,79 @@
+// Package livephoto recognizes photos that carry a short video: iPhone Live
+// Photos, stored as a still plus a .MOV with the same name, and Motion
+// Photos, which embed the video in the JPEG itself.
+package livephoto
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CompanionExts are the video extensions paired with a still of the same name.
+var CompanionExts = map[string]bool{
+	".mov": true,
+}
+
+// motionMarkers are XMP properties that flag a JPEG with an embedded video.
+var motionMarkers = [][]byte{
+	[]byte(`MotionPhoto="1"`),
+	[]byte(`MotionPhoto>1<`),
+	[]byte(`MicroVideo="1"`),
+	[]byte(`MicroVideo>1<`),
+}
+
+// headerSize is how much of a JPEG is searched for the XMP markers. XMP
+// lives in an APP1 segment near the start of the file.
+const headerSize = 128 << 10
+
+// Detect finds the live items among images. videos are the candidate
+// companion files found alongside them. The result maps each live image to
+// its companion files, which is empty for a Motion Photo.
+func Detect(images, videos []string) map[string][]string {
+	byStem := make(map[string][]string, len(videos))
+	for _, v := range videos {
+		byStem[stemKey(v)] = append(byStem[stemKey(v)], v)
+	}
+
+	live := make(map[string][]string)
+	for _, img := range images {
+		if companions, ok := byStem[stemKey(img)]; ok {
+			live[img] = companions
+			delete(byStem, stemKey(img))
+			continue
+		}
+		if ext := strings.ToLower(filepath.Ext(img)); (ext == ".jpg" || ext == ".jpeg") && IsMotionPhoto(img) {
+			live[img] = []string{}
+		}
+	}
+	return live
+}
+
+// IsMotionPhoto reports whether the JPEG at path declares an embedded video
+// in its XMP metadata.
+func IsMotionPhoto(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head, err := io.ReadAll(io.LimitReader(f, headerSize))
+	if err != nil {
+		return false
+	}
+	for _, m := range motionMarkers {
+		if bytes.Contains(head, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// stemKey identifies a file by its directory and case-folded name without
+// extension, so IMG_1234.JPG and IMG_1234.mov match.
+func stemKey(path string) string {
+	return strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path)))
+}
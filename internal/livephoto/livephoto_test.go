@@ -0,0 +1,40 @@
+package livephoto
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	pairStill := write("IMG_1234.JPG", "still")
+	pairVideo := write("IMG_1234.mov", "video")
+	motion := write("PXL_0001.jpg", "\xff\xd8<x:xmpmeta><rdf:Description GCamera:MotionPhoto=\"1\"/></x:xmpmeta>")
+	plain := write("beach.jpg", "\xff\xd8no xmp here")
+	orphan := write("clip.mov", "video")
+
+	live := Detect([]string{pairStill, motion, plain}, []string{pairVideo, orphan})
+
+	if got := live[pairStill]; !slices.Equal(got, []string{pairVideo}) {
+		t.Errorf("Live Photo companions = %v, want [%s]", got, pairVideo)
+	}
+	if got, ok := live[motion]; !ok || len(got) != 0 {
+		t.Errorf("Motion Photo should be live with no companions, got %v, %v", got, ok)
+	}
+	if _, ok := live[plain]; ok {
+		t.Error("a plain JPEG should not be live")
+	}
+	if len(live) != 2 {
+		t.Errorf("expected 2 live items, got %v", live)
+	}
+}
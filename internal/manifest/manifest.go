@@ -0,0 +1,115 @@
+// Package manifest records which subfolders of a sorted directory imgsort
+// created, so later scans can tell its output apart from unsorted input.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// FileName is the manifest kept in the root of each sorted directory.
+const FileName = ".imgsort-meta.json"
+
+// version is the manifest format written by this release.
+const version = 1
+
+// Manifest lists the folders imgsort manages inside a directory.
+type Manifest struct {
+	Version int `json:"version"`
+
+	// Managed holds folder names relative to the directory, sorted.
+	Managed []string `json:"managed"`
+}
+
+// Load reads the manifest in dir. It returns nil without error when the
+// directory has none.
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", FileName, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse %s in %s: %w", FileName, dir, err)
+	}
+	if m.Version > version {
+		return nil, fmt.Errorf("%s in %s was written by a newer imgsort (version %d)", FileName, dir, m.Version)
+	}
+	slices.Sort(m.Managed)
+	return &m, nil
+}
+
+// IsManaged reports whether the folder name was created by imgsort. It is
+// safe to call on a nil manifest.
+func (m *Manifest) IsManaged(name string) bool {
+	if m == nil {
+		return false
+	}
+	_, found := slices.BinarySearch(m.Managed, name)
+	return found
+}
+
+// Record adds folders to the manifest in dir, creating it if needed.
+func Record(dir string, folders ...string) error {
+	m, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = &Manifest{}
+	}
+	m.Version = version
+
+	before := len(m.Managed)
+	for _, f := range folders {
+		if f != "" && !m.IsManaged(f) {
+			m.Managed = append(m.Managed, f)
+			slices.Sort(m.Managed)
+		}
+	}
+	if len(m.Managed) == before && before > 0 {
+		return nil
+	}
+	return write(dir, m)
+}
+
+// Forget removes folders from the manifest in dir, for example after they
+// were deleted. It does nothing if dir has no manifest.
+func Forget(dir string, folders ...string) error {
+	m, err := Load(dir)
+	if err != nil || m == nil {
+		return err
+	}
+	before := len(m.Managed)
+	m.Managed = slices.DeleteFunc(m.Managed, func(f string) bool { return slices.Contains(folders, f) })
+	if len(m.Managed) == before {
+		return nil
+	}
+	m.Version = version
+	return write(dir, m)
+}
+
+// write replaces the manifest in dir with m.
+func write(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, FileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", FileName, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cannot write %s: %w", FileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := Load(dir)
+	if err != nil || m != nil {
+		t.Fatalf("missing manifest should load as nil, got %v, %v", m, err)
+	}
+	if m.IsManaged("food") {
+		t.Error("nil manifest should manage nothing")
+	}
+
+	if err := Record(dir, "food", "beach"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(dir, "city", "food"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.Managed, []string{"beach", "city", "food"}) {
+		t.Errorf("managed = %v", m.Managed)
+	}
+	if !m.IsManaged("city") || m.IsManaged("dog") {
+		t.Error("IsManaged disagrees with the recorded folders")
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(`{"version": 99, "managed": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("expected error for a manifest from a newer release")
+	}
+}
+
+func TestForget(t *testing.T) {
+	dir := t.TempDir()
+	if err := Forget(dir, "food"); err != nil {
+		t.Fatalf("forgetting without a manifest should do nothing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, FileName)); !os.IsNotExist(err) {
+		t.Error("Forget should not create a manifest")
+	}
+
+	if err := Record(dir, "food", "beach", "city"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Forget(dir, "food", "dog"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.Managed, []string{"beach", "city"}) {
+		t.Errorf("managed = %v", m.Managed)
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"slices"
+	"strings"
+)
+
+// calibrateBelow is the number of categories below which Classify adds the
+// distractor prompts. A lone category against the baseline is a two-way
+// softmax, which saturates whenever the category's prompt is even slightly
+// closer to the image than "a photo", so a dark or blank image can score
+// 99% for anything.
+const calibrateBelow = 2
+
+// distractorPrompts describe the kinds of image that are not a photo of
+// anything in particular, which a lone category otherwise wins by default.
+// They avoid photo subjects such as people or the outdoors, which would
+// compete with real categories like "wedding" or "beach".
+var distractorPrompts = []string{
+	"a dark photo",
+	"a blurry photo",
+	"an overexposed photo",
+	"a blank image",
+	"a screenshot",
+	"a photo of text",
+	"a photo of a document",
+	"an abstract pattern",
+}
+
+// Calibrates reports whether classifying against categories with opts adds
+// the distractor prompts: when fewer than calibrateBelow categories are
+// given and the baseline is in use. Calibration keeps a single category from
+// trivially winning the softmax. Each distractor is scored as its own label
+// and its share folded into the baseline score afterwards, so the scores
+// returned still hold only the baseline and the categories and still sum
+// to 1; a real match beats every distractor and keeps most of its score,
+// while an image that matches nothing spreads its score across them.
+func Calibrates(categories []string, opts ClassifyOptions) bool {
+	return !opts.NoBaseline && len(categories) > 0 && len(categories) < calibrateBelow
+}
+
+// distractors returns the distractor prompts that do not describe one of
+// categories, so that calibrating "document" does not pit it against
+// "a photo of a document".
+func distractors(categories []string) []string {
+	var out []string
+	for _, p := range distractorPrompts {
+		if !slices.ContainsFunc(categories, func(cat string) bool { return sharesWord(p, cat) }) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// promptFiller lists words that say nothing about a distractor's subject,
+// which are ignored when comparing it with categories.
+var promptFiller = map[string]bool{"a": true, "an": true, "the": true, "of": true, "photo": true}
+
+// sharesWord reports whether prompt and category have a word in common,
+// ignoring case, filler words, and a plural "s".
+func sharesWord(prompt, category string) bool {
+	inPrompt := make(map[string]bool)
+	for _, w := range words(prompt) {
+		if !promptFiller[w] {
+			inPrompt[w] = true
+		}
+	}
+	return slices.ContainsFunc(words(category), func(w string) bool { return inPrompt[w] })
+}
+
+// words splits s into lowercase words without a trailing "s".
+func words(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	})
+	for i, f := range fields {
+		fields[i] = strings.TrimSuffix(f, "s")
+	}
+	return fields
+}
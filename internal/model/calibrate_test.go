@@ -0,0 +1,105 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+// calibratedLogits returns logits for in: base for the baseline, cat for the
+// category, and other for every distractor except dark, which gets darkLogit.
+func calibratedLogits(in *modelInput, base, cat, other, darkLogit float32) []float32 {
+	logits := []float32{base, cat}
+	for _, label := range in.labels[2:] {
+		if label == "a dark photo" {
+			logits = append(logits, darkLogit)
+		} else {
+			logits = append(logits, other)
+		}
+	}
+	return logits
+}
+
+// TestCalibrationSingleCategory scores a dark image whose "a photo of cat"
+// prompt edges out the baseline, which a two-way softmax turns into near
+// certainty.
+func TestCalibrationSingleCategory(t *testing.T) {
+	tok, img := testTokenizer(t), testImage(t)
+	in, err := prepareInput(tok, img, []string{"cat"}, ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2 + len(distractorPrompts); len(in.labels) != want || len(in.owners) != want || in.distractors != len(distractorPrompts) {
+		t.Fatalf("expected %d labels with distractors, got %v", want, in.labels)
+	}
+
+	uncalibrated, err := scoresFromLogits([]string{BaselineCategory, "cat"}, []float32{20, 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uncalibrated["cat"] < 0.99 {
+		t.Fatalf("expected the two-way softmax to saturate, got %v", uncalibrated)
+	}
+
+	scores, err := in.scores(calibratedLogits(in, 20, 25, 18, 27), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 {
+		t.Fatalf("expected distractors to be folded into the baseline, got %v", scores)
+	}
+	if scores["cat"] > 0.2 || scores["cat"]+scores[BaselineCategory] < 0.999 {
+		t.Errorf("expected the dark image to score low for cat, got %v", scores)
+	}
+
+	// An actual cat still beats every distractor by a wide margin.
+	scores, err = in.scores(calibratedLogits(in, 20, 30, 18, 19), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores["cat"] < 0.95 {
+		t.Errorf("expected a clear match to keep its score, got %v", scores)
+	}
+}
+
+func TestCalibrates(t *testing.T) {
+	for _, tc := range []struct {
+		cats []string
+		opts ClassifyOptions
+		want bool
+	}{
+		{[]string{"cat"}, ClassifyOptions{}, true},
+		{[]string{"cat", "dog"}, ClassifyOptions{}, false},
+		{[]string{"cat"}, ClassifyOptions{NoBaseline: true}, false},
+		{nil, ClassifyOptions{}, false},
+	} {
+		if got := Calibrates(tc.cats, tc.opts); got != tc.want {
+			t.Errorf("Calibrates(%v, %+v) = %v, want %v", tc.cats, tc.opts, got, tc.want)
+		}
+	}
+
+	tok, img := testTokenizer(t), testImage(t)
+	in, err := prepareInput(tok, img, []string{"cat", "dog"}, ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.distractors != 0 || len(in.labels) != 3 {
+		t.Errorf("expected no distractors for two categories, got %v", in.labels)
+	}
+}
+
+func TestDistractorsSkipCategorySubjects(t *testing.T) {
+	for cat, dropped := range map[string]string{
+		"documents":     "a photo of a document",
+		"Screenshots":   "a screenshot",
+		"text_messages": "a photo of text",
+		"abstract-art":  "an abstract pattern",
+	} {
+		got := distractors([]string{cat})
+		if slices.Contains(got, dropped) || len(got) != len(distractorPrompts)-1 {
+			t.Errorf("%s: expected only %q to be dropped, got %v", cat, dropped, got)
+		}
+	}
+	if got := distractors([]string{"cat"}); !slices.Equal(got, distractorPrompts) {
+		t.Errorf("expected every distractor for cat, got %v", got)
+	}
+}
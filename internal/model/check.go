@@ -0,0 +1,65 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// checkNotHTML fails for a file that is an HTML page, as captive portals
+// and proxies serve in place of the requested file.
+func checkNotHTML(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if strings.HasPrefix(http.DetectContentType(head[:n]), "text/html") {
+		return fmt.Errorf("server returned an HTML page instead of the file (a captive portal or proxy error?)")
+	}
+	return nil
+}
+
+// checkVocab fails unless path holds a tokenizer vocabulary: a JSON object
+// mapping tokens to IDs.
+func checkVocab(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var vocab map[string]int
+	if err := json.Unmarshal(data, &vocab); err != nil {
+		return fmt.Errorf("not a valid vocabulary: %w", err)
+	}
+	if len(vocab) == 0 {
+		return fmt.Errorf("not a valid vocabulary: no tokens")
+	}
+	return nil
+}
+
+// checkMerges fails unless path starts with the "#version" header of a BPE
+// merges file.
+func checkMerges(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if !strings.HasPrefix(line, "#version") {
+		return fmt.Errorf("not a valid merges file: missing #version header")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package model_test
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// randomLogits returns n CLIP-like logits (logit_scale * cosine similarity)
+// and the labels they belong to, baseline first.
+func randomLogits(rng *rand.Rand, n int) ([]string, []float32) {
+	labels := []string{model.BaselineCategory}
+	for i := 1; i < n; i++ {
+		labels = append(labels, fmt.Sprintf("cat%03d", i))
+	}
+	logits := make([]float32, n)
+	for i := range logits {
+		logits[i] = 15 + rng.Float32()*20
+	}
+	return labels, logits
+}
+
+// chunkedScores scores logits as ClassifyWithOptions does with the given
+// chunk size, serving each chunk from the fixed logits.
+func chunkedScores(t *testing.T, labels []string, logits []float32, size int) map[string]float32 {
+	t.Helper()
+	var calls int
+	got, err := model.ChunkedLogits(len(logits), size, func(lo, hi int) ([]float32, error) {
+		calls++
+		if size > 0 && hi-lo > size {
+			t.Fatalf("chunk [%d,%d) exceeds size %d", lo, hi, size)
+		}
+		return logits[lo:hi], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size > 0 && calls != (len(logits)+size-1)/size {
+		t.Fatalf("size %d: expected %d chunks, got %d", size, (len(logits)+size-1)/size, calls)
+	}
+	scores, err := model.ScoresFromLogits(labels, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scores
+}
+
+// TestChunkedScoresMatchUnchunked checks that for fixed logits, every chunk
+// size yields exactly the probabilities and skip decisions of a single run,
+// including the baseline comparison.
+func TestChunkedScoresMatchUnchunked(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := range 50 {
+		n := 2 + rng.Intn(600)
+		labels, logits := randomLogits(rng, n)
+		if trial%2 == 0 {
+			// Make the baseline win so skips are exercised too.
+			logits[0] = 40
+		}
+
+		want, err := model.ScoresFromLogits(labels, logits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		threshold := rng.Float64() * 0.05
+		wantDecision := categorizer.DecideOne(categorizer.ImageScores{Path: "img.jpg", Scores: want},
+			categorizer.Options{Threshold: threshold, Quiet: true})
+
+		for _, size := range []int{0, 1, 2, 3, 7, 16, 64, n - 1, n, n + 1, 1 + rng.Intn(n)} {
+			got := chunkedScores(t, labels, logits, size)
+			if !maps.Equal(got, want) {
+				t.Fatalf("trial %d, %d labels, chunk size %d: probabilities differ", trial, n, size)
+			}
+			decision := categorizer.DecideOne(categorizer.ImageScores{Path: "img.jpg", Scores: got},
+				categorizer.Options{Threshold: threshold, Quiet: true})
+			if !reflect.DeepEqual(decision, wantDecision) {
+				t.Fatalf("trial %d, chunk size %d: decision %+v, want %+v", trial, size, decision, wantDecision)
+			}
+		}
+	}
+}
+
+func TestChunkedLogitsShortChunk(t *testing.T) {
+	_, err := model.ChunkedLogits(5, 2, func(lo, hi int) ([]float32, error) {
+		return make([]float32, 1), nil
+	})
+	if err == nil {
+		t.Error("expected an error when a chunk returns too few logits")
+	}
+}
@@ -2,62 +2,100 @@ package model
 
 import (
 	"fmt"
+	"image"
+	"io"
 	"math"
 	"runtime"
 
-	"github.com/bagtoad/imgsort/internal/onnxlib"
 	ort "github.com/yalue/onnxruntime_go"
 )
 
-// CLIPSession holds a loaded CLIP model ready for inference.
+// CLIPSession holds a loaded CLIP model ready for inference. It is safe for
+// concurrent use.
 type CLIPSession struct {
 	session   *ort.DynamicAdvancedSession
 	tokenizer *Tokenizer
+	nodes     nodeNames
+	runtime   *RuntimeInfo
+	prompts   promptCache
+	tensors   tensorPool
 }
 
 // NewCLIPSession creates a new CLIP inference session.
 // If explicitPath is empty, it tries the embedded library first, then platform defaults.
 func NewCLIPSession(explicitPath string) (*CLIPSession, error) {
-	var onnxrtLibPath string
-	if explicitPath != "" {
-		onnxrtLibPath = explicitPath
-	} else if extractedPath, err := onnxlib.Extract(); err == nil {
-		onnxrtLibPath = extractedPath
-	} else {
-		onnxrtLibPath = defaultONNXRuntimePath()
-	}
-	ort.SetSharedLibraryPath(onnxrtLibPath)
-	if err := ort.InitializeEnvironment(); err != nil {
-		return nil, fmt.Errorf("cannot initialize ONNX Runtime: %w", err)
+	runtimeInfo, err := LoadRuntime(explicitPath)
+	if err != nil {
+		return nil, err
 	}
 
 	modelPath, err := FilePath("model.onnx")
 	if err != nil {
+		ort.DestroyEnvironment()
+		return nil, err
+	}
+
+	nodes, err := modelNodeNames(modelPath)
+	if err != nil {
+		ort.DestroyEnvironment()
 		return nil, err
 	}
 
 	session, err := ort.NewDynamicAdvancedSession(
 		modelPath,
-		[]string{"input_ids", "pixel_values", "attention_mask"},
-		[]string{"logits_per_image", "logits_per_text"},
+		nodes.inputNames(),
+		nodes.outputNames(),
 		nil,
 	)
 	if err != nil {
+		ort.DestroyEnvironment()
 		return nil, fmt.Errorf("cannot create ONNX session: %w", err)
 	}
 
 	tokenizer, err := TokenizerFromModelsDir()
 	if err != nil {
 		session.Destroy()
+		ort.DestroyEnvironment()
 		return nil, fmt.Errorf("cannot load tokenizer: %w", err)
 	}
 
 	return &CLIPSession{
 		session:   session,
 		tokenizer: tokenizer,
+		nodes:     nodes,
+		runtime:   runtimeInfo,
 	}, nil
 }
 
+// Runtime returns information about the loaded ONNX Runtime library.
+func (c *CLIPSession) Runtime() *RuntimeInfo {
+	return c.runtime
+}
+
+// modelNodeNames reads the model's input and output metadata and maps the
+// node names to the roles Classify needs.
+func modelNodeNames(modelPath string) (nodeNames, error) {
+	inputInfo, outputInfo, err := ort.GetInputOutputInfo(modelPath)
+	if err != nil {
+		return nodeNames{}, fmt.Errorf("cannot read model metadata: %w", err)
+	}
+
+	inputs := make([]string, len(inputInfo))
+	for i, info := range inputInfo {
+		inputs[i] = info.Name
+	}
+	outputs := make([]string, len(outputInfo))
+	for i, info := range outputInfo {
+		outputs[i] = info.Name
+	}
+
+	nodes, err := resolveNodeNames(inputs, outputs)
+	if err != nil {
+		return nodeNames{}, fmt.Errorf("%s: %w", modelPath, err)
+	}
+	return nodes, nil
+}
+
 // BaselineCategory is the internal label for the baseline "catch-all" prompt
 // used to prevent false-positive classification.
 const BaselineCategory = "uncategorized"
@@ -66,90 +104,179 @@ const BaselineCategory = "uncategorized"
 // If an image is more similar to this than any specific category, it's skipped.
 const baselinePrompt = "a photo"
 
+// ClassifyOptions customizes the prompts Classify sends to the model.
+type ClassifyOptions struct {
+	// Prompts overrides the prompt text for individual categories.
+	// Categories without an entry use "a photo of {category}".
+	Prompts map[string]string
+
+	// NoBaseline omits the baseline prompt. Only use this when the categories
+	// are exhaustive, since nothing then guards against false positives.
+	NoBaseline bool
+
+	// Preprocess controls how the image is prepared, including how images
+	// smaller than the model input are enlarged.
+	Preprocess PreprocessOptions
+
+	// Expansions lists extra terms per category, each scored as
+	// "a photo of {term}" alongside the category's own prompt. The logits of
+	// a category's prompts are pooled with Pool before the softmax.
+	Expansions map[string][]string
+
+	// ExtraPrompts lists further prompts per category, used verbatim and
+	// pooled with Pool like expansion terms.
+	ExtraPrompts map[string][]string
+
+	// Pool combines the logits of a category's prompts. The default is
+	// PoolMax.
+	Pool PoolMode
+
+	// ChunkSize limits how many prompts are sent to the model in one run, for
+	// very long category lists. The raw logits of every chunk are collected
+	// before a single softmax, so scores do not depend on the chunk size.
+	// Zero sends every prompt at once.
+	ChunkSize int
+
+	// Trace, if set, receives a dump of what each classification sends to
+	// the model and gets back: the prompts, their token IDs, the tensor
+	// shapes, and the raw logits. Each image is written in a single Write,
+	// so concurrent classifications do not interleave.
+	Trace io.Writer
+}
+
 // Classify runs zero-shot classification on an image against the given categories.
 // A baseline "uncategorized" prompt is injected to prevent false positives
 // (especially with few categories). Returns a map of category names to their
 // similarity scores (after softmax), including the baseline.
 func (c *CLIPSession) Classify(imagePath string, categories []string) (map[string]float32, error) {
-	// Preprocess image
-	pixelValues, err := PreprocessImage(imagePath)
-	if err != nil {
-		return nil, fmt.Errorf("cannot preprocess image: %w", err)
-	}
-
-	// Build prompt list: baseline + real categories
-	allLabels := append([]string{BaselineCategory}, categories...)
-	numLabels := int64(len(allLabels))
+	return c.ClassifyWithOptions(imagePath, categories, ClassifyOptions{})
+}
 
-	// Tokenize: baseline gets the generic prompt, others get "a photo of {cat}"
-	tokenIDs := make([]int64, 0, len(allLabels)*contextLen)
-	tokenIDs = append(tokenIDs, c.tokenizer.Encode(baselinePrompt)...)
-	for _, cat := range categories {
-		prompt := fmt.Sprintf("a photo of %s", cat)
-		tokenIDs = append(tokenIDs, c.tokenizer.Encode(prompt)...)
+// ClassifyWithOptions is like Classify, with custom prompts and optional
+// omission of the baseline.
+func (c *CLIPSession) ClassifyWithOptions(imagePath string, categories []string, opts ClassifyOptions) (map[string]float32, error) {
+	in, err := prepareInputWith(c.prompts.get(c.tokenizer, categories, opts), imagePath, opts)
+	if err != nil {
+		return nil, err
 	}
+	return c.classifyInput(in, opts)
+}
 
-	// Create attention mask (1 for non-padding, 0 for padding)
-	attentionMask := make([]int64, len(tokenIDs))
-	for i, id := range tokenIDs {
-		if id != 0 {
-			attentionMask[i] = 1
-		}
+// ClassifyReader is like ClassifyWithOptions for encoded image data read
+// from r, so callers such as web services need not write it to disk first.
+func (c *CLIPSession) ClassifyReader(r io.Reader, categories []string, opts ClassifyOptions) (map[string]float32, error) {
+	pixelValues, err := PreprocessReader(r, opts.Preprocess)
+	if err != nil {
+		return nil, fmt.Errorf("cannot preprocess image: %w", err)
 	}
+	in := &modelInput{preparedPrompts: c.prompts.get(c.tokenizer, categories, opts), pixelValues: pixelValues}
+	return c.classifyInput(in, opts)
+}
 
-	// Create input tensors
-	inputIDsTensor, err := ort.NewTensor(ort.NewShape(numLabels, int64(contextLen)), tokenIDs)
+// ClassifyImage is like ClassifyWithOptions for an already decoded image.
+func (c *CLIPSession) ClassifyImage(img image.Image, categories []string, opts ClassifyOptions) (map[string]float32, error) {
+	pixelValues, err := PreprocessDecoded(img, opts.Preprocess)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create input_ids tensor: %w", err)
+		return nil, fmt.Errorf("cannot preprocess image: %w", err)
 	}
-	defer inputIDsTensor.Destroy()
+	in := &modelInput{preparedPrompts: c.prompts.get(c.tokenizer, categories, opts), pixelValues: pixelValues}
+	return c.classifyInput(in, opts)
+}
 
-	pixelTensor, err := ort.NewTensor(ort.NewShape(1, 3, int64(clipImageSize), int64(clipImageSize)), pixelValues)
+// classifyInput runs the model over prepared inputs and returns the scores.
+func (c *CLIPSession) classifyInput(in *modelInput, opts ClassifyOptions) (map[string]float32, error) {
+	logits, err := chunkedLogits(len(in.owners), opts.ChunkSize, func(lo, hi int) ([]float32, error) {
+		return c.runLogits(in, lo, hi)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("cannot create pixel_values tensor: %w", err)
+		return nil, err
+	}
+	scores, err := in.scores(logits, opts.Pool)
+	if err == nil && opts.Trace != nil {
+		in.trace(opts.Trace, logits, opts.ChunkSize, scores)
 	}
-	defer pixelTensor.Destroy()
+	return scores, err
+}
 
-	attentionTensor, err := ort.NewTensor(ort.NewShape(numLabels, int64(contextLen)), attentionMask)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create attention_mask tensor: %w", err)
+// Logits runs the model on inputs prepared elsewhere, as a RemoteRequest
+// carries them: a [1, 3, 224, 224] image tensor and one row of token IDs
+// and attention mask per prompt. It returns one raw logit per row.
+func (c *CLIPSession) Logits(pixelValues []float32, inputIDs, attentionMask [][]int64) ([]float32, error) {
+	if want := 3 * clipImageSize * clipImageSize; len(pixelValues) != want {
+		return nil, fmt.Errorf("expected %d pixel values, got %d", want, len(pixelValues))
+	}
+	if len(inputIDs) == 0 || len(attentionMask) != len(inputIDs) {
+		return nil, fmt.Errorf("expected matching input_ids and attention_mask rows, got %d and %d", len(inputIDs), len(attentionMask))
+	}
+	p := &preparedPrompts{owners: make([]int, len(inputIDs))}
+	for i := range inputIDs {
+		if len(inputIDs[i]) != contextLen || len(attentionMask[i]) != contextLen {
+			return nil, fmt.Errorf("row %d: expected %d tokens", i, contextLen)
+		}
+		p.tokenIDs = append(p.tokenIDs, inputIDs[i]...)
+		p.attentionMask = append(p.attentionMask, attentionMask[i]...)
 	}
-	defer attentionTensor.Destroy()
+	in := &modelInput{preparedPrompts: p, pixelValues: pixelValues}
+	return c.runLogits(in, 0, len(inputIDs))
+}
+
+// runLogits runs the model on the image and prompt rows lo to hi,
+// returning their raw logits.
+func (c *CLIPSession) runLogits(in *modelInput, lo, hi int) ([]float32, error) {
+	perImage, _, err := c.run(in, in.pixelValues, 1, lo, hi)
+	return perImage, err
+}
 
-	// Create output tensors
-	logitsPerImage, err := ort.NewEmptyTensor[float32](ort.NewShape(1, numLabels))
+// run runs the model on numImages preprocessed images, concatenated in
+// pixels, and prompt rows lo to hi. It returns logits_per_image, shaped
+// [numImages, hi-lo], and logits_per_text, shaped [hi-lo, numImages], or
+// nil for the latter if the model does not output it.
+func (c *CLIPSession) run(in *modelInput, pixels []float32, numImages, lo, hi int) ([]float32, []float32, error) {
+	ts, err := c.tensors.get(c.nodes, numImages, hi-lo)
 	if err != nil {
-		return nil, fmt.Errorf("cannot create output tensor: %w", err)
+		return nil, nil, err
 	}
-	defer logitsPerImage.Destroy()
 
-	logitsPerText, err := ort.NewEmptyTensor[float32](ort.NewShape(numLabels, 1))
-	if err != nil {
-		return nil, fmt.Errorf("cannot create output tensor: %w", err)
+	// Fill the reused input tensors in place.
+	copy(ts.inputIDs.GetData(), in.tokenIDs[lo*contextLen:hi*contextLen])
+	copy(ts.pixels.GetData(), pixels)
+	if ts.attentionMask != nil {
+		copy(ts.attentionMask.GetData(), in.attentionMask[lo*contextLen:hi*contextLen])
 	}
-	defer logitsPerText.Destroy()
 
 	// Run inference
-	inputs := []ort.Value{inputIDsTensor, pixelTensor, attentionTensor}
-	outputs := []ort.Value{logitsPerImage, logitsPerText}
-	if err := c.session.Run(inputs, outputs); err != nil {
-		return nil, fmt.Errorf("inference failed: %w", err)
+	if err := c.session.Run(ts.inputs, ts.outputs); err != nil {
+		// Do not reuse tensors from a failed run.
+		ts.destroy()
+		return nil, nil, fmt.Errorf("inference failed: %w", err)
 	}
 
-	// Extract logits and apply softmax over all labels (including baseline)
-	logits := logitsPerImage.GetData()
-	probs := softmax(logits)
+	// Copy the logits out before the tensors are reused.
+	perImage := append([]float32(nil), ts.logitsPerImage.GetData()...)
+	var perText []float32
+	if ts.logitsPerText != nil {
+		perText = append([]float32(nil), ts.logitsPerText.GetData()...)
+	}
+	c.tensors.put(ts)
+	return perImage, perText, nil
+}
 
-	// Return all scores including the baseline
-	result := make(map[string]float32, len(allLabels))
-	for i, label := range allLabels {
-		result[label] = probs[i]
+// Warmup runs one inference on a blank image against the baseline prompt,
+// so ONNX Runtime's one-time setup is paid up front instead of by the first
+// real image, where it skews per-image timing and the ETA. It is optional:
+// classifying a single image gains nothing from it.
+func (c *CLIPSession) Warmup() error {
+	blank := make([]float32, 3*clipImageSize*clipImageSize)
+	in := promptInput(c.tokenizer, blank, nil, ClassifyOptions{})
+	if _, err := c.runLogits(in, 0, len(in.owners)); err != nil {
+		return fmt.Errorf("model warmup failed: %w", err)
 	}
-	return result, nil
+	return nil
 }
 
 // Destroy releases resources held by the CLIP session.
 func (c *CLIPSession) Destroy() {
+	c.tensors.destroy()
 	if c.session != nil {
 		c.session.Destroy()
 	}
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveFiles serves each name in files at /name with handler.
+func serveFiles(t *testing.T, handler http.HandlerFunc, names ...string) []ModelFile {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	var files []ModelFile
+	for _, m := range RequiredFiles {
+		for _, n := range names {
+			if m.Name == n {
+				m.URL = srv.URL + "/" + n
+				files = append(files, m)
+			}
+		}
+	}
+	return files
+}
+
+func TestEnsureFilesRejectsBadDownloads(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		handler http.HandlerFunc
+		wantErr string
+		keepTmp bool // a truncated download is kept for resuming
+	}{
+		{"truncated", "model.onnx", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "1000")
+			w.Write(make([]byte, 100))
+			w.(http.Flusher).Flush()
+			// Close the connection early, as a proxy cutting it off would.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		}, "download truncated: got 100 of 1000 bytes", true},
+		{"captive portal", "model.onnx", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<!DOCTYPE html><html><body>Please log in to the hotel wifi</body></html>"))
+		}, "HTML page", false},
+		{"encoded", "merges.txt", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte("#version: 0.2\n"))
+		}, `unexpected Content-Encoding "br"`, false},
+		{"invalid vocab", "vocab.json", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Service temporarily unavailable"))
+		}, "not a valid vocabulary", false},
+		{"invalid merges", "merges.txt", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"error": "rate limited"}`))
+		}, "not a valid merges file", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := stageModels(t, nil)
+			err := EnsureFiles(serveFiles(t, tt.handler, tt.file), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			var names []string
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				names = append(names, e.Name())
+			}
+			want := 0
+			if tt.keepTmp {
+				want = 1
+				if len(names) != 1 || names[0] != tt.file+".tmp" {
+					t.Errorf("expected only %s.tmp to be kept, found %v", tt.file, names)
+				}
+			}
+			if len(names) != want {
+				t.Errorf("a bad download should leave nothing behind, found %v", names)
+			}
+		})
+	}
+}
+
+func TestEnsureFilesAcceptsTokenizer(t *testing.T) {
+	dir := stageModels(t, nil)
+	files := serveFiles(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vocab.json":
+			w.Write([]byte(`{"<|startoftext|>": 1, "<|endoftext|>": 2}`))
+		case "/merges.txt":
+			w.Write([]byte("#version: 0.2\nh e\n"))
+		}
+	}, "vocab.json", "merges.txt")
+
+	if err := EnsureFiles(files, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTokenizer(filepath.Join(dir, "vocab.json"), filepath.Join(dir, "merges.txt")); err != nil {
+		t.Errorf("downloaded tokenizer does not load: %v", err)
+	}
+}
+
+func TestEnsureFilesResumesTruncatedDownload(t *testing.T) {
+	dir := stageModels(t, nil)
+	content := []byte("#version: 0.2\n" + strings.Repeat("h e\n", 500))
+	var ranges []string
+	files := serveFiles(t, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) == 1 {
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			w.Write(content[:100])
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		http.ServeContent(w, r, "merges.txt", time.Time{}, bytes.NewReader(content))
+	}, "merges.txt")
+	sum := sha256.Sum256(content)
+	files[0].SHA256 = hex.EncodeToString(sum[:])
+
+	if err := EnsureFiles(files, nil); err == nil {
+		t.Fatal("expected the first download to be truncated")
+	}
+	var last int64
+	if err := EnsureFiles(files, func(_ string, downloaded, total int64) {
+		if total != int64(len(content)) {
+			t.Errorf("progress total = %d, want %d", total, len(content))
+		}
+		last = downloaded
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ranges) != 2 || ranges[1] != "bytes=100-" {
+		t.Errorf("requests sent Range headers %q, want a second one resuming at byte 100", ranges)
+	}
+	if last != int64(len(content)) {
+		t.Errorf("progress ended at %d, want %d", last, len(content))
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "merges.txt"))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("resumed file differs from the served one (err %v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "merges.txt.tmp")); !os.IsNotExist(err) {
+		t.Errorf("the .tmp file should be renamed into place, stat err %v", err)
+	}
+}
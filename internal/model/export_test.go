@@ -0,0 +1,8 @@
+package model
+
+// Exported for the tests in package model_test, which also need the
+// categorizer and so cannot live in package model.
+var (
+	ChunkedLogits    = chunkedLogits
+	ScoresFromLogits = scoresFromLogits
+)
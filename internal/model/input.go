@@ -0,0 +1,225 @@
+package model
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// preparedPrompts holds the tokenized prompts for a list of labels, which
+// are the same for every image classified against the same categories and
+// options. Each label has one or more prompts; owners[i] is the index in
+// labels of the label prompt i belongs to. tokenIDs and attentionMask are
+// [len(owners), contextLen] row-major. The last distractors labels are
+// calibration prompts, folded into the baseline once scored. prompts are
+// kept for tracing. A preparedPrompts is never modified once built, so
+// concurrent classifications can share it.
+type preparedPrompts struct {
+	labels        []string
+	owners        []int
+	prompts       []string
+	distractors   int
+	tokenIDs      []int64
+	attentionMask []int64
+}
+
+// modelInput holds the prepared inputs for classifying one image: the
+// prompts, and pixelValues in [1, 3, 224, 224] CHW. source is kept for
+// tracing.
+type modelInput struct {
+	*preparedPrompts
+	source      string
+	pixelValues []float32
+}
+
+// prepareInput preprocesses the image and tokenizes one prompt per label.
+// The baseline label comes first unless opts.NoBaseline is set.
+func prepareInput(tok *Tokenizer, imagePath string, categories []string, opts ClassifyOptions) (*modelInput, error) {
+	return prepareInputWith(preparePrompts(tok, categories, opts), imagePath, opts)
+}
+
+// prepareInputWith preprocesses the image to go with already prepared
+// prompts.
+func prepareInputWith(p *preparedPrompts, imagePath string, opts ClassifyOptions) (*modelInput, error) {
+	pixelValues, err := PreprocessImageWithOptions(imagePath, opts.Preprocess)
+	if err != nil {
+		return nil, fmt.Errorf("cannot preprocess image: %w", err)
+	}
+	return &modelInput{preparedPrompts: p, source: imagePath, pixelValues: pixelValues}, nil
+}
+
+// promptInput tokenizes the prompts for categories to go with an already
+// preprocessed image.
+func promptInput(tok *Tokenizer, pixelValues []float32, categories []string, opts ClassifyOptions) *modelInput {
+	return &modelInput{preparedPrompts: preparePrompts(tok, categories, opts), pixelValues: pixelValues}
+}
+
+// preparePrompts tokenizes one prompt per label, plus any expansion, extra,
+// and calibration prompts.
+func preparePrompts(tok *Tokenizer, categories []string, opts ClassifyOptions) *preparedPrompts {
+	// Build prompt list: baseline (unless disabled) + real categories.
+	// The baseline gets the generic prompt, others get "a photo of {cat}"
+	// unless a custom prompt is given, plus one prompt per expansion term and
+	// extra prompt.
+	var allLabels []string
+	var owners []int
+	var prompts []string
+	var tokenIDs, attentionMask []int64
+	addPrompt := func(prompt string) {
+		owners = append(owners, len(allLabels)-1)
+		prompts = append(prompts, prompt)
+		ids, mask := tok.EncodeWithMask(prompt)
+		tokenIDs = append(tokenIDs, ids...)
+		attentionMask = append(attentionMask, mask...)
+	}
+	if !opts.NoBaseline {
+		allLabels = append(allLabels, BaselineCategory)
+		addPrompt(baselinePrompt)
+	}
+	for _, cat := range categories {
+		allLabels = append(allLabels, cat)
+		prompt, ok := opts.Prompts[cat]
+		if !ok {
+			prompt = fmt.Sprintf("a photo of %s", cat)
+		}
+		addPrompt(prompt)
+		for _, term := range opts.Expansions[cat] {
+			addPrompt(fmt.Sprintf("a photo of %s", term))
+		}
+		for _, extra := range opts.ExtraPrompts[cat] {
+			addPrompt(extra)
+		}
+	}
+	var calibration []string
+	if Calibrates(categories, opts) {
+		calibration = distractors(categories)
+	}
+	for _, p := range calibration {
+		allLabels = append(allLabels, p)
+		addPrompt(p)
+	}
+
+	return &preparedPrompts{
+		labels:        allLabels,
+		owners:        owners,
+		prompts:       prompts,
+		tokenIDs:      tokenIDs,
+		attentionMask: attentionMask,
+		distractors:   len(calibration),
+	}
+}
+
+// promptCache keeps the prompts prepared for the most recent categories and
+// options, so a run classifying every image against the same list
+// tokenizes it once instead of once per image. It is safe for concurrent
+// use.
+type promptCache struct {
+	mu         sync.Mutex
+	categories []string
+	opts       ClassifyOptions
+	prepared   *preparedPrompts
+}
+
+// get returns the prompts for categories and opts, preparing them with tok
+// unless they match the last ones asked for.
+func (pc *promptCache) get(tok *Tokenizer, categories []string, opts ClassifyOptions) *preparedPrompts {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.prepared != nil && samePrompts(pc.categories, pc.opts, categories, opts) {
+		return pc.prepared
+	}
+	pc.prepared = preparePrompts(tok, categories, opts)
+	// Keep copies, so a caller changing its maps later cannot make stale
+	// prompts look current.
+	pc.categories = slices.Clone(categories)
+	pc.opts = ClassifyOptions{
+		NoBaseline:   opts.NoBaseline,
+		Prompts:      maps.Clone(opts.Prompts),
+		Expansions:   cloneLists(opts.Expansions),
+		ExtraPrompts: cloneLists(opts.ExtraPrompts),
+	}
+	return pc.prepared
+}
+
+// samePrompts reports whether two sets of categories and options produce
+// the same prompts. Only the options preparePrompts reads are compared.
+func samePrompts(cats []string, opts ClassifyOptions, otherCats []string, other ClassifyOptions) bool {
+	return slices.Equal(cats, otherCats) &&
+		opts.NoBaseline == other.NoBaseline &&
+		maps.Equal(opts.Prompts, other.Prompts) &&
+		maps.EqualFunc(opts.Expansions, other.Expansions, slices.Equal[[]string]) &&
+		maps.EqualFunc(opts.ExtraPrompts, other.ExtraPrompts, slices.Equal[[]string])
+}
+
+// cloneLists copies m and each of its lists.
+func cloneLists(m map[string][]string) map[string][]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(m))
+	for k, v := range m {
+		out[k] = slices.Clone(v)
+	}
+	return out
+}
+
+// chunkedLogits collects the raw logits for n labels, asking run for at most
+// size labels at a time (all of them if size is zero or negative). CLIP's
+// logit for a prompt depends only on that prompt and the image, so the
+// concatenated chunks equal the logits of a single run. Softmax must only be
+// applied afterwards, across every label, so the baseline competes with all
+// categories rather than the ones in its chunk.
+func chunkedLogits(n, size int, run func(lo, hi int) ([]float32, error)) ([]float32, error) {
+	if size <= 0 || size > n {
+		size = n
+	}
+	logits := make([]float32, 0, n)
+	for lo := 0; lo < n; lo += size {
+		hi := min(lo+size, n)
+		chunk, err := run(lo, hi)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) != hi-lo {
+			return nil, fmt.Errorf("model returned %d logits for %d labels", len(chunk), hi-lo)
+		}
+		logits = append(logits, chunk...)
+	}
+	return logits, nil
+}
+
+// scores pools the logits of each label's prompts with pool and returns the
+// softmaxed scores per label, with any distractors folded into the baseline.
+func (in *modelInput) scores(logits []float32, pool PoolMode) (map[string]float32, error) {
+	if len(in.owners) != len(in.labels) {
+		if len(logits) != len(in.owners) {
+			return nil, fmt.Errorf("model returned %d logits for %d prompts", len(logits), len(in.owners))
+		}
+		logits = poolLogits(logits, in.owners, len(in.labels), pool)
+	}
+	scores, err := scoresFromLogits(in.labels, logits)
+	if err != nil {
+		return nil, err
+	}
+	for _, label := range in.labels[len(in.labels)-in.distractors:] {
+		scores[BaselineCategory] += scores[label]
+		delete(scores, label)
+	}
+	return scores, nil
+}
+
+// scoresFromLogits applies softmax over all labels (including the baseline)
+// and returns a map of label to score.
+func scoresFromLogits(labels []string, logits []float32) (map[string]float32, error) {
+	if len(logits) != len(labels) {
+		return nil, fmt.Errorf("model returned %d logits for %d labels", len(logits), len(labels))
+	}
+	probs := softmax(logits)
+
+	result := make(map[string]float32, len(labels))
+	for i, label := range labels {
+		result[label] = probs[i]
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPromptCache(t *testing.T) {
+	tok := mergingTokenizer(t)
+	var pc promptCache
+	cats := []string{"ocean", "city"}
+	opts := ClassifyOptions{Prompts: map[string]string{"city": "a photo of a city street"}}
+
+	first := pc.get(tok, cats, opts)
+	if !reflect.DeepEqual(first, preparePrompts(tok, cats, opts)) {
+		t.Fatal("cached prompts differ from freshly prepared ones")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { pc.get(tok, cats, opts) }); allocs != 0 {
+		t.Errorf("expected reusing prompts not to allocate, got %v allocations", allocs)
+	}
+
+	// Changing anything that reaches the prompts prepares them again, even
+	// when the caller edits the map it passed before.
+	opts.Prompts["city"] = "a photo of a skyline"
+	for _, tc := range []struct {
+		cats []string
+		opts ClassifyOptions
+	}{
+		{cats, opts},
+		{[]string{"ocean"}, opts},
+		{cats, ClassifyOptions{Prompts: opts.Prompts, NoBaseline: true}},
+		{cats, ClassifyOptions{Prompts: opts.Prompts, Expansions: map[string][]string{"ocean": {"sea"}}}},
+	} {
+		got := pc.get(tok, tc.cats, tc.opts)
+		if got == first || !reflect.DeepEqual(got, preparePrompts(tok, tc.cats, tc.opts)) {
+			t.Errorf("%v %+v: expected freshly prepared prompts", tc.cats, tc.opts)
+		}
+		first = got
+	}
+}
+
+// BenchmarkPrepareInput compares preparing the inputs for each image in
+// testdata from scratch with reusing the prompts across images, as
+// CLIPSession does.
+func BenchmarkPrepareInput(b *testing.B) {
+	tok := mergingTokenizer(b)
+	paths, err := filepath.Glob("../../testdata/*.jpg")
+	if err != nil || len(paths) == 0 {
+		b.Fatalf("no test images: %v", err)
+	}
+	cats := []string{"landscape", "sunset", "document", "night", "nature", "flower"}
+
+	b.Run("per image", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := range b.N {
+			if _, err := prepareInput(tok, paths[i%len(paths)], cats, ClassifyOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reused prompts", func(b *testing.B) {
+		b.ReportAllocs()
+		var pc promptCache
+		for i := range b.N {
+			if _, err := prepareInputWith(pc.get(tok, cats, ClassifyOptions{}), paths[i%len(paths)], ClassifyOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
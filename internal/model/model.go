@@ -4,20 +4,33 @@ package model
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
 )
 
-const hfBaseURL = "https://huggingface.co/Xenova/clip-vit-base-patch32/resolve/main"
+// ModelID identifies the CLIP export imgsort downloads and runs.
+const ModelID = "Xenova/clip-vit-base-patch32"
+
+const hfBaseURL = "https://huggingface.co/" + ModelID + "/resolve/main"
 
 // ModelFile describes a file to download.
 type ModelFile struct {
 	Name   string
 	URL    string
 	SHA256 string // expected hash (empty = skip verification)
+
+	// Check, if set, validates the downloaded file before it is moved into
+	// place, so an error page a proxy served with status 200 is caught
+	// even when no hash is known.
+	Check func(path string) error
 }
 
 // RequiredFiles defines all files needed for CLIP inference.
@@ -27,46 +40,83 @@ var RequiredFiles = []ModelFile{
 		URL:  hfBaseURL + "/onnx/model.onnx",
 	},
 	{
-		Name: "vocab.json",
-		URL:  hfBaseURL + "/vocab.json",
+		Name:  "vocab.json",
+		URL:   hfBaseURL + "/vocab.json",
+		Check: checkVocab,
 	},
 	{
-		Name: "merges.txt",
-		URL:  hfBaseURL + "/merges.txt",
+		Name:  "merges.txt",
+		URL:   hfBaseURL + "/merges.txt",
+		Check: checkMerges,
 	},
 }
 
-// ModelsDir returns the path to the model storage directory (~/.imgsort/models/).
+// TokenizerFiles are the files needed to tokenize prompts without running
+// the model locally, as when classifying against a remote server.
+var TokenizerFiles = RequiredFiles[1:]
+
+// modelsDir replaces the default models directory when set.
+var modelsDir string
+
+// SetModelsDir makes ModelsDir return dir, for models staged in a fixed
+// location such as a container image. An empty dir restores the default.
+func SetModelsDir(dir string) {
+	modelsDir = dir
+}
+
+// ModelsDir returns the path to the model storage directory: the one given
+// to SetModelsDir, $IMGSORT_MODELS_DIR, or ~/.imgsort/models/.
 func ModelsDir() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	if modelsDir != "" {
+		return modelsDir, nil
 	}
-	return filepath.Join(home, ".imgsort", "models"), nil
+	return appdir.Sub(appdir.ModelsEnv, "models")
 }
 
 // EnsureModels checks that all required files exist, downloading any that are missing.
 func EnsureModels(progressFn func(filename string, downloaded, total int64)) error {
+	return EnsureFiles(RequiredFiles, progressFn)
+}
+
+// EnsureFiles checks that the given files exist, downloading any that are
+// missing. Downloads are checked against the lock file when it lists them.
+// Nothing is written when every file is present, so staged models work from
+// a read-only directory.
+func EnsureFiles(files []ModelFile, progressFn func(filename string, downloaded, total int64)) error {
 	dir, err := ModelsDir()
 	if err != nil {
 		return err
 	}
+	var missing []ModelFile
+	for _, m := range files {
+		if _, err := os.Stat(filepath.Join(dir, m.Name)); err != nil {
+			missing = append(missing, m)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("cannot create models directory: %w", err)
+		return fmt.Errorf("cannot create models directory %s: %w (set %s or --models-dir to a writable directory, or to one with the models staged)",
+			dir, err, appdir.ModelsEnv)
+	}
+	lock, err := ReadLock()
+	if err != nil {
+		return err
 	}
 
-	for _, m := range RequiredFiles {
+	for _, m := range missing {
 		path := filepath.Join(dir, m.Name)
-		if _, err := os.Stat(path); err == nil {
-			continue // already downloaded
-		}
-
-		if err := downloadFile(path, m.URL, m.SHA256, func(downloaded, total int64) {
+		if err := downloadFile(path, m.URL, ExpectedHash(m, lock), m.Check, func(downloaded, total int64) {
 			if progressFn != nil {
 				progressFn(m.Name, downloaded, total)
 			}
 		}); err != nil {
 			os.Remove(path) // clean up partial download
+			if errors.Is(err, fs.ErrPermission) {
+				return fmt.Errorf("failed to download %s into %s: %w (set %s or --models-dir to a writable directory)",
+					m.Name, dir, err, appdir.ModelsEnv)
+			}
 			return fmt.Errorf("failed to download %s: %w", m.Name, err)
 		}
 	}
@@ -86,31 +136,81 @@ func FilePath(name string) (string, error) {
 	return path, nil
 }
 
-func downloadFile(destPath, url, expectedHash string, progressFn func(downloaded, total int64)) error {
-	resp, err := http.Get(url)
+// downloadFile fetches url into destPath through destPath+".tmp". A .tmp a
+// truncated or failed earlier download left behind is resumed with a Range
+// request; if the server ignores the range, the download starts over.
+func downloadFile(destPath, url, expectedHash string, check func(string) error, progressFn func(downloaded, total int64)) error {
+	tmpPath := destPath + ".tmp"
+	var offset int64
+	if info, err := os.Stat(tmpPath); err == nil && info.Mode().IsRegular() {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	resumed := resp.StatusCode == http.StatusPartialContent && offset > 0 &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset))
+	switch {
+	case resumed:
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The kept .tmp is no prefix of what the server has now.
+		os.Remove(tmpPath)
+		return downloadFile(destPath, url, expectedHash, check, progressFn)
+	case resp.StatusCode != http.StatusOK:
 		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
+	// The transport decodes gzip itself and drops the header; any encoding
+	// left would be written to disk still encoded.
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		os.Remove(tmpPath)
+		return fmt.Errorf("unexpected Content-Encoding %q", enc)
+	}
 
-	tmpPath := destPath + ".tmp"
-	f, err := os.Create(tmpPath)
+	hasher := sha256.New()
+	var f *os.File
+	if resumed {
+		f, err = os.OpenFile(tmpPath, os.O_RDWR, 0644)
+		if err == nil {
+			_, err = io.Copy(hasher, f)
+		}
+	} else {
+		offset = 0
+		f, err = os.Create(tmpPath)
+	}
 	if err != nil {
+		if f != nil {
+			f.Close()
+		}
 		return fmt.Errorf("cannot create file: %w", err)
 	}
+	// A .tmp cut short by the connection is kept for the next run to
+	// resume; one whose content is known to be wrong is removed.
+	keep := false
 	defer func() {
 		f.Close()
-		os.Remove(tmpPath) // clean up if not renamed
+		if !keep {
+			os.Remove(tmpPath)
+		}
 	}()
 
-	hasher := sha256.New()
 	writer := io.MultiWriter(f, hasher)
 
-	var downloaded int64
+	total := resp.ContentLength
+	if total >= 0 {
+		total += offset
+	}
+	downloaded := offset
 	buf := make([]byte, 32*1024)
 	for {
 		n, readErr := resp.Body.Read(buf)
@@ -120,19 +220,31 @@ func downloadFile(destPath, url, expectedHash string, progressFn func(downloaded
 			}
 			downloaded += int64(n)
 			if progressFn != nil {
-				progressFn(downloaded, resp.ContentLength)
+				progressFn(downloaded, total)
 			}
 		}
 		if readErr == io.EOF {
 			break
 		}
+		if readErr == io.ErrUnexpectedEOF {
+			keep = true
+			return fmt.Errorf("download truncated: got %d of %d bytes", downloaded, total)
+		}
 		if readErr != nil {
+			keep = true
 			return fmt.Errorf("read error: %w", readErr)
 		}
 	}
 
 	f.Close()
 
+	// A connection cut short can end in a clean EOF, for example through
+	// a proxy.
+	if total >= 0 && downloaded != total {
+		keep = downloaded < total
+		return fmt.Errorf("download truncated: got %d of %d bytes", downloaded, total)
+	}
+
 	// Verify hash if provided
 	if expectedHash != "" {
 		actualHash := hex.EncodeToString(hasher.Sum(nil))
@@ -141,6 +253,15 @@ func downloadFile(destPath, url, expectedHash string, progressFn func(downloaded
 		}
 	}
 
+	if err := checkNotHTML(tmpPath); err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(tmpPath); err != nil {
+			return err
+		}
+	}
+
 	if err := os.Rename(tmpPath, destPath); err != nil {
 		return fmt.Errorf("cannot finalize download: %w", err)
 	}
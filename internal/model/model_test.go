@@ -1,11 +1,19 @@
 package model
 
 import (
+	"bytes"
+	"errors"
 	"image"
 	"image/color"
 	"image/png"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"github.com/bagtoad/imgsort/internal/video"
 )
 
 func TestPreprocessImage(t *testing.T) {
@@ -133,3 +141,317 @@ func TestSoftmax(t *testing.T) {
 		t.Errorf("softmax probabilities should be ascending: %v", probs)
 	}
 }
+
+// writeCheckerboard saves a size x size black and white checkerboard with
+// cell-pixel squares and returns its path.
+func writeCheckerboard(t *testing.T, size, cell int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := color.RGBA{A: 255}
+			if (x/cell+y/cell)%2 == 1 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "small.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPreprocessSmallImage(t *testing.T) {
+	path := writeCheckerboard(t, 32, 4)
+	black := (0 - clipMean[0]) / clipStd[0]
+	white := (1 - clipMean[0]) / clipStd[0]
+
+	for _, mode := range []SmallImageMode{SmallSharp, SmallPad} {
+		t.Run(string(mode), func(t *testing.T) {
+			tensor, err := PreprocessImageWithOptions(path, PreprocessOptions{Small: mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tensor) != 3*clipImageSize*clipImageSize {
+				t.Fatalf("unexpected tensor length %d", len(tensor))
+			}
+
+			// 224 is exactly 7 x 32, so every source pixel becomes a 7x7
+			// block: no blended values, and cells stay 28 pixels wide.
+			for y := 0; y < clipImageSize; y++ {
+				for x := 0; x < clipImageSize; x++ {
+					want := black
+					if (x/28+y/28)%2 == 1 {
+						want = white
+					}
+					if got := tensor[y*clipImageSize+x]; abs32(got-want) > 1e-4 {
+						t.Fatalf("pixel (%d,%d) = %f, want %f", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+
+	// Stretching blends neighbouring cells at their edges.
+	tensor, err := PreprocessImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blended := false
+	for _, v := range tensor[:clipImageSize*clipImageSize] {
+		if abs32(v-black) > 1e-3 && abs32(v-white) > 1e-3 {
+			blended = true
+			break
+		}
+	}
+	if !blended {
+		t.Error("bilinear stretch should produce intermediate values")
+	}
+}
+
+func TestPreprocessSmallImagePad(t *testing.T) {
+	// 30 x 7 = 210, leaving a 7 pixel border on every side.
+	path := writeCheckerboard(t, 30, 3)
+	tensor, err := PreprocessImageWithOptions(path, PreprocessOptions{Small: SmallPad})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plane := clipImageSize * clipImageSize
+	for c := 0; c < 3; c++ {
+		for _, p := range []image.Point{{0, 0}, {6, 100}, {223, 223}, {100, 217}} {
+			if v := tensor[c*plane+p.Y*clipImageSize+p.X]; abs32(v) > 0.02 {
+				t.Errorf("padding at %v channel %d = %f, want about 0", p, c, v)
+			}
+		}
+	}
+	black := (0 - clipMean[0]) / clipStd[0]
+	if v := tensor[7*clipImageSize+7]; abs32(v-black) > 1e-4 {
+		t.Errorf("image should start at (7,7), got %f there", v)
+	}
+}
+
+func TestPreprocessMinSize(t *testing.T) {
+	path := writeCheckerboard(t, 32, 4)
+	_, err := PreprocessImageWithOptions(path, PreprocessOptions{MinSize: 48})
+	if !errors.Is(err, ErrTooSmall) {
+		t.Errorf("expected ErrTooSmall, got %v", err)
+	}
+	if _, err := PreprocessImageWithOptions(path, PreprocessOptions{MinSize: 32}); err != nil {
+		t.Errorf("image at the minimum should be accepted: %v", err)
+	}
+}
+
+func TestPreprocessMaxPixels(t *testing.T) {
+	path := writeCheckerboard(t, 32, 4)
+	_, err := PreprocessImageWithOptions(path, PreprocessOptions{MaxPixels: 32*32 - 1})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PreprocessReader(bytes.NewReader(data), PreprocessOptions{MaxPixels: 32 * 32}); err != nil {
+		t.Errorf("image at the maximum should be accepted: %v", err)
+	}
+}
+
+func TestPreprocessDecodedHook(t *testing.T) {
+	path := writeCheckerboard(t, 300, 10)
+	var got []string
+	opts := PreprocessOptions{Decoded: func(p string, img image.Image) {
+		if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 300 {
+			t.Errorf("expected the full 300x300 image, got %dx%d", b.Dx(), b.Dy())
+		}
+		got = append(got, p)
+	}}
+	if _, err := PreprocessImageWithOptions(path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != path {
+		t.Errorf("expected Decoded to be called once with %s, got %v", path, got)
+	}
+}
+
+func TestPreprocessSixteenBit(t *testing.T) {
+	// A dark 16-bit gradient whose columns differ by less than one 8-bit
+	// step, in a wide image so the center crop is exercised too.
+	img := image.NewGray16(image.Rect(0, 0, 320, clipImageSize))
+	level := func(x int) uint16 { return uint16(4000 + 3*x) }
+	for y := 0; y < clipImageSize; y++ {
+		for x := 0; x < 320; x++ {
+			img.SetGray16(x, y, color.Gray16{Y: level(x)})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "gradient.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tensor, err := PreprocessImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plane := clipImageSize * clipImageSize
+	seen := make(map[float32]bool)
+	for c := 0; c < 3; c++ {
+		for x := 0; x < clipImageSize; x++ {
+			want := (float32(level(x+48))/65535 - clipMean[c]) / clipStd[c]
+			if got := tensor[c*plane+100*clipImageSize+x]; abs32(got-want) > 1e-5 {
+				t.Fatalf("channel %d column %d = %f, want %f", c, x, got, want)
+			}
+			if c == 0 {
+				seen[tensor[x]] = true
+			}
+		}
+	}
+	if len(seen) != clipImageSize {
+		t.Errorf("expected %d distinct levels across the gradient, got %d", clipImageSize, len(seen))
+	}
+}
+
+func TestPreprocessVideo(t *testing.T) {
+	frame := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for i := range frame.Pix {
+		frame.Pix[i] = 255
+	}
+	prev := video.Register(func(path string, position float64) (image.Image, error) {
+		if strings.HasSuffix(path, "broken.mp4") {
+			return nil, errors.New("invalid data found when processing input")
+		}
+		return frame, nil
+	})
+	defer video.Register(prev)
+
+	// The file is never opened: the decoder is given its path.
+	tensor, err := PreprocessImage("clip.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (1 - clipMean[0]) / clipStd[0]; math.Abs(float64(tensor[0]-want)) > 1e-5 {
+		t.Errorf("expected the frame to be preprocessed, got %f want %f", tensor[0], want)
+	}
+	if _, err := PreprocessImage("broken.mp4"); err == nil || !strings.Contains(err.Error(), "cannot decode video: invalid data") {
+		t.Errorf("expected a clear decode error, got %v", err)
+	}
+}
+
+func TestParseSmallImageMode(t *testing.T) {
+	for in, want := range map[string]SmallImageMode{"": SmallStretch, "stretch": SmallStretch, "sharp": SmallSharp, "pad": SmallPad} {
+		if got, err := ParseSmallImageMode(in); err != nil || got != want {
+			t.Errorf("ParseSmallImageMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseSmallImageMode("lanczos"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func abs32(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+func TestPreprocessReader(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 120; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 2), G: uint8(y * 3), B: 90, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "img.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := PreprocessImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromReader, err := PreprocessReader(bytes.NewReader(buf.Bytes()), PreprocessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromImage, err := PreprocessDecoded(img, PreprocessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(fromReader, want) || !slices.Equal(fromImage, want) {
+		t.Error("preprocessing a reader or decoded image should match preprocessing the file")
+	}
+
+	if _, err := PreprocessReader(bytes.NewReader(buf.Bytes()), PreprocessOptions{MinSize: 100}); !errors.Is(err, ErrTooSmall) {
+		t.Errorf("expected ErrTooSmall, got %v", err)
+	}
+	if _, err := PreprocessReader(strings.NewReader("not an image"), PreprocessOptions{}); err == nil {
+		t.Error("expected an error for undecodable data")
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		w, h, size int
+		wantW      int
+		wantH      int
+	}{
+		{400, 200, 160, 160, 80},
+		{200, 400, 160, 80, 160},
+		{100, 50, 160, 100, 50},
+	}
+	for _, tt := range tests {
+		thumb := Thumbnail(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), tt.size)
+		if b := thumb.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("Thumbnail(%dx%d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.size, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+// TestPreprocessFastPathMatchesAt checks that reading pixels through
+// RGBA64At gives the same tensors as reading them through At.
+func TestPreprocessFastPathMatchesAt(t *testing.T) {
+	paths, _ := filepath.Glob("../../testdata/*.[jp][pn]g")
+	if len(paths) == 0 {
+		t.Fatal("no test images")
+	}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, small := range []SmallImageMode{SmallStretch, SmallSharp} {
+			fast, err := fitImage(img, clipImageSize, PreprocessOptions{Small: small})
+			if err != nil {
+				t.Fatal(err)
+			}
+			slow, err := fitImage(rgba64Adapter{img}, clipImageSize, PreprocessOptions{Small: small})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(imageToTensor(fast), imageToTensor(rgba64Adapter{slow})) {
+				t.Errorf("%s (%s): tensors differ between RGBA64At and At", filepath.Base(path), small)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nodeNames maps the roles CLIP inference needs to the node names used by a
+// particular ONNX export. Optional roles are left empty when the model does
+// not provide them.
+type nodeNames struct {
+	inputIDs       string
+	pixelValues    string
+	attentionMask  string // optional
+	logitsPerImage string
+	logitsPerText  string // optional
+}
+
+// defaultNodeNames are the node names used by the Xenova/HuggingFace export.
+var defaultNodeNames = nodeNames{
+	inputIDs:       "input_ids",
+	pixelValues:    "pixel_values",
+	attentionMask:  "attention_mask",
+	logitsPerImage: "logits_per_image",
+	logitsPerText:  "logits_per_text",
+}
+
+// inputNames returns the model inputs in the order Classify supplies them.
+func (n nodeNames) inputNames() []string {
+	names := []string{n.inputIDs, n.pixelValues}
+	if n.attentionMask != "" {
+		names = append(names, n.attentionMask)
+	}
+	return names
+}
+
+// outputNames returns the model outputs in the order Classify reads them.
+func (n nodeNames) outputNames() []string {
+	names := []string{n.logitsPerImage}
+	if n.logitsPerText != "" {
+		names = append(names, n.logitsPerText)
+	}
+	return names
+}
+
+// resolveNodeNames maps a model's actual input and output names to CLIP roles.
+// Exact matches on the default names win; otherwise each role is matched by a
+// name heuristic. It returns an error listing the model's real node names when
+// a required role cannot be found.
+func resolveNodeNames(inputs, outputs []string) (nodeNames, error) {
+	var n nodeNames
+	var missing []string
+
+	n.inputIDs = matchNode(inputs, defaultNodeNames.inputIDs, "input_ids", "ids", "token", "text")
+	n.pixelValues = matchNode(inputs, defaultNodeNames.pixelValues, "pixel", "image")
+	n.attentionMask = matchNode(inputs, defaultNodeNames.attentionMask, "mask")
+
+	// Exports often also emit embeddings; only consider logits for the outputs.
+	logits := outputs
+	if l := filterNodes(outputs, "logit"); len(l) > 0 {
+		logits = l
+	}
+	n.logitsPerImage = matchNode(logits, defaultNodeNames.logitsPerImage, "per_image", "image")
+	n.logitsPerText = matchNode(logits, defaultNodeNames.logitsPerText, "per_text", "text")
+
+	if n.inputIDs == "" {
+		missing = append(missing, "token ids input")
+	}
+	if n.pixelValues == "" {
+		missing = append(missing, "pixel values input")
+	}
+	if n.logitsPerImage == "" {
+		missing = append(missing, "image logits output")
+	}
+	if n.inputIDs != "" && n.inputIDs == n.pixelValues {
+		missing = append(missing, "distinct token and pixel inputs")
+	}
+
+	if len(missing) > 0 {
+		return nodeNames{}, fmt.Errorf("model does not look like a CLIP export (missing %s); inputs: [%s], outputs: [%s]",
+			strings.Join(missing, ", "), strings.Join(inputs, ", "), strings.Join(outputs, ", "))
+	}
+	return n, nil
+}
+
+// matchNode returns the exact name if present, otherwise the first name
+// containing any of the hints (checked in order), or "" if none match.
+func matchNode(names []string, exact string, hints ...string) string {
+	for _, name := range names {
+		if name == exact {
+			return name
+		}
+	}
+	for _, hint := range hints {
+		for _, name := range names {
+			if strings.Contains(strings.ToLower(name), hint) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// filterNodes returns the names containing substr (case-insensitive).
+func filterNodes(names []string, substr string) []string {
+	var out []string
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), substr) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveNodeNamesDefault(t *testing.T) {
+	nodes, err := resolveNodeNames(
+		[]string{"input_ids", "pixel_values", "attention_mask"},
+		[]string{"logits_per_image", "logits_per_text", "text_embeds", "image_embeds"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes != defaultNodeNames {
+		t.Errorf("expected default node names, got %+v", nodes)
+	}
+}
+
+func TestResolveNodeNamesHeuristic(t *testing.T) {
+	nodes, err := resolveNodeNames(
+		[]string{"text_tokens", "image"},
+		[]string{"image_embeds", "text_embeds", "image_logits", "text_logits"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := nodeNames{
+		inputIDs:       "text_tokens",
+		pixelValues:    "image",
+		logitsPerImage: "image_logits",
+		logitsPerText:  "text_logits",
+	}
+	if nodes != expected {
+		t.Errorf("expected %+v, got %+v", expected, nodes)
+	}
+	if got := nodes.inputNames(); len(got) != 2 {
+		t.Errorf("expected attention mask to be omitted from inputs, got %v", got)
+	}
+}
+
+func TestResolveNodeNamesMismatch(t *testing.T) {
+	_, err := resolveNodeNames([]string{"data"}, []string{"output0"})
+	if err == nil {
+		t.Fatal("expected error for non-CLIP node names")
+	}
+	for _, want := range []string{"data", "output0", "pixel values input"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+}
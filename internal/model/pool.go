@@ -0,0 +1,55 @@
+package model
+
+import "fmt"
+
+// PoolMode is how the logits of a category's prompts are combined when it
+// has expansion terms.
+type PoolMode string
+
+const (
+	// PoolMax takes the best-matching prompt, so a term that fits the image
+	// is not diluted by ones that do not.
+	PoolMax PoolMode = "max"
+	// PoolMean averages the prompts, which is steadier when the terms are
+	// all close paraphrases.
+	PoolMean PoolMode = "mean"
+)
+
+// ParsePoolMode validates a pooling mode name. The empty string selects
+// PoolMax.
+func ParsePoolMode(s string) (PoolMode, error) {
+	switch s {
+	case "", string(PoolMax):
+		return PoolMax, nil
+	case string(PoolMean):
+		return PoolMean, nil
+	}
+	return "", fmt.Errorf("unknown prompt pooling %q (expected max or mean)", s)
+}
+
+// poolLogits combines per-prompt logits into n per-label logits, where
+// owners[i] is the label of prompt i.
+func poolLogits(logits []float32, owners []int, n int, pool PoolMode) []float32 {
+	out := make([]float32, n)
+	counts := make([]int, n)
+	for i, l := range logits {
+		o := owners[i]
+		switch {
+		case counts[o] == 0:
+			out[o] = l
+		case pool == PoolMean:
+			out[o] += l
+		case l > out[o]:
+			out[o] = l
+		}
+		counts[o]++
+	}
+	if pool == PoolMean {
+		for o, c := range counts {
+			if c > 1 {
+				out[o] /= float32(c)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestExpansionRescuesBorderlineImage scores an image whose plain "a photo
+// of dog" prompt loses narrowly to the baseline but whose "puppy" prompt
+// matches well, as with a close-up of a young dog.
+func TestExpansionRescuesBorderlineImage(t *testing.T) {
+	tok, img := testTokenizer(t), testImage(t)
+	cats := []string{"dog", "cat"}
+
+	plain, err := prepareInput(tok, img, cats, ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores, err := plain.scores([]float32{20, 19.5, 17}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores["dog"] >= scores[BaselineCategory] {
+		t.Fatalf("expected the baseline to win without expansion, got %v", scores)
+	}
+
+	expanded, err := prepareInput(tok, img, cats, ClassifyOptions{
+		Expansions: map[string][]string{"dog": {"puppy", "canine"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(expanded.owners, []int{0, 1, 1, 1, 2}) {
+		t.Fatalf("unexpected prompt owners %v", expanded.owners)
+	}
+	if n := len(expanded.tokenIDs); n != 5*contextLen {
+		t.Fatalf("expected 5 prompt rows, got %d tokens", n)
+	}
+
+	// Rows: baseline, dog, puppy, canine, cat.
+	logits := []float32{20, 19.5, 23, 18.5, 17}
+	scores, err = expanded.scores(logits, PoolMax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores["dog"] <= scores[BaselineCategory] {
+		t.Errorf("expected dog to beat the baseline with max pooling, got %v", scores)
+	}
+
+	mean, err := expanded.scores(logits, PoolMean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := scoresFromLogits(expanded.labels, []float32{20, (19.5 + 23 + 18.5) / 3, 17})
+	if mean["dog"] != want["dog"] || mean["dog"] <= mean[BaselineCategory] {
+		t.Errorf("mean pooling: got %v, want %v", mean, want)
+	}
+
+	if _, err := expanded.scores(logits[:3], PoolMax); err == nil {
+		t.Error("expected an error for a short logits list")
+	}
+}
+
+func TestParsePoolMode(t *testing.T) {
+	for in, want := range map[string]PoolMode{"": PoolMax, "max": PoolMax, "mean": PoolMean} {
+		if got, err := ParsePoolMode(in); err != nil || got != want {
+			t.Errorf("ParsePoolMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParsePoolMode("median"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestExtraPromptsUsedVerbatim(t *testing.T) {
+	tok, img := testTokenizer(t), testImage(t)
+	in, err := prepareInput(tok, img, []string{"dog", "cat"}, ClassifyOptions{
+		ExtraPrompts: map[string][]string{"cat": {"a cat asleep on a sofa"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(in.owners, []int{0, 1, 2, 2}) {
+		t.Fatalf("unexpected prompt owners %v", in.owners)
+	}
+	if row := in.tokenIDs[3*contextLen:]; !slices.Equal(row, tok.Encode("a cat asleep on a sofa")) {
+		t.Errorf("extra prompt should be encoded as given, got %v", row)
+	}
+}
@@ -1,15 +1,21 @@
 package model
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
-	"image/color"
+	"io"
 	"math"
 	"os"
 
+	"github.com/bagtoad/imgsort/internal/video"
+
 	_ "golang.org/x/image/bmp"
 	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
@@ -23,31 +29,206 @@ var (
 	clipStd  = [3]float32{0.26862954, 0.26130258, 0.27577711}
 )
 
+// SmallImageMode chooses how images smaller than the model input are
+// enlarged.
+type SmallImageMode string
+
+const (
+	// SmallStretch scales small images up with bilinear interpolation, like
+	// any other image (the default).
+	SmallStretch SmallImageMode = "stretch"
+	// SmallSharp scales small images up with nearest-neighbour sampling,
+	// which keeps edges crisp instead of blurring them.
+	SmallSharp SmallImageMode = "sharp"
+	// SmallPad scales small images up by the largest whole factor that fits
+	// and centers them on a neutral background, so pixels are never
+	// stretched unevenly.
+	SmallPad SmallImageMode = "pad"
+)
+
+// ParseSmallImageMode validates a small image mode name. The empty string
+// means SmallStretch.
+func ParseSmallImageMode(s string) (SmallImageMode, error) {
+	switch SmallImageMode(s) {
+	case "", SmallStretch:
+		return SmallStretch, nil
+	case SmallSharp, SmallPad:
+		return SmallImageMode(s), nil
+	}
+	return "", fmt.Errorf("unknown small image mode %q (expected stretch, sharp, or pad)", s)
+}
+
+// ErrTooSmall is returned for images below the minimum size.
+var ErrTooSmall = errors.New("image is too small")
+
+// ErrTooLarge is returned for images above PreprocessOptions.MaxPixels.
+var ErrTooLarge = errors.New("image is too large")
+
+// PreprocessOptions controls how images are prepared for the model.
+type PreprocessOptions struct {
+	// Small chooses how images smaller than the model input are enlarged.
+	Small SmallImageMode
+
+	// MinSize rejects images whose shorter side is below this many pixels
+	// with ErrTooSmall. Zero accepts every image.
+	MinSize int
+
+	// MaxPixels rejects images with more pixels than this with ErrTooLarge,
+	// checked from the image header before it is decoded. Zero accepts
+	// every image.
+	MaxPixels int
+
+	// Decoded, if set, is called with the path and decoded image of every
+	// file loaded for preprocessing, before it is cropped and scaled, so a
+	// caller can keep a thumbnail without decoding the file again. It may
+	// be called from several goroutines at once.
+	Decoded func(path string, img image.Image)
+}
+
 // PreprocessImage loads an image file and returns a float32 tensor in
 // [1, 3, 224, 224] CHW format, normalized for CLIP.
 func PreprocessImage(path string) ([]float32, error) {
+	return PreprocessImageWithOptions(path, PreprocessOptions{})
+}
+
+// PreprocessImageWithOptions is like PreprocessImage, with control over
+// how small images are handled.
+func PreprocessImageWithOptions(path string, opts PreprocessOptions) ([]float32, error) {
+	img, err := loadImage(path, clipImageSize, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to CHW float32 tensor with normalization
+	return imageToTensor(img), nil
+}
+
+// PreprocessReader is like PreprocessImageWithOptions for encoded image
+// data read from r, such as an HTTP request body.
+func PreprocessReader(r io.Reader, opts PreprocessOptions) ([]float32, error) {
+	img, err := decode(r, opts.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+	return PreprocessDecoded(img, opts)
+}
+
+// decode decodes an image, first checking its size against maxPixels (if
+// positive) so an oversized image is refused before it is held in memory.
+func decode(r io.Reader, maxPixels int) (image.Image, error) {
+	if maxPixels > 0 {
+		var header bytes.Buffer
+		cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode image: %w", err)
+		}
+		if n := cfg.Width * cfg.Height; n > maxPixels {
+			return nil, fmt.Errorf("%w: %dx%d is over %d pixels", ErrTooLarge, cfg.Width, cfg.Height, maxPixels)
+		}
+		r = io.MultiReader(&header, r)
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode image: %w", err)
+	}
+	return img, nil
+}
+
+// PreprocessDecoded is like PreprocessImageWithOptions for an image that is
+// already decoded.
+func PreprocessDecoded(img image.Image, opts PreprocessOptions) ([]float32, error) {
+	img, err := fitImage(img, clipImageSize, opts)
+	if err != nil {
+		return nil, err
+	}
+	return imageToTensor(img), nil
+}
+
+// LoadImage decodes an image file, center crops it to a square, and resizes
+// it to size x size using bilinear interpolation.
+func LoadImage(path string, size int) (image.Image, error) {
+	return loadImage(path, size, PreprocessOptions{})
+}
+
+// FitImage center crops an image that is already decoded to a square and
+// resizes it to size x size, as LoadImage does.
+func FitImage(img image.Image, size int) image.Image {
+	img, _ = fitImage(img, size, PreprocessOptions{})
+	return img
+}
+
+// loadImage is LoadImage with small image handling. A video is read as
+// its representative frame.
+func loadImage(path string, size int, opts PreprocessOptions) (image.Image, error) {
+	if video.Is(path) {
+		img, err := video.Frame(path)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Decoded != nil {
+			opts.Decoded(path, img)
+		}
+		return fitImage(img, size, opts)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open image: %w", err)
 	}
 	defer f.Close()
 
-	img, _, err := image.Decode(f)
+	img, err := decode(f, opts.MaxPixels)
 	if err != nil {
-		return nil, fmt.Errorf("cannot decode image: %w", err)
+		return nil, err
+	}
+	if opts.Decoded != nil {
+		opts.Decoded(path, img)
+	}
+	return fitImage(img, size, opts)
+}
+
+// fitImage center crops img to a square and scales it to size x size,
+// enlarging small images as opts.Small says.
+func fitImage(img image.Image, size int, opts PreprocessOptions) (image.Image, error) {
+	b := img.Bounds()
+	if opts.MinSize > 0 && min(b.Dx(), b.Dy()) < opts.MinSize {
+		return nil, fmt.Errorf("%w: %dx%d is below the minimum of %d pixels", ErrTooSmall, b.Dx(), b.Dy(), opts.MinSize)
 	}
 
 	// Center crop to square
 	img = centerCrop(img)
 
-	// Resize to 224x224 using bilinear interpolation
-	img = resize(img, clipImageSize, clipImageSize)
+	if side := img.Bounds().Dx(); side < size {
+		switch opts.Small {
+		case SmallSharp:
+			return resizeNearest(img, size, size), nil
+		case SmallPad:
+			factor := size / side
+			return padCenter(resizeNearest(img, side*factor, side*factor), size), nil
+		}
+	}
 
-	// Convert to CHW float32 tensor with normalization
-	return imageToTensor(img), nil
+	// Resize using bilinear interpolation
+	return resize(img, size, size), nil
+}
+
+// Thumbnail scales img so its longer side is size pixels, keeping the aspect
+// ratio. Images that already fit are returned unchanged.
+func Thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w >= h {
+		return resize(img, size, max(1, h*size/w))
+	}
+	return resize(img, max(1, w*size/h), size)
 }
 
-// centerCrop crops the image to a square from the center.
+// centerCrop crops the image to a square from the center. Images that
+// support SubImage, which includes every decoded format, are cropped
+// without copying, so they keep their bit depth.
 func centerCrop(img image.Image) image.Image {
 	bounds := img.Bounds()
 	w := bounds.Dx()
@@ -66,22 +247,25 @@ func centerCrop(img image.Image) image.Image {
 		cropRect = image.Rect(bounds.Min.X, bounds.Min.Y+offset, bounds.Max.X, bounds.Min.Y+offset+w)
 	}
 
-	cropped := image.NewRGBA(image.Rect(0, 0, cropRect.Dx(), cropRect.Dy()))
-	for y := 0; y < cropRect.Dy(); y++ {
-		for x := 0; x < cropRect.Dx(); x++ {
-			cropped.Set(x, y, img.At(cropRect.Min.X+x, cropRect.Min.Y+y))
-		}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(cropRect)
 	}
+	cropped := image.NewRGBA64(image.Rect(0, 0, cropRect.Dx(), cropRect.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, cropRect.Min, draw.Src)
 	return cropped
 }
 
-// resize performs bilinear interpolation to resize an image.
+// resize performs bilinear interpolation to resize an image. It works in
+// 16 bits per channel, so high-bit-depth images are not quantized to 8.
 func resize(img image.Image, width, height int) image.Image {
 	bounds := img.Bounds()
 	srcW := bounds.Dx()
 	srcH := bounds.Dy()
 
-	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	src := asRGBA64(img)
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
 
 	xRatio := float64(srcW) / float64(width)
 	yRatio := float64(srcH) / float64(height)
@@ -106,17 +290,17 @@ func resize(img image.Image, width, height int) image.Image {
 			xFrac := srcX - float64(x0)
 			yFrac := srcY - float64(y0)
 
-			r00, g00, b00, a00 := img.At(x0, y0).RGBA()
-			r10, g10, b10, a10 := img.At(x1, y0).RGBA()
-			r01, g01, b01, a01 := img.At(x0, y1).RGBA()
-			r11, g11, b11, a11 := img.At(x1, y1).RGBA()
+			c00 := src.RGBA64At(x0, y0)
+			c10 := src.RGBA64At(x1, y0)
+			c01 := src.RGBA64At(x0, y1)
+			c11 := src.RGBA64At(x1, y1)
 
-			r := bilinear(float64(r00), float64(r10), float64(r01), float64(r11), xFrac, yFrac)
-			g := bilinear(float64(g00), float64(g10), float64(g01), float64(g11), xFrac, yFrac)
-			b := bilinear(float64(b00), float64(b10), float64(b01), float64(b11), xFrac, yFrac)
-			a := bilinear(float64(a00), float64(a10), float64(a01), float64(a11), xFrac, yFrac)
+			r := bilinear(float64(c00.R), float64(c10.R), float64(c01.R), float64(c11.R), xFrac, yFrac)
+			g := bilinear(float64(c00.G), float64(c10.G), float64(c01.G), float64(c11.G), xFrac, yFrac)
+			b := bilinear(float64(c00.B), float64(c10.B), float64(c01.B), float64(c11.B), xFrac, yFrac)
+			a := bilinear(float64(c00.A), float64(c10.A), float64(c01.A), float64(c11.A), xFrac, yFrac)
 
-			dst.Set(x, y, color.RGBA64{
+			dst.SetRGBA64(x, y, color.RGBA64{
 				R: uint16(r),
 				G: uint16(g),
 				B: uint16(b),
@@ -127,6 +311,56 @@ func resize(img image.Image, width, height int) image.Image {
 	return dst
 }
 
+// resizeNearest resizes an image by nearest-neighbour sampling.
+func resizeNearest(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	src := asRGBA64(img)
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		srcY := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			dst.SetRGBA64(x, y, src.RGBA64At(bounds.Min.X+x*bounds.Dx()/width, srcY))
+		}
+	}
+	return dst
+}
+
+// padCenter places img in the middle of a size x size canvas filled with the
+// CLIP mean color, which normalizes to zero.
+func padCenter(img image.Image, size int) image.Image {
+	bg := color.RGBA64{
+		R: uint16(math.Round(float64(clipMean[0]) * 0xffff)),
+		G: uint16(math.Round(float64(clipMean[1]) * 0xffff)),
+		B: uint16(math.Round(float64(clipMean[2]) * 0xffff)),
+		A: 0xffff,
+	}
+	dst := image.NewRGBA64(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+
+	b := img.Bounds()
+	offset := image.Pt((size-b.Dx())/2, (size-b.Dy())/2)
+	draw.Draw(dst, image.Rectangle{Min: offset, Max: offset.Add(b.Size())}, img, b.Min, draw.Src)
+	return dst
+}
+
+// asRGBA64 returns img as an image.RGBA64Image. Every standard image type
+// is one; reading pixels through RGBA64At avoids the allocation At makes
+// for each pixel, which dominated preprocessing.
+func asRGBA64(img image.Image) image.RGBA64Image {
+	if src, ok := img.(image.RGBA64Image); ok {
+		return src
+	}
+	return rgba64Adapter{img}
+}
+
+// rgba64Adapter gives any image an RGBA64At method.
+type rgba64Adapter struct{ image.Image }
+
+func (a rgba64Adapter) RGBA64At(x, y int) color.RGBA64 {
+	r, g, b, al := a.At(x, y).RGBA()
+	return color.RGBA64{R: uint16(r), G: uint16(g), B: uint16(b), A: uint16(al)}
+}
+
 func bilinear(c00, c10, c01, c11, xFrac, yFrac float64) float64 {
 	return c00*(1-xFrac)*(1-yFrac) + c10*xFrac*(1-yFrac) +
 		c01*(1-xFrac)*yFrac + c11*xFrac*yFrac
@@ -140,15 +374,16 @@ func imageToTensor(img image.Image) []float32 {
 	h := bounds.Dy()
 
 	tensor := make([]float32, 3*h*w)
+	src := asRGBA64(img)
 
 	for y := 0; y < h; y++ {
 		for x := 0; x < w; x++ {
-			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			c := src.RGBA64At(bounds.Min.X+x, bounds.Min.Y+y)
 
 			// Convert from uint16 [0, 65535] to float32 [0, 1], then normalize
-			rf := float32(r) / 65535.0
-			gf := float32(g) / 65535.0
-			bf := float32(b) / 65535.0
+			rf := float32(c.R) / 65535.0
+			gf := float32(c.G) / 65535.0
+			bf := float32(c.B) / 65535.0
 
 			idx := y*w + x
 			tensor[0*h*w+idx] = (rf - clipMean[0]) / clipStd[0] // R channel
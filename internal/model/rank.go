@@ -0,0 +1,118 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// rankBatch is how many images RankImages runs through the model at once.
+const rankBatch = 16
+
+// RankedImage is how well one image matches a category in RankImages.
+type RankedImage struct {
+	Path string
+
+	// Logit is the category's pooled logit for the image. Score is its
+	// softmax across every ranked image, so a category's scores sum to 1.
+	Logit float32
+	Score float32
+}
+
+// RankImages ranks paths by how well each matches each category, best
+// first, answering questions like "which are the best sunset photos". It
+// reads the model's logits_per_text, the per-category view of the logits
+// that Classify takes per image, and falls back to transposing
+// logits_per_image for exports without it. Prompts follow opts as for
+// Classify; the baseline is left out, since it ranks nothing.
+func (c *CLIPSession) RankImages(paths []string, categories []string, opts ClassifyOptions) (map[string][]RankedImage, error) {
+	opts.NoBaseline = true
+	in := promptInput(c.tokenizer, nil, categories, opts)
+	numPrompts := len(in.owners)
+	if numPrompts == 0 {
+		return nil, fmt.Errorf("no categories to rank images for")
+	}
+	chunk := opts.ChunkSize
+	if chunk <= 0 || chunk > numPrompts {
+		chunk = numPrompts
+	}
+
+	// perText is [numPrompts, len(paths)], filled one batch of images and
+	// one chunk of prompts at a time.
+	perText := make([]float32, numPrompts*len(paths))
+	for b0 := 0; b0 < len(paths); b0 += rankBatch {
+		b1 := min(b0+rankBatch, len(paths))
+		var pixels []float32
+		for _, p := range paths[b0:b1] {
+			px, err := PreprocessImageWithOptions(p, opts.Preprocess)
+			if err != nil {
+				return nil, fmt.Errorf("cannot preprocess %s: %w", p, err)
+			}
+			pixels = append(pixels, px...)
+		}
+		batch := b1 - b0
+		for lo := 0; lo < numPrompts; lo += chunk {
+			hi := min(lo+chunk, numPrompts)
+			perImage, text, err := c.run(in, pixels, batch, lo, hi)
+			if err != nil {
+				return nil, err
+			}
+			if text == nil {
+				text = transpose(perImage, batch, hi-lo)
+			}
+			if len(text) != (hi-lo)*batch {
+				return nil, fmt.Errorf("model returned %d text logits for %d prompts and %d images", len(text), hi-lo, batch)
+			}
+			for i := 0; i < hi-lo; i++ {
+				copy(perText[(lo+i)*len(paths)+b0:], text[i*batch:(i+1)*batch])
+			}
+		}
+	}
+	return rankFromTextLogits(in.labels, in.owners, paths, perText, opts.Pool), nil
+}
+
+// rankFromTextLogits ranks paths for each label given perText, the
+// per-prompt logits shaped [len(owners), len(paths)], where owners[i] is the
+// label of prompt i. A label's prompts are pooled per image with pool.
+func rankFromTextLogits(labels []string, owners []int, paths []string, perText []float32, pool PoolMode) map[string][]RankedImage {
+	n := len(paths)
+	labelLogits := make([][]float32, len(labels))
+	for l := range labelLogits {
+		labelLogits[l] = make([]float32, n)
+	}
+	column := make([]float32, len(owners))
+	for j := 0; j < n; j++ {
+		for i := range owners {
+			column[i] = perText[i*n+j]
+		}
+		for l, v := range poolLogits(column, owners, len(labels), pool) {
+			labelLogits[l][j] = v
+		}
+	}
+
+	ranked := make(map[string][]RankedImage, len(labels))
+	for l, label := range labels {
+		if n == 0 {
+			ranked[label] = []RankedImage{}
+			continue
+		}
+		probs := softmax(labelLogits[l])
+		images := make([]RankedImage, n)
+		for j, p := range paths {
+			images[j] = RankedImage{Path: p, Logit: labelLogits[l][j], Score: probs[j]}
+		}
+		sort.SliceStable(images, func(a, b int) bool { return images[a].Logit > images[b].Logit })
+		ranked[label] = images
+	}
+	return ranked
+}
+
+// transpose returns the rows x cols matrix m transposed.
+func transpose(m []float32, rows, cols int) []float32 {
+	t := make([]float32, len(m))
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			t[c*rows+r] = m[r*cols+c]
+		}
+	}
+	return t
+}
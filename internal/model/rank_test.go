@@ -0,0 +1,57 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRankFromTextLogits(t *testing.T) {
+	labels := []string{"sunset", "document"}
+	// sunset has two prompts; rows are prompts, columns images.
+	owners := []int{0, 0, 1}
+	paths := []string{"a.jpg", "b.jpg", "c.jpg"}
+	perText := []float32{
+		20, 25, 10, // "a photo of sunset"
+		24, 21, 11, // "an evening sky"
+		12, 13, 30, // "a photo of document"
+	}
+
+	ranked := rankFromTextLogits(labels, owners, paths, perText, PoolMax)
+	order := func(images []RankedImage) []string {
+		var names []string
+		for _, im := range images {
+			names = append(names, im.Path)
+		}
+		return names
+	}
+	if got := order(ranked["sunset"]); !slices.Equal(got, []string{"b.jpg", "a.jpg", "c.jpg"}) {
+		t.Errorf("sunset ranking = %v", got)
+	}
+	if top := ranked["sunset"][0]; top.Logit != 25 {
+		t.Errorf("expected the max-pooled logit 25 for b.jpg, got %v", top.Logit)
+	}
+	if got := order(ranked["document"]); !slices.Equal(got, []string{"c.jpg", "b.jpg", "a.jpg"}) {
+		t.Errorf("document ranking = %v", got)
+	}
+
+	var sum float32
+	for _, im := range ranked["document"] {
+		sum += im.Score
+	}
+	if sum < 0.999 || sum > 1.001 {
+		t.Errorf("scores should sum to 1 across images, got %v", sum)
+	}
+
+	// Mean pooling averages a.jpg to 22 and b.jpg to 23.
+	mean := rankFromTextLogits(labels, owners, paths, perText, PoolMean)
+	if top := mean["sunset"][0]; top.Path != "b.jpg" || top.Logit != 23 {
+		t.Errorf("expected b.jpg with mean logit 23, got %+v", top)
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	got := transpose([]float32{1, 2, 3, 4, 5, 6}, 2, 3)
+	if !slices.Equal(got, []float32{1, 4, 2, 5, 3, 6}) {
+		t.Errorf("transpose = %v", got)
+	}
+}
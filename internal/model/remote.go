@@ -0,0 +1,305 @@
+package model
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// RemoteClassifyPath is appended to the server URL for classification
+	// requests.
+	RemoteClassifyPath = "/v1/classify"
+
+	// RemoteInfoPath is appended to the server URL to ask which model it
+	// runs. Servers other than "imgsort serve" need not answer it.
+	RemoteInfoPath = "/v1/info"
+)
+
+// RemoteRequest is the JSON body POSTed to a remote inference server. Image
+// preprocessing and tokenization happen on the client, so the server only
+// has to run the CLIP ONNX model.
+type RemoteRequest struct {
+	// Model is the expected model ID (see ModelID).
+	Model string `json:"model"`
+
+	// PixelValues is the normalized [1, 3, 224, 224] image tensor as
+	// little-endian float32 values, base64-encoded.
+	PixelValues string `json:"pixel_values,omitempty"`
+
+	// Image or Path replace PixelValues when the server preprocesses the
+	// image itself: Image carries the file's contents, and Path names a
+	// file the server reads at the same path. Small and MinSize are the
+	// PreprocessOptions to use, and Name keeps the file's extension.
+	Image   []byte `json:"image,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Small   string `json:"small,omitempty"`
+	MinSize int    `json:"min_size,omitempty"`
+
+	// InputIDs and AttentionMask have one row of 77 tokens per label.
+	InputIDs      [][]int64 `json:"input_ids"`
+	AttentionMask [][]int64 `json:"attention_mask"`
+}
+
+// RemoteResponse is the JSON body returned by a remote inference server.
+type RemoteResponse struct {
+	// LogitsPerImage holds one raw logit per label, in request order.
+	LogitsPerImage []float32 `json:"logits_per_image"`
+
+	// Error is set instead of LogitsPerImage when inference failed.
+	Error string `json:"error,omitempty"`
+}
+
+// RemoteInfo is the JSON body returned for RemoteInfoPath.
+type RemoteInfo struct {
+	Model string `json:"model"`
+}
+
+// RemoteOptions configures a RemoteSession.
+type RemoteOptions struct {
+	// Token is sent as a bearer token when non-empty.
+	Token string
+
+	// Upload sends the image file for the server to preprocess instead of
+	// the pixel tensor, and SharedFS sends its absolute path, for servers
+	// that see the same filesystem at the same paths.
+	Upload   bool
+	SharedFS bool
+
+	// Timeout bounds each request. Zero means two minutes.
+	Timeout time.Duration
+
+	// Concurrency is the number of pooled connections kept to the server.
+	Concurrency int
+
+	// Retries is how often a request is retried after a network error or a
+	// 502/503/504 response.
+	Retries int
+}
+
+// RemoteSession classifies images by sending prepared inputs to a remote
+// inference server. It is safe for concurrent use.
+type RemoteSession struct {
+	baseURL   string
+	opts      RemoteOptions
+	client    *http.Client
+	tokenizer *Tokenizer
+	prompts   promptCache
+}
+
+// remoteRetryBackoff is the wait before the first retry; it doubles each
+// time.
+var remoteRetryBackoff = 500 * time.Millisecond
+
+// NewRemoteSession creates a session that sends requests to baseURL. The
+// tokenizer files must already be present in the models directory.
+func NewRemoteSession(baseURL string, opts RemoteOptions) (*RemoteSession, error) {
+	tokenizer, err := TokenizerFromModelsDir()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load tokenizer: %w", err)
+	}
+	return newRemoteSession(baseURL, tokenizer, opts), nil
+}
+
+func newRemoteSession(baseURL string, tokenizer *Tokenizer, opts RemoteOptions) *RemoteSession {
+	if opts.Timeout == 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = max(opts.Concurrency, 1)
+	return &RemoteSession{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		opts:      opts,
+		client:    &http.Client{Timeout: opts.Timeout, Transport: transport},
+		tokenizer: tokenizer,
+	}
+}
+
+// CheckModel asks the server which model it runs, so a mismatch stops the
+// run before any image is sent rather than failing every one of them.
+func (r *RemoteSession) CheckModel() error {
+	var info RemoteInfo
+	if err := r.do(http.MethodGet, RemoteInfoPath, nil, &info); err != nil {
+		return fmt.Errorf("cannot reach inference server at %s: %w", r.baseURL, err)
+	}
+	if info.Model != ModelID {
+		return fmt.Errorf("server at %s runs model %q but this imgsort uses %q", r.baseURL, info.Model, ModelID)
+	}
+	return nil
+}
+
+// ClassifyWithOptions tokenizes, and unless the server preprocesses,
+// prepares the image locally, runs inference on the remote server, and
+// returns softmaxed scores as CLIPSession does.
+func (r *RemoteSession) ClassifyWithOptions(imagePath string, categories []string, opts ClassifyOptions) (map[string]float32, error) {
+	prompts := r.prompts.get(r.tokenizer, categories, opts)
+	req := RemoteRequest{
+		Model:         ModelID,
+		InputIDs:      rows(prompts.tokenIDs, contextLen),
+		AttentionMask: rows(prompts.attentionMask, contextLen),
+	}
+	in := &modelInput{preparedPrompts: prompts, source: imagePath}
+	switch {
+	case r.opts.SharedFS || r.opts.Upload:
+		req.Name = filepath.Base(imagePath)
+		req.Small = string(opts.Preprocess.Small)
+		req.MinSize = opts.Preprocess.MinSize
+		if r.opts.SharedFS {
+			abs, err := filepath.Abs(imagePath)
+			if err != nil {
+				return nil, err
+			}
+			req.Path = abs
+		} else {
+			data, err := os.ReadFile(imagePath)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read image: %w", err)
+			}
+			req.Image = data
+		}
+	default:
+		var err error
+		in, err = prepareInputWith(prompts, imagePath, opts)
+		if err != nil {
+			return nil, err
+		}
+		req.PixelValues = EncodeFloat32s(in.pixelValues)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode request: %w", err)
+	}
+	var out RemoteResponse
+	if err := r.do(http.MethodPost, RemoteClassifyPath, body, &out); err != nil {
+		return nil, fmt.Errorf("remote inference failed: %w", err)
+	}
+	if out.Error != "" {
+		return nil, fmt.Errorf("remote inference failed: %s", out.Error)
+	}
+
+	scores, err := in.scores(out.LogitsPerImage, opts.Pool)
+	if err != nil {
+		return nil, fmt.Errorf("remote inference failed: %w", err)
+	}
+	if opts.Trace != nil {
+		in.trace(opts.Trace, out.LogitsPerImage, 0, scores)
+	}
+	return scores, nil
+}
+
+// remoteStatusError is a non-200 response from the server.
+type remoteStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *remoteStatusError) Error() string {
+	if e.msg == "" {
+		return fmt.Sprintf("HTTP %d", e.code)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.code, e.msg)
+}
+
+// retryable reports whether a failed request may succeed if sent again.
+func retryable(err error) bool {
+	var se *remoteStatusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusBadGateway || se.code == http.StatusServiceUnavailable ||
+			se.code == http.StatusGatewayTimeout
+	}
+	return true // network errors and timeouts
+}
+
+// do sends a request, retrying transient failures, and decodes the JSON
+// response into out.
+func (r *RemoteSession) do(method, path string, body []byte, out any) error {
+	backoff := remoteRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := r.doOnce(method, path, body, out)
+		if err == nil || attempt >= r.opts.Retries || !retryable(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (r *RemoteSession) doOnce(method, path string, body []byte, out any) error {
+	req, err := http.NewRequest(method, r.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.opts.Token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e RemoteResponse
+		json.Unmarshal(data, &e)
+		return &remoteStatusError{code: resp.StatusCode, msg: e.Error}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid response from server: %w", err)
+	}
+	return nil
+}
+
+// EncodeFloat32s encodes values as base64 little-endian float32s, the
+// format of RemoteRequest.PixelValues.
+func EncodeFloat32s(values []float32) string {
+	buf := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// DecodeFloat32s reverses EncodeFloat32s.
+func DecodeFloat32s(s string) ([]float32, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, fmt.Errorf("float32 data has %d bytes, not a multiple of 4", len(buf))
+	}
+	values := make([]float32, len(buf)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return values, nil
+}
+
+// rows splits a flat row-major slice into rows of width n.
+func rows(flat []int64, n int) [][]int64 {
+	out := make([][]int64, 0, len(flat)/n)
+	for i := 0; i+n <= len(flat); i += n {
+		out = append(out, flat[i:i+n])
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testTokenizer builds a tiny tokenizer whose vocab only covers the
+// start/end tokens; unknown words encode to nothing, which is enough to
+// exercise request framing.
+func testTokenizer(t *testing.T) *Tokenizer {
+	t.Helper()
+	dir := t.TempDir()
+	vocab := filepath.Join(dir, "vocab.json")
+	merges := filepath.Join(dir, "merges.txt")
+	if err := os.WriteFile(vocab, []byte(`{"<|startoftext|>": 1, "<|endoftext|>": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(merges, []byte("#version: 0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := LoadTokenizer(vocab, merges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func testImage(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "img.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 32, 32))); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRemoteSessionClassify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != RemoteClassifyPath {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req RemoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		pixels, err := DecodeFloat32s(req.PixelValues)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pixels) != 3*clipImageSize*clipImageSize {
+			t.Errorf("expected %d pixel values, got %d", 3*clipImageSize*clipImageSize, len(pixels))
+		}
+		if req.Model != ModelID || len(req.InputIDs) != 3 || len(req.AttentionMask) != 3 || len(req.InputIDs[0]) != contextLen {
+			t.Errorf("unexpected request shape: model %q, %d input rows", req.Model, len(req.InputIDs))
+		}
+		json.NewEncoder(w).Encode(RemoteResponse{LogitsPerImage: []float32{0, 10, 0}})
+	}))
+	defer srv.Close()
+
+	r := newRemoteSession(srv.URL+"/", testTokenizer(t), RemoteOptions{})
+	scores, err := r.ClassifyWithOptions(testImage(t), []string{"cats", "dogs"}, ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores["cats"] < 0.99 || len(scores) != 3 {
+		t.Errorf("expected cats to win after softmax, got %v", scores)
+	}
+}
+
+func TestRemoteSessionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"server error", http.StatusInternalServerError, `{"error": "out of memory"}`, "out of memory"},
+		{"wrong logit count", http.StatusOK, `{"logits_per_image": [1]}`, "1 logits for 3 labels"},
+		{"not json", http.StatusBadGateway, `<html>`, "HTTP 502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			r := newRemoteSession(srv.URL, testTokenizer(t), RemoteOptions{})
+			_, err := r.ClassifyWithOptions(testImage(t), []string{"cats", "dogs"}, ClassifyOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRemoteSessionRetriesUnavailable(t *testing.T) {
+	orig := remoteRetryBackoff
+	remoteRetryBackoff = 0
+	t.Cleanup(func() { remoteRetryBackoff = orig })
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "busy"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(RemoteInfo{Model: ModelID})
+	}))
+	defer srv.Close()
+
+	r := newRemoteSession(srv.URL, testTokenizer(t), RemoteOptions{Retries: 2})
+	if err := r.CheckModel(); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestFloat32RoundTrip(t *testing.T) {
+	in := []float32{0, 1.5, -2.25, 3e-8}
+	out, err := DecodeFloat32s(EncodeFloat32s(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range in {
+		if in[i] != out[i] {
+			t.Errorf("value %d: got %v, want %v", i, out[i], in[i])
+		}
+	}
+}
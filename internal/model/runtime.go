@@ -0,0 +1,154 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/onnxlib"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// MinONNXRuntimeVersion is the oldest ONNX Runtime release whose C API the
+// onnxruntime_go binding supports.
+const MinONNXRuntimeVersion = "1.22.0"
+
+// RuntimeInfo describes the ONNX Runtime library that was loaded.
+type RuntimeInfo struct {
+	LibraryPath string
+	Version     string
+}
+
+// ResolveLibraryPath returns the ONNX Runtime shared library to load.
+// If explicitPath is empty, it tries the embedded library first, then platform defaults.
+func ResolveLibraryPath(explicitPath string) string {
+	if explicitPath != "" {
+		return explicitPath
+	}
+	if extractedPath, err := onnxlib.Extract(); err == nil {
+		return extractedPath
+	}
+	return defaultONNXRuntimePath()
+}
+
+// LoadRuntime loads the ONNX Runtime library like InitRuntime. Without an
+// explicit path it loads the embedded library, re-extracting it elsewhere
+// if its first location is mounted noexec, and falls back to the platform
+// default when nothing is embedded.
+func LoadRuntime(explicitPath string) (*RuntimeInfo, error) {
+	if explicitPath != "" {
+		return InitRuntime(explicitPath)
+	}
+	var info *RuntimeInfo
+	_, err := onnxlib.Load(func(path string) error {
+		var err error
+		info, err = InitRuntime(path)
+		return err
+	})
+	switch {
+	case err == nil:
+		return info, nil
+	case errors.Is(err, onnxlib.ErrNoLibrary):
+		return InitRuntime(defaultONNXRuntimePath())
+	}
+	return nil, err
+}
+
+// InitRuntime loads the ONNX Runtime library at libPath and verifies that its
+// version is supported. On a version mismatch the environment is torn down
+// again and an error naming the library, its version, and the required
+// version is returned.
+func InitRuntime(libPath string) (*RuntimeInfo, error) {
+	ort.SetSharedLibraryPath(libPath)
+	if err := ort.InitializeEnvironment(); err != nil {
+		return nil, fmt.Errorf("cannot initialize ONNX Runtime from %s: %w (%s)", libPath, err, runtimeHint)
+	}
+
+	info := &RuntimeInfo{LibraryPath: libPath, Version: ort.GetVersion()}
+	if err := CheckRuntimeVersion(info.Version); err != nil {
+		ort.DestroyEnvironment()
+		return nil, fmt.Errorf("incompatible ONNX Runtime at %s: %w (%s)", libPath, err, runtimeHint)
+	}
+	return info, nil
+}
+
+// DestroyRuntime releases the environment created by InitRuntime.
+func DestroyRuntime() {
+	ort.DestroyEnvironment()
+}
+
+// runtimeHint suggests how to fix a missing or incompatible runtime.
+const runtimeHint = "use a release binary with the embedded library, or point --onnxruntime at ONNX Runtime " +
+	MinONNXRuntimeVersion + " or newer"
+
+// CheckRuntimeVersion returns an error if version is older than
+// MinONNXRuntimeVersion or cannot be parsed.
+func CheckRuntimeVersion(version string) error {
+	cmp, err := compareVersions(version, MinONNXRuntimeVersion)
+	if err != nil {
+		return err
+	}
+	if cmp < 0 {
+		return fmt.Errorf("version %s is older than the required %s", version, MinONNXRuntimeVersion)
+	}
+	return nil
+}
+
+// compareVersions compares two MAJOR.MINOR.PATCH[-PRERELEASE] versions and
+// returns -1, 0, or 1. A pre-release sorts before the release it precedes.
+func compareVersions(a, b string) (int, error) {
+	va, preA, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, preB, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0, nil
+	case preA == "":
+		return 1, nil
+	case preB == "":
+		return -1, nil
+	case preA < preB:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// parseVersion splits a version string into its numeric parts and an
+// optional pre-release suffix. Missing minor or patch numbers are zero.
+func parseVersion(s string) ([3]int, string, error) {
+	var v [3]int
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	core, pre, _ := strings.Cut(s, "-")
+	core, _, _ = strings.Cut(core, "+") // ignore build metadata
+
+	parts := strings.Split(core, ".")
+	if core == "" || len(parts) > 3 {
+		return v, "", fmt.Errorf("malformed version %q", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, "", fmt.Errorf("malformed version %q", s)
+		}
+		v[i] = n
+	}
+	return v, pre, nil
+}
@@ -0,0 +1,51 @@
+package model
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.22.0", "1.22.0", 0},
+		{"1.23.1", "1.22.0", 1},
+		{"1.9.0", "1.22.0", -1},
+		{"2.0", "1.22.0", 1},
+		{"v1.22.0", "1.22.0", 0},
+		{"1.22.0+cuda", "1.22.0", 0},
+		{"1.22.0-dev", "1.22.0", -1},
+		{"1.22.0", "1.22.0-rc1", 1},
+		{"1.22.0-rc1", "1.22.0-rc2", -1},
+		{"1.23.0-dev", "1.22.0", 1},
+	}
+	for _, tt := range tests {
+		got, err := compareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("compareVersions(%q, %q) failed: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersionsMalformed(t *testing.T) {
+	for _, v := range []string{"", "abc", "1.x.0", "1.2.3.4", "1..2", "-1.0.0"} {
+		if _, err := compareVersions(v, "1.22.0"); err == nil {
+			t.Errorf("expected error for malformed version %q", v)
+		}
+	}
+}
+
+func TestCheckRuntimeVersion(t *testing.T) {
+	if err := CheckRuntimeVersion(MinONNXRuntimeVersion); err != nil {
+		t.Errorf("minimum version should be accepted: %v", err)
+	}
+	if err := CheckRuntimeVersion("1.16.3"); err == nil {
+		t.Error("expected error for old version")
+	}
+	if err := CheckRuntimeVersion("unknown"); err == nil {
+		t.Error("expected error for malformed version")
+	}
+}
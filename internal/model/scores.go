@@ -0,0 +1,39 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+)
+
+// CategoryScore is one category's score for an image.
+type CategoryScore struct {
+	Category string
+	Score    float32
+
+	// Baseline is true for the baseline prompt's score, whose Category is
+	// BaselineCategory.
+	Baseline bool
+}
+
+// RankScores returns the scores Classify returns sorted best first, with
+// ties going to the alphabetically first category so the order is
+// repeatable. The baseline is included and flagged.
+func RankScores(scores map[string]float32) []CategoryScore {
+	ranked := make([]CategoryScore, 0, len(scores))
+	for cat, score := range scores {
+		ranked = append(ranked, CategoryScore{Category: cat, Score: score, Baseline: cat == BaselineCategory})
+	}
+	slices.SortFunc(ranked, func(a, b CategoryScore) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Category, b.Category))
+	})
+	return ranked
+}
+
+// ClassifyRanked is like Classify, with the scores ranked by RankScores.
+func (c *CLIPSession) ClassifyRanked(imagePath string, categories []string) ([]CategoryScore, error) {
+	scores, err := c.Classify(imagePath, categories)
+	if err != nil {
+		return nil, err
+	}
+	return RankScores(scores), nil
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRankScores(t *testing.T) {
+	ranked := RankScores(map[string]float32{
+		"city":           0.2,
+		BaselineCategory: 0.3,
+		"beach":          0.4,
+		"forest":         0.2,
+		"document":       0,
+	})
+	want := []CategoryScore{
+		{"beach", 0.4, false},
+		{BaselineCategory, 0.3, true},
+		{"city", 0.2, false}, // ties go to the alphabetically first
+		{"forest", 0.2, false},
+		{"document", 0, false},
+	}
+	if !slices.Equal(ranked, want) {
+		t.Errorf("RankScores = %v, want %v", ranked, want)
+	}
+
+	if ranked := RankScores(nil); len(ranked) != 0 {
+		t.Errorf("expected an empty ranking, got %v", ranked)
+	}
+}
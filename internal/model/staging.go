@@ -0,0 +1,138 @@
+package model
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LockFile records the SHA-256 of each staged model file, in the format
+// sha256sum writes, so "sha256sum -c" can check a models directory too.
+const LockFile = "SHA256SUMS"
+
+// ErrNotStaged is returned by VerifyFiles when files are missing or do not
+// match their recorded hashes.
+var ErrNotStaged = errors.New("model files are not staged")
+
+// FileHash returns the hex SHA-256 of the file at path.
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteLock hashes files in the models directory and records them in
+// LockFile, so later offline runs can check that nothing changed.
+func WriteLock(files []ModelFile) error {
+	dir, err := ModelsDir()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, m := range files {
+		hash, err := FileHash(filepath.Join(dir, m.Name))
+		if err != nil {
+			return fmt.Errorf("cannot hash %s: %w", m.Name, err)
+		}
+		fmt.Fprintf(&b, "%s  %s\n", hash, m.Name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, LockFile), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", LockFile, err)
+	}
+	return nil
+}
+
+// ReadLock returns the hashes recorded in the models directory's LockFile
+// by file name, or nil if there is none.
+func ReadLock() (map[string]string, error) {
+	dir, err := ModelsDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, LockFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hash, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		if !ok || len(hash) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("%s line %d: expected \"<sha256>  <file>\"", LockFile, n)
+		}
+		hashes[name] = strings.ToLower(hash)
+	}
+	return hashes, scanner.Err()
+}
+
+// ExpectedHash returns the hash a file must have: its pinned SHA256, or
+// the one recorded in the lock file, or "" if neither is known.
+func ExpectedHash(m ModelFile, lock map[string]string) string {
+	if m.SHA256 != "" {
+		return m.SHA256
+	}
+	return lock[m.Name]
+}
+
+// VerifyFiles checks that files are present in the models directory and
+// match their expected hashes, without downloading anything. The error
+// wraps ErrNotStaged and lists every problem found.
+func VerifyFiles(files []ModelFile) error {
+	dir, err := ModelsDir()
+	if err != nil {
+		return err
+	}
+	lock, err := ReadLock()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, m := range files {
+		path := filepath.Join(dir, m.Name)
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, m.Name+" is missing")
+			continue
+		}
+		want := ExpectedHash(m, lock)
+		if want == "" {
+			continue
+		}
+		got, err := FileHash(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("cannot read %s: %v", m.Name, err))
+			continue
+		}
+		if got != want {
+			problems = append(problems, fmt.Sprintf("%s has SHA-256 %s, expected %s", m.Name, got, want))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w in %s: %s (run \"imgsort models fetch\" with the same --models-dir to stage them)",
+			ErrNotStaged, dir, strings.Join(problems, "; "))
+	}
+	return nil
+}
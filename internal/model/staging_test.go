@@ -0,0 +1,168 @@
+package model
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stageModels points ModelsDir at a temporary directory holding files with
+// the given contents, and returns the directory.
+func stageModels(t *testing.T, contents map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	SetModelsDir(dir)
+	t.Cleanup(func() { SetModelsDir("") })
+	for name, data := range contents {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSetModelsDir(t *testing.T) {
+	t.Setenv("IMGSORT_HOME", "/srv/imgsort")
+	t.Setenv("IMGSORT_MODELS_DIR", "")
+	if dir, err := ModelsDir(); err != nil || dir != filepath.Join("/srv/imgsort", "models") {
+		t.Errorf("ModelsDir() = %q, %v; want it under IMGSORT_HOME", dir, err)
+	}
+	t.Setenv("IMGSORT_MODELS_DIR", "/srv/models")
+	if dir, _ := ModelsDir(); dir != "/srv/models" {
+		t.Errorf("ModelsDir() = %q, want IMGSORT_MODELS_DIR", dir)
+	}
+	SetModelsDir("/opt/models")
+	defer SetModelsDir("")
+	if dir, _ := ModelsDir(); dir != "/opt/models" {
+		t.Errorf("ModelsDir() = %q after SetModelsDir", dir)
+	}
+}
+
+// unwritableHome points IMGSORT_HOME below a regular file, where nothing
+// can be created even by root.
+func unwritableHome(t *testing.T) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	home := filepath.Join(file, "home")
+	t.Setenv("IMGSORT_HOME", home)
+	return home
+}
+
+func TestEnsureFilesReadOnlyHome(t *testing.T) {
+	unwritableHome(t)
+	staged := t.TempDir()
+	for _, m := range RequiredFiles {
+		if err := os.WriteFile(filepath.Join(staged, m.Name), []byte(m.Name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("IMGSORT_MODELS_DIR", staged)
+	if err := EnsureFiles(RequiredFiles, nil); err != nil {
+		t.Errorf("staged models should need no writes, got %v", err)
+	}
+
+	t.Setenv("IMGSORT_MODELS_DIR", "")
+	err := EnsureFiles(RequiredFiles, nil)
+	if err == nil || !strings.Contains(err.Error(), "set IMGSORT_MODELS_DIR") {
+		t.Errorf("expected an error naming IMGSORT_MODELS_DIR, got %v", err)
+	}
+}
+
+func TestVerifyFilesWithLock(t *testing.T) {
+	files := []ModelFile{{Name: "model.onnx"}, {Name: "vocab.json"}}
+	dir := stageModels(t, map[string]string{"model.onnx": "weights", "vocab.json": "{}"})
+
+	if err := VerifyFiles(files); err != nil {
+		t.Fatalf("files without a lock should verify by presence: %v", err)
+	}
+	if err := WriteLock(files); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := ReadLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := FileHash(filepath.Join(dir, "model.onnx")); lock["model.onnx"] != want {
+		t.Errorf("lock has %q for model.onnx, want %q", lock["model.onnx"], want)
+	}
+	if err := VerifyFiles(files); err != nil {
+		t.Fatalf("unchanged files should verify: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "model.onnx"), []byte("other weights"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(dir, "vocab.json"))
+	err = VerifyFiles(files)
+	if !errors.Is(err, ErrNotStaged) {
+		t.Fatalf("expected ErrNotStaged, got %v", err)
+	}
+	for _, want := range []string{"model.onnx has SHA-256", "vocab.json is missing"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %q", err, want)
+		}
+	}
+}
+
+func TestVerifyFilesPinnedHash(t *testing.T) {
+	stageModels(t, map[string]string{"model.onnx": "weights"})
+	pinned := []ModelFile{{Name: "model.onnx", SHA256: strings.Repeat("0", 64)}}
+	if err := VerifyFiles(pinned); !errors.Is(err, ErrNotStaged) {
+		t.Errorf("a pinned hash should be checked, got %v", err)
+	}
+}
+
+func TestVerifyFilesNeverDownloads(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	stageModels(t, nil)
+	if err := VerifyFiles([]ModelFile{{Name: "model.onnx", URL: srv.URL}}); !errors.Is(err, ErrNotStaged) {
+		t.Errorf("expected ErrNotStaged for a missing file, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("VerifyFiles made %d requests", requests)
+	}
+}
+
+func TestEnsureFilesChecksLock(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer srv.Close()
+
+	files := []ModelFile{{Name: "vocab.json", URL: srv.URL}}
+	dir := stageModels(t, map[string]string{"vocab.json": "{}"})
+	if err := WriteLock(files); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(dir, "vocab.json"))
+
+	err := EnsureFiles(files, nil)
+	if err == nil || !strings.Contains(err.Error(), "SHA256 mismatch") {
+		t.Errorf("a download that does not match the lock should fail, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vocab.json")); !os.IsNotExist(err) {
+		t.Error("a mismatched download should not be kept")
+	}
+}
+
+func TestReadLockMalformed(t *testing.T) {
+	dir := stageModels(t, nil)
+	if err := os.WriteFile(filepath.Join(dir, LockFile), []byte("abc model.onnx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLock(); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected a line error, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// maxIdleTensorSets caps how many unused tensor sets a tensorPool keeps.
+// A run reuses one set per chunk shape, so this covers a chunked category
+// list, a ranking batch, and a few concurrent server requests.
+const maxIdleTensorSets = 8
+
+// tensorSet holds the input and output tensors for one run shape: numImages
+// images against numLabels prompt rows. Inputs are filled in place before
+// each run, so classifying image after image does not allocate four or five
+// tensors each time.
+type tensorSet struct {
+	numImages, numLabels int
+
+	inputIDs       *ort.Tensor[int64]
+	pixels         *ort.Tensor[float32]
+	attentionMask  *ort.Tensor[int64]
+	logitsPerImage *ort.Tensor[float32]
+	logitsPerText  *ort.Tensor[float32]
+
+	inputs, outputs []ort.Value
+}
+
+// newTensorSet creates the tensors for a run of numImages images against
+// numLabels prompt rows, with the inputs and outputs nodes calls for.
+func newTensorSet(nodes nodeNames, numImages, numLabels int) (*tensorSet, error) {
+	ts := &tensorSet{numImages: numImages, numLabels: numLabels}
+	rows, images := int64(numLabels), int64(numImages)
+	var err error
+	if ts.inputIDs, err = ort.NewTensor(ort.NewShape(rows, int64(contextLen)), make([]int64, numLabels*contextLen)); err != nil {
+		return nil, fmt.Errorf("cannot create input_ids tensor: %w", err)
+	}
+	ts.inputs = append(ts.inputs, ts.inputIDs)
+	pixels := make([]float32, numImages*3*clipImageSize*clipImageSize)
+	if ts.pixels, err = ort.NewTensor(ort.NewShape(images, 3, int64(clipImageSize), int64(clipImageSize)), pixels); err != nil {
+		ts.destroy()
+		return nil, fmt.Errorf("cannot create pixel_values tensor: %w", err)
+	}
+	ts.inputs = append(ts.inputs, ts.pixels)
+	if nodes.attentionMask != "" {
+		if ts.attentionMask, err = ort.NewTensor(ort.NewShape(rows, int64(contextLen)), make([]int64, numLabels*contextLen)); err != nil {
+			ts.destroy()
+			return nil, fmt.Errorf("cannot create attention_mask tensor: %w", err)
+		}
+		ts.inputs = append(ts.inputs, ts.attentionMask)
+	}
+
+	if ts.logitsPerImage, err = ort.NewEmptyTensor[float32](ort.NewShape(images, rows)); err != nil {
+		ts.destroy()
+		return nil, fmt.Errorf("cannot create output tensor: %w", err)
+	}
+	ts.outputs = append(ts.outputs, ts.logitsPerImage)
+	if nodes.logitsPerText != "" {
+		if ts.logitsPerText, err = ort.NewEmptyTensor[float32](ort.NewShape(rows, images)); err != nil {
+			ts.destroy()
+			return nil, fmt.Errorf("cannot create output tensor: %w", err)
+		}
+		ts.outputs = append(ts.outputs, ts.logitsPerText)
+	}
+	return ts, nil
+}
+
+// destroy releases the set's tensors.
+func (ts *tensorSet) destroy() {
+	for _, v := range append(ts.inputs, ts.outputs...) {
+		v.Destroy()
+	}
+}
+
+// tensorPool keeps tensor sets between runs. A set is used by one run at a
+// time: get takes it out of the pool and put returns it. It is safe for
+// concurrent use.
+type tensorPool struct {
+	mu   sync.Mutex
+	idle []*tensorSet
+}
+
+// get returns an idle set of the given shape, or a new one.
+func (p *tensorPool) get(nodes nodeNames, numImages, numLabels int) (*tensorSet, error) {
+	p.mu.Lock()
+	for i, ts := range p.idle {
+		if ts.numImages == numImages && ts.numLabels == numLabels {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			p.mu.Unlock()
+			return ts, nil
+		}
+	}
+	p.mu.Unlock()
+	return newTensorSet(nodes, numImages, numLabels)
+}
+
+// put returns ts to the pool, destroying the least recently used set if
+// the pool is full.
+func (p *tensorPool) put(ts *tensorSet) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle = append(p.idle, ts)
+	if len(p.idle) > maxIdleTensorSets {
+		p.idle[0].destroy()
+		p.idle = p.idle[1:]
+	}
+}
+
+// destroy releases every idle set.
+func (p *tensorPool) destroy() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ts := range p.idle {
+		ts.destroy()
+	}
+	p.idle = nil
+}
@@ -2,10 +2,15 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 )
@@ -25,6 +30,12 @@ type Tokenizer struct {
 	pat        *regexp.Regexp
 	sotTokenID int
 	eotTokenID int
+	padTokenID int
+
+	// bpeCache memoizes bpe by word. Prompts repeat words like "a", "photo",
+	// and "of", and images may be classified from several goroutines.
+	mu       sync.Mutex
+	bpeCache map[string][]string
 }
 
 // LoadTokenizer loads the tokenizer from vocab.json and merges.txt files.
@@ -67,6 +78,18 @@ func LoadTokenizer(vocabPath, mergesPath string) (*Tokenizer, error) {
 		decoder[v] = k
 	}
 
+	pad, err := padToken(filepath.Join(filepath.Dir(vocabPath), tokenizerConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	padID := encoder[eotToken]
+	if pad != "" {
+		var ok bool
+		if padID, ok = encoder[pad]; !ok {
+			return nil, fmt.Errorf("pad token %q from %s is not in the vocabulary", pad, tokenizerConfigFile)
+		}
+	}
+
 	// CLIP tokenizer pattern
 	pat := regexp.MustCompile(`<\|startoftext\|>|<\|endoftext\|>|'s|'t|'re|'ve|'m|'ll|'d|[\pL]+|[\pN]|[^\s\pL\pN]+`)
 
@@ -77,13 +100,27 @@ func LoadTokenizer(vocabPath, mergesPath string) (*Tokenizer, error) {
 		pat:        pat,
 		sotTokenID: encoder[sotToken],
 		eotTokenID: encoder[eotToken],
+		padTokenID: padID,
+		bpeCache:   make(map[string][]string),
 	}
 	return t, nil
 }
 
-// Encode tokenizes a text string and returns token IDs padded/truncated to contextLen.
+// Encode tokenizes a text string and returns token IDs padded/truncated to
+// contextLen. Text too long to fit is cut short, keeping the end-of-text
+// token last, as the model pools its features at that token.
 func (t *Tokenizer) Encode(text string) []int64 {
-	text = strings.ToLower(strings.TrimSpace(text))
+	ids, _ := t.EncodeWithMask(text)
+	return ids
+}
+
+// EncodeWithMask is like Encode, and also returns the attention mask: 1 for
+// each of the text's tokens, start and end tokens included, and 0 for the
+// padding after them. The mask is built from the text's length rather than
+// from the IDs, since the padding token may also be the end token, and ID 0
+// is "!" in CLIP's vocabulary.
+func (t *Tokenizer) EncodeWithMask(text string) (ids, mask []int64) {
+	text = cleanText(text)
 
 	tokens := []int{t.sotTokenID}
 
@@ -98,13 +135,168 @@ func (t *Tokenizer) Encode(text string) []int64 {
 	}
 
 	tokens = append(tokens, t.eotTokenID)
+	if len(tokens) > contextLen {
+		tokens = tokens[:contextLen]
+		tokens[contextLen-1] = t.eotTokenID
+	}
 
-	// Pad or truncate to context length
-	result := make([]int64, contextLen)
-	for i := 0; i < contextLen && i < len(tokens); i++ {
-		result[i] = int64(tokens[i])
+	ids = make([]int64, contextLen)
+	mask = make([]int64, contextLen)
+	for i := range contextLen {
+		if i < len(tokens) {
+			ids[i], mask[i] = int64(tokens[i]), 1
+		} else {
+			ids[i] = int64(t.padTokenID)
+		}
 	}
-	return result
+	return ids, mask
+}
+
+// tokenizerConfigFile is the Hugging Face tokenizer configuration that may
+// sit next to vocab.json, naming the padding token.
+const tokenizerConfigFile = "tokenizer_config.json"
+
+// padToken returns the padding token named by the tokenizer configuration
+// at path, or "" when there is no configuration or it names none, in which
+// case the end-of-text token pads prompts, as Hugging Face's CLIP tokenizer
+// does by default.
+func padToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot read tokenizer config: %w", err)
+	}
+	// pad_token is either the token itself or an object holding it.
+	var config struct {
+		PadToken json.RawMessage `json:"pad_token"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("cannot parse tokenizer config: %w", err)
+	}
+	if len(config.PadToken) == 0 || string(config.PadToken) == "null" {
+		return "", nil
+	}
+	var token string
+	if err := json.Unmarshal(config.PadToken, &token); err == nil {
+		return token, nil
+	}
+	var added struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(config.PadToken, &added); err != nil || added.Content == "" {
+		return "", fmt.Errorf("cannot parse tokenizer config: unexpected pad_token %s", config.PadToken)
+	}
+	return added.Content, nil
+}
+
+// cleanText normalizes text the way CLIP's reference tokenizer does before
+// splitting it into words: ftfy's fixes for HTML entities, curly quotes,
+// fullwidth ASCII, Latin ligatures, and control characters, then every run
+// of Unicode whitespace collapsed to one space, then Python's lowercasing.
+// ftfy's Unicode normalization (NFC) and mojibake repair are not applied,
+// so decomposed accents tokenize differently from precomposed ones.
+func cleanText(text string) string {
+	text = html.UnescapeString(html.UnescapeString(text))
+
+	var b strings.Builder
+	space := false
+	for _, r := range text {
+		switch {
+		case isControl(r):
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		switch {
+		case r == '\u02BC' || (r >= '\u2018' && r <= '\u201B'):
+			b.WriteByte('\'')
+		case r >= '\u201C' && r <= '\u201F':
+			b.WriteByte('"')
+		case r >= '\uFF01' && r <= '\uFF5E':
+			b.WriteRune(r - 0xFEE0)
+		case ligatures[r] != "":
+			b.WriteString(ligatures[r])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return lowerPython(b.String())
+}
+
+// ligatures maps Latin ligatures to the letters ftfy replaces them with.
+var ligatures = map[rune]string{
+	'\uFB00': "ff", '\uFB01': "fi", '\uFB02': "fl", '\uFB03': "ffi",
+	'\uFB04': "ffl", '\uFB05': "st", '\uFB06': "st",
+}
+
+// isControl reports whether ftfy removes r: C0 controls other than
+// whitespace, DEL, and the byte order mark.
+func isControl(r rune) bool {
+	return (r < 0x20 && !unicode.IsSpace(r)) || r == 0x0B || r == 0x7F || r == '\uFEFF'
+}
+
+// lowerPython lowercases s as Python's str.lower does, which differs from
+// strings.ToLower in two cases CLIP's vocabulary sees: a capital sigma
+// ending a word becomes final sigma, and dotted capital I becomes "i"
+// followed by a combining dot above.
+func lowerPython(s string) string {
+	if !strings.ContainsAny(s, "\u03A3\u0130") {
+		return strings.ToLower(s)
+	}
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		switch r {
+		case '\u0130':
+			b.WriteString("i\u0307")
+		case '\u03A3':
+			if casedBefore(runes, i) && !casedAfter(runes, i) {
+				b.WriteRune('\u03C2')
+			} else {
+				b.WriteRune('\u03C3')
+			}
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// casedBefore and casedAfter report whether a cased letter comes before or
+// after runes[i], skipping case-ignorable characters, for Unicode's
+// Final_Sigma rule.
+func casedBefore(runes []rune, i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		if !caseIgnorable(runes[j]) {
+			return cased(runes[j])
+		}
+	}
+	return false
+}
+
+func casedAfter(runes []rune, i int) bool {
+	for _, r := range runes[i+1:] {
+		if !caseIgnorable(r) {
+			return cased(r)
+		}
+	}
+	return false
+}
+
+func cased(r rune) bool {
+	return unicode.IsUpper(r) || unicode.IsLower(r) || unicode.IsTitle(r)
+}
+
+func caseIgnorable(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf, unicode.Lm, unicode.Sk) ||
+		strings.ContainsRune("'.:\u00B7\u2019", r)
 }
 
 // encodeBytes converts a string to byte-level BPE tokens (CLIP uses byte-level encoding).
@@ -116,8 +308,25 @@ func (t *Tokenizer) encodeBytes(s string) string {
 	return string(result)
 }
 
-// bpe applies BPE merges to a word, returning the final subword tokens.
+// bpe returns the subword tokens for a word, computing them once per
+// Tokenizer. The returned slice is shared and must not be modified.
 func (t *Tokenizer) bpe(token string) []string {
+	t.mu.Lock()
+	word, ok := t.bpeCache[token]
+	t.mu.Unlock()
+	if ok {
+		return word
+	}
+
+	word = t.mergeBPE(token)
+	t.mu.Lock()
+	t.bpeCache[token] = word
+	t.mu.Unlock()
+	return word
+}
+
+// mergeBPE applies BPE merges to a word, returning the final subword tokens.
+func (t *Tokenizer) mergeBPE(token string) []string {
 	if len(token) == 0 {
 		return nil
 	}
@@ -0,0 +1,287 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+)
+
+// mergingTokenizer loads a small tokenizer whose merges build every word of the
+// default category prompts left to right, so encoding them exercises many
+// merge steps without the real vocabulary.
+func mergingTokenizer(tb testing.TB) *Tokenizer {
+	tb.Helper()
+	vocab := map[string]int{sotToken: 0, eotToken: 1}
+	add := func(tok string) {
+		if _, ok := vocab[tok]; !ok {
+			vocab[tok] = len(vocab)
+		}
+	}
+	merges := []string{"#version: test"}
+	seen := make(map[string]bool)
+
+	for _, cat := range append([]string{"a photo of"}, categories.DefaultCategories...) {
+		words := strings.FieldsFunc(strings.ToLower(cat), func(r rune) bool { return !unicode.IsLetter(r) })
+		for _, w := range words {
+			runes := []rune(w)
+			parts := make([]string, len(runes))
+			for i, r := range runes {
+				parts[i] = string(r)
+			}
+			parts[len(parts)-1] += endOfWordSfx
+			for _, p := range parts {
+				add(p)
+			}
+			prefix := parts[0]
+			for _, p := range parts[1:] {
+				if m := prefix + " " + p; !seen[m] {
+					seen[m] = true
+					merges = append(merges, m)
+				}
+				prefix += p
+				add(prefix)
+			}
+		}
+	}
+
+	dir := tb.TempDir()
+	vocabData, err := json.Marshal(vocab)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	vocabPath := filepath.Join(dir, "vocab.json")
+	mergesPath := filepath.Join(dir, "merges.txt")
+	if err := os.WriteFile(vocabPath, vocabData, 0644); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(mergesPath, []byte(strings.Join(merges, "\n")), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	tok, err := LoadTokenizer(vocabPath, mergesPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return tok
+}
+
+func TestBPECacheMatchesUncached(t *testing.T) {
+	tok := mergingTokenizer(t)
+	for _, cat := range categories.DefaultCategories {
+		prompt := fmt.Sprintf("a photo of %s", cat)
+		for _, match := range tok.pat.FindAllString(strings.ToLower(prompt), -1) {
+			word := tok.encodeBytes(match)
+			want := tok.mergeBPE(word)
+			// The second call is served from the cache.
+			for range 2 {
+				if got := tok.bpe(word); !slices.Equal(got, want) {
+					t.Fatalf("bpe(%q) = %v, want %v", word, got, want)
+				}
+			}
+		}
+	}
+	if _, ok := tok.bpeCache[tok.encodeBytes("photo")]; !ok {
+		t.Error("expected repeated words to be cached")
+	}
+
+	fresh := mergingTokenizer(t)
+	if got, want := tok.EncodeCategories(categories.DefaultCategories), fresh.EncodeCategories(categories.DefaultCategories); !slices.Equal(got, want) {
+		t.Error("encoding with a warm cache differs from a cold one")
+	}
+}
+
+func TestEncodeMergesWords(t *testing.T) {
+	tok := mergingTokenizer(t)
+	ids := tok.Encode("a photo of ocean")
+	want := []int64{int64(tok.sotTokenID), int64(tok.encoder["a</w>"]), int64(tok.encoder["photo</w>"]),
+		int64(tok.encoder["of</w>"]), int64(tok.encoder["ocean</w>"]), int64(tok.eotTokenID)}
+	if !slices.Equal(ids[:len(want)], want) {
+		t.Errorf("Encode = %v, want prefix %v", ids[:len(want)], want)
+	}
+}
+
+func BenchmarkEncodeCategories(b *testing.B) {
+	tok := mergingTokenizer(b)
+	b.Run("cached", func(b *testing.B) {
+		for range b.N {
+			tok.EncodeCategories(categories.DefaultCategories)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for range b.N {
+			tok.bpeCache = make(map[string][]string)
+			tok.EncodeCategories(categories.DefaultCategories)
+		}
+	})
+}
+
+// byteTokenizer loads a tokenizer with the first 512 entries of CLIP's real
+// vocabulary, one per byte with and without the end-of-word marker, built
+// as CLIP's bytes_to_unicode does, and its real start and end token IDs. It
+// has no merges, so its IDs are what the reference tokenizer gives before
+// merging, and they check the byte mapping and word splitting on their own.
+func byteTokenizer(tb testing.TB) *Tokenizer {
+	tb.Helper()
+	var bs []int
+	for b := range 256 {
+		if (b >= '!' && b <= '~') || (b >= 0xA1 && b <= 0xAC) || (b >= 0xAE && b <= 0xFF) {
+			bs = append(bs, b)
+		}
+	}
+	cs := slices.Clone(bs)
+	n := 0
+	for b := range 256 {
+		if !slices.Contains(bs, b) {
+			bs = append(bs, b)
+			cs = append(cs, 256+n)
+			n++
+		}
+	}
+	vocab := map[string]int{sotToken: 49406, eotToken: 49407}
+	for i, c := range cs {
+		vocab[string(rune(c))] = i
+		vocab[string(rune(c))+endOfWordSfx] = i + 256
+	}
+
+	dir := tb.TempDir()
+	vocabData, err := json.Marshal(vocab)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	vocabPath := filepath.Join(dir, "vocab.json")
+	mergesPath := filepath.Join(dir, "merges.txt")
+	if err := os.WriteFile(vocabPath, vocabData, 0644); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(mergesPath, []byte("#version: test\n"), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	tok, err := LoadTokenizer(vocabPath, mergesPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return tok
+}
+
+func TestEncodeReferenceIDs(t *testing.T) {
+	tok := byteTokenizer(t)
+	// IDs from CLIP's simple_tokenizer.py with the same 512-entry vocabulary.
+	tests := []struct {
+		text string
+		want []int64
+	}{
+		{"café", []int64{49406, 66, 64, 69, 127, 358, 49407}},
+		{"ΟΔΟΣ ΣΑΣ", []int64{49406, 138, 123, 138, 112, 138, 123, 139, 480, 139, 225, 138, 109, 139, 480, 49407}},
+		{"hello\u00a0world", []int64{49406, 71, 68, 75, 75, 334, 86, 78, 81, 75, 323, 49407}},
+		{"a\t\u3000 b", []int64{49406, 320, 321, 49407}},
+		{"東京タワー", []int64{49406, 162, 251, 109, 160, 118, 105, 159, 224, 123, 159, 225, 107, 159, 225, 376, 49407}},
+		{"собака", []int64{49406, 141, 223, 140, 122, 140, 109, 140, 108, 140, 118, 140, 364, 49407}},
+		{"नमस्ते", []int64{49406, 156, 97, 101, 156, 97, 106, 156, 97, 372, 156, 98, 491, 156, 97, 353, 156, 98, 485, 49407}},
+		{"🐶 dog", []int64{49406, 172, 253, 238, 370, 67, 78, 326, 49407}},
+		{"dog’s toy", []int64{49406, 67, 78, 326, 6, 338, 83, 78, 344, 49407}},
+		{"ｃａｔ", []int64{49406, 66, 64, 339, 49407}},
+		{"ﬁsh", []int64{49406, 69, 72, 82, 327, 49407}},
+		{"rock &amp; roll", []int64{49406, 81, 78, 66, 330, 261, 81, 78, 75, 331, 49407}},
+		{"İstanbul", []int64{49406, 328, 136, 485, 82, 83, 64, 77, 65, 84, 331, 49407}},
+		{"42 cats", []int64{49406, 275, 273, 66, 64, 83, 338, 49407}},
+	}
+	for _, tt := range tests {
+		got := tok.Encode(tt.text)
+		// Padding follows Hugging Face's CLIP tokenizer, which pads with the
+		// end token where simple_tokenizer.py pads with 0.
+		if !slices.Equal(got[:len(tt.want)], tt.want) || slices.ContainsFunc(got[len(tt.want):], func(id int64) bool { return id != 49407 }) {
+			t.Errorf("Encode(%q) = %v, want %v", tt.text, got[:len(tt.want)+1], tt.want)
+		}
+	}
+}
+
+func TestEncodeTruncationKeepsEndToken(t *testing.T) {
+	tok := byteTokenizer(t)
+	got := tok.Encode(strings.Repeat("猫", 40))
+	if len(got) != contextLen || got[0] != 49406 || got[contextLen-1] != 49407 {
+		t.Errorf("Encode of a long prompt = %v, want 77 IDs from start to end token", got)
+	}
+}
+
+func TestEncodeWithMask(t *testing.T) {
+	tok := byteTokenizer(t)
+	// What Hugging Face's CLIPTokenizer returns for "wow!!" with
+	// padding="max_length" over the same vocabulary. The first "!" is ID 0,
+	// which the mask must not mistake for padding.
+	wantIDs := []int64{49406, 86, 78, 342, 0, 256, 49407}
+	ids, mask := tok.EncodeWithMask("wow!!")
+	if len(ids) != contextLen || len(mask) != contextLen {
+		t.Fatalf("expected %d IDs and mask values, got %d and %d", contextLen, len(ids), len(mask))
+	}
+	for i := range contextLen {
+		wantID, wantMask := int64(49407), int64(0)
+		if i < len(wantIDs) {
+			wantID, wantMask = wantIDs[i], 1
+		}
+		if ids[i] != wantID || mask[i] != wantMask {
+			t.Fatalf("position %d: got ID %d mask %d, want ID %d mask %d\nids  %v\nmask %v",
+				i, ids[i], mask[i], wantID, wantMask, ids, mask)
+		}
+	}
+
+	// A prompt cut short has no padding.
+	if _, mask := tok.EncodeWithMask(strings.Repeat("猫", 40)); slices.Contains(mask, 0) {
+		t.Errorf("expected a full mask for a truncated prompt, got %v", mask)
+	}
+}
+
+func TestLoadTokenizerPadToken(t *testing.T) {
+	tok := byteTokenizer(t)
+	vocab, err := json.Marshal(tok.encoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func(config string) (*Tokenizer, error) {
+		dir := t.TempDir()
+		vocabPath := filepath.Join(dir, "vocab.json")
+		mergesPath := filepath.Join(dir, "merges.txt")
+		if err := os.WriteFile(vocabPath, vocab, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(mergesPath, []byte("#version: test\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if config != "" {
+			if err := os.WriteFile(filepath.Join(dir, tokenizerConfigFile), []byte(config), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return LoadTokenizer(vocabPath, mergesPath)
+	}
+
+	for _, tt := range []struct {
+		config string
+		want   int
+	}{
+		{"", 49407},
+		{`{"model_max_length": 77}`, 49407},
+		{`{"pad_token": "!"}`, 0},
+		{`{"pad_token": {"content": "<|endoftext|>", "lstrip": false}}`, 49407},
+	} {
+		tok, err := load(tt.config)
+		if err != nil {
+			t.Errorf("%s: %v", tt.config, err)
+			continue
+		}
+		if tok.padTokenID != tt.want {
+			t.Errorf("%s: pad token %d, want %d", tt.config, tok.padTokenID, tt.want)
+		}
+		if ids, _ := tok.EncodeWithMask("a"); ids[contextLen-1] != int64(tt.want) {
+			t.Errorf("%s: padded with %d, want %d", tt.config, ids[contextLen-1], tt.want)
+		}
+	}
+	if _, err := load(`{"pad_token": "<pad>"}`); err == nil {
+		t.Error("expected an error for a pad token missing from the vocabulary")
+	}
+}
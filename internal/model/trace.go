@@ -0,0 +1,56 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// trace writes what was sent to the model for in and what came back to w,
+// as one Write: the tensor shapes, then each prompt row with its label,
+// text, token IDs (without padding), and raw logit, then the final scores.
+// Errors writing the trace are ignored, since it is only a debugging aid.
+func (in *modelInput) trace(w io.Writer, logits []float32, chunkSize int, scores map[string]float32) {
+	var b bytes.Buffer
+	source := in.source
+	if source == "" {
+		source = "(in-memory image)"
+	}
+	rows := len(in.owners)
+	fmt.Fprintf(&b, "trace %s\n", source)
+	fmt.Fprintf(&b, "  pixel_values   [1 3 %d %d]\n", clipImageSize, clipImageSize)
+	fmt.Fprintf(&b, "  input_ids      [%d %d]\n", rows, contextLen)
+	fmt.Fprintf(&b, "  attention_mask [%d %d]\n", rows, contextLen)
+	if chunkSize > 0 && chunkSize < rows {
+		fmt.Fprintf(&b, "  run in chunks of %d rows\n", chunkSize)
+	}
+
+	for i, owner := range in.owners {
+		label := in.labels[owner]
+		if owner >= len(in.labels)-in.distractors {
+			label = BaselineCategory + " (distractor)"
+		}
+		fmt.Fprintf(&b, "  row %d %s: %q\n", i, label, in.prompts[i])
+		row := in.tokenIDs[i*contextLen : (i+1)*contextLen]
+		mask := in.attentionMask[i*contextLen : (i+1)*contextLen]
+		var ids []int64
+		for j, id := range row {
+			if mask[j] != 0 {
+				ids = append(ids, id)
+			}
+		}
+		fmt.Fprintf(&b, "    tokens %v\n", ids)
+		if i < len(logits) {
+			fmt.Fprintf(&b, "    logit  %.4f\n", logits[i])
+		}
+	}
+
+	fmt.Fprintf(&b, "  scores")
+	for _, label := range slices.Sorted(maps.Keys(scores)) {
+		fmt.Fprintf(&b, " %s=%.4f", label, scores[label])
+	}
+	b.WriteString("\n")
+	w.Write(b.Bytes())
+}
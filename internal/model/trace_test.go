@@ -0,0 +1,61 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTraceRemoteClassify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(RemoteResponse{LogitsPerImage: []float32{20, 25.5, 18}})
+	}))
+	defer srv.Close()
+
+	var trace bytes.Buffer
+	img := testImage(t)
+	r := newRemoteSession(srv.URL, testTokenizer(t), RemoteOptions{})
+	if _, err := r.ClassifyWithOptions(img, []string{"cats", "dogs"}, ClassifyOptions{
+		Prompts: map[string]string{"dogs": "a photo of a good dog"},
+		Trace:   &trace,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := trace.String()
+	for _, want := range []string{
+		"trace " + img + "\n",
+		"  pixel_values   [1 3 224 224]\n",
+		"  input_ids      [3 77]\n",
+		"  row 0 uncategorized: \"a photo\"\n    tokens [1 2]\n    logit  20.0000\n",
+		"  row 1 cats: \"a photo of cats\"\n",
+		"  row 2 dogs: \"a photo of a good dog\"\n",
+		"    logit  25.5000\n",
+		"  scores cats=",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trace is missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestTraceMarksDistractors(t *testing.T) {
+	in, err := prepareInput(testTokenizer(t), testImage(t), []string{"cat"}, ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logits := make([]float32, len(in.owners))
+	scores, err := in.scores(logits, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace bytes.Buffer
+	in.trace(&trace, logits, 4, scores)
+	if !strings.Contains(trace.String(), "uncategorized (distractor): \"a dark photo\"") ||
+		!strings.Contains(trace.String(), "run in chunks of 4 rows") {
+		t.Errorf("unexpected trace:\n%s", trace.String())
+	}
+}
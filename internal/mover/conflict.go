@@ -0,0 +1,143 @@
+package mover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConflictStrategy decides what happens when a destination file already exists.
+type ConflictStrategy string
+
+const (
+	// ConflictSuffix appends _1, _2, ... until a free name is found.
+	ConflictSuffix ConflictStrategy = "suffix"
+	// ConflictHash appends a short hash of the source file's contents.
+	ConflictHash ConflictStrategy = "hash"
+	// ConflictTimestamp appends the source file's modification time.
+	ConflictTimestamp ConflictStrategy = "timestamp"
+	// ConflictSkip leaves the source file where it is.
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the existing destination file, unless an
+	// earlier move in the same run put it there.
+	ConflictOverwrite ConflictStrategy = "overwrite"
+)
+
+// ConflictStrategies lists every supported strategy, in display order.
+var ConflictStrategies = []ConflictStrategy{
+	ConflictSuffix, ConflictHash, ConflictTimestamp, ConflictSkip, ConflictOverwrite,
+}
+
+// ParseConflictStrategy validates a strategy name.
+func ParseConflictStrategy(s string) (ConflictStrategy, error) {
+	for _, cs := range ConflictStrategies {
+		if string(cs) == s {
+			return cs, nil
+		}
+	}
+	names := make([]string, len(ConflictStrategies))
+	for i, cs := range ConflictStrategies {
+		names[i] = string(cs)
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q (expected one of: %s)", s, strings.Join(names, ", "))
+}
+
+// timestampLayout is the mtime format used by ConflictTimestamp.
+const timestampLayout = "20060102-150405"
+
+// conflictAction describes how a destination was resolved.
+type conflictAction int
+
+const (
+	actionMove conflictAction = iota
+	actionSkip
+	actionOverwrite
+)
+
+// resolveConflict picks the destination for srcPath according to strategy.
+// names knows which paths are on disk or taken by an earlier move planned
+// in the same run; taken, if not nil, rejects further names, for files
+// that need more than one name free at once.
+func resolveConflict(srcPath, destPath string, strategy ConflictStrategy, names *destNames, taken func(string) bool) (string, conflictAction, error) {
+	// A file already at its destination stays put under every strategy;
+	// overwriting it would set aside the very file being moved.
+	if names.free(destPath, taken) || sameFile(srcPath, destPath) {
+		return destPath, actionMove, nil
+	}
+
+	ext := filepath.Ext(destPath)
+	base := strings.TrimSuffix(destPath, ext)
+
+	switch strategy {
+	case ConflictSkip:
+		return destPath, actionSkip, nil
+	case ConflictOverwrite:
+		// Only files that were there before the run are replaced. A name
+		// an earlier move in this run took is numbered instead, so two
+		// same-named sources both survive.
+		if !names.movedHere(destPath) {
+			return destPath, actionOverwrite, nil
+		}
+		return names.suffixed(base, ext, taken), actionMove, nil
+	case ConflictHash:
+		sum, err := shortHash(srcPath)
+		if err != nil {
+			return "", actionMove, fmt.Errorf("cannot hash %s: %w", srcPath, err)
+		}
+		base = base + "_" + sum
+	case ConflictTimestamp:
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return "", actionMove, fmt.Errorf("cannot stat %s: %w", srcPath, err)
+		}
+		base = base + "_" + info.ModTime().Format(timestampLayout)
+	}
+
+	if strategy != ConflictSuffix {
+		if candidate := base + ext; names.free(candidate, taken) {
+			return candidate, actionMove, nil
+		}
+	}
+
+	// Fall back to numeric suffixes for the suffix strategy, or when the
+	// hash/timestamp name is itself already taken.
+	return names.suffixed(base, ext, taken), actionMove, nil
+}
+
+// shortHash returns the first 8 hex digits of the SHA-256 of a file's contents.
+func shortHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:8], nil
+}
+
+// sameFile reports whether a and b name the same file, including on
+// case-insensitive filesystems where the paths differ only in case. Hard
+// links under different names are separate files here.
+func sameFile(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if a == b {
+		return true
+	}
+	if !strings.EqualFold(a, b) {
+		return false
+	}
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
@@ -0,0 +1,275 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// setupConflict creates dir/photo.jpg (the source) and dir/nature/photo.jpg
+// (an existing destination) and returns the source path.
+func setupConflict(t *testing.T, dir string) string {
+	t.Helper()
+	src := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	catDir := filepath.Join(dir, "nature")
+	if err := os.MkdirAll(catDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(catDir, "photo.jpg"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	for _, cs := range ConflictStrategies {
+		got, err := ParseConflictStrategy(string(cs))
+		if err != nil {
+			t.Errorf("ParseConflictStrategy(%q) failed: %v", cs, err)
+		}
+		if got != cs {
+			t.Errorf("expected %q, got %q", cs, got)
+		}
+	}
+
+	if _, err := ParseConflictStrategy("rename"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestConflictHash(t *testing.T) {
+	dir := t.TempDir()
+	src := setupConflict(t, dir)
+
+	sum, err := shortHash(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{{Path: src, Category: "nature", Confidence: 0.5}}
+	moves, err := MoveFiles(dir, results, Options{OnConflict: ConflictHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join(dir, "nature", "photo_"+sum+".jpg")
+	if moves[0].DestPath != expected {
+		t.Errorf("expected dest %s, got %s", expected, moves[0].DestPath)
+	}
+	if _, err := os.Stat(expected); err != nil {
+		t.Error("hashed file should exist")
+	}
+}
+
+func TestConflictTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	src := setupConflict(t, dir)
+
+	mtime := time.Date(2024, 3, 9, 14, 5, 30, 0, time.Local)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{{Path: src, Category: "nature", Confidence: 0.5}}
+	moves, err := MoveFiles(dir, results, Options{OnConflict: ConflictTimestamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := filepath.Join(dir, "nature", "photo_20240309-140530.jpg")
+	if moves[0].DestPath != expected {
+		t.Errorf("expected dest %s, got %s", expected, moves[0].DestPath)
+	}
+}
+
+func TestConflictSkip(t *testing.T) {
+	dir := t.TempDir()
+	src := setupConflict(t, dir)
+
+	results := []categorizer.Result{{Path: src, Category: "nature", Confidence: 0.5}}
+	moves, err := MoveFiles(dir, results, Options{OnConflict: ConflictSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !moves[0].Skipped || moves[0].Reason == "" {
+		t.Errorf("expected skipped move with a reason, got %+v", moves[0])
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Error("source should be left in place")
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "nature", "photo.jpg"))
+	if string(data) != "existing" {
+		t.Error("existing destination should be untouched")
+	}
+}
+
+func TestConflictOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	src := setupConflict(t, dir)
+
+	results := []categorizer.Result{{Path: src, Category: "nature", Confidence: 0.5}}
+	moves, err := MoveFiles(dir, results, Options{OnConflict: ConflictOverwrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "nature", "photo.jpg")
+	if moves[0].DestPath != dest || !moves[0].Overwrote {
+		t.Errorf("expected overwrite of %s, got %+v", dest, moves[0])
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "new" {
+		t.Errorf("destination should contain the source contents, got %q", data)
+	}
+}
+
+func TestConflictDryRunPredictsNames(t *testing.T) {
+	dir := t.TempDir()
+	setupConflict(t, dir)
+
+	// A second photo.jpg in another folder collides with the first one too.
+	otherDir := filepath.Join(dir, "import")
+	if err := os.MkdirAll(otherDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(otherDir, "photo.jpg"), []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{
+		{Path: filepath.Join(dir, "photo.jpg"), Category: "nature", Confidence: 0.5},
+		{Path: filepath.Join(otherDir, "photo.jpg"), Category: "nature", Confidence: 0.5},
+	}
+	moves, err := MoveFiles(dir, results, Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dests []string
+	for _, m := range moves {
+		dests = append(dests, filepath.Base(m.DestPath))
+	}
+	if strings.Join(dests, ",") != "photo_1.jpg,photo_2.jpg" {
+		t.Errorf("expected dry run to predict photo_1.jpg,photo_2.jpg, got %v", dests)
+	}
+}
+
+// TestConflictDryRun checks that each strategy reports the same outcome in a
+// dry run as in a real run, without touching either file.
+func TestConflictDryRun(t *testing.T) {
+	for _, tc := range []struct {
+		strategy  ConflictStrategy
+		dest      string
+		skipped   bool
+		overwrote bool
+	}{
+		{ConflictSuffix, "photo_1.jpg", false, false},
+		{ConflictSkip, "photo.jpg", true, false},
+		{ConflictOverwrite, "photo.jpg", false, true},
+	} {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			dir := t.TempDir()
+			src := setupConflict(t, dir)
+
+			results := []categorizer.Result{{Path: src, Category: "nature", Confidence: 0.5}}
+			moves, err := MoveFiles(dir, results, Options{DryRun: true, OnConflict: tc.strategy})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m := moves[0]
+			if m.Skipped != tc.skipped || m.Overwrote != tc.overwrote || filepath.Base(m.DestPath) != tc.dest {
+				t.Errorf("unexpected result %+v", m)
+			}
+			if tc.skipped && m.Reason == "" {
+				t.Error("a skipped move needs a reason")
+			}
+			if data, _ := os.ReadFile(src); string(data) != "new" {
+				t.Error("source should be left in place")
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "nature", "photo.jpg")); string(data) != "existing" {
+				t.Error("existing destination should be untouched")
+			}
+		})
+	}
+}
+
+func TestConflictOverwriteKeepsSameRunMoves(t *testing.T) {
+	dir := t.TempDir()
+	setupConflict(t, dir)
+
+	// import/photo.jpg is moved after photo.jpg has replaced the existing
+	// nature/photo.jpg, and must not replace it in turn.
+	otherDir := filepath.Join(dir, "import")
+	if err := os.MkdirAll(otherDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(otherDir, "photo.jpg"), []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{
+		{Path: filepath.Join(dir, "photo.jpg"), Category: "nature", Confidence: 0.5},
+		{Path: filepath.Join(otherDir, "photo.jpg"), Category: "nature", Confidence: 0.5},
+	}
+	for _, dryRun := range []bool{true, false} {
+		moves, err := MoveFiles(dir, results, Options{OnConflict: ConflictOverwrite, DryRun: dryRun})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(moves[0].DestPath) != "photo.jpg" || !moves[0].Overwrote {
+			t.Errorf("dry run %v: expected the first move to overwrite photo.jpg, got %+v", dryRun, moves[0])
+		}
+		if filepath.Base(moves[1].DestPath) != "photo_1.jpg" || moves[1].Overwrote {
+			t.Errorf("dry run %v: expected the second move to photo_1.jpg, got %+v", dryRun, moves[1])
+		}
+	}
+
+	for name, want := range map[string]string{"photo.jpg": "new", "photo_1.jpg": "other"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, "nature", name)); string(data) != want {
+			t.Errorf("nature/%s: expected %q, got %q", name, want, data)
+		}
+	}
+}
+
+func TestConflictOverwriteSamePath(t *testing.T) {
+	// A file already in its category folder, as a recursive run over an
+	// unmanaged folder can find, is its own destination.
+	for _, transactional := range []bool{false, true} {
+		dir := t.TempDir()
+		src := setupConflict(t, dir)
+		existing := filepath.Join(dir, "nature", "photo.jpg")
+
+		results := []categorizer.Result{
+			{Path: existing, Category: "nature", Confidence: 0.5},
+			{Path: src, Category: "nature", Confidence: 0.5},
+		}
+		moves, err := MoveFiles(dir, results, Options{OnConflict: ConflictOverwrite, Transactional: transactional})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moves[0].DestPath != existing || moves[0].Overwrote || moves[0].Skipped {
+			t.Errorf("transactional %v: expected the file to stay in place, got %+v", transactional, moves[0])
+		}
+		if filepath.Base(moves[1].DestPath) != "photo_1.jpg" {
+			t.Errorf("transactional %v: expected the second file to be numbered, got %+v", transactional, moves[1])
+		}
+		tree := listTree(t, dir)
+		if tree[filepath.Join("nature", "photo.jpg")] != "existing" || tree[filepath.Join("nature", "photo_1.jpg")] != "new" {
+			t.Errorf("transactional %v: unexpected files after the run: %v", transactional, tree)
+		}
+		for path := range tree {
+			if strings.Contains(path, backupSuffix) {
+				t.Errorf("transactional %v: backup %s left behind", transactional, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package mover
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FolderPolicy decides what happens when a category's folder name is
+// already taken by a file, such as a plain file called "food".
+type FolderPolicy string
+
+const (
+	// FolderFail stops the run with an error (the default).
+	FolderFail FolderPolicy = "fail"
+	// FolderSuffix sorts the category into a folder named with
+	// FolderSuffixText appended instead, such as food_sorted.
+	FolderSuffix FolderPolicy = "suffix"
+	// FolderSkipCategory leaves the category's images in place, skipped
+	// with ReasonFolderIsFile.
+	FolderSkipCategory FolderPolicy = "skip-category"
+)
+
+// FolderSuffixText is appended to a category's folder name by FolderSuffix.
+const FolderSuffixText = "_sorted"
+
+// ReasonFolderIsFile is the MoveResult.Reason for images left in place by
+// FolderSkipCategory.
+const ReasonFolderIsFile = "category folder name is taken by a file"
+
+// ParseFolderPolicy validates a folder conflict policy name. The empty
+// string means FolderFail.
+func ParseFolderPolicy(s string) (FolderPolicy, error) {
+	switch FolderPolicy(s) {
+	case "", FolderFail:
+		return FolderFail, nil
+	case FolderSuffix, FolderSkipCategory:
+		return FolderPolicy(s), nil
+	}
+	return "", fmt.Errorf("unknown folder conflict policy %q (expected fail, suffix, or skip-category)", s)
+}
+
+// FolderConflicts returns the categories whose folder in baseDir is taken
+// by something other than a directory, in the order given, so a run can
+// report them before classifying anything.
+func FolderConflicts(baseDir string, categories []string) []string {
+	var taken []string
+	for _, c := range categories {
+		if notDir(filepath.Join(baseDir, c)) {
+			taken = append(taken, c)
+		}
+	}
+	return taken
+}
+
+// notDir reports whether path exists but is not a directory, or a link to
+// one, so no folder can be created there.
+func notDir(path string) bool {
+	if _, err := os.Lstat(path); err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err != nil || !info.IsDir()
+}
+
+// categoryFolder returns the folder in baseDir that category is sorted
+// into under policy. skip is set when the category should be left alone.
+func categoryFolder(baseDir, category string, policy FolderPolicy) (folder string, skip bool, err error) {
+	if !notDir(filepath.Join(baseDir, category)) {
+		return category, false, nil
+	}
+	switch policy {
+	case FolderSuffix:
+		folder = category + FolderSuffixText
+		for n := 2; notDir(filepath.Join(baseDir, folder)); n++ {
+			folder = fmt.Sprintf("%s%s_%d", category, FolderSuffixText, n)
+		}
+		return folder, false, nil
+	case FolderSkipCategory:
+		return "", true, nil
+	}
+	return "", false, fmt.Errorf("cannot sort into category %q: %s exists and is not a folder",
+		category, filepath.Join(baseDir, category))
+}
@@ -0,0 +1,107 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// folderConflictDir returns a directory holding a plain file named "food",
+// a food image, and a beach image.
+func folderConflictDir(t *testing.T) (string, []categorizer.Result) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, f := range []string{"food", "lunch.jpg", "sea.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, []categorizer.Result{
+		{Path: filepath.Join(dir, "lunch.jpg"), Category: "food", Confidence: 0.7},
+		{Path: filepath.Join(dir, "sea.jpg"), Category: "beach", Confidence: 0.7},
+	}
+}
+
+func TestFolderConflicts(t *testing.T) {
+	dir, _ := folderConflictDir(t)
+	if err := os.Mkdir(filepath.Join(dir, "beach"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	got := FolderConflicts(dir, []string{"beach", "food", "city"})
+	if !reflect.DeepEqual(got, []string{"food"}) {
+		t.Errorf("expected only food to conflict, got %v", got)
+	}
+}
+
+func TestMoveFilesFolderConflictFail(t *testing.T) {
+	dir, results := folderConflictDir(t)
+	for _, dryRun := range []bool{true, false} {
+		_, err := MoveFiles(dir, results, Options{DryRun: dryRun})
+		if err == nil || !strings.Contains(err.Error(), "not a folder") {
+			t.Errorf("dryRun=%v: expected a folder conflict error, got %v", dryRun, err)
+		}
+	}
+}
+
+func TestMoveFilesFolderConflictSuffix(t *testing.T) {
+	dir, results := folderConflictDir(t)
+	// The first suffixed name is taken too.
+	if err := os.WriteFile(filepath.Join(dir, "food"+FolderSuffixText), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	moves, err := MoveFiles(dir, results, Options{OnFolderConflict: FolderSuffix})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "food"+FolderSuffixText+"_2", "lunch.jpg")
+	for _, m := range moves {
+		if m.Category == "food" && m.DestPath != want {
+			t.Errorf("expected lunch.jpg in %s, got %+v", want, m)
+		}
+	}
+	if _, err := os.Stat(want); err != nil {
+		t.Error(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "food")); err != nil || string(data) != "fake" {
+		t.Errorf("the file named food should be untouched: %v", err)
+	}
+}
+
+func TestMoveFilesFolderConflictSkipCategory(t *testing.T) {
+	dir, results := folderConflictDir(t)
+	moves, err := MoveFiles(dir, results, Options{OnFolderConflict: FolderSkipCategory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range moves {
+		switch m.Category {
+		case "food":
+			if !m.Skipped || m.Reason != ReasonFolderIsFile {
+				t.Errorf("expected the food image to be skipped, got %+v", m)
+			}
+		case "beach":
+			if m.Skipped || m.DestPath != filepath.Join(dir, "beach", "sea.jpg") {
+				t.Errorf("expected other categories to be sorted, got %+v", m)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lunch.jpg")); err != nil {
+		t.Errorf("expected lunch.jpg left in place: %v", err)
+	}
+}
+
+func TestParseFolderPolicy(t *testing.T) {
+	for in, want := range map[string]FolderPolicy{"": FolderFail, "fail": FolderFail, "suffix": FolderSuffix, "skip-category": FolderSkipCategory} {
+		if got, err := ParseFolderPolicy(in); err != nil || got != want {
+			t.Errorf("ParseFolderPolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFolderPolicy("rename"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,83 @@
+package mover
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// IndexFile is the per-folder listing written by WriteIndexes.
+const IndexFile = "_index.txt"
+
+// indexEntry is one file listed in a category's index.
+type indexEntry struct {
+	name       string
+	confidence float32
+}
+
+// WriteIndexes writes IndexFile into each category folder that received
+// files, listing them relative to the folder with their confidence, best
+// first, one tab-separated line per file. An existing index is replaced,
+// so it describes the latest run. It returns the paths written.
+func WriteIndexes(baseDir string, results []categorizer.Result, moves []MoveResult) ([]string, error) {
+	confidence := make(map[string]float32, len(results))
+	for _, r := range results {
+		confidence[r.Path] = r.Confidence
+	}
+
+	var order []string
+	byCategory := make(map[string][]indexEntry)
+	for _, m := range moves {
+		if m.Skipped {
+			continue
+		}
+		catDir := filepath.Join(baseDir, m.Category)
+		name, err := filepath.Rel(catDir, m.DestPath)
+		if err != nil {
+			name = filepath.Base(m.DestPath)
+		}
+		if _, ok := byCategory[m.Category]; !ok {
+			order = append(order, m.Category)
+		}
+		byCategory[m.Category] = append(byCategory[m.Category],
+			indexEntry{filepath.ToSlash(name), confidence[m.SourcePath]})
+	}
+
+	var written []string
+	for _, category := range order {
+		entries := byCategory[category]
+		slices.SortStableFunc(entries, func(a, b indexEntry) int {
+			return cmp.Or(cmp.Compare(b.confidence, a.confidence), cmp.Compare(a.name, b.name))
+		})
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "# %s: %d files, best match first\n", category, len(entries))
+		for _, e := range entries {
+			fmt.Fprintf(&buf, "%s\t%.4f\n", e.name, e.confidence)
+		}
+
+		path := filepath.Join(baseDir, category, IndexFile)
+		if err := writeIndex(path, buf.Bytes()); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// writeIndex replaces the file at path with data.
+func writeIndex(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+func TestWriteIndexes(t *testing.T) {
+	dir := t.TempDir()
+	var results []categorizer.Result
+	for _, r := range []struct {
+		name, category string
+		confidence     float32
+	}{
+		{"b.jpg", "beach", 0.4},
+		{"a.jpg", "beach", 0.9},
+		{"d.jpg", "beach", 0.4},
+		{"c.jpg", "beach", 0.65},
+		{"city.jpg", "city", 0.5},
+		{"blurry.jpg", "", 0},
+	} {
+		path := filepath.Join(dir, r.name)
+		if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, categorizer.Result{
+			Path: path, Category: r.category, Confidence: r.confidence, Skipped: r.category == "",
+		})
+	}
+
+	moves, err := MoveFiles(dir, results, Options{MaxPerCategory: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := WriteIndexes(dir, results, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 2 {
+		t.Fatalf("expected an index per category, got %v", written)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "beach", IndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# beach: 4 files, best match first\n" +
+		"part-1/a.jpg\t0.9000\n" +
+		"part-1/c.jpg\t0.6500\n" +
+		"part-1/b.jpg\t0.4000\n" +
+		"part-2/d.jpg\t0.4000\n"
+	if string(data) != want {
+		t.Errorf("unexpected beach index:\n%s\nwant:\n%s", data, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "city", IndexFile)); string(data) != "# city: 1 files, best match first\ncity.jpg\t0.5000\n" {
+		t.Errorf("unexpected city index: %q", data)
+	}
+}
@@ -0,0 +1,189 @@
+package mover
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// rename is os.Rename, replaced in tests to inject failures.
+var rename = os.Rename
+
+// backupSuffix is appended to files set aside by a transactional overwrite
+// until the run completes.
+const backupSuffix = ".imgsort-bak"
+
+// journal records the filesystem changes made by a transactional run so they
+// can be undone if the run fails partway. A nil journal records nothing.
+type journal struct {
+	moves   []move   // files moved, in order
+	backups []move   // overwritten files set aside: From is the original path
+	created []string // symlinks and sidecars written
+	dirs    []string // directories created, parents first
+}
+
+type move struct {
+	From, To string
+}
+
+// mkdirAll creates dir and records every directory that did not exist yet.
+func (j *journal) mkdirAll(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if j != nil {
+		for i := len(missing) - 1; i >= 0; i-- {
+			j.dirs = append(j.dirs, missing[i])
+		}
+	}
+	return nil
+}
+
+// move renames from to to and records it.
+func (j *journal) move(from, to string) error {
+	if err := rename(from, to); err != nil {
+		return err
+	}
+	if j != nil {
+		j.moves = append(j.moves, move{From: from, To: to})
+	}
+	return nil
+}
+
+// copy copies from to to, keeping its permissions and modification time,
+// and records the copy as a file the run created. The copy is written
+// under a temporary name first, so a failure never leaves half a file at
+// to. Copying a file onto itself does nothing.
+func (j *journal) copy(from, to string) error {
+	if sameFile(from, to) {
+		return nil
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(to), ".imgsort-copy-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = rename(tmp.Name(), to)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	j.create(to)
+	return nil
+}
+
+// setAside moves an existing file about to be overwritten out of the way so
+// a rollback can restore it, under the first of path.imgsort-bak,
+// path.imgsort-bak.1, and so on that is free. Without a journal it does
+// nothing.
+func (j *journal) setAside(path string) error {
+	if j == nil {
+		return nil
+	}
+	backup := path + backupSuffix
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s%s.%d", path, backupSuffix, n)
+	}
+	if err := rename(path, backup); err != nil {
+		return fmt.Errorf("cannot set aside %s: %w", path, err)
+	}
+	j.backups = append(j.backups, move{From: path, To: backup})
+	return nil
+}
+
+// restoreAside puts back a file set aside by setAside whose replacement
+// never arrived. Without a journal it does nothing.
+func (j *journal) restoreAside(path string) error {
+	if j == nil {
+		return nil
+	}
+	for i := len(j.backups) - 1; i >= 0; i-- {
+		if b := j.backups[i]; b.From == path {
+			if err := rename(b.To, b.From); err != nil {
+				return fmt.Errorf("cannot restore %s: %w", path, err)
+			}
+			j.backups = slices.Delete(j.backups, i, i+1)
+			return nil
+		}
+	}
+	return nil
+}
+
+// create records a file written by the run.
+func (j *journal) create(path string) {
+	if j != nil {
+		j.created = append(j.created, path)
+	}
+}
+
+// rollback undoes every recorded change in reverse order. It keeps going
+// after individual failures and returns them all.
+func (j *journal) rollback() error {
+	var errs []error
+	for i := len(j.created) - 1; i >= 0; i-- {
+		if err := os.Remove(j.created[i]); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	for i := len(j.moves) - 1; i >= 0; i-- {
+		m := j.moves[i]
+		if err := rename(m.To, m.From); err != nil {
+			errs = append(errs, fmt.Errorf("cannot restore %s: %w", m.From, err))
+		}
+	}
+	for i := len(j.backups) - 1; i >= 0; i-- {
+		b := j.backups[i]
+		if err := rename(b.To, b.From); err != nil {
+			errs = append(errs, fmt.Errorf("cannot restore %s: %w", b.From, err))
+		}
+	}
+	for i := len(j.dirs) - 1; i >= 0; i-- {
+		// Only empty directories are removed; anything left behind was
+		// already reported above.
+		os.Remove(j.dirs[i])
+	}
+	return errors.Join(errs...)
+}
+
+// commit discards the backups of overwritten files once the run succeeded.
+func (j *journal) commit() {
+	for _, b := range j.backups {
+		os.Remove(b.To)
+	}
+}
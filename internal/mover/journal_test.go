@@ -0,0 +1,184 @@
+package mover
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// failRenameAt makes the nth call to rename (1-based) fail.
+func failRenameAt(t *testing.T, n int) {
+	t.Helper()
+	calls := 0
+	orig := rename
+	rename = func(from, to string) error {
+		calls++
+		if calls == n {
+			return errors.New("disk full")
+		}
+		return orig(from, to)
+	}
+	t.Cleanup(func() { rename = orig })
+}
+
+// listTree returns every path under dir with its contents, for comparing
+// directory state before and after a run.
+func listTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if info.IsDir() {
+			tree[rel] = "<dir>"
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		tree[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestMoveFilesTransactionalRollback(t *testing.T) {
+	dir := t.TempDir()
+	var results []categorizer.Result
+	for i, name := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cat := []string{"beach", "city"}[i%2]
+		results = append(results, categorizer.Result{
+			Path: path, Category: cat, Confidence: 0.6, RunnerUp: "ocean", RunnerUpConfidence: 0.3,
+		})
+	}
+	// An existing file that will be overwritten must come back too.
+	if err := os.MkdirAll(filepath.Join(dir, "beach"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "beach", "a.jpg"), []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := listTree(t, dir)
+
+	failRenameAt(t, 5)
+	_, err := MoveFiles(dir, results, Options{
+		Transactional: true,
+		OnConflict:    ConflictOverwrite,
+		Secondary:     SecondarySidecar,
+	})
+	if err == nil {
+		t.Fatal("expected injected failure")
+	}
+
+	after := listTree(t, dir)
+	if len(before) != len(after) {
+		t.Errorf("expected %d entries after rollback, got %d: %v", len(before), len(after), after)
+	}
+	for path, contents := range before {
+		if after[path] != contents {
+			t.Errorf("%s: expected %q after rollback, got %q", path, contents, after[path])
+		}
+	}
+}
+
+func TestMoveFilesTransactionalSuccess(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "beach"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "beach", "a.jpg"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{{Path: src, Category: "beach", Confidence: 0.6}}
+	if _, err := MoveFiles(dir, results, Options{Transactional: true, OnConflict: ConflictOverwrite}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "beach"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !slices.Equal(names, []string{"a.jpg"}) {
+		t.Errorf("backup should be removed after success, got %v", names)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "beach", "a.jpg")); string(data) != "new" {
+		t.Errorf("expected overwritten contents, got %q", data)
+	}
+}
+
+func TestSetAsideKeepsExistingBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.jpg")
+	for name, contents := range map[string]string{
+		"a.jpg":                       "current",
+		"a.jpg" + backupSuffix:        "user backup",
+		"a.jpg" + backupSuffix + ".1": "another",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	j := &journal{}
+	if err := j.setAside(path); err != nil {
+		t.Fatal(err)
+	}
+	tree := listTree(t, dir)
+	if tree["a.jpg"+backupSuffix] != "user backup" || tree["a.jpg"+backupSuffix+".1"] != "another" {
+		t.Errorf("existing backups should be untouched, got %v", tree)
+	}
+	if tree["a.jpg"+backupSuffix+".2"] != "current" {
+		t.Errorf("expected the file set aside as a.jpg%s.2, got %v", backupSuffix, tree)
+	}
+
+	if err := j.restoreAside(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "current" {
+		t.Errorf("expected the file restored, got %q", data)
+	}
+}
+
+func TestMoveFilesCopyRollback(t *testing.T) {
+	dir, out := t.TempDir(), t.TempDir()
+	var results []categorizer.Result
+	for i, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, categorizer.Result{Path: path, Category: []string{"beach", "city"}[i%2], Confidence: 0.6})
+	}
+	before := listTree(t, dir)
+
+	failRenameAt(t, 3)
+	if _, err := MoveFiles(dir, results, Options{Transactional: true, Copy: true, OutputDir: out}); err == nil {
+		t.Fatal("expected injected failure")
+	}
+	if after := listTree(t, dir); len(after) != len(before) {
+		t.Errorf("the originals should be untouched, got %v", after)
+	}
+	if after := listTree(t, out); len(after) != 1 {
+		t.Errorf("expected the copies and their folders removed, got %v", after)
+	}
+}
@@ -0,0 +1,167 @@
+package mover
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/bagtoad/imgsort/internal/junk"
+)
+
+// MergeOptions controls MergeCategories.
+type MergeOptions struct {
+	// DryRun computes destinations without touching the filesystem.
+	DryRun bool
+
+	// OnConflict decides what happens when a file with the same name
+	// already exists in the target. The zero value behaves like ConflictSuffix.
+	OnConflict ConflictStrategy
+
+	// NoJunkFilter moves junk files such as .DS_Store like any other file.
+	// By default they are left behind and deleted with the emptied folder.
+	NoJunkFilter bool
+}
+
+// MergeCategories moves the contents of each source category folder in
+// baseDir into the target folder, keeping any subfolders (such as part-1/),
+// and removes source folders left empty. Each MoveResult's Category is the
+// source it came from. Renaming a category is a merge with one source.
+func MergeCategories(baseDir string, sources []string, target string, opts MergeOptions) ([]MoveResult, error) {
+	strategy := opts.OnConflict
+	if strategy == "" {
+		strategy = ConflictSuffix
+	}
+	if target == "" {
+		return nil, fmt.Errorf("no target category given")
+	}
+	for _, src := range sources {
+		if src == target {
+			return nil, fmt.Errorf("cannot merge %q into itself", src)
+		}
+		info, err := os.Stat(filepath.Join(baseDir, src))
+		if err != nil {
+			return nil, fmt.Errorf("cannot find category folder %q: %w", src, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a category folder", src)
+		}
+	}
+
+	names := newDestNames(os.Lstat)
+	targetDir := filepath.Join(baseDir, target)
+
+	var moves []MoveResult
+	for _, src := range sources {
+		srcDir := filepath.Join(baseDir, src)
+		files, err := listFiles(srcDir, !opts.NoJunkFilter)
+		if err != nil {
+			return moves, err
+		}
+
+		for _, rel := range files {
+			from := filepath.Join(srcDir, rel)
+			dest, action, err := resolveConflict(from, filepath.Join(targetDir, rel), strategy, names, nil)
+			if err != nil {
+				return moves, err
+			}
+
+			mr := MoveResult{SourcePath: from, DestPath: dest, Category: src}
+			switch action {
+			case actionSkip:
+				mr.Skipped = true
+				mr.Reason = "destination exists"
+				moves = append(moves, mr)
+				continue
+			case actionOverwrite:
+				mr.Overwrote = true
+			}
+
+			if !opts.DryRun {
+				if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+					return moves, fmt.Errorf("cannot create category folder %q: %w", filepath.Dir(dest), err)
+				}
+				if err := rename(from, dest); err != nil {
+					return moves, fmt.Errorf("cannot move %s to %s: %w", from, dest, err)
+				}
+			}
+			names.claim(dest)
+			moves = append(moves, mr)
+		}
+
+		if !opts.DryRun {
+			removeEmptyDirs(srcDir, !opts.NoJunkFilter)
+		}
+	}
+	if !opts.DryRun {
+		recordManaged(baseDir, moves)
+	}
+	return moves, nil
+}
+
+// listFiles returns every non-directory entry under dir, relative to dir,
+// in lexical order, leaving out junk files if skipJunk is set.
+func listFiles(dir string, skipJunk bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !(skipJunk && junk.Is(d.Name())) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// removeEmptyDirs removes dir and its subdirectories, deepest first, where
+// they are empty, or with ignoreJunk, hold nothing but junk files. Directories
+// still holding other files are left alone.
+func removeEmptyDirs(dir string, ignoreJunk bool) {
+	var dirs []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	for _, d := range slices.Backward(dirs) {
+		if ignoreJunk {
+			removeJunk(d)
+		}
+		os.Remove(d) // fails harmlessly when not empty
+	}
+}
+
+// removeJunk deletes the junk files in dir if nothing else is in it apart
+// from subdirectories.
+func removeJunk(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var files []string
+	for _, e := range entries {
+		switch {
+		case e.IsDir():
+		case junk.Is(e.Name()) && e.Type().IsRegular():
+			files = append(files, filepath.Join(dir, e.Name()))
+		default:
+			return
+		}
+	}
+	for _, f := range files {
+		os.Remove(f)
+	}
+}
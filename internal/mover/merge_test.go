@@ -0,0 +1,139 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates each relative path under dir with its name as contents.
+func writeFiles(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		full := filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMergeCategories(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "sea/a.jpg", "sea/part-1/b.jpg", "ocean/a.jpg", "waves/c.jpg")
+
+	moves, err := MergeCategories(dir, []string{"sea", "waves"}, "ocean", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 3 {
+		t.Fatalf("expected 3 moves, got %d", len(moves))
+	}
+
+	for path, contents := range map[string]string{
+		"ocean/a.jpg":        "ocean/a.jpg",
+		"ocean/a_1.jpg":      "sea/a.jpg",
+		"ocean/part-1/b.jpg": "sea/part-1/b.jpg",
+		"ocean/c.jpg":        "waves/c.jpg",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil || string(data) != contents {
+			t.Errorf("%s: expected %q, got %q (%v)", path, contents, data, err)
+		}
+	}
+	for _, gone := range []string{"sea", "waves"} {
+		if _, err := os.Stat(filepath.Join(dir, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s/ should be removed after merging", gone)
+		}
+	}
+}
+
+func TestMergeCategoriesLeavesJunk(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "sea/a.jpg", "sea/.DS_Store", "sea/part-1/Thumbs.db", "waves/b.jpg", "waves/.DS_Store")
+
+	moves, err := MergeCategories(dir, []string{"sea"}, "ocean", MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 {
+		t.Errorf("junk files should not be moved, got %d moves", len(moves))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sea")); !os.IsNotExist(err) {
+		t.Error("sea/ should be removed along with its junk files")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ocean", ".DS_Store")); !os.IsNotExist(err) {
+		t.Error("junk should not follow the images")
+	}
+
+	moves, err = MergeCategories(dir, []string{"waves"}, "ocean", MergeOptions{NoJunkFilter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 {
+		t.Errorf("with NoJunkFilter, junk should be moved too, got %d moves", len(moves))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ocean", ".DS_Store")); err != nil {
+		t.Error("with NoJunkFilter, .DS_Store should be moved")
+	}
+}
+
+func TestMergeCategoriesRenamesIntoNewFolder(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "docs/scan.png")
+
+	if _, err := MergeCategories(dir, []string{"docs"}, "documents", MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "documents", "scan.png")); err != nil {
+		t.Errorf("expected file in documents/: %v", err)
+	}
+}
+
+func TestMergeCategoriesSkipKeepsSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "sea/a.jpg", "ocean/a.jpg")
+
+	moves, err := MergeCategories(dir, []string{"sea"}, "ocean", MergeOptions{OnConflict: ConflictSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !moves[0].Skipped {
+		t.Errorf("expected collision to be skipped, got %+v", moves[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sea", "a.jpg")); err != nil {
+		t.Errorf("skipped file and its folder should remain: %v", err)
+	}
+}
+
+func TestMergeCategoriesDryRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "sea/a.jpg", "waves/a.jpg", "ocean/a.jpg")
+
+	moves, err := MergeCategories(dir, []string{"sea", "waves"}, "ocean", MergeOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both collide with ocean/a.jpg, and the second must not reuse the
+	// name predicted for the first.
+	if moves[0].DestPath != filepath.Join(dir, "ocean", "a_1.jpg") || moves[1].DestPath != filepath.Join(dir, "ocean", "a_2.jpg") {
+		t.Errorf("unexpected predicted destinations: %s, %s", moves[0].DestPath, moves[1].DestPath)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sea", "a.jpg")); err != nil {
+		t.Error("dry run should not move files")
+	}
+}
+
+func TestMergeCategoriesErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "sea/a.jpg")
+
+	if _, err := MergeCategories(dir, []string{"sea"}, "sea", MergeOptions{}); err == nil {
+		t.Error("expected error merging a category into itself")
+	}
+	if _, err := MergeCategories(dir, []string{"lake"}, "sea", MergeOptions{}); err == nil {
+		t.Error("expected error for a missing source folder")
+	}
+}
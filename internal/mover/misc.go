@@ -0,0 +1,46 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// MiscDir is the folder Options.MinCategorySize gathers small categories in.
+const MiscDir = "misc"
+
+// foldSmall returns groups with every category that captured fewer than
+// minSize images moved into MiscDir, merged with any real category of that
+// name. Categories whose folder already exists in baseDir are kept, since
+// earlier runs filled them. Images keep their own category in their
+// Result; only where they are moved changes.
+func foldSmall(baseDir string, groups []categorizer.Group, minSize int) []categorizer.Group {
+	var kept []categorizer.Group
+	var small []categorizer.Result
+	for _, g := range groups {
+		if len(g.Results) >= minSize || g.Category == MiscDir || isDir(filepath.Join(baseDir, g.Category)) {
+			kept = append(kept, g)
+			continue
+		}
+		small = append(small, g.Results...)
+	}
+	if len(small) == 0 {
+		return groups
+	}
+
+	i := slices.IndexFunc(kept, func(g categorizer.Group) bool { return g.Category == MiscDir })
+	if i < 0 {
+		kept = append(kept, categorizer.Group{Category: MiscDir})
+		i = len(kept) - 1
+	}
+	kept[i].Results = append(kept[i].Results, small...)
+	return kept
+}
+
+// isDir reports whether path is an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,60 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+func TestMoveFilesMinCategorySize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "forest"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var results []categorizer.Result
+	for name, category := range map[string]string{
+		"beach1.jpg": "beach", "beach2.jpg": "beach",
+		"city.jpg": "city",
+		"tree.jpg": "forest",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, categorizer.Result{Path: path, Category: category, Confidence: 0.5})
+	}
+
+	moves, err := MoveFiles(dir, results, Options{MinCategorySize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"beach1.jpg": filepath.Join("beach", "beach1.jpg"),
+		"beach2.jpg": filepath.Join("beach", "beach2.jpg"),
+		"city.jpg":   filepath.Join(MiscDir, "city.jpg"),
+		"tree.jpg":   filepath.Join("forest", "tree.jpg"), // folder existed before the run
+	}
+	for _, m := range moves {
+		name := filepath.Base(m.SourcePath)
+		if m.DestPath != filepath.Join(dir, want[name]) {
+			t.Errorf("%s moved to %s, want %s", name, m.DestPath, want[name])
+		}
+		if name == "city.jpg" && m.Category != MiscDir {
+			t.Errorf("expected city.jpg recorded under %s, got %q", MiscDir, m.Category)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, MiscDir, "city.jpg")); err != nil {
+		t.Errorf("expected city.jpg in %s/: %v", MiscDir, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "city")); !os.IsNotExist(err) {
+		t.Errorf("expected no city/ folder, got %v", err)
+	}
+	for _, r := range results {
+		if r.Category == MiscDir {
+			t.Errorf("%s: results should keep their own category", r.Path)
+		}
+	}
+}
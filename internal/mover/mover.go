@@ -2,12 +2,20 @@
 package mover
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/filedate"
+	"github.com/bagtoad/imgsort/internal/manifest"
 )
 
 // MoveResult records what happened to a single file.
@@ -15,61 +23,525 @@ type MoveResult struct {
 	SourcePath string
 	DestPath   string
 	Category   string
+
+	// Skipped is set when the file was left in place; Reason explains why.
+	Skipped bool
+	Reason  string
+
+	// Overwrote is set when an existing file at DestPath was replaced.
+	// The replaced file cannot be recovered.
+	Overwrote bool
+
+	// Copied is set when the file was (or would be) copied under
+	// Options.Copy rather than moved.
+	Copied bool
+
+	// Secondary is the runner-up category the file was also recorded under,
+	// and SecondaryPath the symlink or sidecar created there.
+	Secondary     string
+	SecondaryPath string
+
+	// Group is the burst ID shared by files that were sorted together.
+	Group string
+
+	// Tier is the file's confidence tier, if the run used one; files in
+	// categorizer.TierTentative go to the category's TentativeDir.
+	Tier string
+
+	// Size is the source file's size in bytes, or zero if it could not be
+	// read. It is set for every file that was (or would be) moved, and
+	// includes any companions.
+	Size int64
+
+	// Live is set for a Live Photo or Motion Photo, and Companions holds
+	// the videos that belong with it and where they were moved.
+	Live       bool
+	Companions []Companion
+
+	// ImplausibleDate is set under DateFolders when a source of the file's
+	// date was passed over as implausible. The file was dated by the next
+	// source, or, under Dates.Strict, moved to UnsortedDir instead, which
+	// sets DateUnsorted.
+	ImplausibleDate bool
+	DateUnsorted    bool
 }
 
-// MoveFiles moves categorized images into category subfolders within baseDir.
-// If dryRun is true, no files are moved but results are still returned.
-func MoveFiles(baseDir string, results []categorizer.Result, dryRun bool) ([]MoveResult, error) {
-	groups := categorizer.GroupByCategory(results)
-	var moveResults []MoveResult
+// Companion is a file moved along with an image, such as a Live Photo video.
+type Companion struct {
+	SourcePath string
+	DestPath   string
+}
+
+// Options controls how MoveFiles places files.
+type Options struct {
+	// DryRun computes destinations without touching the filesystem.
+	DryRun bool
+
+	// OutputDir, if set, is where category folders are created and
+	// recorded in the manifest, instead of baseDir. PreserveStructure still
+	// mirrors directories relative to baseDir.
+	OutputDir string
+
+	// Copy copies files into their category folders and leaves the
+	// originals in place. A transactional rollback deletes the copies.
+	Copy bool
+
+	// OnConflict decides what happens when a destination already exists.
+	// The zero value behaves like ConflictSuffix.
+	OnConflict ConflictStrategy
+
+	// Secondary additionally records each file under its runner-up category.
+	// Runner-ups scoring below SecondaryThreshold are omitted.
+	Secondary          SecondaryMode
+	SecondaryThreshold float64
+
+	// MaxPerCategory caps how many files go into one folder. Larger
+	// categories are split into part-1, part-2, ... subfolders, filled in
+	// order of descending confidence; tentative files go to the _tentative
+	// folder inside their part. Zero means no limit.
+	MaxPerCategory int
+
+	// PreserveStructure mirrors each file's directory relative to baseDir
+	// under its category folder (landscape/2023/iceland/IMG_1.jpg) instead
+	// of placing every file directly in the category folder.
+	PreserveStructure bool
+
+	// DateFolders places each file in a YYYY/MM folder inside its category
+	// folder (landscape/2023/07/IMG_1.jpg), by the date filedate.Resolve
+	// finds for it with Dates. Combined with PreserveStructure, the
+	// mirrored directories go inside the date folder. With Dates.Strict, a
+	// file with an implausible date goes to UnsortedDir, without a date
+	// folder, instead of its category.
+	DateFolders bool
+	Dates       filedate.Options
+
+	// Transactional records every change and, if the run fails partway,
+	// undoes them all before returning the error, so the directory is left
+	// as it started. Files replaced by ConflictOverwrite are kept aside
+	// until the run completes.
+	Transactional bool
+
+	// Live maps Live Photo and Motion Photo stills to their companion
+	// videos, which are moved alongside the still and renamed to match it.
+	Live map[string][]string
+
+	// OnFolderConflict decides what happens when a category's folder name
+	// is taken by a file. The zero value behaves like FolderFail.
+	OnFolderConflict FolderPolicy
+
+	// MinCategorySize gathers categories that captured fewer than this many
+	// images in the run into MiscDir instead of giving each its own folder,
+	// keeping exploratory sorts tidy. Categories whose folder already
+	// exists are kept. Zero means every category gets a folder.
+	MinCategorySize int
+
+	// Unsorted decides whether images that were not categorized stay in
+	// place or move into UnsortedDir. The zero value behaves like
+	// UnsortedLeave.
+	Unsorted UnsortedPolicy
+
+	// IgnoreVanished checks that each source still exists just before it
+	// is moved, and leaves any that has vanished out of the results
+	// entirely instead of reporting it with ReasonSourceDisappeared.
+	IgnoreVanished bool
+
+	// BeforeMove, if set, is called with each planned move (including in
+	// dry runs) before it happens. Returning ErrSkipMove leaves the file in
+	// place; any other error aborts the run.
+	BeforeMove func(MoveResult) error
+
+	// AfterMove, if set, is called after each file is moved (or, in a dry
+	// run, would have been).
+	AfterMove func(MoveResult)
+}
+
+// TentativeDir is the subfolder of a category that images categorized
+// with categorizer.TierTentative are placed in, to be reviewed later.
+const TentativeDir = "_tentative"
+
+// ReasonSourceDisappeared is the MoveResult.Reason of a file deleted or
+// moved by something else, such as a sync client, after it was classified.
+const ReasonSourceDisappeared = "source disappeared"
 
-	for category, items := range groups {
-		catDir := filepath.Join(baseDir, category)
+// ErrSkipMove is returned by an Options.BeforeMove hook to leave a file in place.
+var ErrSkipMove = errors.New("move skipped")
 
-		if !dryRun {
-			if err := os.MkdirAll(catDir, 0755); err != nil {
-				return nil, fmt.Errorf("cannot create category folder %q: %w", catDir, err)
+// MoveFiles moves categorized images into category subfolders within baseDir.
+// If opts.DryRun is true, no files are moved but results are still returned,
+// with destinations predicted as they would be for a real run.
+func MoveFiles(baseDir string, results []categorizer.Result, opts Options) ([]MoveResult, error) {
+	var j *journal
+	if opts.Transactional && !opts.DryRun {
+		j = &journal{}
+	}
+
+	moveResults, err := moveFiles(baseDir, results, opts, j)
+	if j != nil {
+		if err != nil {
+			if rbErr := j.rollback(); rbErr != nil {
+				return nil, fmt.Errorf("%w; rollback incomplete: %w", err, rbErr)
 			}
+			return nil, fmt.Errorf("%w (all moves from this run were rolled back)", err)
 		}
+		j.commit()
+	}
+	if !opts.DryRun {
+		recordManaged(opts.destDir(baseDir), moveResults)
+	}
+	return moveResults, err
+}
 
-		for _, item := range items {
-			destPath := filepath.Join(catDir, filepath.Base(item.Path))
-			destPath = resolveConflict(destPath, dryRun)
+// destDir returns the folder category folders are created in when sorting
+// baseDir.
+func (o Options) destDir(baseDir string) string {
+	if o.OutputDir != "" {
+		return o.OutputDir
+	}
+	return baseDir
+}
 
-			if !dryRun {
-				if err := os.Rename(item.Path, destPath); err != nil {
-					return nil, fmt.Errorf("cannot move %s to %s: %w", item.Path, destPath, err)
-				}
+// recordManaged adds the top-level folders that received files to baseDir's
+// manifest, so recursive scans skip them. Failing to record them does not
+// undo the moves, so it only logs a warning.
+func recordManaged(baseDir string, moves []MoveResult) {
+	folders := make(map[string]bool)
+	for _, m := range moves {
+		addManaged(folders, baseDir, m)
+	}
+	recordFolders(baseDir, folders)
+}
+
+// addManaged adds the top-level folders of baseDir that m put files in to
+// folders.
+func addManaged(folders map[string]bool, baseDir string, m MoveResult) {
+	if m.Skipped {
+		return
+	}
+	for _, p := range []string{m.DestPath, m.SecondaryPath} {
+		if rel, err := filepath.Rel(baseDir, p); err == nil && p != "" {
+			folders[strings.Split(rel, string(filepath.Separator))[0]] = true
+		}
+	}
+}
+
+// recordFolders records folders in baseDir's manifest, warning on failure.
+func recordFolders(baseDir string, folders map[string]bool) {
+	if len(folders) == 0 {
+		return
+	}
+	if err := manifest.Record(baseDir, slices.Sorted(maps.Keys(folders))...); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// run is the state shared by every file moved in one run. Files come from
+// baseDir and go into category folders in destDir.
+type run struct {
+	baseDir  string
+	destDir  string
+	opts     Options
+	strategy ConflictStrategy
+	j        *journal
+
+	// names tracks destinations planned during this run so that dry runs
+	// predict the same names a real run would produce.
+	names   *destNames
+	created map[string]bool
+}
+
+func newRun(baseDir string, opts Options, j *journal) *run {
+	strategy := opts.OnConflict
+	if strategy == "" {
+		strategy = ConflictSuffix
+	}
+	return &run{
+		baseDir:  baseDir,
+		destDir:  opts.destDir(baseDir),
+		opts:     opts,
+		strategy: strategy,
+		j:        j,
+		names:    newDestNames(os.Stat),
+		created:  make(map[string]bool),
+	}
+}
+
+func (r *run) exists(path string) bool {
+	return r.names.taken(path) || r.names.onDisk(path)
+}
+
+func (r *run) mkdir(dir string) error {
+	if r.opts.DryRun || r.created[dir] {
+		return nil
+	}
+	if err := r.j.mkdirAll(dir); err != nil {
+		return fmt.Errorf("cannot create category folder %q: %w", dir, err)
+	}
+	r.created[dir] = true
+	return nil
+}
+
+// moveFiles does the work of MoveFiles, recording changes in j if non-nil.
+func moveFiles(baseDir string, results []categorizer.Result, opts Options, j *journal) ([]MoveResult, error) {
+	groups := categorizer.GroupByCategory(results, categorizer.GroupOptions{})
+	destDir := opts.destDir(baseDir)
+	if opts.MinCategorySize > 1 {
+		groups = foldSmall(destDir, groups, opts.MinCategorySize)
+	}
+	if opts.Unsorted == UnsortedMove {
+		groups = withUnsorted(groups, results)
+	}
+	var moveResults []MoveResult
+	rn := newRun(baseDir, opts, j)
+
+	for _, g := range groups {
+		category, items := g.Category, g.Results
+		folder, skip, err := categoryFolder(destDir, category, opts.OnFolderConflict)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			for _, item := range items {
+				moveResults = append(moveResults, folderIsFile(category, item, opts))
 			}
+			continue
+		}
+		catDir := filepath.Join(destDir, folder)
 
-			moveResults = append(moveResults, MoveResult{
-				SourcePath: item.Path,
-				DestPath:   destPath,
-				Category:   category,
+		split := opts.MaxPerCategory > 0 && len(items) > opts.MaxPerCategory
+		if split {
+			items = slices.Clone(items)
+			sort.SliceStable(items, func(i, j int) bool {
+				return items[i].Confidence > items[j].Confidence
 			})
 		}
+
+		for i, item := range items {
+			dir := catDir
+			if split {
+				dir = filepath.Join(dir, fmt.Sprintf("part-%d", i/opts.MaxPerCategory+1))
+			}
+			mr, ok, err := rn.moveOne(category, dir, item)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				moveResults = append(moveResults, mr)
+			}
+		}
 	}
 
 	return moveResults, nil
 }
 
-// resolveConflict appends a numeric suffix if a file already exists at destPath.
-func resolveConflict(destPath string, dryRun bool) string {
-	if dryRun {
-		return destPath
+// folderIsFile is the result for an item whose category folder is taken
+// by a file under FolderSkipCategory.
+func folderIsFile(category string, item categorizer.Result, opts Options) MoveResult {
+	return MoveResult{
+		SourcePath: item.Path, Category: category, Group: item.Group, Tier: item.Tier,
+		Live: len(opts.Live[item.Path]) > 0, Skipped: true, Reason: ReasonFolderIsFile,
+	}
+}
+
+// moveOne moves item into dir, the folder of category or a part of it, or
+// the tentative folder inside dir if the item is tentative. ok is false for
+// a vanished source left out under IgnoreVanished.
+func (r *run) moveOne(category, dir string, item categorizer.Result) (mr MoveResult, ok bool, err error) {
+	opts, j, names := r.opts, r.j, r.names
+	if opts.IgnoreVanished && vanished(item.Path) {
+		return MoveResult{}, false, nil
+	}
+	var dated string
+	var implausible, dateUnsorted bool
+	if opts.DateFolders {
+		// A file whose date cannot be read, such as one that vanished, is
+		// left to the move to report.
+		d, err := filedate.Resolve(item.Path, opts.Dates)
+		switch {
+		case errors.Is(err, filedate.ErrImplausible):
+			folder, skip, err := categoryFolder(r.destDir, UnsortedDir, opts.OnFolderConflict)
+			if err != nil {
+				return mr, false, err
+			}
+			implausible, dateUnsorted = true, true
+			if skip {
+				mr = folderIsFile(UnsortedDir, item, opts)
+				mr.ImplausibleDate, mr.DateUnsorted = true, true
+				return mr, true, nil
+			}
+			category, dir = UnsortedDir, filepath.Join(r.destDir, folder)
+		case err == nil:
+			dated = filepath.Join(d.Time.Format("2006"), d.Time.Format("01"))
+			implausible = d.Implausible
+		}
+	}
+	if item.Tier == categorizer.TierTentative && !dateUnsorted {
+		dir = filepath.Join(dir, TentativeDir)
+	}
+	if dated != "" {
+		dir = filepath.Join(dir, dated)
+	}
+	if opts.PreserveStructure {
+		dir = filepath.Join(dir, relativeDir(r.baseDir, item.Path))
+	}
+	if err := r.mkdir(dir); err != nil {
+		return mr, false, err
+	}
+
+	companions, live := opts.Live[item.Path]
+	var companionTaken func(string) bool
+	if len(companions) > 0 {
+		// A name is only free if every file of the pair fits.
+		companionTaken = func(path string) bool {
+			for _, c := range companions {
+				if r.exists(companionPath(path, c)) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	destPath := filepath.Join(dir, filepath.Base(item.Path))
+	destPath, action, err := resolveConflict(item.Path, destPath, r.strategy, names, companionTaken)
+	if err != nil {
+		return mr, false, err
+	}
+
+	mr = MoveResult{
+		SourcePath: item.Path,
+		DestPath:   destPath,
+		Category:   category,
+		Group:      item.Group,
+		Tier:       item.Tier,
+		Live:       live,
+		Copied:     opts.Copy,
+
+		ImplausibleDate: implausible,
+		DateUnsorted:    dateUnsorted,
 	}
 
-	if _, err := os.Stat(destPath); os.IsNotExist(err) {
-		return destPath
+	switch action {
+	case actionSkip:
+		mr.Skipped = true
+		mr.Reason = "destination exists"
+		return mr, true, nil
+	case actionOverwrite:
+		mr.Overwrote = true
+	}
+	for _, p := range append([]string{item.Path}, companions...) {
+		if info, err := os.Stat(p); err == nil {
+			mr.Size += info.Size()
+		}
+	}
+	if opts.BeforeMove != nil {
+		if err := opts.BeforeMove(mr); errors.Is(err, ErrSkipMove) {
+			mr.Skipped = true
+			mr.Reason = "skipped by hook"
+			mr.Overwrote = false
+			return mr, true, nil
+		} else if err != nil {
+			return mr, false, err
+		}
 	}
 
-	ext := filepath.Ext(destPath)
-	base := strings.TrimSuffix(destPath, ext)
+	if !opts.DryRun {
+		if mr.Overwrote {
+			if err := j.setAside(destPath); err != nil {
+				return mr, false, err
+			}
+		}
+		if err := r.transfer(item.Path, destPath); err != nil {
+			if vanished(item.Path) {
+				if mr.Overwrote {
+					if err := j.restoreAside(destPath); err != nil {
+						return mr, false, err
+					}
+				}
+				mr.Skipped = true
+				mr.Reason = ReasonSourceDisappeared
+				mr.Overwrote = false
+				return mr, true, nil
+			}
+			return mr, false, err
+		}
+	}
+	names.claim(destPath)
+
+	for _, c := range companions {
+		cDest := companionPath(destPath, c)
+		if !opts.DryRun {
+			if mr.Overwrote && r.exists(cDest) {
+				if err := j.setAside(cDest); err != nil {
+					return mr, false, err
+				}
+			}
+			if err := r.transfer(c, cDest); err != nil {
+				if vanished(c) {
+					// The image moved; only its companion is gone.
+					continue
+				}
+				return mr, false, err
+			}
+		}
+		names.claim(cDest)
+		mr.Companions = append(mr.Companions, Companion{SourcePath: c, DestPath: cDest})
+	}
 
-	for i := 1; ; i++ {
-		candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
-		if _, err := os.Stat(candidate); os.IsNotExist(err) {
-			return candidate
+	if opts.Secondary != SecondaryNone && item.RunnerUp != "" &&
+		float64(item.RunnerUpConfidence) >= opts.SecondaryThreshold {
+		secFolder, skip, err := categoryFolder(r.destDir, item.RunnerUp, opts.OnFolderConflict)
+		if err != nil {
+			return mr, false, err
 		}
+		if !skip {
+			secPath, err := placeSecondary(filepath.Join(r.destDir, secFolder), item, destPath, opts.Secondary, opts.DryRun, names, r.mkdir, j)
+			if err != nil {
+				return mr, false, err
+			}
+			names.claim(secPath)
+			mr.Secondary = item.RunnerUp
+			mr.SecondaryPath = secPath
+		}
+	}
+
+	if opts.AfterMove != nil {
+		opts.AfterMove(mr)
+	}
+	return mr, true, nil
+}
+
+// transfer moves from to to, or copies it under Copy.
+func (r *run) transfer(from, to string) error {
+	if r.opts.Copy {
+		if err := r.j.copy(from, to); err != nil {
+			return fmt.Errorf("cannot copy %s to %s: %w", from, to, err)
+		}
+		return nil
+	}
+	if err := r.j.move(from, to); err != nil {
+		return fmt.Errorf("cannot move %s to %s: %w", from, to, err)
+	}
+	return nil
+}
+
+// vanished reports whether path no longer exists.
+func vanished(path string) bool {
+	_, err := os.Lstat(path)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// companionPath returns where a companion file goes when its still moves to
+// dest: next to it, with the same name and the companion's extension.
+func companionPath(dest, companion string) string {
+	return strings.TrimSuffix(dest, filepath.Ext(dest)) + filepath.Ext(companion)
+}
+
+// relativeDir returns the directory of path relative to baseDir, or "" when
+// the file is directly in baseDir or outside it.
+func relativeDir(baseDir, path string) string {
+	rel, err := filepath.Rel(baseDir, filepath.Dir(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
 	}
+	return rel
 }
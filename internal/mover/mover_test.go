@@ -1,11 +1,18 @@
 package mover
 
 import (
+	"errors"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/filedate"
+	"github.com/bagtoad/imgsort/internal/manifest"
+	"github.com/bagtoad/imgsort/internal/model"
 )
 
 func TestMoveFiles(t *testing.T) {
@@ -25,7 +32,7 @@ func TestMoveFiles(t *testing.T) {
 		{Path: filepath.Join(dir, "food.png"), Category: "food", Confidence: 0.9},
 	}
 
-	moves, err := MoveFiles(dir, results, false)
+	moves, err := MoveFiles(dir, results, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -56,6 +63,128 @@ func TestMoveFiles(t *testing.T) {
 	}
 }
 
+func TestMoveFilesTentative(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"sure.jpg", "maybe.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake image"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := []categorizer.Result{
+		{Path: filepath.Join(dir, "sure.jpg"), Category: "beach", Confidence: 0.8, Tier: categorizer.TierConfident},
+		{Path: filepath.Join(dir, "maybe.jpg"), Category: "beach", Confidence: 0.2, Tier: categorizer.TierTentative},
+	}
+
+	moves, err := MoveFiles(dir, results, Options{PreserveStructure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"sure.jpg":  filepath.Join(dir, "beach", "sure.jpg"),
+		"maybe.jpg": filepath.Join(dir, "beach", TentativeDir, "maybe.jpg"),
+	}
+	for _, m := range moves {
+		if dest := want[filepath.Base(m.SourcePath)]; m.DestPath != dest {
+			t.Errorf("%s: expected %s, got %s", m.SourcePath, dest, m.DestPath)
+		}
+		if _, err := os.Stat(m.DestPath); err != nil {
+			t.Error(err)
+		}
+	}
+	if m, err := manifest.Load(dir); err != nil || m == nil || len(m.Managed) != 1 || m.Managed[0] != "beach" {
+		t.Errorf("expected only the category folder to be recorded, got %+v, %v", m, err)
+	}
+}
+
+func TestMoveFilesOpenSet(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"beach.jpg", "nothing.jpg", "broken.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake image"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := categorizer.Decide([]categorizer.ImageScores{
+		{Path: filepath.Join(dir, "beach.jpg"), Scores: map[string]float32{model.BaselineCategory: 0.1, "beach": 0.9}},
+		{Path: filepath.Join(dir, "nothing.jpg"), Scores: map[string]float32{model.BaselineCategory: 0.7, "beach": 0.3}},
+		{Path: filepath.Join(dir, "broken.jpg"), Error: "cannot decode image"},
+	}, categorizer.Options{Threshold: 0.2, OpenSet: "misc", Quiet: true})
+
+	if _, err := MoveFiles(dir, results, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"beach/beach.jpg", "misc/nothing.jpg", "broken.jpg"} {
+		if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+			t.Errorf("expected %s: %v", p, err)
+		}
+	}
+}
+
+func TestMoveFilesSourceDisappeared(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		dir := t.TempDir()
+		for _, f := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+			if err := os.WriteFile(filepath.Join(dir, f), []byte(f), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		results := categorizer.Decide([]categorizer.ImageScores{
+			{Path: filepath.Join(dir, "a.jpg"), Scores: map[string]float32{"beach": 0.9}},
+			{Path: filepath.Join(dir, "b.jpg"), Scores: map[string]float32{"beach": 0.9}},
+			{Path: filepath.Join(dir, "c.jpg"), Scores: map[string]float32{"city": 0.9}},
+		}, categorizer.Options{Threshold: 0.2, Quiet: true})
+
+		// A sync client removes b.jpg after it was classified.
+		if err := os.Remove(filepath.Join(dir, "b.jpg")); err != nil {
+			t.Fatal(err)
+		}
+		moves, err := MoveFiles(dir, results, Options{IgnoreVanished: ignore})
+		if err != nil {
+			t.Fatalf("ignore=%v: %v", ignore, err)
+		}
+		for _, p := range []string{"beach/a.jpg", "city/c.jpg"} {
+			if _, err := os.Stat(filepath.Join(dir, p)); err != nil {
+				t.Errorf("ignore=%v: expected %s: %v", ignore, p, err)
+			}
+		}
+
+		var vanished []MoveResult
+		for _, m := range moves {
+			if m.SourcePath == filepath.Join(dir, "b.jpg") {
+				vanished = append(vanished, m)
+			}
+		}
+		switch {
+		case ignore && len(vanished) != 0:
+			t.Errorf("with IgnoreVanished, expected b.jpg to be dropped, got %+v", vanished)
+		case !ignore && (len(vanished) != 1 || !vanished[0].Skipped || vanished[0].Reason != ReasonSourceDisappeared):
+			t.Errorf("expected b.jpg skipped as %q, got %+v", ReasonSourceDisappeared, vanished)
+		}
+	}
+}
+
+func TestMoveFilesSourceDisappearedKeepsOverwriteTarget(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "beach"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "beach", "a.jpg"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results := []categorizer.Result{{Path: filepath.Join(dir, "a.jpg"), Category: "beach", Confidence: 0.9}}
+
+	moves, err := MoveFiles(dir, results, Options{OnConflict: ConflictOverwrite, Transactional: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].Reason != ReasonSourceDisappeared || moves[0].Overwrote {
+		t.Errorf("unexpected results: %+v", moves)
+	}
+	want := map[string]string{".": "<dir>", "beach": "<dir>", "beach/a.jpg": "existing"}
+	if got := listTree(t, dir); !maps.Equal(got, want) {
+		t.Errorf("directory = %v, want %v", got, want)
+	}
+}
+
 func TestMoveFilesDryRun(t *testing.T) {
 	dir := t.TempDir()
 
@@ -67,7 +196,7 @@ func TestMoveFilesDryRun(t *testing.T) {
 		{Path: filepath.Join(dir, "test.jpg"), Category: "nature", Confidence: 0.5},
 	}
 
-	moves, err := MoveFiles(dir, results, true)
+	moves, err := MoveFiles(dir, results, Options{DryRun: true})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -106,7 +235,7 @@ func TestMoveFilesConflict(t *testing.T) {
 		{Path: filepath.Join(dir, "photo.jpg"), Category: "nature", Confidence: 0.5},
 	}
 
-	moves, err := MoveFiles(dir, results, false)
+	moves, err := MoveFiles(dir, results, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -137,7 +266,7 @@ func TestMoveFilesSkipped(t *testing.T) {
 		{Path: "/fake/path.jpg", Skipped: true},
 	}
 
-	moves, err := MoveFiles(dir, results, false)
+	moves, err := MoveFiles(dir, results, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -146,3 +275,403 @@ func TestMoveFilesSkipped(t *testing.T) {
 		t.Errorf("expected 0 moves for skipped files, got %d", len(moves))
 	}
 }
+
+func TestMoveFilesMaxPerCategory(t *testing.T) {
+	dir := t.TempDir()
+
+	var results []categorizer.Result
+	for i := 0; i < 250; i++ {
+		name := fmt.Sprintf("scan%03d.png", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, categorizer.Result{
+			Path:       filepath.Join(dir, name),
+			Category:   "document",
+			Confidence: float32(i) / 250,
+		})
+	}
+
+	moves, err := MoveFiles(dir, results, Options{MaxPerCategory: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+	for _, m := range moves {
+		counts[filepath.Base(filepath.Dir(m.DestPath))]++
+	}
+	expected := map[string]int{"part-1": 100, "part-2": 100, "part-3": 50}
+	for part, n := range expected {
+		if counts[part] != n {
+			t.Errorf("expected %d files in %s, got %d", n, part, counts[part])
+		}
+	}
+	if len(counts) != len(expected) {
+		t.Errorf("expected exactly 3 parts, got %v", counts)
+	}
+
+	// The most confident match fills part-1 first.
+	best := filepath.Join(dir, "document", "part-1", "scan249.png")
+	if _, err := os.Stat(best); err != nil {
+		t.Errorf("expected best match in part-1: %v", err)
+	}
+}
+
+func TestMoveFilesMaxPerCategoryTentative(t *testing.T) {
+	dir := t.TempDir()
+
+	var results []categorizer.Result
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("scan%d.png", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tier := categorizer.TierConfident
+		if i < 3 {
+			tier = categorizer.TierTentative
+		}
+		results = append(results, categorizer.Result{
+			Path:       filepath.Join(dir, name),
+			Category:   "document",
+			Confidence: float32(i) / 5,
+			Tier:       tier,
+		})
+	}
+
+	if _, err := MoveFiles(dir, results, Options{MaxPerCategory: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Parts fill by confidence, and tentative files keep their own folder
+	// inside whichever part they land in.
+	for _, want := range []string{
+		filepath.Join("part-1", "scan4.png"),
+		filepath.Join("part-1", "scan3.png"),
+		filepath.Join("part-2", TentativeDir, "scan2.png"),
+		filepath.Join("part-2", TentativeDir, "scan1.png"),
+		filepath.Join("part-3", TentativeDir, "scan0.png"),
+	} {
+		if _, err := os.Stat(filepath.Join(dir, "document", want)); err != nil {
+			t.Errorf("expected document/%s: %v", want, err)
+		}
+	}
+}
+
+func TestMoveFilesMaxPerCategoryUnderLimit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.jpg"), []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{{Path: filepath.Join(dir, "a.jpg"), Category: "beach", Confidence: 0.5}}
+	moves, err := MoveFiles(dir, results, Options{MaxPerCategory: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moves[0].DestPath != filepath.Join(dir, "beach", "a.jpg") {
+		t.Errorf("categories under the limit should not be split, got %s", moves[0].DestPath)
+	}
+}
+
+// writeNestedFixture creates a small nested tree under dir and returns
+// results for every file, all categorized as landscape.
+func writeNestedFixture(t *testing.T, dir string) []categorizer.Result {
+	t.Helper()
+	files := []string{
+		"top.jpg",
+		filepath.Join("2023", "iceland", "IMG_1.jpg"),
+		filepath.Join("2024", "iceland", "IMG_1.jpg"),
+	}
+	var results []categorizer.Result
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, categorizer.Result{Path: path, Category: "landscape", Confidence: 0.5})
+	}
+	return results
+}
+
+func TestMoveFilesPreserveStructure(t *testing.T) {
+	dir := t.TempDir()
+	results := writeNestedFixture(t, dir)
+
+	moves, err := MoveFiles(dir, results, Options{PreserveStructure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "landscape", "top.jpg"),
+		filepath.Join(dir, "landscape", "2023", "iceland", "IMG_1.jpg"),
+		filepath.Join(dir, "landscape", "2024", "iceland", "IMG_1.jpg"),
+	}
+	for i, m := range moves {
+		if m.DestPath != expected[i] {
+			t.Errorf("expected dest %s, got %s", expected[i], m.DestPath)
+		}
+		if _, err := os.Stat(m.DestPath); err != nil {
+			t.Errorf("moved file missing: %v", err)
+		}
+	}
+}
+
+func TestMoveFilesFlatLayout(t *testing.T) {
+	dir := t.TempDir()
+	results := writeNestedFixture(t, dir)
+
+	moves, err := MoveFiles(dir, results, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both IMG_1.jpg files land directly in the category folder, so the
+	// second one gets a suffix.
+	expected := []string{
+		filepath.Join(dir, "landscape", "top.jpg"),
+		filepath.Join(dir, "landscape", "IMG_1.jpg"),
+		filepath.Join(dir, "landscape", "IMG_1_1.jpg"),
+	}
+	for i, m := range moves {
+		if m.DestPath != expected[i] {
+			t.Errorf("expected dest %s, got %s", expected[i], m.DestPath)
+		}
+	}
+}
+
+func TestMoveFilesDateFolders(t *testing.T) {
+	dir := t.TempDir()
+	results := writeNestedFixture(t, dir)
+	dates := []time.Time{
+		time.Date(2021, 3, 14, 12, 0, 0, 0, time.Local),
+		time.Date(2023, 7, 1, 12, 0, 0, 0, time.Local),
+		time.Date(2023, 7, 30, 12, 0, 0, 0, time.Local),
+	}
+	for i, r := range results {
+		if err := os.Chtimes(r.Path, dates[i], dates[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moves, err := MoveFiles(dir, results, Options{DateFolders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files without EXIF are dated by their modification time.
+	expected := []string{
+		filepath.Join(dir, "landscape", "2021", "03", "top.jpg"),
+		filepath.Join(dir, "landscape", "2023", "07", "IMG_1.jpg"),
+		filepath.Join(dir, "landscape", "2023", "07", "IMG_1_1.jpg"),
+	}
+	for i, m := range moves {
+		if m.DestPath != expected[i] {
+			t.Errorf("expected dest %s, got %s", expected[i], m.DestPath)
+		}
+		if _, err := os.Stat(m.DestPath); err != nil {
+			t.Errorf("moved file missing: %v", err)
+		}
+	}
+}
+
+func TestMoveFilesStrictDates(t *testing.T) {
+	dir := t.TempDir()
+	results := writeNestedFixture(t, dir)[:2]
+	results[1].Tier = categorizer.TierTentative
+	epoch := time.Unix(0, 0)
+	if err := os.Chtimes(results[1].Path, epoch, epoch); err != nil {
+		t.Fatal(err)
+	}
+	taken := time.Date(2023, 7, 1, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(results[0].Path, taken, taken); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without Strict, the 1970 file falls back to today's date.
+	moves, err := MoveFiles(dir, results, Options{DryRun: true, DateFolders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := moves[1]; !m.ImplausibleDate || m.DateUnsorted || m.Category != "landscape" {
+		t.Errorf("expected a fallback date in landscape, got %+v", m)
+	}
+
+	moves, err = MoveFiles(dir, results, Options{DateFolders: true, Dates: filedate.Options{Strict: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := moves[0]; m.ImplausibleDate || m.DestPath != filepath.Join(dir, "landscape", "2023", "07", "top.jpg") {
+		t.Errorf("expected top.jpg dated 2023/07, got %+v", m)
+	}
+	if m := moves[1]; !m.DateUnsorted || m.Category != UnsortedDir ||
+		m.DestPath != filepath.Join(dir, UnsortedDir, "IMG_1.jpg") {
+		t.Errorf("expected IMG_1.jpg moved to %s, got %+v", UnsortedDir, m)
+	}
+	if m, _ := manifest.Load(dir); m == nil || !m.IsManaged(UnsortedDir) {
+		t.Errorf("expected %s in the manifest, got %+v", UnsortedDir, m)
+	}
+}
+
+func TestMoveFilesCopyToOutputDir(t *testing.T) {
+	dir, out := t.TempDir(), t.TempDir()
+	results := writeNestedFixture(t, dir)
+	before := listTree(t, dir)
+
+	moves, err := MoveFiles(dir, results, Options{Copy: true, OutputDir: out, PreserveStructure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Directories are mirrored relative to the directory being sorted.
+	expected := []string{
+		filepath.Join(out, "landscape", "top.jpg"),
+		filepath.Join(out, "landscape", "2023", "iceland", "IMG_1.jpg"),
+		filepath.Join(out, "landscape", "2024", "iceland", "IMG_1.jpg"),
+	}
+	for i, m := range moves {
+		if m.DestPath != expected[i] {
+			t.Errorf("expected dest %s, got %s", expected[i], m.DestPath)
+		}
+		data, err := os.ReadFile(m.DestPath)
+		if err != nil || string(data) != before[mustRel(t, dir, m.SourcePath)] {
+			t.Errorf("%s: expected a copy of %s, got %q, %v", m.DestPath, m.SourcePath, data, err)
+		}
+	}
+	if after := listTree(t, dir); !maps.Equal(before, after) {
+		t.Errorf("the originals should be left in place, got %v", after)
+	}
+	if m, _ := manifest.Load(out); m == nil || !m.IsManaged("landscape") {
+		t.Errorf("expected landscape in the output directory's manifest, got %+v", m)
+	}
+	if m, _ := manifest.Load(dir); m != nil {
+		t.Errorf("the sorted directory should have no manifest, got %+v", m)
+	}
+}
+
+func mustRel(t *testing.T, base, path string) string {
+	t.Helper()
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rel
+}
+
+func TestMoveFilesHooks(t *testing.T) {
+	dir := t.TempDir()
+	var results []categorizer.Result
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, categorizer.Result{Path: path, Category: "beach", Confidence: 0.5})
+	}
+
+	var after []string
+	moves, err := MoveFiles(dir, results, Options{
+		BeforeMove: func(m MoveResult) error {
+			if filepath.Base(m.SourcePath) == "b.jpg" {
+				return ErrSkipMove
+			}
+			return nil
+		},
+		AfterMove: func(m MoveResult) { after = append(after, filepath.Base(m.DestPath)) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after) != 1 || after[0] != "a.jpg" {
+		t.Errorf("expected AfterMove for a.jpg only, got %v", after)
+	}
+	if !moves[1].Skipped || moves[1].Reason != "skipped by hook" {
+		t.Errorf("expected b.jpg to be skipped by the hook, got %+v", moves[1])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.jpg")); err != nil {
+		t.Error("vetoed file should stay in place")
+	}
+
+	abort := errors.New("stop")
+	_, err = MoveFiles(dir, []categorizer.Result{{Path: filepath.Join(dir, "b.jpg"), Category: "city"}}, Options{
+		BeforeMove: func(MoveResult) error { return abort },
+	})
+	if !errors.Is(err, abort) {
+		t.Errorf("expected hook error to abort, got %v", err)
+	}
+}
+
+func TestMoveFilesRecordsManagedFolders(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"beach.jpg", "lunch.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := []categorizer.Result{
+		{Path: filepath.Join(dir, "beach.jpg"), Category: "beach", Confidence: 0.8},
+		{Path: filepath.Join(dir, "lunch.jpg"), Category: "food", Confidence: 0.8},
+	}
+
+	if _, err := MoveFiles(dir, results, Options{DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := manifest.Load(dir); m != nil {
+		t.Fatal("a dry run should not write the manifest")
+	}
+
+	if _, err := MoveFiles(dir, results, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsManaged("beach") || !m.IsManaged("food") {
+		t.Errorf("expected beach and food to be managed, got %v", m.Managed)
+	}
+}
+
+func TestMoveFilesLivePhotos(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"IMG_1.JPG", "IMG_1.MOV", "beach/IMG_1.MOV"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	still := filepath.Join(dir, "IMG_1.JPG")
+	results := []categorizer.Result{{Path: still, Category: "beach", Confidence: 0.8}}
+	live := map[string][]string{still: {filepath.Join(dir, "IMG_1.MOV")}}
+
+	moves, err := MoveFiles(dir, results, Options{Live: live})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the video's name is taken in beach/, but the pair moves under a
+	// shared new name so the two stay matched.
+	m := moves[0]
+	wantStill := filepath.Join(dir, "beach", "IMG_1_1.JPG")
+	wantVideo := filepath.Join(dir, "beach", "IMG_1_1.MOV")
+	if !m.Live || m.DestPath != wantStill || len(m.Companions) != 1 || m.Companions[0].DestPath != wantVideo {
+		t.Fatalf("unexpected move: %+v", m)
+	}
+	if data, err := os.ReadFile(wantVideo); err != nil || string(data) != "IMG_1.MOV" {
+		t.Errorf("video should move with its still: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "beach", "IMG_1.MOV")); err != nil || string(data) != "beach/IMG_1.MOV" {
+		t.Error("the existing video should be left alone")
+	}
+	if m.Size != int64(len("IMG_1.JPG")+len("IMG_1.MOV")) {
+		t.Errorf("size should include the companion, got %d", m.Size)
+	}
+}
@@ -0,0 +1,118 @@
+package mover
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// destNames tracks which destination paths are taken: those already on
+// disk, read with one listing per directory, and those claimed by moves
+// planned earlier in the run. It lets thousands of same-named files find
+// free suffixes without a stat for every candidate.
+type destNames struct {
+	// stat double-checks a name the listing says is free, since the
+	// listing may be stale or the filesystem case-insensitive.
+	stat func(string) (os.FileInfo, error)
+
+	listed  map[string]map[string]bool
+	claimed map[string]bool
+
+	// moved holds the claims made by moves in this run, as opposed to
+	// files found on disk.
+	moved map[string]bool
+
+	// next maps a suffix pattern to the lowest _N that may be free; every
+	// lower one is known to be taken.
+	next map[string]int
+
+	// onlyDisk leaves claims unrecorded, for a streamed run whose moves
+	// are found on disk, so memory does not grow with each file moved.
+	onlyDisk bool
+}
+
+// newDestNames returns an empty destNames that double-checks with stat.
+func newDestNames(stat func(string) (os.FileInfo, error)) *destNames {
+	return &destNames{
+		stat:    stat,
+		listed:  make(map[string]map[string]bool),
+		claimed: make(map[string]bool),
+		moved:   make(map[string]bool),
+		next:    make(map[string]int),
+	}
+}
+
+// taken reports whether path was in its directory's listing or has been
+// claimed. A directory that cannot be listed counts as empty, leaving it
+// to the final check on disk.
+func (d *destNames) taken(path string) bool {
+	if d.claimed[path] {
+		return true
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	names, ok := d.listed[dir]
+	if !ok {
+		entries, _ := os.ReadDir(dir)
+		names = make(map[string]bool, len(entries))
+		for _, e := range entries {
+			names[e.Name()] = true
+		}
+		d.listed[dir] = names
+	}
+	return names[name]
+}
+
+// claim marks path as taken by a move planned in this run.
+func (d *destNames) claim(path string) {
+	if d.onlyDisk {
+		return
+	}
+	d.claimed[path] = true
+	d.moved[path] = true
+}
+
+// movedHere reports whether path was claimed by a move in this run.
+func (d *destNames) movedHere(path string) bool {
+	return d.moved[path]
+}
+
+// onDisk reports whether path exists although taken said it was free, and
+// remembers it if so.
+func (d *destNames) onDisk(path string) bool {
+	if _, err := d.stat(path); err != nil {
+		return false
+	}
+	if !d.onlyDisk {
+		d.claimed[path] = true
+	}
+	return true
+}
+
+// free reports whether path is free: neither taken, nor taken by the
+// caller's own test (if any), nor found on disk.
+func (d *destNames) free(path string, taken func(string) bool) bool {
+	return !d.taken(path) && (taken == nil || !taken(path)) && !d.onDisk(path)
+}
+
+// suffixed returns the first free base_N+ext, for N from 1. Probing starts
+// at the lowest N not already known to be taken, so numbering a burst of
+// same-named files is linear rather than quadratic. A name only taken's
+// test rejects is skipped without moving the start, since other files may
+// still fit there.
+func (d *destNames) suffixed(base, ext string, taken func(string) bool) string {
+	key := base + "\x00" + ext
+	start := max(d.next[key], 1)
+	for i := start; ; i++ {
+		candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+		switch {
+		case d.taken(candidate) || d.onDisk(candidate):
+			if i == start {
+				start++
+				d.next[key] = start
+			}
+		case taken != nil && taken(candidate):
+		default:
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package mover
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// probeSuffix is the search resolveConflict made before destNames: a stat
+// for every candidate from _1 up, each time.
+func probeSuffix(dest string, taken func(string) bool) string {
+	if !taken(dest) {
+		return dest
+	}
+	ext := filepath.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	for i := 1; ; i++ {
+		if candidate := fmt.Sprintf("%s_%d%s", base, i, ext); !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+func TestSuffixMatchesProbing(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"photo.jpg", "photo_1.jpg", "photo_3.jpg", "photo_5.mov"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mov := func(path string) string { return strings.TrimSuffix(path, ".jpg") + ".mov" }
+	onDisk := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	names := newDestNames(os.Stat)
+	claimed := make(map[string]bool)
+	// Live Photos need the .mov name free too, so they skip photo_5.
+	for i, live := range []bool{false, true, false, false, true, false, false, true, false, false} {
+		if i == 4 {
+			// A file appearing after the listing is caught on disk.
+			if err := os.WriteFile(filepath.Join(dir, "photo_8.jpg"), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dest := filepath.Join(dir, "photo.jpg")
+		var companionTaken func(string) bool
+		if live {
+			companionTaken = func(p string) bool { return names.taken(mov(p)) || names.onDisk(mov(p)) }
+		}
+		got, action, err := resolveConflict("", dest, ConflictSuffix, names, companionTaken)
+		if err != nil || action != actionMove {
+			t.Fatalf("resolveConflict: %v, %v", action, err)
+		}
+		want := probeSuffix(dest, func(p string) bool {
+			return claimed[p] || onDisk(p) || (live && (claimed[mov(p)] || onDisk(mov(p))))
+		})
+		if got != want {
+			t.Errorf("file %d (live %v): got %s, want %s", i, live, filepath.Base(got), filepath.Base(want))
+		}
+		names.claim(got)
+		claimed[got] = true
+		if live {
+			names.claim(mov(got))
+			claimed[mov(got)] = true
+		}
+	}
+}
+
+// BenchmarkSuffixBurst numbers a burst of same-named files landing in one
+// folder, with the listing-based search and with a stat per candidate.
+func BenchmarkSuffixBurst(b *testing.B) {
+	const n = 2000
+	dir := b.TempDir()
+	dest := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(dest, nil, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("listing", func(b *testing.B) {
+		for b.Loop() {
+			names := newDestNames(os.Stat)
+			for range n {
+				got, _, err := resolveConflict("", dest, ConflictSuffix, names, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				names.claim(got)
+			}
+		}
+	})
+	b.Run("stat", func(b *testing.B) {
+		for b.Loop() {
+			claimed := make(map[string]bool)
+			taken := func(p string) bool {
+				if claimed[p] {
+					return true
+				}
+				_, err := os.Stat(p)
+				return err == nil
+			}
+			for range n {
+				claimed[probeSuffix(dest, taken)] = true
+			}
+		}
+	})
+}
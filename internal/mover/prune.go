@@ -0,0 +1,125 @@
+package mover
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/bagtoad/imgsort/internal/junk"
+	"github.com/bagtoad/imgsort/internal/manifest"
+)
+
+// PruneOptions controls PruneEmpty.
+type PruneOptions struct {
+	DryRun bool
+
+	// Categories are the active category names. When the directory has no
+	// manifest, only top-level folders with these names are pruned.
+	Categories []string
+
+	// AllEmpty prunes every empty folder, whether or not imgsort created it.
+	AllEmpty bool
+
+	// NoJunkFilter keeps folders holding only junk files such as .DS_Store.
+	// By default those files do not count as content and are deleted along
+	// with the folder.
+	NoJunkFilter bool
+}
+
+// PruneEmpty removes empty folders inside baseDir that imgsort created: the
+// folders listed in its manifest, or without one, top-level folders named
+// after an active category. Empty subfolders inside them are removed first,
+// so a chain like category/2023/03 goes bottom-up. Folders holding any file
+// or symlink are kept, and symlinks are never followed. baseDir itself is
+// never removed.
+//
+// It returns the removed folders in the order they were removed (or would
+// be, in a dry run). Folders that cannot be read or removed are kept and
+// reported in the returned error, without stopping the rest.
+func PruneEmpty(baseDir string, opts PruneOptions) ([]string, error) {
+	m, err := manifest.Load(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", baseDir, err)
+	}
+
+	p := &pruner{opts: opts}
+	var forgotten []string
+	for _, e := range entries {
+		if !e.IsDir() || e.Type()&os.ModeSymlink != 0 {
+			continue
+		}
+		name := e.Name()
+		eligible := opts.AllEmpty || m.IsManaged(name) || (m == nil && slices.Contains(opts.Categories, name))
+		if eligible && p.prune(filepath.Join(baseDir, name)) && m.IsManaged(name) {
+			forgotten = append(forgotten, name)
+		}
+	}
+
+	if !opts.DryRun && len(forgotten) > 0 {
+		if err := manifest.Forget(baseDir, forgotten...); err != nil {
+			p.errs = append(p.errs, err)
+		}
+	}
+	return p.removed, errors.Join(p.errs...)
+}
+
+// pruner carries the state of one PruneEmpty call.
+type pruner struct {
+	opts    PruneOptions
+	removed []string
+	errs    []error
+}
+
+// prune removes dir's empty subfolders and then dir itself if nothing else
+// is left in it. It reports whether dir was removed.
+func (p *pruner) prune(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("cannot read %s: %w", dir, err))
+		return false
+	}
+
+	empty := true
+	var junkFiles []string
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		switch {
+		case e.Type()&os.ModeSymlink != 0:
+			empty = false
+		case e.IsDir():
+			if !p.prune(path) {
+				empty = false
+			}
+		case !p.opts.NoJunkFilter && junk.Is(e.Name()):
+			junkFiles = append(junkFiles, path)
+		default:
+			empty = false
+		}
+	}
+	if !empty {
+		return false
+	}
+
+	if !p.opts.DryRun {
+		for _, f := range junkFiles {
+			if err := os.Remove(f); err != nil {
+				p.errs = append(p.errs, fmt.Errorf("cannot remove %s: %w", f, err))
+				return false
+			}
+		}
+		// os.Remove refuses non-empty directories, so a file created since
+		// the listing keeps the folder.
+		if err := os.Remove(dir); err != nil {
+			p.errs = append(p.errs, fmt.Errorf("cannot remove %s: %w", dir, err))
+			return false
+		}
+	}
+	p.removed = append(p.removed, dir)
+	return true
+}
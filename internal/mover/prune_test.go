@@ -0,0 +1,194 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/manifest"
+)
+
+// mkdirs creates each directory under base.
+func mkdirs(t *testing.T, base string, dirs ...string) {
+	t.Helper()
+	for _, d := range dirs {
+		if err := os.MkdirAll(filepath.Join(base, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// relPaths returns paths relative to base.
+func relPaths(t *testing.T, base string, paths []string) []string {
+	t.Helper()
+	var out []string
+	for _, p := range paths {
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, filepath.ToSlash(rel))
+	}
+	return out
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestPruneEmptyManaged(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "beach/2023/03", "beach/2024", "city", "food/empty", "mine")
+	writeFiles(t, dir, "food/pizza.jpg")
+	if err := manifest.Record(dir, "beach", "city", "food"); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := PruneEmpty(dir, PruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"beach/2023/03", "beach/2023", "beach/2024", "beach", "city", "food/empty"}
+	if got := relPaths(t, dir, removed); !slices.Equal(got, want) {
+		t.Errorf("removed %v, want %v", got, want)
+	}
+	for _, kept := range []string{"food/pizza.jpg", "mine"} {
+		if !exists(filepath.Join(dir, kept)) {
+			t.Errorf("%s should be kept", kept)
+		}
+	}
+	if !exists(dir) {
+		t.Error("the base directory must never be removed")
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.Managed, []string{"food"}) {
+		t.Errorf("removed folders should be forgotten, manifest has %v", m.Managed)
+	}
+}
+
+func TestPruneEmptyWithoutManifest(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "beach/2023", "holiday")
+
+	removed, err := PruneEmpty(dir, PruneOptions{Categories: []string{"beach", "city"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relPaths(t, dir, removed); !slices.Equal(got, []string{"beach/2023", "beach"}) {
+		t.Errorf("removed %v", got)
+	}
+	if !exists(filepath.Join(dir, "holiday")) {
+		t.Error("folders that are not categories should be kept")
+	}
+
+	removed, err = PruneEmpty(dir, PruneOptions{AllEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relPaths(t, dir, removed); !slices.Equal(got, []string{"holiday"}) {
+		t.Errorf("--all-empty removed %v", got)
+	}
+}
+
+func TestPruneEmptyDryRun(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "beach/2023/03")
+
+	removed, err := PruneEmpty(dir, PruneOptions{DryRun: true, Categories: []string{"beach"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 {
+		t.Errorf("expected 3 folders to be listed, got %v", removed)
+	}
+	if !exists(filepath.Join(dir, "beach", "2023", "03")) {
+		t.Error("a dry run must not remove anything")
+	}
+}
+
+func TestPruneEmptySymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	mkdirs(t, dir, "beach", "city")
+	if err := os.Symlink(outside, filepath.Join(dir, "beach", "link")); err != nil {
+		t.Fatal(err)
+	}
+	// A top-level symlink named like a category points at an empty folder.
+	if err := os.Symlink(outside, filepath.Join(dir, "food")); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := PruneEmpty(dir, PruneOptions{Categories: []string{"beach", "city", "food"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relPaths(t, dir, removed); !slices.Equal(got, []string{"city"}) {
+		t.Errorf("removed %v, want only city", got)
+	}
+	for _, kept := range []string{filepath.Join(dir, "beach", "link"), filepath.Join(dir, "food"), outside} {
+		if !exists(kept) {
+			t.Errorf("%s should be kept", kept)
+		}
+	}
+}
+
+func TestPruneEmptyJunk(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "beach/2023", "city")
+	writeFiles(t, dir, "beach/.DS_Store", "beach/2023/Thumbs.db", "beach/2023/._IMG_1.jpg", "city/.hidden")
+	opts := PruneOptions{Categories: []string{"beach", "city"}, NoJunkFilter: true}
+
+	removed, err := PruneEmpty(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("junk files should count as content with NoJunkFilter, removed %v", removed)
+	}
+
+	opts.NoJunkFilter = false
+	removed, err = PruneEmpty(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relPaths(t, dir, removed); !slices.Equal(got, []string{"beach/2023", "beach"}) {
+		t.Errorf("removed %v", got)
+	}
+	if !exists(filepath.Join(dir, "city", ".hidden")) {
+		t.Error("other hidden files should keep their folder")
+	}
+}
+
+func TestPruneEmptyUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	mkdirs(t, dir, "beach/locked", "city")
+	locked := filepath.Join(dir, "beach", "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("permissions are not enforced in this environment")
+	}
+
+	removed, err := PruneEmpty(dir, PruneOptions{Categories: []string{"beach", "city"}})
+	if err == nil {
+		t.Error("expected an error for the unreadable folder")
+	}
+	if got := relPaths(t, dir, removed); !slices.Equal(got, []string{"city"}) {
+		t.Errorf("removed %v, want only city", got)
+	}
+	if !exists(locked) {
+		t.Error("an unreadable folder must be kept")
+	}
+}
@@ -0,0 +1,99 @@
+package mover
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// SecondaryMode controls how an image's runner-up category is represented.
+type SecondaryMode string
+
+const (
+	// SecondaryNone records only the primary category (the default).
+	SecondaryNone SecondaryMode = ""
+	// SecondarySymlink places a relative symlink to the moved file in the
+	// secondary category folder.
+	SecondarySymlink SecondaryMode = "symlink"
+	// SecondarySidecar places a small JSON file pointing at the moved file in
+	// the secondary category folder, for filesystems without symlinks.
+	SecondarySidecar SecondaryMode = "sidecar"
+)
+
+// sidecarExt is appended to the image name for SecondarySidecar files.
+const sidecarExt = ".imgsort.json"
+
+// ParseSecondaryMode validates a secondary mode name. "none" and the empty
+// string both disable secondary assignments.
+func ParseSecondaryMode(s string) (SecondaryMode, error) {
+	switch s {
+	case "", "none":
+		return SecondaryNone, nil
+	case string(SecondarySymlink):
+		return SecondarySymlink, nil
+	case string(SecondarySidecar):
+		return SecondarySidecar, nil
+	}
+	return "", fmt.Errorf("unknown secondary mode %q (expected one of: none, symlink, sidecar)", s)
+}
+
+// sidecar is the JSON payload written for SecondarySidecar.
+type sidecar struct {
+	Primary    string  `json:"primary"`
+	Category   string  `json:"category"`
+	Confidence float32 `json:"confidence"`
+}
+
+// placeSecondary records item in secDir, its runner-up category's folder,
+// pointing at the file already moved to primaryPath. It returns the created
+// path.
+func placeSecondary(secDir string, item categorizer.Result, primaryPath string, mode SecondaryMode, dryRun bool,
+	names *destNames, mkdir func(string) error, j *journal) (string, error) {
+	name := filepath.Base(primaryPath)
+	if mode == SecondarySidecar {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + sidecarExt
+	}
+
+	linkPath, _, err := resolveConflict(primaryPath, filepath.Join(secDir, name), ConflictSuffix, names, nil)
+	if err != nil {
+		return "", err
+	}
+	if dryRun {
+		return linkPath, nil
+	}
+
+	if err := mkdir(secDir); err != nil {
+		return "", err
+	}
+
+	target, err := filepath.Rel(secDir, primaryPath)
+	if err != nil {
+		target = primaryPath
+	}
+
+	switch mode {
+	case SecondarySymlink:
+		if err := os.Symlink(target, linkPath); err != nil {
+			return "", fmt.Errorf("cannot link %s: %w", linkPath, err)
+		}
+		j.create(linkPath)
+	case SecondarySidecar:
+		data, err := json.MarshalIndent(sidecar{
+			Primary:    filepath.ToSlash(target),
+			Category:   item.RunnerUp,
+			Confidence: item.RunnerUpConfidence,
+		}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(linkPath, append(data, '\n'), 0644); err != nil {
+			return "", fmt.Errorf("cannot write %s: %w", linkPath, err)
+		}
+		j.create(linkPath)
+	}
+	return linkPath, nil
+}
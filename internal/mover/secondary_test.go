@@ -0,0 +1,100 @@
+package mover
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+func TestMoveFilesSecondarySymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "beach.jpg"), []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{{
+		Path: filepath.Join(dir, "beach.jpg"), Category: "beach", Confidence: 0.6,
+		RunnerUp: "ocean", RunnerUpConfidence: 0.3,
+	}}
+	moves, err := MoveFiles(dir, results, Options{Secondary: SecondarySymlink, SecondaryThreshold: 0.15})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(dir, "ocean", "beach.jpg")
+	if moves[0].Secondary != "ocean" || moves[0].SecondaryPath != link {
+		t.Fatalf("expected secondary link %s, got %+v", link, moves[0])
+	}
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != filepath.Join("..", "beach", "beach.jpg") {
+		t.Errorf("expected relative link target, got %s", target)
+	}
+	if data, err := os.ReadFile(link); err != nil || string(data) != "fake" {
+		t.Errorf("symlink should resolve to the moved file: %v", err)
+	}
+}
+
+func TestMoveFilesSecondarySidecar(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "beach.jpg"), []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{{
+		Path: filepath.Join(dir, "beach.jpg"), Category: "beach", Confidence: 0.6,
+		RunnerUp: "ocean", RunnerUpConfidence: 0.3,
+	}}
+	moves, err := MoveFiles(dir, results, Options{Secondary: SecondarySidecar})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "ocean", "beach"+sidecarExt)
+	if moves[0].SecondaryPath != path {
+		t.Fatalf("expected sidecar %s, got %s", path, moves[0].SecondaryPath)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sc sidecar
+	if err := json.Unmarshal(data, &sc); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Primary != "../beach/beach.jpg" || sc.Category != "ocean" {
+		t.Errorf("unexpected sidecar contents: %+v", sc)
+	}
+}
+
+func TestMoveFilesSecondaryBelowThreshold(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "beach.jpg"), []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []categorizer.Result{{
+		Path: filepath.Join(dir, "beach.jpg"), Category: "beach", Confidence: 0.6,
+		RunnerUp: "ocean", RunnerUpConfidence: 0.05,
+	}}
+	moves, err := MoveFiles(dir, results, Options{Secondary: SecondarySidecar, SecondaryThreshold: 0.15})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moves[0].Secondary != "" {
+		t.Errorf("secondary below threshold should be omitted, got %+v", moves[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ocean")); !os.IsNotExist(err) {
+		t.Error("secondary folder should not be created")
+	}
+}
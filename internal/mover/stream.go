@@ -0,0 +1,90 @@
+package mover
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// Stream moves files one result at a time, for runs that never hold every
+// result at once. Unlike a MoveFiles call per result, it keeps one set of
+// destination names for the whole run, so a dry run predicts the same
+// suffixes a real run would produce, and it records the category folders
+// in the manifest once, when it is closed. A dry run, or one with
+// ConflictOverwrite, remembers every destination it hands out; other runs
+// find earlier moves on disk, so their memory use stays flat.
+type Stream struct {
+	run     *run
+	folders map[string]bool
+
+	// dirs caches each category's folder, or "" if FolderSkipCategory
+	// leaves it alone.
+	dirs map[string]string
+}
+
+// NewStream returns a Stream that moves files into category folders within
+// baseDir. Options that need every result at once, MaxPerCategory,
+// MinCategorySize, and Transactional, are rejected.
+func NewStream(baseDir string, opts Options) (*Stream, error) {
+	switch {
+	case opts.MaxPerCategory > 0:
+		return nil, errors.New("cannot stream moves with MaxPerCategory, which needs every result at once")
+	case opts.MinCategorySize > 1:
+		return nil, errors.New("cannot stream moves with MinCategorySize, which needs every result at once")
+	case opts.Transactional:
+		return nil, errors.New("cannot stream moves with Transactional, which needs every result at once")
+	}
+	rn := newRun(baseDir, opts, nil)
+	rn.names.onlyDisk = !opts.DryRun && rn.strategy != ConflictOverwrite
+	return &Stream{
+		run:     rn,
+		folders: make(map[string]bool),
+		dirs:    make(map[string]string),
+	}, nil
+}
+
+// Move moves the file of one result, as MoveFiles would, and returns what
+// happened to it and its companions. A skipped result is moved only under
+// UnsortedMove; otherwise, like a vanished source under IgnoreVanished, it
+// has no MoveResult.
+func (s *Stream) Move(r categorizer.Result) ([]MoveResult, error) {
+	category := r.Category
+	if r.Skipped {
+		if s.run.opts.Unsorted != UnsortedMove {
+			return nil, nil
+		}
+		r.Skipped, r.Category = false, UnsortedDir
+		category = UnsortedDir
+	}
+
+	dir, ok := s.dirs[category]
+	if !ok {
+		folder, skip, err := categoryFolder(s.run.destDir, category, s.run.opts.OnFolderConflict)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			dir = filepath.Join(s.run.destDir, folder)
+		}
+		s.dirs[category] = dir
+	}
+	if dir == "" {
+		return []MoveResult{folderIsFile(category, r, s.run.opts)}, nil
+	}
+
+	mr, ok, err := s.run.moveOne(category, dir, r)
+	if err != nil || !ok {
+		return nil, err
+	}
+	addManaged(s.folders, s.run.destDir, mr)
+	return []MoveResult{mr}, nil
+}
+
+// Close records the folders that received files in the manifest of
+// baseDir, or of OutputDir if set, unless this is a dry run.
+func (s *Stream) Close() {
+	if !s.run.opts.DryRun {
+		recordFolders(s.run.destDir, s.folders)
+	}
+}
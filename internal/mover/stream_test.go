@@ -0,0 +1,93 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/manifest"
+)
+
+func TestStreamPredictsNames(t *testing.T) {
+	// Two photo.jpg files from different folders land in the same category.
+	dir := t.TempDir()
+	var results []categorizer.Result
+	for _, sub := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, sub, "photo.jpg")
+		if err := os.WriteFile(path, []byte(sub), 0644); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, categorizer.Result{Path: path, Category: "beach", Confidence: 0.8})
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		s, err := NewStream(dir, Options{DryRun: dryRun})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dests []string
+		for _, r := range results {
+			moves, err := s.Move(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dests = append(dests, filepath.Base(moves[0].DestPath))
+		}
+		if dests[0] != "photo.jpg" || dests[1] != "photo_1.jpg" {
+			t.Errorf("dry run %v: expected photo.jpg and photo_1.jpg, got %v", dryRun, dests)
+		}
+
+		if m, _ := manifest.Load(dir); m != nil {
+			t.Errorf("dry run %v: the manifest should not be written before Close", dryRun)
+		}
+		s.Close()
+		m, _ := manifest.Load(dir)
+		if dryRun && m != nil {
+			t.Error("a dry run should not write the manifest")
+		}
+		if !dryRun && (m == nil || !m.IsManaged("beach")) {
+			t.Errorf("expected beach to be managed after Close, got %+v", m)
+		}
+	}
+}
+
+func TestStreamSkipped(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blurry.jpg")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := categorizer.Result{Path: path, Skipped: true, SkipReason: categorizer.SkipThreshold}
+
+	s, err := NewStream(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moves, err := s.Move(r); err != nil || len(moves) != 0 {
+		t.Errorf("a skipped image should be left alone, got %+v, %v", moves, err)
+	}
+
+	s, err = NewStream(dir, Options{Unsorted: UnsortedMove})
+	if err != nil {
+		t.Fatal(err)
+	}
+	moves, err := s.Move(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].DestPath != filepath.Join(dir, UnsortedDir, "blurry.jpg") {
+		t.Errorf("expected the image moved to %s, got %+v", UnsortedDir, moves)
+	}
+}
+
+func TestStreamRejectsWholeRunOptions(t *testing.T) {
+	for _, opts := range []Options{{MaxPerCategory: 10}, {MinCategorySize: 3}, {Transactional: true}} {
+		if _, err := NewStream(t.TempDir(), opts); err == nil {
+			t.Errorf("expected %+v to be rejected", opts)
+		}
+	}
+}
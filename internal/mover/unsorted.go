@@ -0,0 +1,54 @@
+package mover
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// UnsortedPolicy controls what happens to images that were not categorized.
+type UnsortedPolicy string
+
+const (
+	// UnsortedLeave leaves uncategorized images where they are (the default).
+	UnsortedLeave UnsortedPolicy = "leave"
+	// UnsortedMove moves uncategorized images into UnsortedDir, so every
+	// image ends up in some folder.
+	UnsortedMove UnsortedPolicy = "move"
+)
+
+// UnsortedDir is the folder UnsortedMove places uncategorized images in.
+const UnsortedDir = "unsorted"
+
+// ParseUnsortedPolicy validates an unsorted policy name. The empty string
+// means UnsortedLeave.
+func ParseUnsortedPolicy(s string) (UnsortedPolicy, error) {
+	switch s {
+	case "", string(UnsortedLeave):
+		return UnsortedLeave, nil
+	case string(UnsortedMove):
+		return UnsortedMove, nil
+	}
+	return "", fmt.Errorf("unknown unsorted policy %q (expected leave or move)", s)
+}
+
+// withUnsorted returns groups with the skipped results added under
+// UnsortedDir, merged with any real category of that name.
+func withUnsorted(groups []categorizer.Group, results []categorizer.Result) []categorizer.Group {
+	_, skipped := categorizer.Partition(results)
+	if len(skipped) == 0 {
+		return groups
+	}
+	i := slices.IndexFunc(groups, func(g categorizer.Group) bool { return g.Category == UnsortedDir })
+	if i < 0 {
+		groups = append(groups, categorizer.Group{Category: UnsortedDir})
+		i = len(groups) - 1
+	}
+	for _, r := range skipped {
+		r.Skipped = false
+		r.Category = UnsortedDir
+		groups[i].Results = append(groups[i].Results, r)
+	}
+	return groups
+}
@@ -0,0 +1,64 @@
+package mover
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+func TestParseUnsortedPolicy(t *testing.T) {
+	for in, want := range map[string]UnsortedPolicy{"": UnsortedLeave, "leave": UnsortedLeave, "move": UnsortedMove} {
+		got, err := ParseUnsortedPolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseUnsortedPolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseUnsortedPolicy("copy"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestMoveFilesUnsorted(t *testing.T) {
+	tests := []struct {
+		policy     UnsortedPolicy
+		wantMoved  int
+		wantInRoot bool
+	}{
+		{UnsortedLeave, 1, true},
+		{UnsortedMove, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range []string{"beach.jpg", "blurry.jpg"} {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte("fake"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			results := []categorizer.Result{
+				{Path: filepath.Join(dir, "beach.jpg"), Category: "beach", Confidence: 0.8},
+				{Path: filepath.Join(dir, "blurry.jpg"), Skipped: true},
+			}
+
+			moves, err := MoveFiles(dir, results, Options{Unsorted: tt.policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(moves) != tt.wantMoved {
+				t.Fatalf("expected %d moves, got %+v", tt.wantMoved, moves)
+			}
+
+			_, err = os.Stat(filepath.Join(dir, "blurry.jpg"))
+			if inRoot := err == nil; inRoot != tt.wantInRoot {
+				t.Errorf("blurry.jpg left in place = %v, want %v", inRoot, tt.wantInRoot)
+			}
+			if !tt.wantInRoot {
+				if _, err := os.Stat(filepath.Join(dir, UnsortedDir, "blurry.jpg")); err != nil {
+					t.Errorf("expected blurry.jpg in %s/: %v", UnsortedDir, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package mover
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNotWritable is returned by CheckWritable when files cannot be created in
+// the directory being sorted.
+var ErrNotWritable = errors.New("directory is not writable")
+
+// CheckWritable verifies that category folders can be created in dir by
+// creating and removing a temporary entry. Checking permission bits alone is
+// not enough: read-only mounts and ACLs only show up on an actual write.
+func CheckWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".imgsort-probe-*")
+	if err != nil {
+		return fmt.Errorf("%w: %s (%v); imgsort creates category folders in the directory it sorts, "+
+			"so use --copy --output-dir to sort copies into a writable location, or --dry-run to preview", ErrNotWritable, dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
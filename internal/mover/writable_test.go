@@ -0,0 +1,41 @@
+package mover
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := CheckWritable(dir); err != nil {
+		t.Fatalf("temp dir should be writable: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("probe should be removed, found %d entries", len(entries))
+	}
+}
+
+func TestCheckWritableReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	// Root and some filesystems ignore permission bits.
+	if f, err := os.CreateTemp(dir, "check"); err == nil {
+		f.Close()
+		os.Remove(f.Name())
+		t.Skip("permissions are not enforced in this environment")
+	}
+
+	err := CheckWritable(dir)
+	if !errors.Is(err, ErrNotWritable) {
+		t.Errorf("expected ErrNotWritable, got %v", err)
+	}
+}
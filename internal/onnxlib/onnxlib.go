@@ -1,31 +1,190 @@
-// Package onnxlib extracts the embedded ONNX Runtime shared library to a
-// temporary directory and returns its path. This allows the binary to be
-// fully self-contained with no external runtime dependencies.
+// Package onnxlib extracts the embedded ONNX Runtime shared library to disk
+// and returns its path. This allows the binary to be fully self-contained
+// with no external runtime dependencies.
 package onnxlib
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
 )
 
-// Extract writes the embedded ONNX Runtime shared library to a temporary
-// directory and returns its full path.
+// LibDirEnv names a directory to extract the library into when the usual
+// location does not allow loading it, typically because it is mounted
+// noexec.
+const LibDirEnv = "IMGSORT_LIB_DIR"
+
+// ErrNoLibrary is returned when the binary has no embedded library for
+// this platform.
+var ErrNoLibrary = errors.New("no embedded ONNX Runtime library for this platform")
+
+// Embedded reports whether the binary carries an ONNX Runtime library.
+func Embedded() bool {
+	return len(libraryData) > 0
+}
+
+// Extract writes the embedded ONNX Runtime shared library to the user cache
+// directory, or a temporary directory if there is none, and returns its
+// full path. The cached copy is reused by later runs.
 func Extract() (string, error) {
 	if len(libraryData) == 0 {
-		return "", fmt.Errorf("no embedded ONNX Runtime library for this platform")
+		return "", ErrNoLibrary
+	}
+	if dir, err := cacheDir(); err == nil {
+		if path, err := extractTo(dir, libraryData, libraryName); err == nil {
+			return path, nil
+		}
+	}
+	return extractTemp(libraryData, libraryName)
+}
+
+// Load extracts the library and calls load with its path. If load fails
+// because the extraction directory forbids executing code, the library is
+// extracted once more to LibDirEnv, or ~/.imgsort/lib, and loaded from
+// there. It returns the path that loaded.
+func Load(load func(path string) error) (string, error) {
+	if len(libraryData) == 0 {
+		return "", ErrNoLibrary
+	}
+	path, err := Extract()
+	if err != nil {
+		return "", err
+	}
+	retry, err := retryDir()
+	if err != nil {
+		retry = ""
+	}
+	return loadWith(path, retry, libraryData, libraryName, load)
+}
+
+// loadWith loads path, falling back to a copy of data extracted to retry.
+func loadWith(path, retry string, data []byte, name string, load func(string) error) (string, error) {
+	err := load(path)
+	if err == nil {
+		return path, nil
+	}
+	if !IsNoExec(err) {
+		return "", err
+	}
+	if retry == "" || retry == filepath.Dir(filepath.Dir(path)) {
+		return "", noExecError(path, "", err)
+	}
+	retryPath, xerr := extractTo(retry, data, name)
+	if xerr != nil {
+		return "", noExecError(path, "", fmt.Errorf("%v; cannot extract to %s: %w", err, retry, xerr))
+	}
+	if err := load(retryPath); err != nil {
+		if IsNoExec(err) {
+			return "", noExecError(path, retryPath, err)
+		}
+		return "", err
+	}
+	return retryPath, nil
+}
+
+// IsNoExec reports whether err looks like the dynamic loader refusing a
+// library because its file system is mounted noexec. dlopen reports this
+// only as text, so the usual messages are matched as well as errnos.
+func IsNoExec(err error) bool {
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.ENOEXEC) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"operation not permitted", "failed to map segment", "exec format error"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// noExecError explains a library that could not be loaded from where it
+// was extracted, which users otherwise only see as a dlopen error.
+func noExecError(path, retryPath string, err error) error {
+	where := filepath.Dir(path)
+	if retryPath != "" {
+		where += " or " + filepath.Dir(retryPath)
+	}
+	return fmt.Errorf("cannot load the embedded ONNX Runtime library from %s: the file system is probably mounted noexec, "+
+		"which forbids loading shared libraries from it. Set %s to a writable directory that allows executing files, "+
+		"or point --onnxruntime at an installed library (%w)", where, LibDirEnv, err)
+}
+
+// cacheDir returns the directory for the cached library.
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "imgsort", "onnxruntime"), nil
+}
+
+// retryDir returns where to extract the library when the first location
+// cannot load it: LibDirEnv if set, otherwise ~/.imgsort/lib.
+func retryDir() (string, error) {
+	if dir := os.Getenv(LibDirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := appdir.Dir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(dir, "lib"), nil
+}
 
+// extractTo writes data as name in a subdirectory of dir named after its
+// hash, so different library builds do not overwrite each other, and
+// reuses an existing copy of the right size.
+func extractTo(dir string, data []byte, name string) (string, error) {
+	sum := sha256.Sum256(data)
+	libDir := filepath.Join(dir, hex.EncodeToString(sum[:6]))
+	libPath := filepath.Join(libDir, name)
+	if info, err := os.Stat(libPath); err == nil && info.Size() == int64(len(data)) {
+		return libPath, nil
+	}
+
+	if err := os.MkdirAll(libDir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create library directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(libDir, ".lib-*")
+	if err != nil {
+		return "", fmt.Errorf("cannot write library: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0755)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), libPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("cannot write library: %w", err)
+	}
+	return libPath, nil
+}
+
+// extractTemp writes data as name in a new temporary directory.
+func extractTemp(data []byte, name string) (string, error) {
 	dir, err := os.MkdirTemp("", "imgsort-onnxrt-*")
 	if err != nil {
 		return "", fmt.Errorf("cannot create temp dir: %w", err)
 	}
 
-	libPath := filepath.Join(dir, libraryName)
-	if err := os.WriteFile(libPath, libraryData, 0755); err != nil {
+	libPath := filepath.Join(dir, name)
+	if err := os.WriteFile(libPath, data, 0755); err != nil {
 		os.RemoveAll(dir)
 		return "", fmt.Errorf("cannot write library: %w", err)
 	}
-
 	return libPath, nil
 }
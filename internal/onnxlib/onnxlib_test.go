@@ -0,0 +1,128 @@
+package onnxlib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// noexecLoader rejects libraries under dir the way dlopen does on a noexec
+// mount, and records every path it was asked to load.
+type noexecLoader struct {
+	dir   string
+	tried []string
+}
+
+func (l *noexecLoader) load(path string) error {
+	l.tried = append(l.tried, path)
+	if strings.HasPrefix(path, l.dir+string(filepath.Separator)) {
+		return fmt.Errorf("cannot initialize ONNX Runtime from %s: Error loading ONNX shared library %q: "+
+			"failed to map segment from shared object: Operation not permitted", path, path)
+	}
+	return nil
+}
+
+func TestLoadRetriesOutsideNoexecDir(t *testing.T) {
+	data := []byte("fake library")
+	noexec, retry := t.TempDir(), t.TempDir()
+	path, err := extractTo(noexec, data, "libonnxruntime.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loader := &noexecLoader{dir: noexec}
+	got, err := loadWith(path, retry, data, "libonnxruntime.so", loader.load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, retry) || len(loader.tried) != 2 {
+		t.Errorf("expected a retry under %s, loaded %s after %v", retry, got, loader.tried)
+	}
+	if b, err := os.ReadFile(got); err != nil || string(b) != string(data) {
+		t.Errorf("retry copy has wrong contents: %q, %v", b, err)
+	}
+}
+
+func TestLoadExplainsNoexec(t *testing.T) {
+	data := []byte("fake library")
+	noexec := t.TempDir()
+	path, err := extractTo(noexec, data, "libonnxruntime.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry directory is on the same noexec mount.
+	loader := &noexecLoader{dir: noexec}
+	_, err = loadWith(path, filepath.Join(noexec, "other"), data, "libonnxruntime.so", loader.load)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"noexec", LibDirEnv, "--onnxruntime", "Operation not permitted"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q: %v", want, err)
+		}
+	}
+	if len(loader.tried) != 2 {
+		t.Errorf("expected exactly one retry, tried %v", loader.tried)
+	}
+}
+
+func TestLoadOtherErrorsDoNotRetry(t *testing.T) {
+	data := []byte("fake library")
+	path, err := extractTo(t.TempDir(), data, "libonnxruntime.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	wrong := errors.New("incompatible ONNX Runtime: version 1.16.3 is older than the required 1.22.0")
+	_, err = loadWith(path, t.TempDir(), data, "libonnxruntime.so", func(string) error {
+		calls++
+		return wrong
+	})
+	if !errors.Is(err, wrong) || calls != 1 {
+		t.Errorf("expected the load error without a retry, got %v after %d calls", err, calls)
+	}
+}
+
+func TestExtractToReusesCopy(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("fake library")
+	first, err := extractTo(dir, data, "libonnxruntime.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("extracted library should be executable, mode %v", info.Mode())
+	}
+	second, err := extractTo(dir, data, "libonnxruntime.so")
+	if err != nil || second != first {
+		t.Errorf("expected the same path again, got %s, %v", second, err)
+	}
+	other, err := extractTo(dir, []byte("another build"), "libonnxruntime.so")
+	if err != nil || other == first {
+		t.Errorf("a different library should get its own directory, got %s, %v", other, err)
+	}
+}
+
+func TestIsNoExec(t *testing.T) {
+	for _, err := range []error{
+		syscall.EPERM,
+		fmt.Errorf("dlopen: %w", syscall.ENOEXEC),
+		errors.New("libonnxruntime.so: failed to map segment from shared object"),
+	} {
+		if !IsNoExec(err) {
+			t.Errorf("IsNoExec(%v) = false", err)
+		}
+	}
+	if IsNoExec(errors.New("libonnxruntime.so: cannot open shared object file: No such file or directory")) {
+		t.Error("a missing library is not a noexec failure")
+	}
+}
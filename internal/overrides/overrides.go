@@ -0,0 +1,102 @@
+// Package overrides reads the --overrides file, which assigns files the
+// model keeps getting wrong to a category by name or glob, so they are
+// sorted without asking the model.
+package overrides
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Rule assigns files whose base name matches Pattern to Category. Line is
+// where it was read, for error messages.
+type Rule struct {
+	Pattern  string
+	Category string
+	Line     int
+}
+
+// Overrides are the rules of an overrides file, in file order.
+type Overrides []Rule
+
+// Load reads the overrides file at path.
+func Load(path string) (Overrides, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open overrides: %w", err)
+	}
+	defer f.Close()
+
+	o, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return o, nil
+}
+
+// Parse reads rules written one "pattern = category" per line, with blank
+// lines and "#" comments ignored. A pattern is a file name, or a glob in
+// the syntax of filepath.Match such as "scan_*.png", matched against the
+// base name of each image. The line is split at its last "=", so a pattern
+// may contain one.
+func Parse(r io.Reader) (Overrides, error) {
+	var o Overrides
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected \"name = category\", got %q", n, line)
+		}
+		pattern := strings.TrimSpace(line[:i])
+		category := strings.TrimSpace(line[i+1:])
+		if pattern == "" || category == "" {
+			return nil, fmt.Errorf("line %d: expected \"name = category\", got %q", n, line)
+		}
+		if strings.ContainsAny(pattern, `/\`) {
+			return nil, fmt.Errorf("line %d: %q must be a file name or glob, not a path", n, pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("line %d: bad pattern %q: %w", n, pattern, err)
+		}
+		o = append(o, Rule{Pattern: pattern, Category: category, Line: n})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Lookup returns the category of the first rule matching path's base name.
+func (o Overrides) Lookup(path string) (string, bool) {
+	name := filepath.Base(path)
+	for _, r := range o {
+		if ok, _ := filepath.Match(r.Pattern, name); ok {
+			return r.Category, true
+		}
+	}
+	return "", false
+}
+
+// Check returns an error naming the rules whose category is not in cats.
+func (o Overrides) Check(cats []string) error {
+	var unknown []string
+	for _, r := range o {
+		if !slices.Contains(cats, r.Category) {
+			unknown = append(unknown, fmt.Sprintf("line %d: %q", r.Line, r.Category))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("overrides name categories that are not in use (%s); add them to the categories or fix the overrides",
+			strings.Join(unknown, ", "))
+	}
+	return nil
+}
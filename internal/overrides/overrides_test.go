@@ -0,0 +1,82 @@
+package overrides
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	o, err := Parse(strings.NewReader(`# hand-sorted
+IMG_4410.jpg = documents
+
+scan_*.png = documents
+IMG_44?0.jpg = beach
+a=b.jpg = city
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o) != 4 {
+		t.Fatalf("expected 4 rules, got %+v", o)
+	}
+	for _, tc := range []struct {
+		path     string
+		category string
+		ok       bool
+	}{
+		{"/photos/IMG_4410.jpg", "documents", true}, // first match wins
+		{"/photos/IMG_4420.jpg", "beach", true},
+		{"/photos/sub/scan_001.png", "documents", true},
+		{"/photos/scan_001.jpg", "", false},
+		{"/photos/img_4410.jpg", "", false},
+		{"/photos/a=b.jpg", "city", true},
+	} {
+		category, ok := o.Lookup(tc.path)
+		if category != tc.category || ok != tc.ok {
+			t.Errorf("Lookup(%s) = %q, %v; want %q, %v", tc.path, category, ok, tc.category, tc.ok)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"IMG_1.jpg",
+		"IMG_1.jpg =",
+		"= documents",
+		"sub/IMG_1.jpg = documents",
+		"[a.jpg = documents",
+	} {
+		if _, err := Parse(strings.NewReader("ok.jpg = beach\n" + in + "\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("%q: expected an error on line 2, got %v", in, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	o := Overrides{
+		{Pattern: "a.jpg", Category: "beach", Line: 1},
+		{Pattern: "b.jpg", Category: "docs", Line: 3},
+	}
+	if err := o.Check([]string{"beach", "documents"}); err == nil || !strings.Contains(err.Error(), `line 3: "docs"`) {
+		t.Errorf("expected the unknown category named, got %v", err)
+	}
+	if err := o.Check([]string{"beach", "docs"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.txt")
+	if _, err := Load(path); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if err := os.WriteFile(path, []byte("IMG_1.jpg = beach\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o, err := Load(path)
+	if err != nil || len(o) != 1 || o[0].Category != "beach" {
+		t.Errorf("Load = %+v, %v", o, err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package palette classifies images by dominant color using simple pixel
+// statistics. It needs no model download or ONNX Runtime and serves as a
+// lightweight alternative to CLIP.
+package palette
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// ModelID identifies color scores in score files, so they are never mixed
+// with CLIP scores.
+const ModelID = "imgsort/dominant-color"
+
+// Monochrome collects grayscale, near-black, and near-white images.
+const Monochrome = "monochrome"
+
+// Categories are the color folders images can be sorted into.
+var Categories = []string{"red", "orange", "yellow", "green", "blue", "purple", "pink", Monochrome}
+
+// sampleSize is the edge length images are resized to before counting
+// pixels; dominant color does not need full resolution.
+const sampleSize = 64
+
+// Pixels with less saturation or brightness than these count as monochrome,
+// since their hue is mostly noise.
+const (
+	minSaturation = 0.25
+	minValue      = 0.15
+)
+
+// hueBuckets maps the upper bound of each hue range (in degrees) to a color.
+// Hues at or above the last bound wrap around to red.
+var hueBuckets = []struct {
+	max  float64
+	name string
+}{
+	{15, "red"},
+	{45, "orange"},
+	{70, "yellow"},
+	{170, "green"},
+	{260, "blue"},
+	{290, "purple"},
+	{345, "pink"},
+}
+
+// Classifier scores images by the share of pixels falling into each color.
+// It implements categorizer.Classifier.
+type Classifier struct {
+	// Load, if set, returns the image to score for a path, such as a cached
+	// thumbnail, in place of decoding the file.
+	Load func(path string) (image.Image, error)
+}
+
+// ClassifyWithOptions returns, for each requested color, the fraction of
+// pixels of that color among pixels matching any requested color. No
+// baseline is scored; opts is accepted for interface compatibility.
+func (c Classifier) ClassifyWithOptions(imagePath string, categories []string, _ model.ClassifyOptions) (map[string]float32, error) {
+	img, err := c.load(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float32, len(categories))
+	for _, cat := range categories {
+		scores[cat] = 0
+	}
+
+	total := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			name := ColorName(float64(r)/0xffff, float64(g)/0xffff, float64(bl)/0xffff)
+			if _, ok := scores[name]; ok {
+				scores[name]++
+				total++
+			}
+		}
+	}
+
+	if total > 0 {
+		for cat := range scores {
+			scores[cat] /= float32(total)
+		}
+	}
+	return scores, nil
+}
+
+// load returns the image at path scaled down to sampleSize.
+func (c Classifier) load(path string) (image.Image, error) {
+	if c.Load == nil {
+		return model.LoadImage(path, sampleSize)
+	}
+	img, err := c.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return model.FitImage(img, sampleSize), nil
+}
+
+// ColorName returns the color bucket for an RGB pixel with components in [0, 1].
+func ColorName(r, g, b float64) string {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	if max < minValue || max == 0 || (max-min)/max < minSaturation {
+		return Monochrome
+	}
+
+	var hue float64
+	d := max - min
+	switch max {
+	case r:
+		hue = math.Mod((g-b)/d, 6)
+	case g:
+		hue = (b-r)/d + 2
+	default:
+		hue = (r-g)/d + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+
+	for _, bucket := range hueBuckets {
+		if hue < bucket.max {
+			return bucket.name
+		}
+	}
+	return "red"
+}
+
+// Resolve validates a user-supplied subset of color names. An empty list
+// selects every color.
+func Resolve(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return Categories, nil
+	}
+	known := make(map[string]bool, len(Categories))
+	for _, c := range Categories {
+		known[c] = true
+	}
+	for _, n := range names {
+		if !known[n] {
+			return nil, fmt.Errorf("unknown color %q (expected some of: %v)", n, Categories)
+		}
+	}
+	return names, nil
+}
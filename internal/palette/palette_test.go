@@ -0,0 +1,116 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// dominant returns the highest-scoring color for the image at path.
+func dominant(t *testing.T, path string) string {
+	t.Helper()
+	scores, err := Classifier{}.ClassifyWithOptions(path, Categories, model.ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	best, bestScore := "", float32(-1)
+	for cat, s := range scores {
+		if s > bestScore {
+			best, bestScore = cat, s
+		}
+	}
+	return best
+}
+
+func TestClassifyTestdata(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"red_object.jpg", "red"},
+		{"nature.jpg", "green"},
+		{"dark_scene.png", Monochrome},
+		{"document.png", Monochrome},
+	}
+	for _, tt := range tests {
+		if got := dominant(t, filepath.Join("..", "..", "testdata", tt.file)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.file, tt.want, got)
+		}
+	}
+}
+
+func TestClassifySolidColors(t *testing.T) {
+	tests := []struct {
+		c    color.RGBA
+		want string
+	}{
+		{color.RGBA{30, 60, 220, 255}, "blue"},
+		{color.RGBA{240, 220, 30, 255}, "yellow"},
+		{color.RGBA{128, 128, 128, 255}, Monochrome},
+	}
+	for _, tt := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				img.Set(x, y, tt.c)
+			}
+		}
+		path := filepath.Join(t.TempDir(), "solid.png")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		if got := dominant(t, path); got != tt.want {
+			t.Errorf("%v: expected %s, got %s", tt.c, tt.want, got)
+		}
+	}
+}
+
+func TestClassifySubsetNormalizes(t *testing.T) {
+	path := filepath.Join("..", "..", "testdata", "red_object.jpg")
+	scores, err := Classifier{}.ClassifyWithOptions(path, []string{"red", "blue"}, model.ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 || scores["red"] < 0.99 {
+		t.Errorf("expected red to take the whole share, got %v", scores)
+	}
+}
+
+func TestClassifyLoad(t *testing.T) {
+	// A loaded thumbnail is scored in place of the file, which need not
+	// exist.
+	img := image.NewRGBA(image.Rect(0, 0, 160, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 160; x++ {
+			img.Set(x, y, color.RGBA{30, 60, 220, 255})
+		}
+	}
+	c := Classifier{Load: func(string) (image.Image, error) { return img, nil }}
+	scores, err := c.ClassifyWithOptions("missing.jpg", []string{"red", "blue"}, model.ClassifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores["blue"] != 1 {
+		t.Errorf("expected the loaded image to be scored all blue, got %v", scores)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if cats, err := Resolve(nil); err != nil || len(cats) != len(Categories) {
+		t.Errorf("empty list should select all colors, got %v, %v", cats, err)
+	}
+	if _, err := Resolve([]string{"red", "beach"}); err == nil {
+		t.Error("expected error for unknown color")
+	}
+}
@@ -0,0 +1,119 @@
+// Package pins reads and writes the per-directory file of pinned
+// assignments: images whose category a person has decided, which are sorted
+// into that category without asking the model.
+package pins
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the pins file looked up inside the directory being sorted.
+const FileName = ".imgsort-pins"
+
+// header starts a pins file created by Append.
+const header = "# Pinned categories, one \"path = category\" per line. Paths are\n" +
+	"# relative to this directory; pinned images skip classification.\n"
+
+// Pins maps an image path, relative to the sorted directory and using
+// forward slashes, to the category it is pinned to.
+type Pins map[string]string
+
+// Load reads FileName from dir. It returns nil without error when the
+// directory has no pins file.
+func Load(dir string) (Pins, error) {
+	path := filepath.Join(dir, FileName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open pins: %w", err)
+	}
+	defer f.Close()
+
+	p, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse reads pins written one "path = category" per line, with blank lines
+// and "#" comments ignored. The line is split at its last "=", so a file
+// name may contain one. When a path is pinned twice the later line wins,
+// which lets Append correct an earlier pin.
+func Parse(r io.Reader) (Pins, error) {
+	p := make(Pins)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected \"path = category\", got %q", n, line)
+		}
+		path := filepath.ToSlash(filepath.Clean(strings.TrimSpace(line[:i])))
+		category := strings.TrimSpace(line[i+1:])
+		if path == "." || category == "" {
+			return nil, fmt.Errorf("line %d: expected \"path = category\", got %q", n, line)
+		}
+		p[path] = category
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Lookup returns the category that imgPath, an image inside dir, is pinned
+// to.
+func (p Pins) Lookup(dir, imgPath string) (string, bool) {
+	if len(p) == 0 {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, imgPath)
+	if err != nil {
+		return "", false
+	}
+	category, ok := p[filepath.ToSlash(rel)]
+	return category, ok
+}
+
+// Append pins imgPath, an image inside dir, to category by adding a line to
+// dir's pins file, creating it if needed. A path already pinned is
+// overridden, since the last line for a path wins.
+func Append(dir, imgPath, category string) error {
+	category = strings.TrimSpace(category)
+	if category == "" || strings.ContainsAny(category, "=\n") {
+		return fmt.Errorf("invalid category %q", category)
+	}
+	rel, err := filepath.Rel(dir, imgPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+		return fmt.Errorf("%s is not inside %s", imgPath, dir)
+	}
+	if strings.Contains(rel, "\n") {
+		return fmt.Errorf("cannot pin %q: the path contains a newline", rel)
+	}
+
+	path := filepath.Join(dir, FileName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open pins: %w", err)
+	}
+	line := fmt.Sprintf("%s = %s\n", filepath.ToSlash(rel), category)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		line = header + line
+	}
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write pins: %w", err)
+	}
+	return f.Close()
+}
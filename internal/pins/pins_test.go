@@ -0,0 +1,71 @@
+package pins
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse(strings.NewReader(`# corrections
+IMG_0042.jpg = receipts
+trips/a=b.jpg = city street
+./IMG_0042.jpg = documents
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Pins{"IMG_0042.jpg": "documents", "trips/a=b.jpg": "city street"}
+	if len(p) != len(want) {
+		t.Fatalf("got %v, want %v", p, want)
+	}
+	for path, cat := range want {
+		if p[path] != cat {
+			t.Errorf("%s: got %q, want %q", path, p[path], cat)
+		}
+	}
+
+	for _, bad := range []string{"IMG_0042.jpg", "IMG_0042.jpg =", "= receipts"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("%q: expected a line 1 error, got %v", bad, err)
+		}
+	}
+}
+
+func TestAppendAndLookup(t *testing.T) {
+	dir := t.TempDir()
+	if p, err := Load(dir); err != nil || p != nil {
+		t.Fatalf("expected no pins without a file, got %v, %v", p, err)
+	}
+
+	img := filepath.Join(dir, "trips", "IMG_1.jpg")
+	if err := Append(dir, img, "beach"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(dir, img, "ocean"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(dir, filepath.Join(filepath.Dir(dir), "elsewhere.jpg"), "beach"); err == nil {
+		t.Error("expected an image outside the directory to be rejected")
+	}
+
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cat, ok := p.Lookup(dir, img); !ok || cat != "ocean" {
+		t.Errorf("expected the later pin to win, got %q, %v", cat, ok)
+	}
+	if _, ok := p.Lookup(dir, filepath.Join(dir, "IMG_1.jpg")); ok {
+		t.Error("expected a file with the same name elsewhere not to be pinned")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "# Pinned") != 1 {
+		t.Errorf("expected one header, got:\n%s", data)
+	}
+}
@@ -0,0 +1,146 @@
+// Package pipeline sorts a directory while it is being scanned: each image
+// is classified as soon as the scan finds it, then decided and moved, so
+// memory use does not grow with the number of images. It runs both
+// "imgsort --stream" and sorter.SortStream.
+package pipeline
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// Options configures Run.
+type Options struct {
+	// Scan configures the scan. NoImagePaths is always set.
+	Scan scanner.Options
+
+	Categories []string
+	Classify   model.ClassifyOptions
+
+	// Workers is how many images are classified at once. With one or less,
+	// the scan, the model, and every callback run in Run's goroutine.
+	Workers int
+
+	// Classifier returns the classifier. It is called when the first image
+	// needs the model, so a run that finds none never loads it.
+	Classifier func() (categorizer.Classifier, error)
+
+	// Route, if set, is called with each image the scan finds. It returns
+	// scores with Override or Pinned set for an image sorted by hand, ok
+	// false for one to classify, or ErrSkip to leave the image out; any
+	// other error ends the run. With more than one worker it is called on
+	// another goroutine.
+	Route func(path string) (is categorizer.ImageScores, ok bool, err error)
+
+	// Decide turns an image's scores into a result. An error ends the run.
+	Decide func(categorizer.ImageScores) (categorizer.Result, error)
+
+	// Move configures the mover.Stream every result is moved with.
+	Move mover.Options
+
+	// Sorted, if set, is called with each image's scores and result, and
+	// the moves made for it or the error that stopped them. An error it
+	// returns ends the run.
+	Sorted func(is categorizer.ImageScores, r categorizer.Result, moves []mover.MoveResult, err error) error
+}
+
+// ErrSkip is returned by Options.Route to leave an image out of the run.
+var ErrSkip = errors.New("image left out")
+
+// errStopped ends the scan once Run has stopped reading images.
+var errStopped = errors.New("pipeline stopped")
+
+// Run sorts the images in dir and returns the scan's result and error. An
+// error from a callback, or from loading the classifier, ends the run and
+// is returned as is. The category folders that received files are recorded
+// in the manifest however the run ends.
+func Run(dir string, opts Options) (*scanner.Result, error) {
+	moves, err := mover.NewStream(dir, opts.Move)
+	if err != nil {
+		return nil, err
+	}
+	defer moves.Close()
+
+	clip := &lazyClassifier{load: opts.Classifier}
+	var scanRes *scanner.Result
+	var scanErr error
+	items := func(yield func(categorizer.ImageScores) bool) {
+		scanOpts := opts.Scan
+		scanOpts.NoImagePaths = true
+		scanRes, scanErr = scanner.Stream(dir, scanOpts, func(e scanner.Entry) error {
+			if !e.Image {
+				return nil
+			}
+			is := categorizer.ImageScores{Path: e.Path}
+			if opts.Route != nil {
+				routed, ok, err := opts.Route(e.Path)
+				switch {
+				case errors.Is(err, ErrSkip):
+					return nil
+				case err != nil:
+					return err
+				case ok:
+					is = routed
+				}
+			}
+			if !yield(is) {
+				return errStopped
+			}
+			return nil
+		})
+	}
+
+	for is := range categorizer.ClassifyStreamScores(clip, items, opts.Categories, opts.Classify, opts.Workers) {
+		if err := clip.loadErr(); err != nil {
+			return nil, err
+		}
+		r, err := opts.Decide(is)
+		if err != nil {
+			return nil, err
+		}
+		mr, err := moves.Move(r)
+		if opts.Sorted != nil {
+			err = opts.Sorted(is, r, mr, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scanRes, scanErr
+}
+
+// lazyClassifier loads the classifier on first use.
+type lazyClassifier struct {
+	load func() (categorizer.Classifier, error)
+
+	mu     sync.Mutex
+	loaded bool
+	clip   categorizer.Classifier
+	err    error
+}
+
+func (l *lazyClassifier) ClassifyWithOptions(path string, categories []string, opts model.ClassifyOptions) (map[string]float32, error) {
+	l.mu.Lock()
+	if !l.loaded {
+		l.clip, l.err = l.load()
+		l.loaded = true
+	}
+	clip, err := l.clip, l.err
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return clip.ClassifyWithOptions(path, categories, opts)
+}
+
+// loadErr returns the error loading the classifier failed with, if any.
+func (l *lazyClassifier) loadErr() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/manifest"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// beachClassifier scores every image as a beach.
+type beachClassifier struct{}
+
+func (beachClassifier) ClassifyWithOptions(_ string, cats []string, _ model.ClassifyOptions) (map[string]float32, error) {
+	scores := map[string]float32{model.BaselineCategory: 0.1}
+	for _, c := range cats {
+		scores[c] = 0.05
+	}
+	scores["beach"] = 0.8
+	return scores, nil
+}
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, n := range names {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(n), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		dir := t.TempDir()
+		writeFiles(t, dir, "a.jpg", "b.jpg", "c.jpg", "d.jpg", "notes.txt")
+
+		sorted := 0
+		_, err := Run(dir, Options{
+			Categories: []string{"beach", "city"},
+			Workers:    workers,
+			Classifier: func() (categorizer.Classifier, error) { return beachClassifier{}, nil },
+			Route: func(path string) (categorizer.ImageScores, bool, error) {
+				switch filepath.Base(path) {
+				case "b.jpg":
+					return categorizer.ImageScores{Path: path, Override: "city"}, true, nil
+				case "c.jpg":
+					return categorizer.ImageScores{}, false, ErrSkip
+				}
+				return categorizer.ImageScores{}, false, nil
+			},
+			Decide: func(is categorizer.ImageScores) (categorizer.Result, error) {
+				return categorizer.DecideOne(is, categorizer.Options{Threshold: 0.15, Quiet: true}), nil
+			},
+			Sorted: func(_ categorizer.ImageScores, _ categorizer.Result, _ []mover.MoveResult, err error) error {
+				sorted++
+				return err
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sorted != 3 {
+			t.Errorf("workers %d: expected 3 images sorted, got %d", workers, sorted)
+		}
+		for _, path := range []string{"beach/a.jpg", "city/b.jpg", "c.jpg", "beach/d.jpg", "notes.txt"} {
+			if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+				t.Errorf("workers %d: expected %s: %v", workers, path, err)
+			}
+		}
+		m, _ := manifest.Load(dir)
+		if m == nil || !m.IsManaged("beach") || !m.IsManaged("city") {
+			t.Errorf("workers %d: expected beach and city in the manifest, got %+v", workers, m)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "notes.txt")
+
+	// A directory without images never loads the model.
+	opts := Options{
+		Categories: []string{"beach"},
+		Classifier: func() (categorizer.Classifier, error) {
+			t.Error("the classifier should not be loaded")
+			return nil, errors.New("unexpected")
+		},
+		Decide: func(is categorizer.ImageScores) (categorizer.Result, error) {
+			return categorizer.DecideOne(is, categorizer.Options{Threshold: 0.15, Quiet: true}), nil
+		},
+	}
+	if _, err := Run(dir, opts); !errors.Is(err, scanner.ErrNoImages) {
+		t.Errorf("expected ErrNoImages, got %v", err)
+	}
+
+	// A classifier that cannot load ends the run.
+	writeFiles(t, dir, "a.jpg")
+	loadErr := errors.New("no model")
+	opts.Classifier = func() (categorizer.Classifier, error) { return nil, loadErr }
+	if _, err := Run(dir, opts); !errors.Is(err, loadErr) {
+		t.Errorf("expected the load error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.jpg")); err != nil {
+		t.Errorf("image should be left in place: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bagtoad/imgsort/internal/cache"
+)
+
+// PrintCacheInfo lists each location imgsort keeps data in with its size,
+// followed by the total.
+func PrintCacheInfo(w io.Writer, locs []cache.Location) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	var total int64
+	for _, l := range locs {
+		size := "empty"
+		if l.Files > 0 {
+			size = fmt.Sprintf("%s in %d files", formatBytes(l.Size), l.Files)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", l.Kind, size, l.Path)
+		total += l.Size
+	}
+	fmt.Fprintf(tw, "total\t%s\n", formatBytes(total))
+	tw.Flush()
+}
+
+// PrintCacheClear lists the locations that are about to be cleared.
+func PrintCacheClear(w io.Writer, locs []cache.Location) {
+	for _, l := range locs {
+		fmt.Fprintf(w, "  %s: %s (%s)\n", l.Kind, l.Path, formatBytes(l.Size))
+	}
+}
+
+// PrintCacheProbe summarizes how much of a run the score cache would
+// answer, for a dry run.
+func PrintCacheProbe(w io.Writer, p cache.Probe) {
+	total := p.Hits + p.Uncached()
+	fmt.Fprintf(w, "Score cache: %d of %d images cached, %d need the model", p.Hits, total, p.Uncached())
+	var why []string
+	if p.Misses > 0 {
+		why = append(why, fmt.Sprintf("%d new or changed", p.Misses))
+	}
+	if p.OtherSettings > 0 {
+		why = append(why, fmt.Sprintf("%d cached for other settings", p.OtherSettings))
+	}
+	if p.Unreadable > 0 {
+		why = append(why, fmt.Sprintf("%d unreadable", p.Unreadable))
+	}
+	if len(why) > 0 {
+		fmt.Fprintf(w, " (%s)", strings.Join(why, ", "))
+	}
+	fmt.Fprintln(w)
+}
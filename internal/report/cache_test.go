@@ -0,0 +1,46 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/cache"
+)
+
+func TestPrintCacheInfo(t *testing.T) {
+	var buf bytes.Buffer
+	PrintCacheInfo(&buf, []cache.Location{
+		{Kind: cache.KindModels, Path: "/h/models", Size: 3 << 20, Files: 4},
+		{Kind: cache.KindScores, Path: "/h/cache/scores.json"},
+	})
+	for _, want := range []string{
+		"models  3.0 MiB in 4 files  /h/models\n",
+		"scores  empty               /h/cache/scores.json\n",
+		"total   3.0 MiB\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintCacheProbe(t *testing.T) {
+	var buf bytes.Buffer
+	PrintCacheProbe(&buf, cache.Probe{Hits: 850, Misses: 120, OtherSettings: 30})
+	if want := "Score cache: 850 of 1000 images cached, 150 need the model (120 new or changed, 30 cached for other settings)\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	PrintCacheProbe(&buf, cache.Probe{Hits: 4})
+	if want := "Score cache: 4 of 4 images cached, 0 need the model\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	rep := RunReport{Files: []FileEntry{{Path: "/p/a.jpg"}, {Path: "/p/b.jpg"}, {Path: "/p/notes.txt", Status: StatusExcluded}}}
+	AddCacheStatus(&rep, map[string]string{"/p/a.jpg": cache.StatusHit, "/p/b.jpg": cache.StatusMiss})
+	if rep.Files[0].Cache != "hit" || rep.Files[1].Cache != "miss" || rep.Files[2].Cache != "" {
+		t.Errorf("unexpected cache statuses: %+v", rep.Files)
+	}
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// ConfusionPair counts how often a category won while another came second.
+type ConfusionPair struct {
+	Winner   string `json:"winner"`
+	RunnerUp string `json:"runner_up"`
+	Count    int    `json:"count"`
+}
+
+// Confusion aggregates (winner, runner-up) pairs across all categorized
+// results. Pairs are sorted by count (descending), then by name.
+func Confusion(results []categorizer.Result) []ConfusionPair {
+	counts := make(map[[2]string]int)
+	for _, r := range results {
+		if r.Skipped || r.RunnerUp == "" {
+			continue
+		}
+		counts[[2]string{r.Category, r.RunnerUp}]++
+	}
+
+	pairs := make([]ConfusionPair, 0, len(counts))
+	for k, n := range counts {
+		pairs = append(pairs, ConfusionPair{Winner: k[0], RunnerUp: k[1], Count: n})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		if pairs[i].Winner != pairs[j].Winner {
+			return pairs[i].Winner < pairs[j].Winner
+		}
+		return pairs[i].RunnerUp < pairs[j].RunnerUp
+	})
+	return pairs
+}
+
+// PrintConfusion writes a compact table of competing category pairs.
+func PrintConfusion(w io.Writer, pairs []ConfusionPair) {
+	if len(pairs) == 0 {
+		return
+	}
+
+	width := len("Winner")
+	for _, p := range pairs {
+		if len(p.Winner) > width {
+			width = len(p.Winner)
+		}
+	}
+
+	fmt.Fprintln(w, "=== Competing Categories ===")
+	fmt.Fprintf(w, "  %-*s   %s\n", width, "Winner", "Runner-up (count)")
+	for _, p := range pairs {
+		fmt.Fprintf(w, "  %-*s   %s (%d)\n", width, p.Winner, p.RunnerUp, p.Count)
+	}
+	fmt.Fprintln(w)
+}
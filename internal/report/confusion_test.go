@@ -0,0 +1,55 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+func TestConfusion(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "/imgs/a.jpg", Category: "beach", RunnerUp: "ocean"},
+		{Path: "/imgs/b.jpg", Category: "beach", RunnerUp: "ocean"},
+		{Path: "/imgs/c.jpg", Category: "ocean", RunnerUp: "beach"},
+		{Path: "/imgs/d.jpg", Category: "dog", RunnerUp: "pet"},
+		{Path: "/imgs/e.jpg", Category: "cat"},
+		{Path: "/imgs/f.jpg", Skipped: true, RunnerUp: "pet"},
+	}
+
+	pairs := Confusion(results)
+
+	expected := []ConfusionPair{
+		{Winner: "beach", RunnerUp: "ocean", Count: 2},
+		{Winner: "dog", RunnerUp: "pet", Count: 1},
+		{Winner: "ocean", RunnerUp: "beach", Count: 1},
+	}
+	if len(pairs) != len(expected) {
+		t.Fatalf("expected %d pairs, got %d: %v", len(expected), len(pairs), pairs)
+	}
+	for i, want := range expected {
+		if pairs[i] != want {
+			t.Errorf("pair %d: expected %+v, got %+v", i, want, pairs[i])
+		}
+	}
+}
+
+func TestPrintConfusion(t *testing.T) {
+	var buf bytes.Buffer
+	PrintConfusion(&buf, []ConfusionPair{{Winner: "beach", RunnerUp: "ocean", Count: 3}})
+
+	output := buf.String()
+	if !strings.Contains(output, "Competing Categories") {
+		t.Errorf("expected header in output:\n%s", output)
+	}
+	if !strings.Contains(output, "ocean (3)") {
+		t.Errorf("expected pair count in output:\n%s", output)
+	}
+
+	buf.Reset()
+	PrintConfusion(&buf, nil)
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for empty pairs, got:\n%s", buf.String())
+	}
+}
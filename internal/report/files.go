@@ -0,0 +1,242 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// File statuses in a RunReport.
+const (
+	StatusMoved     = "moved"
+	StatusWouldMove = "would_move"
+	StatusSkipped   = "skipped"
+	StatusExcluded  = "excluded"
+)
+
+// ReasonUnreadable marks scanned paths that could not be read.
+const ReasonUnreadable = "unreadable"
+
+// RunReport accounts for every file a sorting run saw: where each image
+// went, or why it stayed put, and why other entries were not classified.
+type RunReport struct {
+	Header
+	Dir       string      `json:"dir"`
+	DryRun    bool        `json:"dry_run"`
+	Threshold float64     `json:"threshold"`
+	Files     []FileEntry `json:"files"`
+
+	// Tiers counts the files moved into each category by confidence tier.
+	// It is only set when the run sorted into tiers.
+	Tiers map[string]TierCounts `json:"tiers,omitempty"`
+
+	// Confusion counts the (winner, runner-up) category pairs of the
+	// categorized files, most frequent first.
+	Confusion []ConfusionPair `json:"confusion,omitempty"`
+}
+
+// TierCounts is how many files of a category landed in each tier.
+type TierCounts struct {
+	Confident int `json:"confident"`
+	Tentative int `json:"tentative"`
+}
+
+// FileEntry is one file in a RunReport. Destination is where the file is
+// after the run (or would be, in a dry run); for files left in place it is
+// the unchanged path.
+type FileEntry struct {
+	Path        string  `json:"path"`
+	Status      string  `json:"status"`
+	Destination string  `json:"destination"`
+	Category    string  `json:"category,omitempty"`
+	Confidence  float32 `json:"confidence,omitempty"`
+
+	// Pinned is true when the category came from the directory's pins
+	// rather than the model.
+	Pinned bool `json:"pinned,omitempty"`
+
+	// Overridden is true when the category came from the --overrides file.
+	// Confidence is then left out, since no score was involved.
+	Overridden bool `json:"overridden,omitempty"`
+
+	// Tier is the confidence tier the image was sorted in, if the run used
+	// tiers.
+	Tier string `json:"tier,omitempty"`
+
+	// Reason is a machine-readable reason the file was skipped or
+	// excluded, such as "below_threshold" or "non_image".
+	Reason string `json:"reason,omitempty"`
+
+	// For skipped images: the closest category, its score, and the
+	// threshold it had to reach.
+	BestCandidate string  `json:"best_candidate,omitempty"`
+	BestScore     float32 `json:"best_score,omitempty"`
+	Threshold     float64 `json:"threshold,omitempty"`
+	Error         string  `json:"error,omitempty"`
+
+	// CompanionOf is the still a Live Photo video moved with.
+	CompanionOf string `json:"companion_of,omitempty"`
+
+	// Content identifies the file's contents independently of its name,
+	// so a later run can recognize it after a rename.
+	Content string `json:"content,omitempty"`
+
+	// Cache is what the score cache held for the file before a dry run:
+	// "hit", "miss", "other_settings", or "unreadable".
+	Cache string `json:"cache,omitempty"`
+}
+
+// NewRunReport builds a RunReport. scan may be nil when the run did not scan
+// (for example with --scores-in); its excluded entries are only included if
+// the scan recorded them.
+func NewRunReport(dir string, results []categorizer.Result, moves []mover.MoveResult, scan *scanner.Result,
+	threshold float64, dryRun bool) RunReport {
+	rep := RunReport{
+		Header:    NewHeader(),
+		Dir:       dir,
+		DryRun:    dryRun,
+		Threshold: threshold,
+		Files:     []FileEntry{},
+		Confusion: Confusion(results),
+	}
+
+	bySource := make(map[string]mover.MoveResult, len(moves))
+	for _, m := range moves {
+		bySource[m.SourcePath] = m
+	}
+	moved := StatusMoved
+	if dryRun {
+		moved = StatusWouldMove
+	}
+
+	for _, r := range results {
+		e := FileEntry{Path: r.Path, Status: StatusSkipped, Destination: r.Path}
+		if r.Skipped {
+			e.Reason = r.SkipReason
+			e.BestCandidate = r.BestCandidate
+			e.BestScore = r.BestScore
+			e.Threshold = threshold
+			e.Error = r.Error
+			e.Overridden = r.Overridden
+		} else {
+			e.Category = r.Category
+			e.Confidence = r.Confidence
+			e.Pinned = r.Pinned
+			e.Overridden = r.Overridden
+			if r.Overridden {
+				e.Confidence = 0
+			}
+			e.Tier = r.Tier
+			if r.Rejected != "" {
+				// Sorted into the open-set category.
+				e.Reason = r.Rejected
+				e.BestCandidate = r.BestCandidate
+				e.BestScore = r.BestScore
+				e.Threshold = threshold
+			}
+		}
+
+		m, ok := bySource[r.Path]
+		switch {
+		case ok && m.Skipped:
+			e.Status = StatusSkipped
+			e.Reason = strings.ReplaceAll(m.Reason, " ", "_")
+		case ok:
+			// Includes uncategorized images moved by --unsorted move,
+			// which keep their skip reason.
+			e.Status = moved
+			e.Destination = m.DestPath
+			if m.Tier != "" {
+				if rep.Tiers == nil {
+					rep.Tiers = make(map[string]TierCounts)
+				}
+				c := rep.Tiers[m.Category]
+				if m.Tier == categorizer.TierTentative {
+					c.Tentative++
+				} else {
+					c.Confident++
+				}
+				rep.Tiers[m.Category] = c
+			}
+		}
+		rep.Files = append(rep.Files, e)
+
+		if ok && !m.Skipped {
+			for _, c := range m.Companions {
+				rep.Files = append(rep.Files, FileEntry{
+					Path: c.SourcePath, Status: moved, Destination: c.DestPath,
+					Category: m.Category, CompanionOf: r.Path,
+				})
+			}
+		}
+	}
+
+	if scan != nil {
+		for _, x := range scan.Excluded {
+			rep.Files = append(rep.Files, FileEntry{Path: x.Path, Status: StatusExcluded, Destination: x.Path, Reason: x.Reason})
+		}
+		for _, p := range scan.Unreadable {
+			rep.Files = append(rep.Files, FileEntry{Path: p, Status: StatusExcluded, Destination: p, Reason: ReasonUnreadable})
+		}
+	}
+	return rep
+}
+
+// AddContentKeys sets Content on every classified file using key, which
+// is tried on the file's path and then its destination, since a file that
+// was moved is only at the latter. Files key cannot read are left without.
+func AddContentKeys(r *RunReport, key func(path string) (string, error)) {
+	for i := range r.Files {
+		e := &r.Files[i]
+		if e.Status == StatusExcluded {
+			continue
+		}
+		for _, p := range []string{e.Path, e.Destination} {
+			if k, err := key(p); err == nil {
+				e.Content = k
+				break
+			}
+		}
+	}
+}
+
+// AddCacheStatus sets Cache on every file listed in status, a path's
+// status from cache.Probe.
+func AddCacheStatus(r *RunReport, status map[string]string) {
+	for i := range r.Files {
+		if s, ok := status[r.Files[i].Path]; ok {
+			r.Files[i].Cache = s
+		}
+	}
+}
+
+// ReadRunJSON reads a run report written by WriteRunJSON.
+func ReadRunJSON(r io.Reader) (RunReport, error) {
+	var rep struct {
+		RunReport
+		// LegacyVersion is where version 1 reports kept their version.
+		LegacyVersion int `json:"version"`
+	}
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return RunReport{}, fmt.Errorf("cannot parse run report: %w", err)
+	}
+	if rep.SchemaVersion == 0 {
+		rep.SchemaVersion = rep.LegacyVersion
+	}
+	if rep.SchemaVersion < 1 || rep.SchemaVersion > SchemaVersion {
+		return RunReport{}, fmt.Errorf("unsupported run report version %d (expected %d)", rep.SchemaVersion, SchemaVersion)
+	}
+	return rep.RunReport, nil
+}
+
+// WriteRunJSON writes the run report as indented JSON to w.
+func WriteRunJSON(w io.Writer, r RunReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
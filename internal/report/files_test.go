@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// jsonKeys returns the sorted top-level keys v encodes to.
+func jsonKeys(t *testing.T, v any) []string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// TestRunReportSchema locks the JSON field names. Changing them breaks
+// external tools and requires bumping SchemaVersion.
+func TestRunReportSchema(t *testing.T) {
+	if got, want := jsonKeys(t, RunReport{}), []string{"dir", "dry_run", "files", "imgsort_version", "schema_version", "threshold"}; !slices.Equal(got, want) {
+		t.Errorf("RunReport keys = %v, want %v", got, want)
+	}
+
+	full := FileEntry{
+		Path: "p", Status: "s", Destination: "d", Category: "c", Confidence: 1, Reason: "r",
+		BestCandidate: "b", BestScore: 1, Threshold: 1, Error: "e", CompanionOf: "o", Content: "k",
+		Pinned: true, Overridden: true, Tier: "t", Cache: "h",
+	}
+	want := []string{
+		"best_candidate", "best_score", "cache", "category", "companion_of", "confidence", "content", "destination",
+		"error", "overridden", "path", "pinned", "reason", "status", "threshold", "tier",
+	}
+	if got := jsonKeys(t, full); !slices.Equal(got, want) {
+		t.Errorf("FileEntry keys = %v, want %v", got, want)
+	}
+	if got := jsonKeys(t, FileEntry{}); !slices.Equal(got, []string{"destination", "path", "status"}) {
+		t.Errorf("required FileEntry keys = %v", got)
+	}
+}
+
+func TestNewRunReport(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "/p/beach.jpg", Category: "beach", Confidence: 0.8},
+		{Path: "/p/blur.jpg", Skipped: true, SkipReason: categorizer.SkipThreshold, BestCandidate: "city", BestScore: 0.1},
+		{Path: "/p/dup.jpg", Category: "city", Confidence: 0.5},
+		{Path: "/p/fog.jpg", Category: "other", Rejected: categorizer.SkipBaseline, BestCandidate: "beach", BestScore: 0.3},
+	}
+	moves := []mover.MoveResult{
+		{SourcePath: "/p/beach.jpg", DestPath: "/p/beach/beach.jpg", Category: "beach", Live: true,
+			Companions: []mover.Companion{{SourcePath: "/p/beach.mov", DestPath: "/p/beach/beach.mov"}}},
+		{SourcePath: "/p/dup.jpg", DestPath: "/p/city/dup.jpg", Category: "city", Skipped: true, Reason: "destination exists"},
+		{SourcePath: "/p/fog.jpg", DestPath: "/p/other/fog.jpg", Category: "other"},
+	}
+	scan := &scanner.Result{
+		Excluded:   []scanner.Excluded{{Path: "/p/notes.txt", Reason: scanner.ReasonNonImage}},
+		Unreadable: []string{"/p/private"},
+	}
+
+	rep := NewRunReport("/p", results, moves, scan, 0.15, false)
+	want := []FileEntry{
+		{Path: "/p/beach.jpg", Status: StatusMoved, Destination: "/p/beach/beach.jpg", Category: "beach", Confidence: 0.8},
+		{Path: "/p/beach.mov", Status: StatusMoved, Destination: "/p/beach/beach.mov", Category: "beach", CompanionOf: "/p/beach.jpg"},
+		{Path: "/p/blur.jpg", Status: StatusSkipped, Destination: "/p/blur.jpg", Reason: "below_threshold",
+			BestCandidate: "city", BestScore: 0.1, Threshold: 0.15},
+		{Path: "/p/dup.jpg", Status: StatusSkipped, Destination: "/p/dup.jpg", Category: "city", Confidence: 0.5,
+			Reason: "destination_exists"},
+		{Path: "/p/fog.jpg", Status: StatusMoved, Destination: "/p/other/fog.jpg", Category: "other",
+			Reason: "baseline", BestCandidate: "beach", BestScore: 0.3, Threshold: 0.15},
+		{Path: "/p/notes.txt", Status: StatusExcluded, Destination: "/p/notes.txt", Reason: "non_image"},
+		{Path: "/p/private", Status: StatusExcluded, Destination: "/p/private", Reason: "unreadable"},
+	}
+	if !slices.Equal(rep.Files, want) {
+		t.Errorf("files:\n got %+v\nwant %+v", rep.Files, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRunJSON(&buf, NewRunReport("/p", results, moves, nil, 0.15, true)); err != nil {
+		t.Fatal(err)
+	}
+	var decoded RunReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.DryRun || decoded.Files[0].Status != StatusWouldMove || len(decoded.Files) != 5 {
+		t.Errorf("unexpected dry-run report: %+v", decoded)
+	}
+}
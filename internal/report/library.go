@@ -0,0 +1,180 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// histogramBins is how many equal slices of 0–100% a category's confidence
+// histogram has.
+const histogramBins = 10
+
+// dateLayout is how library stats write dates.
+const dateLayout = "2006-01-02"
+
+// LibraryFile is one image of a sorted tree counted by NewLibraryStats.
+// Category is empty for an image left uncategorized. HasConfidence is
+// false when no sidecar or attribute recorded the image's confidence.
+type LibraryFile struct {
+	Path          string    `json:"path"`
+	Category      string    `json:"category"`
+	Bytes         int64     `json:"bytes"`
+	Date          time.Time `json:"date"`
+	Confidence    float32   `json:"confidence,omitempty"`
+	HasConfidence bool      `json:"-"`
+}
+
+// CategoryStats are the aggregate numbers for one category. Category is
+// empty for the images left uncategorized. ConfidenceHistogram counts the
+// images with a recorded confidence in ten equal bins from 0–10% to
+// 90–100%; Unscored counts those without one.
+type CategoryStats struct {
+	Category            string             `json:"category"`
+	Images              int                `json:"images"`
+	Bytes               int64              `json:"bytes"`
+	ConfidenceHistogram [histogramBins]int `json:"confidence_histogram"`
+	Unscored            int                `json:"unscored"`
+	MeanConfidence      float32            `json:"mean_confidence"`
+	Earliest            string             `json:"earliest"`
+	Latest              string             `json:"latest"`
+}
+
+// LibraryStats are a sorted tree's statistics for its owner: the aggregate
+// numbers, plus every image they were counted from.
+type LibraryStats struct {
+	Header
+	Dir        string          `json:"dir"`
+	Images     int             `json:"images"`
+	Bytes      int64           `json:"bytes"`
+	Categories []CategoryStats `json:"categories"`
+	Files      []LibraryFile   `json:"files"`
+}
+
+// AnonymousStats are a sorted tree's statistics for sharing. The type has
+// no field that could hold a path, file name, or content hash, so nothing
+// identifying can be written through it by mistake; category names are the
+// only text it carries.
+type AnonymousStats struct {
+	Header
+	Anonymized bool            `json:"anonymized"`
+	Images     int             `json:"images"`
+	Bytes      int64           `json:"bytes"`
+	Categories []CategoryStats `json:"categories"`
+}
+
+// NewLibraryStats counts files, the images of the sorted tree at dir.
+// Categories are in name order, with the uncategorized images last.
+func NewLibraryStats(dir string, files []LibraryFile) LibraryStats {
+	type acc struct {
+		stats       CategoryStats
+		sum         float64
+		first, last time.Time
+	}
+	byCat := make(map[string]*acc)
+	s := LibraryStats{Dir: dir, Files: files}
+	for _, f := range files {
+		a := byCat[f.Category]
+		if a == nil {
+			a = &acc{stats: CategoryStats{Category: f.Category}}
+			byCat[f.Category] = a
+		}
+		a.stats.Images++
+		a.stats.Bytes += f.Bytes
+		s.Images++
+		s.Bytes += f.Bytes
+		if f.HasConfidence {
+			bin := min(max(int(f.Confidence*histogramBins), 0), histogramBins-1)
+			a.stats.ConfidenceHistogram[bin]++
+			a.sum += float64(f.Confidence)
+		} else {
+			a.stats.Unscored++
+		}
+		if !f.Date.IsZero() {
+			if a.first.IsZero() || f.Date.Before(a.first) {
+				a.first = f.Date
+			}
+			if f.Date.After(a.last) {
+				a.last = f.Date
+			}
+		}
+	}
+
+	for _, a := range byCat {
+		if scored := a.stats.Images - a.stats.Unscored; scored > 0 {
+			a.stats.MeanConfidence = float32(a.sum / float64(scored))
+		}
+		if !a.first.IsZero() {
+			a.stats.Earliest = a.first.Format(dateLayout)
+			a.stats.Latest = a.last.Format(dateLayout)
+		}
+		s.Categories = append(s.Categories, a.stats)
+	}
+	sort.Slice(s.Categories, func(i, j int) bool {
+		ci, cj := s.Categories[i].Category, s.Categories[j].Category
+		if (ci == "") != (cj == "") {
+			return cj == ""
+		}
+		return ci < cj
+	})
+	return s
+}
+
+// Anonymize returns the aggregate numbers of s, leaving out its directory
+// and files.
+func Anonymize(s LibraryStats) AnonymousStats {
+	return AnonymousStats{
+		Anonymized: true,
+		Images:     s.Images,
+		Bytes:      s.Bytes,
+		Categories: s.Categories,
+	}
+}
+
+// PrintLibraryStats prints s as a table of categories.
+func PrintLibraryStats(w io.Writer, s LibraryStats) {
+	fmt.Fprintf(w, "%s: %d images (%s)\n\n", s.Dir, s.Images, formatBytes(s.Bytes))
+	fmt.Fprintf(w, "  %-20s %7s %10s %10s  %s\n", "category", "images", "size", "mean conf", "dates")
+	for _, c := range s.Categories {
+		name := c.Category
+		if name == "" {
+			name = "(uncategorized)"
+		}
+		conf := "-"
+		if c.Unscored < c.Images {
+			conf = fmt.Sprintf("%.1f%%", c.MeanConfidence*100)
+		}
+		dates := ""
+		if c.Earliest != "" {
+			dates = c.Earliest + " – " + c.Latest
+		}
+		fmt.Fprintf(w, "  %-20s %7d %10s %10s  %s\n", name, c.Images, formatBytes(c.Bytes), conf, dates)
+	}
+}
+
+// WriteLibraryStatsJSON writes s as indented JSON to w.
+func WriteLibraryStatsJSON(w io.Writer, s LibraryStats) error {
+	s.Header = NewHeader()
+	if s.Categories == nil {
+		s.Categories = []CategoryStats{}
+	}
+	if s.Files == nil {
+		s.Files = []LibraryFile{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteAnonymousStatsJSON writes s as indented JSON to w.
+func WriteAnonymousStatsJSON(w io.Writer, s AnonymousStats) error {
+	s.Header = NewHeader()
+	if s.Categories == nil {
+		s.Categories = []CategoryStats{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func libraryFixture() LibraryStats {
+	day := func(d int) time.Time { return time.Date(2023, 5, d, 12, 0, 0, 0, time.UTC) }
+	return NewLibraryStats("/photos", []LibraryFile{
+		{Path: "/photos/beach/IMG_1.jpg", Category: "beach", Bytes: 100, Date: day(3), Confidence: 0.95, HasConfidence: true},
+		{Path: "/photos/beach/IMG_2.jpg", Category: "beach", Bytes: 200, Date: day(1), Confidence: 1, HasConfidence: true},
+		{Path: "/photos/beach/IMG_3.jpg", Category: "beach", Bytes: 300, Date: day(9), Confidence: 0.42, HasConfidence: true},
+		{Path: "/photos/beach/IMG_4.jpg", Category: "beach", Bytes: 400, Date: day(5)},
+		{Path: "/photos/IMG_5.jpg", Bytes: 50},
+		{Path: "/photos/city/IMG_6.jpg", Category: "city", Bytes: 60, Date: day(2), Confidence: 0.05, HasConfidence: true},
+	})
+}
+
+func TestNewLibraryStats(t *testing.T) {
+	s := libraryFixture()
+	if s.Images != 6 || s.Bytes != 1110 {
+		t.Errorf("totals: %d images, %d bytes", s.Images, s.Bytes)
+	}
+	var names []string
+	for _, c := range s.Categories {
+		names = append(names, c.Category)
+	}
+	if !slices.Equal(names, []string{"beach", "city", ""}) {
+		t.Fatalf("expected categories in name order, uncategorized last, got %q", names)
+	}
+
+	beach := s.Categories[0]
+	want := CategoryStats{
+		Category: "beach", Images: 4, Bytes: 1000, Unscored: 1,
+		ConfidenceHistogram: [10]int{4: 1, 9: 2},
+		Earliest:            "2023-05-01", Latest: "2023-05-09",
+	}
+	beach.MeanConfidence = 0
+	if beach != want {
+		t.Errorf("got %+v\nwant %+v", beach, want)
+	}
+	if mean := s.Categories[0].MeanConfidence; mean < 0.789 || mean > 0.791 {
+		t.Errorf("mean confidence %v, want 0.79", mean)
+	}
+	if unsorted := s.Categories[2]; unsorted.Unscored != 1 || unsorted.Earliest != "" || unsorted.MeanConfidence != 0 {
+		t.Errorf("unexpected uncategorized stats %+v", unsorted)
+	}
+}
+
+// TestAnonymousStatsSchema locks what --anonymize shares. Anything added
+// here is published by everyone who exports anonymized stats.
+func TestAnonymousStatsSchema(t *testing.T) {
+	a := Anonymize(libraryFixture())
+	want := []string{"anonymized", "bytes", "categories", "images", "imgsort_version", "schema_version"}
+	if got := jsonKeys(t, a); !slices.Equal(got, want) {
+		t.Errorf("AnonymousStats keys = %v, want %v", got, want)
+	}
+	want = []string{"bytes", "category", "confidence_histogram", "earliest", "images", "latest", "mean_confidence", "unscored"}
+	if got := jsonKeys(t, a.Categories[0]); !slices.Equal(got, want) {
+		t.Errorf("CategoryStats keys = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAnonymousStatsJSON(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"/photos", "IMG_"} {
+		if strings.Contains(buf.String(), leak) {
+			t.Errorf("anonymized stats contain %q:\n%s", leak, buf.String())
+		}
+	}
+}
+
+// TestAnonymousStatsHasNoFreeText keeps the type itself from gaining a
+// field that could hold a path or name: its only strings are the format
+// header, category names, and formatted dates.
+func TestAnonymousStatsHasNoFreeText(t *testing.T) {
+	allowed := map[string]bool{"ImgsortVersion": true, "Category": true, "Earliest": true, "Latest": true}
+	var check func(typ reflect.Type)
+	check = func(typ reflect.Type) {
+		switch typ.Kind() {
+		case reflect.Struct:
+			for i := range typ.NumField() {
+				f := typ.Field(i)
+				if f.Type.Kind() == reflect.String && !allowed[f.Name] {
+					t.Errorf("%s.%s is a string field", typ.Name(), f.Name)
+				}
+				check(f.Type)
+			}
+		case reflect.Slice, reflect.Array, reflect.Pointer, reflect.Map:
+			if typ.Kind() == reflect.Map {
+				t.Errorf("%s is a map, whose keys could hold names", typ)
+			}
+			check(typ.Elem())
+		}
+	}
+	check(reflect.TypeOf(AnonymousStats{}))
+}
+
+func TestLibraryStatsJSONListsFiles(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLibraryStatsJSON(&buf, libraryFixture()); err != nil {
+		t.Fatal(err)
+	}
+	var got LibraryStats
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Dir != "/photos" || len(got.Files) != 6 || got.Files[0].Path != "/photos/beach/IMG_1.jpg" {
+		t.Errorf("unexpected export %+v", got)
+	}
+}
+
+func TestPrintLibraryStats(t *testing.T) {
+	var buf bytes.Buffer
+	PrintLibraryStats(&buf, libraryFixture())
+	out := buf.String()
+	for _, want := range []string{"6 images", "beach", "79.0%", "2023-05-01 – 2023-05-09", "(uncategorized)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/bagtoad/imgsort/internal/mover"
+)
+
+// PrintMerge writes a summary of a category merge, listing each source
+// folder in the order given and what happened to each of its files.
+func PrintMerge(w io.Writer, sources []string, target string, moves []mover.MoveResult, dryRun bool) {
+	fmt.Fprintln(w)
+	if dryRun {
+		fmt.Fprintln(w, "=== Dry Run Merge Summary ===")
+	} else {
+		fmt.Fprintln(w, "=== Merge Summary ===")
+	}
+
+	verb := "Moved"
+	if dryRun {
+		verb = "Would move"
+	}
+
+	bySource := make(map[string][]mover.MoveResult)
+	for _, m := range moves {
+		bySource[m.Category] = append(bySource[m.Category], m)
+	}
+
+	for _, src := range sources {
+		items := bySource[src]
+		fmt.Fprintf(w, "  %s/ → %s/ (%d files)\n", src, target, len(items))
+		for _, m := range items {
+			switch {
+			case m.Skipped:
+				fmt.Fprintf(w, "    Skipped %s (%s)\n", filepath.Base(m.SourcePath), m.Reason)
+			case m.Overwrote:
+				fmt.Fprintf(w, "    %s %s → %s (overwrites existing file)\n", verb, filepath.Base(m.SourcePath), m.DestPath)
+			case filepath.Base(m.DestPath) != filepath.Base(m.SourcePath):
+				fmt.Fprintf(w, "    %s %s → %s (renamed to avoid a collision)\n", verb, filepath.Base(m.SourcePath), m.DestPath)
+			default:
+				fmt.Fprintf(w, "    %s %s → %s\n", verb, filepath.Base(m.SourcePath), m.DestPath)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
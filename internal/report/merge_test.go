@@ -0,0 +1,34 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/mover"
+)
+
+func TestPrintMerge(t *testing.T) {
+	moves := []mover.MoveResult{
+		{SourcePath: "/p/sea/a.jpg", DestPath: "/p/ocean/a_1.jpg", Category: "sea"},
+		{SourcePath: "/p/sea/b.jpg", DestPath: "/p/ocean/b.jpg", Category: "sea"},
+		{SourcePath: "/p/waves/c.jpg", Category: "waves", Skipped: true, Reason: "destination exists"},
+	}
+
+	var buf bytes.Buffer
+	PrintMerge(&buf, []string{"sea", "waves"}, "ocean", moves, true)
+	out := buf.String()
+
+	for _, want := range []string{
+		"Dry Run Merge Summary",
+		"sea/ → ocean/ (2 files)",
+		"Would move a.jpg → /p/ocean/a_1.jpg (renamed to avoid a collision)",
+		"Would move b.jpg → /p/ocean/b.jpg\n",
+		"waves/ → ocean/ (1 files)",
+		"Skipped c.jpg (destination exists)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Kinds of PlanChange.
+const (
+	ChangeCategorized = "newly_categorized"
+	ChangeCategory    = "category_changed"
+	ChangeSkipped     = "now_skipped"
+	ChangeGone        = "no_longer_present"
+)
+
+// PlanChange is one difference between two plans. Old fields describe the
+// file in the previous plan; OldPath is set when the file was matched by
+// content after a rename.
+type PlanChange struct {
+	Kind          string  `json:"kind"`
+	Path          string  `json:"path"`
+	OldPath       string  `json:"old_path,omitempty"`
+	OldCategory   string  `json:"old_category,omitempty"`
+	OldConfidence float32 `json:"old_confidence,omitempty"`
+	Category      string  `json:"category,omitempty"`
+	Confidence    float32 `json:"confidence,omitempty"`
+	Reason        string  `json:"reason,omitempty"`
+}
+
+// PlanDiff is the result of DiffPlans. Unchanged counts files present in
+// both plans whose outcome is the same.
+type PlanDiff struct {
+	Header
+	Changes   []PlanChange `json:"changes"`
+	Unchanged int          `json:"unchanged"`
+}
+
+// DiffPlans compares the images in cur against prev, matching files by
+// their path relative to the sorted directory and falling back to their
+// content key for files renamed in between. Excluded entries and Live
+// Photo companions are ignored, as are files uncategorized in both plans.
+func DiffPlans(prev, cur RunReport) PlanDiff {
+	prevFiles := planFiles(prev)
+	curFiles := planFiles(cur)
+
+	byPath := make(map[string]int, len(prevFiles))
+	for i, e := range prevFiles {
+		byPath[planKey(prev, e)] = i
+	}
+	matched := make([]bool, len(prevFiles))
+	match := make([]int, len(curFiles))
+	for i, e := range curFiles {
+		match[i] = -1
+		if j, ok := byPath[planKey(cur, e)]; ok {
+			match[i] = j
+			matched[j] = true
+		}
+	}
+
+	// Content keys only pair files left over on both sides, and only when
+	// unambiguous, so duplicates are never matched to the wrong copy.
+	byContent := make(map[string][]int)
+	for j, e := range prevFiles {
+		if !matched[j] && e.Content != "" {
+			byContent[e.Content] = append(byContent[e.Content], j)
+		}
+	}
+	curContent := make(map[string]int)
+	for i, e := range curFiles {
+		if match[i] < 0 && e.Content != "" {
+			curContent[e.Content]++
+		}
+	}
+	for i, e := range curFiles {
+		if js := byContent[e.Content]; match[i] < 0 && len(js) == 1 && curContent[e.Content] == 1 {
+			match[i] = js[0]
+			matched[js[0]] = true
+		}
+	}
+
+	var d PlanDiff
+	for i, e := range curFiles {
+		c := PlanChange{Path: e.Path, Category: e.Category, Confidence: e.Confidence}
+		var old FileEntry
+		if match[i] >= 0 {
+			old = prevFiles[match[i]]
+			c.OldCategory, c.OldConfidence = old.Category, old.Confidence
+			if planKey(prev, old) != planKey(cur, e) {
+				c.OldPath = old.Path
+			}
+		}
+		wasSorted, isSorted := categorized(old), categorized(e)
+		switch {
+		case isSorted && !wasSorted:
+			c.Kind = ChangeCategorized
+		case isSorted && old.Category != e.Category:
+			c.Kind = ChangeCategory
+		case wasSorted && !isSorted:
+			c.Kind = ChangeSkipped
+			c.Category, c.Confidence = "", 0
+			c.Reason = e.Reason
+		default:
+			if match[i] >= 0 {
+				d.Unchanged++
+			}
+			continue
+		}
+		d.Changes = append(d.Changes, c)
+	}
+	for j, e := range prevFiles {
+		if !matched[j] {
+			d.Changes = append(d.Changes, PlanChange{
+				Kind: ChangeGone, Path: e.Path, OldCategory: e.Category, OldConfidence: e.Confidence,
+			})
+		}
+	}
+
+	order := map[string]int{ChangeCategorized: 0, ChangeCategory: 1, ChangeSkipped: 2, ChangeGone: 3}
+	sort.SliceStable(d.Changes, func(a, b int) bool {
+		ca, cb := d.Changes[a], d.Changes[b]
+		if ca.Kind != cb.Kind {
+			return order[ca.Kind] < order[cb.Kind]
+		}
+		return ca.Path < cb.Path
+	})
+	return d
+}
+
+// planFiles returns the entries of r that DiffPlans compares.
+func planFiles(r RunReport) []FileEntry {
+	var files []FileEntry
+	for _, e := range r.Files {
+		if e.Status != StatusExcluded && e.CompanionOf == "" {
+			files = append(files, e)
+		}
+	}
+	return files
+}
+
+// planKey returns e's path relative to the plan's directory, so plans made
+// with the directory given differently still line up.
+func planKey(r RunReport, e FileEntry) string {
+	if rel, err := filepath.Rel(r.Dir, e.Path); err == nil {
+		return rel
+	}
+	return e.Path
+}
+
+// categorized reports whether e was sorted into a category. Images moved by
+// --unsorted move have no category and do not count.
+func categorized(e FileEntry) bool {
+	return (e.Status == StatusMoved || e.Status == StatusWouldMove) && e.Category != ""
+}
+
+// PrintPlanDiff prints d grouped by kind of change, relative to baseDir.
+func PrintPlanDiff(w io.Writer, baseDir string, d PlanDiff) {
+	if len(d.Changes) == 0 {
+		fmt.Fprintf(w, "No changes from the previous plan (%d files unchanged)\n", d.Unchanged)
+		return
+	}
+	fmt.Fprintf(w, "%d changes from the previous plan (%d files unchanged):\n", len(d.Changes), d.Unchanged)
+
+	titles := map[string]string{
+		ChangeCategorized: "Newly categorized",
+		ChangeCategory:    "Category changed",
+		ChangeSkipped:     "Now skipped",
+		ChangeGone:        "No longer present",
+	}
+	kind := ""
+	for _, c := range d.Changes {
+		if c.Kind != kind {
+			kind = c.Kind
+			fmt.Fprintf(w, "\n%s:\n", titles[kind])
+		}
+		name := relTo(baseDir, c.Path)
+		if c.OldPath != "" {
+			name += " (was " + relTo(baseDir, c.OldPath) + ")"
+		}
+		switch c.Kind {
+		case ChangeCategorized:
+			fmt.Fprintf(w, "  %s → %s (%.1f%%)\n", name, c.Category, c.Confidence*100)
+		case ChangeCategory:
+			fmt.Fprintf(w, "  %s: %s (%.1f%%) → %s (%.1f%%)\n", name,
+				c.OldCategory, c.OldConfidence*100, c.Category, c.Confidence*100)
+		case ChangeSkipped:
+			reason := c.Reason
+			if reason == "" {
+				reason = "skipped"
+			}
+			fmt.Fprintf(w, "  %s: %s (%.1f%%) → %s\n", name, c.OldCategory, c.OldConfidence*100, reason)
+		case ChangeGone:
+			if c.OldCategory != "" {
+				fmt.Fprintf(w, "  %s (was %s)\n", name, c.OldCategory)
+			} else {
+				fmt.Fprintf(w, "  %s\n", name)
+			}
+		}
+	}
+}
+
+// WritePlanDiffJSON writes d as indented JSON to w.
+func WritePlanDiffJSON(w io.Writer, d PlanDiff) error {
+	d.Header = NewHeader()
+	if d.Changes == nil {
+		d.Changes = []PlanChange{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// relTo returns path relative to baseDir, or path itself if it is not
+// inside it.
+func relTo(baseDir, path string) string {
+	if rel, err := filepath.Rel(baseDir, path); err == nil {
+		return rel
+	}
+	return path
+}
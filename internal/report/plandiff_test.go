@@ -0,0 +1,153 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// plan builds a run report over /photos from entries.
+func plan(entries ...FileEntry) RunReport {
+	return RunReport{Header: NewHeader(), Dir: "/photos", DryRun: true, Files: entries}
+}
+
+func sorted(name, cat string, conf float32, content string) FileEntry {
+	return FileEntry{Path: "/photos/" + name, Status: StatusWouldMove, Category: cat, Confidence: conf, Content: content}
+}
+
+func skipped(name, reason, content string) FileEntry {
+	return FileEntry{Path: "/photos/" + name, Status: StatusSkipped, Reason: reason, Content: content}
+}
+
+func TestDiffPlans(t *testing.T) {
+	prev := plan(
+		sorted("same.jpg", "beach", 0.8, "1-a"),
+		sorted("changed.jpg", "city", 0.4, "1-b"),
+		sorted("dropped.jpg", "beach", 0.3, "1-c"),
+		skipped("promoted.jpg", "below_threshold", "1-d"),
+		sorted("deleted.jpg", "forest", 0.9, "1-e"),
+		sorted("old-name.jpg", "beach", 0.7, "1-f"),
+		skipped("still-skipped.jpg", "below_threshold", "1-g"),
+		FileEntry{Path: "/photos/notes.txt", Status: StatusExcluded, Reason: "non_image"},
+	)
+	cur := RunReport{Header: NewHeader(), Dir: "/photos/", DryRun: true, Files: []FileEntry{
+		sorted("same.jpg", "beach", 0.75, "1-a"),
+		sorted("changed.jpg", "ocean", 0.55, "1-b"),
+		skipped("dropped.jpg", "below_threshold", "1-c"),
+		sorted("promoted.jpg", "city", 0.6, "1-d"),
+		sorted("new-name.jpg", "ocean", 0.7, "1-f"),
+		skipped("still-skipped.jpg", "below_threshold", "1-g"),
+		sorted("added.jpg", "forest", 0.5, "1-h"),
+		skipped("added-skipped.jpg", "below_threshold", "1-i"),
+		{Path: "/photos/same.mov", Status: StatusWouldMove, Category: "beach", CompanionOf: "/photos/same.jpg"},
+	}}
+
+	got := DiffPlans(prev, cur)
+	want := PlanDiff{Unchanged: 2, Changes: []PlanChange{
+		{Kind: ChangeCategorized, Path: "/photos/added.jpg", Category: "forest", Confidence: 0.5},
+		{Kind: ChangeCategorized, Path: "/photos/promoted.jpg", Category: "city", Confidence: 0.6},
+		{Kind: ChangeCategory, Path: "/photos/changed.jpg", OldCategory: "city", OldConfidence: 0.4, Category: "ocean", Confidence: 0.55},
+		{Kind: ChangeCategory, Path: "/photos/new-name.jpg", OldPath: "/photos/old-name.jpg",
+			OldCategory: "beach", OldConfidence: 0.7, Category: "ocean", Confidence: 0.7},
+		{Kind: ChangeSkipped, Path: "/photos/dropped.jpg", OldCategory: "beach", OldConfidence: 0.3, Reason: "below_threshold"},
+		{Kind: ChangeGone, Path: "/photos/deleted.jpg", OldCategory: "forest", OldConfidence: 0.9},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected diff:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestDiffPlansAmbiguousContent(t *testing.T) {
+	// Two renamed copies of the same file cannot be told apart, so neither
+	// is matched by content.
+	prev := plan(sorted("a.jpg", "beach", 0.8, "1-x"), sorted("b.jpg", "beach", 0.8, "1-x"))
+	cur := plan(sorted("c.jpg", "beach", 0.8, "1-x"), sorted("d.jpg", "beach", 0.8, "1-x"))
+
+	got := DiffPlans(prev, cur)
+	kinds := make(map[string]int)
+	for _, c := range got.Changes {
+		kinds[c.Kind]++
+		if c.OldPath != "" {
+			t.Errorf("%s should not be matched to %s", c.Path, c.OldPath)
+		}
+	}
+	if kinds[ChangeCategorized] != 2 || kinds[ChangeGone] != 2 {
+		t.Errorf("expected 2 new and 2 gone, got %v", kinds)
+	}
+}
+
+func TestDiffPlansIgnoresUnsortedMoves(t *testing.T) {
+	// --unsorted move gives would_move entries without a category.
+	unsorted := FileEntry{Path: "/photos/a.jpg", Status: StatusWouldMove, Reason: "below_threshold"}
+	got := DiffPlans(plan(unsorted), plan(skipped("a.jpg", "below_threshold", "")))
+	if len(got.Changes) != 0 || got.Unchanged != 1 {
+		t.Errorf("expected no changes, got %+v", got)
+	}
+}
+
+func TestPrintPlanDiff(t *testing.T) {
+	d := PlanDiff{Unchanged: 3, Changes: []PlanChange{
+		{Kind: ChangeCategorized, Path: "/photos/a.jpg", Category: "beach", Confidence: 0.81},
+		{Kind: ChangeCategory, Path: "/photos/b.jpg", OldPath: "/photos/old.jpg",
+			OldCategory: "city", OldConfidence: 0.4, Category: "ocean", Confidence: 0.55},
+		{Kind: ChangeSkipped, Path: "/photos/c.jpg", OldCategory: "beach", OldConfidence: 0.3, Reason: "below_threshold"},
+		{Kind: ChangeGone, Path: "/photos/d.jpg", OldCategory: "forest"},
+	}}
+	var buf bytes.Buffer
+	PrintPlanDiff(&buf, "/photos", d)
+	out := buf.String()
+	for _, want := range []string{
+		"4 changes from the previous plan (3 files unchanged):",
+		"Newly categorized:\n  a.jpg → beach (81.0%)",
+		"Category changed:\n  b.jpg (was old.jpg): city (40.0%) → ocean (55.0%)",
+		"Now skipped:\n  c.jpg: beach (30.0%) → below_threshold",
+		"No longer present:\n  d.jpg (was forest)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	PrintPlanDiff(&buf, "/photos", PlanDiff{Unchanged: 2})
+	if got := buf.String(); got != "No changes from the previous plan (2 files unchanged)\n" {
+		t.Errorf("unexpected output for an empty diff: %q", got)
+	}
+}
+
+func TestPlanDiffJSONRoundTrip(t *testing.T) {
+	var rep bytes.Buffer
+	prev := plan(sorted("a.jpg", "beach", 0.8, "1-a"))
+	if err := WriteRunJSON(&rep, prev); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadRunJSON(&rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, prev) {
+		t.Errorf("run report changed in a round trip: %+v", read)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlanDiffJSON(&buf, DiffPlans(read, read)); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if changes, ok := got["changes"].([]any); !ok || len(changes) != 0 || got["unchanged"] != 1.0 {
+		t.Errorf("unexpected JSON: %s", buf.String())
+	}
+
+	if _, err := ReadRunJSON(strings.NewReader(`{"schema_version": 99}`)); err == nil {
+		t.Error("expected an error for an unknown report version")
+	}
+	legacy, err := ReadRunJSON(strings.NewReader(`{"version": 1, "dir": "/photos", "files": []}`))
+	if err != nil || legacy.SchemaVersion != 1 || legacy.Dir != "/photos" {
+		t.Errorf("expected a version 1 report to load, got %+v, %v", legacy, err)
+	}
+}
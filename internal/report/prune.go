@@ -0,0 +1,29 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// PrintPrune lists the empty folders removed from baseDir, or that would be
+// removed in a dry run, followed by a count.
+func PrintPrune(w io.Writer, baseDir string, removed []string, dryRun bool) {
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	for _, d := range removed {
+		rel, err := filepath.Rel(baseDir, d)
+		if err != nil {
+			rel = d
+		}
+		fmt.Fprintf(w, "  %s %s/\n", verb, rel)
+	}
+
+	noun := "folders"
+	if len(removed) == 1 {
+		noun = "folder"
+	}
+	fmt.Fprintf(w, "%s %d empty %s\n", verb, len(removed), noun)
+}
@@ -0,0 +1,22 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintPrune(t *testing.T) {
+	var buf bytes.Buffer
+	PrintPrune(&buf, "/photos", []string{"/photos/beach/2023", "/photos/beach"}, true)
+	want := "  Would remove beach/2023/\n  Would remove beach/\nWould remove 2 empty folders\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	PrintPrune(&buf, "/photos", []string{"/photos/city"}, false)
+	if !strings.HasSuffix(buf.String(), "Removed 1 empty folder\n") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
@@ -6,34 +6,21 @@ import (
 	"io"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/bagtoad/imgsort/internal/categorizer"
 	"github.com/bagtoad/imgsort/internal/mover"
 )
 
-// Print writes a summary report to the given writer.
-func Print(w io.Writer, results []categorizer.Result, moves []mover.MoveResult, skippedNonImage int, dryRun bool) {
-	totalImages := len(results)
-	skippedCount := 0
-	for _, r := range results {
-		if r.Skipped {
-			skippedCount++
-		}
-	}
-	categorizedCount := totalImages - skippedCount
+// DefaultListLimit is how many files Print lists per category by default.
+const DefaultListLimit = 20
 
-	fmt.Fprintln(w)
-	if dryRun {
-		fmt.Fprintln(w, "=== Dry Run Summary ===")
-	} else {
-		fmt.Fprintln(w, "=== Summary ===")
-	}
-	fmt.Fprintf(w, "Images found:        %d\n", totalImages)
-	fmt.Fprintf(w, "Images categorized:  %d\n", categorizedCount)
-	fmt.Fprintf(w, "Images skipped:      %d\n", skippedCount)
-	if skippedNonImage > 0 {
-		fmt.Fprintf(w, "Non-image files:     %d\n", skippedNonImage)
-	}
+// Print writes a summary report to the given writer. It lists at most limit
+// files per category, followed by a count of the rest; 0 lists them all.
+// The counts always cover every file.
+func Print(w io.Writer, results []categorizer.Result, moves []mover.MoveResult, skippedNonImage int, dryRun bool, limit int) {
+	stats := NewStats(results, moves, skippedNonImage, dryRun)
+	printCounts(w, stats)
 
 	if len(moves) == 0 {
 		fmt.Fprintln(w, "\nNo files to move.")
@@ -53,20 +40,223 @@ func Print(w io.Writer, results []categorizer.Result, moves []mover.MoveResult,
 	}
 	sort.Strings(catNames)
 
-	fmt.Fprintf(w, "Categories:          %d\n", len(catNames))
-	fmt.Fprintln(w)
+	// Overridden images have no score to show; they are marked instead.
+	manual := make(map[string]bool)
+	for _, r := range results {
+		if r.Overridden {
+			manual[r.Path] = true
+		}
+	}
 
-	verb := "Moved"
+	fmt.Fprintf(w, "Categories:          %d\n", stats.Categories)
+	if stats.Disappeared > 0 {
+		fmt.Fprintf(w, "Sources disappeared: %d\n", stats.Disappeared)
+	}
+	printDates(w, stats)
+	sizes := CategorySizes(moves)
 	if dryRun {
-		verb = "Would move"
+		var total int64
+		for _, n := range sizes {
+			total += n
+		}
+		fmt.Fprintf(w, "Data to move:        %s\n", formatBytes(total))
 	}
+	fmt.Fprintln(w)
 
 	for _, cat := range catNames {
 		items := groups[cat]
-		fmt.Fprintf(w, "  %s/ (%d files)\n", cat, len(items))
+		tentative := ""
+		if n := tierCount(items, categorizer.TierTentative); n > 0 {
+			tentative = fmt.Sprintf(", %d tentative", n)
+		}
+		if dryRun {
+			fmt.Fprintf(w, "  %s/ (%d files%s, %s)\n", cat, len(items), tentative, formatBytes(sizes[cat]))
+		} else {
+			fmt.Fprintf(w, "  %s/ (%d files%s)\n", cat, len(items), tentative)
+		}
+
+		// Burst members are listed together under a single heading.
+		burstSize := make(map[string]int)
 		for _, m := range items {
-			fmt.Fprintf(w, "    %s %s → %s\n", verb, filepath.Base(m.SourcePath), m.DestPath)
+			if m.Group != "" {
+				burstSize[m.Group]++
+			}
+		}
+		seen := make(map[string]bool)
+
+		listed := items
+		if limit > 0 && len(items) > limit {
+			listed = items[:limit]
+		}
+		for _, m := range listed {
+			indent := "    "
+			if m.Group != "" {
+				if !seen[m.Group] {
+					seen[m.Group] = true
+					fmt.Fprintf(w, "    Burst %s (%d files)\n", m.Group, burstSize[m.Group])
+				}
+				indent = "      "
+			}
+			note := ""
+			if m.Live {
+				note = " (live)"
+			}
+			if manual[m.SourcePath] {
+				note += " (manual)"
+			}
+			verb := moveVerb(m, dryRun)
+			switch {
+			case m.Skipped:
+				fmt.Fprintf(w, "%sSkipped %s%s (%s)\n", indent, filepath.Base(m.SourcePath), note, m.Reason)
+			case m.Overwrote:
+				fmt.Fprintf(w, "%s%s %s%s → %s (overwrites existing file)\n", indent, verb, filepath.Base(m.SourcePath), note, m.DestPath)
+			default:
+				fmt.Fprintf(w, "%s%s %s%s → %s\n", indent, verb, filepath.Base(m.SourcePath), note, m.DestPath)
+			}
+			if m.Secondary != "" {
+				fmt.Fprintf(w, "%s  also in %s/ → %s\n", indent, m.Secondary, m.SecondaryPath)
+			}
+		}
+		if rest := items[len(listed):]; len(rest) > 0 {
+			skipped := 0
+			for _, m := range rest {
+				if m.Skipped {
+					skipped++
+				}
+			}
+			if skipped > 0 {
+				fmt.Fprintf(w, "    … and %d more, %d of them skipped\n", len(rest), skipped)
+			} else {
+				fmt.Fprintf(w, "    … and %d more\n", len(rest))
+			}
 		}
 	}
 	fmt.Fprintln(w)
 }
+
+// PrintStats writes the summary of a run that kept only counts, such as a
+// streamed one, without listing each file.
+func PrintStats(w io.Writer, s Stats) {
+	printCounts(w, s)
+	fmt.Fprintf(w, "Categories:          %d\n", s.Categories)
+	if s.Disappeared > 0 {
+		fmt.Fprintf(w, "Sources disappeared: %d\n", s.Disappeared)
+	}
+	verb := "Files moved:        "
+	if s.DryRun {
+		verb = "Files to move:      "
+	}
+	fmt.Fprintf(w, "%s %d\n", verb, s.Moved)
+	fmt.Fprintf(w, "Left in place:       %d\n", s.Left)
+	fmt.Fprintf(w, "Failed:              %d\n", s.Failed)
+	printDates(w, s)
+	fmt.Fprintln(w)
+}
+
+// printCounts writes the heading and image counts that start a summary.
+func printCounts(w io.Writer, s Stats) {
+	fmt.Fprintln(w)
+	if s.DryRun {
+		fmt.Fprintln(w, "=== Dry Run Summary ===")
+	} else {
+		fmt.Fprintln(w, "=== Summary ===")
+	}
+	fmt.Fprintf(w, "Images found:        %d\n", s.Images)
+	fmt.Fprintf(w, "Images categorized:  %d\n", s.Categorized)
+	fmt.Fprintf(w, "Images skipped:      %d\n", s.Skipped)
+	if s.NonImage > 0 {
+		fmt.Fprintf(w, "Non-image files:     %d\n", s.NonImage)
+	}
+}
+
+// moveVerb describes what happened to m's file, such as "Moved" or, in a
+// dry run of copies, "Would copy".
+func moveVerb(m mover.MoveResult, dryRun bool) string {
+	switch {
+	case m.Copied && dryRun:
+		return "Would copy"
+	case m.Copied:
+		return "Copied"
+	case dryRun:
+		return "Would move"
+	}
+	return "Moved"
+}
+
+// printDates reports the files whose dates were implausible, if any.
+func printDates(w io.Writer, s Stats) {
+	if s.DateFallbacks > 0 {
+		fmt.Fprintf(w, "%d files had implausible dates; used fallback\n", s.DateFallbacks)
+	}
+	if s.DatesUnsorted > 0 {
+		fmt.Fprintf(w, "%d files had implausible dates; moved to %s/\n", s.DatesUnsorted, mover.UnsortedDir)
+	}
+}
+
+// tierCount returns how many of moves are in tier and were not skipped.
+func tierCount(moves []mover.MoveResult, tier string) int {
+	n := 0
+	for _, m := range moves {
+		if m.Tier == tier && !m.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// CategorySizes returns the total bytes moved into each category. Skipped
+// files are not counted.
+func CategorySizes(moves []mover.MoveResult) map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, m := range moves {
+		if !m.Skipped {
+			sizes[m.Category] += m.Size
+		}
+	}
+	return sizes
+}
+
+// PrintTiming writes how long classification took overall and per image,
+// so a dry run doubles as an estimate for the real run. The model's
+// warm-up, if any, is reported on its own so it does not inflate the rate.
+func PrintTiming(w io.Writer, images int, elapsed, warmup time.Duration) {
+	if images == 0 {
+		return
+	}
+	per := elapsed / time.Duration(images)
+	warm := ""
+	if warmup > 0 {
+		warm = fmt.Sprintf(", after a %s model warm-up", warmup.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "Classified %d images in %s (%s per image%s); a real run takes about as long unless it reuses --scores-in.\n",
+		images, elapsed.Round(time.Millisecond), per.Round(time.Millisecond), warm)
+}
+
+// PrintEstimate writes the expected duration of a run over images files.
+func PrintEstimate(w io.Writer, images int, total time.Duration) {
+	fmt.Fprintf(w, "Estimated %s for %d images at current settings\n", ApproxDuration(total), images)
+}
+
+// ApproxDuration renders d for humans: "less than a minute", "about 42
+// minutes", or "about 3 hours 5 minutes".
+func ApproxDuration(d time.Duration) string {
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	switch {
+	case d < 30*time.Second:
+		return "less than a minute"
+	case minutes == 1:
+		return "about 1 minute"
+	case minutes < 60:
+		return fmt.Sprintf("about %d minutes", minutes)
+	}
+
+	hours, minutes := minutes/60, minutes%60
+	unit := "hours"
+	if hours == 1 {
+		unit = "hour"
+	}
+	if minutes == 0 {
+		return fmt.Sprintf("about %d %s", hours, unit)
+	}
+	return fmt.Sprintf("about %d %s %d minutes", hours, unit, minutes)
+}
@@ -2,8 +2,11 @@ package report
 
 import (
 	"bytes"
+	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bagtoad/imgsort/internal/categorizer"
 	"github.com/bagtoad/imgsort/internal/mover"
@@ -22,7 +25,7 @@ func TestPrintReport(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	Print(&buf, results, moves, 5, false)
+	Print(&buf, results, moves, 5, false, 0)
 
 	output := buf.String()
 
@@ -44,6 +47,82 @@ func TestPrintReport(t *testing.T) {
 	}
 }
 
+func TestPrintReportSourceDisappeared(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "/imgs/beach.jpg", Category: "landscape", Confidence: 0.8},
+		{Path: "/imgs/gone.jpg", Category: "landscape", Confidence: 0.7},
+	}
+	moves := []mover.MoveResult{
+		{SourcePath: "/imgs/beach.jpg", DestPath: "/imgs/landscape/beach.jpg", Category: "landscape"},
+		{SourcePath: "/imgs/gone.jpg", DestPath: "/imgs/landscape/gone.jpg", Category: "landscape",
+			Skipped: true, Reason: mover.ReasonSourceDisappeared},
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, results, moves, 0, false, 0)
+	for _, check := range []string{"Sources disappeared: 1", "Skipped gone.jpg (source disappeared)"} {
+		if !strings.Contains(buf.String(), check) {
+			t.Errorf("report missing %q\nFull output:\n%s", check, buf.String())
+		}
+	}
+}
+
+func TestPrintReportImplausibleDates(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "/imgs/old.jpg", Category: "landscape", Confidence: 0.8},
+		{Path: "/imgs/epoch.jpg", Category: "landscape", Confidence: 0.7},
+	}
+	moves := []mover.MoveResult{
+		{SourcePath: "/imgs/old.jpg", DestPath: "/imgs/landscape/2023/07/old.jpg", Category: "landscape",
+			ImplausibleDate: true},
+		{SourcePath: "/imgs/epoch.jpg", DestPath: "/imgs/unsorted/epoch.jpg", Category: mover.UnsortedDir,
+			ImplausibleDate: true, DateUnsorted: true},
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, results, moves, 0, false, 0)
+	for _, check := range []string{
+		"1 files had implausible dates; used fallback",
+		"1 files had implausible dates; moved to unsorted/",
+	} {
+		if !strings.Contains(buf.String(), check) {
+			t.Errorf("report missing %q\nFull output:\n%s", check, buf.String())
+		}
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	s := Stats{Moved: 231, Categories: 9, Left: 47, Failed: 3}
+	if got, want := SummaryLine(s, 2*time.Minute+14*time.Second+300*time.Millisecond),
+		"imgsort: 231 moved into 9 categories, 47 skipped, 3 failed (2m14s)"; got != want {
+		t.Errorf("SummaryLine = %q, want %q", got, want)
+	}
+	s.DryRun = true
+	if got, want := SummaryLine(s, 0), "imgsort: 231 would move into 9 categories, 47 skipped, 3 failed (0s)"; got != want {
+		t.Errorf("SummaryLine = %q, want %q", got, want)
+	}
+}
+
+func TestNewStats(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "/imgs/beach.jpg", Category: "landscape", Confidence: 0.8},
+		{Path: "/imgs/dup.jpg", Category: "landscape", Confidence: 0.6},
+		{Path: "/imgs/gone.jpg", Category: "animals", Confidence: 0.7},
+		{Path: "/imgs/blur.jpg", Skipped: true, SkipReason: categorizer.SkipThreshold},
+		{Path: "/imgs/broken.jpg", Skipped: true, SkipReason: categorizer.SkipError},
+	}
+	moves := []mover.MoveResult{
+		{SourcePath: "/imgs/beach.jpg", Category: "landscape"},
+		{SourcePath: "/imgs/dup.jpg", Category: "landscape", Skipped: true, Reason: "destination exists"},
+		{SourcePath: "/imgs/gone.jpg", Category: "animals", Skipped: true, Reason: mover.ReasonSourceDisappeared},
+	}
+	want := Stats{Images: 5, Categorized: 3, Skipped: 2, NonImage: 1, Categories: 2,
+		Moved: 1, Left: 2, Failed: 2, Disappeared: 1}
+	if got := NewStats(results, moves, 1, false); got != want {
+		t.Errorf("NewStats = %+v, want %+v", got, want)
+	}
+}
+
 func TestPrintReportDryRun(t *testing.T) {
 	results := []categorizer.Result{
 		{Path: "/imgs/beach.jpg", Category: "landscape", Confidence: 0.8},
@@ -54,7 +133,7 @@ func TestPrintReportDryRun(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	Print(&buf, results, moves, 0, true)
+	Print(&buf, results, moves, 0, true, 0)
 
 	output := buf.String()
 
@@ -66,12 +145,176 @@ func TestPrintReportDryRun(t *testing.T) {
 	}
 }
 
+func TestPrintReportCopied(t *testing.T) {
+	results := []categorizer.Result{{Path: "/imgs/beach.jpg", Category: "landscape", Confidence: 0.8}}
+	moves := []mover.MoveResult{
+		{SourcePath: "/imgs/beach.jpg", DestPath: "/sorted/landscape/beach.jpg", Category: "landscape", Copied: true},
+	}
+	for dryRun, want := range map[bool]string{false: "Copied beach.jpg", true: "Would copy beach.jpg"} {
+		var buf bytes.Buffer
+		Print(&buf, results, moves, 0, dryRun, 0)
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q\nFull output:\n%s", want, buf.String())
+		}
+	}
+}
+
 func TestPrintReportEmpty(t *testing.T) {
 	var buf bytes.Buffer
-	Print(&buf, nil, nil, 0, false)
+	Print(&buf, nil, nil, 0, false, 0)
 
 	output := buf.String()
 	if !strings.Contains(output, "No files to move") {
 		t.Errorf("expected empty message in output:\n%s", output)
 	}
 }
+
+func TestPrintReportBursts(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "/imgs/IMG_1.jpg", Category: "ocean", Group: "IMG_1.jpg"},
+		{Path: "/imgs/IMG_2.jpg", Category: "ocean", Group: "IMG_1.jpg"},
+	}
+	moves := []mover.MoveResult{
+		{SourcePath: "/imgs/IMG_1.jpg", DestPath: "/imgs/ocean/IMG_1.jpg", Category: "ocean", Group: "IMG_1.jpg"},
+		{SourcePath: "/imgs/IMG_2.jpg", DestPath: "/imgs/ocean/IMG_2.jpg", Category: "ocean", Group: "IMG_1.jpg"},
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, results, moves, 0, false, 0)
+
+	output := buf.String()
+	if strings.Count(output, "Burst IMG_1.jpg (2 files)") != 1 {
+		t.Errorf("expected a single burst heading in output:\n%s", output)
+	}
+}
+
+func TestPrintReportTiers(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "/imgs/a.jpg", Category: "beach", Confidence: 0.8, Tier: categorizer.TierConfident},
+		{Path: "/imgs/b.jpg", Category: "beach", Confidence: 0.2, Tier: categorizer.TierTentative},
+		{Path: "/imgs/c.jpg", Category: "city", Confidence: 0.2, Tier: categorizer.TierTentative},
+	}
+	moves := []mover.MoveResult{
+		{SourcePath: "/imgs/a.jpg", DestPath: "/imgs/beach/a.jpg", Category: "beach", Tier: categorizer.TierConfident},
+		{SourcePath: "/imgs/b.jpg", DestPath: "/imgs/beach/_tentative/b.jpg", Category: "beach", Tier: categorizer.TierTentative},
+		{SourcePath: "/imgs/c.jpg", DestPath: "/imgs/city/_tentative/c.jpg", Category: "city", Tier: categorizer.TierTentative},
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, results, moves, 0, false, 0)
+	for _, want := range []string{"beach/ (2 files, 1 tentative)", "city/ (1 files, 1 tentative)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in output:\n%s", want, buf.String())
+		}
+	}
+
+	rep := NewRunReport("/imgs", results, moves, nil, 0.4, false)
+	want := map[string]TierCounts{"beach": {Confident: 1, Tentative: 1}, "city": {Tentative: 1}}
+	if len(rep.Tiers) != len(want) || rep.Tiers["beach"] != want["beach"] || rep.Tiers["city"] != want["city"] {
+		t.Errorf("tiers = %v, want %v", rep.Tiers, want)
+	}
+	if rep.Files[1].Tier != categorizer.TierTentative {
+		t.Errorf("expected the file entry to carry its tier, got %+v", rep.Files[1])
+	}
+}
+
+func TestCategorySizes(t *testing.T) {
+	moves := []mover.MoveResult{
+		{Category: "landscape", Size: 3 << 20},
+		{Category: "landscape", Size: 1 << 20},
+		{Category: "animals", Size: 512},
+		{Category: "animals", Size: 4096, Skipped: true},
+	}
+
+	sizes := CategorySizes(moves)
+	if sizes["landscape"] != 4<<20 || sizes["animals"] != 512 {
+		t.Errorf("unexpected sizes: %v", sizes)
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, []categorizer.Result{{Category: "landscape"}}, moves, 0, true, 0)
+	for _, want := range []string{"landscape/ (2 files, 4.0 MiB)", "animals/ (2 files, 512 B)", "Data to move:        4.0 MiB"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("dry run report missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintTiming(t *testing.T) {
+	var buf bytes.Buffer
+	PrintTiming(&buf, 4, 2*time.Second, 0)
+	if !strings.Contains(buf.String(), "Classified 4 images in 2s (500ms per image)") {
+		t.Errorf("unexpected timing output: %s", buf.String())
+	}
+
+	buf.Reset()
+	PrintTiming(&buf, 4, 2*time.Second, 1500*time.Millisecond)
+	if !strings.Contains(buf.String(), "Classified 4 images in 2s (500ms per image, after a 1.5s model warm-up)") {
+		t.Errorf("warm-up should be reported apart from the per-image rate: %s", buf.String())
+	}
+}
+
+func TestApproxDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{10 * time.Second, "less than a minute"},
+		{50 * time.Second, "about 1 minute"},
+		{42*time.Minute + 10*time.Second, "about 42 minutes"},
+		{60 * time.Minute, "about 1 hour"},
+		{185 * time.Minute, "about 3 hours 5 minutes"},
+	}
+	for _, tt := range tests {
+		if got := ApproxDuration(tt.d); got != tt.want {
+			t.Errorf("ApproxDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	PrintEstimate(&buf, 18230, 42*time.Minute)
+	if !strings.Contains(buf.String(), "Estimated about 42 minutes for 18230 images at current settings") {
+		t.Errorf("unexpected estimate output: %s", buf.String())
+	}
+}
+
+func TestPrintReportLimit(t *testing.T) {
+	var results []categorizer.Result
+	var moves []mover.MoveResult
+	for i := 0; i < 30; i++ {
+		src := fmt.Sprintf("/imgs/beach%02d.jpg", i)
+		results = append(results, categorizer.Result{Path: src, Category: "landscape", Confidence: 0.8})
+		moves = append(moves, mover.MoveResult{SourcePath: src, DestPath: "/imgs/landscape/" + filepath.Base(src), Category: "landscape", Skipped: i >= 28, Reason: "failed"})
+	}
+	moves = append(moves, mover.MoveResult{SourcePath: "/imgs/cat.png", DestPath: "/imgs/animals/cat.png", Category: "animals"})
+	results = append(results, categorizer.Result{Path: "/imgs/cat.png", Category: "animals", Confidence: 0.9})
+
+	var full, limited bytes.Buffer
+	Print(&full, results, moves, 0, false, 0)
+	Print(&limited, results, moves, 0, false, 20)
+
+	out := limited.String()
+	if n := strings.Count(out, "Moved "); n != 20+1 {
+		t.Errorf("expected 21 listed moves, got %d\n%s", n, out)
+	}
+	if !strings.Contains(out, "    … and 10 more, 2 of them skipped\n") {
+		t.Errorf("expected a line counting the files not listed\n%s", out)
+	}
+	if strings.Contains(out, "beach20.jpg") || !strings.Contains(out, "beach19.jpg") {
+		t.Errorf("expected the first 20 files listed\n%s", out)
+	}
+	if strings.Contains(full.String(), "more") || !strings.Contains(full.String(), "beach29.jpg") {
+		t.Errorf("limit 0 should list every file\n%s", full.String())
+	}
+
+	// The limit only shortens the listing; the counts stay the same.
+	head := func(s string) string { return s[:strings.Index(s, "  animals/")] }
+	if head(out) != head(full.String()) {
+		t.Errorf("limit changed the summary:\n%s\nwant:\n%s", head(out), head(full.String()))
+	}
+	for _, check := range []string{"Images categorized:  31", "landscape/ (30 files)", "animals/ (1 files)"} {
+		if !strings.Contains(out, check) {
+			t.Errorf("report missing %q\n%s", check, out)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// ScanSummary describes what a sorting run would process, without running
+// the model.
+type ScanSummary struct {
+	Header
+	Dir        string         `json:"dir"`
+	Files      []string       `json:"files"`
+	Extensions map[string]int `json:"extensions"`
+	TotalBytes int64          `json:"total_bytes"`
+
+	// Excluded counts entries left out by each filter.
+	Excluded map[string]int `json:"excluded"`
+
+	// Unreadable lists the paths that could not be read.
+	Unreadable []string `json:"unreadable,omitempty"`
+}
+
+// NewScanSummary builds a ScanSummary from a scan result.
+func NewScanSummary(dir string, r *scanner.Result) ScanSummary {
+	s := ScanSummary{
+		Dir:        dir,
+		Files:      []string{},
+		Extensions: make(map[string]int),
+		TotalBytes: r.ImageBytes,
+		Excluded: map[string]int{
+			"non_image":   r.SkippedCount,
+			"hidden":      r.HiddenCount,
+			"directories": r.DirCount,
+			"unreadable":  len(r.Unreadable),
+		},
+		Unreadable: r.Unreadable,
+	}
+	for _, p := range r.ImagePaths {
+		s.Files = append(s.Files, p)
+		s.Extensions[strings.ToLower(filepath.Ext(p))]++
+	}
+	return s
+}
+
+// PrintScan writes a human-readable scan summary to w.
+func PrintScan(w io.Writer, s ScanSummary) {
+	for _, f := range s.Files {
+		fmt.Fprintf(w, "  %s\n", filepath.Base(f))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "=== Scan Summary ===")
+	fmt.Fprintf(w, "Images found:        %d (%s)\n", len(s.Files), formatBytes(s.TotalBytes))
+
+	exts := make([]string, 0, len(s.Extensions))
+	for ext := range s.Extensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		fmt.Fprintf(w, "  %-18s %d\n", ext, s.Extensions[ext])
+	}
+
+	fmt.Fprintf(w, "Non-image files:     %d\n", s.Excluded["non_image"])
+	fmt.Fprintf(w, "Hidden files:        %d\n", s.Excluded["hidden"])
+	fmt.Fprintf(w, "Subdirectories:      %d\n", s.Excluded["directories"])
+	PrintUnreadable(w, s.Unreadable)
+}
+
+// PrintUnreadable writes the number of paths that could not be read, and
+// the paths themselves, to w. It writes nothing when paths is empty.
+func PrintUnreadable(w io.Writer, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Unreadable:          %d (permission denied or I/O error; not sorted)\n", len(paths))
+	for _, p := range paths {
+		fmt.Fprintf(w, "  %s\n", p)
+	}
+}
+
+// WriteScanJSON writes the scan summary as indented JSON to w.
+func WriteScanJSON(w io.Writer, s ScanSummary) error {
+	s.Header = NewHeader()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// formatBytes renders a byte count with a binary unit suffix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
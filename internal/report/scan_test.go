@@ -0,0 +1,120 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// writeMixedFixture creates a directory with images, non-images, hidden
+// files, and a subdirectory.
+func writeMixedFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.jpg":       "12345",
+		"b.JPG":       "123",
+		"c.png":       "12",
+		"notes.txt":   "hello",
+		".hidden.jpg": "x",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestScanSummary(t *testing.T) {
+	dir := writeMixedFixture(t)
+	result, err := scanner.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScanSummary(dir, result)
+	if len(s.Files) != 3 {
+		t.Errorf("expected 3 files, got %v", s.Files)
+	}
+	if s.Extensions[".jpg"] != 2 || s.Extensions[".png"] != 1 {
+		t.Errorf("unexpected extension counts: %v", s.Extensions)
+	}
+	if s.TotalBytes != 10 {
+		t.Errorf("expected 10 bytes, got %d", s.TotalBytes)
+	}
+	if s.Excluded["non_image"] != 1 || s.Excluded["hidden"] != 1 || s.Excluded["directories"] != 1 {
+		t.Errorf("unexpected exclusion counts: %v", s.Excluded)
+	}
+
+	var buf bytes.Buffer
+	PrintScan(&buf, s)
+	for _, check := range []string{"Images found:        3 (10 B)", "Non-image files:     1", "Hidden files:        1"} {
+		if !strings.Contains(buf.String(), check) {
+			t.Errorf("scan output missing %q\nFull output:\n%s", check, buf.String())
+		}
+	}
+}
+
+func TestWriteScanJSON(t *testing.T) {
+	dir := writeMixedFixture(t)
+	result, err := scanner.Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteScanJSON(&buf, NewScanSummary(dir, result)); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded ScanSummary
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(decoded.Files) != 3 || decoded.TotalBytes != 10 {
+		t.Errorf("unexpected decoded summary: %+v", decoded)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1536:        "1.5 KiB",
+		5 * 1 << 20: "5.0 MiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPrintUnreadable(t *testing.T) {
+	var buf bytes.Buffer
+	PrintUnreadable(&buf, nil)
+	if buf.Len() != 0 {
+		t.Errorf("nothing should be printed without unreadable paths, got %q", buf.String())
+	}
+
+	s := NewScanSummary("/photos", &scanner.Result{
+		ImagePaths: []string{"/photos/a.jpg"},
+		Unreadable: []string{"/photos/private"},
+	})
+	if s.Excluded["unreadable"] != 1 {
+		t.Errorf("expected 1 unreadable, got %v", s.Excluded)
+	}
+	PrintScan(&buf, s)
+	if !strings.Contains(buf.String(), "Unreadable:          1") || !strings.Contains(buf.String(), "  /photos/private\n") {
+		t.Errorf("scan summary should list unreadable paths:\n%s", buf.String())
+	}
+}
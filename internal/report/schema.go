@@ -0,0 +1,24 @@
+package report
+
+import "github.com/bagtoad/imgsort/internal/buildinfo"
+
+// SchemaVersion identifies the format of the JSON reports: RunReport,
+// ScanSummary, PlanDiff, TreeDiff, LibraryStats, and AnonymousStats.
+// Fields are only ever added within a version; renaming or removing one,
+// in any of them, bumps it.
+//
+// Version 2 renamed the run report's "version" to "schema_version" and
+// added "imgsort_version".
+const SchemaVersion = 2
+
+// Header starts every JSON report, so scripts can check the format before
+// relying on it.
+type Header struct {
+	SchemaVersion  int    `json:"schema_version"`
+	ImgsortVersion string `json:"imgsort_version"`
+}
+
+// NewHeader returns the header for reports written by this binary.
+func NewHeader() Header {
+	return Header{SchemaVersion: SchemaVersion, ImgsortVersion: buildinfo.Get().Version}
+}
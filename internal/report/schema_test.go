@@ -0,0 +1,96 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// schemaKeys lists the JSON keys of each report at each SchemaVersion.
+// Adding a field means adding its key to the current version's list.
+// Renaming or removing one means bumping SchemaVersion and adding a new
+// list; the lists of earlier versions are never edited, since scripts
+// written against them rely on every key they name.
+var schemaKeys = map[int]map[string][]string{
+	1: {
+		"RunReport":   {"dir", "dry_run", "files", "threshold", "version"},
+		"ScanSummary": {"dir", "excluded", "extensions", "files", "total_bytes", "unreadable"},
+		"PlanDiff":    {"changes", "unchanged"},
+		"PlanChange":  {"category", "confidence", "kind", "old_category", "old_confidence", "old_path", "path", "reason"},
+	},
+	2: {
+		"RunReport":     {"confusion", "dir", "dry_run", "files", "imgsort_version", "schema_version", "threshold"},
+		"ConfusionPair": {"count", "runner_up", "winner"},
+		"ScanSummary":   {"dir", "excluded", "extensions", "files", "imgsort_version", "schema_version", "total_bytes", "unreadable"},
+		"PlanDiff":      {"changes", "imgsort_version", "schema_version", "unchanged"},
+		"PlanChange":    {"category", "confidence", "kind", "old_category", "old_confidence", "old_path", "path", "reason"},
+	},
+}
+
+// TestSchemaCompatibility fails when a report's JSON keys change without
+// SchemaVersion and schemaKeys being updated to match.
+func TestSchemaCompatibility(t *testing.T) {
+	want, ok := schemaKeys[SchemaVersion]
+	if !ok {
+		t.Fatalf("schemaKeys has no entry for SchemaVersion %d", SchemaVersion)
+	}
+	got := map[string][]string{
+		"RunReport":     jsonKeys(t, RunReport{Confusion: []ConfusionPair{{}}}),
+		"ConfusionPair": jsonKeys(t, ConfusionPair{}),
+		"ScanSummary":   jsonKeys(t, ScanSummary{Unreadable: []string{"x"}}),
+		"PlanDiff":      jsonKeys(t, PlanDiff{}),
+		"PlanChange": jsonKeys(t, PlanChange{
+			Kind: "k", Path: "p", OldPath: "o", OldCategory: "c", OldConfidence: 1, Category: "c", Confidence: 1, Reason: "r",
+		}),
+	}
+	for name, keys := range got {
+		if !slices.Equal(keys, want[name]) {
+			t.Errorf("%s keys = %v, schema version %d lists %v", name, keys, SchemaVersion, want[name])
+		}
+	}
+
+}
+
+func TestWritersStampHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteScanJSON(&buf, ScanSummary{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePlanDiffJSON(&buf, PlanDiff{}); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	for range 2 {
+		var h Header
+		if err := dec.Decode(&h); err != nil {
+			t.Fatal(err)
+		}
+		if h.SchemaVersion != SchemaVersion || h.ImgsortVersion == "" {
+			t.Errorf("expected a stamped header, got %+v", h)
+		}
+	}
+}
+
+func TestRunReportConfusion(t *testing.T) {
+	results := []categorizer.Result{
+		{Path: "a.jpg", Category: "beach", RunnerUp: "ocean"},
+		{Path: "b.jpg", Category: "beach", RunnerUp: "ocean"},
+		{Path: "c.jpg", Skipped: true},
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(NewRunReport("dir", results, nil, nil, 0.15, false)); err != nil {
+		t.Fatal(err)
+	}
+	var rep struct {
+		Confusion []ConfusionPair `json:"confusion"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if want := []ConfusionPair{{Winner: "beach", RunnerUp: "ocean", Count: 2}}; !slices.Equal(rep.Confusion, want) {
+		t.Errorf("expected confusion %v in the JSON report, got %v", want, rep.Confusion)
+	}
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/mover"
+)
+
+// WriteScript writes a POSIX shell script that carries out the planned
+// moves of a dry run over dir: a mkdir -p for each destination folder
+// before its first file, then a move for each file and its companions.
+// Skipped moves are listed as comments. Paths are made absolute, so the
+// script works from any directory. The script stops at the first
+// command that fails, and replaces an existing file only where the plan
+// overwrites it.
+func WriteScript(w io.Writer, dir string, moves []mover.MoveResult) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#!/bin/sh")
+	fmt.Fprintf(bw, "# Moves planned by imgsort for %s.\n", commentSafe(absPath(dir)))
+	fmt.Fprintln(bw, "# Review before running. Stops at the first failure.")
+	fmt.Fprintln(bw, "set -e")
+	fmt.Fprint(bw, scriptMove)
+
+	made := make(map[string]bool)
+	mv := func(src, dest string, overwrite bool) {
+		src, dest = absPath(src), absPath(dest)
+		if d := filepath.Dir(dest); !made[d] {
+			made[d] = true
+			fmt.Fprintf(bw, "mkdir -p -- %s\n", shellQuote(d))
+		}
+		cmd := "move"
+		if overwrite {
+			cmd = "mv -f --"
+		}
+		fmt.Fprintf(bw, "%s %s %s\n", cmd, shellQuote(src), shellQuote(dest))
+	}
+	for _, m := range moves {
+		if m.Skipped {
+			fmt.Fprintf(bw, "# skipped %s (%s)\n", commentSafe(m.SourcePath), m.Reason)
+			continue
+		}
+		mv(m.SourcePath, m.DestPath, m.Overwrote)
+		for _, c := range m.Companions {
+			mv(c.SourcePath, c.DestPath, m.Overwrote)
+		}
+	}
+	return bw.Flush()
+}
+
+// scriptMove defines the script's move command, which refuses to replace
+// a file that appeared since the plan was made.
+const scriptMove = `move() {
+	if [ -e "$2" ] || [ -L "$2" ]; then
+		echo "$2 already exists; not replacing it" >&2
+		exit 1
+	fi
+	mv -- "$1" "$2"
+}
+`
+
+// shellQuote quotes s as a single shell word. Inside single quotes nothing
+// is special except the quote itself, which is closed, escaped, and reopened.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// absPath returns path made absolute, or path itself if that fails.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// commentSafe keeps a path on one comment line.
+func commentSafe(s string) string {
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`).Replace(s)
+}
@@ -0,0 +1,145 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/mover"
+)
+
+// shellWords splits a script line into words, undoing shellQuote. It
+// handles only the words WriteScript writes: plain, single-quoted, and
+// backslash-escaped.
+func shellWords(t *testing.T, line string) []string {
+	t.Helper()
+	var words []string
+	var cur strings.Builder
+	inWord, quoted, escaped := false, false, false
+	for _, r := range line {
+		switch {
+		case quoted && r == '\'':
+			quoted = false
+		case quoted, escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\'':
+			quoted, inWord = true, true
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == ' ':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		t.Fatalf("unterminated quote in %q", line)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
+
+func TestWriteScript(t *testing.T) {
+	moves := []mover.MoveResult{
+		{SourcePath: "/p/my photo.jpg", DestPath: "/p/beach/my photo.jpg", Category: "beach"},
+		{SourcePath: "/p/it's $HOME `x`.jpg", DestPath: "/p/beach/it's $HOME `x`.jpg", Category: "beach"},
+		{SourcePath: "/p/IMG_1.jpg", DestPath: "/p/city/IMG_1.jpg", Category: "city", Live: true,
+			Companions: []mover.Companion{{SourcePath: "/p/IMG_1.mov", DestPath: "/p/city/IMG_1.mov"}}},
+		{SourcePath: "/p/dup.jpg", DestPath: "/p/city/dup.jpg", Category: "city", Skipped: true, Reason: "destination exists"},
+	}
+	var buf bytes.Buffer
+	if err := WriteScript(&buf, "/p", moves); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotMoves [][2]string
+	var gotDirs []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "move ") && !strings.HasPrefix(line, "mkdir ") {
+			continue
+		}
+		w := shellWords(t, line)
+		switch w[0] {
+		case "mkdir":
+			gotDirs = append(gotDirs, w[len(w)-1])
+		case "move":
+			if len(w) != 3 {
+				t.Fatalf("move line %q has %d words", line, len(w))
+			}
+			gotMoves = append(gotMoves, [2]string{w[1], w[2]})
+		}
+	}
+	wantMoves := [][2]string{
+		{"/p/my photo.jpg", "/p/beach/my photo.jpg"},
+		{"/p/it's $HOME `x`.jpg", "/p/beach/it's $HOME `x`.jpg"},
+		{"/p/IMG_1.jpg", "/p/city/IMG_1.jpg"},
+		{"/p/IMG_1.mov", "/p/city/IMG_1.mov"},
+	}
+	if !slices.Equal(gotMoves, wantMoves) {
+		t.Errorf("moves = %q, want %q", gotMoves, wantMoves)
+	}
+	if want := []string{"/p/beach", "/p/city"}; !slices.Equal(gotDirs, want) {
+		t.Errorf("mkdirs = %q, want %q", gotDirs, want)
+	}
+	if !strings.Contains(buf.String(), "# skipped /p/dup.jpg (destination exists)") {
+		t.Errorf("skipped move not listed:\n%s", buf.String())
+	}
+}
+
+func TestWriteScriptRuns(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	dir := t.TempDir()
+	names := []string{"my photo.jpg", "it's $HOME.jpg"}
+	var moves []mover.MoveResult
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		moves = append(moves, mover.MoveResult{
+			SourcePath: filepath.Join(dir, name), DestPath: filepath.Join(dir, "beach", name), Category: "beach",
+		})
+	}
+	script := filepath.Join(t.TempDir(), "moves.sh")
+	var buf bytes.Buffer
+	if err := WriteScript(&buf, dir, moves); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, buf.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := exec.Command(sh, script).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, out)
+	}
+	for _, name := range names {
+		if data, err := os.ReadFile(filepath.Join(dir, "beach", name)); err != nil || string(data) != name {
+			t.Errorf("%s not moved: %v", name, err)
+		}
+	}
+
+	// Running it again fails rather than touching anything.
+	if err := os.WriteFile(filepath.Join(dir, names[0]), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command(sh, script).Run(); err == nil {
+		t.Error("expected a second run to stop at the existing destination")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "beach", names[0])); string(data) != names[0] {
+		t.Error("existing destination was replaced")
+	}
+}
@@ -0,0 +1,124 @@
+package report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/mover"
+)
+
+// Stats are the counts a run's report is built from, so the full report
+// and the one-line summary cannot disagree.
+type Stats struct {
+	DryRun bool
+
+	// Images is how many images were classified; Categorized and Skipped
+	// split them by whether they matched a category. NonImage counts the
+	// other files the scan passed over.
+	Images      int
+	Categorized int
+	Skipped     int
+	NonImage    int
+
+	// Categories is how many categories the run planned moves into.
+	Categories int
+
+	// Every image ends up in exactly one of Moved, Left, and Failed: moved
+	// (or, in a dry run, would be), left in place, or not moved because it
+	// could not be classified or its source disappeared. Disappeared counts
+	// the latter alone.
+	Moved       int
+	Left        int
+	Failed      int
+	Disappeared int
+
+	// DateFallbacks counts files dated by a later source because an earlier
+	// one was implausible, and DatesUnsorted the files moved to the unsorted
+	// folder instead under strict dates.
+	DateFallbacks int
+	DatesUnsorted int
+}
+
+// NewStats counts a run's results and moves.
+func NewStats(results []categorizer.Result, moves []mover.MoveResult, skippedNonImage int, dryRun bool) Stats {
+	bySource := make(map[string][]mover.MoveResult, len(moves))
+	for _, m := range moves {
+		bySource[m.SourcePath] = append(bySource[m.SourcePath], m)
+	}
+	t := NewTally(dryRun)
+	for _, r := range results {
+		t.Add(r, bySource[r.Path])
+	}
+	t.AddNonImage(skippedNonImage)
+	return t.Stats()
+}
+
+// Tally builds Stats one image at a time, for runs that do not keep every
+// result. It holds only the counts and the names of the categories seen.
+type Tally struct {
+	stats Stats
+	cats  map[string]bool
+}
+
+// NewTally returns an empty Tally.
+func NewTally(dryRun bool) *Tally {
+	return &Tally{stats: Stats{DryRun: dryRun}, cats: make(map[string]bool)}
+}
+
+// Add counts one image's result and the moves planned for it.
+func (t *Tally) Add(r categorizer.Result, moves []mover.MoveResult) {
+	s := &t.stats
+	s.Images++
+	if r.Skipped {
+		s.Skipped++
+	} else {
+		s.Categorized++
+	}
+
+	var m *mover.MoveResult
+	for i := range moves {
+		t.cats[moves[i].Category] = true
+		m = &moves[i]
+	}
+	switch {
+	case m != nil && m.DateUnsorted:
+		s.DatesUnsorted++
+	case m != nil && m.ImplausibleDate:
+		s.DateFallbacks++
+	}
+	s.Categories = len(t.cats)
+	switch {
+	case m != nil && !m.Skipped:
+		s.Moved++
+	case m != nil && m.Reason == mover.ReasonSourceDisappeared:
+		s.Failed++
+		s.Disappeared++
+	case r.Skipped && (r.SkipReason == categorizer.SkipError || r.SkipReason == categorizer.SkipTimeout):
+		s.Failed++
+	default:
+		s.Left++
+	}
+}
+
+// AddNonImage counts n files the scan passed over.
+func (t *Tally) AddNonImage(n int) {
+	t.stats.NonImage += n
+}
+
+// Stats returns the counts so far.
+func (t *Tally) Stats() Stats {
+	return t.stats
+}
+
+// SummaryLine formats s as one line for notifications and scripts, such as
+// "imgsort: 231 moved into 9 categories, 47 skipped, 3 failed (2m14s)".
+// A dry run says "would move" instead of "moved". The wording is stable.
+func SummaryLine(s Stats, elapsed time.Duration) string {
+	verb := "moved"
+	if s.DryRun {
+		verb = "would move"
+	}
+	return fmt.Sprintf("imgsort: %d %s into %d categories, %d skipped, %d failed (%s)",
+		s.Moved, verb, s.Categories, s.Left, s.Failed, elapsed.Round(time.Second))
+}
@@ -0,0 +1,170 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// Reasons a category is flagged, recorded in Suggestion.Reason.
+const (
+	SuggestNeverWon      = "never_won"
+	SuggestLowConfidence = "low_confidence"
+)
+
+// lowConfidenceMargin is how far above its threshold a category's mean
+// winning confidence must be for its wins to count as clear.
+const lowConfidenceMargin = 0.1
+
+// suggestMinWins is how many images a category must win before its
+// confidence is judged, so one narrow win is not called a pattern.
+const suggestMinWins = 3
+
+// Suggestion flags a category whose name or prompt fits the images poorly.
+type Suggestion struct {
+	Category string
+	Reason   string
+
+	// Wins is how many images the category was assigned, and
+	// MeanConfidence their mean confidence.
+	Wins           int
+	MeanConfidence float32
+
+	// Threshold is the confidence the category needed to win.
+	Threshold float64
+
+	// Closest counts skipped images for which the category came closest.
+	// TopScore and TopImage are its best score on any image, from the full
+	// scores.
+	Closest  int
+	TopScore float32
+	TopImage string
+
+	// RunnerUp is the category most often second when this one won.
+	RunnerUp string
+}
+
+// Suggest finds categories among cats that were never assigned an image,
+// or that won at least suggestMinWins images with a mean confidence less
+// than lowConfidenceMargin above their threshold. Negative categories are
+// left out, since they never win by design, and pinned and overridden
+// images are ignored.
+// Suggestions are sorted with never-won categories first, then by name.
+func Suggest(cats []string, scores []categorizer.ImageScores, results []categorizer.Result, opts categorizer.Options) []Suggestion {
+	type stat struct {
+		wins     int
+		sum      float32
+		closest  int
+		topScore float32
+		topImage string
+		runnerUp map[string]int
+	}
+	stats := make(map[string]*stat, len(cats))
+	for _, c := range cats {
+		if !opts.Rules[c].Negative {
+			stats[c] = &stat{runnerUp: make(map[string]int)}
+		}
+	}
+
+	for _, is := range scores {
+		if is.Pinned != "" || is.Override != "" {
+			continue
+		}
+		for c, s := range stats {
+			if score := is.Scores[c]; score > s.topScore {
+				s.topScore, s.topImage = score, is.Path
+			}
+		}
+	}
+	for _, r := range results {
+		if r.Pinned || r.Overridden {
+			continue
+		}
+		if r.Skipped {
+			if s := stats[r.BestCandidate]; s != nil {
+				s.closest++
+			}
+			continue
+		}
+		if s := stats[r.Category]; s != nil {
+			s.wins++
+			s.sum += r.Confidence
+			if r.RunnerUp != "" {
+				s.runnerUp[r.RunnerUp]++
+			}
+		}
+	}
+
+	var out []Suggestion
+	for c, s := range stats {
+		threshold := opts.Threshold
+		if t := opts.Rules[c].Threshold; t > 0 {
+			threshold = t
+		}
+		sg := Suggestion{
+			Category:  c,
+			Wins:      s.wins,
+			Threshold: threshold,
+			Closest:   s.closest,
+			TopScore:  s.topScore,
+			TopImage:  s.topImage,
+		}
+		switch {
+		case s.wins == 0:
+			sg.Reason = SuggestNeverWon
+		case s.wins >= suggestMinWins && float64(s.sum)/float64(s.wins) < threshold+lowConfidenceMargin:
+			sg.Reason = SuggestLowConfidence
+			sg.MeanConfidence = s.sum / float32(s.wins)
+			for name, n := range s.runnerUp {
+				if n > s.runnerUp[sg.RunnerUp] || (n == s.runnerUp[sg.RunnerUp] && name < sg.RunnerUp) {
+					sg.RunnerUp = name
+				}
+			}
+		default:
+			continue
+		}
+		out = append(out, sg)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Reason != out[j].Reason {
+			return out[i].Reason == SuggestNeverWon
+		}
+		return out[i].Category < out[j].Category
+	})
+	return out
+}
+
+// PrintSuggestions writes each suggestion with a hint on what to change.
+func PrintSuggestions(w io.Writer, suggestions []Suggestion) {
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "=== Category Suggestions ===")
+	for _, s := range suggestions {
+		switch s.Reason {
+		case SuggestNeverWon:
+			fmt.Fprintf(w, "  %s: never won an image", s.Category)
+			if s.TopImage != "" {
+				fmt.Fprintf(w, " (best score %.0f%% on %s)", s.TopScore*100, filepath.Base(s.TopImage))
+			}
+			fmt.Fprintln(w)
+			if s.Closest > 0 {
+				fmt.Fprintf(w, "    came closest on %d skipped images; rename it to describe them, or give it a lower threshold\n", s.Closest)
+			} else {
+				fmt.Fprintf(w, "    no image came close; drop it, or rename it to describe what it should catch\n")
+			}
+		case SuggestLowConfidence:
+			fmt.Fprintf(w, "  %s: won %d images with a mean confidence of %.0f%% (threshold %.0f%%)\n",
+				s.Category, s.Wins, s.MeanConfidence*100, s.Threshold*100)
+			if s.RunnerUp != "" {
+				fmt.Fprintf(w, "    usually just ahead of %s; merge the two, or rename it to something more specific\n", s.RunnerUp)
+			} else {
+				fmt.Fprintf(w, "    rename it, or give it a prompt that describes these photos better\n")
+			}
+		}
+	}
+	fmt.Fprintln(w)
+}
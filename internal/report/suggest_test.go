@@ -0,0 +1,65 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+func TestSuggest(t *testing.T) {
+	cats := []string{"beach", "ocean", "skiing", "receipt", "blurry"}
+	scores := []categorizer.ImageScores{
+		{Path: "/imgs/a.jpg", Scores: map[string]float32{"beach": 0.7, "ocean": 0.2, "skiing": 0.04, "receipt": 0.01}},
+		{Path: "/imgs/b.jpg", Scores: map[string]float32{"beach": 0.2, "ocean": 0.22, "skiing": 0.06, "receipt": 0.02}},
+		{Path: "/imgs/c.jpg", Scores: map[string]float32{"beach": 0.2, "ocean": 0.21, "skiing": 0.03, "receipt": 0.03}},
+		{Path: "/imgs/d.jpg", Scores: map[string]float32{"beach": 0.19, "ocean": 0.23, "skiing": 0.02, "receipt": 0.04}},
+		{Path: "/imgs/e.jpg", Scores: map[string]float32{"beach": 0.01, "ocean": 0.01, "skiing": 0.01, "receipt": 0.12}},
+		{Path: "/imgs/f.jpg", Pinned: "skiing"},
+	}
+	opts := categorizer.Options{
+		Threshold: 0.15,
+		Rules:     map[string]categorizer.Rule{"blurry": {Negative: true}},
+	}
+	results := categorizer.Decide(scores, opts)
+
+	got := Suggest(cats, scores, results, opts)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 suggestions, got %+v", got)
+	}
+	// A pinned image does not count as a win.
+	if s := got[0]; s.Category != "receipt" || s.Reason != SuggestNeverWon || s.Closest != 1 || s.TopImage != "/imgs/e.jpg" {
+		t.Errorf("expected receipt to have come closest once, got %+v", s)
+	}
+	if s := got[1]; s.Category != "skiing" || s.Reason != SuggestNeverWon || s.Closest != 0 || s.TopScore != 0.06 {
+		t.Errorf("expected skiing to never win, got %+v", s)
+	}
+	if s := got[2]; s.Category != "ocean" || s.Reason != SuggestLowConfidence || s.Wins != 3 || s.RunnerUp != "beach" {
+		t.Errorf("expected ocean to win narrowly over beach, got %+v", s)
+	}
+
+	var buf bytes.Buffer
+	PrintSuggestions(&buf, got)
+	out := buf.String()
+	for _, want := range []string{
+		"=== Category Suggestions ===",
+		"receipt: never won an image (best score 12% on e.jpg)",
+		"came closest on 1 skipped images",
+		"skiing: never won an image (best score 6% on b.jpg)",
+		"ocean: won 3 images with a mean confidence of 22% (threshold 15%)",
+		"usually just ahead of beach",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// A category's own threshold decides whether its wins are clear.
+	opts.Rules["ocean"] = categorizer.Rule{Threshold: 0.1}
+	for _, s := range Suggest(cats, scores, categorizer.Decide(scores, opts), opts) {
+		if s.Category == "ocean" {
+			t.Errorf("ocean clears its own threshold by enough, got %+v", s)
+		}
+	}
+}
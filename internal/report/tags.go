@@ -0,0 +1,62 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+// ExportTags is the --export format written by WriteTagsJSON.
+const ExportTags = "tags"
+
+// ExportFormats lists the formats accepted by --export.
+var ExportFormats = []string{ExportTags}
+
+// Tag is one category an image was tagged with.
+type Tag struct {
+	Tag        string  `json:"tag"`
+	Confidence float32 `json:"confidence"`
+}
+
+// Tags returns the tags for each categorized image, keyed by its original
+// absolute path, for photo managers such as Immich or PhotoPrism to import.
+// An image is tagged with its category and, if it scored at least
+// threshold, its runner-up, best first. Skipped images are left out.
+func Tags(results []categorizer.Result, threshold float64) map[string][]Tag {
+	tags := make(map[string][]Tag)
+	for _, r := range results {
+		if r.Skipped || r.Category == "" {
+			continue
+		}
+		path, err := filepath.Abs(r.Path)
+		if err != nil {
+			path = r.Path
+		}
+		t := []Tag{{Tag: r.Category, Confidence: r.Confidence}}
+		if r.RunnerUp != "" && float64(r.RunnerUpConfidence) >= threshold {
+			t = append(t, Tag{Tag: r.RunnerUp, Confidence: r.RunnerUpConfidence})
+		}
+		tags[path] = t
+	}
+	return tags
+}
+
+// WriteTagsJSON writes tags as an indented JSON object mapping each path to
+// its list of tags. Keys are written in sorted order.
+func WriteTagsJSON(w io.Writer, tags map[string][]Tag) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tags)
+}
+
+// CheckExportFormat returns an error if format is not one of ExportFormats.
+func CheckExportFormat(format string) error {
+	if !slices.Contains(ExportFormats, format) {
+		return fmt.Errorf("unknown export format %q (expected one of: %v)", format, ExportFormats)
+	}
+	return nil
+}
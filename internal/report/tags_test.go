@@ -0,0 +1,61 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+)
+
+func TestTagsJSON(t *testing.T) {
+	dir := t.TempDir()
+	results := []categorizer.Result{
+		{Path: filepath.Join(dir, "beach.jpg"), Category: "beach", Confidence: 0.75, RunnerUp: "ocean", RunnerUpConfidence: 0.5},
+		{Path: filepath.Join(dir, "city.jpg"), Category: "city", Confidence: 0.5, RunnerUp: "night", RunnerUpConfidence: 0.125},
+		{Path: filepath.Join(dir, "blurry.jpg"), Skipped: true, SkipReason: categorizer.SkipThreshold},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTagsJSON(&buf, Tags(results, 0.25)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Decode generically so the test pins the exact documented shape.
+	var got map[string][]map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("export is not a map of path to tag list: %v\n%s", err, buf.String())
+	}
+	want := map[string][]map[string]any{
+		filepath.Join(dir, "beach.jpg"): {
+			{"tag": "beach", "confidence": 0.75},
+			{"tag": "ocean", "confidence": 0.5},
+		},
+		filepath.Join(dir, "city.jpg"): {
+			{"tag": "city", "confidence": 0.5},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected export:\n%s", buf.String())
+	}
+}
+
+func TestTagsAbsolutePaths(t *testing.T) {
+	tags := Tags([]categorizer.Result{{Path: "photo.jpg", Category: "beach", Confidence: 0.5}}, 0.15)
+	for path := range tags {
+		if !filepath.IsAbs(path) {
+			t.Errorf("expected an absolute path, got %q", path)
+		}
+	}
+}
+
+func TestCheckExportFormat(t *testing.T) {
+	if err := CheckExportFormat("tags"); err != nil {
+		t.Error(err)
+	}
+	if err := CheckExportFormat("xmp"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,197 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// Kinds of TreeEntry.
+const (
+	TreeIdentical       = "identical"
+	TreeCategoryChanged = "category_changed"
+	TreeOnlyA           = "only_in_a"
+	TreeOnlyB           = "only_in_b"
+)
+
+// TreeFile is an image in a sorted tree: its slash-separated path relative
+// to the tree, and a hash of its contents.
+type TreeFile struct {
+	Path    string
+	Content string
+}
+
+// TreeEntry is how one image compares between two sorted trees. The A
+// fields are empty for an image only in tree B, and the other way round.
+// A category is the folder holding the image relative to its tree, empty
+// for an image left at the top level.
+type TreeEntry struct {
+	Kind      string `json:"kind"`
+	Content   string `json:"content"`
+	PathA     string `json:"path_a,omitempty"`
+	CategoryA string `json:"category_a,omitempty"`
+	PathB     string `json:"path_b,omitempty"`
+	CategoryB string `json:"category_b,omitempty"`
+}
+
+// TreeDiff is the result of DiffTrees, with a count of each kind of entry.
+type TreeDiff struct {
+	Header
+	DirA            string      `json:"dir_a"`
+	DirB            string      `json:"dir_b"`
+	Identical       int         `json:"identical"`
+	CategoryChanged int         `json:"category_changed"`
+	OnlyA           int         `json:"only_in_a"`
+	OnlyB           int         `json:"only_in_b"`
+	Files           []TreeEntry `json:"files"`
+}
+
+// TreeCategory returns the category of the image at rel, a slash-separated
+// path relative to a sorted tree.
+func TreeCategory(rel string) string {
+	if dir := path.Dir(rel); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// DiffTrees compares the images of two sorted trees, matching them by
+// content, since the same image may have been renamed to avoid a conflict
+// in one tree and not the other. Copies of an image in the same category
+// of both trees are matched first, then the rest in path order; copies
+// left over are only in one tree. Entries are ordered by kind, then path.
+func DiffTrees(dirA string, a []TreeFile, dirB string, b []TreeFile) TreeDiff {
+	byContent := make(map[string][]string)
+	for _, f := range b {
+		byContent[f.Content] = append(byContent[f.Content], f.Path)
+	}
+	for _, paths := range byContent {
+		sort.Strings(paths)
+	}
+	a = append([]TreeFile(nil), a...)
+	sort.Slice(a, func(i, j int) bool { return a[i].Path < a[j].Path })
+
+	d := TreeDiff{DirA: dirA, DirB: dirB}
+	add := func(e TreeEntry) {
+		e.CategoryA, e.CategoryB = TreeCategory(e.PathA), TreeCategory(e.PathB)
+		d.Files = append(d.Files, e)
+	}
+	// take removes and returns the first path in B with the content that
+	// match accepts.
+	take := func(content string, match func(string) bool) (string, bool) {
+		bs := byContent[content]
+		for i, p := range bs {
+			if match(p) {
+				byContent[content] = append(bs[:i:i], bs[i+1:]...)
+				return p, true
+			}
+		}
+		return "", false
+	}
+
+	var unmatched []TreeFile
+	for _, f := range a {
+		cat := TreeCategory(f.Path)
+		if p, ok := take(f.Content, func(p string) bool { return TreeCategory(p) == cat }); ok {
+			add(TreeEntry{Kind: TreeIdentical, Content: f.Content, PathA: f.Path, PathB: p})
+		} else {
+			unmatched = append(unmatched, f)
+		}
+	}
+	for _, f := range unmatched {
+		if p, ok := take(f.Content, func(string) bool { return true }); ok {
+			add(TreeEntry{Kind: TreeCategoryChanged, Content: f.Content, PathA: f.Path, PathB: p})
+		} else {
+			add(TreeEntry{Kind: TreeOnlyA, Content: f.Content, PathA: f.Path})
+		}
+	}
+	for content, paths := range byContent {
+		for _, p := range paths {
+			add(TreeEntry{Kind: TreeOnlyB, Content: content, PathB: p})
+		}
+	}
+
+	order := map[string]int{TreeCategoryChanged: 0, TreeOnlyA: 1, TreeOnlyB: 2, TreeIdentical: 3}
+	sort.SliceStable(d.Files, func(i, j int) bool {
+		ei, ej := d.Files[i], d.Files[j]
+		if ei.Kind != ej.Kind {
+			return order[ei.Kind] < order[ej.Kind]
+		}
+		if ei.PathA != ej.PathA {
+			return ei.PathA < ej.PathA
+		}
+		return ei.PathB < ej.PathB
+	})
+	for _, e := range d.Files {
+		switch e.Kind {
+		case TreeIdentical:
+			d.Identical++
+		case TreeCategoryChanged:
+			d.CategoryChanged++
+		case TreeOnlyA:
+			d.OnlyA++
+		case TreeOnlyB:
+			d.OnlyB++
+		}
+	}
+	return d
+}
+
+// PrintTreeDiff prints the images d found in different places, and counts
+// the identical ones.
+func PrintTreeDiff(w io.Writer, d TreeDiff) {
+	fmt.Fprintf(w, "Comparing %s (A) with %s (B)\n", d.DirA, d.DirB)
+	fmt.Fprintf(w, "Identical:               %d\n", d.Identical)
+	fmt.Fprintf(w, "Categorized differently: %d\n", d.CategoryChanged)
+	fmt.Fprintf(w, "Only in A:               %d\n", d.OnlyA)
+	fmt.Fprintf(w, "Only in B:               %d\n", d.OnlyB)
+
+	titles := map[string]string{
+		TreeCategoryChanged: "Categorized differently",
+		TreeOnlyA:           "Only in A",
+		TreeOnlyB:           "Only in B",
+	}
+	kind := ""
+	for _, e := range d.Files {
+		if e.Kind == TreeIdentical {
+			continue
+		}
+		if e.Kind != kind {
+			kind = e.Kind
+			fmt.Fprintf(w, "\n%s:\n", titles[kind])
+		}
+		switch e.Kind {
+		case TreeCategoryChanged:
+			name := path.Base(e.PathA)
+			if b := path.Base(e.PathB); b != name {
+				name += " (" + b + " in B)"
+			}
+			fmt.Fprintf(w, "  %s: %s → %s\n", name, treeCategoryName(e.CategoryA), treeCategoryName(e.CategoryB))
+		case TreeOnlyA:
+			fmt.Fprintf(w, "  %s\n", e.PathA)
+		case TreeOnlyB:
+			fmt.Fprintf(w, "  %s\n", e.PathB)
+		}
+	}
+}
+
+// treeCategoryName names a TreeEntry category for people.
+func treeCategoryName(category string) string {
+	if category == "" {
+		return "(top level)"
+	}
+	return category
+}
+
+// WriteTreeDiffJSON writes d as indented JSON to w.
+func WriteTreeDiffJSON(w io.Writer, d TreeDiff) error {
+	d.Header = NewHeader()
+	if d.Files == nil {
+		d.Files = []TreeEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffTrees(t *testing.T) {
+	a := []TreeFile{
+		{"beach/same.jpg", "h1"},
+		{"beach/renamed.jpg", "h2"},
+		{"city/moved.jpg", "h3"},
+		{"unsorted.jpg", "h4"},
+		{"gone.jpg", "h5"},
+		{"beach/dup.jpg", "h6"},
+		{"city/dup.jpg", "h6"},
+	}
+	b := []TreeFile{
+		{"beach/same.jpg", "h1"},
+		{"beach/renamed_1.jpg", "h2"},
+		{"forest/moved.jpg", "h3"},
+		{"beach/_tentative/unsorted.jpg", "h4"},
+		{"new.jpg", "h7"},
+		{"city/dup.jpg", "h6"},
+	}
+	d := DiffTrees("/a", a, "/b", b)
+
+	want := []TreeEntry{
+		{TreeCategoryChanged, "h3", "city/moved.jpg", "city", "forest/moved.jpg", "forest"},
+		{TreeCategoryChanged, "h4", "unsorted.jpg", "", "beach/_tentative/unsorted.jpg", "beach/_tentative"},
+		{TreeOnlyA, "h6", "beach/dup.jpg", "beach", "", ""},
+		{TreeOnlyA, "h5", "gone.jpg", "", "", ""},
+		{TreeOnlyB, "h7", "", "", "new.jpg", ""},
+		{TreeIdentical, "h2", "beach/renamed.jpg", "beach", "beach/renamed_1.jpg", "beach"},
+		{TreeIdentical, "h1", "beach/same.jpg", "beach", "beach/same.jpg", "beach"},
+		{TreeIdentical, "h6", "city/dup.jpg", "city", "city/dup.jpg", "city"},
+	}
+	if !reflect.DeepEqual(d.Files, want) {
+		t.Errorf("got %+v\nwant %+v", d.Files, want)
+	}
+	if d.Identical != 3 || d.CategoryChanged != 2 || d.OnlyA != 2 || d.OnlyB != 1 {
+		t.Errorf("unexpected counts %+v", d)
+	}
+}
+
+func TestPrintTreeDiff(t *testing.T) {
+	d := DiffTrees("/a", []TreeFile{
+		{"city/IMG_1.jpg", "h1"},
+		{"IMG_2.jpg", "h2"},
+		{"beach/IMG_3.jpg", "h3"},
+	}, "/b", []TreeFile{
+		{"forest/IMG_1_1.jpg", "h1"},
+		{"beach/IMG_2.jpg", "h2"},
+		{"beach/IMG_3.jpg", "h3"},
+	})
+	var buf bytes.Buffer
+	PrintTreeDiff(&buf, d)
+	out := buf.String()
+	for _, want := range []string{
+		"Identical:               1\n",
+		"Categorized differently: 2\n",
+		"  IMG_1.jpg (IMG_1_1.jpg in B): city → forest\n",
+		"  IMG_2.jpg: (top level) → beach\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "IMG_3") || strings.Contains(out, "Only in A:\n") {
+		t.Errorf("expected only differences listed:\n%s", out)
+	}
+}
+
+func TestTreeDiffJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTreeDiffJSON(&buf, DiffTrees("/a", nil, "/b", nil)); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"schema_version", "dir_a", "dir_b", "identical", "category_changed", "only_in_a", "only_in_b"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing %q in %s", key, buf.String())
+		}
+	}
+	if files, ok := got["files"].([]any); !ok || len(files) != 0 {
+		t.Errorf("expected an empty files list, got %v", got["files"])
+	}
+}
@@ -0,0 +1,25 @@
+package scanner
+
+import "path/filepath"
+
+// Dedupe returns paths with repeats of the same file removed, keeping the
+// first occurrence, and how many were dropped. Paths are compared by their
+// cleaned absolute form, so ./a.jpg and a.jpg count as the same file.
+// Without this a repeated file is classified twice and its second move
+// fails because the source is already gone.
+func Dedupe(paths []string) ([]string, int) {
+	seen := make(map[string]bool, len(paths))
+	unique := make([]string, 0, len(paths))
+	for _, p := range paths {
+		key, err := filepath.Abs(p)
+		if err != nil {
+			key = filepath.Clean(p)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, p)
+	}
+	return unique, len(paths) - len(unique)
+}
@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	paths := []string{
+		"a.jpg",
+		"./a.jpg",
+		"b.jpg",
+		filepath.Join(dir, "a.jpg"),
+		"sub/../b.jpg",
+		"sub/a.jpg",
+	}
+	got, dropped := Dedupe(paths)
+	if want := []string{"a.jpg", "b.jpg", "sub/a.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if dropped != 3 {
+		t.Errorf("expected 3 duplicates dropped, got %d", dropped)
+	}
+}
@@ -0,0 +1,29 @@
+package scanner
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// Sample returns a reproducible random percent (0-100) of paths, in their
+// original order. The same paths, percent, and seed always give the same
+// sample. Any positive percent of a non-empty list keeps at least one path.
+func Sample(paths []string, percent float64, seed uint64) []string {
+	n := int(math.Round(float64(len(paths)) * percent / 100))
+	if n == 0 && percent > 0 && len(paths) > 0 {
+		n = 1
+	}
+	if n >= len(paths) {
+		return slices.Clone(paths)
+	}
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+	picked := rng.Perm(len(paths))[:n]
+	slices.Sort(picked)
+	sample := make([]string, n)
+	for i, idx := range picked {
+		sample[i] = paths[idx]
+	}
+	return sample
+}
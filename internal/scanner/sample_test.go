@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	paths := make([]string, 1000)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("IMG_%04d.jpg", i)
+	}
+
+	sample := Sample(paths, 12.5, 42)
+	if len(sample) != 125 {
+		t.Errorf("expected 125 paths, got %d", len(sample))
+	}
+	if !slices.IsSorted(sample) {
+		t.Error("sample should keep the original order")
+	}
+	if again := Sample(paths, 12.5, 42); !slices.Equal(again, sample) {
+		t.Error("the same seed should give the same sample")
+	}
+	if other := Sample(paths, 12.5, 43); slices.Equal(other, sample) {
+		t.Error("a different seed should give a different sample")
+	}
+
+	for _, tt := range []struct {
+		n       int
+		percent float64
+		want    int
+	}{
+		{10, 0, 0},
+		{10, 100, 10},
+		{10, 1, 1}, // rounds to 0, but a positive percent keeps one
+		{10, 25, 3},
+		{0, 50, 0},
+	} {
+		if got := len(Sample(paths[:tt.n], tt.percent, 1)); got != tt.want {
+			t.Errorf("Sample(%d paths, %g%%) kept %d, want %d", tt.n, tt.percent, got, tt.want)
+		}
+	}
+}
@@ -2,10 +2,19 @@
 package scanner
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/junk"
+	"github.com/bagtoad/imgsort/internal/livephoto"
+	"github.com/bagtoad/imgsort/internal/manifest"
+	"github.com/bagtoad/imgsort/internal/video"
 )
 
 // SupportedExtensions contains the set of image file extensions we process.
@@ -20,15 +29,209 @@ var SupportedExtensions = map[string]bool{
 	".tif":  true,
 }
 
+// Supported reports whether the file at path is classified: an image with
+// one of SupportedExtensions, or a video when imgsort is built with video
+// support.
+func Supported(path string) bool {
+	return SupportedExtensions[strings.ToLower(filepath.Ext(path))] || video.Is(path)
+}
+
+// ErrNoImages is returned (wrapped) by Scan when a directory contains no
+// supported image files.
+var ErrNoImages = errors.New("no image files found")
+
 // Result holds the output of scanning a directory.
 type Result struct {
 	ImagePaths   []string
 	SkippedCount int
+
+	// HiddenCount and DirCount count entries that were ignored entirely.
+	HiddenCount int
+	DirCount    int
+
+	// ImageBytes is the total size of the image files found.
+	ImageBytes int64
+
+	// UnchangedCount counts images left out because they were not
+	// modified after Options.ModifiedAfter.
+	UnchangedCount int
+
+	// Unreadable lists subfolders and images that could not be read, for
+	// example because permission was denied. They are left out of the scan
+	// rather than failing it.
+	Unreadable []string
+
+	// Live maps each Live Photo or Motion Photo to the companion videos
+	// that belong with it (none for a Motion Photo, whose video is
+	// embedded). Paired videos are not counted in SkippedCount, and are not
+	// in ImagePaths even when video support is built in.
+	Live map[string][]string
+
+	// Excluded lists every entry left out of ImagePaths other than
+	// unreadable ones, with the reason. It is only filled in when
+	// Options.RecordExcluded is set, since it can be large.
+	Excluded []Excluded
+
+	// videos holds files that may be Live Photo companions.
+	videos []string
+
+	// images counts the images found, including any not kept in ImagePaths.
+	images int
+}
+
+// Reasons an entry is excluded from a scan, recorded in Excluded.Reason.
+const (
+	ReasonNonImage  = "non_image"
+	ReasonHidden    = "hidden"
+	ReasonDirectory = "directory"
+	ReasonUnchanged = "unchanged"
+)
+
+// Excluded is a scanned entry that is not an image to classify.
+type Excluded struct {
+	Path   string
+	Reason string
+}
+
+// Options controls how Scan walks a directory.
+type Options struct {
+	// Recursive also scans subfolders, except hidden ones and the category
+	// folders imgsort created. Those are read from the directory's
+	// manifest; without one, any top-level folder named after one of
+	// Categories is assumed to be output.
+	Recursive  bool
+	Categories []string
+
+	// OutputFolders makes Recursive descend into imgsort's category folders
+	// too, for reading a tree that has already been sorted.
+	OutputFolders bool
+
+	// CategoryFolders makes Recursive leave out every top-level folder named
+	// after one of Categories, even with a manifest, for a run that moves
+	// files into them while the scan is still going.
+	CategoryFolders bool
+
+	// SplitLivePhotos treats Live Photo videos as unrelated files instead
+	// of pairing them with their stills.
+	SplitLivePhotos bool
+
+	// RecordExcluded fills in Result.Excluded.
+	RecordExcluded bool
+
+	// NoJunkFilter counts and reports operating system metadata files such
+	// as .DS_Store like any other file, instead of ignoring them.
+	NoJunkFilter bool
+
+	// ModifiedAfter, if set, leaves out images last modified at or before
+	// it, as for an incremental run.
+	ModifiedAfter time.Time
+
+	// NoImagePaths leaves Result.ImagePaths empty, for Stream callers that
+	// handle each image as it is reported and must not hold a listing of
+	// the whole directory. Live Photos are not paired.
+	NoImagePaths bool
 }
 
 // Scan walks the given directory (non-recursive) and returns image file paths
-// and a count of skipped non-image files.
+// and a count of skipped non-image files. If no images are found, the
+// partial result is returned along with an error wrapping ErrNoImages.
 func Scan(dir string) (*Result, error) {
+	return ScanWithOptions(dir, Options{})
+}
+
+// ScanWithOptions is like Scan, optionally descending into subfolders.
+// Each directory is read in full and visited in name order.
+func ScanWithOptions(dir string, opts Options) (*Result, error) {
+	return scan(dir, opts, &walker{sorted: true})
+}
+
+// Entry is a file reported by Stream as soon as it is read. Image is set
+// for an image to classify; otherwise Reason says why the file was left
+// out: one of the Reason constants, or ReasonUnreadable.
+type Entry struct {
+	Path   string
+	Image  bool
+	Reason string
+}
+
+// ReasonUnreadable marks an Entry for a file or folder that could not be
+// read. Such paths are listed in Result.Unreadable rather than Excluded.
+const ReasonUnreadable = "unreadable"
+
+// streamBatch is how many directory entries Stream reads at a time.
+const streamBatch = 1024
+
+// Stream is like ScanWithOptions, but reads directories a batch at a time
+// and calls fn with each entry as it is found, so callers can show progress
+// through directories with hundreds of thousands of entries instead of
+// waiting for the whole listing. Junk files are not reported, and neither
+// are the folders it descends into. Entries arrive, and ImagePaths are
+// listed, in the order the file system returns them rather than by name;
+// callers that need a stable order must sort. An error from fn stops the
+// scan and is returned as is. Live Photo pairs are only known once the scan
+// is complete, so they appear in the returned Result alone.
+func Stream(dir string, opts Options, fn func(Entry) error) (*Result, error) {
+	return scan(dir, opts, &walker{emit: fn})
+}
+
+// walker reads the directories of one scan.
+type walker struct {
+	// sorted reads each directory in full and in name order, instead of
+	// streamBatch entries at a time in file system order.
+	sorted bool
+
+	// emit, if set, is called with each entry; err records its first
+	// failure, which ends the scan.
+	emit func(Entry) error
+	err  error
+}
+
+// report passes e to the walker's callback, if any.
+func (w *walker) report(e Entry) error {
+	if w.emit == nil {
+		return nil
+	}
+	if err := w.emit(e); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// readDir calls fn with the entries of dir, a batch at a time unless the
+// walker is sorted.
+func (w *walker) readDir(dir string, fn func([]os.DirEntry) error) error {
+	if w.sorted {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		return fn(entries)
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for {
+		entries, err := f.ReadDir(streamBatch)
+		if len(entries) > 0 {
+			if err := fn(entries); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// scan does the work of ScanWithOptions and Stream.
+func scan(dir string, opts Options, w *walker) (*Result, error) {
 	info, err := os.Stat(dir)
 	if err != nil {
 		return nil, fmt.Errorf("cannot access directory: %w", err)
@@ -37,27 +240,167 @@ func Scan(dir string) (*Result, error) {
 		return nil, fmt.Errorf("%s is not a directory", dir)
 	}
 
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read directory: %w", err)
+	var isOutput func(name string) bool
+	if opts.Recursive && !opts.OutputFolders {
+		isOutput, err = outputFolders(dir, opts.Categories, opts.CategoryFolders)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	result := &Result{}
-	for _, entry := range entries {
-		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
-			continue
+	if err := scanDir(dir, result, opts, isOutput, w); err != nil {
+		if w.err != nil {
+			return nil, w.err
+		}
+		return nil, fmt.Errorf("cannot read directory: %w", err)
+	}
+
+	if !opts.SplitLivePhotos && !opts.NoImagePaths {
+		stills := slices.DeleteFunc(slices.Clone(result.ImagePaths), video.Is)
+		result.Live = livephoto.Detect(stills, result.videos)
+		paired := make(map[string]bool)
+		for _, companions := range result.Live {
+			for _, c := range companions {
+				paired[c] = true
+				// A companion video that would otherwise be classified on
+				// its own moves with its still instead.
+				if !video.Is(c) {
+					result.SkippedCount--
+					continue
+				}
+				result.images--
+				if info, err := os.Stat(c); err == nil {
+					result.ImageBytes -= info.Size()
+				}
+			}
+		}
+		result.ImagePaths = slices.DeleteFunc(result.ImagePaths, func(p string) bool { return paired[p] })
+		result.Excluded = slices.DeleteFunc(result.Excluded, func(e Excluded) bool { return paired[e.Path] })
+	}
+
+	if result.images == 0 {
+		if result.UnchangedCount > 0 {
+			return result, fmt.Errorf("%w in %s (%d unchanged since %s)", ErrNoImages, dir,
+				result.UnchangedCount, opts.ModifiedAfter.Format(time.DateTime))
+		}
+		if len(result.Unreadable) > 0 {
+			return result, fmt.Errorf("%w in %s (%d paths could not be read)", ErrNoImages, dir, len(result.Unreadable))
+		}
+		return result, fmt.Errorf("%w in %s", ErrNoImages, dir)
+	}
+
+	return result, nil
+}
+
+// scanDir adds the images in dir to result. When recursive, it descends into
+// subfolders other than hidden ones and, at the top level only, those
+// isOutput reports as imgsort output. Subfolders that cannot be listed are
+// recorded in result.Unreadable; only a failure to list dir itself, or an
+// error from the walker's callback, is returned.
+func scanDir(dir string, result *Result, opts Options, isOutput func(string) bool, w *walker) error {
+	exclude := func(name, reason string) error {
+		path := filepath.Join(dir, name)
+		if opts.RecordExcluded {
+			result.Excluded = append(result.Excluded, Excluded{path, reason})
+		}
+		return w.report(Entry{Path: path, Reason: reason})
+	}
+	unreadable := func(path string) error {
+		result.Unreadable = append(result.Unreadable, path)
+		return w.report(Entry{Path: path, Reason: ReasonUnreadable})
+	}
+
+	visit := func(entry os.DirEntry) error {
+		if entry.IsDir() {
+			if !opts.Recursive || strings.HasPrefix(entry.Name(), ".") ||
+				(isOutput != nil && isOutput(entry.Name())) {
+				result.DirCount++
+				return exclude(entry.Name(), ReasonDirectory)
+			}
+			sub := filepath.Join(dir, entry.Name())
+			if err := scanDir(sub, result, opts, nil, w); err != nil {
+				if w.err != nil {
+					return w.err
+				}
+				return unreadable(sub)
+			}
+			return nil
+		}
+		if !opts.NoJunkFilter && junk.Is(entry.Name()) {
+			return nil
 		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			result.HiddenCount++
+			return exclude(entry.Name(), ReasonHidden)
+		}
+
+		path := filepath.Join(dir, entry.Name())
 		ext := strings.ToLower(filepath.Ext(entry.Name()))
-		if SupportedExtensions[ext] {
-			result.ImagePaths = append(result.ImagePaths, filepath.Join(dir, entry.Name()))
-		} else {
+		if livephoto.CompanionExts[ext] && !opts.NoImagePaths {
+			result.videos = append(result.videos, path)
+		}
+		if !Supported(path) {
 			result.SkippedCount++
+			return exclude(entry.Name(), ReasonNonImage)
+		}
+		if !readable(path) {
+			return unreadable(path)
+		}
+		info, err := entry.Info()
+		if err == nil && !opts.ModifiedAfter.IsZero() && !info.ModTime().After(opts.ModifiedAfter) {
+			result.UnchangedCount++
+			return exclude(entry.Name(), ReasonUnchanged)
+		}
+		result.images++
+		if !opts.NoImagePaths {
+			result.ImagePaths = append(result.ImagePaths, path)
 		}
+		if err == nil {
+			result.ImageBytes += info.Size()
+		}
+		return w.report(Entry{Path: path, Image: true})
 	}
 
-	if len(result.ImagePaths) == 0 {
-		return nil, fmt.Errorf("no image files found in %s", dir)
+	return w.readDir(dir, func(entries []os.DirEntry) error {
+		for _, entry := range entries {
+			if err := visit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readable reports whether the file at path can be opened for reading.
+func readable(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
 	}
+	f.Close()
+	return true
+}
 
-	return result, nil
+// outputFolders returns a test for top-level folders of dir that hold
+// imgsort output: those in the manifest if there is one, and otherwise those
+// named after a category. byName counts the folders named after a category
+// even when there is a manifest.
+func outputFolders(dir string, categories []string, byName bool) (func(string) bool, error) {
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		names[strings.ToLower(c)] = true
+	}
+	named := func(name string) bool { return names[strings.ToLower(name)] }
+	switch {
+	case m == nil:
+		return named, nil
+	case byName:
+		return func(name string) bool { return m.IsManaged(name) || named(name) }, nil
+	}
+	return m.IsManaged, nil
 }
@@ -1,9 +1,18 @@
 package scanner
 
 import (
+	"errors"
+	"image"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/manifest"
+	"github.com/bagtoad/imgsort/internal/video"
 )
 
 func TestScan(t *testing.T) {
@@ -70,10 +79,16 @@ func TestScanNoImages(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err := Scan(dir)
+	result, err := Scan(dir)
 	if err == nil {
 		t.Error("expected error for directory with no images")
 	}
+	if !errors.Is(err, ErrNoImages) {
+		t.Errorf("expected ErrNoImages, got %v", err)
+	}
+	if result == nil || result.SkippedCount != 1 {
+		t.Errorf("expected partial result counting the skipped file, got %+v", result)
+	}
 }
 
 func TestScanNonexistentDir(t *testing.T) {
@@ -123,4 +138,304 @@ func TestScanSkipsHiddenFiles(t *testing.T) {
 	if result.SkippedCount != 0 {
 		t.Errorf("expected 0 skipped (hidden files should be ignored), got %d", result.SkippedCount)
 	}
+	// .DS_Store is junk and not counted at all; see TestScanIgnoresJunk.
+	if result.HiddenCount != 1 {
+		t.Errorf("expected 1 hidden file counted, got %d", result.HiddenCount)
+	}
+}
+
+func TestScanIgnoresJunk(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"photo.jpg", ".DS_Store", "Thumbs.db", "desktop.ini", "._photo.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := ScanWithOptions(dir, Options{RecordExcluded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ImagePaths) != 1 || result.SkippedCount != 0 || result.HiddenCount != 0 || len(result.Excluded) != 0 {
+		t.Errorf("junk files should be neither counted nor reported, got %+v", result)
+	}
+
+	result, err = ScanWithOptions(dir, Options{RecordExcluded: true, NoJunkFilter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SkippedCount != 2 || result.HiddenCount != 2 || len(result.Excluded) != 4 {
+		t.Errorf("with NoJunkFilter, expected 2 skipped and 2 hidden, got %+v", result)
+	}
+}
+
+func TestScanRecursiveSkipsManagedFolders(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.jpg", "food/sorted.jpg", "dessert/cake.jpg", "trips/food/lunch.jpg", ".cache/thumb.jpg"} {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cats := []string{"food", "dessert"}
+
+	found := func(t *testing.T) []string {
+		t.Helper()
+		result, err := ScanWithOptions(dir, Options{Recursive: true, Categories: cats})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rel []string
+		for _, p := range result.ImagePaths {
+			r, _ := filepath.Rel(dir, p)
+			rel = append(rel, filepath.ToSlash(r))
+		}
+		slices.Sort(rel)
+		return rel
+	}
+
+	// Without a manifest, folders named after a category are assumed to be
+	// output, but only at the top level.
+	want := []string{"a.jpg", "trips/food/lunch.jpg"}
+	if got := found(t); !slices.Equal(got, want) {
+		t.Errorf("without manifest: got %v, want %v", got, want)
+	}
+
+	// With a manifest, only the folders it lists are skipped, so an
+	// unsorted "dessert" folder is scanned even though it shares a
+	// category's name.
+	if err := manifest.Record(dir, "food"); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"a.jpg", "dessert/cake.jpg", "trips/food/lunch.jpg"}
+	if got := found(t); !slices.Equal(got, want) {
+		t.Errorf("with manifest: got %v, want %v", got, want)
+	}
+
+	// CategoryFolders skips folders named after a category anyway.
+	result, err := ScanWithOptions(dir, Options{Recursive: true, Categories: cats, CategoryFolders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ImagePaths) != 2 {
+		t.Errorf("expected only a.jpg and trips/food/lunch.jpg, got %v", result.ImagePaths)
+	}
+
+	// OutputFolders reads the sorted folders too.
+	result, err = ScanWithOptions(dir, Options{Recursive: true, Categories: cats, OutputFolders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ImagePaths) != 4 {
+		t.Errorf("expected every image outside hidden folders, got %v", result.ImagePaths)
+	}
+
+	// Non-recursive scans are unaffected.
+	result, err = Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ImagePaths) != 1 {
+		t.Errorf("non-recursive scan should find only a.jpg, got %v", result.ImagePaths)
+	}
+}
+
+func TestScanRecordsUnreadable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits do not deny listing on Windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root ignores permission bits")
+	}
+
+	dir := t.TempDir()
+	locked := filepath.Join(dir, "locked")
+	if err := os.Mkdir(locked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"a.jpg", "secret.jpg", "locked/b.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(f)), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "secret.jpg"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	result, err := ScanWithOptions(dir, Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("unreadable entries should not fail the scan: %v", err)
+	}
+	if len(result.ImagePaths) != 1 || filepath.Base(result.ImagePaths[0]) != "a.jpg" {
+		t.Errorf("expected only a.jpg, got %v", result.ImagePaths)
+	}
+	slices.Sort(result.Unreadable)
+	want := []string{locked, filepath.Join(dir, "secret.jpg")}
+	if !slices.Equal(result.Unreadable, want) {
+		t.Errorf("unreadable = %v, want %v", result.Unreadable, want)
+	}
+
+	// With nothing readable, the error is still ErrNoImages but says why.
+	os.Remove(filepath.Join(dir, "a.jpg"))
+	_, err = ScanWithOptions(dir, Options{Recursive: true})
+	if !errors.Is(err, ErrNoImages) || !strings.Contains(err.Error(), "2 paths could not be read") {
+		t.Errorf("expected ErrNoImages mentioning unreadable paths, got %v", err)
+	}
+}
+
+func TestScanPairsLivePhotos(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"IMG_1.JPG", "IMG_1.MOV", "clip.mov", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	still := filepath.Join(dir, "IMG_1.JPG")
+	// Pairing applies whether or not videos can be classified; without a
+	// decoder the unpaired clip counts as skipped.
+	prev := video.Register(nil)
+	defer video.Register(prev)
+
+	result, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Live[still]; !slices.Equal(got, []string{filepath.Join(dir, "IMG_1.MOV")}) {
+		t.Errorf("expected IMG_1.MOV paired with the still, got %v", result.Live)
+	}
+	if result.SkippedCount != 2 {
+		t.Errorf("the paired video should not count as skipped, got %d", result.SkippedCount)
+	}
+
+	result, err = ScanWithOptions(dir, Options{SplitLivePhotos: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Live) != 0 || result.SkippedCount != 3 {
+		t.Errorf("split scan should not pair, got live %v and %d skipped", result.Live, result.SkippedCount)
+	}
+}
+
+func TestScanVideos(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"IMG_1.JPG", "IMG_1.MOV", "clip.mp4", "trip.MOV", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prev := video.Register(nil)
+	defer video.Register(prev)
+
+	result, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ImagePaths) != 1 || result.SkippedCount != 3 {
+		t.Errorf("videos should be skipped without a decoder, got %v and %d skipped", result.ImagePaths, result.SkippedCount)
+	}
+
+	video.Register(func(string, float64) (image.Image, error) { return nil, nil })
+	result, err = Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "IMG_1.JPG"), filepath.Join(dir, "clip.mp4"), filepath.Join(dir, "trip.MOV")}
+	if !slices.Equal(result.ImagePaths, want) {
+		t.Errorf("expected %v, got %v", want, result.ImagePaths)
+	}
+	if got := result.Live[want[0]]; !slices.Equal(got, []string{filepath.Join(dir, "IMG_1.MOV")}) {
+		t.Errorf("a Live Photo video should still move with its still, got %v", result.Live)
+	}
+	if result.SkippedCount != 1 || result.ImageBytes != 12 {
+		t.Errorf("expected only notes.txt skipped and 12 bytes, got %d skipped and %d bytes", result.SkippedCount, result.ImageBytes)
+	}
+}
+
+func TestScanRecordExcluded(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.jpg", "notes.txt", ".hidden", "IMG_1.jpg", "IMG_1.mov"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Excluded != nil {
+		t.Errorf("excluded entries should only be recorded on request, got %v", result.Excluded)
+	}
+
+	result, err = ScanWithOptions(dir, Options{RecordExcluded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"notes.txt": ReasonNonImage,
+		".hidden":   ReasonHidden,
+		"sub":       ReasonDirectory,
+	}
+	got := make(map[string]string)
+	for _, e := range result.Excluded {
+		got[filepath.Base(e.Path)] = e.Reason
+	}
+	if len(got) != len(want) {
+		t.Errorf("excluded = %v, want %v (the paired video is not excluded)", got, want)
+	}
+	for name, reason := range want {
+		if got[name] != reason {
+			t.Errorf("%s: reason %q, want %q", name, got[name], reason)
+		}
+	}
+}
+
+func TestScanModifiedAfter(t *testing.T) {
+	dir := t.TempDir()
+	cutoff := time.Now().Add(-time.Hour)
+	for name, mtime := range map[string]time.Time{
+		"old.jpg":  cutoff.Add(-time.Minute),
+		"same.jpg": cutoff,
+		"new.jpg":  cutoff.Add(time.Minute),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := ScanWithOptions(dir, Options{ModifiedAfter: cutoff, RecordExcluded: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.ImagePaths, []string{filepath.Join(dir, "new.jpg")}) {
+		t.Errorf("expected only new.jpg, got %v", result.ImagePaths)
+	}
+	if result.UnchangedCount != 2 || len(result.Excluded) != 2 || result.Excluded[0].Reason != ReasonUnchanged {
+		t.Errorf("expected 2 unchanged images recorded, got %d and %+v", result.UnchangedCount, result.Excluded)
+	}
+
+	// Nothing new is reported as no images, with the partial result.
+	result, err = ScanWithOptions(dir, Options{ModifiedAfter: cutoff.Add(time.Hour)})
+	if !errors.Is(err, ErrNoImages) || result == nil || result.UnchangedCount != 3 {
+		t.Errorf("expected ErrNoImages with 3 unchanged, got %v", err)
+	}
+
+	// A zero time filters nothing, as on a first run.
+	if result, err := ScanWithOptions(dir, Options{}); err != nil || len(result.ImagePaths) != 3 {
+		t.Errorf("expected all 3 images without a cutoff, got %v", err)
+	}
 }
@@ -0,0 +1,155 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.jpg", "b.png", "notes.txt", ".hidden.jpg", ".DS_Store", "sub/c.jpg", "sub/d.gif"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := Options{Recursive: true, RecordExcluded: true}
+
+	var images []string
+	reasons := make(map[string]string)
+	got, err := Stream(dir, opts, func(e Entry) error {
+		if e.Image {
+			images = append(images, e.Path)
+		} else {
+			reasons[filepath.Base(e.Path)] = e.Reason
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ScanWithOptions(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(images, got.ImagePaths) {
+		t.Errorf("entries reported %v, result lists %v", images, got.ImagePaths)
+	}
+	slices.Sort(images)
+	if !slices.Equal(images, want.ImagePaths) {
+		t.Errorf("Stream found %v, ScanWithOptions %v", images, want.ImagePaths)
+	}
+	if reasons["notes.txt"] != ReasonNonImage || reasons[".hidden.jpg"] != ReasonHidden || len(reasons) != 2 {
+		t.Errorf("unexpected excluded entries %v", reasons)
+	}
+	if got.SkippedCount != want.SkippedCount || got.HiddenCount != want.HiddenCount || len(got.Excluded) != len(want.Excluded) {
+		t.Errorf("Stream counts %+v differ from ScanWithOptions %+v", got, want)
+	}
+}
+
+func TestStreamStopsOnError(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 5 {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.jpg", i)), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stop := errors.New("stop")
+	calls := 0
+	_, err := Stream(dir, Options{}, func(Entry) error {
+		calls++
+		if calls == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("expected the callback's error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected the scan to stop after 2 entries, got %d", calls)
+	}
+}
+
+func TestStreamNoImagePaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"a.jpg", "b.jpg", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("fake"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	images := 0
+	got, err := Stream(dir, Options{NoImagePaths: true}, func(e Entry) error {
+		if e.Image {
+			images++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images != 2 || len(got.ImagePaths) != 0 {
+		t.Errorf("expected 2 reported images and none listed, got %d and %v", images, got.ImagePaths)
+	}
+
+	empty := t.TempDir()
+	if _, err := Stream(empty, Options{NoImagePaths: true}, func(Entry) error { return nil }); !errors.Is(err, ErrNoImages) {
+		t.Errorf("expected ErrNoImages for an empty directory, got %v", err)
+	}
+}
+
+// BenchmarkScanHugeDir compares reading a directory of 50,000 entries in
+// full with streaming it. first-entry-ms is how long each takes to report
+// its first image, which is the pause before a run shows any progress.
+func BenchmarkScanHugeDir(b *testing.B) {
+	dir := b.TempDir()
+	for i := range 50_000 {
+		name := fmt.Sprintf("IMG_%05d.jpg", i)
+		if i%10 == 0 {
+			name = fmt.Sprintf("note_%05d.txt", i)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		var first time.Duration
+		for b.Loop() {
+			start := time.Now()
+			if _, err := ScanWithOptions(dir, Options{}); err != nil {
+				b.Fatal(err)
+			}
+			first += time.Since(start) // nothing is reported until the end
+		}
+		b.ReportMetric(first.Seconds()*1000/float64(b.N), "first-entry-ms")
+	})
+	b.Run("stream", func(b *testing.B) {
+		var first time.Duration
+		for b.Loop() {
+			start := time.Now()
+			seen := false
+			_, err := Stream(dir, Options{}, func(e Entry) error {
+				if !seen && e.Image {
+					seen = true
+					first += time.Since(start)
+				}
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(first.Seconds()*1000/float64(b.N), "first-entry-ms")
+	})
+}
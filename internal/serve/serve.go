@@ -0,0 +1,196 @@
+// Package serve runs the model for other machines ("imgsort serve"), so a
+// weak machine can sort its images using a stronger machine's model. Over
+// HTTP it answers the remote inference protocol that model.RemoteSession
+// speaks; over standard input it answers one JSON request per line.
+package serve
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// TokenEnv names the environment variable holding the bearer token. When set
+// on the server every request must present it; the client sends it.
+const TokenEnv = "IMGSORT_TOKEN"
+
+// DefaultAddr is the address "imgsort serve" listens on. It only accepts
+// connections from the same machine; listening more widely needs a token.
+const DefaultAddr = "127.0.0.1:8787"
+
+// maxRequestBytes bounds request bodies, which may carry an uploaded image.
+const maxRequestBytes = 64 << 20
+
+// maxImagePixels bounds the images the server decodes, a little above the
+// largest camera sensors, so a small file claiming huge dimensions cannot
+// exhaust its memory.
+const maxImagePixels = 120_000_000
+
+// Runner runs the model on prepared inputs. *model.CLIPSession implements
+// it.
+type Runner interface {
+	Logits(pixelValues []float32, inputIDs, attentionMask [][]int64) ([]float32, error)
+}
+
+// Options configures a Server.
+type Options struct {
+	// Token, if non-empty, must be sent by every request as a bearer token.
+	Token string
+
+	// Concurrency is how many images are classified at once (minimum 1).
+	Concurrency int
+
+	// AllowPaths accepts requests that name an image by its path on the
+	// server (--shared-fs clients). It is off by default, since it lets
+	// clients make the server open its own files.
+	AllowPaths bool
+}
+
+// Server answers remote inference requests using a local model.
+type Server struct {
+	runner  Runner
+	modelID string
+	opts    Options
+	sem     chan struct{}
+}
+
+// NewServer wraps runner, which must be running modelID.
+func NewServer(runner Runner, modelID string, opts Options) *Server {
+	return &Server{
+		runner:  runner,
+		modelID: modelID,
+		opts:    opts,
+		sem:     make(chan struct{}, max(opts.Concurrency, 1)),
+	}
+}
+
+// CheckAddr refuses to serve on an address other machines can reach
+// without a token, since anyone could then use the server.
+func CheckAddr(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("refusing to listen on %s without a token: set %s, or listen on 127.0.0.1", addr, TokenEnv)
+}
+
+// Handler returns the HTTP handler for the server's endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+model.RemoteInfoPath, s.handleInfo)
+	mux.HandleFunc("POST "+model.RemoteClassifyPath, s.handleClassify)
+	return s.authorize(mux)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.opts.Token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.opts.Token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "missing or invalid bearer token (set "+TokenEnv+")")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, model.RemoteInfo{Model: s.modelID})
+}
+
+func (s *Server) handleClassify(w http.ResponseWriter, r *http.Request) {
+	var req model.RemoteRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if req.Model != s.modelID {
+		writeError(w, http.StatusConflict, fmt.Sprintf("server runs model %q but the client expects %q", s.modelID, req.Model))
+		return
+	}
+	if len(req.InputIDs) == 0 {
+		writeError(w, http.StatusBadRequest, "no prompts provided")
+		return
+	}
+	if req.Path != "" && !s.opts.AllowPaths {
+		writeError(w, http.StatusForbidden, "this server does not read image paths; start it with --allow-paths or upload the image")
+		return
+	}
+
+	if err := s.acquire(r.Context()); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer func() { <-s.sem }()
+
+	pixels, err := pixelValues(req)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	logits, err := s.runner.Logits(pixels, req.InputIDs, req.AttentionMask)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, model.RemoteResponse{LogitsPerImage: logits})
+}
+
+// pixelValues returns the image tensor the request carries, or prepares it
+// from the image the request names or uploads.
+func pixelValues(req model.RemoteRequest) ([]float32, error) {
+	opts := model.PreprocessOptions{Small: model.SmallImageMode(req.Small), MinSize: req.MinSize, MaxPixels: maxImagePixels}
+	var pixels []float32
+	var err error
+	switch {
+	case req.PixelValues != "":
+		pixels, err = model.DecodeFloat32s(req.PixelValues)
+	case req.Path != "":
+		pixels, err = model.PreprocessImageWithOptions(req.Path, opts)
+	case len(req.Image) > 0:
+		pixels, err = model.PreprocessReader(bytes.NewReader(req.Image), opts)
+	default:
+		return nil, fmt.Errorf("request has no pixel values, image, or path")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot preprocess image: %w", err)
+	}
+	return pixels, nil
+}
+
+// acquire waits for a free inference slot or for the request to be canceled.
+func (s *Server) acquire(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, model.RemoteResponse{Error: msg})
+}
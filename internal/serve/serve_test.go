@@ -0,0 +1,175 @@
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// colorCategories are scored by channelRunner: row i by channel i.
+var colorCategories = []string{"red", "green", "blue"}
+
+// channelRunner stands in for the model: each prompt row's logit is the
+// mean of one color channel, so requests exercise real image decoding and
+// preprocessing end to end without model files.
+type channelRunner struct {
+	rows int // if set, the number of logits returned regardless of input
+}
+
+func (c channelRunner) Logits(pixelValues []float32, inputIDs, attentionMask [][]int64) ([]float32, error) {
+	n := len(inputIDs)
+	if c.rows > 0 {
+		n = c.rows
+	}
+	plane := len(pixelValues) / 3
+	logits := make([]float32, n)
+	for i := range logits {
+		var sum float32
+		for _, v := range pixelValues[(i%3)*plane : (i%3+1)*plane] {
+			sum += v
+		}
+		logits[i] = 10 * sum / float32(plane)
+	}
+	return logits, nil
+}
+
+func newTestServer(t *testing.T, runner Runner, opts Options) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(NewServer(runner, model.ModelID, opts).Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestSession returns a RemoteSession for srv, with a tokenizer whose
+// vocab only covers the start and end tokens; channelRunner ignores the
+// prompts anyway.
+func newTestSession(t *testing.T, srv *httptest.Server, opts model.RemoteOptions) *model.RemoteSession {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "vocab.json"), []byte(`{"<|startoftext|>": 1, "<|endoftext|>": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "merges.txt"), []byte("#version: 0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	model.SetModelsDir(dir)
+	defer model.SetModelsDir("")
+
+	r, err := model.NewRemoteSession(srv.URL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func testdata(name string) string {
+	return filepath.Join("..", "..", "testdata", name)
+}
+
+func TestRemoteSessionEndToEnd(t *testing.T) {
+	for name, opts := range map[string]model.RemoteOptions{
+		"tensor":    {Token: "secret"},
+		"upload":    {Token: "secret", Upload: true, Concurrency: 2},
+		"shared fs": {Token: "secret", SharedFS: true, Concurrency: 2},
+	} {
+		srv := newTestServer(t, channelRunner{}, Options{Token: "secret", Concurrency: 2, AllowPaths: opts.SharedFS})
+		r := newTestSession(t, srv, opts)
+		if err := r.CheckModel(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		paths := []string{testdata("red_object.jpg"), testdata("missing.jpg")}
+		all, err := categorizer.ClassifyAllParallel(r, paths, colorCategories,
+			model.ClassifyOptions{NoBaseline: true}, 2, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := categorizer.Decide(all, categorizer.Options{Threshold: 0.15, Quiet: true})
+
+		if results[0].Category != "red" {
+			t.Errorf("%s: expected red, got %+v", name, all[0])
+		}
+		if !results[1].Skipped || all[1].Error == "" {
+			t.Errorf("%s: missing file should be skipped with an error, got %+v", name, all[1])
+		}
+	}
+}
+
+func TestCheckModelMismatch(t *testing.T) {
+	srv := httptest.NewServer(NewServer(channelRunner{}, "other/model", Options{}).Handler())
+	defer srv.Close()
+	r := newTestSession(t, srv, model.RemoteOptions{})
+	if err := r.CheckModel(); err == nil || !strings.Contains(err.Error(), "other/model") {
+		t.Errorf("expected model mismatch error naming the server model, got %v", err)
+	}
+	_, err := r.ClassifyWithOptions(testdata("red_object.jpg"), colorCategories, model.ClassifyOptions{})
+	if err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("expected the server to refuse the request, got %v", err)
+	}
+}
+
+func TestServerRequiresToken(t *testing.T) {
+	srv := newTestServer(t, channelRunner{}, Options{Token: "secret"})
+	r := newTestSession(t, srv, model.RemoteOptions{Token: "wrong"})
+	if err := r.CheckModel(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected unauthorized error, got %v", err)
+	}
+}
+
+func TestRemoteSessionChecksCategories(t *testing.T) {
+	srv := newTestServer(t, channelRunner{rows: 2}, Options{})
+	r := newTestSession(t, srv, model.RemoteOptions{Upload: true})
+	_, err := r.ClassifyWithOptions(testdata("red_object.jpg"), colorCategories, model.ClassifyOptions{NoBaseline: true})
+	if err == nil || !strings.Contains(err.Error(), "2 logits for 3 labels") {
+		t.Errorf("expected a logit count mismatch error, got %v", err)
+	}
+}
+
+func TestServerRejectsEmptyRequest(t *testing.T) {
+	srv := newTestServer(t, channelRunner{}, Options{})
+	resp, err := http.Post(srv.URL+model.RemoteClassifyPath, "application/json",
+		strings.NewReader(`{"model": "`+model.ModelID+`", "input_ids": [[1]], "attention_mask": [[1]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected HTTP 422 for a request without an image, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerRefusesPathsByDefault(t *testing.T) {
+	srv := newTestServer(t, channelRunner{}, Options{})
+	r := newTestSession(t, srv, model.RemoteOptions{SharedFS: true})
+	_, err := r.ClassifyWithOptions(testdata("red_object.jpg"), colorCategories, model.ClassifyOptions{NoBaseline: true})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected the server to refuse a path, got %v", err)
+	}
+}
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		addr, token string
+		ok          bool
+	}{
+		{DefaultAddr, "", true},
+		{"localhost:8787", "", true},
+		{"[::1]:8787", "", true},
+		{":8787", "", false},
+		{"0.0.0.0:8787", "", false},
+		{"192.168.1.5:8787", "", false},
+		{":8787", "secret", true},
+		{"8787", "", false},
+	}
+	for _, tt := range tests {
+		if err := CheckAddr(tt.addr, tt.token); (err == nil) != tt.ok {
+			t.Errorf("CheckAddr(%q, %q) = %v, want ok %v", tt.addr, tt.token, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package serve
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// maxStdioLine bounds one request line read by ServeStdio.
+const maxStdioLine = 1 << 20
+
+// StdioRequest is one line read by ServeStdio. ID is echoed back unchanged
+// so callers can match responses to requests. Categories default to the
+// ones ServeStdio was started with.
+type StdioRequest struct {
+	ID         json.RawMessage   `json:"id,omitempty"`
+	Path       string            `json:"path"`
+	Categories []string          `json:"categories,omitempty"`
+	Prompts    map[string]string `json:"prompts,omitempty"`
+	NoBaseline bool              `json:"no_baseline,omitempty"`
+}
+
+// StdioResponse is written by ServeStdio for each request. Error is set on
+// failure.
+type StdioResponse struct {
+	ID     json.RawMessage    `json:"id,omitempty"`
+	Path   string             `json:"path,omitempty"`
+	Scores map[string]float32 `json:"scores,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// ServeStdio reads newline-delimited JSON requests from r and writes one
+// JSON response line to w for each, until r ends, so scripts can keep one
+// model loaded across many classifications. Malformed requests get an error
+// response rather than ending the loop. If w has a Flush method it is called
+// after every response. Blank lines are ignored.
+func ServeStdio(r io.Reader, w io.Writer, clip categorizer.Classifier, categories []string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStdioLine)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(interface{ Flush() error })
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := enc.Encode(answerStdio(line, clip, categories)); err != nil {
+			return err
+		}
+		if flusher != nil {
+			if err := flusher.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// answerStdio handles one request line.
+func answerStdio(line []byte, clip categorizer.Classifier, categories []string) StdioResponse {
+	var req StdioRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return StdioResponse{Error: "invalid request: " + err.Error()}
+	}
+	resp := StdioResponse{ID: req.ID, Path: req.Path}
+	if req.Path == "" {
+		resp.Error = "request has no path"
+		return resp
+	}
+	if len(req.Categories) == 0 {
+		req.Categories = categories
+	}
+	if len(req.Categories) == 0 {
+		resp.Error = "no categories provided"
+		return resp
+	}
+
+	scores, err := clip.ClassifyWithOptions(req.Path, req.Categories, model.ClassifyOptions{
+		Prompts:    req.Prompts,
+		NoBaseline: req.NoBaseline,
+	})
+	if err != nil {
+		resp.Error = fmt.Sprintf("cannot classify %s: %v", req.Path, err)
+		return resp
+	}
+	resp.Scores = scores
+	return resp
+}
@@ -0,0 +1,110 @@
+package serve
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// fakeClassifier scores every category equally and fails for "bad.jpg".
+type fakeClassifier struct {
+	calls int
+}
+
+func (f *fakeClassifier) ClassifyWithOptions(path string, categories []string, opts model.ClassifyOptions) (map[string]float32, error) {
+	f.calls++
+	if path == "bad.jpg" {
+		return nil, errors.New("cannot decode image")
+	}
+	scores := make(map[string]float32, len(categories))
+	for _, c := range categories {
+		scores[c] = 1 / float32(len(categories))
+	}
+	return scores, nil
+}
+
+func TestServeStdio(t *testing.T) {
+	in := strings.Join([]string{
+		`{"id": 1, "path": "a.jpg", "categories": ["beach", "city"]}`,
+		``,
+		`{"id": "two", "path": "b.jpg"}`,
+		`not json`,
+		`{"id": 3, "categories": ["beach"]}`,
+		`{"id": 4, "path": "bad.jpg"}`,
+	}, "\n")
+	var out strings.Builder
+	clip := &fakeClassifier{}
+	if err := ServeStdio(strings.NewReader(in), &out, clip, []string{"forest"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var resps []StdioResponse
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var r StdioResponse
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("response %q is not JSON: %v", line, err)
+		}
+		resps = append(resps, r)
+	}
+	if len(resps) != 5 {
+		t.Fatalf("expected 5 responses (blank lines ignored), got %d:\n%s", len(resps), out.String())
+	}
+
+	if r := resps[0]; string(r.ID) != "1" || r.Error != "" || len(r.Scores) != 2 {
+		t.Errorf("unexpected first response %+v", r)
+	}
+	if r := resps[1]; string(r.ID) != `"two"` || r.Scores["forest"] != 1 {
+		t.Errorf("request without categories should use the defaults, got %+v", r)
+	}
+	if r := resps[2]; !strings.HasPrefix(r.Error, "invalid request") {
+		t.Errorf("expected an invalid request error, got %+v", r)
+	}
+	if r := resps[3]; string(r.ID) != "3" || r.Error != "request has no path" {
+		t.Errorf("expected a missing path error, got %+v", r)
+	}
+	if r := resps[4]; r.Path != "bad.jpg" || !strings.Contains(r.Error, "cannot decode image") {
+		t.Errorf("expected the classifier's error, got %+v", r)
+	}
+	if clip.calls != 3 {
+		t.Errorf("expected 3 classifications, got %d", clip.calls)
+	}
+}
+
+// TestServeStdioRespondsPerLine checks that each response is written, and
+// flushed, before the next request is read, as a co-process needs.
+func TestServeStdioRespondsPerLine(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	out := bufio.NewWriter(respW)
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeStdio(reqR, out, &fakeClassifier{}, []string{"beach"})
+		respW.Close()
+	}()
+
+	responses := bufio.NewScanner(respR)
+	for _, path := range []string{"a.jpg", "b.jpg"} {
+		if _, err := io.WriteString(reqW, `{"path": "`+path+`"}`+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		if !responses.Scan() {
+			t.Fatalf("no response for %s", path)
+		}
+		var r StdioResponse
+		if err := json.Unmarshal(responses.Bytes(), &r); err != nil || r.Path != path {
+			t.Errorf("unexpected response %s (%v)", responses.Text(), err)
+		}
+	}
+	reqW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if responses.Scan() {
+		t.Errorf("unexpected extra output %q", responses.Text())
+	}
+}
@@ -0,0 +1,109 @@
+// Package sidecar writes a JSON file next to each image describing how it
+// was classified, for cataloging and asset-management tools.
+package sidecar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// SchemaVersion identifies the sidecar format. Fields are only ever added
+// within a version; a change to existing fields bumps it.
+const SchemaVersion = 1
+
+// Ext is appended to the image's file name: photo.jpg gets photo.jpg.json.
+const Ext = ".json"
+
+// DefaultTop is how many categories a sidecar lists.
+const DefaultTop = 5
+
+// Sidecar is the JSON document written for each image.
+type Sidecar struct {
+	Version int    `json:"version"`
+	Image   string `json:"image"`
+	Model   string `json:"model"`
+
+	// Category and Confidence are the decision; Category is empty when the
+	// image was left uncategorized, with Error set if it failed to classify.
+	Category   string  `json:"category,omitempty"`
+	Confidence float32 `json:"confidence,omitempty"`
+	Error      string  `json:"error,omitempty"`
+
+	// Baseline is the score of the generic "uncategorized" prompt, or zero
+	// when the run did not use one.
+	Baseline float32 `json:"baseline"`
+
+	// Top lists the highest-scoring categories, best first, excluding the
+	// baseline.
+	Top []Score `json:"top"`
+
+	ClassifiedAt time.Time `json:"classified_at"`
+}
+
+// Score is one category's raw score.
+type Score struct {
+	Category string  `json:"category"`
+	Score    float32 `json:"score"`
+}
+
+// New builds the sidecar for an image from its decision and raw scores,
+// listing up to top categories.
+func New(r categorizer.Result, is categorizer.ImageScores, modelID string, top int, at time.Time) Sidecar {
+	sc := Sidecar{
+		Version:      SchemaVersion,
+		Image:        filepath.Base(r.Path),
+		Model:        modelID,
+		Error:        is.Error,
+		Baseline:     is.Scores[model.BaselineCategory],
+		Top:          []Score{},
+		ClassifiedAt: at.UTC(),
+	}
+	if !r.Skipped {
+		sc.Category = r.Category
+		sc.Confidence = r.Confidence
+	}
+
+	for _, cs := range model.RankScores(is.Scores) {
+		if !cs.Baseline && len(sc.Top) < top {
+			sc.Top = append(sc.Top, Score{cs.Category, cs.Score})
+		}
+	}
+	return sc
+}
+
+// Write saves sc next to imagePath and returns the sidecar's path.
+func Write(imagePath string, sc Sidecar) (string, error) {
+	data, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := imagePath + Ext
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("cannot write sidecar: %w", err)
+	}
+	return path, nil
+}
+
+// Read loads the sidecar Write saved next to imagePath. ok is false if
+// there is none.
+func Read(imagePath string) (sc Sidecar, ok bool, err error) {
+	data, err := os.ReadFile(imagePath + Ext)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Sidecar{}, false, nil
+	}
+	if err != nil {
+		return Sidecar{}, false, fmt.Errorf("cannot read sidecar: %w", err)
+	}
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return Sidecar{}, false, fmt.Errorf("cannot parse sidecar %s: %w", imagePath+Ext, err)
+	}
+	return sc, true, nil
+}
@@ -0,0 +1,96 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+func TestSidecarMatchesResult(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "beach.jpg")
+	is := categorizer.ImageScores{Path: img, Scores: map[string]float32{
+		model.BaselineCategory: 0.1, "beach": 0.6, "ocean": 0.25, "city": 0.05,
+	}}
+	r := categorizer.DecideOne(is, categorizer.Options{Threshold: 0.15})
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	path, err := Write(img, New(r, is, "test/model", 2, at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != img+".json" {
+		t.Errorf("sidecar path = %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sc Sidecar
+	if err := json.Unmarshal(data, &sc); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Version != SchemaVersion || sc.Image != "beach.jpg" || sc.Model != "test/model" {
+		t.Errorf("unexpected header: %+v", sc)
+	}
+	if sc.Category != r.Category || sc.Confidence != r.Confidence {
+		t.Errorf("decision %s %.2f does not match result %s %.2f", sc.Category, sc.Confidence, r.Category, r.Confidence)
+	}
+	if sc.Baseline != 0.1 {
+		t.Errorf("baseline = %v", sc.Baseline)
+	}
+	if len(sc.Top) != 2 || sc.Top[0] != (Score{"beach", 0.6}) || sc.Top[1] != (Score{"ocean", 0.25}) {
+		t.Errorf("top = %+v", sc.Top)
+	}
+	if sc.Top[1].Category != r.RunnerUp {
+		t.Errorf("second entry %s should be the runner-up %s", sc.Top[1].Category, r.RunnerUp)
+	}
+	if !sc.ClassifiedAt.Equal(at) {
+		t.Errorf("classified_at = %v", sc.ClassifiedAt)
+	}
+}
+
+func TestSidecarSkipped(t *testing.T) {
+	is := categorizer.ImageScores{Path: "broken.jpg", Error: "cannot decode image"}
+	r := categorizer.DecideOne(is, categorizer.Options{Threshold: 0.15})
+
+	sc := New(r, is, "test/model", DefaultTop, time.Now())
+	if sc.Category != "" || sc.Error != is.Error || len(sc.Top) != 0 {
+		t.Errorf("unexpected sidecar for a failed image: %+v", sc)
+	}
+	data, _ := json.Marshal(sc)
+	var raw map[string]any
+	json.Unmarshal(data, &raw)
+	if _, ok := raw["top"].([]any); !ok {
+		t.Errorf("top should encode as an empty list, got %s", data)
+	}
+}
+
+func TestRead(t *testing.T) {
+	img := filepath.Join(t.TempDir(), "beach.jpg")
+	if _, ok, err := Read(img); ok || err != nil {
+		t.Errorf("expected no sidecar, got %v, %v", ok, err)
+	}
+
+	want := Sidecar{Version: SchemaVersion, Image: "beach.jpg", Category: "beach", Confidence: 0.6, Top: []Score{{"beach", 0.6}}}
+	if _, err := Write(img, want); err != nil {
+		t.Fatal(err)
+	}
+	sc, ok, err := Read(img)
+	if !ok || err != nil || sc.Category != "beach" || sc.Confidence != 0.6 {
+		t.Errorf("Read = %+v, %v, %v", sc, ok, err)
+	}
+
+	if err := os.WriteFile(img+Ext, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Read(img); err == nil {
+		t.Error("expected an error for a corrupt sidecar")
+	}
+}
@@ -0,0 +1,94 @@
+// Package state remembers, per sorted directory, when imgsort last sorted
+// it, so --incremental runs only look at newer files.
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
+)
+
+// State is the record kept for one directory.
+type State struct {
+	// Dir is the absolute directory, kept for people reading the file.
+	Dir string `json:"dir"`
+
+	// LastRun is when the last successful run started. Files modified
+	// after it are new to the next incremental run.
+	LastRun time.Time `json:"last_run"`
+}
+
+// Dir returns the state directory: $IMGSORT_STATE_DIR, or ~/.imgsort/state/.
+func Dir() (string, error) {
+	return appdir.Sub(appdir.StateEnv, "state")
+}
+
+// Path returns the state file for dir: a file in the state directory named
+// after a hash of dir's absolute path.
+func Path(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	base, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(base, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Load returns the state recorded for dir. Before the first run it returns
+// a zero State, whose LastRun filters out nothing.
+func Load(dir string) (State, error) {
+	path, err := Path(dir)
+	if err != nil {
+		return State{}, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, fmt.Errorf("cannot read run state: %w", err)
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return State{}, fmt.Errorf("cannot parse run state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save records lastRun as the start of the last successful run over dir.
+func Save(dir string, lastRun time.Time) error {
+	path, err := Path(dir)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(State{Dir: abs, LastRun: lastRun.UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("cannot write run state: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("cannot write run state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("cannot write run state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bagtoad/imgsort/internal/appdir"
+)
+
+func TestLoadSave(t *testing.T) {
+	t.Setenv(appdir.Env, t.TempDir())
+	photos, other := t.TempDir(), t.TempDir()
+
+	s, err := Load(photos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.LastRun.IsZero() {
+		t.Errorf("expected no last run before the first save, got %v", s.LastRun)
+	}
+
+	when := time.Date(2026, 5, 1, 12, 30, 0, 0, time.UTC)
+	if err := Save(photos, when); err != nil {
+		t.Fatal(err)
+	}
+	s, err = Load(photos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.LastRun.Equal(when) || s.Dir != photos {
+		t.Errorf("Load = %+v, want last run %v in %s", s, when, photos)
+	}
+
+	// The same directory given relatively shares the state.
+	wd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Chdir(filepath.Dir(photos)); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := Load(filepath.Base(photos)); err != nil || !s.LastRun.Equal(when) {
+		t.Errorf("relative path should find the saved state, got %+v, %v", s, err)
+	}
+
+	if s, err := Load(other); err != nil || !s.LastRun.IsZero() {
+		t.Errorf("another directory should have no state, got %+v, %v", s, err)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	t.Setenv(appdir.Env, t.TempDir())
+	dir := t.TempDir()
+	path, err := Path(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("expected an error for a corrupt state file")
+	}
+}
+
+func TestStateDirEnv(t *testing.T) {
+	t.Setenv(appdir.Env, t.TempDir())
+	stateDir := t.TempDir()
+	t.Setenv(appdir.StateEnv, stateDir)
+	photos := t.TempDir()
+	if err := Save(photos, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	path, err := Path(photos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != stateDir {
+		t.Errorf("state file %s should be in $%s", path, appdir.StateEnv)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build video
+
+package video
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decodeTimeout bounds each call to ffprobe or ffmpeg, so a damaged file
+// cannot stall a run.
+const decodeTimeout = time.Minute
+
+func init() {
+	Register(extractFFmpeg)
+}
+
+// extractFFmpeg reads the video's duration with ffprobe and has ffmpeg
+// decode the frame at position as a PNG. A video whose duration is unknown
+// gives its first frame.
+func extractFFmpeg(path string, position float64) (image.Image, error) {
+	ffprobe, err := tool("ffprobe")
+	if err != nil {
+		return nil, err
+	}
+	ffmpeg, err := tool("ffmpeg")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := run(ffprobe, "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", "-i", path)
+	if err != nil {
+		return nil, err
+	}
+	var at float64
+	if d, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64); err == nil && d > 0 {
+		at = d * position
+	}
+
+	out, err = run(ffmpeg, "-v", "error", "-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", path, "-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "-")
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no video frames")
+	}
+	return png.Decode(bytes.NewReader(out))
+}
+
+// tool finds the named FFmpeg program, preferring a copy bundled next to
+// the imgsort executable over one on PATH.
+func tool(name string) (string, error) {
+	if exe, err := os.Executable(); err == nil {
+		bundled := filepath.Join(filepath.Dir(exe), name)
+		if p, err := exec.LookPath(bundled); err == nil {
+			return p, nil
+		}
+	}
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found next to imgsort or on PATH", name)
+	}
+	return p, nil
+}
+
+// run runs name with args and returns its standard output. A failure is
+// reported with the last line the program wrote to standard error.
+func run(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), decodeTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s timed out after %s", filepath.Base(name), decodeTimeout)
+		}
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if msg := lines[len(lines)-1]; msg != "" {
+			return nil, fmt.Errorf("%s: %s", filepath.Base(name), msg)
+		}
+		return nil, fmt.Errorf("%s: %w", filepath.Base(name), err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+// Package video extracts a representative frame from video files so they
+// can be classified like images. Decoding needs a decoder that is only
+// compiled in with the video build tag; without it no decoder is
+// registered and videos are left alone like any other non-image file.
+package video
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Extensions contains the video file extensions recognized when a decoder
+// is available.
+var Extensions = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+	".mkv":  true,
+	".webm": true,
+	".avi":  true,
+}
+
+// FramePosition is how far into a video, as a fraction of its duration,
+// the frame to classify is taken from. The very first frame is often black
+// or a fade in.
+const FramePosition = 0.1
+
+// ErrNoDecoder is returned by Frame when imgsort was built without video
+// support.
+var ErrNoDecoder = errors.New("built without video support (rebuild with -tags video)")
+
+// Extractor decodes the frame at position, a fraction of the duration, of
+// the video at path.
+type Extractor func(path string, position float64) (image.Image, error)
+
+var (
+	mu        sync.RWMutex
+	extractor Extractor
+)
+
+// Register installs the decoder used by Frame and returns the one it
+// replaces, if any. A nil e unregisters it. Decoders register themselves in
+// an init function, like image formats.
+func Register(e Extractor) Extractor {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := extractor
+	extractor = e
+	return prev
+}
+
+// Enabled reports whether a decoder is registered.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return extractor != nil
+}
+
+// Is reports whether path has a video extension and a decoder is
+// registered to read it.
+func Is(path string) bool {
+	return Extensions[strings.ToLower(filepath.Ext(path))] && Enabled()
+}
+
+// Frame returns the frame FramePosition of the way into the video at path.
+func Frame(path string) (image.Image, error) {
+	mu.RLock()
+	e := extractor
+	mu.RUnlock()
+	if e == nil {
+		return nil, fmt.Errorf("cannot decode video: %w", ErrNoDecoder)
+	}
+	img, err := e(path, FramePosition)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode video: %w", err)
+	}
+	return img, nil
+}
@@ -0,0 +1,45 @@
+package video
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+// stub registers e for the rest of the test.
+func stub(t *testing.T, e Extractor) {
+	prev := Register(e)
+	t.Cleanup(func() { Register(prev) })
+}
+
+func TestFrame(t *testing.T) {
+	stub(t, nil)
+	if Is("clip.mp4") {
+		t.Error("videos should not be recognized without a decoder")
+	}
+	if _, err := Frame("clip.mp4"); !errors.Is(err, ErrNoDecoder) {
+		t.Errorf("expected ErrNoDecoder, got %v", err)
+	}
+
+	var gotPos float64
+	stub(t, func(path string, position float64) (image.Image, error) {
+		gotPos = position
+		if strings.Contains(path, "broken") {
+			return nil, errors.New("moov atom not found")
+		}
+		return image.NewGray(image.Rect(0, 0, 4, 3)), nil
+	})
+	for path, want := range map[string]bool{"clip.mp4": true, "CLIP.MOV": true, "clip.webm": true, "clip.jpg": false, "clip": false} {
+		if got := Is(path); got != want {
+			t.Errorf("Is(%q) = %v, want %v", path, got, want)
+		}
+	}
+	img, err := Frame("clip.mp4")
+	if err != nil || img.Bounds().Dx() != 4 || gotPos != FramePosition {
+		t.Errorf("unexpected frame %v at %v: %v", img, gotPos, err)
+	}
+	if _, err := Frame("broken.mp4"); err == nil || err.Error() != "cannot decode video: moov atom not found" {
+		t.Errorf("expected a decode error, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Package xattr records an image's category in its extended attributes, so
+// file managers and scripts can read it without a sidecar file.
+package xattr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Attribute names written by Write.
+const (
+	Category   = "user.imgsort.category"
+	Confidence = "user.imgsort.confidence"
+)
+
+// ErrUnsupported is returned (wrapped) when the file system or platform has
+// no user extended attributes, as on FAT, exFAT, and some network mounts.
+var ErrUnsupported = errors.New("extended attributes not supported")
+
+// Write stores category and confidence on the file at path.
+func Write(path, category string, confidence float32) error {
+	if err := set(path, Category, []byte(category)); err != nil {
+		return fmt.Errorf("cannot set %s on %s: %w", Category, path, err)
+	}
+	conf := strconv.FormatFloat(float64(confidence), 'f', 4, 32)
+	if err := set(path, Confidence, []byte(conf)); err != nil {
+		return fmt.Errorf("cannot set %s on %s: %w", Confidence, path, err)
+	}
+	return nil
+}
+
+// Read returns the category and confidence Write stored on path. ok is
+// false if the file has no category attribute.
+func Read(path string) (category string, confidence float32, ok bool, err error) {
+	cat, found, err := get(path, Category)
+	if err != nil || !found {
+		return "", 0, false, err
+	}
+	conf, found, err := get(path, Confidence)
+	if err != nil {
+		return "", 0, false, err
+	}
+	if found {
+		f, err := strconv.ParseFloat(string(conf), 32)
+		if err != nil {
+			return "", 0, false, fmt.Errorf("invalid %s on %s: %w", Confidence, path, err)
+		}
+		confidence = float32(f)
+	}
+	return string(cat), confidence, true, nil
+}
+
+// Remove deletes the attributes Write stores from path. Attributes that
+// are not present are ignored.
+func Remove(path string) error {
+	for _, name := range []string{Category, Confidence} {
+		if err := remove(path, name); err != nil {
+			return fmt.Errorf("cannot remove %s from %s: %w", name, path, err)
+		}
+	}
+	return nil
+}
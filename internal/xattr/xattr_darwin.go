@@ -0,0 +1,84 @@
+package xattr
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// macOS has the same calls as Linux with two more arguments, a position
+// used only for resource forks and an options mask, and the standard
+// library does not wrap them.
+
+func set(path, name string, value []byte) error {
+	p, n, err := cstrings(path, name)
+	if err != nil {
+		return err
+	}
+	var v unsafe.Pointer
+	if len(value) > 0 {
+		v = unsafe.Pointer(&value[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(n)),
+		uintptr(v), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return convert(errno)
+	}
+	return nil
+}
+
+func get(path, name string) ([]byte, bool, error) {
+	p, n, err := cstrings(path, name)
+	if err != nil {
+		return nil, false, err
+	}
+	buf := make([]byte, 256)
+	for {
+		size, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(n)),
+			uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0, 0)
+		switch {
+		case errno == syscall.ENOATTR:
+			return nil, false, nil
+		case errno == syscall.ERANGE:
+			buf = make([]byte, 2*len(buf))
+			continue
+		case errno != 0:
+			return nil, false, convert(errno)
+		}
+		return buf[:size], true, nil
+	}
+}
+
+func remove(path, name string) error {
+	p, n, err := cstrings(path, name)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_REMOVEXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(n)), 0)
+	if errno != 0 && errno != syscall.ENOATTR {
+		return convert(errno)
+	}
+	return nil
+}
+
+// cstrings returns path and name as NUL-terminated strings for a syscall.
+func cstrings(path, name string) (*byte, *byte, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, n, nil
+}
+
+// convert maps the errno macOS returns for file systems without extended
+// attributes to ErrUnsupported.
+func convert(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) {
+		return errors.Join(ErrUnsupported, err)
+	}
+	return err
+}
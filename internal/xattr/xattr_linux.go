@@ -0,0 +1,43 @@
+package xattr
+
+import (
+	"errors"
+	"syscall"
+)
+
+func set(path, name string, value []byte) error {
+	return convert(syscall.Setxattr(path, name, value, 0))
+}
+
+func get(path, name string) ([]byte, bool, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := syscall.Getxattr(path, name, buf)
+		switch {
+		case errors.Is(err, syscall.ENODATA):
+			return nil, false, nil
+		case errors.Is(err, syscall.ERANGE):
+			buf = make([]byte, 2*len(buf))
+			continue
+		case err != nil:
+			return nil, false, convert(err)
+		}
+		return buf[:n], true, nil
+	}
+}
+
+func remove(path, name string) error {
+	if err := syscall.Removexattr(path, name); err != nil && !errors.Is(err, syscall.ENODATA) {
+		return convert(err)
+	}
+	return nil
+}
+
+// convert maps the errno Linux returns for file systems without user
+// extended attributes to ErrUnsupported.
+func convert(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) {
+		return errors.Join(ErrUnsupported, err)
+	}
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package xattr
+
+// Extended attributes are only supported on Linux and macOS; on other
+// platforms every call reports ErrUnsupported.
+
+func set(string, string, []byte) error { return ErrUnsupported }
+
+func get(string, string) ([]byte, bool, error) { return nil, false, ErrUnsupported }
+
+func remove(string, string) error { return ErrUnsupported }
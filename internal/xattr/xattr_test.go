@@ -0,0 +1,60 @@
+//go:build linux || darwin
+
+package xattr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tempImage returns a file in a temporary directory, skipping the test if
+// that file system has no user extended attributes.
+func tempImage(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := set(path, "user.imgsort.probe", []byte("1")); errors.Is(err, ErrUnsupported) {
+		t.Skip("temp directory has no user extended attributes")
+	}
+	remove(path, "user.imgsort.probe")
+	return path
+}
+
+func TestWriteReadRemove(t *testing.T) {
+	path := tempImage(t)
+	if _, _, ok, err := Read(path); err != nil || ok {
+		t.Fatalf("expected no attributes on a new file, got ok=%v err=%v", ok, err)
+	}
+
+	if err := Write(path, "beach", 0.8125); err != nil {
+		t.Fatal(err)
+	}
+	cat, conf, ok, err := Read(path)
+	if err != nil || !ok || cat != "beach" || conf != 0.8125 {
+		t.Errorf("got %q %v ok=%v err=%v, want beach 0.8125", cat, conf, ok, err)
+	}
+	if raw, _, _ := get(path, Confidence); string(raw) != "0.8125" {
+		t.Errorf("confidence stored as %q", raw)
+	}
+
+	if err := Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok, _ := Read(path); ok {
+		t.Error("expected attributes to be removed")
+	}
+	if err := Remove(path); err != nil {
+		t.Errorf("removing missing attributes should succeed, got %v", err)
+	}
+}
+
+func TestWriteMissingFile(t *testing.T) {
+	err := Write(filepath.Join(t.TempDir(), "gone.jpg"), "beach", 0.5)
+	if err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected a plain error for a missing file, got %v", err)
+	}
+}
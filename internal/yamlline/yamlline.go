@@ -0,0 +1,52 @@
+// Package yamlline holds the line-level helpers shared by imgsort's small
+// YAML readers, the categories file and the per-directory settings file.
+// They cover the subset of YAML those files use, not the whole language.
+package yamlline
+
+import "strings"
+
+// StripComment removes a trailing "#" comment. A "#" only starts a comment
+// at the beginning of a line or after whitespace, and never inside a quoted
+// value, so values like "c#" and "shot #1" (with the quotes) survive.
+func StripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && opensValue(line[:i]):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// opensValue reports whether a quote after before starts a quoted value:
+// it begins the line, a flow list item, or follows a "key: " or "- ". A
+// quote inside a plain value, as in "don't", does not.
+func opensValue(before string) bool {
+	trimmed := strings.TrimRight(before, " \t")
+	if trimmed == "" {
+		return true
+	}
+	switch trimmed[len(trimmed)-1] {
+	case '[', ',':
+		return true
+	case ':', '-':
+		return len(trimmed) < len(before)
+	}
+	return false
+}
+
+// Unquote strips matching single or double quotes around s.
+func Unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package yamlline
+
+import "testing"
+
+func TestStripComment(t *testing.T) {
+	tests := []struct{ line, want string }{
+		{"# a comment", ""},
+		{"prompt: a beach  # sunny", "prompt: a beach"},
+		{"label: c#", "label: c#"},
+		{`prompt: "shot #1"`, `prompt: "shot #1"`},
+		{`prompt: "shot #1" # first`, `prompt: "shot #1"`},
+		{`  - 'tag #2'`, `  - 'tag #2'`},
+		{`prompts: ["a #1", 'b #2'] # both`, `prompts: ["a #1", 'b #2']`},
+		{"prompt: don't # note", "prompt: don't"},
+		{"label: well-'known #x", "label: well-'known"},
+	}
+	for _, tt := range tests {
+		if got := StripComment(tt.line); got != tt.want {
+			t.Errorf("StripComment(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`"shot #1"`, "shot #1"},
+		{`'it says "hi"'`, `it says "hi"`},
+		{`"mismatched'`, `"mismatched'`},
+		{`"`, `"`},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		if got := Unquote(tt.in); got != tt.want {
+			t.Errorf("Unquote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
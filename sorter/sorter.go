@@ -0,0 +1,305 @@
+// Package sorter is the public API for embedding imgsort in other programs.
+// A Sorter runs the same scan, classify, decide, and move pipeline as the
+// command-line tool, and reports each phase through optional Hooks.
+package sorter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bagtoad/imgsort/internal/categories"
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// DefaultThreshold is the confidence threshold used when Config.Threshold is zero.
+const DefaultThreshold = 0.15
+
+// ErrSkip is returned by a hook to skip the current file. The file is left
+// in place and the run continues. Any other error returned by a hook aborts
+// the run and is returned from Run.
+var ErrSkip = errors.New("skipped by hook")
+
+// ClassifyFunc scores an image against categories. The result maps each
+// category, plus "uncategorized" for the baseline, to a score in [0, 1].
+type ClassifyFunc func(path string, categories []string) (map[string]float32, error)
+
+// Config controls a sorting run.
+type Config struct {
+	// Categories to sort into. Empty means ~/.imgsort/categories.txt if it
+	// exists, otherwise the built-in defaults.
+	Categories []string
+
+	// Threshold is the minimum confidence for an image to be moved. Zero
+	// means DefaultThreshold.
+	Threshold float64
+
+	// DryRun computes every decision and destination without moving files.
+	DryRun bool
+
+	// OnConflict is the conflict strategy name ("suffix", "hash",
+	// "timestamp", "skip", or "overwrite"). Empty means "suffix".
+	OnConflict string
+
+	// Classify replaces the local CLIP model. When nil, the model is
+	// downloaded on first use and run with the ONNX Runtime library at
+	// ONNXRuntime (empty means the embedded or system library).
+	Classify    ClassifyFunc
+	ONNXRuntime string
+
+	// ModelsDir holds the model files instead of ~/.imgsort/models. It
+	// applies process-wide. Offline checks the staged files against their
+	// recorded hashes instead of downloading missing ones.
+	ModelsDir string
+	Offline   bool
+}
+
+// Decision is the categorization of one image. AfterClassify hooks may
+// change Category or set Skipped.
+type Decision struct {
+	Path       string
+	Category   string
+	Confidence float32
+	Skipped    bool
+	Scores     map[string]float32
+}
+
+// Move describes a file being moved into a category folder.
+type Move struct {
+	Source   string
+	Dest     string
+	Category string
+	DryRun   bool
+}
+
+// Summary counts what happened in a run.
+type Summary struct {
+	Images      int
+	Categorized int
+	Skipped     int
+	Moved       int
+}
+
+// Hooks observe and steer a run. Every field is optional.
+type Hooks struct {
+	// ScanDone is called with the images found, before any classification.
+	ScanDone func(paths []string) error
+
+	// BeforeClassify is called before each image is classified. Returning
+	// ErrSkip skips the image without running the model.
+	BeforeClassify func(path string) error
+
+	// AfterClassify is called with each decision and may modify it to
+	// override the category. Returning ErrSkip skips the image.
+	AfterClassify func(d *Decision) error
+
+	// BeforeMove is called before each file is moved (also in dry runs).
+	// Returning ErrSkip leaves the file in place.
+	BeforeMove func(m Move) error
+
+	// AfterMove is called after each file is moved.
+	AfterMove func(m Move)
+
+	// Done is called once the run completes successfully.
+	Done func(s Summary)
+}
+
+// Sorter sorts directories of images into category folders.
+type Sorter struct {
+	cfg   Config
+	hooks Hooks
+}
+
+// New returns a Sorter with the given configuration and hooks.
+func New(cfg Config, hooks Hooks) *Sorter {
+	if cfg.Threshold == 0 {
+		cfg.Threshold = DefaultThreshold
+	}
+	return &Sorter{cfg: cfg, hooks: hooks}
+}
+
+// Run sorts the images directly inside dir.
+func (s *Sorter) Run(dir string) (Summary, error) {
+	var sum Summary
+
+	strategy, cats, err := s.setup()
+	if err != nil {
+		return sum, err
+	}
+
+	scan, err := scanner.Scan(dir)
+	if err != nil {
+		return sum, err
+	}
+	sum.Images = len(scan.ImagePaths)
+	if err := call(s.hooks.ScanDone, scan.ImagePaths); err != nil {
+		return sum, err
+	}
+
+	// BeforeClassify vetoes are decided up front so the model never sees them.
+	var paths []string
+	var vetoed []categorizer.Result
+	for _, p := range scan.ImagePaths {
+		switch err := call(s.hooks.BeforeClassify, p); {
+		case errors.Is(err, ErrSkip):
+			vetoed = append(vetoed, categorizer.Result{Path: p, Skipped: true, SkipReason: reasonHook})
+		case err != nil:
+			return sum, err
+		default:
+			paths = append(paths, p)
+		}
+	}
+
+	clip, cleanup, err := s.classifier()
+	if err != nil {
+		return sum, err
+	}
+	defer cleanup()
+
+	scores, err := categorizer.ClassifyAll(clip, paths, cats, model.ClassifyOptions{}, nil)
+	if err != nil {
+		return sum, err
+	}
+	results := categorizer.Decide(scores, categorizer.Options{Threshold: s.cfg.Threshold, StoreScores: true})
+
+	for i, r := range results {
+		if results[i], err = s.afterClassify(r); err != nil {
+			return sum, err
+		}
+	}
+	results = append(results, vetoed...)
+
+	moves, err := mover.MoveFiles(dir, results, s.moveOptions(strategy))
+	if err != nil {
+		return sum, err
+	}
+
+	for _, r := range results {
+		if r.Skipped {
+			sum.Skipped++
+		} else {
+			sum.Categorized++
+		}
+	}
+	for _, m := range moves {
+		if !m.Skipped {
+			sum.Moved++
+		}
+	}
+	if s.hooks.Done != nil {
+		s.hooks.Done(sum)
+	}
+	return sum, nil
+}
+
+// setup parses the conflict strategy and resolves the categories to use.
+func (s *Sorter) setup() (mover.ConflictStrategy, []string, error) {
+	strategy := mover.ConflictSuffix
+	if s.cfg.OnConflict != "" {
+		var err error
+		if strategy, err = mover.ParseConflictStrategy(s.cfg.OnConflict); err != nil {
+			return "", nil, err
+		}
+	}
+	cats, err := categories.Resolve(s.cfg.Categories)
+	if err != nil {
+		return "", nil, err
+	}
+	return strategy, cats, nil
+}
+
+// afterClassify runs the AfterClassify hook on r and applies its changes.
+func (s *Sorter) afterClassify(r categorizer.Result) (categorizer.Result, error) {
+	if s.hooks.AfterClassify == nil {
+		return r, nil
+	}
+	d := Decision{Path: r.Path, Category: r.Category, Confidence: r.Confidence, Skipped: r.Skipped, Scores: r.Scores}
+	err := s.hooks.AfterClassify(&d)
+	if errors.Is(err, ErrSkip) {
+		d.Skipped = true
+	} else if err != nil {
+		return r, err
+	}
+	skipped := d.Skipped || d.Category == ""
+	if skipped && !r.Skipped {
+		r.SkipReason = reasonHook
+	}
+	r.Skipped = skipped
+	if r.Skipped {
+		r.Category = ""
+	} else {
+		// A hook may un-skip a result; it then has no skip details.
+		r.Category = d.Category
+		r.SkipReason, r.BestCandidate, r.BestScore, r.Error = "", "", 0, ""
+	}
+	return r, nil
+}
+
+// reasonHook is the skip reason for a file a hook skipped.
+const reasonHook = "skipped by hook"
+
+// moveOptions returns the mover options for a run, with the move hooks
+// adapted to the mover's.
+func (s *Sorter) moveOptions(strategy mover.ConflictStrategy) mover.Options {
+	return mover.Options{
+		DryRun:     s.cfg.DryRun,
+		OnConflict: strategy,
+		BeforeMove: func(m mover.MoveResult) error {
+			err := call(s.hooks.BeforeMove, s.move(m))
+			if errors.Is(err, ErrSkip) {
+				return mover.ErrSkipMove
+			}
+			return err
+		},
+		AfterMove: func(m mover.MoveResult) {
+			if s.hooks.AfterMove != nil {
+				s.hooks.AfterMove(s.move(m))
+			}
+		},
+	}
+}
+
+func (s *Sorter) move(m mover.MoveResult) Move {
+	return Move{Source: m.SourcePath, Dest: m.DestPath, Category: m.Category, DryRun: s.cfg.DryRun}
+}
+
+// classifier returns the configured classifier and a cleanup function.
+func (s *Sorter) classifier() (categorizer.Classifier, func(), error) {
+	if s.cfg.Classify != nil {
+		return funcClassifier(s.cfg.Classify), func() {}, nil
+	}
+	if s.cfg.ModelsDir != "" {
+		model.SetModelsDir(s.cfg.ModelsDir)
+	}
+	var err error
+	if s.cfg.Offline {
+		err = model.VerifyFiles(model.RequiredFiles)
+	} else {
+		err = model.EnsureModels(nil)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("model setup failed: %w", err)
+	}
+	clip, err := model.NewCLIPSession(s.cfg.ONNXRuntime)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load CLIP model: %w", err)
+	}
+	return clip, clip.Destroy, nil
+}
+
+// funcClassifier adapts a ClassifyFunc to categorizer.Classifier.
+type funcClassifier ClassifyFunc
+
+func (f funcClassifier) ClassifyWithOptions(path string, categories []string, _ model.ClassifyOptions) (map[string]float32, error) {
+	return f(path, categories)
+}
+
+// call invokes an optional hook.
+func call[T any](hook func(T) error, arg T) error {
+	if hook == nil {
+		return nil
+	}
+	return hook(arg)
+}
@@ -0,0 +1,199 @@
+package sorter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/model"
+)
+
+// classifyByName scores each image for the category its name starts with.
+func classifyByName(path string, cats []string) (map[string]float32, error) {
+	scores := map[string]float32{"uncategorized": 0.1}
+	for _, c := range cats {
+		scores[c] = 0.05
+		if strings.HasPrefix(filepath.Base(path), c) {
+			scores[c] = 0.8
+		}
+	}
+	return scores, nil
+}
+
+func setupDir(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, n := range names {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(n), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunWithHooks(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg", "beach2.jpg", "city1.jpg", "city2.jpg", "dog1.jpg")
+
+	var scanned, classified, moved []string
+	var done Summary
+	s := New(Config{Categories: []string{"beach", "city", "dog"}, Classify: classifyByName}, Hooks{
+		ScanDone: func(paths []string) error {
+			for _, p := range paths {
+				scanned = append(scanned, filepath.Base(p))
+			}
+			return nil
+		},
+		BeforeClassify: func(path string) error {
+			if filepath.Base(path) == "dog1.jpg" {
+				return ErrSkip
+			}
+			return nil
+		},
+		AfterClassify: func(d *Decision) error {
+			classified = append(classified, filepath.Base(d.Path))
+			if filepath.Base(d.Path) == "city2.jpg" {
+				d.Category = "beach" // override
+			}
+			return nil
+		},
+		BeforeMove: func(m Move) error {
+			if filepath.Base(m.Source) == "beach2.jpg" {
+				return ErrSkip
+			}
+			return nil
+		},
+		AfterMove: func(m Move) {
+			moved = append(moved, m.Category+"/"+filepath.Base(m.Dest))
+		},
+		Done: func(s Summary) { done = s },
+	})
+
+	sum, err := s.Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scanned) != 5 {
+		t.Errorf("expected 5 scanned images, got %v", scanned)
+	}
+	if slices.Contains(classified, "dog1.jpg") || len(classified) != 4 {
+		t.Errorf("vetoed image should not be classified, got %v", classified)
+	}
+	slices.Sort(moved)
+	if want := []string{"beach/beach1.jpg", "beach/city2.jpg", "city/city1.jpg"}; !slices.Equal(moved, want) {
+		t.Errorf("expected moves %v, got %v", want, moved)
+	}
+	if want := (Summary{Images: 5, Categorized: 4, Skipped: 1, Moved: 3}); sum != want || done != want {
+		t.Errorf("expected summary %+v, got %+v (Done saw %+v)", want, sum, done)
+	}
+
+	for _, path := range []string{"beach/city2.jpg", "beach2.jpg", "dog1.jpg"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("expected %s to exist: %v", path, err)
+		}
+	}
+}
+
+func TestRunHookUnskips(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg", "misc.jpg")
+
+	s := New(Config{Categories: []string{"beach"}, Classify: classifyByName}, Hooks{
+		AfterClassify: func(d *Decision) error {
+			if d.Skipped {
+				d.Skipped, d.Category = false, "beach"
+			}
+			return nil
+		},
+	})
+	sum, err := s.Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Moved != 2 || sum.Skipped != 0 {
+		t.Errorf("expected both images moved, got %+v", sum)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "beach", "misc.jpg")); err != nil {
+		t.Errorf("un-skipped image should be moved: %v", err)
+	}
+
+	r, err := s.afterClassify(categorizer.Result{
+		Path: "misc.jpg", Skipped: true, SkipReason: categorizer.SkipBaseline, BestCandidate: "beach", BestScore: 0.05,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Skipped || r.Category != "beach" || r.SkipReason != "" || r.BestCandidate != "" || r.BestScore != 0 {
+		t.Errorf("un-skipped result should keep no skip details, got %+v", r)
+	}
+}
+
+func TestRunHookErrorAborts(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg")
+	abort := errors.New("stop")
+
+	s := New(Config{Categories: []string{"beach"}, Classify: classifyByName}, Hooks{
+		AfterClassify: func(*Decision) error { return abort },
+	})
+	if _, err := s.Run(dir); !errors.Is(err, abort) {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "beach1.jpg")); err != nil {
+		t.Error("aborted run should not move files")
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg")
+
+	var moves []Move
+	s := New(Config{Categories: []string{"beach"}, Classify: classifyByName, DryRun: true}, Hooks{
+		BeforeMove: func(m Move) error { moves = append(moves, m); return nil },
+	})
+	if _, err := s.Run(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || !moves[0].DryRun {
+		t.Errorf("expected one dry-run move, got %+v", moves)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "beach1.jpg")); err != nil {
+		t.Error("dry run should not move files")
+	}
+}
+
+func TestRunOfflineRequiresStagedModels(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg")
+	t.Cleanup(func() { model.SetModelsDir("") })
+
+	s := New(Config{Categories: []string{"beach"}, ModelsDir: t.TempDir(), Offline: true}, Hooks{})
+	if _, err := s.Run(dir); !errors.Is(err, model.ErrNotStaged) {
+		t.Fatalf("expected ErrNotStaged without staged models, got %v", err)
+	}
+}
+
+func TestRunReadOnlyHome(t *testing.T) {
+	home := t.TempDir()
+	if err := os.Chmod(home, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(home, 0755) })
+	if f, err := os.CreateTemp(home, "probe"); err == nil {
+		f.Close()
+		os.Remove(f.Name())
+		t.Skip("permissions are not enforced for this user")
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("IMGSORT_HOME", "")
+
+	dir := setupDir(t, "beach1.jpg", "city1.jpg")
+	sum, err := New(Config{Categories: []string{"beach", "city"}, Classify: classifyByName}, Hooks{}).Run(dir)
+	if err != nil {
+		t.Fatalf("Run with a read-only home: %v", err)
+	}
+	if sum.Moved != 2 {
+		t.Errorf("expected both images moved, got %+v", sum)
+	}
+}
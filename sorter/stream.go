@@ -0,0 +1,207 @@
+package sorter
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"github.com/bagtoad/imgsort/internal/categorizer"
+	"github.com/bagtoad/imgsort/internal/mover"
+	"github.com/bagtoad/imgsort/internal/pipeline"
+	"github.com/bagtoad/imgsort/internal/scanner"
+)
+
+// EventKind says what happened to a file in a SortStream run.
+type EventKind int
+
+const (
+	// EventClassified means the image was categorized. Decision is set,
+	// and a Moved, Skipped, or Failed event for its move follows.
+	EventClassified EventKind = iota + 1
+
+	// EventSkipped means the file was left in place. Reason says why, and
+	// Decision or Move is set if it got that far.
+	EventSkipped
+
+	// EventMoved means the file was moved, or would be in a dry run. Move
+	// is set.
+	EventMoved
+
+	// EventFailed means the image could not be classified or moved. Err is
+	// set, and the run continues with the next file.
+	EventFailed
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventClassified:
+		return "classified"
+	case EventSkipped:
+		return "skipped"
+	case EventMoved:
+		return "moved"
+	case EventFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// Event is the outcome of one step for one file in a SortStream run.
+type Event struct {
+	Kind EventKind
+	Path string
+
+	// Decision is the image's categorization, once it has one, and Move
+	// the planned move, once there is one. Reason says why a file was
+	// skipped, and Err why it failed.
+	Decision *Decision
+	Move     *Move
+	Reason   string
+	Err      error
+}
+
+// errStopped ends the run when the caller stops iterating.
+var errStopped = errors.New("stream stopped")
+
+// SortStream is like Run, but handles each image as the scan finds it and
+// yields what happened to it as events, so memory use stays flat however
+// many files dir holds. Images are visited in file system order.
+//
+// The run happens inside the caller's loop: the next image is not read,
+// classified, or moved until the loop body returns, so a slow consumer
+// slows the run down instead of letting events pile up, and breaking out of
+// the loop stops it. Hooks apply as in Run, except ScanDone, since the full
+// list of images is never known. Done is called if the run completes.
+//
+// Problems with a single file are reported as EventFailed and the run
+// continues. An error that ends the run, such as a scan failure, a hook
+// error other than ErrSkip, or ctx being canceled, is yielded last with a
+// zero Event.
+func (s *Sorter) SortStream(ctx context.Context, dir string) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		var sum Summary
+		strategy, cats, err := s.setup()
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+
+		// The classifier is loaded with the first image, so an empty or
+		// missing directory does not load the model.
+		var cleanup func()
+		defer func() {
+			if cleanup != nil {
+				cleanup()
+			}
+		}()
+
+		// hookErr records a BeforeMove error, which ends the run rather
+		// than failing the one file.
+		var hookErr error
+		moveOpts := s.moveOptions(strategy)
+		beforeMove := moveOpts.BeforeMove
+		moveOpts.BeforeMove = func(m mover.MoveResult) error {
+			err := beforeMove(m)
+			if err != nil && !errors.Is(err, mover.ErrSkipMove) {
+				hookErr = err
+			}
+			return err
+		}
+
+		emit := func(e Event) error {
+			if !yield(e, nil) {
+				return errStopped
+			}
+			return nil
+		}
+
+		// decisions holds the Decision of the image being moved, for the
+		// events of its moves.
+		var decision *Decision
+
+		_, err = pipeline.Run(dir, pipeline.Options{
+			Scan:       scanner.Options{},
+			Categories: cats,
+			Workers:    1,
+			Classifier: func() (categorizer.Classifier, error) {
+				clip, done, err := s.classifier()
+				cleanup = done
+				return clip, err
+			},
+			Route: func(path string) (categorizer.ImageScores, bool, error) {
+				if err := ctx.Err(); err != nil {
+					return categorizer.ImageScores{}, false, err
+				}
+				sum.Images++
+				switch err := call(s.hooks.BeforeClassify, path); {
+				case errors.Is(err, ErrSkip):
+					sum.Skipped++
+					if err := emit(Event{Kind: EventSkipped, Path: path, Reason: reasonHook}); err != nil {
+						return categorizer.ImageScores{}, false, err
+					}
+					return categorizer.ImageScores{}, false, pipeline.ErrSkip
+				case err != nil:
+					return categorizer.ImageScores{}, false, err
+				}
+				return categorizer.ImageScores{}, false, nil
+			},
+			Decide: func(is categorizer.ImageScores) (categorizer.Result, error) {
+				decision = nil
+				if is.Error != "" {
+					sum.Skipped++
+					r := categorizer.Result{Path: is.Path, Skipped: true, Error: is.Error}
+					return r, emit(Event{Kind: EventFailed, Path: is.Path, Err: errors.New(is.Error)})
+				}
+				r := categorizer.DecideOne(is, categorizer.Options{Threshold: s.cfg.Threshold, StoreScores: true})
+				r, err := s.afterClassify(r)
+				if err != nil {
+					return r, err
+				}
+				d := &Decision{Path: r.Path, Category: r.Category, Confidence: r.Confidence, Skipped: r.Skipped, Scores: r.Scores}
+				if r.Skipped {
+					sum.Skipped++
+					return r, emit(Event{Kind: EventSkipped, Path: r.Path, Decision: d, Reason: r.SkipReason})
+				}
+				sum.Categorized++
+				decision = d
+				return r, emit(Event{Kind: EventClassified, Path: r.Path, Decision: d})
+			},
+			Move: moveOpts,
+			Sorted: func(is categorizer.ImageScores, r categorizer.Result, moves []mover.MoveResult, err error) error {
+				if hookErr != nil {
+					return hookErr
+				}
+				if r.Skipped {
+					return nil
+				}
+				if err != nil {
+					return emit(Event{Kind: EventFailed, Path: r.Path, Decision: decision, Err: err})
+				}
+				for _, m := range moves {
+					mv := s.move(m)
+					if m.Skipped {
+						if err := emit(Event{Kind: EventSkipped, Path: r.Path, Decision: decision, Move: &mv, Reason: m.Reason}); err != nil {
+							return err
+						}
+						continue
+					}
+					sum.Moved++
+					if err := emit(Event{Kind: EventMoved, Path: r.Path, Decision: decision, Move: &mv}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+		if errors.Is(err, errStopped) {
+			return
+		}
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		if s.hooks.Done != nil {
+			s.hooks.Done(sum)
+		}
+	}
+}
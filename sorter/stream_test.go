@@ -0,0 +1,171 @@
+package sorter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestSortStreamEvents(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg", "beach2.jpg", "city1.jpg", "dog1.jpg", "notes.txt")
+	if err := os.WriteFile(filepath.Join(dir, "broken.jpg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	classify := func(path string, cats []string) (map[string]float32, error) {
+		if filepath.Base(path) == "broken.jpg" {
+			return nil, errors.New("cannot decode")
+		}
+		return classifyByName(path, cats)
+	}
+
+	var done Summary
+	s := New(Config{Categories: []string{"beach", "city"}, Classify: classify}, Hooks{
+		BeforeMove: func(m Move) error {
+			if filepath.Base(m.Source) == "beach2.jpg" {
+				return ErrSkip
+			}
+			return nil
+		},
+		Done: func(s Summary) { done = s },
+	})
+
+	got := make(map[string][]string)
+	for e, err := range s.SortStream(context.Background(), dir) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[filepath.Base(e.Path)] = append(got[filepath.Base(e.Path)], e.Kind.String())
+		if e.Kind == EventMoved && filepath.Base(e.Move.Dest) != filepath.Base(e.Path) {
+			t.Errorf("unexpected move %+v", e.Move)
+		}
+		if e.Kind == EventFailed && e.Err == nil {
+			t.Errorf("failed event without an error: %+v", e)
+		}
+	}
+
+	want := map[string][]string{
+		"beach1.jpg": {"classified", "moved"},
+		"beach2.jpg": {"classified", "skipped"},
+		"city1.jpg":  {"classified", "moved"},
+		"dog1.jpg":   {"skipped"},
+		"broken.jpg": {"failed"},
+	}
+	for name, kinds := range want {
+		if !slices.Equal(got[name], kinds) {
+			t.Errorf("%s: expected events %v, got %v", name, kinds, got[name])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected events for %d files, got %v", len(want), got)
+	}
+	if want := (Summary{Images: 5, Categorized: 3, Skipped: 2, Moved: 2}); done != want {
+		t.Errorf("expected summary %+v, got %+v", want, done)
+	}
+	for _, path := range []string{"beach/beach1.jpg", "city/city1.jpg", "beach2.jpg", "dog1.jpg"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("expected %s to exist: %v", path, err)
+		}
+	}
+}
+
+func TestSortStreamStopsEarly(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg", "beach2.jpg", "beach3.jpg")
+	s := New(Config{Categories: []string{"beach"}, Classify: classifyByName}, Hooks{
+		Done: func(Summary) { t.Error("Done should not be called for a stopped run") },
+	})
+
+	for e, err := range s.SortStream(context.Background(), dir) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Kind == EventMoved {
+			break
+		}
+	}
+	left, err := filepath.Glob(filepath.Join(dir, "*.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 {
+		t.Errorf("expected the run to stop after one move, got %v left", left)
+	}
+}
+
+func TestSortStreamCanceled(t *testing.T) {
+	dir := setupDir(t, "beach1.jpg", "beach2.jpg")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := New(Config{Categories: []string{"beach"}, Classify: classifyByName, DryRun: true}, Hooks{})
+
+	var last error
+	events := 0
+	for e, err := range s.SortStream(ctx, dir) {
+		if err != nil {
+			last = err
+			continue
+		}
+		events++
+		if e.Kind == EventMoved {
+			cancel()
+		}
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("expected the run to end with context.Canceled, got %v", last)
+	}
+	if events != 2 {
+		t.Errorf("expected events for one image before canceling, got %d", events)
+	}
+}
+
+// TestSortStreamMemoryFlat sorts 100,000 files with a fake classifier and
+// checks that the live heap does not grow with the number of files handled.
+// A dry run is not flat: it remembers each planned name to predict
+// suffixes.
+func TestSortStreamMemoryFlat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("creates 100,000 files")
+	}
+	const files = 100_000
+	dir := t.TempDir()
+	for i := range files {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("beach_%06d.jpg", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	heap := func() uint64 {
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+
+	s := New(Config{Categories: []string{"beach", "city"}, Classify: classifyByName}, Hooks{})
+	var samples []uint64
+	moved := 0
+	for e, err := range s.SortStream(context.Background(), dir) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Kind != EventMoved {
+			continue
+		}
+		if moved++; moved%10_000 == 0 {
+			samples = append(samples, heap())
+		}
+	}
+	if moved != files {
+		t.Fatalf("expected %d moves, got %d", files, moved)
+	}
+
+	// Holding even the paths of every file would take several megabytes.
+	const slack = 1 << 20
+	if growth := int64(slices.Max(samples)) - int64(samples[0]); growth > slack {
+		t.Errorf("heap grew by %d bytes over the run (samples %v)", growth, samples)
+	}
+}
@@ -3,9 +3,13 @@
 package integration_test
 
 import (
+	"bytes"
+	"math"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/bagtoad/imgsort/internal/categories"
 	"github.com/bagtoad/imgsort/internal/categorizer"
@@ -13,6 +17,7 @@ import (
 	"github.com/bagtoad/imgsort/internal/mover"
 	"github.com/bagtoad/imgsort/internal/report"
 	"github.com/bagtoad/imgsort/internal/scanner"
+	"github.com/bagtoad/imgsort/internal/serve"
 )
 
 func TestMain(m *testing.M) {
@@ -80,6 +85,148 @@ func TestCLIPClassifySingleImage(t *testing.T) {
 	t.Logf("Landscape scores: %v", scores)
 }
 
+func TestCLIPWarmup(t *testing.T) {
+	clip := newCLIP(t)
+	if err := clip.Warmup(); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+
+	var times []time.Duration
+	for range 3 {
+		start := time.Now()
+		scores, err := clip.Classify("../testdata/sunset.png", []string{"sunset", "document"})
+		if err != nil {
+			t.Fatalf("Classify after Warmup failed: %v", err)
+		}
+		times = append(times, time.Since(start))
+		if scores["sunset"] <= scores["document"] {
+			t.Errorf("expected sunset to win after warmup, got %v", scores)
+		}
+	}
+	// Warming up pays the runtime's setup, so the first real image is no
+	// slower than the ones after it.
+	steady := min(times[1], times[2])
+	if times[0] > 3*steady {
+		t.Errorf("first image after warm-up took %s, steady state %s", times[0], steady)
+	}
+	t.Logf("classification times after warm-up: %v", times)
+}
+
+func TestCLIPClassifyReader(t *testing.T) {
+	clip := newCLIP(t)
+
+	cats := []string{"landscape", "sunset", "document", "night", "nature", "flower"}
+	want, err := clip.Classify("../testdata/landscape.jpg", cats)
+	if err != nil {
+		t.Fatalf("Classify failed: %v", err)
+	}
+
+	data, err := os.ReadFile("../testdata/landscape.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := clip.ClassifyReader(bytes.NewReader(data), cats, model.ClassifyOptions{})
+	if err != nil {
+		t.Fatalf("ClassifyReader failed: %v", err)
+	}
+
+	for cat, w := range want {
+		if math.Abs(float64(got[cat]-w)) > 1e-5 {
+			t.Errorf("%s: reader score %f, file score %f", cat, got[cat], w)
+		}
+	}
+}
+
+func TestCLIPClassifyRanked(t *testing.T) {
+	clip := newCLIP(t)
+
+	cats := []string{"landscape", "sunset", "document", "night"}
+	scores, err := clip.Classify("../testdata/sunset.png", cats)
+	if err != nil {
+		t.Fatalf("Classify failed: %v", err)
+	}
+	ranked, err := clip.ClassifyRanked("../testdata/sunset.png", cats)
+	if err != nil {
+		t.Fatalf("ClassifyRanked failed: %v", err)
+	}
+
+	if len(ranked) != len(scores) {
+		t.Fatalf("expected %d ranked categories, got %v", len(scores), ranked)
+	}
+	for i, cs := range ranked {
+		if math.Abs(float64(cs.Score-scores[cs.Category])) > 1e-5 {
+			t.Errorf("%s: ranked score %f, map score %f", cs.Category, cs.Score, scores[cs.Category])
+		}
+		if cs.Baseline != (cs.Category == model.BaselineCategory) {
+			t.Errorf("%s: Baseline is %v", cs.Category, cs.Baseline)
+		}
+		if i > 0 && cs.Score > ranked[i-1].Score {
+			t.Errorf("%s ranked below %s despite scoring higher", cs.Category, ranked[i-1].Category)
+		}
+	}
+}
+
+// TestCLIPReusedInputsMatch classifies images in an order that reuses the
+// prepared prompts and tensors, switches category lists, and reuses them
+// again, checking every result against a fresh session.
+func TestCLIPReusedInputsMatch(t *testing.T) {
+	clip := newCLIP(t)
+	cats := []string{"landscape", "sunset", "document", "night", "nature", "flower"}
+	other := []string{"sunset", "document"}
+	chunked := model.ClassifyOptions{ChunkSize: 3}
+	steps := []struct {
+		path string
+		cats []string
+		opts model.ClassifyOptions
+	}{
+		{"../testdata/landscape.jpg", cats, model.ClassifyOptions{}},
+		{"../testdata/sunset.png", cats, model.ClassifyOptions{}},
+		{"../testdata/sunset.png", other, model.ClassifyOptions{}},
+		{"../testdata/landscape.jpg", cats, chunked},
+		{"../testdata/landscape.jpg", cats, model.ClassifyOptions{}},
+	}
+	for _, s := range steps {
+		got, err := clip.ClassifyWithOptions(s.path, s.cats, s.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fresh := newCLIP(t)
+		want, err := fresh.ClassifyWithOptions(s.path, s.cats, s.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for cat, w := range want {
+			if math.Abs(float64(got[cat]-w)) > 1e-6 {
+				t.Errorf("%s %v: reused score for %s is %f, fresh %f", s.path, s.cats, cat, got[cat], w)
+			}
+		}
+	}
+}
+
+func TestCLIPRankImages(t *testing.T) {
+	clip := newCLIP(t)
+
+	paths := []string{
+		"../testdata/landscape.jpg", "../testdata/sunset.png", "../testdata/document.png",
+		"../testdata/dark_scene.png", "../testdata/red_object.jpg",
+	}
+	ranked, err := clip.RankImages(paths, []string{"sunset", "document"}, model.ClassifyOptions{})
+	if err != nil {
+		t.Fatalf("RankImages failed: %v", err)
+	}
+
+	for cat, want := range map[string]string{"sunset": "../testdata/sunset.png", "document": "../testdata/document.png"} {
+		images := ranked[cat]
+		if len(images) != len(paths) {
+			t.Fatalf("%s: expected %d ranked images, got %d", cat, len(paths), len(images))
+		}
+		if images[0].Path != want {
+			t.Errorf("%s: expected %s first, got %+v", cat, want, images)
+		}
+		t.Logf("%s ranking: %+v", cat, images)
+	}
+}
+
 func TestCLIPClassifyAllTestImages(t *testing.T) {
 	clip := newCLIP(t)
 
@@ -98,25 +245,23 @@ func TestCLIPClassifyAllTestImages(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(filepath.Base(tc.image), func(t *testing.T) {
-			scores, err := clip.Classify(tc.image, cats)
+			ranked, err := clip.ClassifyRanked(tc.image, cats)
 			if err != nil {
-				t.Fatalf("Classify failed: %v", err)
+				t.Fatalf("ClassifyRanked failed: %v", err)
 			}
 
-			bestCat := ""
-			bestScore := float32(0)
-			for cat, score := range scores {
-				if cat == model.BaselineCategory {
-					continue
-				}
-				if score > bestScore {
-					bestScore = score
-					bestCat = cat
+			var best, baseline model.CategoryScore
+			for _, cs := range ranked {
+				switch {
+				case cs.Baseline:
+					baseline = cs
+				case best.Category == "":
+					best = cs
 				}
 			}
 
 			t.Logf("%s → %s (%.1f%%, baseline=%.1f%%)",
-				filepath.Base(tc.image), bestCat, bestScore*100, scores[model.BaselineCategory]*100)
+				filepath.Base(tc.image), best.Category, best.Score*100, baseline.Score*100)
 		})
 	}
 }
@@ -174,6 +319,35 @@ func TestSingleCategoryDoesNotAlwaysMatch(t *testing.T) {
 	}
 }
 
+// TestSingleCategoryCalibrated checks that the distractor prompts added for
+// a lone category keep unrelated images well clear of saturation, while an
+// image that does match is still categorized.
+func TestSingleCategoryCalibrated(t *testing.T) {
+	clip := newCLIP(t)
+
+	for _, img := range []string{"dark_scene.png", "document.png"} {
+		scores, err := clip.Classify(filepath.Join("../testdata", img), []string{"cat"})
+		if err != nil {
+			t.Fatalf("Classify failed: %v", err)
+		}
+		t.Logf("%s as 'cat': cat=%.1f%%, baseline=%.1f%%", img, scores["cat"]*100, scores[model.BaselineCategory]*100)
+		if len(scores) != 2 {
+			t.Errorf("%s: expected only cat and the baseline, got %v", img, scores)
+		}
+		if scores["cat"] > 0.5 {
+			t.Errorf("%s should score well below 95%% as 'cat', got %.1f%%", img, scores["cat"]*100)
+		}
+	}
+
+	result, err := categorizer.Categorize(clip, []string{"../testdata/landscape.jpg"}, []string{"landscape"}, 0.15, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := result[0]; r.Skipped || r.Category != "landscape" {
+		t.Errorf("expected landscape.jpg to still match a lone 'landscape' category, got %+v", r)
+	}
+}
+
 func TestFullPipelineDryRun(t *testing.T) {
 	clip := newCLIP(t)
 
@@ -218,7 +392,7 @@ func TestFullPipelineDryRun(t *testing.T) {
 	}
 
 	// Move (dry run)
-	moves, err := mover.MoveFiles(tmpDir, results, true)
+	moves, err := mover.MoveFiles(tmpDir, results, mover.Options{DryRun: true})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -232,7 +406,7 @@ func TestFullPipelineDryRun(t *testing.T) {
 	}
 
 	// Print report
-	report.Print(os.Stdout, results, moves, scanResult.SkippedCount, true)
+	report.Print(os.Stdout, results, moves, scanResult.SkippedCount, true, 0)
 }
 
 func TestFullPipelineWithMove(t *testing.T) {
@@ -255,7 +429,7 @@ func TestFullPipelineWithMove(t *testing.T) {
 	}
 
 	// Actually move files
-	moves, err := mover.MoveFiles(tmpDir, results, false)
+	moves, err := mover.MoveFiles(tmpDir, results, mover.Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -286,7 +460,7 @@ func TestFullPipelineWithMove(t *testing.T) {
 	}
 
 	// Print report
-	report.Print(os.Stdout, results, moves, scanResult.SkippedCount, false)
+	report.Print(os.Stdout, results, moves, scanResult.SkippedCount, false, 0)
 	t.Logf("Successfully moved %d files into %d categories", len(moves), len(catDirs))
 }
 
@@ -318,6 +492,115 @@ func TestCategorizeWithDefaultCategories(t *testing.T) {
 	}
 }
 
+func TestTriageProfile(t *testing.T) {
+	clip := newCLIP(t)
+
+	profile := categories.TriageProfile
+	opts := model.ClassifyOptions{Prompts: profile.Prompts, NoBaseline: profile.NoBaseline}
+
+	testCases := []struct {
+		image    string
+		expected string
+	}{
+		{image: "../testdata/landscape.jpg", expected: "photos"},
+		{image: "../testdata/nature.jpg", expected: "photos"},
+		{image: "../testdata/sunset.png", expected: "photos"},
+		{image: "../testdata/triage/screenshot.png", expected: "screenshots"},
+		{image: "../testdata/triage/meme.jpg", expected: "memes"},
+	}
+
+	paths := make([]string, len(testCases))
+	for i, tc := range testCases {
+		paths[i] = tc.image
+	}
+	scores, err := categorizer.ClassifyAll(clip, paths, profile.Categories, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	correct := 0
+	for i, r := range categorizer.Decide(scores, categorizer.Options{}) {
+		if _, ok := scores[i].Scores[model.BaselineCategory]; ok {
+			t.Errorf("%s: triage scores should not include the baseline", filepath.Base(r.Path))
+		}
+		if r.Category == testCases[i].expected {
+			correct++
+		}
+		t.Logf("%s → %s (%.1f%%, expected %s)",
+			filepath.Base(r.Path), r.Category, r.Confidence*100, testCases[i].expected)
+	}
+
+	accuracy := float64(correct) / float64(len(testCases))
+	t.Logf("Triage accuracy: %d/%d", correct, len(testCases))
+	if accuracy < 0.6 {
+		t.Errorf("triage accuracy %.0f%% is below 60%%", accuracy*100)
+	}
+}
+
+func TestCategorizeStoreScores(t *testing.T) {
+	clip := newCLIP(t)
+
+	cats := []string{"landscape", "sunset", "document"}
+	image := "../testdata/landscape.jpg"
+
+	expected, err := clip.Classify(image, cats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scores, err := categorizer.ClassifyAll(clip, []string{image}, cats, model.ClassifyOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := categorizer.Decide(scores, categorizer.Options{Threshold: 0.10, StoreScores: true})[0]
+
+	if len(r.Scores) != len(expected) {
+		t.Fatalf("expected %d stored scores, got %d", len(expected), len(r.Scores))
+	}
+	for cat, want := range expected {
+		if got := r.Scores[cat]; math.Abs(float64(got-want)) > 1e-5 {
+			t.Errorf("%s: stored score %.5f does not match Classify's %.5f", cat, got, want)
+		}
+	}
+}
+
+func TestServeBackendMatchesLocal(t *testing.T) {
+	clip := newCLIP(t)
+	srv := httptest.NewServer(serve.NewServer(clip, model.ModelID, serve.Options{Concurrency: 1}).Handler())
+	defer srv.Close()
+
+	cats := []string{"landscape", "sunset", "document"}
+	paths := []string{"../testdata/landscape.jpg", "../testdata/sunset.png", "../testdata/document.png"}
+
+	// --remote sends tensors and --backend the files; both must score as
+	// the local model does.
+	for _, upload := range []bool{false, true} {
+		client, err := model.NewRemoteSession(srv.URL, model.RemoteOptions{Upload: upload, Concurrency: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := client.CheckModel(); err != nil {
+			t.Fatal(err)
+		}
+		remote, err := categorizer.ClassifyAllParallel(client, paths, cats, model.ClassifyOptions{}, 2, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, path := range paths {
+			local, err := clip.Classify(path, cats)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for cat, want := range local {
+				if got := remote[i].Scores[cat]; math.Abs(float64(got-want)) > 1e-5 {
+					t.Errorf("upload=%v %s %s: server score %.5f does not match local %.5f", upload, path, cat, got, want)
+				}
+			}
+		}
+	}
+}
+
 // copyTestImages copies image files from testdata to a destination directory.
 func copyTestImages(t *testing.T, dstDir string) {
 	t.Helper()
@@ -39,6 +39,17 @@ func main() {
 
 	// A non-image file for skip testing
 	os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("not an image"), 0644)
+
+	// Extra fixtures for --triage, kept in a subfolder so the top-level
+	// image counts above stay the same
+	triageDir := filepath.Join(dir, "triage")
+	os.MkdirAll(triageDir, 0755)
+
+	// A phone UI with a status bar, app bar, and list rows — screenshot-like
+	generateScreenshot(filepath.Join(triageDir, "screenshot.png"))
+
+	// A photo-like background with bold caption bars — meme-like
+	generateMeme(filepath.Join(triageDir, "meme.jpg"))
 }
 
 func generateSkyGround(path string) {
@@ -121,6 +132,51 @@ func generateDocument(path string) {
 	savePNG(path, img)
 }
 
+func generateScreenshot(path string) {
+	img := image.NewRGBA(image.Rect(0, 0, 224, 224))
+	fillRect(img, 0, 0, 224, 224, color.RGBA{250, 250, 250, 255})
+	// Status bar and app bar
+	fillRect(img, 0, 0, 224, 12, color.RGBA{20, 20, 20, 255})
+	fillRect(img, 0, 12, 224, 40, color.RGBA{33, 150, 243, 255})
+	fillRect(img, 12, 22, 100, 30, color.RGBA{255, 255, 255, 255})
+	// List rows: round-ish icon, two lines of text, divider
+	for row := 0; row < 5; row++ {
+		y := 48 + row*34
+		fillRect(img, 12, y+4, 36, y+28, color.RGBA{76, 175, 80, 255})
+		fillRect(img, 46, y+8, 180, y+12, color.RGBA{60, 60, 60, 255})
+		fillRect(img, 46, y+18, 140, y+21, color.RGBA{150, 150, 150, 255})
+		fillRect(img, 0, y+33, 224, y+34, color.RGBA{220, 220, 220, 255})
+	}
+	savePNG(path, img)
+}
+
+func generateMeme(path string) {
+	img := image.NewRGBA(image.Rect(0, 0, 224, 224))
+	for y := 0; y < 224; y++ {
+		for x := 0; x < 224; x++ {
+			g := uint8(90 + int(60*math.Sin(float64(x)/15)*math.Cos(float64(y)/18)))
+			img.Set(x, y, color.RGBA{140, g, 70, 255})
+		}
+	}
+	// Outlined white block letters across the top and bottom
+	for _, y := range []int{10, 186} {
+		for i := 0; i < 8; i++ {
+			x := 14 + i*25
+			fillRect(img, x-2, y-2, x+20, y+30, color.RGBA{0, 0, 0, 255})
+			fillRect(img, x, y, x+18, y+28, color.RGBA{255, 255, 255, 255})
+		}
+	}
+	saveJPEG(path, img)
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	for y := y0; y < y1 && y < 224; y++ {
+		for x := x0; x < x1 && x < 224; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
 func saveJPEG(path string, img image.Image) {
 	f, _ := os.Create(path)
 	defer f.Close()